@@ -0,0 +1,197 @@
+package attach
+
+import (
+	"bufio"
+	"bytes"
+	"common/protocol"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRequester struct {
+	outputs chan protocol.Output
+}
+
+func (r *testRequester) request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	r.outputs <- output
+	return &protocol.MsgTerminalResponse{}, nil
+}
+
+// testTerminal 模拟终端附件连接
+type testTerminal struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	number uint16
+}
+
+// send 发送2011版协议帧
+func (tt *testTerminal) send(id uint16, body []byte) {
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.BigEndian, id)
+	binary.Write(&raw, binary.BigEndian, uint16(len(body)))
+	raw.Write([]byte{0x01, 0x39, 0x12, 0x34, 0x56, 0x78})
+	binary.Write(&raw, binary.BigEndian, tt.number)
+	raw.Write(body)
+	sum := byte(0)
+	for _, b := range raw.Bytes() {
+		sum ^= b
+	}
+	raw.WriteByte(sum)
+	tt.number++
+
+	frame := []byte{0x7E}
+	for _, b := range raw.Bytes() {
+		switch b {
+		case 0x7E:
+			frame = append(frame, 0x7D, 0x02)
+		case 0x7D:
+			frame = append(frame, 0x7D, 0x01)
+		default:
+			frame = append(frame, b)
+		}
+	}
+	tt.conn.Write(append(frame, 0x7E))
+}
+
+// receive 读取平台应答，返回消息ID与消息体
+func (tt *testTerminal) receive() (uint16, []byte) {
+	tt.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := tt.reader.ReadBytes(0x7E); nil != err {
+		tt.t.Fatal(err)
+	}
+	frame, err := tt.reader.ReadBytes(0x7E)
+	if nil != err {
+		tt.t.Fatal(err)
+	}
+	frame = bytes.ReplaceAll(frame[:len(frame)-1], []byte{0x7D, 0x02}, []byte{0x7E})
+	frame = bytes.ReplaceAll(frame, []byte{0x7D, 0x01}, []byte{0x7D})
+	return binary.BigEndian.Uint16(frame), frame[12 : len(frame)-1]
+}
+
+func fileInfoBody(name string, size uint32) []byte {
+	body := append([]byte{byte(len(name))}, name...)
+	body = append(body, protocol.AttachFileTypeImage)
+	return append(body, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+}
+
+func TestAttachment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attach")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	requester := &testRequester{outputs: make(chan protocol.Output, 1)}
+	server := NewServer(requester.request, Config{Dir: dir, IP: "127.0.0.1", TCPPort: 7611})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	// 带附件的DMS报警触发0x9208
+	marking := protocol.AlarmMarking{TerminalId: "T000001", Time: time.Now().Truncate(time.Second), Count: 1}
+	position := protocol.Position{Auxs: []protocol.PositionAux{{ID: protocol.AuxIDDMSAlarm, Value: &protocol.DMSAlarm{Marking: marking}}}}
+	server.OnPosition("013912345678", &position)
+
+	var cmd *protocol.MsgAttachUploadSb
+	select {
+	case output := <-requester.outputs:
+		cmd = output.(*protocol.MsgAttachUploadSb)
+	case <-time.After(time.Second):
+		t.Fatal("0x9208 not sent")
+	}
+	if "127.0.0.1" != cmd.ServerAddr || 7611 != cmd.TCPPort || 32 != len(cmd.AlarmNo) || "T000001" != cmd.Marking.TerminalId {
+		t.Fatalf("unexpected command: %+v", cmd)
+	}
+	// 等待Request记录报警
+	for idx := 0; idx < 100 && 0 == len(server.Alarms("13912345678")); idx++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	terminal := &testTerminal{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	name := "00_65_6501_0_" + cmd.AlarmNo + ".jpg"
+
+	// 附件列表
+	var body bytes.Buffer
+	body.WriteString("T000001")
+	body.Write(make([]byte, 16))
+	body.WriteString(cmd.AlarmNo)
+	body.Write([]byte{0, 1})
+	body.Write(fileInfoBody(name, 10)[:1+len(name)])
+	body.Write([]byte{0, 0, 0, 10})
+	terminal.send(protocol.MsgIDAlarmAttachInfo, body.Bytes())
+	if id, _ := terminal.receive(); 0x8001 != id {
+		t.Fatalf("got %#x, want 0x8001", id)
+	}
+
+	// 文件信息
+	terminal.send(protocol.MsgIDAttachFileInfo, fileInfoBody(name, 10))
+	if id, resp := terminal.receive(); 0x8001 != id || 0 != resp[4] {
+		t.Fatalf("unexpected response: %#x %x", id, resp)
+	}
+
+	// 发送部分数据后通知完成，应答补传区间
+	write := func(offset uint32, data string) {
+		var buf bytes.Buffer
+		(&protocol.AttachData{Name: name, Offset: offset, Body: []byte(data)}).WriteTo(&buf)
+		conn.Write(buf.Bytes())
+	}
+	write(0, "0123")
+	write(6, "6789")
+	// 超出文件大小的数据被丢弃
+	write(8, "89AB")
+	write(0xFFFFFFF0, "0123")
+	terminal.send(protocol.MsgIDAttachFileFinish, fileInfoBody(name, 10))
+	id, resp := terminal.receive()
+	if want := append(fileInfoBody(name, 0)[:2+len(name)], 1, 1, 0, 0, 0, 4, 0, 0, 0, 2); 0x9212 != id || !bytes.Equal(want, resp) {
+		t.Fatalf("unexpected response: %#x %x", id, resp)
+	}
+
+	write(4, "45")
+	terminal.send(protocol.MsgIDAttachFileFinish, fileInfoBody(name, 10))
+	if id, resp := terminal.receive(); 0x9212 != id || 0 != resp[len(resp)-2] || 0 != resp[len(resp)-1] {
+		t.Fatalf("unexpected response: %#x %x", id, resp)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "13912345678", cmd.AlarmNo, name))
+	if nil != err || "0123456789" != string(data) {
+		t.Fatalf("unexpected file: %q %v", data, err)
+	}
+	info, ok := server.Alarm(cmd.AlarmNo)
+	if !ok || 1 != len(info.Files) || !info.Files[0].Done || 10 != info.Files[0].Received {
+		t.Fatalf("unexpected alarm: %+v", info)
+	}
+
+	// 报警编号不能访问附件目录以外的路径
+	traversal := strings.Repeat("../", 10) + "xx"
+	body.Reset()
+	body.WriteString("T000001")
+	body.Write(make([]byte, 16))
+	body.WriteString(traversal)
+	body.Write([]byte{0, 0})
+	terminal.send(protocol.MsgIDAlarmAttachInfo, body.Bytes())
+	if id, resp := terminal.receive(); 0x8001 != id || 1 != resp[4] {
+		t.Fatalf("unexpected response: %#x %x", id, resp)
+	}
+	terminal.send(protocol.MsgIDAttachFileInfo, fileInfoBody("a.jpg", 1))
+	if id, resp := terminal.receive(); 0x8001 != id || 1 != resp[4] {
+		t.Fatalf("unexpected response: %#x %x", id, resp)
+	}
+	if _, ok := server.Alarm(traversal); ok {
+		t.Fatal("the alarm should not be created")
+	}
+}
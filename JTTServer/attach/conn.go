@@ -0,0 +1,279 @@
+package attach
+
+import (
+	"bufio"
+	"common/protocol"
+	"io"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 附件连接空闲超时时间
+const idleTimeout = time.Minute * 2
+
+// 附件数据包帧头标识首字节
+const attachMagic = byte(0x30)
+
+// 协议帧标识位
+const frameDelimiter = byte(0x7E)
+
+// validPathPart 用作附件目录的手机号及报警编号，避免终端上报的内容访问附件目录以外的路径
+var validPathPart = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+
+// attachConn 一个终端附件连接，协议帧与附件数据包交替传输
+type attachConn struct {
+	server *Server
+	conn   net.Conn
+	reader *bufio.Reader
+	header protocol.Header // 终端最近一次消息的消息头，用于应答
+	number uint16          // 平台消息流水号
+	alarm  *alarm          // 当前上传的报警
+	files  map[string]*openFile
+}
+
+// openFile 正在接收的附件文件
+type openFile struct {
+	*os.File
+	alarm *alarm
+}
+
+func newAttachConn(server *Server, conn net.Conn) *attachConn {
+	return &attachConn{
+		server: server,
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, protocol.MaxFrameSize),
+		files:  make(map[string]*openFile),
+	}
+}
+
+func (c *attachConn) serve() {
+	defer c.conn.Close()
+	defer func() {
+		for _, f := range c.files {
+			f.Close()
+		}
+	}()
+	// 连接上的数据来自未鉴权的终端，处理异常只关闭当前连接
+	defer func() {
+		if r := recover(); nil != r {
+			log.Printf("附件连接[%s]处理异常：%v", c.conn.RemoteAddr(), r)
+		}
+	}()
+
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		lead, err := c.reader.Peek(1)
+		if nil != err {
+			return
+		}
+
+		switch lead[0] {
+		case frameDelimiter:
+			err = c.readFrame()
+		case attachMagic:
+			err = c.readData()
+		default:
+			// 帧外垃圾数据，跳过直到下一个帧标识或数据包头
+			_, err = c.reader.Discard(1)
+		}
+		if nil != err {
+			if io.EOF != err {
+				log.Printf("附件连接[%s]异常：%s", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame 读取并处理一个协议帧
+func (c *attachConn) readFrame() error {
+	c.reader.Discard(1)
+	frame, err := c.reader.ReadSlice(frameDelimiter)
+	if nil != err {
+		return err
+	}
+	// 连续的帧标识位，后一个作为帧开始
+	if 1 == len(frame) {
+		return c.reader.UnreadByte()
+	}
+
+	header, input, err := protocol.DecodeFrame(frame[:len(frame)-1])
+	if nil != err {
+		log.Printf("附件连接[%s]协议帧解码失败：%s", c.conn.RemoteAddr(), err)
+		return nil
+	}
+	c.header = header
+
+	switch msg := input.(type) {
+	case *protocol.MsgAlarmAttachInfo:
+		result := byte(0)
+		if !c.onAttachInfo(msg) {
+			result = 1
+		}
+		return c.reply(protocol.NewMsgServerResponse(msg.Number, msg.ID, result))
+	case *protocol.MsgAttachFileInfo:
+		result := byte(0)
+		if !c.onFileInfo(msg) {
+			result = 1
+		}
+		return c.reply(protocol.NewMsgServerResponse(msg.Number, msg.ID, result))
+	case *protocol.MsgAttachFileFinish:
+		return c.reply(c.onFileFinish(msg))
+	}
+	return nil
+}
+
+// readData 读取并保存一个附件数据包
+func (c *attachConn) readData() error {
+	head, err := c.reader.Peek(protocol.AttachDataHeaderSize)
+	if nil != err {
+		return err
+	}
+	data, length, err := protocol.ParseAttachDataHeader(head)
+	if nil != err {
+		// 非数据包头，跳过当前字节重新同步
+		_, err = c.reader.Discard(1)
+		return err
+	}
+	c.reader.Discard(protocol.AttachDataHeaderSize)
+
+	data.Body = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data.Body); nil != err {
+		return err
+	}
+
+	f, ok := c.files[data.Name]
+	if !ok {
+		log.Printf("附件连接[%s]收到未知文件[%s]的数据", c.conn.RemoteAddr(), data.Name)
+		return nil
+	}
+
+	s := c.server
+	s.mtx.Lock()
+	size := f.alarm.file(data.Name).info.Size
+	s.mtx.Unlock()
+	// 只接受终端声明的文件大小以内的数据
+	if uint64(data.Offset)+uint64(len(data.Body)) > uint64(size) {
+		log.Printf("附件[%s]数据[%d, +%d]超出文件大小%d", data.Name, data.Offset, len(data.Body), size)
+		return nil
+	}
+	if _, err := f.WriteAt(data.Body, int64(data.Offset)); nil != err {
+		log.Printf("附件[%s]写入失败：%s", data.Name, err)
+		return nil
+	}
+
+	s.mtx.Lock()
+	f.alarm.file(data.Name).add(data.Offset, uint32(len(data.Body)))
+	s.mtx.Unlock()
+	return nil
+}
+
+// onAttachInfo 终端上报报警附件列表（0x1210），平台未记录的报警编号（如补传）按上报内容新建。
+// 报警编号及手机号用作附件目录，只接受字母和数字
+func (c *attachConn) onAttachInfo(msg *protocol.MsgAlarmAttachInfo) bool {
+	s := c.server
+	phone := strings.TrimLeft(c.header.Phone, "0")
+	if !validPathPart.MatchString(phone) || !validPathPart.MatchString(msg.AlarmNo) {
+		log.Printf("附件连接[%s]上报的手机号[%q]或报警编号[%q]无效", c.conn.RemoteAddr(), phone, msg.AlarmNo)
+		c.alarm = nil
+		return false
+	}
+
+	s.mtx.Lock()
+	a, ok := s.alarms[msg.AlarmNo]
+	s.mtx.Unlock()
+	if !ok {
+		a = s.create(phone, msg.AlarmNo, msg.Marking)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, file := range msg.Files {
+		a.file(file.Name).info.Size = file.Size
+	}
+	c.alarm = a
+	return true
+}
+
+// onFileInfo 终端上报文件信息（0x1211），打开附件文件准备接收数据
+func (c *attachConn) onFileInfo(msg *protocol.MsgAttachFileInfo) bool {
+	if nil == c.alarm {
+		log.Printf("附件连接[%s]未上报附件列表即上传文件[%s]", c.conn.RemoteAddr(), msg.Name)
+		return false
+	}
+
+	if _, ok := c.files[msg.Name]; !ok {
+		f, err := c.alarm.openFile(msg.Name)
+		if nil != err {
+			log.Printf("附件[%s]创建失败：%s", msg.Name, err)
+			return false
+		}
+		c.files[msg.Name] = &openFile{File: f, alarm: c.alarm}
+	}
+
+	s := c.server
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	file := c.alarm.file(msg.Name)
+	file.info.Type = msg.Type
+	file.info.Size = msg.Size
+	return true
+}
+
+// onFileFinish 终端上报文件上传完成（0x1212），数据不完整时应答需要补传的区间
+func (c *attachConn) onFileFinish(msg *protocol.MsgAttachFileFinish) protocol.Output {
+	resp := protocol.NewMsgAttachFileFinishResp()
+	resp.Name = msg.Name
+	resp.Type = msg.Type
+
+	if nil == c.alarm {
+		resp.Result = 1
+		resp.Resends = []protocol.AttachRange{{Offset: 0, Length: msg.Size}}
+		return resp
+	}
+
+	s := c.server
+	s.mtx.Lock()
+	file := c.alarm.file(msg.Name)
+	file.info.Type = msg.Type
+	file.info.Size = msg.Size
+	resp.Resends = file.missing()
+	done := 0 == len(resp.Resends)
+	file.info.Done = done
+	s.mtx.Unlock()
+
+	if !done {
+		resp.Result = 1
+		return resp
+	}
+	if f, ok := c.files[msg.Name]; ok {
+		f.Truncate(int64(msg.Size))
+		f.Close()
+		delete(c.files, msg.Name)
+	}
+	log.Printf("终端[%s]报警[%s]附件[%s]接收完成", c.alarm.info.Phone, c.alarm.info.AlarmNo, msg.Name)
+	return resp
+}
+
+// reply 应答终端，版本号与手机号取自终端最近一次消息
+func (c *attachConn) reply(output protocol.Output) error {
+	header := protocol.Header{
+		Version: c.header.Version,
+		Phone:   c.header.Phone,
+		Number:  c.number,
+	}
+	c.number++
+
+	frame, err := protocol.EncodeFrame(output, header)
+	if nil != err {
+		log.Printf("附件连接[%s]应答编码失败：%s", c.conn.RemoteAddr(), err)
+		return nil
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+	_, err = c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,342 @@
+package attach
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// AttachApp 默认的报警附件服务，由Run初始化
+	AttachApp *Server
+)
+
+// Run 运行报警附件服务，addr为附件TCP服务监听地址
+//
+// attach.Run("0.0.0.0:7611", jtt.Request, config)
+func Run(addr string, request terminal.Requester, config Config) {
+	AttachApp = NewServer(request, config)
+	go func() {
+		if err := AttachApp.ListenAndServe(addr); nil != err {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// Config 报警附件服务配置
+type Config struct {
+	// 附件存储目录，按终端手机号与报警编号分目录存放
+	Dir string
+	// 下发给终端的附件服务地址
+	IP string
+	// 下发给终端的附件服务TCP端口
+	TCPPort uint16
+	// 下发给终端的附件服务UDP端口，不支持UDP时为0
+	UDPPort uint16
+}
+
+// AttachInfo 报警附件
+type AttachInfo struct {
+	// 文件名称
+	Name string `json:"name"`
+	// 文件类型，见protocol.AttachFileTypeXXX
+	Type byte `json:"type"`
+	// 文件大小
+	Size uint32 `json:"size"`
+	// 已接收的字节数
+	Received uint32 `json:"received"`
+	// 是否接收完整
+	Done bool `json:"done"`
+}
+
+// AlarmInfo 报警及其附件
+type AlarmInfo struct {
+	// 报警编号，平台分配
+	AlarmNo string `json:"alarm_no"`
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 报警标识号
+	Marking protocol.AlarmMarking `json:"marking"`
+	// 附件目录
+	Dir string `json:"dir"`
+	// 附件列表，终端上报0x1210后有效
+	Files []AttachInfo `json:"files"`
+	// 创建时间
+	Created time.Time `json:"created"`
+}
+
+// alarm 报警附件记录，由Server.mtx保护
+type alarm struct {
+	info  AlarmInfo
+	names []string // 附件名称，按上报顺序排列
+	files map[string]*attachFile
+}
+
+// attachFile 附件接收状态
+type attachFile struct {
+	info     AttachInfo
+	received []protocol.AttachRange // 已接收的数据区间，按偏移量排序且不重叠
+}
+
+// snapshot 复制报警信息，调用方需持有锁
+func (a *alarm) snapshot() AlarmInfo {
+	info := a.info
+	info.Files = make([]AttachInfo, 0, len(a.names))
+	for _, name := range a.names {
+		info.Files = append(info.Files, a.files[name].info)
+	}
+	return info
+}
+
+// file 获取附件记录，不存在时新建，调用方需持有锁
+func (a *alarm) file(name string) *attachFile {
+	if f, ok := a.files[name]; ok {
+		return f
+	}
+	f := &attachFile{info: AttachInfo{Name: name}}
+	a.files[name] = f
+	a.names = append(a.names, name)
+	return f
+}
+
+// add 记录已接收的数据区间
+func (f *attachFile) add(offset, length uint32) {
+	if 0 == length {
+		return
+	}
+	ranges := append(f.received, protocol.AttachRange{Offset: offset, Length: length})
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Offset < ranges[j].Offset
+	})
+
+	// 合并重叠或相邻的区间
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Offset <= last.Offset+last.Length {
+			if end := r.Offset + r.Length; end > last.Offset+last.Length {
+				last.Length = end - last.Offset
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	f.received = merged
+
+	f.info.Received = 0
+	for _, r := range merged {
+		f.info.Received += r.Length
+	}
+}
+
+// missing 计算尚未接收的数据区间
+func (f *attachFile) missing() []protocol.AttachRange {
+	var ranges []protocol.AttachRange
+	offset := uint32(0)
+	for _, r := range f.received {
+		if r.Offset >= f.info.Size {
+			break
+		}
+		if r.Offset > offset {
+			ranges = append(ranges, protocol.AttachRange{Offset: offset, Length: r.Offset - offset})
+		}
+		offset = r.Offset + r.Length
+	}
+	if offset < f.info.Size {
+		ranges = append(ranges, protocol.AttachRange{Offset: offset, Length: f.info.Size - offset})
+	}
+	return ranges
+}
+
+// Server 主动安全（苏标）报警附件服务。
+//
+// 终端上报带附件的报警后，平台下发0x9208通知终端连接附件服务；终端在附件连接上依次发送
+// 0x1210附件列表、每个附件的0x1211文件信息、30 31 63 64数据包及0x1212上传完成，
+// 平台以0x9212应答并给出需要补传的数据区间。附件按终端手机号与报警编号分目录存放。
+type Server struct {
+	request terminal.Requester
+	config  Config
+
+	mtx      sync.Mutex
+	alarms   map[string]*alarm
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// NewServer 新建报警附件服务
+func NewServer(request terminal.Requester, config Config) *Server {
+	if "" == config.Dir {
+		config.Dir = "attachments"
+	}
+
+	return &Server{
+		request: request,
+		config:  config,
+		alarms:  make(map[string]*alarm),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe 监听附件TCP服务，阻塞直到服务关闭
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在已建立的监听上服务，阻塞直到服务关闭
+func (s *Server) Serve(l net.Listener) error {
+	s.mtx.Lock()
+	s.listener = l
+	s.mtx.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if nil != err {
+			return err
+		}
+
+		s.mtx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mtx.Unlock()
+
+		go func() {
+			newAttachConn(s, conn).serve()
+
+			s.mtx.Lock()
+			delete(s.conns, conn)
+			s.mtx.Unlock()
+		}()
+	}
+}
+
+// Close 关闭服务及所有连接
+func (s *Server) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if nil != s.listener {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// OnPosition 处理终端位置信息，报警附加信息中附件数量不为0时异步下发附件上传指令
+func (s *Server) OnPosition(phone string, position *protocol.Position) {
+	for _, marking := range position.AlarmMarkings() {
+		if 0 == marking.Count {
+			continue
+		}
+		go func(marking protocol.AlarmMarking) {
+			if _, err := s.Request(phone, marking); nil != err {
+				log.Printf("终端[%s]报警附件上传指令下发失败：%s", phone, err)
+			}
+		}(marking)
+	}
+}
+
+// Request 为报警分配报警编号并下发附件上传指令（0x9208），终端通用应答成功后返回报警信息
+func (s *Server) Request(phone string, marking protocol.AlarmMarking) (AlarmInfo, error) {
+	phone = strings.TrimLeft(phone, "0")
+
+	// 报警编号为32字节
+	a := s.create(phone, util.RandomHex(16), marking)
+
+	msg := protocol.NewMsgAttachUploadSb()
+	msg.ServerAddr = s.config.IP
+	msg.TCPPort = s.config.TCPPort
+	msg.UDPPort = s.config.UDPPort
+	msg.Marking = marking
+	msg.AlarmNo = a.info.AlarmNo
+
+	input, err := s.request(phone, msg, terminal.RequestTimeout)
+	if nil == err {
+		if resp, ok := input.(*protocol.MsgTerminalResponse); ok && 0 != resp.Result {
+			err = terminal.ErrRejected
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if nil != err {
+		delete(s.alarms, a.info.AlarmNo)
+		return AlarmInfo{}, err
+	}
+	return a.snapshot(), nil
+}
+
+// create 新建报警附件记录
+func (s *Server) create(phone, alarmNo string, marking protocol.AlarmMarking) *alarm {
+	a := &alarm{
+		info: AlarmInfo{
+			AlarmNo: alarmNo,
+			Phone:   phone,
+			Marking: marking,
+			Dir:     filepath.Join(s.config.Dir, phone, alarmNo),
+			Created: time.Now(),
+		},
+		files: make(map[string]*attachFile),
+	}
+
+	s.mtx.Lock()
+	s.alarms[alarmNo] = a
+	s.mtx.Unlock()
+	return a
+}
+
+// Alarm 获取报警附件信息
+func (s *Server) Alarm(alarmNo string) (AlarmInfo, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	a, ok := s.alarms[alarmNo]
+	if !ok {
+		return AlarmInfo{}, false
+	}
+	return a.snapshot(), true
+}
+
+// Alarms 获取报警附件信息，phone为空时返回所有终端，按创建时间排序
+func (s *Server) Alarms(phone string) []AlarmInfo {
+	phone = strings.TrimLeft(phone, "0")
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	list := make([]AlarmInfo, 0, len(s.alarms))
+	for _, a := range s.alarms {
+		if "" == phone || phone == a.info.Phone {
+			list = append(list, a.snapshot())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
+
+// path 附件本地路径，文件名只取最后一级，不会超出报警目录
+func (a *alarm) path(name string) string {
+	return filepath.Join(a.info.Dir, filepath.Base(filepath.FromSlash("/"+name)))
+}
+
+// openFile 打开附件文件用于写入，保留已接收的数据以便补传
+func (a *alarm) openFile(name string) (*os.File, error) {
+	if err := os.MkdirAll(a.info.Dir, 0755); nil != err {
+		return nil, err
+	}
+	return os.OpenFile(a.path(name), os.O_WRONLY|os.O_CREATE, 0644)
+}
@@ -0,0 +1,109 @@
+package canbus
+
+import (
+	"common/protocol"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// CanApp 默认的CAN总线数据解码服务，由Setup初始化，未配置DBC文件时为空
+	CanApp *Monitor
+)
+
+// Setup 加载DBC文件并初始化默认的CAN总线数据解码服务
+//
+// canbus.Setup("conf/vehicle.dbc")
+func Setup(dbcFile string) error {
+	dbc, err := protocol.LoadDBCFile(dbcFile)
+	if nil != err {
+		return err
+	}
+	CanApp = NewMonitor(dbc)
+	return nil
+}
+
+// Snapshot 终端最近一次上报的各信号值
+type Snapshot struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 最近一次上报的平台接收时间
+	Updated time.Time `json:"updated"`
+	// 各信号的最新值，按报文名称和信号名称排序
+	Values []Value `json:"values"`
+}
+
+// Value 信号值及其上报时间
+type Value struct {
+	protocol.CanValue
+	// 平台接收时间
+	Updated time.Time `json:"updated"`
+}
+
+// Monitor CAN总线数据（0x0705）解码服务，按DBC定义解码信号并保留每个终端各信号的最新值
+type Monitor struct {
+	dbc *protocol.DBC
+
+	mtx    sync.Mutex
+	latest map[string]map[string]Value // 终端手机号 -> 报文名称.信号名称 -> 最新值
+}
+
+// NewMonitor 新建CAN总线数据解码服务
+func NewMonitor(dbc *protocol.DBC) *Monitor {
+	return &Monitor{
+		dbc:    dbc,
+		latest: make(map[string]map[string]Value),
+	}
+}
+
+// OnReport 解码终端上报的CAN总线数据，返回本次上报的信号值
+func (m *Monitor) OnReport(phone string, msg *protocol.MsgCANDataReport) []protocol.CanValue {
+	phone = strings.TrimLeft(phone, "0")
+	values := m.dbc.Decode(msg)
+	if 0 == len(values) {
+		return values
+	}
+
+	now := time.Now()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	latest, ok := m.latest[phone]
+	if !ok {
+		latest = make(map[string]Value)
+		m.latest[phone] = latest
+	}
+	for _, value := range values {
+		latest[value.Message+"."+value.Signal] = Value{CanValue: value, Updated: now}
+	}
+	return values
+}
+
+// Latest 获取终端各信号的最新值
+func (m *Monitor) Latest(phone string) (Snapshot, bool) {
+	phone = strings.TrimLeft(phone, "0")
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	latest, ok := m.latest[phone]
+	if !ok {
+		return Snapshot{}, false
+	}
+	snapshot := Snapshot{Phone: phone, Values: make([]Value, 0, len(latest))}
+	for _, value := range latest {
+		snapshot.Values = append(snapshot.Values, value)
+		if value.Updated.After(snapshot.Updated) {
+			snapshot.Updated = value.Updated
+		}
+	}
+	sort.Slice(snapshot.Values, func(i, j int) bool {
+		a, b := snapshot.Values[i], snapshot.Values[j]
+		if a.Message != b.Message {
+			return a.Message < b.Message
+		}
+		return a.Signal < b.Signal
+	})
+	return snapshot, true
+}
@@ -0,0 +1,44 @@
+package canbus
+
+import (
+	"common/protocol"
+	"strings"
+	"testing"
+)
+
+const testDBC = `BO_ 2364540158 EEC1: 8 Vector__XXX
+ SG_ EngineSpeed : 24|16@1+ (0.125,0) [0|8031.875] "rpm" Vector__XXX
+ SG_ DriverDemandTorque : 8|8@1+ (1,-125) [-125|125] "%" Vector__XXX
+`
+
+func TestMonitor(t *testing.T) {
+	dbc, err := protocol.LoadDBC(strings.NewReader(testDBC))
+	if nil != err {
+		t.Fatal(err)
+	}
+	m := NewMonitor(dbc)
+
+	msg := &protocol.MsgCANDataReport{Datas: []protocol.CanData{
+		{ID: 0x4CF004FE, Data: [8]byte{0, 150, 0, 0xE0, 0x2E}},
+		{ID: 0x4CF004FE, Data: [8]byte{0, 175, 0, 0x40, 0x38}},
+		{ID: 0x40000100, Data: [8]byte{1}},
+	}}
+	if values := m.OnReport("013912345678", msg); 4 != len(values) {
+		t.Fatalf("got %d values, want 4", len(values))
+	}
+
+	// 保留每个信号的最新值
+	snapshot, ok := m.Latest("13912345678")
+	if !ok || 2 != len(snapshot.Values) {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	if v := snapshot.Values[0]; "DriverDemandTorque" != v.Signal || 50 != v.Value {
+		t.Fatalf("unexpected value: %+v", v)
+	}
+	if v := snapshot.Values[1]; "EngineSpeed" != v.Signal || 1800 != v.Value || "rpm" != v.Unit {
+		t.Fatalf("unexpected value: %+v", v)
+	}
+	if _, ok := m.Latest("13900000000"); ok {
+		t.Fatal("unexpected snapshot")
+	}
+}
@@ -0,0 +1,703 @@
+package contacts
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ContactsApp 默认的电话本服务，由Setup初始化
+	ContactsApp *Manager
+)
+
+var (
+	// ErrFleetNotFound 车队不存在
+	ErrFleetNotFound = errors.New("the fleet does not exist")
+	// ErrDriverNotFound 驾驶员不存在
+	ErrDriverNotFound = errors.New("the driver does not exist")
+	// ErrTerminalNotFound 终端不属于任何车队且没有驾驶员
+	ErrTerminalNotFound = errors.New("the terminal has no contacts")
+	// ErrContactNotFound 修改的联系人不存在
+	ErrContactNotFound = errors.New("the contact does not exist")
+	// ErrContactExists 追加的联系人已存在
+	ErrContactExists = errors.New("the contact already exists")
+	// ErrInvalidNumber 回拨的电话号码无效
+	ErrInvalidNumber = errors.New("invalid callback number")
+	// ErrUnknownOperation 未知的电话本操作
+	ErrUnknownOperation = errors.New("unknown contacts operation")
+)
+
+// Setup 初始化默认的电话本服务，offline为终端不在线时Requester返回的错误，
+// file为车队、驾驶员及终端电话本的存储文件，为空时不持久化
+//
+// contacts.Setup(jtt.Request, jtt.ErrClientOffline, "contacts.json")
+func Setup(request terminal.Requester, offline error, file string) error {
+	m := NewManager(request, offline, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	ContactsApp = m
+	return nil
+}
+
+// 电话本操作，对应0x8401的设置电话本类型
+const (
+	OpDelete = "delete" // 删除全部联系人
+	OpUpdate = "update" // 以消息中的联系人替换全部联系人
+	OpAppend = "append" // 追加联系人
+	OpModify = "modify" // 以联系人姓名为索引修改联系人
+)
+
+// 联系人权限，对应联系人标志
+const (
+	PermissionIn   = "in"   // 呼入
+	PermissionOut  = "out"  // 呼出
+	PermissionBoth = "both" // 呼入/呼出
+)
+
+// permissionFlags 权限对应的联系人标志
+var permissionFlags = map[string]byte{
+	PermissionIn:   protocol.ContactIn,
+	PermissionOut:  protocol.ContactOut,
+	PermissionBoth: protocol.ContactBoth,
+}
+
+// 终端电话本同步状态
+const (
+	StateSynced  = "synced"  // 已下发
+	StatePending = "pending" // 终端不在线或已重新注册，终端鉴权后下发
+	StateFailed  = "failed"  // 下发失败
+)
+
+// Contact 联系人，姓名在电话本中唯一
+type Contact struct {
+	// 联系人姓名
+	Name string `json:"name"`
+	// 电话号码
+	Phone string `json:"phone"`
+	// 权限，见PermissionXXX
+	Permission string `json:"permission"`
+}
+
+// message 转换为消息中的联系人项
+func (c *Contact) message() protocol.Contact {
+	return protocol.Contact{Flag: permissionFlags[c.Permission], Phone: c.Phone, Name: c.Name}
+}
+
+// validate 校验联系人，检查权限、号码及姓名长度
+func (c *Contact) validate() error {
+	if _, ok := permissionFlags[c.Permission]; !ok {
+		return fmt.Errorf("unknown permission %q of %s", c.Permission, c.Name)
+	}
+	contact := c.message()
+	return contact.Validate()
+}
+
+// Fleet 车队，车队的联系人下发到车队的全部终端
+type Fleet struct {
+	// 车队名称
+	Name string `json:"name"`
+	// 联系人
+	Contacts []Contact `json:"contacts"`
+	// 终端手机号
+	Phones []string `json:"phones"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Driver 驾驶员，驾驶员的联系人随驾驶员插卡下发到所驾驶车辆的终端
+type Driver struct {
+	// 从业资格证编码
+	Credential string `json:"credential"`
+	// 驾驶员姓名
+	Name string `json:"name"`
+	// 联系人
+	Contacts []Contact `json:"contacts"`
+	// 当前驾驶车辆的终端手机号，未插卡时为空
+	Phone string `json:"phone,omitempty"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Terminal 终端电话本
+type Terminal struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 所属车队
+	Fleet string `json:"fleet,omitempty"`
+	// 当前驾驶员的从业资格证编码
+	Driver string `json:"driver,omitempty"`
+	// 当前驾驶员姓名
+	DriverName string `json:"driver_name,omitempty"`
+	// 最近一次下发成功的联系人
+	Contacts []Contact `json:"contacts"`
+	// 同步状态，见StateXXX
+	State string `json:"state"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 最近一次同步成功的时间
+	Synced time.Time `json:"synced"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Manager 电话本服务。
+//
+// 按车队维护联系人并以0x8401下发到车队的全部终端，驾驶员的联系人随驾驶员插卡（0x0702）
+// 下发到所驾驶车辆，换车后从原车辆删除；根据终端上已有的联系人选择删除、更新、追加或修改方式下发，
+// 终端不在线或重新注册后在鉴权时下发。以0x8400对终端发起电话回拨（普通通话或监听）。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	file    string
+
+	mtx       sync.Mutex
+	fleets    map[string]*Fleet
+	drivers   map[string]*Driver
+	terminals map[string]*Terminal
+	// 终端正在下发，避免并发下发顺序错乱
+	busy map[string]*sync.Mutex
+	// 在新的goroutine中进行的下发
+	syncing sync.WaitGroup
+}
+
+// NewManager 新建电话本服务
+func NewManager(request terminal.Requester, offline error, file string) *Manager {
+	return &Manager{
+		request:   request,
+		offline:   offline,
+		file:      file,
+		fleets:    make(map[string]*Fleet),
+		drivers:   make(map[string]*Driver),
+		terminals: make(map[string]*Terminal),
+		busy:      make(map[string]*sync.Mutex),
+	}
+}
+
+// SaveFleet 新建或更新车队，一台终端只能属于一个车队。车队原有及现有的终端在新的goroutine中下发
+func (m *Manager) SaveFleet(f Fleet) (Fleet, error) {
+	if "" == f.Name {
+		return Fleet{}, errors.New("the fleet name is required")
+	}
+	if err := validate(f.Contacts); nil != err {
+		return Fleet{}, err
+	}
+	f.Phones = unique(f.Phones)
+	f.Updated = time.Now()
+
+	m.mtx.Lock()
+	for _, phone := range f.Phones {
+		if t, ok := m.terminals[phone]; ok && "" != t.Fleet && f.Name != t.Fleet {
+			m.mtx.Unlock()
+			return Fleet{}, fmt.Errorf("the terminal %s belongs to the fleet %s", phone, t.Fleet)
+		}
+	}
+	var phones []string
+	if old, ok := m.fleets[f.Name]; ok {
+		phones = append(phones, old.Phones...)
+	}
+	m.fleets[f.Name] = &f
+	m.assign(f.Name, phones, f.Phones)
+	m.save()
+	info := f.snapshot()
+	m.mtx.Unlock()
+
+	m.syncAll(append(phones, f.Phones...))
+	return info, nil
+}
+
+// Apply 以op修改车队的联系人并在新的goroutine中下发到车队的全部终端，op见OpXXX，删除时忽略contacts
+func (m *Manager) Apply(name string, op string, contacts []Contact) (Fleet, error) {
+	if OpDelete != op {
+		if err := validate(contacts); nil != err {
+			return Fleet{}, err
+		}
+	}
+
+	m.mtx.Lock()
+	f, ok := m.fleets[name]
+	if !ok {
+		m.mtx.Unlock()
+		return Fleet{}, ErrFleetNotFound
+	}
+	updated, err := apply(f.Contacts, op, contacts)
+	if nil != err {
+		m.mtx.Unlock()
+		return Fleet{}, err
+	}
+	f.Contacts, f.Updated = updated, time.Now()
+	m.save()
+	info := f.snapshot()
+	m.mtx.Unlock()
+
+	m.syncAll(info.Phones)
+	return info, nil
+}
+
+// DeleteFleet 删除车队，车队的联系人在新的goroutine中从终端删除
+func (m *Manager) DeleteFleet(name string) error {
+	m.mtx.Lock()
+	f, ok := m.fleets[name]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrFleetNotFound
+	}
+	delete(m.fleets, name)
+	m.assign(name, f.Phones, nil)
+	m.save()
+	m.mtx.Unlock()
+
+	m.syncAll(f.Phones)
+	return nil
+}
+
+// Fleet 获取车队
+func (m *Manager) Fleet(name string) (Fleet, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	f, ok := m.fleets[name]
+	if !ok {
+		return Fleet{}, false
+	}
+	return f.snapshot(), true
+}
+
+// Fleets 获取全部车队，按名称排列
+func (m *Manager) Fleets() []Fleet {
+	m.mtx.Lock()
+	fleets := make([]Fleet, 0, len(m.fleets))
+	for _, f := range m.fleets {
+		fleets = append(fleets, f.snapshot())
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(fleets, func(i, j int) bool {
+		return fleets[i].Name < fleets[j].Name
+	})
+	return fleets
+}
+
+// SaveDriver 新建或更新驾驶员，驾驶员正在驾驶的车辆在新的goroutine中下发
+func (m *Manager) SaveDriver(d Driver) (Driver, error) {
+	d.Credential = strings.TrimSpace(d.Credential)
+	if "" == d.Credential {
+		return Driver{}, errors.New("the driver credential is required")
+	}
+	if err := validate(d.Contacts); nil != err {
+		return Driver{}, err
+	}
+	d.Phone, d.Updated = "", time.Now()
+
+	m.mtx.Lock()
+	if old, ok := m.drivers[d.Credential]; ok {
+		d.Phone = old.Phone
+	}
+	m.drivers[d.Credential] = &d
+	m.save()
+	info := d.snapshot()
+	m.mtx.Unlock()
+
+	m.syncAll([]string{info.Phone})
+	return info, nil
+}
+
+// DeleteDriver 删除驾驶员，驾驶员的联系人在新的goroutine中从所驾驶的车辆删除
+func (m *Manager) DeleteDriver(credential string) error {
+	m.mtx.Lock()
+	d, ok := m.drivers[credential]
+	if !ok {
+		m.mtx.Unlock()
+		return ErrDriverNotFound
+	}
+	delete(m.drivers, credential)
+	m.save()
+	m.mtx.Unlock()
+
+	m.syncAll([]string{d.Phone})
+	return nil
+}
+
+// Drivers 获取全部驾驶员，按从业资格证编码排列
+func (m *Manager) Drivers() []Driver {
+	m.mtx.Lock()
+	drivers := make([]Driver, 0, len(m.drivers))
+	for _, d := range m.drivers {
+		drivers = append(drivers, d.snapshot())
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(drivers, func(i, j int) bool {
+		return drivers[i].Credential < drivers[j].Credential
+	})
+	return drivers
+}
+
+// Terminal 获取终端电话本
+func (m *Manager) Terminal(phone string) (Terminal, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t, ok := m.terminals[phone]
+	if !ok {
+		return Terminal{}, false
+	}
+	return t.snapshot(), true
+}
+
+// Terminals 获取全部终端电话本，按手机号排列
+func (m *Manager) Terminals() []Terminal {
+	m.mtx.Lock()
+	terminals := make([]Terminal, 0, len(m.terminals))
+	for _, t := range m.terminals {
+		terminals = append(terminals, t.snapshot())
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(terminals, func(i, j int) bool {
+		return terminals[i].Phone < terminals[j].Phone
+	})
+	return terminals
+}
+
+// Sync 将车队及当前驾驶员的联系人下发到终端，与终端上已有的联系人一致时不下发。
+// 终端不在线时记为等待下发，终端鉴权后下发
+func (m *Manager) Sync(phone string) (Terminal, error) {
+	lock := m.lock(phone)
+	lock.Lock()
+	defer lock.Unlock()
+
+	m.mtx.Lock()
+	t, ok := m.terminals[phone]
+	if !ok {
+		m.mtx.Unlock()
+		return Terminal{}, ErrTerminalNotFound
+	}
+	book := m.book(t)
+	outputs, err := plan(t, book)
+	m.mtx.Unlock()
+	if nil != err {
+		m.setState(phone, StateFailed, nil, err)
+		info, _ := m.Terminal(phone)
+		return info, nil
+	}
+
+	for _, output := range outputs {
+		if err = m.request.Send(phone, output); nil != err {
+			break
+		}
+	}
+	switch {
+	case nil == err:
+		m.setState(phone, StateSynced, book, nil)
+	case nil != m.offline && errors.Is(err, m.offline):
+		m.setState(phone, StatePending, nil, nil)
+	default:
+		m.setState(phone, StateFailed, nil, err)
+	}
+
+	info, _ := m.Terminal(phone)
+	return info, nil
+}
+
+// Callback 电话回拨，终端拨打number，listen为true时监听，否则为普通通话
+func (m *Manager) Callback(phone string, number string, listen bool) error {
+	msg, err := protocol.NewMsgCallback(number, listen)
+	if nil != err {
+		return fmt.Errorf("%w: %v", ErrInvalidNumber, err)
+	}
+	if err := m.request.Send(phone, msg); nil != err {
+		return err
+	}
+	log.Printf("终端[%s]电话回拨 %s 监听：%v", phone, number, listen)
+	return nil
+}
+
+// OnRegister 终端注册，终端恢复出厂设置或更换后重新注册，电话本需要重新下发
+func (m *Manager) OnRegister(phone string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if t, ok := m.terminals[phone]; ok {
+		t.State, t.Error, t.Updated = StatePending, "", time.Now()
+		m.save()
+	}
+}
+
+// OnAuth 终端鉴权成功，等待下发的终端在新的goroutine中下发
+func (m *Manager) OnAuth(phone string) {
+	m.mtx.Lock()
+	t, ok := m.terminals[phone]
+	pending := ok && StatePending == t.State
+	m.mtx.Unlock()
+
+	if pending {
+		m.syncAll([]string{phone})
+	}
+}
+
+// OnDriver 驾驶员身份信息上报，插卡时驾驶员换到终端所在的车辆，拔卡时驾驶员离开车辆。
+// 驾驶员的联系人在新的goroutine中从原车辆删除并下发到现车辆
+func (m *Manager) OnDriver(phone string, msg *protocol.MsgICCardReport) {
+	var phones []string
+
+	m.mtx.Lock()
+	t, ok := m.terminals[phone]
+	switch {
+	case 1 == msg.Operation && 0 == msg.Result:
+		credential := strings.TrimRight(msg.Credential, "\x00 ")
+		if !ok {
+			t = &Terminal{Phone: phone, State: StatePending}
+			m.terminals[phone] = t
+		}
+		if credential == t.Driver {
+			break
+		}
+		m.leave(t)
+		t.Driver, t.DriverName, t.Updated = credential, strings.TrimRight(msg.Name, "\x00 "), time.Now()
+		if d, ok := m.drivers[credential]; ok {
+			if other, ok := m.terminals[d.Phone]; ok && phone != d.Phone && credential == other.Driver {
+				other.Driver, other.DriverName, other.Updated = "", "", time.Now()
+				phones = append(phones, other.Phone)
+			}
+			d.Phone = phone
+		}
+		phones = append(phones, phone)
+	case 2 == msg.Operation && ok && "" != t.Driver:
+		m.leave(t)
+		t.Driver, t.DriverName, t.Updated = "", "", time.Now()
+		phones = append(phones, phone)
+	}
+	if 0 != len(phones) {
+		m.save()
+	}
+	m.mtx.Unlock()
+
+	m.syncAll(phones)
+}
+
+// leave 驾驶员离开终端所在的车辆，调用方持有锁
+func (m *Manager) leave(t *Terminal) {
+	if d, ok := m.drivers[t.Driver]; ok && t.Phone == d.Phone {
+		d.Phone = ""
+	}
+}
+
+// assign 将车队的终端由phones改为assigned，调用方持有锁
+func (m *Manager) assign(name string, phones []string, assigned []string) {
+	now := time.Now()
+	for _, phone := range phones {
+		if t, ok := m.terminals[phone]; ok && name == t.Fleet {
+			t.Fleet, t.Updated = "", now
+		}
+	}
+	for _, phone := range assigned {
+		t, ok := m.terminals[phone]
+		if !ok {
+			t = &Terminal{Phone: phone, State: StatePending}
+			m.terminals[phone] = t
+		}
+		t.Fleet, t.Updated = name, now
+	}
+}
+
+// book 终端应有的电话本：车队联系人及当前驾驶员联系人，姓名重复时以车队为准，调用方持有锁
+func (m *Manager) book(t *Terminal) []Contact {
+	var contacts []Contact
+	if f, ok := m.fleets[t.Fleet]; ok {
+		contacts = append(contacts, f.Contacts...)
+	}
+	if d, ok := m.drivers[t.Driver]; ok {
+		for _, c := range d.Contacts {
+			if indexOf(contacts, c.Name) < 0 {
+				contacts = append(contacts, c)
+			}
+		}
+	}
+	return contacts
+}
+
+// syncAll 在新的goroutine中依次下发到各终端
+func (m *Manager) syncAll(phones []string) {
+	phones = unique(phones)
+	if 0 == len(phones) {
+		return
+	}
+	m.syncing.Add(1)
+	go func() {
+		defer m.syncing.Done()
+		for _, phone := range phones {
+			if _, err := m.Sync(phone); nil != err && ErrTerminalNotFound != err {
+				log.Printf("终端[%s]电话本下发失败：%v", phone, err)
+			}
+		}
+	}()
+}
+
+// setState 记录终端同步状态，同步成功时记录已下发的联系人
+func (m *Manager) setState(phone string, state string, contacts []Contact, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t, ok := m.terminals[phone]
+	if !ok {
+		return
+	}
+	t.State, t.Error, t.Updated = state, "", time.Now()
+	if nil != err {
+		t.Error = err.Error()
+	}
+	if StateSynced == state {
+		t.Contacts, t.Synced = contacts, t.Updated
+	}
+	m.save()
+}
+
+// lock 获取终端的下发锁
+func (m *Manager) lock(phone string) *sync.Mutex {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	lock, ok := m.busy[phone]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.busy[phone] = lock
+	}
+	return lock
+}
+
+// store 持久化的电话本数据
+type store struct {
+	Fleets    []Fleet    `json:"fleets"`
+	Drivers   []Driver   `json:"drivers"`
+	Terminals []Terminal `json:"terminals"`
+}
+
+// save 保存电话本数据，先写入临时文件再替换，调用方持有锁
+func (m *Manager) save() {
+	if "" == m.file {
+		return
+	}
+
+	var s store
+	for _, f := range m.fleets {
+		s.Fleets = append(s.Fleets, *f)
+	}
+	for _, d := range m.drivers {
+		s.Drivers = append(s.Drivers, *d)
+	}
+	for _, t := range m.terminals {
+		s.Terminals = append(s.Terminals, *t)
+	}
+	sort.Slice(s.Fleets, func(i, j int) bool { return s.Fleets[i].Name < s.Fleets[j].Name })
+	sort.Slice(s.Drivers, func(i, j int) bool { return s.Drivers[i].Credential < s.Drivers[j].Credential })
+	sort.Slice(s.Terminals, func(i, j int) bool { return s.Terminals[i].Phone < s.Terminals[j].Phone })
+
+	if err := util.SaveJSON(m.file, &s); nil != err {
+		log.Printf("保存电话本失败：%v", err)
+	}
+}
+
+// load 加载已保存的数据，文件不存在时忽略
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var s store
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for idx := range s.Fleets {
+		f := s.Fleets[idx]
+		m.fleets[f.Name] = &f
+	}
+	for idx := range s.Drivers {
+		d := s.Drivers[idx]
+		m.drivers[d.Credential] = &d
+	}
+	for idx := range s.Terminals {
+		t := s.Terminals[idx]
+		m.terminals[t.Phone] = &t
+	}
+	return nil
+}
+
+func (f *Fleet) snapshot() Fleet {
+	result := *f
+	result.Contacts = append([]Contact{}, f.Contacts...)
+	result.Phones = append([]string{}, f.Phones...)
+	return result
+}
+
+func (d *Driver) snapshot() Driver {
+	result := *d
+	result.Contacts = append([]Contact{}, d.Contacts...)
+	return result
+}
+
+func (t *Terminal) snapshot() Terminal {
+	result := *t
+	result.Contacts = append([]Contact{}, t.Contacts...)
+	return result
+}
+
+// validate 校验联系人，姓名不能重复
+func validate(contacts []Contact) error {
+	seen := make(map[string]bool, len(contacts))
+	for idx := range contacts {
+		if err := contacts[idx].validate(); nil != err {
+			return err
+		}
+		if seen[contacts[idx].Name] {
+			return fmt.Errorf("duplicate contact %s", contacts[idx].Name)
+		}
+		seen[contacts[idx].Name] = true
+	}
+	return nil
+}
+
+// unique 去掉空白及重复的手机号，保持顺序
+func unique(phones []string) []string {
+	var result []string
+	seen := make(map[string]bool, len(phones))
+	for _, phone := range phones {
+		phone = strings.TrimSpace(phone)
+		if "" != phone && !seen[phone] {
+			seen[phone] = true
+			result = append(result, phone)
+		}
+	}
+	return result
+}
+
+// indexOf 按姓名查找联系人，不存在时返回-1
+func indexOf(contacts []Contact, name string) int {
+	for idx := range contacts {
+		if name == contacts[idx].Name {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,220 @@
+package contacts
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// fakeTerminal 模拟终端，按收到的设置电话本消息维护终端上的联系人
+type fakeTerminal struct {
+	*terminaltest.Terminals
+	books map[string][]protocol.Contact
+	ops   map[string][]byte
+}
+
+func newFakeTerminal() *fakeTerminal {
+	f := &fakeTerminal{
+		books: make(map[string][]protocol.Contact),
+		ops:   make(map[string][]byte),
+	}
+	f.Terminals = terminaltest.New(f.handle)
+	return f
+}
+
+func (f *fakeTerminal) handle(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+	switch msg := output.(type) {
+	case *protocol.MsgContactsSettings:
+		f.ops[phone] = append(f.ops[phone], msg.Operation)
+		book := f.books[phone]
+		switch msg.Operation {
+		case protocol.ContactsDelete:
+			book = nil
+		case protocol.ContactsUpdate:
+			book = append([]protocol.Contact{}, msg.Contacts...)
+		case protocol.ContactsAppend:
+			book = append(book, msg.Contacts...)
+		case protocol.ContactsModify:
+			for _, c := range msg.Contacts {
+				for idx := range book {
+					if c.Name == book[idx].Name {
+						book[idx] = c
+					}
+				}
+			}
+		}
+		f.books[phone] = book
+	case *protocol.MsgTELCallback:
+	default:
+		return nil, terminaltest.ErrUnexpectedMessage
+	}
+	return &protocol.MsgTerminalResponse{}, nil
+}
+
+// book 终端上的联系人姓名及上次获取后收到的设置类型
+func (f *fakeTerminal) book(phone string) (names []string, ops []byte) {
+	f.Do(func() {
+		for _, c := range f.books[phone] {
+			names = append(names, c.Name)
+		}
+		ops = f.ops[phone]
+		f.ops[phone] = nil
+	})
+	return names, ops
+}
+
+// calls 终端收到的电话回拨
+func (f *fakeTerminal) calls(phone string) []*protocol.MsgTELCallback {
+	var calls []*protocol.MsgTELCallback
+	for _, output := range f.Received(phone) {
+		if msg, ok := output.(*protocol.MsgTELCallback); ok {
+			calls = append(calls, msg)
+		}
+	}
+	return calls
+}
+
+// wait 等待终端同步状态为state
+func wait(t *testing.T, m *Manager, phone string, state string) Terminal {
+	var info Terminal
+	terminaltest.Wait(t, "the terminal is not "+state, func() bool {
+		info, _ = m.Terminal(phone)
+		return state == info.State
+	})
+	return info
+}
+
+func equal(names []string, want ...string) bool {
+	if len(names) != len(want) {
+		return false
+	}
+	for idx := range names {
+		if names[idx] != want[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFleetModes(t *testing.T) {
+	terminal := newFakeTerminal()
+	file := filepath.Join(t.TempDir(), "contacts.json")
+	m := NewManager(terminal.Request, terminaltest.ErrOffline, file)
+	// 后台下发结束后才能删除临时目录
+	t.Cleanup(m.syncing.Wait)
+
+	if _, err := m.SaveFleet(Fleet{Name: "a", Contacts: []Contact{{Name: "调度", Phone: "110", Permission: "call"}}}); nil == err {
+		t.Fatal("expected an error for an unknown permission")
+	}
+	if _, err := m.SaveFleet(Fleet{Name: "a", Contacts: []Contact{
+		{Name: "调度", Phone: "110", Permission: PermissionBoth},
+		{Name: "调度", Phone: "120", Permission: PermissionIn},
+	}}); nil == err {
+		t.Fatal("expected an error for a duplicate contact")
+	}
+
+	_, err := m.SaveFleet(Fleet{Name: "a", Phones: []string{"1"}, Contacts: []Contact{{Name: "调度", Phone: "110", Permission: PermissionBoth}}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wait(t, m, "1", StateSynced)
+	if names, ops := terminal.book("1"); !equal(names, "调度") || string([]byte{protocol.ContactsUpdate}) != string(ops) {
+		t.Fatalf("unexpected book %v, operations %v", names, ops)
+	}
+	if _, err := m.SaveFleet(Fleet{Name: "b", Phones: []string{"1"}}); nil == err {
+		t.Fatal("expected an error for a terminal in another fleet")
+	}
+
+	steps := []struct {
+		op       string
+		contacts []Contact
+		names    []string
+		ops      []byte
+	}{
+		{OpAppend, []Contact{{Name: "维修", Phone: "119", Permission: PermissionOut}}, []string{"调度", "维修"}, []byte{protocol.ContactsAppend}},
+		{OpModify, []Contact{{Name: "调度", Phone: "112", Permission: PermissionIn}}, []string{"调度", "维修"}, []byte{protocol.ContactsModify}},
+		{OpUpdate, []Contact{{Name: "维修", Phone: "119", Permission: PermissionOut}}, []string{"维修"}, []byte{protocol.ContactsUpdate}},
+		{OpDelete, nil, nil, []byte{protocol.ContactsDelete}},
+	}
+	for _, step := range steps {
+		if _, err := m.Apply("a", step.op, step.contacts); nil != err {
+			t.Fatal(err)
+		}
+		// 与Apply启动的下发按终端串行，已下发时不再重复下发
+		if info, err := m.Sync("1"); nil != err || StateSynced != info.State {
+			t.Fatalf("%s was not applied: %+v, %v", step.op, info, err)
+		}
+		if names, ops := terminal.book("1"); !equal(names, step.names...) || string(step.ops) != string(ops) {
+			t.Fatalf("%s: unexpected book %v, operations %v", step.op, names, ops)
+		}
+	}
+
+	if _, err := m.Apply("a", OpModify, []Contact{{Name: "不存在", Phone: "1", Permission: PermissionIn}}); !errors.Is(err, ErrContactNotFound) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	loaded := NewManager(terminal.Request, terminaltest.ErrOffline, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if f, ok := loaded.Fleet("a"); !ok || 0 != len(f.Contacts) || 1 != len(f.Phones) || 1 != len(loaded.Terminals()) {
+		t.Fatalf("unexpected loaded fleet %+v", f)
+	}
+}
+
+func TestDriverChange(t *testing.T) {
+	terminal := newFakeTerminal()
+	m := NewManager(terminal.Request, terminaltest.ErrOffline, "")
+
+	m.SaveFleet(Fleet{Name: "a", Phones: []string{"1", "2"}, Contacts: []Contact{{Name: "调度", Phone: "110", Permission: PermissionBoth}}})
+	wait(t, m, "1", StateSynced)
+	wait(t, m, "2", StateSynced)
+	terminal.book("1")
+	terminal.book("2")
+	m.SaveDriver(Driver{Credential: "D1", Name: "张三", Contacts: []Contact{{Name: "家", Phone: "13800138000", Permission: PermissionIn}}})
+
+	insert := func(phone string) {
+		m.OnDriver(phone, &protocol.MsgICCardReport{MsgICCardReport2011: protocol.MsgICCardReport2011{Operation: 1, Name: "张三", Credential: "D1\x00\x00"}})
+	}
+	insert("1")
+	m.Sync("1")
+	if names, ops := terminal.book("1"); !equal(names, "调度", "家") || string([]byte{protocol.ContactsAppend}) != string(ops) {
+		t.Fatalf("unexpected book %v, operations %v", names, ops)
+	}
+
+	// 驾驶员换车，原车辆移除驾驶员的联系人
+	terminal.SetOffline("2", true)
+	insert("2")
+	m.Sync("1")
+	if info, _ := m.Sync("2"); StatePending != info.State {
+		t.Fatalf("unexpected terminal %+v", info)
+	}
+	if names, _ := terminal.book("1"); !equal(names, "调度") {
+		t.Fatalf("unexpected book %v", names)
+	}
+	if info, _ := m.Terminal("1"); "" != info.Driver {
+		t.Fatalf("unexpected driver %+v", info)
+	}
+
+	// 终端上线后下发
+	terminal.SetOffline("2", false)
+	m.OnAuth("2")
+	if info := wait(t, m, "2", StateSynced); "D1" != info.Driver || 2 != len(info.Contacts) {
+		t.Fatalf("unexpected terminal %+v", info)
+	}
+	if names, _ := terminal.book("2"); !equal(names, "调度", "家") {
+		t.Fatalf("unexpected book %v", names)
+	}
+	if d := m.Drivers(); 1 != len(d) || "2" != d[0].Phone {
+		t.Fatalf("unexpected drivers %+v", d)
+	}
+
+	if err := m.Callback("2", "13800138000", true); nil != err {
+		t.Fatal(err)
+	}
+	if calls := terminal.calls("2"); 1 != len(calls) || protocol.CallbackListen != calls[0].Flag {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
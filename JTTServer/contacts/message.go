@@ -0,0 +1,112 @@
+package contacts
+
+import (
+	"common/protocol"
+	"fmt"
+)
+
+// operations 电话本操作对应的设置电话本类型
+var operations = map[string]byte{
+	OpDelete: protocol.ContactsDelete,
+	OpUpdate: protocol.ContactsUpdate,
+	OpAppend: protocol.ContactsAppend,
+	OpModify: protocol.ContactsModify,
+}
+
+// apply 以op修改联系人，返回修改后的联系人
+func apply(current []Contact, op string, contacts []Contact) ([]Contact, error) {
+	switch op {
+	case OpDelete:
+		return nil, nil
+	case OpUpdate:
+		return append([]Contact{}, contacts...), nil
+	case OpAppend:
+		for _, c := range contacts {
+			if indexOf(current, c.Name) >= 0 {
+				return nil, fmt.Errorf("%w: %s", ErrContactExists, c.Name)
+			}
+		}
+		return append(append([]Contact{}, current...), contacts...), nil
+	case OpModify:
+		result := append([]Contact{}, current...)
+		for _, c := range contacts {
+			idx := indexOf(result, c.Name)
+			if idx < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrContactNotFound, c.Name)
+			}
+			result[idx] = c
+		}
+		return result, nil
+	default:
+		return nil, ErrUnknownOperation
+	}
+}
+
+// plan 生成将终端电话本改为book的消息。
+// 终端上的联系人已知时：一致则不下发，book为空则删除，只有新增或修改的联系人则追加、修改，
+// 有联系人需要移除则更新；终端上的联系人未知（未下发成功或已重新注册）时更新。
+// 联系人超过单条消息的上限时，更新的其余联系人以追加下发
+func plan(t *Terminal, book []Contact) ([]protocol.Output, error) {
+	known := StateSynced == t.State
+	if 0 == len(book) {
+		if known && 0 == len(t.Contacts) || !known && t.Synced.IsZero() {
+			// 从未下发过联系人的终端不删除终端上已有的联系人
+			return nil, nil
+		}
+		msg, err := protocol.NewMsgContacts(protocol.ContactsDelete, nil)
+		return []protocol.Output{msg}, err
+	}
+
+	if known {
+		var added, modified []Contact
+		removed := false
+		for _, c := range t.Contacts {
+			if indexOf(book, c.Name) < 0 {
+				removed = true
+			}
+		}
+		for _, c := range book {
+			switch idx := indexOf(t.Contacts, c.Name); {
+			case idx < 0:
+				added = append(added, c)
+			case c != t.Contacts[idx]:
+				modified = append(modified, c)
+			}
+		}
+		if !removed {
+			outputs, err := messages(OpModify, modified)
+			if nil != err {
+				return nil, err
+			}
+			appended, err := messages(OpAppend, added)
+			return append(outputs, appended...), err
+		}
+	}
+	return messages(OpUpdate, book)
+}
+
+// messages 按单条消息的联系人上限分条，更新时第一条以外的以追加下发
+func messages(op string, contacts []Contact) ([]protocol.Output, error) {
+	var outputs []protocol.Output
+	for start := 0; start < len(contacts); start += protocol.MaxContacts {
+		end := start + protocol.MaxContacts
+		if end > len(contacts) {
+			end = len(contacts)
+		}
+		items := make([]protocol.Contact, 0, end-start)
+		for idx := range contacts[start:end] {
+			items = append(items, contacts[start+idx].message())
+		}
+
+		operation := operations[op]
+		if OpUpdate == op && 0 != start {
+			operation = protocol.ContactsAppend
+		}
+		msg, err := protocol.NewMsgContacts(operation, items)
+		if nil != err {
+			return nil, err
+		}
+		outputs = append(outputs, msg)
+	}
+	return outputs, nil
+}
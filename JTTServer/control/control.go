@@ -0,0 +1,460 @@
+package control
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"bufio"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ControlApp 默认的终端控制服务，由Setup初始化
+	ControlApp *Manager
+)
+
+var (
+	// ErrUnknownCommand 未知的控制命令
+	ErrUnknownCommand = errors.New("unknown control command")
+	// ErrConfirmRequired 破坏性命令需要确认
+	ErrConfirmRequired = errors.New("the command must be confirmed with a token")
+	// ErrInvalidToken 确认令牌无效或已过期
+	ErrInvalidToken = errors.New("the confirmation token is invalid or expired")
+)
+
+// Setup 初始化默认的终端控制服务，offline为终端不在线时Requester返回的错误，
+// auditFile为审计记录文件，为空时不持久化
+//
+// control.Setup(jtt.Request, jtt.ErrClientOffline, "control_audit.log")
+func Setup(request terminal.Requester, offline error, auditFile string) error {
+	m := NewManager(request, offline, auditFile)
+	if err := m.load(); nil != err {
+		return err
+	}
+	ControlApp = m
+	return nil
+}
+
+// 确认令牌有效期
+const tokenTTL = time.Minute
+
+// 终端复位后等待重新鉴权的默认时限
+const defaultReconnectTimeout = time.Minute * 5
+
+// 内存中保留的最大审计记录数
+const maxEntries = 1000
+
+// 控制命令名称
+const (
+	CommandUpgrade       = "upgrade"         // 无线升级
+	CommandConnect       = "connect"         // 连接指定服务器
+	CommandShutdown      = "shutdown"        // 终端关机
+	CommandReset         = "reset"           // 终端复位
+	CommandFactoryReset  = "factory_reset"   // 恢复出厂设置
+	CommandCloseDataLink = "close_data_link" // 关闭数据通信
+	CommandCloseWireless = "close_wireless"  // 关闭所有无线通信
+)
+
+// command 控制命令定义，destructive为true时需要确认，reconnect为true时终端执行后应重新连接
+type command struct {
+	cmd         byte
+	destructive bool
+	reconnect   bool
+}
+
+var commands = map[string]command{
+	CommandUpgrade:       {protocol.TerminalCtrlUpgrade, true, true},
+	CommandConnect:       {protocol.TerminalCtrlConnectServer, true, false},
+	CommandShutdown:      {protocol.TerminalCtrlShutdown, true, false},
+	CommandReset:         {protocol.TerminalCtrlReset, true, true},
+	CommandFactoryReset:  {protocol.TerminalCtrlFactoryReset, true, true},
+	CommandCloseDataLink: {protocol.TerminalCtrlCloseDataLink, true, false},
+	CommandCloseWireless: {protocol.TerminalCtrlCloseWireless, true, false},
+}
+
+// 执行结果
+const (
+	ResultSending     = "sending"     // 已下发，等待终端应答
+	ResultInterrupted = "interrupted" // 等待应答期间服务重启，结果未知
+	ResultAccepted    = "accepted"    // 终端应答成功
+	ResultRejected    = "rejected"    // 终端应答失败
+	ResultOffline     = "offline"     // 终端不在线
+	ResultFailed      = "failed"      // 发送失败或应答超时
+)
+
+// 重连检查状态
+const (
+	ReconnectWaiting     = "waiting"     // 等待终端重新鉴权
+	ReconnectSucceeded   = "reconnected" // 终端已重新鉴权
+	ReconnectTimeout     = "timeout"     // 时限内终端未重新鉴权
+	ReconnectInterrupted = "interrupted" // 等待期间服务重启，结果未知
+)
+
+// Request 终端控制请求
+type Request struct {
+	// 命令名称，见CommandXXX
+	Command string `json:"command"`
+	// 无线升级参数，仅upgrade命令
+	Upgrade *protocol.WirelessUpgradeParam `json:"upgrade,omitempty"`
+	// 连接指定服务器参数，仅connect命令
+	Connect *protocol.ConnectServerParam `json:"connect,omitempty"`
+	// 确认令牌，破坏性命令必填，由首次请求返回
+	Token string `json:"token,omitempty"`
+	// 操作人
+	Operator string `json:"operator"`
+}
+
+// Confirmation 破坏性命令的确认令牌，以相同的请求附带令牌再次提交后执行
+type Confirmation struct {
+	// 确认令牌
+	Token string `json:"token"`
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 命令名称
+	Command string `json:"command"`
+	// 过期时间
+	Expires time.Time `json:"expires"`
+	// 命令参数
+	value string
+}
+
+// Entry 终端控制审计记录
+type Entry struct {
+	// 记录ID
+	ID string `json:"id"`
+	// 执行时间
+	Time time.Time `json:"time"`
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 命令名称
+	Command string `json:"command"`
+	// 命令字
+	Cmd byte `json:"cmd"`
+	// 命令参数，密码已隐去
+	Value string `json:"value,omitempty"`
+	// 操作人
+	Operator string `json:"operator"`
+	// 执行结果，见ResultXXX
+	Result string `json:"result"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 重连检查状态，见ReconnectXXX，不需要检查时为空
+	Reconnect string `json:"reconnect,omitempty"`
+	// 终端重新鉴权时间
+	Reconnected *time.Time `json:"reconnected,omitempty"`
+}
+
+// Manager 终端控制服务。
+//
+// 以0x8105下发终端控制命令，升级、连接指定服务器、关机、复位等破坏性命令需先获取确认令牌再提交；
+// 每次执行在下发前写入审计记录，应答后更新结果，复位、升级类命令执行后检查终端是否在时限内重新鉴权。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	file    string
+	// 终端复位后等待重新鉴权的时限
+	reconnectTimeout time.Duration
+
+	mtx     sync.Mutex
+	tokens  map[string]*Confirmation
+	entries []*Entry
+	timers  map[string]*time.Timer
+}
+
+// NewManager 新建终端控制服务
+func NewManager(request terminal.Requester, offline error, auditFile string) *Manager {
+	return &Manager{
+		request:          request,
+		offline:          offline,
+		file:             auditFile,
+		reconnectTimeout: defaultReconnectTimeout,
+		tokens:           make(map[string]*Confirmation),
+		timers:           make(map[string]*time.Timer),
+	}
+}
+
+// Execute 向终端下发控制命令。破坏性命令未附带令牌时返回确认令牌及ErrConfirmRequired，
+// 令牌与终端、命令及参数绑定，只能使用一次
+func (m *Manager) Execute(phone string, req Request) (Entry, *Confirmation, error) {
+	def, ok := commands[req.Command]
+	if !ok {
+		return Entry{}, nil, ErrUnknownCommand
+	}
+	msg, audit, err := build(def.cmd, &req)
+	if nil != err {
+		return Entry{}, nil, err
+	}
+
+	if def.destructive {
+		if "" == req.Token {
+			return Entry{}, m.confirmation(phone, req.Command, msg.Value), ErrConfirmRequired
+		}
+		if !m.consume(req.Token, phone, req.Command, msg.Value) {
+			return Entry{}, nil, ErrInvalidToken
+		}
+	}
+
+	entry := Entry{
+		ID:       util.RandomID(),
+		Time:     time.Now(),
+		Phone:    phone,
+		Command:  req.Command,
+		Cmd:      def.cmd,
+		Value:    audit,
+		Operator: req.Operator,
+		Result:   ResultSending,
+	}
+	// 下发前写入审计记录，保存副本，应答及重连检查会修改保存的记录
+	record := entry
+	m.mtx.Lock()
+	m.append(&record)
+	m.mtx.Unlock()
+
+	err = m.request.Send(phone, msg)
+	switch {
+	case nil == err:
+		entry.Result = ResultAccepted
+		if def.reconnect {
+			entry.Reconnect = ReconnectWaiting
+		}
+	case terminal.ErrRejected == err:
+		entry.Result, entry.Error = ResultRejected, err.Error()
+	case nil != m.offline && errors.Is(err, m.offline):
+		entry.Result, entry.Error = ResultOffline, err.Error()
+	default:
+		entry.Result, entry.Error = ResultFailed, err.Error()
+	}
+
+	m.mtx.Lock()
+	record.Result, record.Error, record.Reconnect = entry.Result, entry.Error, entry.Reconnect
+	m.persist(&record)
+	if ReconnectWaiting == entry.Reconnect {
+		id := entry.ID
+		m.timers[id] = time.AfterFunc(m.reconnectTimeout, func() {
+			m.expire(id)
+		})
+	}
+	m.mtx.Unlock()
+
+	return entry, nil, err
+}
+
+// OnAuth 终端鉴权成功，完成该终端等待中的重连检查
+func (m *Manager) OnAuth(phone string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	for _, entry := range m.entries {
+		if phone != entry.Phone || ReconnectWaiting != entry.Reconnect {
+			continue
+		}
+		if timer, ok := m.timers[entry.ID]; ok {
+			timer.Stop()
+			delete(m.timers, entry.ID)
+		}
+		entry.Reconnect, entry.Reconnected = ReconnectSucceeded, &now
+		m.persist(entry)
+	}
+}
+
+// Entries 获取审计记录，phone为空时返回所有终端的记录，按执行时间排列
+func (m *Manager) Entries(phone string) []Entry {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	entries := make([]Entry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		if "" == phone || phone == entry.Phone {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+// build 按命令构建终端控制消息，同时返回隐去密码的审计参数
+func build(cmd byte, req *Request) (*protocol.MsgTerminalControl, string, error) {
+	switch cmd {
+	case protocol.TerminalCtrlUpgrade:
+		if nil == req.Upgrade {
+			return nil, "", errors.New("the upgrade parameters are required")
+		}
+		msg, err := protocol.NewMsgWirelessUpgrade(*req.Upgrade)
+		if nil != err {
+			return nil, "", err
+		}
+		masked := *req.Upgrade
+		masked.Password = mask(masked.Password)
+		return msg, masked.String(), nil
+	case protocol.TerminalCtrlConnectServer:
+		if nil == req.Connect {
+			return nil, "", errors.New("the connect parameters are required")
+		}
+		msg, err := protocol.NewMsgConnectServer(*req.Connect)
+		if nil != err {
+			return nil, "", err
+		}
+		masked := *req.Connect
+		masked.Password, masked.AuthCode = mask(masked.Password), mask(masked.AuthCode)
+		return msg, masked.String(), nil
+	case protocol.TerminalCtrlShutdown:
+		return protocol.NewMsgTerminalShutdown(), "", nil
+	case protocol.TerminalCtrlReset:
+		return protocol.NewMsgTerminalReset(), "", nil
+	case protocol.TerminalCtrlFactoryReset:
+		return protocol.NewMsgTerminalFactoryReset(), "", nil
+	case protocol.TerminalCtrlCloseDataLink:
+		return protocol.NewMsgCloseDataLink(), "", nil
+	case protocol.TerminalCtrlCloseWireless:
+		return protocol.NewMsgCloseWireless(), "", nil
+	}
+	return nil, "", ErrUnknownCommand
+}
+
+// mask 隐去敏感参数
+func mask(value string) string {
+	if "" == value {
+		return ""
+	}
+	return "***"
+}
+
+// confirmation 生成确认令牌，同时清理过期的令牌
+func (m *Manager) confirmation(phone, command, value string) *Confirmation {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	for token, c := range m.tokens {
+		if now.After(c.Expires) {
+			delete(m.tokens, token)
+		}
+	}
+
+	c := &Confirmation{
+		Token:   util.RandomID(),
+		Phone:   phone,
+		Command: command,
+		Expires: now.Add(tokenTTL),
+		value:   value,
+	}
+	m.tokens[c.Token] = c
+	result := *c
+	return &result
+}
+
+// consume 校验并作废确认令牌
+func (m *Manager) consume(token, phone, command, value string) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, ok := m.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(m.tokens, token)
+	return phone == c.Phone && command == c.Command && value == c.value && time.Now().Before(c.Expires)
+}
+
+// expire 重连检查超时
+func (m *Manager) expire(id string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.timers, id)
+	for _, entry := range m.entries {
+		if id == entry.ID && ReconnectWaiting == entry.Reconnect {
+			entry.Reconnect = ReconnectTimeout
+			m.persist(entry)
+			log.Printf("终端[%s]执行%s后未在%s内重新鉴权", entry.Phone, entry.Command, m.reconnectTimeout)
+		}
+	}
+}
+
+// append 添加审计记录，调用方持有锁
+func (m *Manager) append(entry *Entry) {
+	m.entries = append(m.entries, entry)
+	if len(m.entries) > maxEntries {
+		m.entries = m.entries[len(m.entries)-maxEntries:]
+	}
+	m.persist(entry)
+}
+
+// persist 追加写入审计记录，记录更新时追加新的一行，加载时以最后一行为准；调用方持有锁
+func (m *Manager) persist(entry *Entry) {
+	if "" == m.file {
+		return
+	}
+
+	err := func() error {
+		if err := os.MkdirAll(filepath.Dir(m.file), 0755); nil != err {
+			return err
+		}
+		f, err := os.OpenFile(m.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if nil != err {
+			return err
+		}
+		defer f.Close()
+		data, _ := json.Marshal(entry)
+		_, err = f.Write(append(data, '\n'))
+		return err
+	}()
+	if nil != err {
+		log.Printf("写入终端控制审计记录失败：%v", err)
+	}
+}
+
+// load 加载审计记录，等待应答的命令及等待中的重连检查记为中断
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	f, err := os.Open(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+	defer f.Close()
+
+	entries := make(map[string]*Entry)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); nil != err {
+			return fmt.Errorf("%s:%d: %w", m.file, line, err)
+		}
+		entries[entry.ID] = &entry
+	}
+	if err := scanner.Err(); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, entry := range entries {
+		if ResultSending == entry.Result {
+			entry.Result = ResultInterrupted
+		}
+		if ReconnectWaiting == entry.Reconnect {
+			entry.Reconnect = ReconnectInterrupted
+		}
+		m.entries = append(m.entries, entry)
+	}
+	sort.Slice(m.entries, func(i, j int) bool {
+		return m.entries[i].Time.Before(m.entries[j].Time)
+	})
+	if len(m.entries) > maxEntries {
+		m.entries = m.entries[len(m.entries)-maxEntries:]
+	}
+	return nil
+}
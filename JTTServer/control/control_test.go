@@ -0,0 +1,143 @@
+package control
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// controls 终端收到的控制命令
+func controls(terminals *terminaltest.Terminals, phone string) []*protocol.MsgTerminalControl {
+	var result []*protocol.MsgTerminalControl
+	for _, output := range terminals.Received(phone) {
+		result = append(result, output.(*protocol.MsgTerminalControl))
+	}
+	return result
+}
+
+func TestExecuteConfirm(t *testing.T) {
+	terminals := terminaltest.New(nil)
+	file := filepath.Join(t.TempDir(), "audit.log")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+
+	// 破坏性命令先返回确认令牌
+	_, confirm, err := m.Execute("1", Request{Command: CommandReset, Operator: "admin"})
+	if ErrConfirmRequired != err || nil == confirm || 0 != len(terminals.Received("1")) {
+		t.Fatalf("got %v, want ErrConfirmRequired", err)
+	}
+	// 令牌与终端绑定
+	if _, _, err := m.Execute("2", Request{Command: CommandReset, Token: confirm.Token}); ErrInvalidToken != err {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+	// 令牌校验失败后作废
+	if _, _, err := m.Execute("1", Request{Command: CommandReset, Token: confirm.Token}); ErrInvalidToken != err {
+		t.Fatalf("got %v, want ErrInvalidToken", err)
+	}
+
+	_, confirm, _ = m.Execute("1", Request{Command: CommandReset})
+	entry, _, err := m.Execute("1", Request{Command: CommandReset, Token: confirm.Token, Operator: "admin"})
+	if nil != err || ResultAccepted != entry.Result || ReconnectWaiting != entry.Reconnect {
+		t.Fatalf("unexpected entry: %+v, %v", entry, err)
+	}
+	if sent := controls(terminals, "1"); 1 != len(sent) || protocol.TerminalCtrlReset != sent[0].Cmd {
+		t.Fatalf("unexpected sent messages: %+v", sent)
+	}
+
+	m.OnAuth("1")
+	if entries := m.Entries("1"); 1 != len(entries) || ReconnectSucceeded != entries[0].Reconnect || nil == entries[0].Reconnected {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	// 连接指定服务器同样需要确认，审计记录隐去密码
+	connect := Request{Command: CommandConnect, Connect: &protocol.ConnectServerParam{Address: "host", Password: "secret"}}
+	if _, confirm, err = m.Execute("2", connect); ErrConfirmRequired != err || 0 != len(terminals.Received("2")) {
+		t.Fatalf("got %v, want ErrConfirmRequired", err)
+	}
+	connect.Token = confirm.Token
+	entry, _, err = m.Execute("2", connect)
+	if nil != err || "0;;;;***;host;0;0;0" != entry.Value || "" != entry.Reconnect {
+		t.Fatalf("unexpected entry: %+v, %v", entry, err)
+	}
+	if sent := controls(terminals, "2"); "0;;;;secret;host;0;0;0" != sent[0].Value {
+		t.Fatalf("unexpected value: %q", sent[0].Value)
+	}
+
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if entries := loaded.Entries(""); 2 != len(entries) || ReconnectSucceeded != entries[0].Reconnect {
+		t.Fatalf("unexpected loaded entries: %+v", entries)
+	}
+}
+
+func TestReconnectTimeout(t *testing.T) {
+	terminals := terminaltest.New(nil)
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, "")
+	m.reconnectTimeout = time.Millisecond * 20
+
+	upgrade := Request{Command: CommandUpgrade, Upgrade: &protocol.WirelessUpgradeParam{URL: "ftp://host/fw.bin"}}
+	_, confirm, err := m.Execute("1", upgrade)
+	if ErrConfirmRequired != err {
+		t.Fatalf("got %v, want ErrConfirmRequired", err)
+	}
+	upgrade.Token = confirm.Token
+	entry, _, err := m.Execute("1", upgrade)
+	if nil != err || ReconnectWaiting != entry.Reconnect {
+		t.Fatalf("unexpected entry: %+v, %v", entry, err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	m.OnAuth("1")
+	if entries := m.Entries("1"); ReconnectTimeout != entries[0].Reconnect {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	terminals.SetOffline("1", true)
+	_, confirm, _ = m.Execute("1", Request{Command: CommandShutdown})
+	entry, _, err = m.Execute("1", Request{Command: CommandShutdown, Token: confirm.Token})
+	if !errors.Is(err, terminaltest.ErrOffline) || ResultOffline != entry.Result || "" != entry.Reconnect {
+		t.Fatalf("unexpected entry: %+v, %v", entry, err)
+	}
+}
+
+func TestAuditBeforeDispatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.log")
+	var m *Manager
+	// 终端收到命令时审计记录已写入
+	var sending []Entry
+	terminals := terminaltest.New(func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+		sending = m.Entries(phone)
+		return nil, errors.New("timeout")
+	})
+	m = NewManager(terminals.Request, terminaltest.ErrOffline, file)
+
+	_, confirm, _ := m.Execute("1", Request{Command: CommandFactoryReset})
+	entry, _, err := m.Execute("1", Request{Command: CommandFactoryReset, Token: confirm.Token, Operator: "admin"})
+	if nil == err || ResultFailed != entry.Result {
+		t.Fatalf("unexpected entry: %+v, %v", entry, err)
+	}
+	if 1 != len(sending) || ResultSending != sending[0].Result || "admin" != sending[0].Operator {
+		t.Fatalf("unexpected entries at dispatch: %+v", sending)
+	}
+	if entries := m.Entries("1"); 1 != len(entries) || ResultFailed != entries[0].Result {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	// 等待应答期间服务重启，结果未知
+	m.mtx.Lock()
+	m.persist(&Entry{ID: "x", Time: time.Now(), Phone: "2", Command: CommandReset, Result: ResultSending})
+	m.mtx.Unlock()
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if entries := loaded.Entries("2"); 1 != len(entries) || ResultInterrupted != entries[0].Result {
+		t.Fatalf("unexpected loaded entries: %+v", entries)
+	}
+	if entries := loaded.Entries("1"); 1 != len(entries) || ResultFailed != entries[0].Result {
+		t.Fatalf("unexpected loaded entries: %+v", entries)
+	}
+}
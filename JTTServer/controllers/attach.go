@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"JTTServer/attach"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errAlarmNotFound = errors.New("the alarm does not exist")
+
+// AttachController 主动安全报警附件
+type AttachController struct {
+	beego.Controller
+}
+
+// Alarms 获取报警附件列表，GET /attachments?phone=13912345678
+func (c *AttachController) Alarms() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = attach.AttachApp.Alarms(c.GetString("phone"))
+	c.ServeJSON()
+}
+
+// Alarm 获取报警附件，GET /attachments/:alarm_no
+func (c *AttachController) Alarm() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := attach.AttachApp.Alarm(c.Ctx.Input.Param(":alarm_no"))
+	if !ok {
+		c.fail(http.StatusNotFound, errAlarmNotFound)
+		return
+	}
+
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+func (c *AttachController) ready() bool {
+	if nil == attach.AttachApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *AttachController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"JTTServer/canbus"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errNoCanData = errors.New("no CAN data has been reported")
+
+// CanController CAN总线数据
+type CanController struct {
+	beego.Controller
+}
+
+// Latest 获取终端各CAN信号的最新值，GET /can/:phone
+func (c *CanController) Latest() {
+	if nil == canbus.CanApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return
+	}
+
+	snapshot, ok := canbus.CanApp.Latest(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, errNoCanData)
+		return
+	}
+
+	c.Data["json"] = snapshot
+	c.ServeJSON()
+}
+
+func (c *CanController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
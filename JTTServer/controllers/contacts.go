@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"JTTServer/contacts"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ContactsController 电话本及电话回拨
+type ContactsController struct {
+	beego.Controller
+}
+
+// applyRequest 修改车队联系人的请求
+type applyRequest struct {
+	// 操作，见contacts.OpXXX
+	Operation string `json:"operation"`
+	// 联系人，删除时忽略
+	Contacts []contacts.Contact `json:"contacts"`
+}
+
+// callbackRequest 电话回拨请求
+type callbackRequest struct {
+	// 回拨的电话号码
+	Phone string `json:"phone"`
+	// 是否监听，否则为普通通话
+	Listen bool `json:"listen"`
+}
+
+// Fleets 获取全部车队，GET /contacts/fleets
+func (c *ContactsController) Fleets() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = contacts.ContactsApp.Fleets()
+	c.ServeJSON()
+}
+
+// SaveFleet 新建或更新车队，POST /contacts/fleets，请求体为contacts.Fleet
+func (c *ContactsController) SaveFleet() {
+	if !c.ready() {
+		return
+	}
+
+	var f contacts.Fleet
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&f); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	saved, err := contacts.ContactsApp.SaveFleet(f)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = saved
+	c.ServeJSON()
+}
+
+// Fleet 获取车队，GET /contacts/fleets/:name
+func (c *ContactsController) Fleet() {
+	if !c.ready() {
+		return
+	}
+
+	f, ok := contacts.ContactsApp.Fleet(c.Ctx.Input.Param(":name"))
+	if !ok {
+		c.fail(http.StatusNotFound, contacts.ErrFleetNotFound)
+		return
+	}
+	c.Data["json"] = f
+	c.ServeJSON()
+}
+
+// DeleteFleet 删除车队，DELETE /contacts/fleets/:name
+func (c *ContactsController) DeleteFleet() {
+	if !c.ready() {
+		return
+	}
+
+	if err := contacts.ContactsApp.DeleteFleet(c.Ctx.Input.Param(":name")); nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+// Apply 以删除、更新、追加或修改方式修改车队的联系人并下发，POST /contacts/fleets/:name/contacts，
+// 请求体为applyRequest
+func (c *ContactsController) Apply() {
+	if !c.ready() {
+		return
+	}
+
+	var req applyRequest
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	f, err := contacts.ContactsApp.Apply(c.Ctx.Input.Param(":name"), req.Operation, req.Contacts)
+	switch {
+	case contacts.ErrFleetNotFound == err:
+		c.fail(http.StatusNotFound, err)
+		return
+	case errors.Is(err, contacts.ErrContactExists) || errors.Is(err, contacts.ErrContactNotFound):
+		c.fail(http.StatusConflict, err)
+		return
+	case nil != err:
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = f
+	c.ServeJSON()
+}
+
+// Drivers 获取全部驾驶员，GET /contacts/drivers
+func (c *ContactsController) Drivers() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = contacts.ContactsApp.Drivers()
+	c.ServeJSON()
+}
+
+// SaveDriver 新建或更新驾驶员的联系人，POST /contacts/drivers，请求体为contacts.Driver
+func (c *ContactsController) SaveDriver() {
+	if !c.ready() {
+		return
+	}
+
+	var d contacts.Driver
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&d); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	saved, err := contacts.ContactsApp.SaveDriver(d)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = saved
+	c.ServeJSON()
+}
+
+// DeleteDriver 删除驾驶员，DELETE /contacts/drivers/:credential
+func (c *ContactsController) DeleteDriver() {
+	if !c.ready() {
+		return
+	}
+
+	if err := contacts.ContactsApp.DeleteDriver(c.Ctx.Input.Param(":credential")); nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+// Terminals 获取全部终端电话本，GET /contacts/terminals
+func (c *ContactsController) Terminals() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = contacts.ContactsApp.Terminals()
+	c.ServeJSON()
+}
+
+// Terminal 获取终端电话本及同步状态，GET /terminals/:phone/contacts
+func (c *ContactsController) Terminal() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := contacts.ContactsApp.Terminal(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, contacts.ErrTerminalNotFound)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Sync 立即下发终端电话本，POST /terminals/:phone/contacts/sync。
+// 终端不在线时返回的状态为pending，终端鉴权后自动下发
+func (c *ContactsController) Sync() {
+	if !c.ready() {
+		return
+	}
+
+	info, err := contacts.ContactsApp.Sync(c.Ctx.Input.Param(":phone"))
+	if nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Callback 电话回拨，POST /terminals/:phone/callback，请求体为callbackRequest
+func (c *ContactsController) Callback() {
+	if !c.ready() {
+		return
+	}
+
+	var req callbackRequest
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	err := contacts.ContactsApp.Callback(c.Ctx.Input.Param(":phone"), req.Phone, req.Listen)
+	switch {
+	case errors.Is(err, contacts.ErrInvalidNumber):
+		c.fail(http.StatusBadRequest, err)
+		return
+	case nil != err:
+		c.fail(requestStatus(err), err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+func (c *ContactsController) ready() bool {
+	if nil == contacts.ContactsApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *ContactsController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
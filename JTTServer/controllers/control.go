@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"JTTServer/control"
+	"encoding/json"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// ControlController 终端控制
+type ControlController struct {
+	beego.Controller
+}
+
+// Execute 下发终端控制命令，POST /controls/:phone，请求体为control.Request。
+// 破坏性命令未附带令牌时返回428及control.Confirmation，附带令牌再次提交后执行
+func (c *ControlController) Execute() {
+	if !c.ready() {
+		return
+	}
+
+	var req control.Request
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	if "" == req.Operator {
+		req.Operator = c.Ctx.Input.IP()
+	}
+
+	entry, confirm, err := control.ControlApp.Execute(c.Ctx.Input.Param(":phone"), req)
+	switch {
+	case control.ErrConfirmRequired == err:
+		c.Ctx.Output.SetStatus(http.StatusPreconditionRequired)
+		c.Data["json"] = confirm
+	case control.ErrInvalidToken == err:
+		c.fail(http.StatusForbidden, err)
+		return
+	case "" == entry.ID && nil != err:
+		c.fail(http.StatusBadRequest, err)
+		return
+	case nil != err:
+		c.Ctx.Output.SetStatus(requestStatus(err))
+		c.Data["json"] = entry
+	default:
+		c.Data["json"] = entry
+	}
+	c.ServeJSON()
+}
+
+// Entries 获取终端控制审计记录，GET /controls?phone=，phone为空时返回所有终端的记录
+func (c *ControlController) Entries() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = control.ControlApp.Entries(c.GetString("phone"))
+	c.ServeJSON()
+}
+
+func (c *ControlController) ready() bool {
+	if nil == control.ControlApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *ControlController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"JTTServer/geofence"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// GeofenceController 电子围栏
+type GeofenceController struct {
+	beego.Controller
+}
+
+// assignRequest 终端区域分配请求
+type assignRequest struct {
+	// 操作，见geofence.OpXXX
+	Operation string `json:"operation"`
+	// 区域或路线
+	Fences []geofence.Key `json:"fences"`
+}
+
+// Fences 获取全部区域与路线，GET /geofences
+func (c *GeofenceController) Fences() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = geofence.GeofenceApp.Fences()
+	c.ServeJSON()
+}
+
+// Save 新建或更新区域，POST /geofences，请求体为geofence.Fence
+func (c *GeofenceController) Save() {
+	if !c.ready() {
+		return
+	}
+
+	var fence geofence.Fence
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&fence); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	saved, err := geofence.GeofenceApp.SaveFence(fence)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = saved
+	c.ServeJSON()
+}
+
+// Fence 获取区域，GET /geofences/:kind/:id
+func (c *GeofenceController) Fence() {
+	if !c.ready() {
+		return
+	}
+
+	key, err := c.key()
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	fence, ok := geofence.GeofenceApp.Fence(key)
+	if !ok {
+		c.fail(http.StatusNotFound, geofence.ErrFenceNotFound)
+		return
+	}
+	c.Data["json"] = fence
+	c.ServeJSON()
+}
+
+// Delete 删除区域，DELETE /geofences/:kind/:id，已分配给终端时返回409
+func (c *GeofenceController) Delete() {
+	if !c.ready() {
+		return
+	}
+
+	key, err := c.key()
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	switch err := geofence.GeofenceApp.DeleteFence(key); err {
+	case nil:
+		c.Ctx.Output.SetStatus(http.StatusNoContent)
+	case geofence.ErrFenceInUse:
+		c.fail(http.StatusConflict, err)
+	default:
+		c.fail(http.StatusNotFound, err)
+	}
+}
+
+// Export 以GeoJSON导出全部区域与路线，GET /geofences/geojson
+func (c *GeofenceController) Export() {
+	if !c.ready() {
+		return
+	}
+
+	collection, err := geofence.GeofenceApp.ExportGeoJSON()
+	if nil != err {
+		c.fail(http.StatusInternalServerError, err)
+		return
+	}
+	c.Data["json"] = collection
+	c.ServeJSON()
+}
+
+// Import 导入GeoJSON要素集合，POST /geofences/geojson
+func (c *GeofenceController) Import() {
+	if !c.ready() {
+		return
+	}
+
+	var collection geofence.FeatureCollection
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&collection); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	fences, err := geofence.GeofenceApp.ImportGeoJSON(&collection)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = fences
+	c.ServeJSON()
+}
+
+// Terminal 获取终端的区域分配及同步状态，GET /terminals/:phone/geofences
+func (c *GeofenceController) Terminal() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := geofence.GeofenceApp.Terminal(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, errors.New("no fences are assigned to the terminal"))
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Assign 修改终端的区域分配并下发，POST /terminals/:phone/geofences，请求体为assignRequest。
+// 终端不在线时返回的状态为pending，终端鉴权后自动下发
+func (c *GeofenceController) Assign() {
+	if !c.ready() {
+		return
+	}
+
+	var req assignRequest
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	info, err := geofence.GeofenceApp.Assign(c.Ctx.Input.Param(":phone"), req.Operation, req.Fences)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Reconcile 查询终端上的区域并与分配核对，POST /terminals/:phone/geofences/reconcile?apply=true，
+// apply为true时下发修正
+func (c *GeofenceController) Reconcile() {
+	if !c.ready() {
+		return
+	}
+
+	apply, _ := c.GetBool("apply")
+	report, err := geofence.GeofenceApp.Reconcile(c.Ctx.Input.Param(":phone"), apply)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+	c.Data["json"] = report
+	c.ServeJSON()
+}
+
+// Events 获取平台判断的最近区域事件，GET /geofences/events?phone=，phone为空时返回所有终端的事件
+func (c *GeofenceController) Events() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = geofence.GeofenceApp.Events(c.GetString("phone"))
+	c.ServeJSON()
+}
+
+// key 解析路径中的区域类型及id
+func (c *GeofenceController) key() (geofence.Key, error) {
+	id, err := strconv.ParseUint(c.Ctx.Input.Param(":id"), 10, 32)
+	if nil != err {
+		return geofence.Key{}, err
+	}
+	return geofence.Key{Kind: c.Ctx.Input.Param(":kind"), ID: uint32(id)}, nil
+}
+
+func (c *GeofenceController) ready() bool {
+	if nil == geofence.GeofenceApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *GeofenceController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"JTTServer/inventory"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// InventoryController 终端台账
+type InventoryController struct {
+	beego.Controller
+}
+
+// Terminals 获取所有终端台账，GET /terminals
+func (c *InventoryController) Terminals() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = inventory.InventoryApp.Terminals()
+	c.ServeJSON()
+}
+
+// Terminal 获取终端台账及变更历史，GET /terminals/:phone
+func (c *InventoryController) Terminal() {
+	if !c.ready() {
+		return
+	}
+
+	record, ok := inventory.InventoryApp.Record(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, inventory.ErrTerminalNotFound)
+		return
+	}
+	c.Data["json"] = record
+	c.ServeJSON()
+}
+
+// Refresh 立即查询终端属性并更新台账，POST /terminals/:phone/refresh
+func (c *InventoryController) Refresh() {
+	if !c.ready() {
+		return
+	}
+
+	info, err := inventory.InventoryApp.Refresh(c.Ctx.Input.Param(":phone"))
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+func (c *InventoryController) ready() bool {
+	if nil == inventory.InventoryApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *InventoryController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"JTTServer/media"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/server/web/context"
+	"github.com/gorilla/websocket"
+)
+
+var errServiceNotRunning = errors.New("the service is not running")
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// LiveController 实时音视频直播，HTTP-FLV与WS-FLV
+type LiveController struct {
+	beego.Controller
+}
+
+// Flv 观看终端通道直播，GET /live/:phone/:channel.flv，带websocket升级头时使用WS-FLV
+func (c *LiveController) Flv() {
+	c.EnableRender = false
+
+	phone := c.Ctx.Input.Param(":phone")
+	channel, err := strconv.ParseUint(strings.TrimSuffix(c.Ctx.Input.Param(":channel"), ".flv"), 10, 8)
+	if nil != err || "" == phone {
+		c.Ctx.Output.SetStatus(http.StatusBadRequest)
+		c.Ctx.Output.Body([]byte("invalid phone or channel"))
+		return
+	}
+
+	if nil == media.LiveApp {
+		c.Ctx.Output.SetStatus(http.StatusServiceUnavailable)
+		c.Ctx.Output.Body([]byte(errServiceNotRunning.Error()))
+		return
+	}
+
+	viewer, err := media.LiveApp.Watch(phone, byte(channel))
+	if nil != err {
+		c.Ctx.Output.SetStatus(requestStatus(err))
+		c.Ctx.Output.Body([]byte(err.Error()))
+		return
+	}
+	defer viewer.Close()
+
+	serveFlv(c.Ctx, viewer)
+}
+
+// serveFlv 向请求方输出观众的FLV数据，带websocket升级头时使用WS-FLV，阻塞直到连接断开或数据结束
+func serveFlv(ctx *context.Context, viewer *media.Viewer) {
+	if websocket.IsWebSocketUpgrade(ctx.Request) {
+		serveWebsocket(ctx, viewer)
+	} else {
+		serveHTTP(ctx, viewer)
+	}
+}
+
+func serveHTTP(ctx *context.Context, viewer *media.Viewer) {
+	w := ctx.ResponseWriter
+	w.Header().Set("Content-Type", "video/x-flv")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+
+	done := ctx.Request.Context().Done()
+	for {
+		select {
+		case data, ok := <-viewer.Data():
+			if !ok {
+				return
+			}
+			if _, err := w.Write(data); nil != err {
+				return
+			}
+			w.Flush()
+		case <-done:
+			return
+		}
+	}
+}
+
+func serveWebsocket(ctx *context.Context, viewer *media.Viewer) {
+	conn, err := upgrader.Upgrade(ctx.ResponseWriter, ctx.Request, nil)
+	if nil != err {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// 读取并丢弃浏览器消息，连接关闭时结束观看
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); nil != err {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data, ok := <-viewer.Data():
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); nil != err {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
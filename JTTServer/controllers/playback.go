@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"JTTServer/jtt"
+	"JTTServer/media"
+	"JTTServer/upload"
+	"common/protocol"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// PlaybackController 终端录像查询与远程回放
+type PlaybackController struct {
+	beego.Controller
+}
+
+// Records 查询终端录像，GET /playback/:phone/records?channel=1&start_time=RFC3339&end_time=RFC3339
+func (c *PlaybackController) Records() {
+	if !c.ready() {
+		return
+	}
+
+	var query media.RecordQuery
+	var err error
+	for _, item := range []struct {
+		name  string
+		value *byte
+	}{
+		{"channel", &query.Channel},
+		{"media_type", &query.MediaType},
+		{"stream_type", &query.StreamType},
+		{"storage_type", &query.StorageType},
+	} {
+		if *item.value, err = c.byteParam(item.name); nil != err {
+			c.fail(http.StatusBadRequest, err)
+			return
+		}
+	}
+	if query.STime, err = c.timeParam("start_time"); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	if query.ETime, err = c.timeParam("end_time"); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := media.PlaybackApp.Query(c.Ctx.Input.Param(":phone"), query)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+
+	c.Data["json"] = list
+	c.ServeJSON()
+}
+
+// Start 开始回放，POST /playback/:phone，请求体为Records返回的一条录像
+func (c *PlaybackController) Start() {
+	if !c.ready() {
+		return
+	}
+
+	var resource protocol.MediaResource
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&resource); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := media.PlaybackApp.Start(c.Ctx.Input.Param(":phone"), resource)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Sessions 获取所有回放会话，GET /playback/sessions
+func (c *PlaybackController) Sessions() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = media.PlaybackApp.Sessions()
+	c.ServeJSON()
+}
+
+// Flv 观看回放，GET /playback/sessions/:id.flv，带websocket升级头时使用WS-FLV
+func (c *PlaybackController) Flv() {
+	c.EnableRender = false
+	if !c.ready() {
+		return
+	}
+
+	viewer, err := media.PlaybackApp.Watch(strings.TrimSuffix(c.Ctx.Input.Param(":id"), ".flv"))
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+	defer viewer.Close()
+
+	serveFlv(c.Ctx, viewer)
+}
+
+// Control 回放控制，POST /playback/sessions/:id/control，请求体为media.PlaybackControl
+func (c *PlaybackController) Control() {
+	if !c.ready() {
+		return
+	}
+
+	var control media.PlaybackControl
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&control); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	id := c.Ctx.Input.Param(":id")
+	if err := media.PlaybackApp.Control(id, control); nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+
+	info, _ := media.PlaybackApp.Session(id)
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Stop 结束回放，DELETE /playback/sessions/:id
+func (c *PlaybackController) Stop() {
+	if !c.ready() {
+		return
+	}
+
+	if err := media.PlaybackApp.Stop(c.Ctx.Input.Param(":id")); nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+func (c *PlaybackController) ready() bool {
+	if nil == media.PlaybackApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *PlaybackController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
+
+func (c *PlaybackController) byteParam(name string) (byte, error) {
+	value := c.GetString(name)
+	if "" == value {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 8)
+	return byte(v), err
+}
+
+func (c *PlaybackController) timeParam(name string) (time.Time, error) {
+	value := c.GetString(name)
+	if "" == value {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// requestStatus 终端请求错误对应的HTTP状态码
+func requestStatus(err error) int {
+	switch err {
+	case jtt.ErrClientOffline, media.ErrPlaybackNotFound, upload.ErrTaskNotFound:
+		return http.StatusNotFound
+	case media.ErrPlaybackBusy, upload.ErrTaskDone:
+		return http.StatusConflict
+	case jtt.ErrRequestTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
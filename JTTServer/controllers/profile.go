@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"JTTServer/profile"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var (
+	errNoPhones        = errors.New("phones are required")
+	errResultNotFound  = errors.New("no profile has been applied to the terminal")
+	errProfileMismatch = errors.New("the profile name does not match the path")
+)
+
+// ProfileController 终端参数配置
+type ProfileController struct {
+	beego.Controller
+}
+
+// Profiles 获取所有参数配置，GET /profiles
+func (c *ProfileController) Profiles() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = profile.ProfileApp.Profiles()
+	c.ServeJSON()
+}
+
+// Profile 获取参数配置，GET /profiles/:name
+func (c *ProfileController) Profile() {
+	if !c.ready() {
+		return
+	}
+
+	p, ok := profile.ProfileApp.Profile(c.Ctx.Input.Param(":name"))
+	if !ok {
+		c.fail(http.StatusNotFound, profile.ErrProfileNotFound)
+		return
+	}
+
+	c.Data["json"] = p
+	c.ServeJSON()
+}
+
+// Save 新建或更新参数配置，PUT /profiles/:name，请求体为profile.Profile，
+// 参数项为{"id":1,"value":30}，参数值类型见protocol.ParamDefs
+func (c *ProfileController) Save() {
+	if !c.ready() {
+		return
+	}
+
+	var p profile.Profile
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&p); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	name := c.Ctx.Input.Param(":name")
+	if "" == p.Name {
+		p.Name = name
+	} else if name != p.Name {
+		c.fail(http.StatusBadRequest, errProfileMismatch)
+		return
+	}
+
+	p, err := profile.ProfileApp.SaveProfile(p)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	c.Data["json"] = p
+	c.ServeJSON()
+}
+
+// Delete 删除参数配置，DELETE /profiles/:name
+func (c *ProfileController) Delete() {
+	if !c.ready() {
+		return
+	}
+
+	if err := profile.ProfileApp.DeleteProfile(c.Ctx.Input.Param(":name")); nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+// Apply 向一组终端下发参数配置，POST /profiles/:name/apply，请求体为{"phones":["13912345678"]}，
+// 在后台下发并返回各终端下发中的结果，通过GET /profiles/results/:phone查询最终结果，不在线的终端鉴权后自动下发
+func (c *ProfileController) Apply() {
+	if !c.ready() {
+		return
+	}
+
+	var body struct {
+		Phones []string `json:"phones"`
+	}
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&body); nil != err || 0 == len(body.Phones) {
+		c.fail(http.StatusBadRequest, errNoPhones)
+		return
+	}
+
+	results, err := profile.ProfileApp.Apply(c.Ctx.Input.Param(":name"), body.Phones)
+	if nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+
+	c.Ctx.Output.SetStatus(http.StatusAccepted)
+	c.Data["json"] = results
+	c.ServeJSON()
+}
+
+// Results 获取所有终端最近一次的下发结果，GET /profiles/results
+func (c *ProfileController) Results() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = profile.ProfileApp.Results()
+	c.ServeJSON()
+}
+
+// Result 获取终端最近一次的下发结果，GET /profiles/results/:phone
+func (c *ProfileController) Result() {
+	if !c.ready() {
+		return
+	}
+
+	result, ok := profile.ProfileApp.Result(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, errResultNotFound)
+		return
+	}
+
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
+func (c *ProfileController) ready() bool {
+	if nil == profile.ProfileApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *ProfileController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"JTTServer/recorder"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errInvalidRange = errors.New("start_time and end_time are required")
+
+// RecorderController 行驶记录仪数据采集
+type RecorderController struct {
+	beego.Controller
+}
+
+// Gather 采集行驶记录仪数据，GET /recorders/:phone/:cmd?start_time=RFC3339&end_time=RFC3339，
+// cmd为GB/T 19056命令字，如0x03；0x08及以上的记录需要指定时间范围
+func (c *RecorderController) Gather() {
+	if !c.ready() {
+		return
+	}
+
+	cmd, err := strconv.ParseUint(c.Ctx.Input.Param(":cmd"), 0, 8)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	phone := c.Ctx.Input.Param(":phone")
+	if cmd < 0x08 {
+		value, err := recorder.RecorderApp.Query(phone, byte(cmd))
+		if nil != err {
+			c.fail(requestStatus(err), err)
+			return
+		}
+		c.Data["json"] = value
+		c.ServeJSON()
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, c.GetString("start_time"))
+	if nil != err {
+		c.fail(http.StatusBadRequest, errInvalidRange)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, c.GetString("end_time"))
+	if nil != err {
+		c.fail(http.StatusBadRequest, errInvalidRange)
+		return
+	}
+
+	records, err := recorder.RecorderApp.Collect(phone, byte(cmd), start, end)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+	c.Data["json"] = records
+	c.ServeJSON()
+}
+
+func (c *RecorderController) ready() bool {
+	if nil == recorder.RecorderApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *RecorderController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
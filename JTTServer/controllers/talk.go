@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"JTTServer/media"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	errTalkCodec = errors.New("only pcm (16-bit little-endian mono) audio is supported")
+	errTalkOpus  = errors.New("opus audio is not supported yet, capture pcm (16-bit little-endian mono) in the browser instead")
+)
+
+// TalkController 双向对讲与监听，浏览器音频通过WebSocket传输
+type TalkController struct {
+	beego.Controller
+}
+
+// Talk 对讲或监听，GET /talk/:phone/:channel?mode=talk|monitor&rate=48000&codec=pcm（websocket）
+//
+// 浏览器发送16位小端单声道PCM二进制消息（采样率为rate），服务端回送终端上行音频（8kHz 16位小端单声道PCM）。
+// codec目前只支持pcm：服务端没有Opus解码器，codec=opus时返回415，浏览器需以AudioWorklet等方式采集PCM后发送。
+func (c *TalkController) Talk() {
+	c.EnableRender = false
+
+	phone := c.Ctx.Input.Param(":phone")
+	channel, err := strconv.ParseUint(c.Ctx.Input.Param(":channel"), 10, 8)
+	if nil != err || "" == phone {
+		c.fail(http.StatusBadRequest, errors.New("invalid phone or channel"))
+		return
+	}
+
+	mode := media.TalkModeTalk
+	if "monitor" == c.GetString("mode") {
+		mode = media.TalkModeMonitor
+	}
+	switch c.GetString("codec", "pcm") {
+	case "pcm":
+	case "opus":
+		c.fail(http.StatusUnsupportedMediaType, errTalkOpus)
+		return
+	default:
+		c.fail(http.StatusUnsupportedMediaType, errTalkCodec)
+		return
+	}
+	rate, err := c.GetInt("rate", 8000)
+	if nil != err || rate <= 0 {
+		c.fail(http.StatusBadRequest, errors.New("invalid rate"))
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Ctx.Request) {
+		c.fail(http.StatusBadRequest, errors.New("websocket upgrade required"))
+		return
+	}
+	if nil == media.TalkApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return
+	}
+
+	session, err := media.TalkApp.Open(phone, byte(channel), mode, rate)
+	if nil != err {
+		status := requestStatus(err)
+		switch err {
+		case media.ErrTalkBusy:
+			status = http.StatusConflict
+		case media.ErrAudioUnsupported:
+			status = http.StatusUnsupportedMediaType
+		}
+		c.fail(status, err)
+		return
+	}
+	defer session.Close()
+
+	conn, err := upgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
+	if nil != err {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+
+	// 浏览器音频下发到终端，连接关闭时结束对讲
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if nil != err {
+				return
+			}
+			if websocket.BinaryMessage == messageType {
+				if err := session.Write(data); nil != err && media.ErrStreamOffline != err {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data, ok := <-session.Audio():
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); nil != err {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (c *TalkController) fail(status int, err error) {
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"JTTServer/text"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errDispatchNotFound = errors.New("the dispatch does not exist")
+
+// TextController 文本信息下发
+type TextController struct {
+	beego.Controller
+}
+
+// Send 下发文本信息，POST /texts，请求体为text.Request，返回的下发记录中各终端为下发中
+func (c *TextController) Send() {
+	if !c.ready() {
+		return
+	}
+
+	var req text.Request
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	if "" == req.Operator {
+		req.Operator = c.Ctx.Input.IP()
+	}
+
+	dispatch, err := text.TextApp.Send(req)
+	switch {
+	case text.ErrTemplateNotFound == err || text.ErrGroupNotFound == err:
+		c.fail(http.StatusNotFound, err)
+		return
+	case nil != err:
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = dispatch
+	c.ServeJSON()
+}
+
+// Dispatches 获取全部下发记录，GET /texts
+func (c *TextController) Dispatches() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = text.TextApp.Dispatches()
+	c.ServeJSON()
+}
+
+// Dispatch 获取下发记录及各终端接收状态，GET /texts/:id
+func (c *TextController) Dispatch() {
+	if !c.ready() {
+		return
+	}
+
+	dispatch, ok := text.TextApp.Dispatch(c.Ctx.Input.Param(":id"))
+	if !ok {
+		c.fail(http.StatusNotFound, errDispatchNotFound)
+		return
+	}
+	c.Data["json"] = dispatch
+	c.ServeJSON()
+}
+
+// Templates 获取全部模板，GET /texts/templates
+func (c *TextController) Templates() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = text.TextApp.Templates()
+	c.ServeJSON()
+}
+
+// SaveTemplate 新建或更新模板，POST /texts/templates，请求体为text.Template
+func (c *TextController) SaveTemplate() {
+	if !c.ready() {
+		return
+	}
+
+	var t text.Template
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&t); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	saved, err := text.TextApp.SaveTemplate(t)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = saved
+	c.ServeJSON()
+}
+
+// DeleteTemplate 删除模板，DELETE /texts/templates/:name
+func (c *TextController) DeleteTemplate() {
+	if !c.ready() {
+		return
+	}
+
+	if err := text.TextApp.DeleteTemplate(c.Ctx.Input.Param(":name")); nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+// Groups 获取全部终端分组，GET /texts/groups
+func (c *TextController) Groups() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = text.TextApp.Groups()
+	c.ServeJSON()
+}
+
+// SaveGroup 新建或更新终端分组，POST /texts/groups，请求体为text.Group
+func (c *TextController) SaveGroup() {
+	if !c.ready() {
+		return
+	}
+
+	var g text.Group
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&g); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	saved, err := text.TextApp.SaveGroup(g)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = saved
+	c.ServeJSON()
+}
+
+// DeleteGroup 删除终端分组，DELETE /texts/groups/:name
+func (c *TextController) DeleteGroup() {
+	if !c.ready() {
+		return
+	}
+
+	if err := text.TextApp.DeleteGroup(c.Ctx.Input.Param(":name")); nil != err {
+		c.fail(http.StatusNotFound, err)
+		return
+	}
+	c.Ctx.Output.SetStatus(http.StatusNoContent)
+}
+
+func (c *TextController) ready() bool {
+	if nil == text.TextApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *TextController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
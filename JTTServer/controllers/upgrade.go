@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"JTTServer/upgrade"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+// UpgradeController 终端升级包与升级计划
+type UpgradeController struct {
+	beego.Controller
+}
+
+// Firmwares 获取所有升级包，GET /firmwares
+func (c *UpgradeController) Firmwares() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = upgrade.UpgradeApp.Firmwares()
+	c.ServeJSON()
+}
+
+// AddFirmware 上传升级包，POST /firmwares，multipart表单：file为升级包文件，
+// vendor_id、version必填，type为升级类型，models为逗号分隔的适用终端型号，name为升级包名称
+func (c *UpgradeController) AddFirmware() {
+	if !c.ready() {
+		return
+	}
+
+	file, _, err := c.GetFile("file")
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	tp, err := c.GetUint8("type", 0)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	info := upgrade.Firmware{
+		Name:     c.GetString("name"),
+		Type:     tp,
+		VendorID: c.GetString("vendor_id"),
+		Version:  c.GetString("version"),
+	}
+	for _, model := range strings.Split(c.GetString("models"), ",") {
+		if model = strings.TrimSpace(model); "" != model {
+			info.Models = append(info.Models, model)
+		}
+	}
+
+	info, err = upgrade.UpgradeApp.AddFirmware(info, data)
+	if nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Firmware 获取升级包，GET /firmwares/:id
+func (c *UpgradeController) Firmware() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := upgrade.UpgradeApp.Firmware(c.Ctx.Input.Param(":id"))
+	if !ok {
+		c.fail(http.StatusNotFound, upgrade.ErrFirmwareNotFound)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// DeleteFirmware 删除升级包，DELETE /firmwares/:id
+func (c *UpgradeController) DeleteFirmware() {
+	if !c.ready() {
+		return
+	}
+
+	switch err := upgrade.UpgradeApp.DeleteFirmware(c.Ctx.Input.Param(":id")); err {
+	case nil:
+		c.Ctx.Output.SetStatus(http.StatusNoContent)
+	case upgrade.ErrFirmwareInUse:
+		c.fail(http.StatusConflict, err)
+	default:
+		c.fail(http.StatusNotFound, err)
+	}
+}
+
+// Campaigns 获取所有升级计划，GET /upgrades
+func (c *UpgradeController) Campaigns() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = upgrade.UpgradeApp.Campaigns()
+	c.ServeJSON()
+}
+
+// StartCampaign 新建升级计划，POST /upgrades，请求体为upgrade.CampaignRequest
+func (c *UpgradeController) StartCampaign() {
+	if !c.ready() {
+		return
+	}
+
+	var req upgrade.CampaignRequest
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := upgrade.UpgradeApp.StartCampaign(req)
+	switch err {
+	case nil:
+	case upgrade.ErrFirmwareNotFound:
+		c.fail(http.StatusNotFound, err)
+		return
+	default:
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Campaign 获取升级计划，GET /upgrades/:id
+func (c *UpgradeController) Campaign() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := upgrade.UpgradeApp.Campaign(c.Ctx.Input.Param(":id"))
+	if !ok {
+		c.fail(http.StatusNotFound, upgrade.ErrCampaignNotFound)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Control 暂停、继续或中止升级计划，POST /upgrades/:id/control，请求体为{"action":"pause"}，见upgrade.ControlXXX
+func (c *UpgradeController) Control() {
+	if !c.ready() {
+		return
+	}
+
+	var body struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&body); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := upgrade.UpgradeApp.Control(c.Ctx.Input.Param(":id"), body.Action)
+	switch err {
+	case nil:
+	case upgrade.ErrCampaignNotFound:
+		c.fail(http.StatusNotFound, err)
+		return
+	case upgrade.ErrCampaignDone:
+		c.fail(http.StatusConflict, err)
+		return
+	default:
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+func (c *UpgradeController) ready() bool {
+	if nil == upgrade.UpgradeApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *UpgradeController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
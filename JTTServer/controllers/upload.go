@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"JTTServer/upload"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errInvalidControl = errors.New("invalid upload control")
+
+// UploadController 终端文件上传任务
+type UploadController struct {
+	beego.Controller
+}
+
+// Start 新建上传任务，POST /uploads/:phone，请求体为upload.UploadRequest
+func (c *UploadController) Start() {
+	if !c.ready() {
+		return
+	}
+
+	var req upload.UploadRequest
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&req); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := upload.UploadApp.Start(c.Ctx.Input.Param(":phone"), req)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Tasks 获取所有上传任务，GET /uploads
+func (c *UploadController) Tasks() {
+	if !c.ready() {
+		return
+	}
+
+	c.Data["json"] = upload.UploadApp.Tasks()
+	c.ServeJSON()
+}
+
+// Task 获取上传任务，GET /uploads/:id
+func (c *UploadController) Task() {
+	if !c.ready() {
+		return
+	}
+
+	info, ok := upload.UploadApp.Task(c.Ctx.Input.Param(":id"))
+	if !ok {
+		c.fail(http.StatusNotFound, upload.ErrTaskNotFound)
+		return
+	}
+
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+// Control 暂停、继续或取消上传任务，POST /uploads/:id/control，请求体为{"ctl":0}，见upload.ControlXXX
+func (c *UploadController) Control() {
+	if !c.ready() {
+		return
+	}
+
+	var body struct {
+		Ctl byte `json:"ctl"`
+	}
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&body); nil != err || body.Ctl > upload.ControlCancel {
+		c.fail(http.StatusBadRequest, errInvalidControl)
+		return
+	}
+
+	info, err := upload.UploadApp.Control(c.Ctx.Input.Param(":id"), body.Ctl)
+	if nil != err {
+		c.fail(requestStatus(err), err)
+		return
+	}
+
+	c.Data["json"] = info
+	c.ServeJSON()
+}
+
+func (c *UploadController) ready() bool {
+	if nil == upload.UploadApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *UploadController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
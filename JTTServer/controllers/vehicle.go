@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"JTTServer/vehicle"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	beego "github.com/beego/beego/v2/server/web"
+)
+
+var errNoVehicleControl = errors.New("no vehicle control has been sent to the terminal")
+
+// VehicleController 车辆控制
+type VehicleController struct {
+	beego.Controller
+}
+
+// Defs 获取车辆控制类型定义，GET /vehicles/controls
+func (c *VehicleController) Defs() {
+	c.Data["json"] = protocol.VehCtrlDefs()
+	c.ServeJSON()
+}
+
+// Control 下发车辆控制，POST /vehicles/:phone/control，请求体为{"params":[{"id":1,"value":0}]}，
+// 控制类型见GET /vehicles/controls
+func (c *VehicleController) Control() {
+	if !c.ready() {
+		return
+	}
+
+	var body struct {
+		Params []protocol.VehCtrlParam `json:"params"`
+	}
+	if err := json.NewDecoder(c.Ctx.Request.Body).Decode(&body); nil != err {
+		c.fail(http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := vehicle.VehicleApp.Control(c.Ctx.Input.Param(":phone"), body.Params)
+	if nil != err {
+		if result.Time.IsZero() {
+			c.fail(http.StatusBadRequest, err)
+			return
+		}
+		c.Ctx.Output.SetStatus(requestStatus(err))
+	}
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
+// Result 获取最近一次的车辆控制结果，GET /vehicles/:phone/control
+func (c *VehicleController) Result() {
+	if !c.ready() {
+		return
+	}
+
+	result, ok := vehicle.VehicleApp.Result(c.Ctx.Input.Param(":phone"))
+	if !ok {
+		c.fail(http.StatusNotFound, errNoVehicleControl)
+		return
+	}
+	c.Data["json"] = result
+	c.ServeJSON()
+}
+
+func (c *VehicleController) ready() bool {
+	if nil == vehicle.VehicleApp {
+		c.fail(http.StatusServiceUnavailable, errServiceNotRunning)
+		return false
+	}
+	return true
+}
+
+func (c *VehicleController) fail(status int, err error) {
+	c.EnableRender = false
+	c.Ctx.Output.SetStatus(status)
+	c.Ctx.Output.Body([]byte(err.Error()))
+}
@@ -0,0 +1,405 @@
+package geofence
+
+import (
+	"common/protocol"
+	"log"
+	"strconv"
+	"time"
+)
+
+// 保留的最近事件数
+const maxEvents = 1000
+
+// 夜间时段，夜间最高速度在该时段内生效
+const (
+	nightStart = 22 // 22:00
+	nightEnd   = 6  // 06:00
+)
+
+// 平台判断的区域事件类型
+const (
+	EventEnter           = "enter"             // 进区域或路线
+	EventExit            = "exit"              // 出区域或路线
+	EventOverspeed       = "overspeed"         // 区域或路段内超速
+	EventDeviate         = "deviate"           // 偏离路线
+	EventSegmentTooShort = "segment_too_short" // 路段行驶时间不足
+	EventSegmentTooLong  = "segment_too_long"  // 路段行驶时间过长
+)
+
+// Event 平台根据位置判断的区域事件
+type Event struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 事件类型，见EventXXX
+	Type string `json:"type"`
+	// 区域或路线
+	Fence Key `json:"fence"`
+	// 区域或路线名称
+	Name string `json:"name,omitempty"`
+	// 路段id，路线事件有效
+	Segment uint32 `json:"segment,omitempty"`
+	// 速度，单位km/h
+	Speed float64 `json:"speed,omitempty"`
+	// 最高速度，单位km/h，超速事件有效
+	Limit uint16 `json:"limit,omitempty"`
+	// 路段行驶时间，单位s，路段行驶时间事件有效
+	Duration int64 `json:"duration,omitempty"`
+	// 纬度，南纬为负
+	Latitude float64 `json:"latitude"`
+	// 经度，西经为负
+	Longitude float64 `json:"longitude"`
+	// 位置时间
+	Time time.Time `json:"time"`
+}
+
+// speeding 超速状态
+type speeding struct {
+	// 开始超速的时间
+	since time.Time
+	// 本次超速是否已产生事件
+	reported bool
+}
+
+// routeState 终端在路线上的状态
+type routeState struct {
+	// 当前所在路段序号，-1表示不在路线上
+	segment int
+	// 进入当前路段的时间
+	entered time.Time
+}
+
+// tracking 终端的区域判断状态
+type tracking struct {
+	// 最近判断的位置时间，早于该时间的位置（如补传）不再判断
+	last time.Time
+	// 所在的区域
+	inside map[Key]bool
+	// 路线状态
+	routes map[Key]*routeState
+	// 区域或路段超速状态，路段以路段序号区分
+	speeding map[Key]map[int]*speeding
+}
+
+// OnPosition 判断终端位置与已分配的区域及路线，返回产生的事件。
+// 未定位及早于上次判断的位置忽略
+func (m *Manager) OnPosition(phone string, position *protocol.Position) []Event {
+	if !position.Status.IsFixed() {
+		return nil
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t, ok := m.terminals[phone]
+	if !ok || 0 == len(t.Fences) {
+		delete(m.tracks, phone)
+		return nil
+	}
+	track, ok := m.tracks[phone]
+	if !ok {
+		track = &tracking{
+			inside:   make(map[Key]bool),
+			routes:   make(map[Key]*routeState),
+			speeding: make(map[Key]map[int]*speeding),
+		}
+		m.tracks[phone] = track
+	}
+	if position.Time.Before(track.last) {
+		return nil
+	}
+	track.last = position.Time
+
+	if nil == m.index {
+		m.index = newIndex(m.fences)
+	}
+	x := toDegrees(position.Longitude, position.Status.IsWest())
+	y := toDegrees(position.Latitude, position.Status.IsSourth())
+	candidates := m.index.query(x, y)
+
+	e := evaluation{
+		track:    track,
+		position: position,
+		base:     Event{Phone: phone, Latitude: y, Longitude: x, Time: position.Time},
+		speed:    float64(position.Speed) / 10,
+	}
+	assigned := make(map[Key]bool, len(t.Fences))
+	for _, key := range t.Fences {
+		s, ok := m.index.shapes[key]
+		if !ok {
+			continue
+		}
+		assigned[key] = true
+		candidate := nil != candidates[key]
+		if KindRoute == key.Kind {
+			e.route(s, candidate, x, y)
+		} else {
+			e.area(s, candidate && s.contains(x, y))
+		}
+	}
+
+	// 清除已取消分配的区域状态
+	for key := range track.inside {
+		if !assigned[key] {
+			delete(track.inside, key)
+		}
+	}
+	for key := range track.routes {
+		if !assigned[key] {
+			delete(track.routes, key)
+		}
+	}
+	for key := range track.speeding {
+		if !assigned[key] {
+			delete(track.speeding, key)
+		}
+	}
+
+	for _, event := range e.events {
+		log.Printf("终端[%s]区域事件：%s %s", phone, event.Type, event.Fence)
+	}
+	m.events = append(m.events, e.events...)
+	if len(m.events) > maxEvents {
+		m.events = append([]Event(nil), m.events[len(m.events)-maxEvents:]...)
+	}
+	return e.events
+}
+
+// Events 获取最近的区域事件，phone为空时返回所有终端的事件
+func (m *Manager) Events(phone string) []Event {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	events := []Event{}
+	for _, event := range m.events {
+		if "" == phone || phone == event.Phone {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// evaluation 单个位置的判断过程
+type evaluation struct {
+	track    *tracking
+	position *protocol.Position
+	base     Event
+	speed    float64
+	events   []Event
+}
+
+// emit 产生事件
+func (e *evaluation) emit(typ string, s *shape, fill func(*Event)) {
+	event := e.base
+	event.Type, event.Fence, event.Name = typ, s.key, fenceName(s.fence)
+	if nil != fill {
+		fill(&event)
+	}
+	e.events = append(e.events, event)
+}
+
+// area 判断圆形、矩形、多边形区域
+func (e *evaluation) area(s *shape, in bool) {
+	attr, stime, etime, speed, duration, night := areaLimits(s.fence)
+	if attr.HasTime() && !inWindow(e.position.Time, stime, etime) {
+		// 不在时间段内，区域不生效
+		delete(e.track.inside, s.key)
+		delete(e.track.speeding, s.key)
+		return
+	}
+
+	was := e.track.inside[s.key]
+	switch {
+	case in && !was && (attr.HasEnterAlarmToServer() || attr.HasEnterAlarmToDriver()):
+		e.emit(EventEnter, s, nil)
+	case !in && was && (attr.HasExitAlarmToServer() || attr.HasExitAlarmToDriver()):
+		e.emit(EventExit, s, nil)
+	}
+	if in {
+		e.track.inside[s.key] = true
+	} else {
+		delete(e.track.inside, s.key)
+	}
+
+	if in && attr.HasSpeed() {
+		e.overspeed(s, -1, speedLimit(e.position.Time, speed, night), duration)
+	} else {
+		delete(e.track.speeding, s.key)
+	}
+}
+
+// route 判断路线偏离、路段行驶时间及路段超速
+func (e *evaluation) route(s *shape, candidate bool, x, y float64) {
+	route := s.fence.Route
+	if route.Attr.HasTime() && !inWindow(e.position.Time, route.STime, route.ETime) {
+		delete(e.track.routes, s.key)
+		delete(e.track.speeding, s.key)
+		return
+	}
+
+	segment := -1
+	if candidate {
+		segment = s.segment(x, y)
+	}
+	state, ok := e.track.routes[s.key]
+	if !ok {
+		state = &routeState{segment: -1}
+		e.track.routes[s.key] = state
+	}
+
+	if state.segment >= 0 && segment != state.segment {
+		// 驶离上一路段（进入其他路段或偏离路线），判断行驶时间
+		e.segmentTime(s, state)
+	}
+	switch {
+	case segment < 0 && state.segment >= 0:
+		// 只在终端到过路线上之后判断偏离，未驶入路线的终端不产生偏离事件
+		e.emit(EventDeviate, s, nil)
+		if route.Attr.HasExitAlarmToServer() || route.Attr.HasExitAlarmToDriver() {
+			e.emit(EventExit, s, nil)
+		}
+	case segment >= 0 && state.segment < 0:
+		if route.Attr.HasEnterAlarmToServer() || route.Attr.HasEnterAlarmToDriver() {
+			e.emit(EventEnter, s, nil)
+		}
+	}
+	if segment != state.segment {
+		state.segment, state.entered = segment, e.position.Time
+		delete(e.track.speeding, s.key)
+	}
+
+	if segment >= 0 {
+		tag := route.Vertexs[segment].Tag
+		if tag.Attr.HasSpeedLimit() {
+			e.overspeed(s, segment, speedLimit(e.position.Time, tag.MaxSpeed, tag.MaxSpeedInNight), tag.Duration)
+		}
+	}
+}
+
+// segmentTime 驶离路段时判断路段行驶时间
+func (e *evaluation) segmentTime(s *shape, state *routeState) {
+	tag := s.fence.Route.Vertexs[state.segment].Tag
+	if !tag.Attr.HasTime() {
+		return
+	}
+	elapsed := int64(e.position.Time.Sub(state.entered) / time.Second)
+	fill := func(event *Event) {
+		event.Segment, event.Duration = tag.ID, elapsed
+	}
+	if elapsed > int64(tag.MaxDuration) {
+		e.emit(EventSegmentTooLong, s, fill)
+	} else if elapsed < int64(tag.MinDuration) {
+		e.emit(EventSegmentTooShort, s, fill)
+	}
+}
+
+// overspeed 速度超过最高速度并持续超速持续时间后产生一次超速事件，速度恢复后重新计时
+func (e *evaluation) overspeed(s *shape, segment int, limit uint16, duration byte) {
+	states, ok := e.track.speeding[s.key]
+	if !ok {
+		states = make(map[int]*speeding)
+		e.track.speeding[s.key] = states
+	}
+	if e.speed <= float64(limit) {
+		delete(states, segment)
+		return
+	}
+
+	state, ok := states[segment]
+	if !ok {
+		state = &speeding{since: e.position.Time}
+		states[segment] = state
+	}
+	if !state.reported && e.position.Time.Sub(state.since) >= time.Duration(duration)*time.Second {
+		state.reported = true
+		e.emit(EventOverspeed, s, func(event *Event) {
+			event.Speed, event.Limit = e.speed, limit
+			if segment >= 0 {
+				event.Segment = s.fence.Route.Vertexs[segment].Tag.ID
+			}
+		})
+	}
+}
+
+// areaLimits 区域属性、时间段及限速
+func areaLimits(fence *Fence) (attr protocol.AreaAttr, stime, etime string, speed uint16, duration byte, night uint16) {
+	switch fence.Kind {
+	case KindCircle:
+		a := fence.Circle
+		return a.Attr, a.STime, a.ETime, a.MaxSpeed, a.Duration, a.MaxSpeedInNight
+	case KindRect:
+		a := fence.Rect
+		return a.Attr, a.STime, a.ETime, a.MaxSpeed, a.Duration, a.MaxSpeedInNight
+	case KindPolygon:
+		a := fence.Polygon
+		return a.Attr, a.STime, a.ETime, a.MaxSpeed, a.Duration, a.MaxSpeedInNight
+	}
+	return
+}
+
+// fenceName 区域或路线名称
+func fenceName(fence *Fence) string {
+	switch fence.Kind {
+	case KindCircle:
+		return fence.Circle.Name
+	case KindRect:
+		return fence.Rect.Name
+	case KindPolygon:
+		return fence.Polygon.Name
+	case KindRoute:
+		return fence.Route.Name
+	}
+	return ""
+}
+
+// speedLimit 当前的最高速度，夜间且设置了夜间最高速度时取夜间最高速度
+func speedLimit(t time.Time, speed, night uint16) uint16 {
+	if 0 != night && noNightSpeed != night && (t.Hour() >= nightStart || t.Hour() < nightEnd) {
+		return night
+	}
+	return speed
+}
+
+// inWindow 时间是否在区域时间段内。时间为YYMMDDhhmmss，
+// 年、月、日为0时表示不限，如000000080000~000000180000表示每天8点至18点
+func inWindow(t time.Time, stime, etime string) bool {
+	start, ok1 := windowTime(t, stime)
+	end, ok2 := windowTime(t, etime)
+	if !ok1 || !ok2 {
+		return true
+	}
+	if end.Before(start) {
+		// 跨越零点的每日时间段
+		return !t.Before(start) || !t.After(end)
+	}
+	return !t.Before(start) && !t.After(end)
+}
+
+// windowTime 以t补齐不限的年、月、日
+func windowTime(t time.Time, value string) (time.Time, bool) {
+	if 12 != len(value) {
+		return time.Time{}, false
+	}
+	var fields [6]int
+	for idx := range fields {
+		n, err := strconv.Atoi(value[idx*2 : idx*2+2])
+		if nil != err {
+			return time.Time{}, false
+		}
+		fields[idx] = n
+	}
+	if fields[1] > 12 || fields[2] > 31 || fields[3] > 23 || fields[4] > 59 || fields[5] > 59 {
+		return time.Time{}, false
+	}
+
+	year, month, day := t.Year(), t.Month(), t.Day()
+	if 0 != fields[0] {
+		year = 2000 + fields[0]
+	}
+	if 0 != fields[1] {
+		month = time.Month(fields[1])
+	}
+	if 0 != fields[2] {
+		day = fields[2]
+	}
+	return time.Date(year, month, day, fields[3], fields[4], fields[5], 0, t.Location()), true
+}
@@ -0,0 +1,154 @@
+package geofence
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"testing"
+	"time"
+)
+
+// position 新建已定位的位置，speed单位km/h
+func position(lon, lat float64, speed uint16, t time.Time) *protocol.Position {
+	p := &protocol.Position{Speed: speed * 10, Time: t}
+	p.Status.Fixed(true)
+	p.Longitude, _ = fromDegrees(lon)
+	p.Latitude, _ = fromDegrees(lat)
+	return p
+}
+
+// types 事件类型列表
+func types(events []Event) []string {
+	var result []string
+	for _, event := range events {
+		result = append(result, event.Type)
+	}
+	return result
+}
+
+func expectEvents(t *testing.T, events []Event, want ...string) {
+	t.Helper()
+	got := types(events)
+	if len(want) != len(got) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	for idx := range want {
+		if want[idx] != got[idx] {
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEvaluateArea(t *testing.T) {
+	m := NewManager(newFakeTerminal().Request, terminaltest.ErrOffline, "")
+	fence := circle(1)
+	attr := &fence.Circle.Attr
+	attr.SetEnterAlarmToServer(true)
+	attr.SetExitAlarmToServer(true)
+	attr.SetSpeedEnable(true)
+	fence.Circle.MaxSpeed, fence.Circle.Duration, fence.Circle.MaxSpeedInNight = 60, 5, 40
+	m.SaveFence(fence)
+	m.terminals["1"] = &Terminal{Phone: "1", Fences: []Key{{KindCircle, 1}}}
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	expectEvents(t, m.OnPosition("1", position(113.91, 22.5, 50, now)))
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 70, now.Add(time.Second))), EventEnter)
+	// 超速持续时间不足
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 70, now.Add(time.Second*3))))
+	events := m.OnPosition("1", position(113.9, 22.5, 70, now.Add(time.Second*6)))
+	expectEvents(t, events, EventOverspeed)
+	if 60 != events[0].Limit || 70 != events[0].Speed {
+		t.Fatalf("unexpected event: %+v", events[0])
+	}
+	// 同一次超速只产生一次事件
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 70, now.Add(time.Second*20))))
+	// 补传的位置不判断
+	expectEvents(t, m.OnPosition("1", position(113.91, 22.5, 70, now)))
+	expectEvents(t, m.OnPosition("1", position(113.91, 22.5, 70, now.Add(time.Second*21))), EventExit)
+
+	// 夜间最高速度
+	night := time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local)
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 50, night)), EventEnter)
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 50, night.Add(time.Second*5))), EventOverspeed)
+
+	// 不在时间段内区域不生效
+	fence.Circle.Attr.SetTimeEnable(true)
+	fence.Circle.STime, fence.Circle.ETime = "000000080000", "000000180000"
+	m.SaveFence(fence)
+	expectEvents(t, m.OnPosition("1", position(113.91, 22.5, 0, night.Add(time.Second*6))))
+	expectEvents(t, m.OnPosition("1", position(113.9, 22.5, 0, night.Add(time.Second*7))))
+	if 5 != len(m.Events("1")) {
+		t.Fatalf("got events %v", types(m.Events("1")))
+	}
+}
+
+func TestEvaluateRoute(t *testing.T) {
+	m := NewManager(newFakeTerminal().Request, terminaltest.ErrOffline, "")
+	fence := route(1)
+	fence.Route.Vertexs = append(fence.Route.Vertexs, protocol.Vertex{ID: 3, Latitude: 22600000, Longitude: 114100000, Tag: protocol.Segment{ID: 3, Width: 50}})
+	first := &fence.Route.Vertexs[0].Tag
+	first.Attr.SetTimeEnable(true)
+	first.MaxDuration, first.MinDuration = 600, 60
+	second := &fence.Route.Vertexs[1].Tag
+	second.Attr.SetSpeedLimitEnable(true)
+	second.MaxSpeed = 80
+	m.SaveFence(fence)
+	m.terminals["1"] = &Terminal{Phone: "1", Fences: []Key{{KindRoute, 1}}}
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	// 第一段：(113.9, 22.5) -> (114.0, 22.6)，第二段：(114.0, 22.6) -> (114.1, 22.6)
+	expectEvents(t, m.OnPosition("1", position(113.95, 22.55, 60, now)))
+	// 第一段行驶时间不足
+	events := m.OnPosition("1", position(114.05, 22.6, 60, now.Add(time.Second*30)))
+	expectEvents(t, events, EventSegmentTooShort)
+	if 1 != events[0].Segment || 30 != events[0].Duration {
+		t.Fatalf("unexpected event: %+v", events[0])
+	}
+	// 路段限速
+	expectEvents(t, m.OnPosition("1", position(114.06, 22.6, 90, now.Add(time.Second*31))), EventOverspeed)
+	// 偏离路线约200m
+	expectEvents(t, m.OnPosition("1", position(114.07, 22.6018, 60, now.Add(time.Second*40))), EventDeviate)
+	expectEvents(t, m.OnPosition("1", position(114.07, 22.6018, 60, now.Add(time.Second*50))))
+	expectEvents(t, m.OnPosition("1", position(114.08, 22.6, 60, now.Add(time.Second*60))))
+
+	// 未驶入路线的终端不判断偏离
+	other := NewManager(newFakeTerminal().Request, terminaltest.ErrOffline, "")
+	other.SaveFence(fence)
+	other.terminals["2"] = &Terminal{Phone: "2", Fences: []Key{{KindRoute, 1}}}
+	expectEvents(t, other.OnPosition("2", position(120, 30, 60, now)))
+	expectEvents(t, other.OnPosition("2", position(113.95, 22.55, 60, now.Add(time.Second*10))))
+	// 由限制行驶时间的路段偏离路线，同时判断该路段的行驶时间
+	events = other.OnPosition("2", position(120, 30, 60, now.Add(time.Second*40)))
+	expectEvents(t, events, EventSegmentTooShort, EventDeviate)
+	if 1 != events[0].Segment || 30 != events[0].Duration {
+		t.Fatalf("unexpected event: %+v", events[0])
+	}
+}
+
+func TestIndex(t *testing.T) {
+	fences := make(map[Key]*Fence)
+	for id := uint32(1); id <= 1000; id++ {
+		fence := circle(id)
+		fence.Circle.CenterX = 100000000 + id*10000
+		fences[fence.Key()] = &fence
+	}
+	// 覆盖网格过多的大区域
+	large := Fence{Kind: KindRect, Rect: &protocol.RectArea{ID: 1, NWX: 70000000, NWY: 50000000, SEX: 130000000, SEY: 20000000}}
+	fences[large.Key()] = &large
+
+	idx := newIndex(fences)
+	if 1 != len(idx.large) {
+		t.Fatalf("got %d large shapes, want 1", len(idx.large))
+	}
+	candidates := idx.query(100.5, 22.5)
+	if 2 != len(candidates) || nil == candidates[Key{KindCircle, 50}] || nil == candidates[large.Key()] {
+		t.Fatalf("unexpected candidates: %v", candidates)
+	}
+	if s := candidates[Key{KindCircle, 50}]; !s.contains(100.5, 22.5) || s.contains(100.51, 22.5) {
+		t.Fatal("unexpected circle containment")
+	}
+
+	poly := newShape(&Fence{Kind: KindPolygon, Polygon: polygon(1).Polygon})
+	if !poly.contains(113.91, 22.59) || poly.contains(113.99, 22.58) {
+		t.Fatal("unexpected polygon containment")
+	}
+}
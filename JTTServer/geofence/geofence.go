@@ -0,0 +1,744 @@
+package geofence
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// GeofenceApp 默认的电子围栏服务，由Setup初始化
+	GeofenceApp *Manager
+)
+
+var (
+	// ErrFenceNotFound 区域或路线不存在
+	ErrFenceNotFound = errors.New("the fence does not exist")
+	// ErrFenceInUse 区域或路线已分配给终端
+	ErrFenceInUse = errors.New("the fence is assigned to terminals")
+	// ErrInvalidFence 区域或路线无效
+	ErrInvalidFence = errors.New("the fence is invalid")
+	// ErrUnknownOperation 未知的下发操作
+	ErrUnknownOperation = errors.New("unknown fence operation")
+)
+
+// Setup 初始化默认的电子围栏服务，offline为终端不在线时Requester返回的错误，
+// file为围栏存储文件，为空时不持久化
+//
+// geofence.Setup(jtt.Request, jtt.ErrClientOffline, "geofences.json")
+func Setup(request terminal.Requester, offline error, file string) error {
+	m := NewManager(request, offline, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	GeofenceApp = m
+	return nil
+}
+
+// 每条设置消息的最大区域数，避免消息过长
+const maxAreasPerMsg = 32
+
+// 每条删除消息的最大区域数，区域数为BYTE
+const maxIDsPerMsg = 125
+
+// 区域或路线类型
+const (
+	KindCircle  = "circle"  // 圆形区域（0x8600）
+	KindRect    = "rect"    // 矩形区域（0x8602）
+	KindPolygon = "polygon" // 多边形区域（0x8604）
+	KindRoute   = "route"   // 路线（0x8606）
+)
+
+// kinds 全部类型，按查询类型排列
+var kinds = []string{KindCircle, KindRect, KindPolygon, KindRoute}
+
+// shapeTypes 类型对应的0x8608查询类型
+var shapeTypes = map[string]protocol.ShapeType{
+	KindCircle:  protocol.ShapeTypeCircle,
+	KindRect:    protocol.ShapeTypeRect,
+	KindPolygon: protocol.ShapeTypePolygon,
+	KindRoute:   protocol.ShapeTypeRoad,
+}
+
+// 下发操作，与区域设置属性一致
+const (
+	OpUpdate = "update" // 更新：终端同类区域替换为指定的区域
+	OpAppend = "append" // 追加
+	OpModify = "modify" // 修改：只能修改已分配的区域
+	OpDelete = "delete" // 删除
+)
+
+// 终端同步状态
+const (
+	StateSynced  = "synced"  // 终端区域与分配一致
+	StatePending = "pending" // 等待下发，终端鉴权后自动核对并下发
+	StateFailed  = "failed"  // 下发失败
+)
+
+// Key 区域或路线的唯一标识，终端上不同类型的区域id相互独立
+type Key struct {
+	// 类型，见KindXXX
+	Kind string `json:"kind"`
+	// 区域或路线id
+	ID uint32 `json:"id"`
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s/%d", k.Kind, k.ID)
+}
+
+// Fence 区域或路线，按类型只有对应的字段有值
+type Fence struct {
+	// 类型，见KindXXX
+	Kind string `json:"kind"`
+	// 圆形区域
+	Circle *protocol.RoundArea `json:"circle,omitempty"`
+	// 矩形区域
+	Rect *protocol.RectArea `json:"rect,omitempty"`
+	// 多边形区域
+	Polygon *protocol.PolygonArea `json:"polygon,omitempty"`
+	// 路线
+	Route *protocol.Polyline `json:"route,omitempty"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Key 区域或路线的唯一标识
+func (f *Fence) Key() Key {
+	key := Key{Kind: f.Kind}
+	switch f.Kind {
+	case KindCircle:
+		key.ID = f.Circle.ID
+	case KindRect:
+		key.ID = f.Rect.ID
+	case KindPolygon:
+		key.ID = f.Polygon.ID
+	case KindRoute:
+		key.ID = f.Route.ID
+	}
+	return key
+}
+
+// validate 校验类型与数据是否一致
+func (f *Fence) validate() error {
+	var id uint32
+	var times []string
+	switch {
+	case KindCircle == f.Kind && nil != f.Circle && nil == f.Rect && nil == f.Polygon && nil == f.Route:
+		id, times = f.Circle.ID, []string{f.Circle.STime, f.Circle.ETime}
+		if !f.Circle.Attr.HasTime() {
+			times = nil
+		}
+		if 0 == f.Circle.Radius {
+			return fmt.Errorf("%w: radius is required", ErrInvalidFence)
+		}
+	case KindRect == f.Kind && nil != f.Rect && nil == f.Circle && nil == f.Polygon && nil == f.Route:
+		id, times = f.Rect.ID, []string{f.Rect.STime, f.Rect.ETime}
+		if !f.Rect.Attr.HasTime() {
+			times = nil
+		}
+	case KindPolygon == f.Kind && nil != f.Polygon && nil == f.Circle && nil == f.Rect && nil == f.Route:
+		id, times = f.Polygon.ID, []string{f.Polygon.STime, f.Polygon.ETime}
+		if !f.Polygon.Attr.HasTime() {
+			times = nil
+		}
+		if len(f.Polygon.Vertexs) < 6 || 0 != len(f.Polygon.Vertexs)%2 {
+			return fmt.Errorf("%w: a polygon needs at least 3 vertexes", ErrInvalidFence)
+		}
+	case KindRoute == f.Kind && nil != f.Route && nil == f.Circle && nil == f.Rect && nil == f.Polygon:
+		id, times = f.Route.ID, []string{f.Route.STime, f.Route.ETime}
+		if !f.Route.Attr.HasTime() {
+			times = nil
+		}
+		if len(f.Route.Vertexs) < 2 {
+			return fmt.Errorf("%w: a route needs at least 2 vertexes", ErrInvalidFence)
+		}
+	default:
+		return fmt.Errorf("%w: kind %q does not match the data", ErrInvalidFence, f.Kind)
+	}
+
+	if 0 == id {
+		return fmt.Errorf("%w: id is required", ErrInvalidFence)
+	}
+	// 起止时间为YYMMDDhhmmss，年、月、日可以为0
+	for _, t := range times {
+		if _, ok := windowTime(time.Now(), t); !ok {
+			return fmt.Errorf("%w: invalid time %q", ErrInvalidFence, t)
+		}
+	}
+	return nil
+}
+
+// Terminal 终端的区域分配及同步状态
+type Terminal struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 分配给终端的区域或路线
+	Fences []Key `json:"fences"`
+	// 同步状态，见StateXXX
+	State string `json:"state"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 最近一次同步成功的时间
+	Synced time.Time `json:"synced"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// has 是否已分配
+func (t *Terminal) has(key Key) bool {
+	for _, k := range t.Fences {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff 单个类型的终端区域核对结果
+type Diff struct {
+	// 类型，见KindXXX
+	Kind string `json:"kind"`
+	// 已分配但终端上不存在的区域id
+	Missing []uint32 `json:"missing,omitempty"`
+	// 终端上存在但未分配的区域id
+	Extra []uint32 `json:"extra,omitempty"`
+	// 终端上数据与分配不一致的区域id
+	Changed []uint32 `json:"changed,omitempty"`
+}
+
+func (d *Diff) empty() bool {
+	return 0 == len(d.Missing) && 0 == len(d.Extra) && 0 == len(d.Changed)
+}
+
+// Report 终端区域核对报告
+type Report struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 各类型的差异，一致的类型不列出
+	Diffs []Diff `json:"diffs"`
+	// 是否已下发修正
+	Applied bool `json:"applied"`
+	// 核对时间
+	Time time.Time `json:"time"`
+}
+
+// Manager 电子围栏服务。
+//
+// 保存区域与路线，记录分配给每台终端的区域，按更新、追加、修改、删除下发到终端；
+// 以0x8608查询终端已有的区域与分配核对，终端重新注册（恢复出厂设置或更换终端）后自动核对并重新下发；
+// 对不支持区域判断的终端，根据上报的位置在平台判断进出区域、区域超速、路线偏离及路段行驶时间。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	file    string
+
+	mtx       sync.Mutex
+	fences    map[Key]*Fence
+	terminals map[string]*Terminal
+	// 终端正在下发，避免并发下发顺序错乱
+	busy map[string]*sync.Mutex
+	// 区域空间索引，区域变化后重建
+	index *index
+	// 终端的区域判断状态
+	tracks map[string]*tracking
+	// 最近的区域事件
+	events []Event
+}
+
+// NewManager 新建电子围栏服务
+func NewManager(request terminal.Requester, offline error, file string) *Manager {
+	return &Manager{
+		request:   request,
+		offline:   offline,
+		file:      file,
+		fences:    make(map[Key]*Fence),
+		terminals: make(map[string]*Terminal),
+		busy:      make(map[string]*sync.Mutex),
+		tracks:    make(map[string]*tracking),
+	}
+}
+
+// SaveFence 新建或更新区域，已分配给终端的区域在新的goroutine中以修改方式下发
+func (m *Manager) SaveFence(fence Fence) (Fence, error) {
+	if err := fence.validate(); nil != err {
+		return Fence{}, err
+	}
+	fence.Updated = time.Now()
+	key := fence.Key()
+
+	m.mtx.Lock()
+	_, exists := m.fences[key]
+	m.fences[key] = &fence
+	m.index = nil
+	var phones []string
+	if exists {
+		for phone, t := range m.terminals {
+			if t.has(key) {
+				phones = append(phones, phone)
+			}
+		}
+	}
+	m.save()
+	m.mtx.Unlock()
+
+	for _, phone := range phones {
+		go m.Assign(phone, OpModify, []Key{key})
+	}
+	return fence, nil
+}
+
+// Fence 获取区域
+func (m *Manager) Fence(key Key) (Fence, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	fence, ok := m.fences[key]
+	if !ok {
+		return Fence{}, false
+	}
+	return *fence, true
+}
+
+// Fences 获取全部区域，按类型及id排列
+func (m *Manager) Fences() []Fence {
+	m.mtx.Lock()
+	fences := make([]Fence, 0, len(m.fences))
+	for _, fence := range m.fences {
+		fences = append(fences, *fence)
+	}
+	m.mtx.Unlock()
+
+	sortFences(fences)
+	return fences
+}
+
+// DeleteFence 删除区域，已分配给终端的区域不能删除
+func (m *Manager) DeleteFence(key Key) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.fences[key]; !ok {
+		return ErrFenceNotFound
+	}
+	for _, t := range m.terminals {
+		if t.has(key) {
+			return ErrFenceInUse
+		}
+	}
+	delete(m.fences, key)
+	m.index = nil
+	m.save()
+	return nil
+}
+
+// Terminal 获取终端的区域分配及同步状态
+func (m *Manager) Terminal(phone string) (Terminal, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t, ok := m.terminals[phone]
+	if !ok {
+		return Terminal{}, false
+	}
+	return copyTerminal(t), true
+}
+
+// Terminals 获取全部终端的区域分配，按手机号排列
+func (m *Manager) Terminals() []Terminal {
+	m.mtx.Lock()
+	terminals := make([]Terminal, 0, len(m.terminals))
+	for _, t := range m.terminals {
+		terminals = append(terminals, copyTerminal(t))
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(terminals, func(i, j int) bool {
+		return terminals[i].Phone < terminals[j].Phone
+	})
+	return terminals
+}
+
+// Assign 修改终端的区域分配并下发，op见OpXXX。更新操作只替换keys中出现的类型；
+// 终端不在线时记为等待下发，终端鉴权后核对并下发
+func (m *Manager) Assign(phone string, op string, keys []Key) (Terminal, error) {
+	lock := m.lock(phone)
+	lock.Lock()
+	defer lock.Unlock()
+
+	m.mtx.Lock()
+	t, ok := m.terminals[phone]
+	if !ok {
+		t = &Terminal{Phone: phone}
+	}
+	var fences []*Fence
+	for _, key := range keys {
+		fence, ok := m.fences[key]
+		if OpDelete != op && !ok {
+			m.mtx.Unlock()
+			return Terminal{}, fmt.Errorf("%w: %s", ErrFenceNotFound, key)
+		}
+		if OpModify == op && !t.has(key) {
+			m.mtx.Unlock()
+			return Terminal{}, fmt.Errorf("%w: %s is not assigned", ErrFenceNotFound, key)
+		}
+		if ok {
+			fences = append(fences, fence)
+		}
+	}
+
+	var outputs []protocol.Output
+	switch op {
+	case OpUpdate:
+		replaced := make(map[string]bool)
+		for _, key := range keys {
+			replaced[key.Kind] = true
+		}
+		var assigned []Key
+		for _, key := range t.Fences {
+			if !replaced[key.Kind] {
+				assigned = append(assigned, key)
+			}
+		}
+		t.Fences = append(assigned, keys...)
+		outputs = setMessages(protocol.AreaOpUpdate, fences)
+	case OpAppend:
+		for _, key := range keys {
+			if !t.has(key) {
+				t.Fences = append(t.Fences, key)
+			}
+		}
+		outputs = setMessages(protocol.AreaOpAppend, fences)
+	case OpModify:
+		outputs = setMessages(protocol.AreaOpModify, fences)
+	case OpDelete:
+		var assigned []Key
+		for _, key := range t.Fences {
+			if !containsKey(keys, key) {
+				assigned = append(assigned, key)
+			}
+		}
+		t.Fences = assigned
+		outputs = deleteMessages(keys)
+	default:
+		m.mtx.Unlock()
+		return Terminal{}, ErrUnknownOperation
+	}
+	sortKeys(t.Fences)
+	m.terminals[phone] = t
+	m.save()
+	m.mtx.Unlock()
+
+	return m.apply(phone, outputs), nil
+}
+
+// Reconcile 以0x8608查询终端上的全部区域与分配核对，apply为true时下发修正：
+// 补发缺失及不一致的区域，删除未分配的区域
+func (m *Manager) Reconcile(phone string, apply bool) (Report, error) {
+	lock := m.lock(phone)
+	lock.Lock()
+	defer lock.Unlock()
+
+	report := Report{Phone: phone, Time: time.Now()}
+	var outputs []protocol.Output
+	for _, kind := range kinds {
+		actual, err := m.query(phone, kind)
+		if nil != err {
+			if apply && nil != m.offline && errors.Is(err, m.offline) {
+				m.setState(phone, StatePending, nil)
+			}
+			return report, err
+		}
+
+		m.mtx.Lock()
+		diff, fixes := m.diff(phone, kind, actual)
+		m.mtx.Unlock()
+		if !diff.empty() {
+			report.Diffs = append(report.Diffs, diff)
+		}
+
+		outputs = append(outputs, setMessages(protocol.AreaOpAppend, fixes)...)
+		var extra []Key
+		for _, id := range diff.Extra {
+			extra = append(extra, Key{Kind: kind, ID: id})
+		}
+		outputs = append(outputs, deleteMessages(extra)...)
+	}
+
+	if apply {
+		m.mtx.Lock()
+		if _, ok := m.terminals[phone]; !ok {
+			m.terminals[phone] = &Terminal{Phone: phone}
+		}
+		m.mtx.Unlock()
+
+		t := m.apply(phone, outputs)
+		report.Applied = StateSynced == t.State
+	}
+	return report, nil
+}
+
+// OnRegister 终端注册，终端恢复出厂设置或更换后重新注册，区域需要重新核对下发
+func (m *Manager) OnRegister(phone string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if t, ok := m.terminals[phone]; ok && 0 != len(t.Fences) {
+		t.State, t.Error, t.Updated = StatePending, "", time.Now()
+		m.save()
+	}
+}
+
+// OnAuth 终端鉴权成功，等待下发的终端在新的goroutine中核对并下发
+func (m *Manager) OnAuth(phone string) {
+	m.mtx.Lock()
+	t, ok := m.terminals[phone]
+	pending := ok && StatePending == t.State
+	m.mtx.Unlock()
+
+	if pending {
+		go func() {
+			if _, err := m.Reconcile(phone, true); nil != err {
+				log.Printf("终端[%s]区域核对失败：%v", phone, err)
+			}
+		}()
+	}
+}
+
+// diff 核对单个类型的区域，返回差异及需要补发的区域，调用方持有锁
+func (m *Manager) diff(phone string, kind string, actual map[uint32]protocol.Area) (Diff, []*Fence) {
+	diff := Diff{Kind: kind}
+	var fixes []*Fence
+
+	var assigned []Key
+	if t, ok := m.terminals[phone]; ok {
+		assigned = t.Fences
+	}
+	for _, key := range assigned {
+		if kind != key.Kind {
+			continue
+		}
+		fence, ok := m.fences[key]
+		if !ok {
+			continue
+		}
+		area, ok := actual[key.ID]
+		switch {
+		case !ok:
+			diff.Missing = append(diff.Missing, key.ID)
+		case !sameArea(fence, area):
+			diff.Changed = append(diff.Changed, key.ID)
+		default:
+			continue
+		}
+		fixes = append(fixes, fence)
+	}
+	for id := range actual {
+		if !containsKey(assigned, Key{Kind: kind, ID: id}) {
+			diff.Extra = append(diff.Extra, id)
+		}
+	}
+	sort.Slice(diff.Extra, func(i, j int) bool {
+		return diff.Extra[i] < diff.Extra[j]
+	})
+	return diff, fixes
+}
+
+// apply 依次下发消息并记录同步状态
+func (m *Manager) apply(phone string, outputs []protocol.Output) Terminal {
+	var err error
+	for _, output := range outputs {
+		if err = m.request.Send(phone, output); nil != err {
+			break
+		}
+	}
+
+	switch {
+	case nil == err:
+		m.setState(phone, StateSynced, nil)
+	case nil != m.offline && errors.Is(err, m.offline):
+		m.setState(phone, StatePending, nil)
+	default:
+		m.setState(phone, StateFailed, err)
+	}
+
+	t, _ := m.Terminal(phone)
+	return t
+}
+
+// setState 记录终端同步状态
+func (m *Manager) setState(phone string, state string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t, ok := m.terminals[phone]
+	if !ok {
+		return
+	}
+	t.State, t.Error, t.Updated = state, "", time.Now()
+	if nil != err {
+		t.Error = err.Error()
+	}
+	if StateSynced == state {
+		t.Synced = t.Updated
+	}
+	m.save()
+}
+
+// query 查询终端上指定类型的全部区域
+func (m *Manager) query(phone string, kind string) (map[uint32]protocol.Area, error) {
+	msg := protocol.NewMsgGetAreaOrPath()
+	msg.Type = shapeTypes[kind]
+	input, err := m.request(phone, msg, terminal.RequestTimeout, protocol.MsgIDGetAreaResp)
+	if nil != err {
+		return nil, err
+	}
+	resp, ok := input.(*protocol.MsgGetAreaOrPathResp)
+	if !ok {
+		return nil, terminal.ErrUnexpectedData
+	}
+
+	areas := make(map[uint32]protocol.Area, len(resp.Areas))
+	for _, area := range resp.Areas {
+		switch a := area.(type) {
+		case *protocol.RoundArea:
+			areas[a.ID] = a
+		case *protocol.RectArea:
+			areas[a.ID] = a
+		case *protocol.PolygonArea:
+			areas[a.ID] = a
+		case *protocol.Polyline:
+			areas[a.ID] = a
+		}
+	}
+	return areas, nil
+}
+
+// lock 获取终端的下发锁
+func (m *Manager) lock(phone string) *sync.Mutex {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	lock, ok := m.busy[phone]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.busy[phone] = lock
+	}
+	return lock
+}
+
+// store 持久化的围栏数据
+type store struct {
+	Fences    []Fence    `json:"fences"`
+	Terminals []Terminal `json:"terminals"`
+}
+
+// save 保存围栏数据，先写入临时文件再替换，调用方持有锁
+func (m *Manager) save() {
+	if "" == m.file {
+		return
+	}
+
+	var s store
+	for _, fence := range m.fences {
+		s.Fences = append(s.Fences, *fence)
+	}
+	for _, t := range m.terminals {
+		s.Terminals = append(s.Terminals, *t)
+	}
+	sortFences(s.Fences)
+	sort.Slice(s.Terminals, func(i, j int) bool {
+		return s.Terminals[i].Phone < s.Terminals[j].Phone
+	})
+
+	if err := util.SaveJSON(m.file, &s); nil != err {
+		log.Printf("保存电子围栏失败：%v", err)
+	}
+}
+
+// load 加载已保存的围栏数据，文件不存在时忽略
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var s store
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for idx := range s.Fences {
+		fence := s.Fences[idx]
+		if nil == fence.validate() {
+			m.fences[fence.Key()] = &fence
+		}
+	}
+	for idx := range s.Terminals {
+		t := s.Terminals[idx]
+		m.terminals[t.Phone] = &t
+	}
+	return nil
+}
+
+// copyTerminal 复制终端分配，避免共享切片
+func copyTerminal(t *Terminal) Terminal {
+	result := *t
+	result.Fences = append([]Key(nil), t.Fences...)
+	return result
+}
+
+func containsKey(keys []Key, key Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// kindOrder 类型的排列顺序
+func kindOrder(kind string) int {
+	for idx, k := range kinds {
+		if k == kind {
+			return idx
+		}
+	}
+	return len(kinds)
+}
+
+func lessKey(a, b Key) bool {
+	if a.Kind != b.Kind {
+		return kindOrder(a.Kind) < kindOrder(b.Kind)
+	}
+	return a.ID < b.ID
+}
+
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		return lessKey(keys[i], keys[j])
+	})
+}
+
+func sortFences(fences []Fence) {
+	sort.Slice(fences, func(i, j int) bool {
+		return lessKey(fences[i].Key(), fences[j].Key())
+	})
+}
@@ -0,0 +1,277 @@
+package geofence
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// fakeTerminal 模拟终端保存的区域，按设置及删除消息修改
+type fakeTerminal struct {
+	*terminaltest.Terminals
+	areas map[Key]protocol.Area
+}
+
+func newFakeTerminal() *fakeTerminal {
+	f := &fakeTerminal{areas: make(map[Key]protocol.Area)}
+	f.Terminals = terminaltest.New(f.handle)
+	return f
+}
+
+func (f *fakeTerminal) handle(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+	switch msg := output.(type) {
+	case *protocol.MsgRoundAreaSettings:
+		if protocol.AreaOpUpdate == msg.Operation {
+			f.clear(KindCircle)
+		}
+		for idx := range msg.Areas {
+			area := msg.Areas[idx]
+			f.areas[Key{KindCircle, area.ID}] = &area
+		}
+	case *protocol.MsgRectAreaSettings:
+		if protocol.AreaOpUpdate == msg.Operation {
+			f.clear(KindRect)
+		}
+		for idx := range msg.Areas {
+			area := msg.Areas[idx]
+			f.areas[Key{KindRect, area.ID}] = &area
+		}
+	case *protocol.MsgPolygonAreaSettings:
+		area := msg.Area
+		f.areas[Key{KindPolygon, area.ID}] = &area
+	case *protocol.MsgPathSettings:
+		path := msg.Path
+		f.areas[Key{KindRoute, path.ID}] = &path
+	case *protocol.MsgRoundAreaDelete:
+		f.remove(KindCircle, msg.IDs)
+	case *protocol.MsgRectAreaDelete:
+		f.remove(KindRect, msg.IDs)
+	case *protocol.MsgPolygonAreaDelete:
+		f.remove(KindPolygon, msg.IDs)
+	case *protocol.MsgPathDelete:
+		f.remove(KindRoute, msg.IDs)
+	case *protocol.MsgGetAreaOrPath:
+		resp := &protocol.MsgGetAreaOrPathResp{}
+		resp.Type = msg.Type
+		for key, area := range f.areas {
+			if shapeTypes[key.Kind] == msg.Type {
+				resp.Areas = append(resp.Areas, area)
+			}
+		}
+		return resp, nil
+	default:
+		return nil, terminaltest.ErrUnexpectedMessage
+	}
+	return &protocol.MsgTerminalResponse{}, nil
+}
+
+func (f *fakeTerminal) clear(kind string) {
+	for key := range f.areas {
+		if kind == key.Kind {
+			delete(f.areas, key)
+		}
+	}
+}
+
+func (f *fakeTerminal) remove(kind string, ids []uint32) {
+	if 0 == len(ids) {
+		f.clear(kind)
+	}
+	for _, id := range ids {
+		delete(f.areas, Key{kind, id})
+	}
+}
+
+// keys 终端上的区域，已排序
+func (f *fakeTerminal) keys() []Key {
+	var keys []Key
+	f.Do(func() {
+		for key := range f.areas {
+			keys = append(keys, key)
+		}
+	})
+	sortKeys(keys)
+	return keys
+}
+
+func circle(id uint32) Fence {
+	return Fence{Kind: KindCircle, Circle: &protocol.RoundArea{ID: id, CenterY: 22500000, CenterX: 113900000, Radius: 500}}
+}
+
+func polygon(id uint32) Fence {
+	return Fence{Kind: KindPolygon, Polygon: &protocol.PolygonArea{ID: id, Vertexs: []uint32{22500000, 113900000, 22600000, 113900000, 22600000, 114000000}}}
+}
+
+func route(id uint32) Fence {
+	vertexes := []protocol.Vertex{
+		{ID: 1, Latitude: 22500000, Longitude: 113900000, Tag: protocol.Segment{ID: 1, Width: 50}},
+		{ID: 2, Latitude: 22600000, Longitude: 114000000, Tag: protocol.Segment{ID: 2, Width: 50}},
+	}
+	return Fence{Kind: KindRoute, Route: &protocol.Polyline{ID: id, Vertexs: vertexes}}
+}
+
+func TestAssign(t *testing.T) {
+	terminal := newFakeTerminal()
+	file := filepath.Join(t.TempDir(), "geofences.json")
+	m := NewManager(terminal.Request, terminaltest.ErrOffline, file)
+
+	var keys []Key
+	for id := uint32(1); id <= maxAreasPerMsg+1; id++ {
+		fence, err := m.SaveFence(circle(id))
+		if nil != err {
+			t.Fatal(err)
+		}
+		keys = append(keys, fence.Key())
+	}
+	m.SaveFence(polygon(1))
+	m.SaveFence(route(1))
+	keys = append(keys, Key{KindPolygon, 1}, Key{KindRoute, 1})
+	if _, err := m.SaveFence(Fence{Kind: KindRect, Circle: &protocol.RoundArea{ID: 1}}); !errors.Is(err, ErrInvalidFence) {
+		t.Fatalf("got %v, want ErrInvalidFence", err)
+	}
+
+	// 圆形区域分两批，第二批为追加；多边形与路线先删除全部再设置
+	info, err := m.Assign("1", OpUpdate, keys)
+	if nil != err || StateSynced != info.State || len(keys) != len(info.Fences) {
+		t.Fatalf("unexpected terminal: %+v, %v", info, err)
+	}
+	sent := terminal.Received("1")
+	if 6 != len(sent) {
+		t.Fatalf("got %d messages, want 6", len(sent))
+	}
+	if second := sent[1].(*protocol.MsgRoundAreaSettings); protocol.AreaOpAppend != second.Operation || 1 != len(second.Areas) {
+		t.Fatalf("unexpected second chunk: %+v", second)
+	}
+	if got := terminal.keys(); len(keys) != len(got) {
+		t.Fatalf("terminal has %v", got)
+	}
+	if err := m.DeleteFence(Key{KindRoute, 1}); ErrFenceInUse != err {
+		t.Fatalf("got %v, want ErrFenceInUse", err)
+	}
+
+	// 删除分配
+	info, err = m.Assign("1", OpDelete, []Key{{KindRoute, 1}, {KindCircle, 2}})
+	if nil != err || len(keys)-2 != len(info.Fences) || len(keys)-2 != len(terminal.keys()) {
+		t.Fatalf("unexpected terminal: %+v, %v", info, err)
+	}
+	if _, err := m.Assign("1", OpModify, []Key{{KindRoute, 1}}); !errors.Is(err, ErrFenceNotFound) {
+		t.Fatalf("got %v, want ErrFenceNotFound", err)
+	}
+
+	// 终端不在线时等待下发
+	terminal.SetOffline("1", true)
+	info, err = m.Assign("1", OpAppend, []Key{{KindRoute, 1}})
+	if nil != err || StatePending != info.State {
+		t.Fatalf("unexpected terminal: %+v, %v", info, err)
+	}
+
+	loaded := NewManager(terminal.Request, terminaltest.ErrOffline, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if info, ok := loaded.Terminal("1"); !ok || StatePending != info.State || len(keys)-1 != len(info.Fences) {
+		t.Fatalf("unexpected loaded terminal: %+v", info)
+	}
+	if len(m.Fences()) != len(loaded.Fences()) {
+		t.Fatalf("got %d fences, want %d", len(loaded.Fences()), len(m.Fences()))
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	terminal := newFakeTerminal()
+	m := NewManager(terminal.Request, terminaltest.ErrOffline, "")
+	for _, fence := range []Fence{circle(1), circle(2), polygon(1), route(1)} {
+		if _, err := m.SaveFence(fence); nil != err {
+			t.Fatal(err)
+		}
+	}
+	keys := []Key{{KindCircle, 1}, {KindCircle, 2}, {KindPolygon, 1}, {KindRoute, 1}}
+	if _, err := m.Assign("1", OpUpdate, keys); nil != err {
+		t.Fatal(err)
+	}
+
+	// 终端恢复出厂设置后只剩一个被修改的区域及一个未分配的区域
+	changed := *circle(1).Circle
+	changed.Radius = 1000
+	terminal.Do(func() {
+		terminal.areas = map[Key]protocol.Area{
+			{KindCircle, 1}: &changed,
+			{KindCircle, 9}: circle(9).Circle,
+		}
+	})
+
+	report, err := m.Reconcile("1", false)
+	if nil != err || report.Applied {
+		t.Fatalf("unexpected report: %+v, %v", report, err)
+	}
+	want := []Diff{
+		{Kind: KindCircle, Missing: []uint32{2}, Extra: []uint32{9}, Changed: []uint32{1}},
+		{Kind: KindPolygon, Missing: []uint32{1}},
+		{Kind: KindRoute, Missing: []uint32{1}},
+	}
+	if len(want) != len(report.Diffs) {
+		t.Fatalf("got diffs %+v, want %+v", report.Diffs, want)
+	}
+	for idx := range want {
+		got := report.Diffs[idx]
+		sort.Slice(got.Changed, func(i, j int) bool { return got.Changed[i] < got.Changed[j] })
+		if want[idx].Kind != got.Kind || !equalIDs(want[idx].Missing, got.Missing) ||
+			!equalIDs(want[idx].Extra, got.Extra) || !equalIDs(want[idx].Changed, got.Changed) {
+			t.Fatalf("got diff %+v, want %+v", got, want[idx])
+		}
+	}
+
+	// 重新注册后鉴权自动核对并下发
+	m.OnRegister("1")
+	if info, _ := m.Terminal("1"); StatePending != info.State {
+		t.Fatalf("got state %q, want pending", info.State)
+	}
+	m.OnAuth("1")
+	terminaltest.Wait(t, "the terminal was not reconciled", func() bool {
+		info, _ := m.Terminal("1")
+		return StateSynced == info.State
+	})
+	got := terminal.keys()
+	if len(keys) != len(got) {
+		t.Fatalf("terminal has %v, want %v", got, keys)
+	}
+	for idx := range keys {
+		if keys[idx] != got[idx] {
+			t.Fatalf("terminal has %v, want %v", got, keys)
+		}
+	}
+	if report, err := m.Reconcile("1", false); nil != err || 0 != len(report.Diffs) {
+		t.Fatalf("unexpected report: %+v, %v", report, err)
+	}
+}
+
+func TestSameArea2011(t *testing.T) {
+	// 2011版终端应答中没有夜间最高速度和名称
+	fence := circle(1)
+	fence.Circle.Attr.SetSpeedEnable(true)
+	fence.Circle.MaxSpeed, fence.Circle.MaxSpeedInNight, fence.Circle.Name = 80, 60, "depot"
+	got := *fence.Circle
+	got.MaxSpeedInNight, got.Name = noNightSpeed, ""
+	if !sameArea(&fence, &got) {
+		t.Fatal("2011 area should match")
+	}
+	got.MaxSpeed = 70
+	if sameArea(&fence, &got) {
+		t.Fatal("changed speed should not match")
+	}
+}
+
+func equalIDs(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,395 @@
+package geofence
+
+import (
+	"common/protocol"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// FeatureCollection GeoJSON要素集合，坐标为WGS84经纬度（度）
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature GeoJSON要素。圆形区域为Point并以radius属性表示半径，
+// 矩形区域为Polygon并以kind属性区分，路线为LineString
+type Feature struct {
+	Type       string     `json:"type"`
+	Geometry   Geometry   `json:"geometry"`
+	Properties Properties `json:"properties"`
+}
+
+// Geometry GeoJSON几何对象
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Properties 要素属性，对应区域或路线中坐标以外的字段
+type Properties struct {
+	// 类型，见KindXXX，为空时按几何类型推断
+	Kind string `json:"kind,omitempty"`
+	// 区域或路线id，为0时导入自动分配
+	ID uint32 `json:"id,omitempty"`
+	// 名称
+	Name string `json:"name,omitempty"`
+	// 区域或路线属性，南纬、西经标志按坐标设置
+	Attr json.RawMessage `json:"attr,omitempty"`
+	// 起始时间，YYMMDDhhmmss
+	StartTime string `json:"start_time,omitempty"`
+	// 结束时间，YYMMDDhhmmss
+	EndTime string `json:"end_time,omitempty"`
+	// 最高速度，单位km/h
+	MaxSpeed uint16 `json:"max_speed,omitempty"`
+	// 超速持续时间，单位s
+	Duration byte `json:"duration,omitempty"`
+	// 夜间最高速度，单位km/h
+	MaxSpeedInNight uint16 `json:"max_speed_in_night,omitempty"`
+	// 圆形区域半径，单位m
+	Radius uint32 `json:"radius,omitempty"`
+	// 路线的拐点及路段，与坐标一一对应
+	Segments []SegmentProps `json:"segments,omitempty"`
+}
+
+// SegmentProps 路线拐点及其路段属性
+type SegmentProps struct {
+	// 拐点id
+	VertexID uint32 `json:"vertex_id"`
+	protocol.Segment
+}
+
+// geoJSON几何类型
+const (
+	geometryPoint      = "Point"
+	geometryPolygon    = "Polygon"
+	geometryLineString = "LineString"
+)
+
+// ExportGeoJSON 导出全部区域与路线
+func (m *Manager) ExportGeoJSON() (*FeatureCollection, error) {
+	collection := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, fence := range m.Fences() {
+		feature, err := toFeature(&fence)
+		if nil != err {
+			return nil, err
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return collection, nil
+}
+
+// ImportGeoJSON 导入区域与路线，id相同的区域将被更新，没有id时分配同类型未使用的id
+func (m *Manager) ImportGeoJSON(collection *FeatureCollection) ([]Fence, error) {
+	var fences []Fence
+	for idx := range collection.Features {
+		fence, err := fromFeature(&collection.Features[idx])
+		if nil != err {
+			return nil, fmt.Errorf("feature %d: %w", idx, err)
+		}
+		fences = append(fences, fence)
+	}
+
+	m.mtx.Lock()
+	used := make(map[Key]bool, len(m.fences))
+	for key := range m.fences {
+		used[key] = true
+	}
+	m.mtx.Unlock()
+	for idx := range fences {
+		if key := fences[idx].Key(); 0 != key.ID {
+			used[key] = true
+		}
+	}
+	for idx := range fences {
+		key := fences[idx].Key()
+		if 0 != key.ID {
+			continue
+		}
+		for key.ID = 1; used[key]; key.ID++ {
+		}
+		used[key] = true
+		setID(&fences[idx], key.ID)
+	}
+
+	for idx := range fences {
+		if err := fences[idx].validate(); nil != err {
+			return nil, fmt.Errorf("%s: %w", fences[idx].Key(), err)
+		}
+	}
+	saved := make([]Fence, 0, len(fences))
+	for _, fence := range fences {
+		fence, err := m.SaveFence(fence)
+		if nil != err {
+			return saved, err
+		}
+		saved = append(saved, fence)
+	}
+	return saved, nil
+}
+
+// toFeature 区域转换为GeoJSON要素
+func toFeature(fence *Fence) (Feature, error) {
+	var coordinates interface{}
+	var attr interface{}
+	var props Properties
+	feature := Feature{Type: "Feature"}
+
+	switch fence.Kind {
+	case KindCircle:
+		c := fence.Circle
+		feature.Geometry.Type = geometryPoint
+		coordinates = point(c.CenterX, c.CenterY, c.Attr.IsWest(), c.Attr.IsSouth())
+		props = areaProps(c.ID, c.Name, c.STime, c.ETime, c.MaxSpeed, c.Duration, c.MaxSpeedInNight)
+		props.Radius, attr = c.Radius, c.Attr
+	case KindRect:
+		r := fence.Rect
+		feature.Geometry.Type = geometryPolygon
+		west, south := r.Attr.IsWest(), r.Attr.IsSouth()
+		nw, se := point(r.NWX, r.NWY, west, south), point(r.SEX, r.SEY, west, south)
+		coordinates = [][][2]float64{{nw, {se[0], nw[1]}, se, {nw[0], se[1]}, nw}}
+		props = areaProps(r.ID, r.Name, r.STime, r.ETime, r.MaxSpeed, r.Duration, r.MaxSpeedInNight)
+		attr = r.Attr
+	case KindPolygon:
+		p := fence.Polygon
+		feature.Geometry.Type = geometryPolygon
+		west, south := p.Attr.IsWest(), p.Attr.IsSouth()
+		var ring [][2]float64
+		for idx := 0; idx+1 < len(p.Vertexs); idx += 2 {
+			ring = append(ring, point(p.Vertexs[idx+1], p.Vertexs[idx], west, south))
+		}
+		ring = append(ring, ring[0])
+		coordinates = [][][2]float64{ring}
+		props = areaProps(p.ID, p.Name, p.STime, p.ETime, p.MaxSpeed, p.Duration, p.MaxSpeedInNight)
+		attr = p.Attr
+	case KindRoute:
+		r := fence.Route
+		feature.Geometry.Type = geometryLineString
+		var line [][2]float64
+		for _, v := range r.Vertexs {
+			line = append(line, point(v.Longitude, v.Latitude, v.Tag.Attr.IsWest(), v.Tag.Attr.IsSouth()))
+			props.Segments = append(props.Segments, SegmentProps{VertexID: v.ID, Segment: v.Tag})
+		}
+		coordinates = line
+		props.ID, props.Name, attr = r.ID, r.Name, r.Attr
+		if r.Attr.HasTime() {
+			props.StartTime, props.EndTime = r.STime, r.ETime
+		}
+	default:
+		return feature, fmt.Errorf("%w: unknown kind %q", ErrInvalidFence, fence.Kind)
+	}
+	props.Kind = fence.Kind
+
+	var err error
+	if feature.Geometry.Coordinates, err = json.Marshal(coordinates); nil != err {
+		return feature, err
+	}
+	if props.Attr, err = json.Marshal(attr); nil != err {
+		return feature, err
+	}
+	feature.Properties = props
+	return feature, nil
+}
+
+// fromFeature GeoJSON要素转换为区域，按坐标设置南纬、西经标志
+func fromFeature(feature *Feature) (Fence, error) {
+	props := &feature.Properties
+	kind := props.Kind
+	if "" == kind {
+		switch feature.Geometry.Type {
+		case geometryPoint:
+			kind = KindCircle
+		case geometryPolygon:
+			kind = KindPolygon
+		case geometryLineString:
+			kind = KindRoute
+		}
+	}
+
+	fence := Fence{Kind: kind}
+	switch kind {
+	case KindCircle:
+		var coord [2]float64
+		if err := decodeCoordinates(feature, geometryPoint, &coord); nil != err {
+			return fence, err
+		}
+		c := &protocol.RoundArea{ID: props.ID, Name: props.Name, Radius: props.Radius}
+		if err := decodeAttr(props.Attr, &c.Attr); nil != err {
+			return fence, err
+		}
+		c.STime, c.ETime, c.MaxSpeed, c.Duration, c.MaxSpeedInNight = props.StartTime, props.EndTime, props.MaxSpeed, props.Duration, props.MaxSpeedInNight
+		x, west := fromDegrees(coord[0])
+		y, south := fromDegrees(coord[1])
+		c.CenterX, c.CenterY = x, y
+		c.Attr.SetWest(west)
+		c.Attr.SetSouth(south)
+		fence.Circle = c
+	case KindRect:
+		ring, err := decodeRing(feature)
+		if nil != err {
+			return fence, err
+		}
+		minLon, minLat, maxLon, maxLat := ring[0][0], ring[0][1], ring[0][0], ring[0][1]
+		for _, coord := range ring {
+			minLon, maxLon = math.Min(minLon, coord[0]), math.Max(maxLon, coord[0])
+			minLat, maxLat = math.Min(minLat, coord[1]), math.Max(maxLat, coord[1])
+		}
+		west, south, err := hemisphere([][2]float64{{minLon, minLat}, {maxLon, maxLat}})
+		if nil != err {
+			return fence, err
+		}
+		r := &protocol.RectArea{ID: props.ID, Name: props.Name}
+		if err := decodeAttr(props.Attr, &r.Attr); nil != err {
+			return fence, err
+		}
+		r.STime, r.ETime, r.MaxSpeed, r.Duration, r.MaxSpeedInNight = props.StartTime, props.EndTime, props.MaxSpeed, props.Duration, props.MaxSpeedInNight
+		r.NWX, _ = fromDegrees(minLon)
+		r.NWY, _ = fromDegrees(maxLat)
+		r.SEX, _ = fromDegrees(maxLon)
+		r.SEY, _ = fromDegrees(minLat)
+		r.Attr.SetWest(west)
+		r.Attr.SetSouth(south)
+		fence.Rect = r
+	case KindPolygon:
+		ring, err := decodeRing(feature)
+		if nil != err {
+			return fence, err
+		}
+		// 去掉闭合的终点
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		west, south, err := hemisphere(ring)
+		if nil != err {
+			return fence, err
+		}
+		p := &protocol.PolygonArea{ID: props.ID, Name: props.Name}
+		if err := decodeAttr(props.Attr, &p.Attr); nil != err {
+			return fence, err
+		}
+		p.STime, p.ETime, p.MaxSpeed, p.Duration, p.MaxSpeedInNight = props.StartTime, props.EndTime, props.MaxSpeed, props.Duration, props.MaxSpeedInNight
+		for _, coord := range ring {
+			x, _ := fromDegrees(coord[0])
+			y, _ := fromDegrees(coord[1])
+			p.Vertexs = append(p.Vertexs, y, x)
+		}
+		p.Attr.SetWest(west)
+		p.Attr.SetSouth(south)
+		fence.Polygon = p
+	case KindRoute:
+		var line [][2]float64
+		if err := decodeCoordinates(feature, geometryLineString, &line); nil != err {
+			return fence, err
+		}
+		if 0 != len(props.Segments) && len(props.Segments) != len(line) {
+			return fence, fmt.Errorf("%w: got %d segments for %d vertexes", ErrInvalidFence, len(props.Segments), len(line))
+		}
+		r := &protocol.Polyline{ID: props.ID, Name: props.Name, STime: props.StartTime, ETime: props.EndTime}
+		if err := decodeAttr(props.Attr, &r.Attr); nil != err {
+			return fence, err
+		}
+		for idx, coord := range line {
+			v := protocol.Vertex{ID: uint32(idx + 1), Tag: protocol.Segment{ID: uint32(idx + 1)}}
+			if 0 != len(props.Segments) {
+				v.ID, v.Tag = props.Segments[idx].VertexID, props.Segments[idx].Segment
+			}
+			x, west := fromDegrees(coord[0])
+			y, south := fromDegrees(coord[1])
+			v.Longitude, v.Latitude = x, y
+			v.Tag.Attr.SetWest(west)
+			v.Tag.Attr.SetSouth(south)
+			r.Vertexs = append(r.Vertexs, v)
+		}
+		fence.Route = r
+	default:
+		return fence, fmt.Errorf("%w: unsupported geometry %q with kind %q", ErrInvalidFence, feature.Geometry.Type, kind)
+	}
+	return fence, nil
+}
+
+// setID 设置区域id
+func setID(fence *Fence, id uint32) {
+	switch fence.Kind {
+	case KindCircle:
+		fence.Circle.ID = id
+	case KindRect:
+		fence.Rect.ID = id
+	case KindPolygon:
+		fence.Polygon.ID = id
+	case KindRoute:
+		fence.Route.ID = id
+	}
+}
+
+// areaProps 区域的公共属性，未启用的时间不导出
+func areaProps(id uint32, name, stime, etime string, speed uint16, duration byte, night uint16) Properties {
+	return Properties{
+		ID: id, Name: name, StartTime: stime, EndTime: etime,
+		MaxSpeed: speed, Duration: duration, MaxSpeedInNight: night,
+	}
+}
+
+// decodeAttr 解析属性，属性可以是数值或标志对象
+func decodeAttr(data json.RawMessage, attr interface{}) error {
+	if 0 == len(data) {
+		return nil
+	}
+	if err := json.Unmarshal(data, attr); nil != err {
+		return fmt.Errorf("%w: invalid attr: %v", ErrInvalidFence, err)
+	}
+	return nil
+}
+
+// decodeCoordinates 校验几何类型并解析坐标
+func decodeCoordinates(feature *Feature, geometry string, coordinates interface{}) error {
+	if geometry != feature.Geometry.Type {
+		return fmt.Errorf("%w: kind %q requires a %s geometry", ErrInvalidFence, feature.Properties.Kind, geometry)
+	}
+	if err := json.Unmarshal(feature.Geometry.Coordinates, coordinates); nil != err {
+		return fmt.Errorf("%w: invalid coordinates: %v", ErrInvalidFence, err)
+	}
+	return nil
+}
+
+// decodeRing 解析多边形的外环，不支持内环
+func decodeRing(feature *Feature) ([][2]float64, error) {
+	var rings [][][2]float64
+	if err := decodeCoordinates(feature, geometryPolygon, &rings); nil != err {
+		return nil, err
+	}
+	if 1 != len(rings) || 0 == len(rings[0]) {
+		return nil, fmt.Errorf("%w: a polygon must have exactly one ring", ErrInvalidFence)
+	}
+	return rings[0], nil
+}
+
+// hemisphere 区域属性只有一个南纬、西经标志，坐标必须在同一半球
+func hemisphere(coords [][2]float64) (west, south bool, err error) {
+	for idx, coord := range coords {
+		w, s := coord[0] < 0, coord[1] < 0
+		if 0 != idx && (w != west || s != south) {
+			return false, false, fmt.Errorf("%w: coordinates cross the equator or the prime meridian", ErrInvalidFence)
+		}
+		west, south = w, s
+	}
+	return west, south, nil
+}
+
+// point 协议坐标（度×10^6）转换为GeoJSON坐标[经度, 纬度]
+func point(x, y uint32, west, south bool) [2]float64 {
+	return [2]float64{toDegrees(x, west), toDegrees(y, south)}
+}
+
+func toDegrees(value uint32, negative bool) float64 {
+	degrees := float64(value) / 1e6
+	if negative {
+		return -degrees
+	}
+	return degrees
+}
+
+// fromDegrees 度转换为协议坐标及是否为负（南纬、西经）
+func fromDegrees(degrees float64) (uint32, bool) {
+	return uint32(math.Round(math.Abs(degrees) * 1e6)), degrees < 0
+}
@@ -0,0 +1,79 @@
+package geofence
+
+import (
+	"common/protocol"
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoJSON(t *testing.T) {
+	data := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","geometry":{"type":"Point","coordinates":[-70.5,-33.25]},"properties":{"radius":300,"name":"depot"}},
+		{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[113.9,22.5],[114,22.5],[114,22.6],[113.9,22.6],[113.9,22.5]]]},"properties":{"kind":"rect","id":7,"attr":{"has_speed":true},"max_speed":60}},
+		{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[113.9,22.5],[114,22.5],[114,22.6],[113.9,22.5]]]},"properties":{}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[[113.9,22.5],[114,22.6]]},"properties":{"segments":[{"vertex_id":1,"id":10,"width":30},{"vertex_id":2,"id":11,"width":30}]}}
+	]}`
+	var collection FeatureCollection
+	if err := json.Unmarshal([]byte(data), &collection); nil != err {
+		t.Fatal(err)
+	}
+
+	m := NewManager(nil, nil, "")
+	m.SaveFence(circle(1))
+	fences, err := m.ImportGeoJSON(&collection)
+	if nil != err || 4 != len(fences) {
+		t.Fatalf("unexpected fences: %+v, %v", fences, err)
+	}
+
+	c := fences[0].Circle
+	if 2 != c.ID || 70500000 != c.CenterX || 33250000 != c.CenterY || !c.Attr.IsWest() || !c.Attr.IsSouth() {
+		t.Fatalf("unexpected circle: %+v", c)
+	}
+	r := fences[1].Rect
+	if 113900000 != r.NWX || 22600000 != r.NWY || 114000000 != r.SEX || 22500000 != r.SEY || !r.Attr.HasSpeed() || 60 != r.MaxSpeed {
+		t.Fatalf("unexpected rect: %+v", r)
+	}
+	if p := fences[2].Polygon; 1 != p.ID || 6 != len(p.Vertexs) || 22500000 != p.Vertexs[0] || 113900000 != p.Vertexs[1] {
+		t.Fatalf("unexpected polygon: %+v", p)
+	}
+	if route := fences[3].Route; 2 != len(route.Vertexs) || 11 != route.Vertexs[1].Tag.ID || 30 != route.Vertexs[1].Tag.Width {
+		t.Fatalf("unexpected route: %+v", route)
+	}
+
+	// 导出后再导入结果一致
+	exported, err := m.ExportGeoJSON()
+	if nil != err || 5 != len(exported.Features) {
+		t.Fatalf("unexpected export: %+v, %v", exported, err)
+	}
+	again := NewManager(nil, nil, "")
+	if _, err := again.ImportGeoJSON(exported); nil != err {
+		t.Fatal(err)
+	}
+	for _, fence := range m.Fences() {
+		got, ok := again.Fence(fence.Key())
+		if !ok || !sameArea(&fence, area(&got)) {
+			t.Fatalf("fence %s changed after export: %+v", fence.Key(), got)
+		}
+	}
+
+	// 跨越赤道的区域无法表示
+	cross := `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[10,1],[11,-1],[11,1],[10,1]]]},"properties":{}}`
+	var feature Feature
+	json.Unmarshal([]byte(cross), &feature)
+	if _, err := fromFeature(&feature); nil == err {
+		t.Fatal("expected an error for a polygon crossing the equator")
+	}
+}
+
+// area 取区域数据用于比较
+func area(fence *Fence) protocol.Area {
+	switch fence.Kind {
+	case KindCircle:
+		return fence.Circle
+	case KindRect:
+		return fence.Rect
+	case KindPolygon:
+		return fence.Polygon
+	}
+	return fence.Route
+}
@@ -0,0 +1,206 @@
+package geofence
+
+import (
+	"common/protocol"
+	"math"
+)
+
+// 空间索引网格大小，单位：度
+const cellSize = 0.05
+
+// 单个区域最多占用的网格数，超过时不进入网格，每次都作为候选
+const maxCells = 4096
+
+// 路段宽度为0时的默认宽度，单位：m
+const defaultWidth = 50
+
+// 地球平均半径，单位：m
+const earthRadius = 6371000
+
+// 每度纬度的距离，单位：m
+const metersPerDegree = earthRadius * math.Pi / 180
+
+// shape 预先计算的区域几何，坐标为带符号的度，南纬、西经为负
+type shape struct {
+	key   Key
+	fence *Fence
+	// 外包矩形，路线已按路段宽度扩展
+	minX, minY, maxX, maxY float64
+	// 圆心及半径（m）
+	centerX, centerY, radius float64
+	// 多边形顶点或路线拐点，[经度, 纬度]
+	points [][2]float64
+}
+
+// newShape 计算区域几何
+func newShape(fence *Fence) *shape {
+	s := &shape{key: fence.Key(), fence: fence}
+	switch fence.Kind {
+	case KindCircle:
+		c := fence.Circle
+		s.centerX, s.centerY = toDegrees(c.CenterX, c.Attr.IsWest()), toDegrees(c.CenterY, c.Attr.IsSouth())
+		s.radius = float64(c.Radius)
+		dy := s.radius / metersPerDegree
+		dx := dy / cosLat(s.centerY)
+		s.minX, s.minY, s.maxX, s.maxY = s.centerX-dx, s.centerY-dy, s.centerX+dx, s.centerY+dy
+	case KindRect:
+		r := fence.Rect
+		west, south := r.Attr.IsWest(), r.Attr.IsSouth()
+		s.points = [][2]float64{point(r.NWX, r.NWY, west, south), point(r.SEX, r.SEY, west, south)}
+		s.bound(0)
+	case KindPolygon:
+		p := fence.Polygon
+		west, south := p.Attr.IsWest(), p.Attr.IsSouth()
+		for idx := 0; idx+1 < len(p.Vertexs); idx += 2 {
+			s.points = append(s.points, point(p.Vertexs[idx+1], p.Vertexs[idx], west, south))
+		}
+		s.bound(0)
+	case KindRoute:
+		var margin float64
+		for _, v := range fence.Route.Vertexs {
+			s.points = append(s.points, point(v.Longitude, v.Latitude, v.Tag.Attr.IsWest(), v.Tag.Attr.IsSouth()))
+			margin = math.Max(margin, halfWidth(v.Tag))
+		}
+		s.bound(margin)
+	}
+	return s
+}
+
+// bound 计算顶点的外包矩形，并向外扩展margin米
+func (s *shape) bound(margin float64) {
+	s.minX, s.minY, s.maxX, s.maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range s.points {
+		s.minX, s.maxX = math.Min(s.minX, p[0]), math.Max(s.maxX, p[0])
+		s.minY, s.maxY = math.Min(s.minY, p[1]), math.Max(s.maxY, p[1])
+	}
+	dy := margin / metersPerDegree
+	dx := dy / math.Min(cosLat(s.minY), cosLat(s.maxY))
+	s.minX, s.minY, s.maxX, s.maxY = s.minX-dx, s.minY-dy, s.maxX+dx, s.maxY+dy
+}
+
+// contains 点是否在区域内，路线不适用
+func (s *shape) contains(x, y float64) bool {
+	if x < s.minX || x > s.maxX || y < s.minY || y > s.maxY {
+		return false
+	}
+	switch s.key.Kind {
+	case KindCircle:
+		return distance(x, y, s.centerX, s.centerY) <= s.radius
+	case KindRect:
+		return true
+	case KindPolygon:
+		// 射线法
+		inside := false
+		for i, j := 0, len(s.points)-1; i < len(s.points); j, i = i, i+1 {
+			a, b := s.points[i], s.points[j]
+			if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+	return false
+}
+
+// segment 点所在的路段序号，按路段宽度判断，不在任何路段上时返回-1。
+// 路段为拐点到下一拐点，属性取自起始拐点
+func (s *shape) segment(x, y float64) int {
+	if x < s.minX || x > s.maxX || y < s.minY || y > s.maxY {
+		return -1
+	}
+	found, nearest := -1, math.Inf(1)
+	vertexes := s.fence.Route.Vertexs
+	for idx := 0; idx+1 < len(s.points); idx++ {
+		d := segmentDistance(x, y, s.points[idx], s.points[idx+1])
+		if d <= halfWidth(vertexes[idx].Tag) && d < nearest {
+			found, nearest = idx, d
+		}
+	}
+	return found
+}
+
+// halfWidth 路段宽度的一半，单位：m
+func halfWidth(tag protocol.Segment) float64 {
+	if 0 == tag.Width {
+		return defaultWidth / 2
+	}
+	return float64(tag.Width) / 2
+}
+
+// cell 网格坐标
+type cell struct {
+	x, y int32
+}
+
+func cellOf(x, y float64) cell {
+	return cell{int32(math.Floor(x / cellSize)), int32(math.Floor(y / cellSize))}
+}
+
+// index 区域网格索引，按外包矩形把区域放入覆盖的网格
+type index struct {
+	cells map[cell][]*shape
+	// 覆盖网格过多的大区域
+	large []*shape
+	// 全部区域
+	shapes map[Key]*shape
+}
+
+// newIndex 建立区域索引
+func newIndex(fences map[Key]*Fence) *index {
+	idx := &index{cells: make(map[cell][]*shape), shapes: make(map[Key]*shape, len(fences))}
+	for key, fence := range fences {
+		s := newShape(fence)
+		idx.shapes[key] = s
+
+		lo, hi := cellOf(s.minX, s.minY), cellOf(s.maxX, s.maxY)
+		if (int64(hi.x)-int64(lo.x)+1)*(int64(hi.y)-int64(lo.y)+1) > maxCells {
+			idx.large = append(idx.large, s)
+			continue
+		}
+		for x := lo.x; x <= hi.x; x++ {
+			for y := lo.y; y <= hi.y; y++ {
+				c := cell{x, y}
+				idx.cells[c] = append(idx.cells[c], s)
+			}
+		}
+	}
+	return idx
+}
+
+// query 外包矩形包含该点的候选区域
+func (idx *index) query(x, y float64) map[Key]*shape {
+	candidates := make(map[Key]*shape)
+	for _, list := range [][]*shape{idx.cells[cellOf(x, y)], idx.large} {
+		for _, s := range list {
+			if x >= s.minX && x <= s.maxX && y >= s.minY && y <= s.maxY {
+				candidates[s.key] = s
+			}
+		}
+	}
+	return candidates
+}
+
+func cosLat(lat float64) float64 {
+	return math.Cos(math.Min(math.Abs(lat), 89) * math.Pi / 180)
+}
+
+// distance 两点间的球面距离，单位：m
+func distance(x1, y1, x2, y2 float64) float64 {
+	lat1, lat2 := y1*math.Pi/180, y2*math.Pi/180
+	dLat, dLon := lat2-lat1, (x2-x1)*math.Pi/180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// segmentDistance 点到线段的距离，单位：m，以该点为中心做等距投影
+func segmentDistance(x, y float64, a, b [2]float64) float64 {
+	scale := cosLat(y) * metersPerDegree
+	ax, ay := (a[0]-x)*scale, (a[1]-y)*metersPerDegree
+	bx, by := (b[0]-x)*scale, (b[1]-y)*metersPerDegree
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
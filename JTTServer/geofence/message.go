@@ -0,0 +1,212 @@
+package geofence
+
+import (
+	"common/protocol"
+	"reflect"
+)
+
+// setMessages 生成设置区域消息。圆形和矩形区域按maxAreasPerMsg分批，
+// 更新操作只有第一批为更新，其余为追加；多边形区域和路线每条消息只能设置一个，
+// 更新操作先删除终端上全部同类区域
+func setMessages(op byte, fences []*Fence) []protocol.Output {
+	var circles []protocol.RoundArea
+	var rects []protocol.RectArea
+	var polygons []protocol.PolygonArea
+	var routes []protocol.Polyline
+	for _, fence := range fences {
+		switch fence.Kind {
+		case KindCircle:
+			circles = append(circles, *fence.Circle)
+		case KindRect:
+			rects = append(rects, *fence.Rect)
+		case KindPolygon:
+			polygons = append(polygons, *fence.Polygon)
+		case KindRoute:
+			routes = append(routes, *fence.Route)
+		}
+	}
+
+	var outputs []protocol.Output
+	for start, chunkOp := 0, op; start < len(circles); start += maxAreasPerMsg {
+		end := start + maxAreasPerMsg
+		if end > len(circles) {
+			end = len(circles)
+		}
+		msg := protocol.NewMsgRoundAreaSettings()
+		msg.Operation, msg.Areas = chunkOp, circles[start:end]
+		outputs = append(outputs, msg)
+		if protocol.AreaOpUpdate == chunkOp {
+			chunkOp = protocol.AreaOpAppend
+		}
+	}
+	for start, chunkOp := 0, op; start < len(rects); start += maxAreasPerMsg {
+		end := start + maxAreasPerMsg
+		if end > len(rects) {
+			end = len(rects)
+		}
+		msg := protocol.NewMsgRectAreaSettings()
+		msg.Operation, msg.Areas = chunkOp, rects[start:end]
+		outputs = append(outputs, msg)
+		if protocol.AreaOpUpdate == chunkOp {
+			chunkOp = protocol.AreaOpAppend
+		}
+	}
+
+	if protocol.AreaOpUpdate == op && 0 != len(polygons) {
+		outputs = append(outputs, protocol.NewMsgPolygonAreaDelete())
+	}
+	for _, polygon := range polygons {
+		msg := protocol.NewMsgPolygonAreaSettings()
+		msg.Area = polygon
+		outputs = append(outputs, msg)
+	}
+	if protocol.AreaOpUpdate == op && 0 != len(routes) {
+		outputs = append(outputs, protocol.NewMsgPathDelete())
+	}
+	for _, route := range routes {
+		msg := protocol.NewMsgPathSettings()
+		msg.Path = route
+		outputs = append(outputs, msg)
+	}
+	return outputs
+}
+
+// deleteMessages 生成删除区域消息，按类型及maxIDsPerMsg分批。
+// 区域数为0表示删除全部，因此不生成空的删除消息
+func deleteMessages(keys []Key) []protocol.Output {
+	ids := make(map[string][]uint32)
+	for _, key := range keys {
+		ids[key.Kind] = append(ids[key.Kind], key.ID)
+	}
+
+	var outputs []protocol.Output
+	for _, kind := range kinds {
+		list := ids[kind]
+		for start := 0; start < len(list); start += maxIDsPerMsg {
+			end := start + maxIDsPerMsg
+			if end > len(list) {
+				end = len(list)
+			}
+			chunk := list[start:end]
+			switch kind {
+			case KindCircle:
+				msg := protocol.NewMsgRoundAreaDelete()
+				msg.IDs = chunk
+				outputs = append(outputs, msg)
+			case KindRect:
+				msg := protocol.NewMsgRectAreaDelete()
+				msg.IDs = chunk
+				outputs = append(outputs, msg)
+			case KindPolygon:
+				msg := protocol.NewMsgPolygonAreaDelete()
+				msg.IDs = chunk
+				outputs = append(outputs, msg)
+			case KindRoute:
+				msg := protocol.NewMsgPathDelete()
+				msg.IDs = chunk
+				outputs = append(outputs, msg)
+			}
+		}
+	}
+	return outputs
+}
+
+// 2011版终端应答中没有夜间最高速度，解析时置为该值
+const noNightSpeed = 0xFFFF
+
+// sameArea 比较分配的区域与终端应答的区域。只比较下发时写入的字段；
+// 2011版终端不保存夜间最高速度和区域名称，终端应答中没有时不比较
+func sameArea(fence *Fence, area protocol.Area) bool {
+	switch fence.Kind {
+	case KindCircle:
+		got, ok := area.(*protocol.RoundArea)
+		if !ok {
+			return false
+		}
+		want, actual := *fence.Circle, *got
+		normalizeArea(&want.Attr, &want.STime, &want.ETime, &want.MaxSpeed, &want.Duration, &want.MaxSpeedInNight)
+		normalizeArea(&actual.Attr, &actual.STime, &actual.ETime, &actual.MaxSpeed, &actual.Duration, &actual.MaxSpeedInNight)
+		ignoreMissing(&want.MaxSpeedInNight, &actual.MaxSpeedInNight, &want.Name, &actual.Name)
+		return want == actual
+	case KindRect:
+		got, ok := area.(*protocol.RectArea)
+		if !ok {
+			return false
+		}
+		want, actual := *fence.Rect, *got
+		normalizeArea(&want.Attr, &want.STime, &want.ETime, &want.MaxSpeed, &want.Duration, &want.MaxSpeedInNight)
+		normalizeArea(&actual.Attr, &actual.STime, &actual.ETime, &actual.MaxSpeed, &actual.Duration, &actual.MaxSpeedInNight)
+		ignoreMissing(&want.MaxSpeedInNight, &actual.MaxSpeedInNight, &want.Name, &actual.Name)
+		return want == actual
+	case KindPolygon:
+		got, ok := area.(*protocol.PolygonArea)
+		if !ok {
+			return false
+		}
+		want, actual := *fence.Polygon, *got
+		normalizeArea(&want.Attr, &want.STime, &want.ETime, &want.MaxSpeed, &want.Duration, &want.MaxSpeedInNight)
+		normalizeArea(&actual.Attr, &actual.STime, &actual.ETime, &actual.MaxSpeed, &actual.Duration, &actual.MaxSpeedInNight)
+		ignoreMissing(&want.MaxSpeedInNight, &actual.MaxSpeedInNight, &want.Name, &actual.Name)
+		return reflect.DeepEqual(want, actual)
+	case KindRoute:
+		got, ok := area.(*protocol.Polyline)
+		if !ok || len(fence.Route.Vertexs) != len(got.Vertexs) {
+			return false
+		}
+		want, actual := *fence.Route, *got
+		if !want.Attr.HasTime() {
+			want.STime, want.ETime = "", ""
+		}
+		if !actual.Attr.HasTime() {
+			actual.STime, actual.ETime = "", ""
+		}
+		if "" == actual.Name {
+			want.Name = ""
+		}
+		for idx := range want.Vertexs {
+			w, a := normalizeSegment(want.Vertexs[idx].Tag), normalizeSegment(actual.Vertexs[idx].Tag)
+			if noNightSpeed == a.MaxSpeedInNight {
+				w.MaxSpeedInNight, a.MaxSpeedInNight = 0, 0
+			}
+			if w != a || want.Vertexs[idx].ID != actual.Vertexs[idx].ID ||
+				want.Vertexs[idx].Latitude != actual.Vertexs[idx].Latitude ||
+				want.Vertexs[idx].Longitude != actual.Vertexs[idx].Longitude {
+				return false
+			}
+		}
+		want.Vertexs, actual.Vertexs = nil, nil
+		return reflect.DeepEqual(want, actual)
+	}
+	return false
+}
+
+// normalizeArea 清除区域属性未启用、下发时不写入的字段
+func normalizeArea(attr *protocol.AreaAttr, stime, etime *string, speed *uint16, duration *byte, night *uint16) {
+	if !attr.HasTime() {
+		*stime, *etime = "", ""
+	}
+	if !attr.HasSpeed() {
+		*speed, *duration, *night = 0, 0, 0
+	}
+}
+
+// ignoreMissing 终端应答中没有夜间最高速度或区域名称时不比较
+func ignoreMissing(wantNight, gotNight *uint16, wantName, gotName *string) {
+	if noNightSpeed == *gotNight {
+		*wantNight, *gotNight = 0, 0
+	}
+	if "" == *gotName {
+		*wantName = ""
+	}
+}
+
+// normalizeSegment 清除路段属性未启用、下发时不写入的字段
+func normalizeSegment(s protocol.Segment) protocol.Segment {
+	if !s.Attr.HasTime() {
+		s.MaxDuration, s.MinDuration = 0, 0
+	}
+	if !s.Attr.HasSpeedLimit() {
+		s.MaxSpeed, s.Duration, s.MaxSpeedInNight = 0, 0, 0
+	}
+	return s
+}
@@ -5,8 +5,11 @@ go 1.16
 require github.com/beego/beego/v2 v2.0.1
 
 require (
-	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
+	common/protocol v0.0.0-00010101000000-000000000000
 	github.com/go-netty/go-netty v0.0.0-20210318115346-68000ac9a2c6
+	github.com/gorilla/websocket v1.4.2
 	github.com/smartystreets/goconvey v1.6.4
 	github.com/spaolacci/murmur3 v1.1.0
 )
+
+replace common/protocol => ../common/protocol
@@ -0,0 +1,403 @@
+package inventory
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// InventoryApp 默认的终端台账服务，由Setup初始化
+	InventoryApp *Manager
+)
+
+var (
+	// ErrTerminalNotFound 终端不在台账中
+	ErrTerminalNotFound = errors.New("the terminal does not exist")
+)
+
+// Setup 初始化默认的终端台账服务，offline为终端不在线时Requester返回的错误，
+// file为台账存储文件，为空时不持久化
+//
+// inventory.Setup(jtt.Request, jtt.ErrClientOffline, "inventory.json")
+func Setup(request terminal.Requester, offline error, file string) error {
+	m := NewManager(request, offline, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	InventoryApp = m
+	return nil
+}
+
+// 每台终端保留的最大变更记录数
+const maxHistory = 100
+
+// 台账变更来源
+const (
+	SourceRegister   = "register"   // 终端注册（0x0100）
+	SourceAuth       = "auth"       // 终端鉴权（0x0102）
+	SourceAttributes = "attributes" // 终端属性（0x0107）
+)
+
+// Terminal 终端台账信息
+type Terminal struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 省域id
+	Province uint16 `json:"province"`
+	// 市域id
+	City uint16 `json:"city"`
+	// 终端类型
+	TerType uint16 `json:"ter_type"`
+	// 制造商id
+	VendorID string `json:"vendor_id"`
+	// 终端型号
+	Model string `json:"model"`
+	// 终端id
+	TerID string `json:"ter_id"`
+	// 终端SIM卡ICCID号
+	ICCID string `json:"iccid"`
+	// 国际移动设备识别码
+	IMEI string `json:"imei"`
+	// 终端硬件版本号
+	HWVersion string `json:"hw_version"`
+	// 终端固件版本号
+	FWVersion string `json:"fw_version"`
+	// 终端鉴权时上报的软件版本号，仅2019版协议
+	SWVersion string `json:"sw_version"`
+	// GNSS模块属性
+	GnssAttr protocol.GNSSAttr `json:"gnss_attr"`
+	// 通信模块属性
+	CommAttr protocol.COMMAttr `json:"comm_attr"`
+	// 车牌颜色
+	PlateColor byte `json:"plate_color"`
+	// 车牌
+	Plate string `json:"plate"`
+	// 最近一次注册时间
+	Registered time.Time `json:"registered"`
+	// 最近一次查询终端属性的时间，未查询时为零值
+	AttrUpdated time.Time `json:"attr_updated"`
+	// 终端属性是否需要重新查询，终端鉴权时自动查询
+	AttrStale bool `json:"attr_stale"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// field 台账字段及其文本值，用于比较变更
+type field struct {
+	name  string
+	value string
+}
+
+func (t *Terminal) fields() []field {
+	return []field{
+		{"province", strconv.Itoa(int(t.Province))},
+		{"city", strconv.Itoa(int(t.City))},
+		{"ter_type", strconv.Itoa(int(t.TerType))},
+		{"vendor_id", t.VendorID},
+		{"model", t.Model},
+		{"ter_id", t.TerID},
+		{"iccid", t.ICCID},
+		{"imei", t.IMEI},
+		{"hw_version", t.HWVersion},
+		{"fw_version", t.FWVersion},
+		{"sw_version", t.SWVersion},
+		{"gnss_attr", strings.Join(t.GnssAttr.Capabilities(), ",")},
+		{"comm_attr", strings.Join(t.CommAttr.Capabilities(), ",")},
+		{"plate_color", strconv.Itoa(int(t.PlateColor))},
+		{"plate", t.Plate},
+	}
+}
+
+// Change 台账字段变更
+type Change struct {
+	// 字段名称，与Terminal的json名称一致
+	Field string `json:"field"`
+	// 原值
+	Old string `json:"old"`
+	// 新值
+	New string `json:"new"`
+}
+
+// Revision 一次台账变更记录
+type Revision struct {
+	// 变更时间
+	Time time.Time `json:"time"`
+	// 变更来源，见SourceXXX
+	Source string `json:"source"`
+	// 变更的字段
+	Changes []Change `json:"changes"`
+}
+
+// Record 终端台账及变更历史
+type Record struct {
+	Terminal
+	// 变更历史，按时间先后排列，最多保留maxHistory条
+	History []Revision `json:"history"`
+}
+
+// Manager 终端台账服务。
+//
+// 记录终端注册（0x0100）与终端属性（0x0107）上报的信息，按手机号索引；终端首次鉴权、
+// 鉴权上报的软件版本变化或升级成功后自动以0x8107查询终端属性，每次变更保留字段级历史。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	file    string
+
+	mtx      sync.Mutex
+	records  map[string]*Record
+	querying map[string]bool
+}
+
+// NewManager 新建终端台账服务
+func NewManager(request terminal.Requester, offline error, file string) *Manager {
+	return &Manager{
+		request:  request,
+		offline:  offline,
+		file:     file,
+		records:  make(map[string]*Record),
+		querying: make(map[string]bool),
+	}
+}
+
+// OnRegister 终端注册，记录注册信息
+func (m *Manager) OnRegister(phone string, msg *protocol.MsgTerminalLogin) {
+	m.update(phone, SourceRegister, func(t *Terminal) {
+		t.Province = msg.Province
+		t.City = msg.City
+		t.VendorID = trim(msg.Vendor)
+		t.Model = trim(msg.Model)
+		t.TerID = trim(msg.TerID)
+		t.PlateColor = msg.Color
+		t.Plate = trim(msg.LicencePlate)
+		t.Registered = time.Now()
+	})
+}
+
+// OnAuth 终端鉴权成功，终端属性未查询、已过期或软件版本变化时在新的goroutine中查询终端属性
+func (m *Manager) OnAuth(phone string, msg *protocol.MsgTerminalAuth) {
+	var refresh bool
+	m.update(phone, SourceAuth, func(t *Terminal) {
+		if imei := trim(msg.IMEI); "" != imei {
+			t.IMEI = imei
+		}
+		if version := trim(msg.Version); "" != version {
+			if "" != t.SWVersion && version != t.SWVersion {
+				t.AttrStale = true
+			}
+			t.SWVersion = version
+		}
+		refresh = t.AttrUpdated.IsZero() || t.AttrStale
+	})
+
+	if refresh {
+		go m.Refresh(phone)
+	}
+}
+
+// OnUpgradeResult 终端升级结果应答，升级成功后固件版本已变化，在新的goroutine中重新查询终端属性
+func (m *Manager) OnUpgradeResult(phone string, msg *protocol.MsgTerminalUpgradeResp) {
+	if protocol.UpgradeResultSuccess != msg.Result {
+		return
+	}
+	m.update(phone, SourceAttributes, func(t *Terminal) {
+		t.AttrStale = true
+	})
+	go m.Refresh(phone)
+}
+
+// OnAttributes 记录终端属性
+func (m *Manager) OnAttributes(phone string, attr *protocol.TerminalAttr) {
+	m.update(phone, SourceAttributes, func(t *Terminal) {
+		t.TerType = attr.TerType
+		t.VendorID = trim(attr.VendorID)
+		t.Model = trim(attr.Model)
+		t.TerID = trim(attr.TerID)
+		t.ICCID = trim(attr.ICCID)
+		t.HWVersion = trim(attr.HWVersion)
+		t.FWVersion = trim(attr.FWVersion)
+		t.GnssAttr = attr.GnssAttr
+		t.CommAttr = attr.CommAttr
+		t.AttrUpdated = time.Now()
+		t.AttrStale = false
+	})
+}
+
+// Refresh 以0x8107查询终端属性并更新台账，同一终端同时只查询一次；
+// 终端不在线时标记为过期，待终端鉴权后重新查询
+func (m *Manager) Refresh(phone string) (Terminal, error) {
+	m.mtx.Lock()
+	if m.querying[phone] {
+		m.mtx.Unlock()
+		return m.terminal(phone), nil
+	}
+	m.querying[phone] = true
+	m.mtx.Unlock()
+
+	defer func() {
+		m.mtx.Lock()
+		delete(m.querying, phone)
+		m.mtx.Unlock()
+	}()
+
+	attr, err := m.queryAttr(phone)
+	if nil != err {
+		if nil != m.offline && errors.Is(err, m.offline) {
+			m.update(phone, SourceAttributes, func(t *Terminal) {
+				t.AttrStale = true
+			})
+		}
+		return Terminal{}, err
+	}
+	m.OnAttributes(phone, attr)
+	return m.terminal(phone), nil
+}
+
+// Record 获取终端台账及变更历史
+func (m *Manager) Record(phone string) (Record, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	record, ok := m.records[phone]
+	if !ok {
+		return Record{}, false
+	}
+	result := *record
+	result.History = append([]Revision(nil), record.History...)
+	return result, true
+}
+
+// Terminals 获取所有终端台账，按手机号排列
+func (m *Manager) Terminals() []Terminal {
+	m.mtx.Lock()
+	terminals := make([]Terminal, 0, len(m.records))
+	for _, record := range m.records {
+		terminals = append(terminals, record.Terminal)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(terminals, func(i, j int) bool {
+		return terminals[i].Phone < terminals[j].Phone
+	})
+	return terminals
+}
+
+// terminal 获取终端台账，不存在时返回零值
+func (m *Manager) terminal(phone string) Terminal {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if record, ok := m.records[phone]; ok {
+		return record.Terminal
+	}
+	return Terminal{}
+}
+
+// update 修改终端台账，记录变更的字段并保存
+func (m *Manager) update(phone string, source string, modify func(t *Terminal)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	record, ok := m.records[phone]
+	if !ok {
+		record = &Record{Terminal: Terminal{Phone: phone}}
+		m.records[phone] = record
+	}
+
+	old := record.Terminal.fields()
+	modify(&record.Terminal)
+	record.Updated = time.Now()
+
+	var changes []Change
+	for idx, f := range record.Terminal.fields() {
+		if f.value != old[idx].value {
+			changes = append(changes, Change{Field: f.name, Old: old[idx].value, New: f.value})
+		}
+	}
+	if 0 != len(changes) {
+		record.History = append(record.History, Revision{Time: record.Updated, Source: source, Changes: changes})
+		if len(record.History) > maxHistory {
+			record.History = record.History[len(record.History)-maxHistory:]
+		}
+	}
+
+	if err := m.save(); nil != err {
+		log.Printf("保存终端台账失败：%v", err)
+	}
+}
+
+// queryAttr 查询终端属性
+func (m *Manager) queryAttr(phone string) (*protocol.TerminalAttr, error) {
+	input, err := m.request(phone, protocol.NewMsgGetTerminalAttr(), terminal.RequestTimeout, protocol.MsgIDGetTerminalAttrResp)
+	if nil != err {
+		return nil, err
+	}
+	switch msg := input.(type) {
+	case *protocol.MsgGetTerminalAttrResp:
+		return &msg.TerminalAttr, nil
+	case *protocol.MsgGetTerminalAttrResp2011:
+		return &msg.TerminalAttr, nil
+	default:
+		return nil, terminal.ErrUnexpectedData
+	}
+}
+
+// save 保存台账，先写入临时文件再替换，调用方持有锁
+func (m *Manager) save() error {
+	if "" == m.file {
+		return nil
+	}
+
+	records := make([]*Record, 0, len(m.records))
+	for _, record := range m.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Phone < records[j].Phone
+	})
+	return util.SaveJSON(m.file, records)
+}
+
+// load 加载已保存的台账，文件不存在时忽略
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var records []*Record
+	if err := json.Unmarshal(data, &records); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, record := range records {
+		if "" != record.Phone {
+			m.records[record.Phone] = record
+		}
+	}
+	return nil
+}
+
+// trim 去除定长字段的填充字节及空白
+func trim(value string) string {
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
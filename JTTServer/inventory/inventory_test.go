@@ -0,0 +1,92 @@
+package inventory
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// attrTerminal 以attr应答终端属性查询
+func attrTerminal(attr *protocol.TerminalAttr) terminaltest.Handler {
+	return func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+		if _, ok := output.(*protocol.MsgGetTerminalAttr); !ok {
+			return nil, terminaltest.ErrUnexpectedMessage
+		}
+		return &protocol.MsgGetTerminalAttrResp{MsgGetTerminalAttrResp2011: protocol.MsgGetTerminalAttrResp2011{TerminalAttr: *attr}}, nil
+	}
+}
+
+func waitAttr(t *testing.T, m *Manager, phone string, version string) Terminal {
+	var record Record
+	terminaltest.Wait(t, "firmware version is not "+version, func() bool {
+		record, _ = m.Record(phone)
+		return version == record.FWVersion && !record.AttrStale
+	})
+	return record.Terminal
+}
+
+func TestInventory(t *testing.T) {
+	attr := &protocol.TerminalAttr{
+		VendorID:  "ACME\x00\x00\x00\x00\x00\x00\x00",
+		Model:     "T1",
+		ICCID:     "89860123456789012345",
+		FWVersion: "1.0",
+		GnssAttr:  protocol.GNSSAttr(0x03),
+		CommAttr:  protocol.COMMAttr(0x20),
+	}
+	terminals := terminaltest.New(attrTerminal(attr))
+	file := filepath.Join(t.TempDir(), "inventory.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+
+	login := &protocol.MsgTerminalLogin{}
+	login.Vendor, login.Model, login.Color, login.LicencePlate = "ACME\x00", "T1", 2, "粤B12345"
+	m.OnRegister("13800000000", login)
+
+	// 首次鉴权查询终端属性
+	auth := &protocol.MsgTerminalAuth{IMEI: "860000000000001", Version: "SW1\x00"}
+	m.OnAuth("13800000000", auth)
+	info := waitAttr(t, m, "13800000000", "1.0")
+	if "ACME" != info.VendorID || "粤B12345" != info.Plate || "860000000000001" != info.IMEI || !info.GnssAttr.IsBDS() {
+		t.Fatalf("unexpected terminal: %+v", info)
+	}
+
+	// 版本未变化时不重复查询
+	m.OnAuth("13800000000", auth)
+	time.Sleep(time.Millisecond * 50)
+	if queries := len(terminals.Received("13800000000")); 1 != queries {
+		t.Fatalf("got %d queries, want 1", queries)
+	}
+
+	// 升级成功后终端不在线，鉴权上报新版本后重新查询
+	terminals.SetOffline("13800000000", true)
+	terminals.Do(func() { attr.FWVersion = "2.0" })
+	m.OnUpgradeResult("13800000000", &protocol.MsgTerminalUpgradeResp{Result: protocol.UpgradeResultSuccess})
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond * 10) {
+		m.mtx.Lock()
+		querying := m.querying["13800000000"]
+		m.mtx.Unlock()
+		if !querying || time.Now().After(deadline) {
+			break
+		}
+	}
+	terminals.SetOffline("13800000000", false)
+	m.OnAuth("13800000000", &protocol.MsgTerminalAuth{Version: "SW2"})
+	waitAttr(t, m, "13800000000", "2.0")
+
+	record, _ := m.Record("13800000000")
+	last := record.History[len(record.History)-1]
+	if SourceAttributes != last.Source || 1 != len(last.Changes) || (Change{"fw_version", "1.0", "2.0"}) != last.Changes[0] {
+		t.Fatalf("unexpected last revision: %+v", last)
+	}
+
+	// 重新加载已保存的台账
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if reloaded, ok := loaded.Record("13800000000"); !ok || "2.0" != reloaded.FWVersion || len(record.History) != len(reloaded.History) {
+		t.Fatalf("unexpected reloaded record: %+v", reloaded)
+	}
+}
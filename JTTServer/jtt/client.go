@@ -4,7 +4,12 @@ import (
 	"io"
 	"log"
 	"net"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"common/protocol"
 
 	"github.com/go-netty/go-netty"
 	"github.com/spaolacci/murmur3"
@@ -18,11 +23,14 @@ type subscriber interface {
 	// 终端断开
 	onClientDisconnected(Client)
 
+	// 终端身份已识别
+	onClientIdentified(Client)
+
 	// 终端消息
-	onMessage(Client, Input)
+	onMessage(Client, protocol.Input)
 }
 
-func sequenceCounter() Counter {
+func sequenceCounter() protocol.Counter {
 	number := int32(-1)
 	return func() uint16 {
 		return uint16(atomic.AddInt32(&number, 1))
@@ -34,8 +42,14 @@ type Client interface {
 	// 终端ID
 	ID() uint32
 
+	// 终端手机号，去掉前导0，收到终端第一条消息前为空
+	Phone() string
+
 	// 发送消息
-	Send(Output) error
+	Send(protocol.Output) error
+
+	// 发送消息并等待终端应答，replyIDs为可接受的应答消息ID，为空时等待终端通用应答
+	Request(output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error)
 
 	// 本地地址 0.0.0.0:0
 	LocalAddr() string
@@ -46,19 +60,150 @@ type Client interface {
 
 type client struct {
 	id        uint32        // murmurhash值，根据服务地址计算得到
+	phone     atomic.Value  // 终端手机号
 	channel   netty.Channel // 数据通道
 	subsriber subscriber    // 终端（连接）事件订阅者
+	mtx       sync.Mutex    // 保护pendings
+	pendings  []*pending    // 等待应答的请求
+	done      chan struct{} // 连接断开时关闭
+	doneOnce  sync.Once
+}
+
+// pending 等待应答的请求
+type pending struct {
+	output   protocol.Output
+	replyIDs []uint16
+	number   uint16 // 平台消息流水号，分包发送时为首包流水号，发送后得到
+	total    uint16 // 分包总数，各子包占用连续的流水号，发送后得到
+	sent     bool
+	reply    chan protocol.Input
+}
+
+// match 终端消息是否为该请求的应答，分包发送的请求可匹配任一子包的应答；
+// done为请求是否结束，只有最后一个子包的应答或失败的通用应答才结束请求
+func (p *pending) match(input protocol.Input) (matched, done bool) {
+	if !p.sent {
+		return false, false
+	}
+	// 没有应答流水号的应答消息（如0x1003）只按消息ID匹配
+	number, ok := protocol.ReplyNumber(input)
+	if ok && uint16(number-p.number) >= p.total {
+		return false, false
+	}
+	if id, ok := protocol.ReplyID(input); ok && id != protocol.OutputID(p.output) {
+		return false, false
+	}
+
+	msgID := protocol.MessageID(input)
+	for _, id := range p.replyIDs {
+		if id == msgID {
+			if resp, isResp := input.(*protocol.MsgTerminalResponse); isResp && 0 != resp.Result {
+				return true, true
+			}
+			return true, !ok || number == p.number+p.total-1
+		}
+	}
+	return false, false
 }
 
 func (c *client) ID() uint32 {
 	return c.id
 }
 
-func (c *client) Send(output Output) error {
+func (c *client) Phone() string {
+	if phone, ok := c.phone.Load().(string); ok {
+		return phone
+	}
+	return ""
+}
+
+func (c *client) Send(output protocol.Output) error {
 	c.channel.Pipeline().FireChannelWrite(output)
 	return nil
 }
 
+func (c *client) Request(output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	if 0 == len(replyIDs) {
+		replyIDs = []uint16{protocol.MsgIDTerminalResponse}
+	}
+	p := &pending{
+		output:   output,
+		replyIDs: replyIDs,
+		reply:    make(chan protocol.Input, 1),
+	}
+
+	c.mtx.Lock()
+	c.pendings = append(c.pendings, p)
+	c.mtx.Unlock()
+	defer c.removePending(p)
+
+	if err := c.Send(output); nil != err {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case input := <-p.reply:
+		return input, nil
+	case <-timer.C:
+		return nil, ErrRequestTimeout
+	case <-c.done:
+		return nil, ErrClientOffline
+	}
+}
+
+func (c *client) removePending(p *pending) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for idx, item := range c.pendings {
+		if item == p {
+			c.pendings = append(c.pendings[:idx], c.pendings[idx+1:]...)
+			return
+		}
+	}
+}
+
+// 将终端应答交给等待的请求
+func (c *client) replyPending(input protocol.Input) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for idx, p := range c.pendings {
+		// 中间子包的应答只消费掉，等待最后一个子包的应答
+		if matched, done := p.match(input); matched {
+			if done {
+				p.reply <- input
+				c.pendings = append(c.pendings[:idx], c.pendings[idx+1:]...)
+			}
+			return
+		}
+	}
+}
+
+// 记录请求的流水号
+func (c *client) onSent(e protocol.SentEvent) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, p := range c.pendings {
+		if p.output == e.Output && !p.sent {
+			p.number, p.total = e.Number, e.Total
+			p.sent = true
+			return
+		}
+	}
+}
+
+func (c *client) disconnected() {
+	c.doneOnce.Do(func() {
+		close(c.done)
+		c.subsriber.onClientDisconnected(c)
+	})
+}
+
 func (c *client) LocalAddr() string {
 	return c.channel.LocalAddr()
 }
@@ -67,7 +212,8 @@ func (c *client) RemoteAddr() string {
 	return c.channel.RemoteAddr()
 }
 
-func (c *client) onReceive(input Input) {
+func (c *client) OnReceive(input protocol.Input) {
+	c.replyPending(input)
 	c.subsriber.onMessage(c, input)
 	// switch msg := input.(type) {
 	// case *MsgTerminalAuth:
@@ -87,18 +233,23 @@ func (c *client) onReceive(input Input) {
 	// }
 }
 
-func (c *client) onEvent(event netty.Event) {
+func (c *client) OnEvent(event netty.Event) {
 	switch e := event.(type) {
-	case ActiveEvent:
+	case protocol.ActiveEvent:
 		log.Printf("终端[%s]数据通信已开始", c.RemoteAddr())
 		c.subsriber.onClientConnected(c)
-	case InactiveEvent:
+	case protocol.SentEvent:
+		c.onSent(e)
+	case protocol.IdentifiedEvent:
+		c.phone.Store(strings.TrimLeft(e.Phone, "0"))
+		c.subsriber.onClientIdentified(c)
+	case protocol.InactiveEvent:
 		log.Printf("终端[%s]数据通信已结束,原因：%s", c.RemoteAddr(), e.Error())
-		c.subsriber.onClientDisconnected(c)
+		c.disconnected()
 	case netty.Exception:
 		if _, ok := e.Unwrap().(*net.OpError); ok || io.EOF == e.Unwrap() {
 			log.Printf("终端[%s]数据通信已结束,原因：%s", c.RemoteAddr(), e.Error())
-			c.subsriber.onClientDisconnected(c)
+			c.disconnected()
 			c.channel.Close()
 		} else {
 			log.Println(e)
@@ -0,0 +1,34 @@
+package jtt
+
+import (
+	"common/protocol"
+	"testing"
+)
+
+func TestPendingMatch(t *testing.T) {
+	output := protocol.NewMsgTerParamsSettings()
+	reply := func(number uint16, result byte) protocol.Input {
+		resp := &protocol.MsgTerminalResponse{Result: result}
+		resp.ID, resp.ReqID, resp.ReqNum = protocol.MsgIDTerminalResponse, protocol.OutputID(output), number
+		return resp
+	}
+	// 分包发送，流水号回绕
+	p := &pending{output: output, replyIDs: []uint16{protocol.MsgIDTerminalResponse}, number: 65534, total: 3, sent: true}
+
+	for _, test := range []struct {
+		input   protocol.Input
+		matched bool
+		done    bool
+	}{
+		{reply(65533, 0), false, false},
+		{reply(65534, 0), true, false},
+		{reply(65535, 0), true, false},
+		{reply(0, 0), true, true},
+		{reply(1, 0), false, false},
+		{reply(65535, 1), true, true},
+	} {
+		if matched, done := p.match(test.input); test.matched != matched || test.done != done {
+			t.Fatalf("unexpected match of %+v: %v, %v", test.input, matched, done)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package jtt
 
+import "common/protocol"
+
 type Presenter interface {
 	// 初始化
 	Init(Context)
@@ -19,14 +21,14 @@ type Context interface {
 	Client() Client
 
 	// 终端消息
-	Message() Input
+	Message() protocol.Input
 
 	// 平台应答
-	Response(Output)
+	Response(protocol.Output)
 }
 
 // NewContext 新建协议通信上下文。
-func NewContext(client Client, input Input) Context {
+func NewContext(client Client, input protocol.Input) Context {
 	return &contextImpl{
 		client:  client,
 		message: input,
@@ -34,18 +36,18 @@ func NewContext(client Client, input Input) Context {
 }
 
 type contextImpl struct {
-	client  Client // 终端
-	message Input  // 终端消息
+	client  Client         // 终端
+	message protocol.Input // 终端消息
 }
 
 func (c *contextImpl) Client() Client {
 	return c.client
 }
 
-func (c *contextImpl) Message() Input {
+func (c *contextImpl) Message() protocol.Input {
 	return c.message
 }
 
-func (c *contextImpl) Response(output Output) {
+func (c *contextImpl) Response(output protocol.Output) {
 	c.client.Send(output)
 }
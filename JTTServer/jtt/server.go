@@ -2,6 +2,7 @@ package jtt
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -9,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"common/protocol"
+
 	"github.com/go-netty/go-netty"
 	"github.com/go-netty/go-netty/transport"
 	"github.com/go-netty/go-netty/transport/tcp"
@@ -18,6 +21,13 @@ var (
 	JttApp Server
 )
 
+var (
+	// ErrClientOffline 终端不在线
+	ErrClientOffline = errors.New("the terminal is offline")
+	// ErrRequestTimeout 等待终端应答超时
+	ErrRequestTimeout = errors.New("the terminal response timed out")
+)
+
 func init() {
 	JttApp = newJttServer()
 }
@@ -44,9 +54,9 @@ func Run(addr string) {
 func newJttServer() Server {
 	pipelineInitializer := func(channel netty.Channel) {
 		channel.Pipeline().
-			AddLast(DelimiterCodec(0x7E, true, 2048)).
-			AddLast(EscapeCodec(0x7D, map[byte]byte{0x7D: 0x01, 0x7E: 0x02}, 0x7D, map[byte]byte{0x01: 0x7D, 0x02: 0x7E})).
-			AddLast(PacketCodec()).AddLast(MessageCodec(0, nil, sequenceCounter()))
+			AddLast(protocol.DelimiterCodec(0x7E, true, 2048, protocol.MaxFrameSize)).
+			AddLast(protocol.EscapeCodec(0x7D, map[byte]byte{0x7D: 0x01, 0x7E: 0x02}, 0x7D, map[byte]byte{0x01: 0x7D, 0x02: 0x7E})).
+			AddLast(protocol.PacketCodec()).AddLast(protocol.MessageCodec(0, nil, sequenceCounter()))
 	}
 
 	server := &server{
@@ -62,10 +72,38 @@ func newJttServer() Server {
 	return server
 }
 
+// GetClientByPhone 根据手机号获取JttApp中在线的终端，不在线时返回nil
+func GetClientByPhone(phone string) Client {
+	return JttApp.GetClientByPhone(phone)
+}
+
+// Send 向JttApp中在线的终端发送消息，终端不在线时返回ErrClientOffline
+func Send(phone string, output protocol.Output) error {
+	client := JttApp.GetClientByPhone(phone)
+	if nil == client {
+		return ErrClientOffline
+	}
+	return client.Send(output)
+}
+
+// OnlinePhones 获取JttApp中已识别的在线终端手机号
+func OnlinePhones() []string {
+	return JttApp.Phones()
+}
+
+// Request 向JttApp中在线的终端发送消息并等待应答，见Client.Request
+func Request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	client := JttApp.GetClientByPhone(phone)
+	if nil == client {
+		return nil, ErrClientOffline
+	}
+	return client.Request(output, timeout, replyIDs...)
+}
+
 // Router 添加一条协议路线到JttApp中。
 // 用法：
-//  jtt.Router(MsgIdTerminalLogin, &LoginPresenter{}, "TerminalLogin")
-//  jtt.Router(MsgIdTerminalAuth, &LoginPresenter{}, "TerminalAuth")
+//  jtt.Router(protocol.MsgIdTerminalLogin, &LoginPresenter{}, "TerminalLogin")
+//  jtt.Router(protocol.MsgIdTerminalAuth, &LoginPresenter{}, "TerminalAuth")
 func Router(msgId uint16, p Presenter, methodName string) {
 	JttApp.router(msgId, p, methodName)
 }
@@ -85,6 +123,12 @@ type Server interface {
 	// 获取终端
 	GetClient(id uint32) Client
 
+	// 根据手机号获取终端，12位与20位手机号一致
+	GetClientByPhone(phone string) Client
+
+	// 获取已识别的在线终端手机号
+	Phones() []string
+
 	listen(url string, option ...transport.Option) error
 
 	listenAsync(url string, option ...transport.Option)
@@ -103,6 +147,7 @@ type server struct {
 	channelIDFactory  netty.ChannelIDFactory
 	acceptor          transport.Acceptor
 	clients           sync.Map
+	phones            sync.Map
 	routes            map[uint16]*route
 }
 
@@ -123,6 +168,7 @@ func (bs *server) serveTransport(transport transport.Transport) Client {
 		id:        generateHash(transport.RemoteAddr().String()),
 		channel:   channel,
 		subsriber: bs,
+		done:      make(chan struct{}),
 	}
 
 	// set the attachment if necessary
@@ -186,16 +232,39 @@ func (s *server) GetClient(id uint32) Client {
 	return nil
 }
 
+func (s *server) GetClientByPhone(phone string) Client {
+	if value, ok := s.phones.Load(strings.TrimLeft(phone, "0")); ok {
+		return value.(Client)
+	}
+	return nil
+}
+
+func (s *server) Phones() []string {
+	var phones []string
+	s.phones.Range(func(key, value interface{}) bool {
+		phones = append(phones, value.(Client).Phone())
+		return true
+	})
+	return phones
+}
+
 func (s *server) onClientConnected(client Client) {
 	s.clients.Store(client.ID(), client)
 }
 
 func (s *server) onClientDisconnected(client Client) {
 	s.clients.Delete(client.ID())
+	if value, ok := s.phones.Load(client.Phone()); ok && value == client {
+		s.phones.Delete(client.Phone())
+	}
+}
+
+func (s *server) onClientIdentified(client Client) {
+	s.phones.Store(client.Phone(), client)
 }
 
-func (s *server) onMessage(client Client, input Input) {
-	route := s.routes[input.msgID()]
+func (s *server) onMessage(client Client, input protocol.Input) {
+	route := s.routes[protocol.MessageID(input)]
 	if nil == route {
 		return
 	}
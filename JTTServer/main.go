@@ -1,13 +1,80 @@
 package main
 
 import (
+	"JTTServer/attach"
+	"JTTServer/canbus"
+	"JTTServer/contacts"
+	"JTTServer/control"
+	"JTTServer/geofence"
+	"JTTServer/inventory"
 	"JTTServer/jtt"
+	"JTTServer/media"
+	"JTTServer/profile"
+	"JTTServer/recorder"
 	_ "JTTServer/routers"
+	"JTTServer/text"
+	"JTTServer/upgrade"
+	"JTTServer/upload"
+	"JTTServer/vehicle"
+	"log"
+	"net"
+	"strconv"
+	"time"
 
 	beego "github.com/beego/beego/v2/server/web"
 )
 
 func main() {
 	jtt.Run("127.0.0.1:8081")
+	mediaConfig := media.LiveConfig{
+		IP:         beego.AppConfig.DefaultString("media_ip", "127.0.0.1"),
+		TCPPort:    uint16(beego.AppConfig.DefaultInt("media_tcp_port", 1078)),
+		UDPPort:    uint16(beego.AppConfig.DefaultInt("media_udp_port", 1078)),
+		StreamType: byte(beego.AppConfig.DefaultInt("live_stream_type", 1)),
+		IdleGrace:  time.Duration(beego.AppConfig.DefaultInt("live_idle_grace", 10)) * time.Second,
+	}
+	mediaAddr := beego.AppConfig.DefaultString("media_addr", "0.0.0.0")
+	media.Run(net.JoinHostPort(mediaAddr, strconv.Itoa(int(mediaConfig.TCPPort))), net.JoinHostPort(mediaAddr, strconv.Itoa(int(mediaConfig.UDPPort))))
+	media.SetupLive(jtt.Send, mediaConfig)
+	media.SetupPlayback(jtt.Request, mediaConfig)
+	media.SetupTalk(jtt.Request, mediaConfig)
+	upload.Run(beego.AppConfig.DefaultString("ftp_addr", "0.0.0.0:2121"), jtt.Request, upload.Config{
+		Dir:  beego.AppConfig.DefaultString("upload_dir", "uploads"),
+		IP:   beego.AppConfig.DefaultString("ftp_ip", "127.0.0.1"),
+		Port: uint16(beego.AppConfig.DefaultInt("ftp_port", 2121)),
+	})
+	attach.Run(beego.AppConfig.DefaultString("attach_addr", "0.0.0.0:7611"), jtt.Request, attach.Config{
+		Dir:     beego.AppConfig.DefaultString("attach_dir", "attachments"),
+		IP:      beego.AppConfig.DefaultString("attach_ip", "127.0.0.1"),
+		TCPPort: uint16(beego.AppConfig.DefaultInt("attach_port", 7611)),
+	})
+	recorder.Setup(jtt.Request)
+	if err := profile.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("profile_file", "profiles.json")); nil != err {
+		log.Printf("终端参数配置加载失败：%s", err)
+	}
+	if err := upgrade.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("firmware_dir", "firmwares"), beego.AppConfig.DefaultString("upgrade_file", "upgrades.json")); nil != err {
+		log.Printf("终端升级计划加载失败：%s", err)
+	}
+	if err := inventory.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("inventory_file", "inventory.json")); nil != err {
+		log.Printf("终端台账加载失败：%s", err)
+	}
+	vehicle.Setup(jtt.Request)
+	if err := control.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("control_audit_file", "control_audit.log")); nil != err {
+		log.Printf("终端控制审计记录加载失败：%s", err)
+	}
+	if err := geofence.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("geofence_file", "geofences.json")); nil != err {
+		log.Printf("电子围栏加载失败：%s", err)
+	}
+	if err := text.Setup(jtt.Request, jtt.ErrClientOffline, jtt.OnlinePhones, beego.AppConfig.DefaultString("text_file", "texts.json")); nil != err {
+		log.Printf("文本信息下发记录加载失败：%s", err)
+	}
+	if err := contacts.Setup(jtt.Request, jtt.ErrClientOffline, beego.AppConfig.DefaultString("contacts_file", "contacts.json")); nil != err {
+		log.Printf("电话本加载失败：%s", err)
+	}
+	if dbcFile := beego.AppConfig.DefaultString("can_dbc", ""); "" != dbcFile {
+		if err := canbus.Setup(dbcFile); nil != err {
+			log.Printf("CAN总线DBC文件[%s]加载失败：%s", dbcFile, err)
+		}
+	}
 	beego.Run()
 }
@@ -0,0 +1,347 @@
+package media
+
+import (
+	"common/protocol"
+	"encoding/binary"
+)
+
+// 终端音频采样率与每帧采样数（20ms）
+const (
+	audioSampleRate   = 8000
+	audioFrameSamples = 160
+)
+
+// G.711 分段上限
+var (
+	aLawSegEnd = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+	uLawSegEnd = [8]int{0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF, 0x1FFF}
+)
+
+func segment(value int, table *[8]int) int {
+	for idx, end := range table {
+		if value <= end {
+			return idx
+		}
+	}
+	return 8
+}
+
+// encodeALaw 16位线性PCM转G.711 A律
+func encodeALaw(sample int16) byte {
+	value := int(sample) >> 3
+	mask := 0xD5
+	if value < 0 {
+		mask = 0x55
+		value = -value - 1
+	}
+
+	seg := segment(value, &aLawSegEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+	aval := seg << 4
+	if seg < 2 {
+		aval |= (value >> 1) & 0x0F
+	} else {
+		aval |= (value >> uint(seg)) & 0x0F
+	}
+	return byte(aval ^ mask)
+}
+
+// decodeALaw G.711 A律转16位线性PCM
+func decodeALaw(value byte) int16 {
+	value ^= 0x55
+	t := int(value&0x0F) << 4
+	seg := int(value&0x70) >> 4
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= uint(seg - 1)
+	}
+	if 0 != value&0x80 {
+		return int16(t)
+	}
+	return int16(-t)
+}
+
+// encodeULaw 16位线性PCM转G.711 μ律
+func encodeULaw(sample int16) byte {
+	const bias = 0x84 >> 2
+	const clip = 8159
+
+	value := int(sample) >> 2
+	mask := 0xFF
+	if value < 0 {
+		value = -value
+		mask = 0x7F
+	}
+	if value > clip {
+		value = clip
+	}
+	value += bias
+
+	seg := segment(value, &uLawSegEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+	return byte((seg<<4 | (value>>uint(seg+1))&0x0F) ^ mask)
+}
+
+// decodeULaw G.711 μ律转16位线性PCM
+func decodeULaw(value byte) int16 {
+	const bias = 0x84
+
+	value = ^value
+	t := (int(value&0x0F) << 3) + bias
+	t <<= uint(value&0x70) >> 4
+	if 0 != value&0x80 {
+		return int16(bias - t)
+	}
+	return int16(t - bias)
+}
+
+// IMA ADPCM 步长表与索引调整表
+var (
+	adpcmSteps = [89]int{
+		7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+		50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+		337, 371, 408, 449, 494, 544, 598, 658, 724, 796, 876, 963, 1060, 1166, 1282, 1411, 1552, 1707, 1878, 2066,
+		2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358, 5894, 6484, 7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899,
+		15289, 16818, 18500, 20350, 22385, 24623, 27086, 29794, 32767,
+	}
+	adpcmIndexes = [16]int{-1, -1, -1, -1, 2, 4, 6, 8, -1, -1, -1, -1, 2, 4, 6, 8}
+)
+
+// adpcmState IMA ADPCM编解码状态
+type adpcmState struct {
+	predicted int
+	index     int
+}
+
+// step 根据编码值更新状态，返回解码后的采样
+func (s *adpcmState) step(code byte) int16 {
+	step := adpcmSteps[s.index]
+	diff := step >> 3
+	if 0 != code&4 {
+		diff += step
+	}
+	if 0 != code&2 {
+		diff += step >> 1
+	}
+	if 0 != code&1 {
+		diff += step >> 2
+	}
+	if 0 != code&8 {
+		s.predicted -= diff
+	} else {
+		s.predicted += diff
+	}
+	if s.predicted > 32767 {
+		s.predicted = 32767
+	} else if s.predicted < -32768 {
+		s.predicted = -32768
+	}
+
+	s.index += adpcmIndexes[code&0x0F]
+	if s.index < 0 {
+		s.index = 0
+	} else if s.index > 88 {
+		s.index = 88
+	}
+	return int16(s.predicted)
+}
+
+// encode 编码一个采样
+func (s *adpcmState) encode(sample int16) byte {
+	diff := int(sample) - s.predicted
+	code := byte(0)
+	if diff < 0 {
+		code = 8
+		diff = -diff
+	}
+
+	step := adpcmSteps[s.index]
+	if diff >= step {
+		code |= 4
+		diff -= step
+	}
+	step >>= 1
+	if diff >= step {
+		code |= 2
+		diff -= step
+	}
+	step >>= 1
+	if diff >= step {
+		code |= 1
+	}
+
+	s.step(code)
+	return code
+}
+
+// encodeFrame 编码一帧IMA ADPCM（海思DVI4格式）：
+// 4字节状态头（预测值小端、步长索引、保留）后接4位编码，低4位在前
+func (s *adpcmState) encodeFrame(samples []int16) []byte {
+	data := make([]byte, 4, 4+(len(samples)+1)/2)
+	binary.LittleEndian.PutUint16(data, uint16(int16(s.predicted)))
+	data[2] = byte(s.index)
+
+	for idx := 0; idx < len(samples); idx += 2 {
+		b := s.encode(samples[idx])
+		if idx+1 < len(samples) {
+			b |= s.encode(samples[idx+1]) << 4
+		}
+		data = append(data, b)
+	}
+	return data
+}
+
+// decodeADPCM 解码一帧海思DVI4格式的IMA ADPCM
+func decodeADPCM(data []byte) []int16 {
+	if len(data) < 4 {
+		return nil
+	}
+
+	s := adpcmState{
+		predicted: int(int16(binary.LittleEndian.Uint16(data))),
+		index:     int(data[2]),
+	}
+	if s.index > 88 {
+		s.index = 88
+	}
+
+	samples := make([]int16, 0, (len(data)-4)*2)
+	for _, b := range data[4:] {
+		samples = append(samples, s.step(b&0x0F), s.step(b>>4))
+	}
+	return samples
+}
+
+// audioEncoder 将8kHz 16位PCM编码为终端音频格式，不支持并发调用
+type audioEncoder struct {
+	payloadType byte
+	adpcm       adpcmState
+}
+
+// supportedAudio 下行音频是否支持该编码方式
+func supportedAudio(payloadType byte) bool {
+	switch payloadType {
+	case protocol.PayloadTypeG711A, protocol.PayloadTypeG711U, protocol.PayloadTypeADPCMA:
+		return true
+	}
+	return false
+}
+
+// encode 编码一帧音频
+func (e *audioEncoder) encode(samples []int16) []byte {
+	switch e.payloadType {
+	case protocol.PayloadTypeG711A:
+		data := make([]byte, len(samples))
+		for idx, sample := range samples {
+			data[idx] = encodeALaw(sample)
+		}
+		return data
+	case protocol.PayloadTypeG711U:
+		data := make([]byte, len(samples))
+		for idx, sample := range samples {
+			data[idx] = encodeULaw(sample)
+		}
+		return data
+	case protocol.PayloadTypeADPCMA:
+		return e.adpcm.encodeFrame(samples)
+	}
+	return nil
+}
+
+// decodeAudio 将终端上行音频帧解码为8kHz 16位PCM，不支持的编码返回nil
+func decodeAudio(payloadType byte, data []byte) []int16 {
+	switch payloadType {
+	case protocol.PayloadTypeG711A:
+		samples := make([]int16, len(data))
+		for idx, b := range data {
+			samples[idx] = decodeALaw(b)
+		}
+		return samples
+	case protocol.PayloadTypeG711U:
+		samples := make([]int16, len(data))
+		for idx, b := range data {
+			samples[idx] = decodeULaw(b)
+		}
+		return samples
+	case protocol.PayloadTypeADPCMA:
+		return decodeADPCM(data)
+	}
+	return nil
+}
+
+// resampler 将任意采样率的单声道PCM线性插值为8kHz，不支持并发调用
+type resampler struct {
+	rate     int
+	position float64 // 下一个输出采样在输入中的位置，相对于last
+	last     int16   // 上一块的最后一个采样
+	started  bool
+}
+
+func newResampler(rate int) *resampler {
+	if rate <= 0 {
+		rate = audioSampleRate
+	}
+	return &resampler{rate: rate}
+}
+
+// resample 重采样一块输入
+func (r *resampler) resample(input []int16) []int16 {
+	if audioSampleRate == r.rate || 0 == len(input) {
+		return input
+	}
+	if !r.started {
+		r.started = true
+		r.last = input[0]
+	}
+
+	// 以上一块最后一个采样为位置0，输入采样依次为位置1..n
+	sample := func(idx int) float64 {
+		if 0 == idx {
+			return float64(r.last)
+		}
+		return float64(input[idx-1])
+	}
+
+	ratio := float64(r.rate) / audioSampleRate
+	output := make([]int16, 0, int(float64(len(input))/ratio)+1)
+	for r.position <= float64(len(input)) {
+		idx := int(r.position)
+		frac := r.position - float64(idx)
+		value := sample(idx)
+		if frac > 0 && idx+1 <= len(input) {
+			value += (sample(idx+1) - value) * frac
+		}
+		output = append(output, int16(value))
+		r.position += ratio
+	}
+	r.position -= float64(len(input))
+	r.last = input[len(input)-1]
+	return output
+}
+
+// pcmSamples 16位小端PCM转采样
+func pcmSamples(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for idx := range samples {
+		samples[idx] = int16(binary.LittleEndian.Uint16(data[idx*2:]))
+	}
+	return samples
+}
+
+// pcmBytes 采样转16位小端PCM
+func pcmBytes(samples []int16) []byte {
+	data := make([]byte, len(samples)*2)
+	for idx, sample := range samples {
+		binary.LittleEndian.PutUint16(data[idx*2:], uint16(sample))
+	}
+	return data
+}
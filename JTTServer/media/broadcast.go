@@ -0,0 +1,146 @@
+package media
+
+import (
+	"log"
+	"sync"
+)
+
+// broadcaster 将订阅的帧封装为FLV分发给多个观众，直播与录像回放共用
+type broadcaster struct {
+	sub    *Subscriber
+	buffer int
+
+	mtx     sync.Mutex
+	muxer   *flvMuxer
+	viewers map[*Viewer]struct{}
+}
+
+func newBroadcaster(sub *Subscriber, buffer int) *broadcaster {
+	b := &broadcaster{
+		sub:     sub,
+		buffer:  buffer,
+		muxer:   newFlvMuxer(),
+		viewers: make(map[*Viewer]struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// join 加入观众，观众关闭时调用leave
+func (b *broadcaster) join(leave func(*Viewer)) *Viewer {
+	viewer := &Viewer{
+		data:  make(chan []byte, b.buffer),
+		leave: leave,
+	}
+	viewer.data <- flvHeader
+
+	b.mtx.Lock()
+	b.viewers[viewer] = struct{}{}
+	b.mtx.Unlock()
+
+	return viewer
+}
+
+// remove 移除观众，返回剩余观众个数
+func (b *broadcaster) remove(viewer *Viewer) int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.removeLocked(viewer)
+	return len(b.viewers)
+}
+
+// count 观众个数
+func (b *broadcaster) count() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.viewers)
+}
+
+// close 取消订阅，所有观众的数据通道随后关闭
+func (b *broadcaster) close() {
+	b.sub.Close()
+}
+
+// run 封装订阅的帧并分发给观众，订阅关闭后退出
+func (b *broadcaster) run() {
+	for frame := range b.sub.Frames() {
+		b.mtx.Lock()
+		for _, tag := range b.muxer.mux(frame) {
+			for viewer := range b.viewers {
+				b.deliver(viewer, tag)
+			}
+		}
+		b.mtx.Unlock()
+	}
+
+	b.mtx.Lock()
+	for viewer := range b.viewers {
+		b.removeLocked(viewer)
+	}
+	b.mtx.Unlock()
+}
+
+// deliver 向观众发送标签，新观众从视频关键帧（纯音频流时从第一个音频帧）开始，调用者持有b.mtx
+func (b *broadcaster) deliver(viewer *Viewer, tag *flvTag) {
+	if !viewer.started {
+		if tag.header || (tag.video && !tag.key) || (!tag.video && b.muxer.hasVideo()) {
+			return
+		}
+		viewer.started = true
+		viewer.base = tag.timestamp
+		for _, header := range b.muxer.headers() {
+			if !b.push(viewer, header) {
+				return
+			}
+		}
+	}
+	b.push(viewer, tag)
+}
+
+// push 非阻塞发送，观众缓存已满时断开观众，调用者持有b.mtx
+func (b *broadcaster) push(viewer *Viewer, tag *flvTag) bool {
+	base := viewer.base
+	if tag.timestamp < base {
+		base = tag.timestamp
+	}
+
+	select {
+	case viewer.data <- tag.bytes(base):
+		return true
+	default:
+		key := b.sub.Key()
+		log.Printf("终端[%s]通道[%d]观众处理不及时，已断开", key.Phone, key.Channel)
+		b.removeLocked(viewer)
+		return false
+	}
+}
+
+// removeLocked 移除观众并关闭其数据通道，调用者持有b.mtx
+func (b *broadcaster) removeLocked(viewer *Viewer) {
+	if _, ok := b.viewers[viewer]; !ok {
+		return
+	}
+	delete(b.viewers, viewer)
+	close(viewer.data)
+}
+
+// Viewer FLV观众
+type Viewer struct {
+	data    chan []byte
+	leave   func(*Viewer)
+	started bool   // 是否已收到关键帧，由broadcaster.mtx保护
+	base    uint32 // 第一个标签的时间戳
+	once    sync.Once
+}
+
+// Data FLV数据通道，第一块为FLV文件头；观众处理不及时或会话结束时通道关闭
+func (v *Viewer) Data() <-chan []byte {
+	return v.data
+}
+
+// Close 离开，可重复调用
+func (v *Viewer) Close() {
+	v.once.Do(func() {
+		v.leave(v)
+	})
+}
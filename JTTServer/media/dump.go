@@ -0,0 +1,85 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"io"
+	"time"
+)
+
+// ReadDump 读取录制的JT/T1078原始流（终端推送的TCP字节流），逐个回调RTP包。
+//
+// 回调中的RTP包数据体只在回调期间有效；帧头标识错乱的数据被跳过。
+func ReadDump(r io.Reader, handle func(*protocol.RTPPacket) error) error {
+	buf := make([]byte, 0, protocol.RTPMaxPacketSize*4)
+	chunk := make([]byte, protocol.RTPMaxPacketSize)
+
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		mark := 0
+		for mark < len(buf) {
+			packet, l, perr := protocol.ParseRTPPacket(buf[mark:])
+			if nil != perr {
+				idx := protocol.IndexRTPMagic(buf[mark+1:])
+				if idx < 0 {
+					mark = len(buf)
+					break
+				}
+				mark += idx + 1
+				continue
+			}
+			if 0 == l {
+				break
+			}
+			if herr := handle(packet); nil != herr {
+				return herr
+			}
+			mark += l
+		}
+		buf = append(buf[:0], buf[mark:]...)
+
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+	}
+}
+
+// PushDump 模拟终端，将录制的JT/T1078原始流逐包写入w（UDP连接时一包一个数据报）。
+//
+// phone、channel不为空时改写RTP包中的手机号和逻辑通道号；pace为true时按时间戳间隔发送。
+func PushDump(w io.Writer, r io.Reader, phone string, channel byte, pace bool) error {
+	var buf bytes.Buffer
+	var lastTimestamp uint64
+	var lastSent time.Time
+
+	return ReadDump(r, func(packet *protocol.RTPPacket) error {
+		if "" != phone {
+			packet.Phone = phone
+		}
+		if 0 != channel {
+			packet.Channel = channel
+		}
+
+		// 按时间戳控制发送节奏
+		if pace && packet.IsVideo() && packet.Timestamp > lastTimestamp {
+			if !lastSent.IsZero() && 0 != lastTimestamp {
+				wait := time.Duration(packet.Timestamp-lastTimestamp)*time.Millisecond - time.Since(lastSent)
+				if wait > 0 && wait < time.Second*5 {
+					time.Sleep(wait)
+				}
+			}
+			lastTimestamp = packet.Timestamp
+			lastSent = time.Now()
+		}
+
+		buf.Reset()
+		packet.WriteTo(&buf)
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+}
@@ -0,0 +1,251 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"encoding/binary"
+)
+
+// FLV标签类型
+const (
+	flvTagAudio = 0x08
+	flvTagVideo = 0x09
+)
+
+// FLV文件头，包含音频、视频，后接PreviousTagSize0
+var flvHeader = []byte{'F', 'L', 'V', 0x01, 0x05, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x00}
+
+// flvTag 编码后的FLV标签，含PreviousTagSize
+type flvTag struct {
+	data      []byte
+	timestamp uint32
+	video     bool
+	key       bool // 视频关键帧
+	header    bool // AVC/AAC序列头
+}
+
+// bytes 获取时间戳减去base后的标签数据，base为0时返回共享数据
+func (t *flvTag) bytes(base uint32) []byte {
+	if 0 == base {
+		return t.data
+	}
+	data := append([]byte(nil), t.data...)
+	putFlvTimestamp(data[4:8], t.timestamp-base)
+	return data
+}
+
+// newFlvTag 编码FLV标签
+func newFlvTag(tagType byte, timestamp uint32, body ...[]byte) []byte {
+	size := 0
+	for _, b := range body {
+		size += len(b)
+	}
+
+	data := make([]byte, 11, 11+size+4)
+	data[0] = tagType
+	data[1] = byte(size >> 16)
+	data[2] = byte(size >> 8)
+	data[3] = byte(size)
+	putFlvTimestamp(data[4:8], timestamp)
+	for _, b := range body {
+		data = append(data, b...)
+	}
+
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(11+size))
+	return append(data, value...)
+}
+
+// 时间戳低24位在前，高8位为扩展时间戳
+func putFlvTimestamp(b []byte, timestamp uint32) {
+	b[0] = byte(timestamp >> 16)
+	b[1] = byte(timestamp >> 8)
+	b[2] = byte(timestamp)
+	b[3] = byte(timestamp >> 24)
+}
+
+// flvMuxer 将JT/T1078音视频帧封装为FLV标签，支持H.264、AAC（ADTS）与G.711，一个直播会话一个，不支持并发调用
+type flvMuxer struct {
+	based       bool
+	base        uint64 // 第一帧的时间戳
+	sps, pps    []byte
+	videoHeader *flvTag // AVC序列头
+	audioHeader *flvTag // AAC序列头
+	audioConfig []byte  // AAC AudioSpecificConfig
+}
+
+func newFlvMuxer() *flvMuxer {
+	return &flvMuxer{}
+}
+
+// hasVideo 是否已收到视频序列头
+func (m *flvMuxer) hasVideo() bool {
+	return nil != m.videoHeader
+}
+
+// headers 当前的序列头标签
+func (m *flvMuxer) headers() []*flvTag {
+	var tags []*flvTag
+	if nil != m.videoHeader {
+		tags = append(tags, m.videoHeader)
+	}
+	if nil != m.audioHeader {
+		tags = append(tags, m.audioHeader)
+	}
+	return tags
+}
+
+// mux 封装一帧，序列头变化时先返回新的序列头标签，不支持的编码返回空
+func (m *flvMuxer) mux(frame *Frame) []*flvTag {
+	if !m.based {
+		m.based = true
+		m.base = frame.Timestamp
+	}
+	timestamp := uint32(0)
+	if frame.Timestamp > m.base {
+		timestamp = uint32(frame.Timestamp - m.base)
+	}
+
+	switch {
+	case frame.IsVideo() && protocol.PayloadTypeH264 == frame.PayloadType:
+		return m.muxH264(frame, timestamp)
+	case frame.IsAudio() && protocol.PayloadTypeAAC == frame.PayloadType:
+		return m.muxAAC(frame, timestamp)
+	case frame.IsAudio() && protocol.PayloadTypeG711A == frame.PayloadType:
+		return []*flvTag{{data: newFlvTag(flvTagAudio, timestamp, []byte{0x72}, frame.Data), timestamp: timestamp}}
+	case frame.IsAudio() && protocol.PayloadTypeG711U == frame.PayloadType:
+		return []*flvTag{{data: newFlvTag(flvTagAudio, timestamp, []byte{0x82}, frame.Data), timestamp: timestamp}}
+	}
+	return nil
+}
+
+func (m *flvMuxer) muxH264(frame *Frame, timestamp uint32) []*flvTag {
+	var tags []*flvTag
+	var payload bytes.Buffer
+	var sps, pps []byte
+	key := frame.IsKeyFrame()
+
+	size := make([]byte, 4)
+	for _, nalu := range splitAnnexB(frame.Data) {
+		switch nalu[0] & 0x1F {
+		case 7:
+			sps = nalu
+			continue
+		case 8:
+			pps = nalu
+			continue
+		case 9:
+			continue
+		case 5:
+			key = true
+		}
+		binary.BigEndian.PutUint32(size, uint32(len(nalu)))
+		payload.Write(size)
+		payload.Write(nalu)
+	}
+
+	// 参数集变化时更新序列头
+	if nil != sps && nil != pps && len(sps) >= 4 && (!bytes.Equal(sps, m.sps) || !bytes.Equal(pps, m.pps)) {
+		m.sps = append([]byte(nil), sps...)
+		m.pps = append([]byte(nil), pps...)
+
+		record := []byte{0x01, sps[1], sps[2], sps[3], 0xFF, 0xE1, byte(len(sps) >> 8), byte(len(sps))}
+		record = append(record, sps...)
+		record = append(record, 0x01, byte(len(pps)>>8), byte(len(pps)))
+		record = append(record, pps...)
+
+		m.videoHeader = &flvTag{
+			data:      newFlvTag(flvTagVideo, timestamp, []byte{0x17, 0x00, 0x00, 0x00, 0x00}, record),
+			timestamp: timestamp,
+			video:     true,
+			header:    true,
+		}
+		tags = append(tags, m.videoHeader)
+	}
+
+	if nil == m.videoHeader || 0 == payload.Len() {
+		return tags
+	}
+
+	flag := byte(0x27)
+	if key {
+		flag = 0x17
+	}
+	return append(tags, &flvTag{
+		data:      newFlvTag(flvTagVideo, timestamp, []byte{flag, 0x01, 0x00, 0x00, 0x00}, payload.Bytes()),
+		timestamp: timestamp,
+		video:     true,
+		key:       key,
+	})
+}
+
+func (m *flvMuxer) muxAAC(frame *Frame, timestamp uint32) []*flvTag {
+	var tags []*flvTag
+
+	data := frame.Data
+	for len(data) >= 7 {
+		// ADTS头
+		if 0xFF != data[0] || 0xF0 != data[1]&0xF0 {
+			break
+		}
+		headerSize := 7
+		if 0 == data[1]&0x01 {
+			headerSize = 9
+		}
+		frameSize := int(data[3]&0x03)<<11 | int(data[4])<<3 | int(data[5])>>5
+		if frameSize < headerSize || frameSize > len(data) {
+			break
+		}
+
+		objectType := data[2]>>6 + 1
+		frequency := (data[2] >> 2) & 0x0F
+		channels := (data[2]&0x01)<<2 | data[3]>>6
+		config := []byte{objectType<<3 | frequency>>1, frequency<<7 | channels<<3}
+		if !bytes.Equal(config, m.audioConfig) {
+			m.audioConfig = config
+			m.audioHeader = &flvTag{
+				data:      newFlvTag(flvTagAudio, timestamp, []byte{0xAF, 0x00}, config),
+				timestamp: timestamp,
+				header:    true,
+			}
+			tags = append(tags, m.audioHeader)
+		}
+
+		tags = append(tags, &flvTag{
+			data:      newFlvTag(flvTagAudio, timestamp, []byte{0xAF, 0x01}, data[headerSize:frameSize]),
+			timestamp: timestamp,
+		})
+		data = data[frameSize:]
+	}
+
+	return tags
+}
+
+// splitAnnexB 按起始码00 00 01或00 00 00 01拆分H.264 Annex B码流
+func splitAnnexB(data []byte) [][]byte {
+	var nalus [][]byte
+
+	start := -1
+	for idx := 0; idx+2 < len(data); {
+		if 0 != data[idx] || 0 != data[idx+1] || 1 != data[idx+2] {
+			idx++
+			continue
+		}
+		if start >= 0 {
+			end := idx
+			if end > start && 0 == data[end-1] {
+				end--
+			}
+			if end > start {
+				nalus = append(nalus, data[start:end])
+			}
+		}
+		idx += 3
+		start = idx
+	}
+	if start >= 0 && start < len(data) {
+		nalus = append(nalus, data[start:])
+	}
+
+	return nalus
+}
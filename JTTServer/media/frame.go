@@ -0,0 +1,150 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"strings"
+)
+
+// Frame 重组后的完整音视频帧
+type Frame struct {
+	// 终端手机号，去掉前导0
+	Phone string
+	// 逻辑通道号
+	Channel byte
+	// 数据类型，0-视频I帧，1-视频P帧，2-视频B帧，3-音频帧，4-透传数据
+	DataType byte
+	// 负载类型，见protocol.PayloadTypeXXX
+	PayloadType byte
+	// 时间戳，单位ms
+	Timestamp uint64
+	// 帧数据，视频为H.264/H.265 Annex B码流，音频为去掉海思头的裸数据
+	Data []byte
+}
+
+// IsVideo 是否是视频帧
+func (f *Frame) IsVideo() bool {
+	return f.DataType <= protocol.RTPDataTypeBFrame
+}
+
+// IsAudio 是否是音频帧
+func (f *Frame) IsAudio() bool {
+	return protocol.RTPDataTypeAudio == f.DataType
+}
+
+// IsKeyFrame 是否是视频关键帧
+func (f *Frame) IsKeyFrame() bool {
+	return protocol.RTPDataTypeIFrame == f.DataType
+}
+
+// StreamKey 音视频流标识，终端手机号与逻辑通道号
+type StreamKey struct {
+	Phone   string
+	Channel byte
+}
+
+// NewStreamKey 新建音视频流标识，手机号去掉前导0，使12位与20位手机号一致
+func NewStreamKey(phone string, channel byte) StreamKey {
+	return StreamKey{
+		Phone:   strings.TrimLeft(phone, "0"),
+		Channel: channel,
+	}
+}
+
+// 分包重组键，同一通道的音频、视频分别重组
+type partialKey struct {
+	stream StreamKey
+	audio  bool
+}
+
+// 未完成的分包帧
+type partial struct {
+	frame    Frame        // 帧信息取自第一个分包
+	sequence uint16       // 最后收到的包序号
+	data     bytes.Buffer // 已收到的数据
+}
+
+// 重组帧的最大长度，超出时丢弃该帧，避免异常推流耗尽内存
+const maxFrameSize = 4 << 20
+
+// assembler RTP分包重组器，一个连接（或UDP来源地址）一个，不支持并发调用
+type assembler struct {
+	partials map[partialKey]*partial
+	dropped  uint64 // 因丢包、乱序或帧过大丢弃的RTP包数
+}
+
+func newAssembler() *assembler {
+	return &assembler{
+		partials: make(map[partialKey]*partial),
+	}
+}
+
+// push 加入一个RTP包，组成完整帧时返回帧，帧数据为独立拷贝
+func (a *assembler) push(packet *protocol.RTPPacket) *Frame {
+	stream := NewStreamKey(packet.Phone, packet.Channel)
+
+	if protocol.RTPSubpackageAtomic == packet.Subpackage {
+		frame := newFrame(stream, packet)
+		frame.Data = trimAudioHeader(&frame, append([]byte(nil), packet.Body...))
+		return &frame
+	}
+
+	key := partialKey{stream: stream, audio: packet.IsAudio()}
+	p := a.partials[key]
+
+	if protocol.RTPSubpackageFirst == packet.Subpackage {
+		if nil == p {
+			p = &partial{}
+			a.partials[key] = p
+		} else if p.data.Len() > 0 {
+			// 上一帧未收到最后一个分包
+			a.dropped++
+		}
+		p.frame = newFrame(stream, packet)
+		p.sequence = packet.Sequence
+		p.data.Reset()
+		p.data.Write(packet.Body)
+		return nil
+	}
+
+	// 中间包或最后一个包，必须紧接上一个分包，帧过大时丢弃
+	if nil == p || 0 == p.data.Len() || p.sequence+1 != packet.Sequence || p.data.Len()+len(packet.Body) > maxFrameSize {
+		a.dropped++
+		if nil != p {
+			p.data.Reset()
+		}
+		return nil
+	}
+	p.sequence = packet.Sequence
+	p.data.Write(packet.Body)
+
+	if protocol.RTPSubpackageLast != packet.Subpackage {
+		return nil
+	}
+
+	frame := p.frame
+	frame.Data = trimAudioHeader(&frame, append([]byte(nil), p.data.Bytes()...))
+	p.data.Reset()
+	return &frame
+}
+
+func newFrame(stream StreamKey, packet *protocol.RTPPacket) Frame {
+	return Frame{
+		Phone:       stream.Phone,
+		Channel:     stream.Channel,
+		DataType:    packet.DataType,
+		PayloadType: packet.PayloadType,
+		Timestamp:   packet.Timestamp,
+	}
+}
+
+// trimAudioHeader 去掉音频帧的海思头（00 01 长度/2 00），其他帧原样返回
+func trimAudioHeader(frame *Frame, data []byte) []byte {
+	if !frame.IsAudio() || len(data) < 4 {
+		return data
+	}
+	if 0x00 == data[0] && 0x01 == data[1] && 0x00 == data[3] && int(data[2])*2 == len(data)-4 {
+		return data[4:]
+	}
+	return data
+}
@@ -0,0 +1,104 @@
+package media
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Hub 音视频流分发中心，按（手机号，逻辑通道号）将帧分发给订阅者
+type Hub struct {
+	mtx     sync.RWMutex
+	streams map[StreamKey]map[*Subscriber]struct{}
+}
+
+// NewHub 新建音视频流分发中心
+func NewHub() *Hub {
+	return &Hub{
+		streams: make(map[StreamKey]map[*Subscriber]struct{}),
+	}
+}
+
+// Subscribe 订阅指定终端通道的音视频帧，size为帧缓存个数，订阅者处理不及时时丢弃新帧
+func (h *Hub) Subscribe(phone string, channel byte, size int) *Subscriber {
+	key := NewStreamKey(phone, channel)
+	s := &Subscriber{
+		key:    key,
+		hub:    h,
+		frames: make(chan *Frame, size),
+	}
+
+	h.mtx.Lock()
+	subscribers := h.streams[key]
+	if nil == subscribers {
+		subscribers = make(map[*Subscriber]struct{})
+		h.streams[key] = subscribers
+	}
+	subscribers[s] = struct{}{}
+	h.mtx.Unlock()
+
+	return s
+}
+
+// Subscribers 获取指定终端通道的订阅者个数
+func (h *Hub) Subscribers(phone string, channel byte) int {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.streams[NewStreamKey(phone, channel)])
+}
+
+// Publish 将帧分发给该终端通道的所有订阅者，不会阻塞
+func (h *Hub) Publish(frame *Frame) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	for s := range h.streams[StreamKey{Phone: frame.Phone, Channel: frame.Channel}] {
+		select {
+		case s.frames <- frame:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+func (h *Hub) unsubscribe(s *Subscriber) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	subscribers := h.streams[s.key]
+	delete(subscribers, s)
+	if 0 == len(subscribers) {
+		delete(h.streams, s.key)
+	}
+	close(s.frames)
+}
+
+// Subscriber 音视频流订阅者，帧在订阅者之间共享，不可修改
+type Subscriber struct {
+	key     StreamKey
+	hub     *Hub
+	frames  chan *Frame
+	dropped uint64 // 因缓存已满丢弃的帧数
+	once    sync.Once
+}
+
+// Key 订阅的音视频流标识
+func (s *Subscriber) Key() StreamKey {
+	return s.key
+}
+
+// Frames 帧通道，订阅关闭后通道关闭
+func (s *Subscriber) Frames() <-chan *Frame {
+	return s.frames
+}
+
+// Dropped 因缓存已满丢弃的帧数
+func (s *Subscriber) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close 取消订阅，可重复调用
+func (s *Subscriber) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s)
+	})
+}
@@ -0,0 +1,156 @@
+package media
+
+import (
+	"common/protocol"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// LiveApp 默认的直播服务，由SetupLive初始化
+	LiveApp *Live
+)
+
+// SetupLive 使用默认音视频接入服务初始化直播服务
+//
+// media.SetupLive(jtt.Send, media.LiveConfig{IP: "1.2.3.4", TCPPort: 1078})
+func SetupLive(send Sender, config LiveConfig) {
+	LiveApp = NewLive(MediaApp.Hub(), send, config)
+}
+
+// Sender 向终端发送消息，终端不在线时返回错误
+type Sender func(phone string, output protocol.Output) error
+
+// LiveConfig 直播服务配置
+type LiveConfig struct {
+	// 音视频接入服务IP地址，下发给终端
+	IP string
+	// 音视频接入服务TCP端口号
+	TCPPort uint16
+	// 音视频接入服务UDP端口号
+	UDPPort uint16
+	// 码流类型：0-主码流，1-子码流
+	StreamType byte
+	// 最后一个观众离开后，等待多久关闭终端推流，默认10秒
+	IdleGrace time.Duration
+	// 每个观众缓存的FLV标签个数，观众处理不及时时被断开，默认256
+	ViewerBuffer int
+}
+
+// Live 直播服务，将终端推送的JT/T1078音视频流封装为FLV分发给多个观众
+type Live struct {
+	hub      *Hub
+	send     Sender
+	config   LiveConfig
+	mtx      sync.Mutex
+	sessions map[StreamKey]*liveSession
+}
+
+// NewLive 新建直播服务，send用于下发实时音视频传输请求与控制
+func NewLive(hub *Hub, send Sender, config LiveConfig) *Live {
+	if 0 == config.IdleGrace {
+		config.IdleGrace = time.Second * 10
+	}
+	if 0 == config.ViewerBuffer {
+		config.ViewerBuffer = 256
+	}
+
+	return &Live{
+		hub:      hub,
+		send:     send,
+		config:   config,
+		sessions: make(map[StreamKey]*liveSession),
+	}
+}
+
+// Watch 观看指定终端通道的直播，第一个观众打开时向终端下发实时音视频传输请求
+func (l *Live) Watch(phone string, channel byte) (*Viewer, error) {
+	key := NewStreamKey(phone, channel)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	session := l.sessions[key]
+	if nil == session {
+		sub := l.hub.Subscribe(key.Phone, key.Channel, l.config.ViewerBuffer)
+
+		request := protocol.NewMsgRealMediaRequest()
+		request.IP = l.config.IP
+		request.TCPPort = l.config.TCPPort
+		request.UDPPort = l.config.UDPPort
+		request.Channel = key.Channel
+		request.MediaType = 0
+		request.StreamType = l.config.StreamType
+		if err := l.send(key.Phone, request); nil != err {
+			sub.Close()
+			return nil, err
+		}
+
+		session = &liveSession{
+			key:         key,
+			broadcaster: newBroadcaster(sub, l.config.ViewerBuffer),
+		}
+		l.sessions[key] = session
+	}
+
+	if nil != session.idle {
+		session.idle.Stop()
+		session.idle = nil
+	}
+
+	return session.join(func(viewer *Viewer) {
+		l.leave(session, viewer)
+	}), nil
+}
+
+// Viewers 获取指定终端通道的观众个数
+func (l *Live) Viewers(phone string, channel byte) int {
+	l.mtx.Lock()
+	session := l.sessions[NewStreamKey(phone, channel)]
+	l.mtx.Unlock()
+
+	if nil == session {
+		return 0
+	}
+	return session.count()
+}
+
+// leave 观众离开，最后一个观众离开时开始空闲计时
+func (l *Live) leave(session *liveSession, viewer *Viewer) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if 0 == session.remove(viewer) && l.sessions[session.key] == session && nil == session.idle {
+		session.idle = time.AfterFunc(l.config.IdleGrace, func() {
+			l.expire(session)
+		})
+	}
+}
+
+// expire 空闲计时结束，关闭终端推流
+func (l *Live) expire(session *liveSession) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if 0 != session.count() || l.sessions[session.key] != session {
+		return
+	}
+	delete(l.sessions, session.key)
+	session.close()
+
+	control := protocol.NewMsgRealMediaControl()
+	control.Channel = session.key.Channel
+	control.Command = 0
+	control.Operation = 0
+	if err := l.send(session.key.Phone, control); nil != err {
+		log.Printf("关闭终端[%s]通道[%d]音视频传输失败：%s", session.key.Phone, session.key.Channel, err)
+	}
+}
+
+// liveSession 一个终端通道的直播会话
+type liveSession struct {
+	*broadcaster
+	key  StreamKey
+	idle *time.Timer // 空闲计时，由live.mtx保护
+}
@@ -0,0 +1,187 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testSPS = []byte{0x67, 0x42, 0x00, 0x1E, 0xAB}
+	testPPS = []byte{0x68, 0xCE, 0x38, 0x80}
+)
+
+func testKeyFrame(timestamp uint64) *Frame {
+	data := append([]byte{0, 0, 0, 1}, testSPS...)
+	data = append(data, 0, 0, 0, 1)
+	data = append(data, testPPS...)
+	data = append(data, 0, 0, 1, 0x65, 0x88, 0x84)
+	return &Frame{Phone: "13912345678", Channel: 1, DataType: protocol.RTPDataTypeIFrame, PayloadType: protocol.PayloadTypeH264, Timestamp: timestamp, Data: data}
+}
+
+func testPFrame(timestamp uint64) *Frame {
+	return &Frame{Phone: "13912345678", Channel: 1, DataType: protocol.RTPDataTypePFrame, PayloadType: protocol.PayloadTypeH264, Timestamp: timestamp, Data: []byte{0, 0, 0, 1, 0x41, 0x9A}}
+}
+
+func TestFlvMuxer(t *testing.T) {
+	m := newFlvMuxer()
+
+	// 序列头之前的P帧被丢弃
+	if tags := m.mux(testPFrame(1000)); 0 != len(tags) {
+		t.Fatalf("got %d tags before sequence header", len(tags))
+	}
+
+	tags := m.mux(testKeyFrame(1040))
+	if 2 != len(tags) || !tags[0].header || !tags[1].key {
+		t.Fatalf("unexpected tags: %d", len(tags))
+	}
+
+	record := []byte{0x09, 0x00, 0x00, 0x19, 0x00, 0x00, 0x28, 0x00, 0x00, 0x00, 0x00,
+		0x17, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x42, 0x00, 0x1E, 0xFF, 0xE1, 0x00, 0x05, 0x67, 0x42, 0x00, 0x1E, 0xAB, 0x01, 0x00, 0x04, 0x68, 0xCE, 0x38, 0x80,
+		0x00, 0x00, 0x00, 0x24}
+	if !bytes.Equal(tags[0].data, record) {
+		t.Fatalf("unexpected sequence header: %x", tags[0].data)
+	}
+
+	nalu := []byte{0x17, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x65, 0x88, 0x84}
+	if !bytes.Equal(tags[1].data[11:len(tags[1].data)-4], nalu) || 40 != tags[1].timestamp {
+		t.Fatalf("unexpected key frame: %x", tags[1].data)
+	}
+
+	// 参数集不变时不重复输出序列头
+	if tags := m.mux(testKeyFrame(1080)); 1 != len(tags) || 0x17 != tags[0].data[11] {
+		t.Fatal("unexpected sequence header")
+	}
+
+	// 时间戳重定基准
+	tag := tags[1].bytes(40)
+	if !bytes.Equal(tag[4:8], []byte{0, 0, 0, 0}) || !bytes.Equal(tags[1].data[4:8], []byte{0, 0, 40, 0}) {
+		t.Fatalf("unexpected timestamp: %x", tag[4:8])
+	}
+}
+
+func TestFlvMuxerAudio(t *testing.T) {
+	m := newFlvMuxer()
+
+	// AAC-LC 44100Hz 双声道，两个ADTS帧
+	adts := func(payload ...byte) []byte {
+		size := 7 + len(payload)
+		return append([]byte{0xFF, 0xF1, 0x50, 0x80 | byte(size>>11), byte(size >> 3), byte(size<<5) | 0x1F, 0xFC}, payload...)
+	}
+	data := append(adts(0x21, 0x10), adts(0x21, 0x20, 0x30)...)
+
+	tags := m.mux(&Frame{DataType: protocol.RTPDataTypeAudio, PayloadType: protocol.PayloadTypeAAC, Data: data})
+	if 3 != len(tags) || !tags[0].header {
+		t.Fatalf("got %d tags", len(tags))
+	}
+	if !bytes.Equal(tags[0].data[11:15], []byte{0xAF, 0x00, 0x12, 0x10}) {
+		t.Fatalf("unexpected AudioSpecificConfig: %x", tags[0].data[11:15])
+	}
+	if !bytes.Equal(tags[2].data[11:16], []byte{0xAF, 0x01, 0x21, 0x20, 0x30}) {
+		t.Fatalf("unexpected raw frame: %x", tags[2].data)
+	}
+
+	tags = m.mux(&Frame{DataType: protocol.RTPDataTypeAudio, PayloadType: protocol.PayloadTypeG711A, Data: []byte{0xD5}})
+	if 1 != len(tags) || 0x72 != tags[0].data[11] {
+		t.Fatal("unexpected G.711A tag")
+	}
+}
+
+// 记录下发给终端的消息
+type testSender struct {
+	mtx     sync.Mutex
+	outputs []protocol.Output
+}
+
+func (s *testSender) send(phone string, output protocol.Output) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.outputs = append(s.outputs, output)
+	return nil
+}
+
+func (s *testSender) sent() []protocol.Output {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]protocol.Output(nil), s.outputs...)
+}
+
+func receive(t *testing.T, viewer *Viewer) []byte {
+	select {
+	case data := <-viewer.Data():
+		return data
+	case <-time.After(time.Second):
+		t.Fatal("data not received")
+	}
+	return nil
+}
+
+func TestLive(t *testing.T) {
+	hub := NewHub()
+	sender := &testSender{}
+	live := NewLive(hub, sender.send, LiveConfig{IP: "10.0.0.1", TCPPort: 1078, IdleGrace: time.Millisecond * 50})
+
+	first, err := live.Watch("013912345678", 1)
+	if nil != err {
+		t.Fatal(err)
+	}
+	second, err := live.Watch("13912345678", 1)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	outputs := sender.sent()
+	if 1 != len(outputs) {
+		t.Fatalf("got %d outputs, want 1", len(outputs))
+	}
+	if request, ok := outputs[0].(*protocol.MsgRealMediaRequest); !ok || "10.0.0.1" != request.IP || 1 != request.Channel {
+		t.Fatalf("unexpected request: %+v", outputs[0])
+	}
+
+	hub.Publish(testPFrame(0))
+	hub.Publish(testKeyFrame(40))
+	hub.Publish(testPFrame(80))
+
+	for _, viewer := range []*Viewer{first, second} {
+		if !bytes.Equal(receive(t, viewer), flvHeader) {
+			t.Fatal("FLV header expected")
+		}
+		if 0x00 != receive(t, viewer)[12] {
+			t.Fatal("sequence header expected")
+		}
+		if key := receive(t, viewer); 0x17 != key[11] || 0 != key[6] {
+			t.Fatalf("key frame expected: %x", key)
+		}
+		if p := receive(t, viewer); 0x27 != p[11] || 40 != p[6] {
+			t.Fatalf("P frame expected: %x", p)
+		}
+	}
+
+	// 最后一个观众离开后，空闲期内重新观看不重复请求
+	first.Close()
+	second.Close()
+	third, err := live.Watch("13912345678", 1)
+	if nil != err {
+		t.Fatal(err)
+	}
+	third.Close()
+	third.Close()
+	if 1 != len(sender.sent()) {
+		t.Fatal("request must not be sent again")
+	}
+
+	time.Sleep(time.Millisecond * 200)
+	outputs = sender.sent()
+	if 2 != len(outputs) {
+		t.Fatalf("got %d outputs, want 2", len(outputs))
+	}
+	if control, ok := outputs[1].(*protocol.MsgRealMediaControl); !ok || 1 != control.Channel || 0 != control.Command {
+		t.Fatalf("unexpected control: %+v", outputs[1])
+	}
+	if 0 != hub.Subscribers("13912345678", 1) || 0 != live.Viewers("13912345678", 1) {
+		t.Fatal("session must be closed")
+	}
+}
@@ -0,0 +1,178 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"net"
+	"testing"
+	"time"
+)
+
+// 录制的推流数据：一个分为3包的H.264关键帧，一个带海思头的G.711A音频帧，一个P帧
+func testDump() ([]byte, []byte) {
+	nalu := append([]byte{0, 0, 0, 1, 0x65}, bytes.Repeat([]byte{0xAB}, 2000)...)
+	packets := []protocol.RTPPacket{
+		{PayloadType: protocol.PayloadTypeH264, Sequence: 1, DataType: protocol.RTPDataTypeIFrame, Subpackage: protocol.RTPSubpackageFirst, Body: nalu[:900]},
+		{PayloadType: protocol.PayloadTypeH264, Sequence: 2, DataType: protocol.RTPDataTypeIFrame, Subpackage: protocol.RTPSubpackageMiddle, Body: nalu[900:1800]},
+		{PayloadType: protocol.PayloadTypeH264, Sequence: 3, DataType: protocol.RTPDataTypeIFrame, Subpackage: protocol.RTPSubpackageLast, Body: nalu[1800:]},
+		{PayloadType: protocol.PayloadTypeG711A, Sequence: 4, DataType: protocol.RTPDataTypeAudio, Timestamp: 20, Body: append([]byte{0x00, 0x01, 0x02, 0x00}, 0xD5, 0xD5, 0xD5, 0xD5)},
+		{Marker: true, PayloadType: protocol.PayloadTypeH264, Sequence: 5, DataType: protocol.RTPDataTypePFrame, Timestamp: 40, Body: []byte{0, 0, 0, 1, 0x41}},
+	}
+
+	var buf bytes.Buffer
+	for idx := range packets {
+		packets[idx].Phone = "013912345678"
+		packets[idx].Channel = 1
+		packets[idx].WriteTo(&buf)
+	}
+	return buf.Bytes(), nalu
+}
+
+func TestAssembler(t *testing.T) {
+	dump, nalu := testDump()
+	a := newAssembler()
+
+	var frames []*Frame
+	err := ReadDump(bytes.NewReader(dump), func(packet *protocol.RTPPacket) error {
+		if frame := a.push(packet); nil != frame {
+			frames = append(frames, frame)
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+	if !frames[0].IsKeyFrame() || !bytes.Equal(frames[0].Data, nalu) || frames[0].Phone != "13912345678" {
+		t.Fatalf("unexpected key frame: %+v", frames[0].Phone)
+	}
+	if !frames[1].IsAudio() || !bytes.Equal(frames[1].Data, []byte{0xD5, 0xD5, 0xD5, 0xD5}) {
+		t.Fatalf("unexpected audio frame: %x", frames[1].Data)
+	}
+	if frames[2].DataType != protocol.RTPDataTypePFrame || frames[2].Timestamp != 40 {
+		t.Fatalf("unexpected frame: %+v", frames[2])
+	}
+}
+
+func TestAssemblerLoss(t *testing.T) {
+	dump, _ := testDump()
+	a := newAssembler()
+
+	// 丢掉中间包，关键帧不完整被丢弃
+	var frames []*Frame
+	ReadDump(bytes.NewReader(dump), func(packet *protocol.RTPPacket) error {
+		if 2 == packet.Sequence {
+			return nil
+		}
+		if frame := a.push(packet); nil != frame {
+			frames = append(frames, frame)
+		}
+		return nil
+	})
+
+	if len(frames) != 2 || frames[0].IsVideo() || a.dropped != 1 {
+		t.Fatalf("got %d frames, dropped %d", len(frames), a.dropped)
+	}
+}
+
+func TestAssemblerOversize(t *testing.T) {
+	a := newAssembler()
+	body := make([]byte, 900)
+	packet := protocol.RTPPacket{Phone: "13912345678", Channel: 1, DataType: protocol.RTPDataTypeIFrame, Subpackage: protocol.RTPSubpackageFirst, Body: body}
+	a.push(&packet)
+
+	// 一直不发送最后一个分包，超出最大帧长度后丢弃
+	packet.Subpackage = protocol.RTPSubpackageMiddle
+	for idx := 0; idx < maxFrameSize/len(body); idx++ {
+		packet.Sequence++
+		a.push(&packet)
+	}
+	p := a.partials[partialKey{stream: NewStreamKey("13912345678", 1)}]
+	if 0 != p.data.Len() || 1 != a.dropped {
+		t.Fatalf("got %d buffered bytes, dropped %d", p.data.Len(), a.dropped)
+	}
+
+	packet.Sequence++
+	packet.Subpackage = protocol.RTPSubpackageLast
+	if nil != a.push(&packet) {
+		t.Fatal("the oversized frame must not be assembled")
+	}
+}
+
+func TestDownlinkTakeover(t *testing.T) {
+	s := NewServer(NewHub())
+	key := NewStreamKey("13912345678", 1)
+	owner, other := &streamHandler{}, &streamHandler{}
+	write := func(*protocol.RTPPacket) error { return nil }
+
+	if !s.setDownlink(key, owner, "10.0.0.1:5000", write) {
+		t.Fatal("the first connection must own the stream")
+	}
+	// 其他地址的连接不能接管
+	if s.setDownlink(key, other, "10.0.0.2:5000", write) || owner != s.downlinks[key].owner {
+		t.Fatal("a connection from another host must not take over the stream")
+	}
+	// 终端重连
+	if !s.setDownlink(key, other, "10.0.0.1:5001", write) || other != s.downlinks[key].owner {
+		t.Fatal("a reconnect from the same host must take over the stream")
+	}
+	// 原连接断开后可以接管
+	s.removeDownlinks(map[StreamKey]struct{}{key: {}}, other)
+	if !s.setDownlink(key, owner, "10.0.0.2:5000", write) {
+		t.Fatal("a free stream must be taken over")
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	s := NewServer(NewHub())
+	go s.ServeUDP(conn)
+	defer conn.Close()
+
+	sub := s.Hub().Subscribe("13912345678", 1, 16)
+	defer sub.Close()
+	other := s.Hub().Subscribe("13912345678", 2, 16)
+	defer other.Close()
+
+	// 模拟终端推流
+	terminal, err := net.Dial("udp", conn.LocalAddr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer terminal.Close()
+
+	dump, nalu := testDump()
+	if err := PushDump(terminal, bytes.NewReader(dump), "", 0, false); nil != err {
+		t.Fatal(err)
+	}
+
+	for idx := 0; idx < 3; idx++ {
+		select {
+		case frame := <-sub.Frames():
+			if 0 == idx && !bytes.Equal(frame.Data, nalu) {
+				t.Fatal("unexpected key frame")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d not received", idx)
+		}
+	}
+
+	if 0 != len(other.Frames()) {
+		t.Fatal("frames must not be published to other channels")
+	}
+
+	sub.Close()
+	if _, ok := <-sub.Frames(); ok {
+		t.Fatal("frames channel must be closed")
+	}
+	if 1 != s.Hub().Subscribers("013912345678", 2) {
+		t.Fatal("unexpected subscribers")
+	}
+}
@@ -0,0 +1,367 @@
+package media
+
+import (
+	"JTTServer/terminal"
+	"common/protocol"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// PlaybackApp 默认的录像回放服务，由SetupPlayback初始化
+	PlaybackApp *Playback
+)
+
+var (
+	// ErrPlaybackBusy 该终端通道正在回放录像
+	ErrPlaybackBusy = errors.New("the channel is busy with another playback")
+	// ErrPlaybackNotFound 回放会话不存在或已结束
+	ErrPlaybackNotFound = errors.New("the playback session does not exist")
+)
+
+// SetupPlayback 使用默认音视频接入服务初始化录像回放服务
+func SetupPlayback(request terminal.Requester, config LiveConfig) {
+	PlaybackApp = NewPlayback(MediaApp.Hub(), request, config)
+}
+
+// RecordQuery 录像查询条件
+type RecordQuery struct {
+	// 逻辑通道号，0表示所有通道
+	Channel byte `json:"channel"`
+	// 开始时间，为空时不限
+	STime time.Time `json:"start_time"`
+	// 结束时间，为空时不限
+	ETime time.Time `json:"end_time"`
+	// 报警标志，0表示不限
+	Alarm uint64 `json:"alarm"`
+	// 音视频类型：0-音视频，1-音频，2-视频,3-视频或音视频
+	MediaType byte `json:"media_type"`
+	// 码流类型，0-所有码流，1-主码流，2-子码流
+	StreamType byte `json:"stream_type"`
+	// 存储器类型，0-所有存储器，1-主存储器，2-灾备存储器
+	StorageType byte `json:"storage_type"`
+}
+
+// 回放控制，对应0x9202回放控制字段
+const (
+	PlaybackResume      = byte(0) // 开始（恢复）回放
+	PlaybackPause       = byte(1) // 暂停回放
+	PlaybackStop        = byte(2) // 结束回放
+	PlaybackFastForward = byte(3) // 快进回放
+	PlaybackRewind      = byte(4) // 关键帧快退回放
+	PlaybackSeek        = byte(5) // 拖动回放
+	PlaybackKeyFrame    = byte(6) // 关键帧播放
+)
+
+// PlaybackControl 回放控制请求
+type PlaybackControl struct {
+	// 回放控制，见PlaybackXXX
+	Operation byte `json:"operation"`
+	// 快进/快退倍数，1-1倍，2-2倍，3-4倍，4-8倍，5-16倍
+	Multiple byte `json:"multiple"`
+	// 拖动位置，拖动回放时有效
+	Seek time.Time `json:"seek"`
+}
+
+// Playback 录像回放服务，查询终端录像并将回放流封装为FLV分发给观众。
+//
+// 同一终端通道同一时间只有一个回放会话，多个观众可观看同一会话，回放控制按会话ID进行并依次下发。
+type Playback struct {
+	hub      *Hub
+	request  terminal.Requester
+	config   LiveConfig
+	mtx      sync.Mutex
+	sessions map[string]*playbackSession
+	channels map[StreamKey]*playbackSession
+	sequence uint32
+}
+
+// NewPlayback 新建录像回放服务，config中的IP、端口下发给终端，IdleGrace为最后一个观众离开后结束回放的等待时间
+func NewPlayback(hub *Hub, request terminal.Requester, config LiveConfig) *Playback {
+	if 0 == config.IdleGrace {
+		config.IdleGrace = time.Second * 10
+	}
+	if 0 == config.ViewerBuffer {
+		config.ViewerBuffer = 256
+	}
+
+	return &Playback{
+		hub:      hub,
+		request:  request,
+		config:   config,
+		sessions: make(map[string]*playbackSession),
+		channels: make(map[StreamKey]*playbackSession),
+	}
+}
+
+// Query 查询终端录像（0x9205），返回终端上传的音视频资源列表（0x1205）
+func (p *Playback) Query(phone string, query RecordQuery) ([]protocol.MediaResource, error) {
+	msg := protocol.NewMsgMediaResourceSelect()
+	msg.Channel = query.Channel
+	if !query.STime.IsZero() {
+		msg.STime = query.STime.In(terminal.CST)
+	}
+	if !query.ETime.IsZero() {
+		msg.ETime = query.ETime.In(terminal.CST)
+	}
+	msg.Alarm = query.Alarm
+	msg.MediaType = query.MediaType
+	msg.StreamType = query.StreamType
+	msg.StorageType = query.StorageType
+
+	input, err := p.request(phone, msg, terminal.RequestTimeout, protocol.MsgIDMediaResourceListReport)
+	if nil != err {
+		return nil, err
+	}
+
+	list := input.(*protocol.MsgMediaResourceList).List
+	if nil == list {
+		list = []protocol.MediaResource{}
+	}
+	return list, nil
+}
+
+// Start 开始回放终端录像（0x9201），resource为Query返回的资源，其中的逻辑通道号必须有效。
+//
+// 该通道已有回放会话时返回ErrPlaybackBusy。
+func (p *Playback) Start(phone string, resource protocol.MediaResource) (PlaybackInfo, error) {
+	key := NewStreamKey(phone, resource.Channel)
+
+	p.mtx.Lock()
+	if _, ok := p.channels[key]; ok {
+		p.mtx.Unlock()
+		return PlaybackInfo{}, ErrPlaybackBusy
+	}
+	session := &playbackSession{
+		key: key,
+		info: PlaybackInfo{
+			ID:       fmt.Sprintf("%s-%d-%d", key.Phone, key.Channel, atomic.AddUint32(&p.sequence, 1)),
+			Phone:    key.Phone,
+			Channel:  key.Channel,
+			Resource: resource,
+			State:    PlaybackResume,
+		},
+	}
+	p.channels[key] = session
+	p.mtx.Unlock()
+
+	sub := p.hub.Subscribe(key.Phone, key.Channel, p.config.ViewerBuffer)
+
+	msg := protocol.NewMsgRemoteVideoReplay()
+	msg.IP = p.config.IP
+	msg.TCPPort = p.config.TCPPort
+	msg.UDPPort = p.config.UDPPort
+	msg.Channel = resource.Channel
+	msg.MediaType = resource.MediaType
+	msg.StreamType = resource.StreamType
+	msg.StorageType = resource.StorageType
+	msg.STime = time.Unix(int64(resource.STime), 0).In(terminal.CST)
+	msg.ETime = time.Unix(int64(resource.ETime), 0).In(terminal.CST)
+
+	// 终端以0x1205或通用应答回复回放请求
+	input, err := p.request(key.Phone, msg, terminal.RequestTimeout, protocol.MsgIDTerminalResponse, protocol.MsgIDMediaResourceListReport)
+	if nil == err {
+		err = terminal.CheckResult(input)
+	}
+	if nil != err {
+		sub.Close()
+		p.mtx.Lock()
+		delete(p.channels, key)
+		p.mtx.Unlock()
+		return PlaybackInfo{}, err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	session.broadcaster = newBroadcaster(sub, p.config.ViewerBuffer)
+	p.sessions[session.info.ID] = session
+	session.idle = time.AfterFunc(p.config.IdleGrace, func() {
+		p.expire(session)
+	})
+
+	return session.info, nil
+}
+
+// Session 获取回放会话信息
+func (p *Playback) Session(id string) (PlaybackInfo, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	session := p.sessions[id]
+	if nil == session {
+		return PlaybackInfo{}, false
+	}
+	return session.info, true
+}
+
+// Sessions 获取所有回放会话信息
+func (p *Playback) Sessions() []PlaybackInfo {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	infos := make([]PlaybackInfo, 0, len(p.sessions))
+	for _, session := range p.sessions {
+		infos = append(infos, session.info)
+	}
+	return infos
+}
+
+// Watch 观看回放会话
+func (p *Playback) Watch(id string) (*Viewer, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	session := p.sessions[id]
+	if nil == session {
+		return nil, ErrPlaybackNotFound
+	}
+	if nil != session.idle {
+		session.idle.Stop()
+		session.idle = nil
+	}
+
+	return session.join(func(viewer *Viewer) {
+		p.leave(session, viewer)
+	}), nil
+}
+
+// Control 控制回放会话（0x9202），结束回放后会话被移除
+func (p *Playback) Control(id string, control PlaybackControl) error {
+	p.mtx.Lock()
+	session := p.sessions[id]
+	p.mtx.Unlock()
+	if nil == session {
+		return ErrPlaybackNotFound
+	}
+
+	// 同一会话的控制依次下发，避免多个观众的操作交错
+	session.ctrlMtx.Lock()
+	defer session.ctrlMtx.Unlock()
+
+	p.mtx.Lock()
+	closed := p.sessions[id] != session
+	p.mtx.Unlock()
+	if closed {
+		return ErrPlaybackNotFound
+	}
+
+	if PlaybackStop == control.Operation {
+		return p.stop(session)
+	}
+
+	msg := protocol.NewMsgRemoteReplayControl()
+	msg.Channel = session.key.Channel
+	msg.Operation = control.Operation
+	msg.Multiple = control.Multiple
+	msg.Seek = control.Seek.In(terminal.CST)
+
+	input, err := p.request(session.key.Phone, msg, terminal.RequestTimeout)
+	if nil == err {
+		err = terminal.CheckResult(input)
+	}
+	if nil != err {
+		return err
+	}
+
+	p.mtx.Lock()
+	switch control.Operation {
+	case PlaybackSeek:
+		// 拖动后保持原有回放方式
+	case PlaybackFastForward, PlaybackRewind:
+		session.info.State = control.Operation
+		session.info.Multiple = control.Multiple
+	default:
+		session.info.State = control.Operation
+		session.info.Multiple = 0
+	}
+	p.mtx.Unlock()
+
+	return nil
+}
+
+// Stop 结束回放会话
+func (p *Playback) Stop(id string) error {
+	return p.Control(id, PlaybackControl{Operation: PlaybackStop})
+}
+
+// stop 下发结束回放并移除会话，调用者持有session.ctrlMtx
+func (p *Playback) stop(session *playbackSession) error {
+	p.mtx.Lock()
+	if p.sessions[session.info.ID] != session {
+		p.mtx.Unlock()
+		return ErrPlaybackNotFound
+	}
+	delete(p.sessions, session.info.ID)
+	delete(p.channels, session.key)
+	if nil != session.idle {
+		session.idle.Stop()
+		session.idle = nil
+	}
+	session.info.State = PlaybackStop
+	p.mtx.Unlock()
+
+	session.close()
+
+	msg := protocol.NewMsgRemoteReplayControl()
+	msg.Channel = session.key.Channel
+	msg.Operation = PlaybackStop
+	_, err := p.request(session.key.Phone, msg, terminal.RequestTimeout)
+	return err
+}
+
+// leave 观众离开，最后一个观众离开时开始空闲计时
+func (p *Playback) leave(session *playbackSession, viewer *Viewer) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if 0 == session.remove(viewer) && p.sessions[session.info.ID] == session && nil == session.idle {
+		session.idle = time.AfterFunc(p.config.IdleGrace, func() {
+			p.expire(session)
+		})
+	}
+}
+
+// expire 空闲计时结束，结束回放
+func (p *Playback) expire(session *playbackSession) {
+	session.ctrlMtx.Lock()
+	defer session.ctrlMtx.Unlock()
+
+	p.mtx.Lock()
+	idle := 0 == session.count() && p.sessions[session.info.ID] == session
+	p.mtx.Unlock()
+
+	if idle {
+		if err := p.stop(session); nil != err {
+			log.Printf("结束终端[%s]通道[%d]录像回放失败：%s", session.key.Phone, session.key.Channel, err)
+		}
+	}
+}
+
+// PlaybackInfo 录像回放会话信息
+type PlaybackInfo struct {
+	// 会话ID
+	ID string `json:"id"`
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 逻辑通道号
+	Channel byte `json:"channel"`
+	// 回放的录像
+	Resource protocol.MediaResource `json:"resource"`
+	// 回放状态，见PlaybackXXX
+	State byte `json:"state"`
+	// 快进/快退倍数
+	Multiple byte `json:"multiple"`
+}
+
+// playbackSession 录像回放会话
+type playbackSession struct {
+	*broadcaster
+	key     StreamKey
+	info    PlaybackInfo // 由Playback.mtx保护
+	idle    *time.Timer  // 空闲计时，由Playback.mtx保护
+	ctrlMtx sync.Mutex   // 控制指令依次下发
+}
@@ -0,0 +1,126 @@
+package media
+
+import (
+	"JTTServer/terminal"
+	"common/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 模拟终端应答录像查询与回放控制
+type testRequester struct {
+	mtx     sync.Mutex
+	outputs []protocol.Output
+	list    []protocol.MediaResource
+}
+
+func (r *testRequester) request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.outputs = append(r.outputs, output)
+
+	switch output.(type) {
+	case *protocol.MsgMediaResourceSelect, *protocol.MsgRemoteVideoReplay:
+		return &protocol.MsgMediaResourceList{List: r.list}, nil
+	default:
+		return &protocol.MsgTerminalResponse{}, nil
+	}
+}
+
+func (r *testRequester) sent() []protocol.Output {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append([]protocol.Output(nil), r.outputs...)
+}
+
+func TestPlayback(t *testing.T) {
+	hub := NewHub()
+	requester := &testRequester{
+		list: []protocol.MediaResource{{Channel: 1, STime: 1634610030, ETime: 1634610630, MediaType: 2, StreamType: 1, StorageType: 1}},
+	}
+	playback := NewPlayback(hub, requester.request, LiveConfig{IP: "10.0.0.1", TCPPort: 1078, IdleGrace: time.Millisecond * 50})
+
+	list, err := playback.Query("013912345678", RecordQuery{Channel: 1, STime: time.Unix(1634610000, 0), ETime: time.Unix(1634613600, 0)})
+	if nil != err || 1 != len(list) {
+		t.Fatalf("unexpected records: %v %v", list, err)
+	}
+	query := requester.sent()[0].(*protocol.MsgMediaResourceSelect)
+	if "211019102000" != query.STime.Format("060102150405") {
+		t.Fatalf("query time must be in CST: %s", query.STime)
+	}
+
+	info, err := playback.Start("013912345678", list[0])
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := playback.Start("13912345678", list[0]); ErrPlaybackBusy != err {
+		t.Fatalf("got %v, want ErrPlaybackBusy", err)
+	}
+	replay := requester.sent()[1].(*protocol.MsgRemoteVideoReplay)
+	if 1 != replay.Channel || "211019102030" != replay.STime.Format("060102150405") || "10.0.0.1" != replay.IP {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+
+	first, err := playback.Watch(info.ID)
+	if nil != err {
+		t.Fatal(err)
+	}
+	second, err := playback.Watch(info.ID)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	hub.Publish(testKeyFrame(0))
+	for _, viewer := range []*Viewer{first, second} {
+		receive(t, viewer)
+		receive(t, viewer)
+		if key := receive(t, viewer); 0x17 != key[11] {
+			t.Fatalf("key frame expected: %x", key)
+		}
+	}
+
+	if err := playback.Control(info.ID, PlaybackControl{Operation: PlaybackFastForward, Multiple: 3}); nil != err {
+		t.Fatal(err)
+	}
+	if state, _ := playback.Session(info.ID); PlaybackFastForward != state.State || 3 != state.Multiple {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	seek := time.Date(2021, 10, 19, 10, 25, 0, 0, terminal.CST)
+	if err := playback.Control(info.ID, PlaybackControl{Operation: PlaybackSeek, Seek: seek}); nil != err {
+		t.Fatal(err)
+	}
+	if state, _ := playback.Session(info.ID); PlaybackFastForward != state.State {
+		t.Fatal("seek must keep the playback mode")
+	}
+	if control := requester.sent()[3].(*protocol.MsgRemoteReplayControl); PlaybackSeek != control.Operation || !seek.Equal(control.Seek) {
+		t.Fatalf("unexpected control: %+v", control)
+	}
+
+	// 观众离开后会话保持到空闲超时
+	first.Close()
+	if err := playback.Control(info.ID, PlaybackControl{Operation: PlaybackPause}); nil != err {
+		t.Fatal(err)
+	}
+	second.Close()
+
+	time.Sleep(time.Millisecond * 200)
+	if _, ok := playback.Session(info.ID); ok {
+		t.Fatal("session must be stopped")
+	}
+	outputs := requester.sent()
+	if stop := outputs[len(outputs)-1].(*protocol.MsgRemoteReplayControl); PlaybackStop != stop.Operation {
+		t.Fatalf("unexpected control: %+v", stop)
+	}
+	if ErrPlaybackNotFound != playback.Control(info.ID, PlaybackControl{Operation: PlaybackResume}) {
+		t.Fatal("stopped session must not be controlled")
+	}
+	if 0 != hub.Subscribers("13912345678", 1) {
+		t.Fatal("subscription must be closed")
+	}
+
+	// 结束后可重新回放
+	if _, err := playback.Start("13912345678", list[0]); nil != err {
+		t.Fatal(err)
+	}
+}
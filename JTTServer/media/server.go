@@ -0,0 +1,280 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/transport/tcp"
+)
+
+var (
+	// MediaApp 默认的JT/T1078音视频接入服务
+	MediaApp *Server
+)
+
+func init() {
+	MediaApp = NewServer(NewHub())
+}
+
+// Run 运行JT/T1078音视频接入服务，tcpAddr、udpAddr分别为TCP与UDP的监听地址
+//
+// media.Run("0.0.0.0:1078", "0.0.0.0:1078")
+func Run(tcpAddr, udpAddr string) {
+	go func() {
+		if err := MediaApp.ListenTCP(tcpAddr); nil != err && !isClosedErr(err) {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		if err := MediaApp.ListenUDP(udpAddr); nil != err && !isClosedErr(err) {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// Subscribe 订阅默认服务中指定终端通道的音视频帧
+func Subscribe(phone string, channel byte, size int) *Subscriber {
+	return MediaApp.Hub().Subscribe(phone, channel, size)
+}
+
+// UDP空闲来源的重组器清理间隔
+const udpIdleTimeout = time.Minute
+
+var (
+	// ErrStreamOffline 终端通道没有推流连接
+	ErrStreamOffline = errors.New("the terminal stream is not connected")
+)
+
+// Server JT/T1078音视频接入服务，终端按0x9101/0x9201指令推流到该服务
+type Server struct {
+	hub       *Hub
+	bootstrap netty.Bootstrap
+	mtx       sync.RWMutex
+	downlinks map[StreamKey]*downlink
+}
+
+// downlink 终端通道的下行通道，即终端推流所用的连接
+type downlink struct {
+	owner interface{} // 所属连接（TCP处理器或UDP来源）
+	host  string      // 所属连接的终端IP
+	write func(*protocol.RTPPacket) error
+}
+
+// NewServer 新建音视频接入服务，重组后的帧发布到hub
+func NewServer(hub *Hub) *Server {
+	s := &Server{
+		hub:       hub,
+		downlinks: make(map[StreamKey]*downlink),
+	}
+
+	s.bootstrap = netty.NewBootstrap(
+		netty.WithTransport(tcp.New()),
+		netty.WithChildInitializer(func(channel netty.Channel) {
+			channel.Pipeline().
+				AddLast(protocol.RTPCodec(protocol.RTPMaxPacketSize * 4)).
+				AddLast(&streamHandler{server: s, assembler: newAssembler(), keys: make(map[StreamKey]struct{})})
+		}),
+	)
+
+	return s
+}
+
+// Hub 获取音视频流分发中心
+func (s *Server) Hub() *Hub {
+	return s.hub
+}
+
+// ListenTCP 监听TCP推流，阻塞直到服务关闭
+func (s *Server) ListenTCP(addr string) error {
+	return s.bootstrap.Listen(addr, tcp.WithOptions(&tcp.Options{
+		Timeout:         time.Second * 3,
+		KeepAlive:       true,
+		KeepAlivePeriod: time.Second * 5,
+		NoDelay:         true,
+		SockBuf:         64 * 1024,
+	})).Sync()
+}
+
+// ListenUDP 监听UDP推流，阻塞直到服务关闭
+func (s *Server) ListenUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if nil != err {
+		return err
+	}
+	return s.ServeUDP(conn)
+}
+
+// ServeUDP 从已建立的UDP连接接收推流，每个数据报包含一个或多个完整的RTP包
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	defer conn.Close()
+
+	type source struct {
+		assembler *assembler
+		active    time.Time
+		keys      map[StreamKey]struct{}
+	}
+	sources := make(map[string]*source)
+	lastClean := time.Now()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if nil != err {
+			return err
+		}
+
+		now := time.Now()
+		src := sources[addr.String()]
+		if nil == src {
+			src = &source{assembler: newAssembler(), keys: make(map[StreamKey]struct{})}
+			sources[addr.String()] = src
+		}
+		src.active = now
+
+		bts := buf[:n]
+		for len(bts) > 0 {
+			packet, l, err := protocol.ParseRTPPacket(bts)
+			if nil != err || 0 == l {
+				break
+			}
+			key := NewStreamKey(packet.Phone, packet.Channel)
+			if _, ok := src.keys[key]; !ok {
+				to := addr
+				if s.setDownlink(key, src, addr.String(), func(packet *protocol.RTPPacket) error {
+					var buf bytes.Buffer
+					packet.WriteTo(&buf)
+					_, err := conn.WriteTo(buf.Bytes(), to)
+					return err
+				}) {
+					src.keys[key] = struct{}{}
+				}
+			}
+			if frame := src.assembler.push(packet); nil != frame {
+				s.hub.Publish(frame)
+			}
+			bts = bts[l:]
+		}
+
+		// 清理长时间没有数据的来源
+		if now.Sub(lastClean) > udpIdleTimeout {
+			for key, src := range sources {
+				if now.Sub(src.active) > udpIdleTimeout {
+					s.removeDownlinks(src.keys, src)
+					delete(sources, key)
+				}
+			}
+			lastClean = now
+		}
+	}
+}
+
+// Close 关闭TCP服务
+func (s *Server) Close() {
+	s.bootstrap.Shutdown()
+}
+
+// SendRTP 通过终端通道的推流连接下发RTP包（双向对讲等），终端未推流时返回ErrStreamOffline
+func (s *Server) SendRTP(phone string, channel byte, packet *protocol.RTPPacket) error {
+	s.mtx.RLock()
+	link := s.downlinks[NewStreamKey(phone, channel)]
+	s.mtx.RUnlock()
+
+	if nil == link {
+		return ErrStreamOffline
+	}
+	return link.write(packet)
+}
+
+// Connected 终端通道是否有推流连接
+func (s *Server) Connected(phone string, channel byte) bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return nil != s.downlinks[NewStreamKey(phone, channel)]
+}
+
+// setDownlink 设置终端通道的下行通道，返回是否设置成功。通道已属于其他连接时，
+// 只有同一终端IP的连接（终端重连）可以接管，其他连接在原连接断开或空闲超时后的推流中接管
+func (s *Server) setDownlink(key StreamKey, owner interface{}, addr string, write func(*protocol.RTPPacket) error) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); nil == err {
+		host = h
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if link := s.downlinks[key]; nil != link && link.owner != owner && link.host != host {
+		return false
+	}
+	s.downlinks[key] = &downlink{owner: owner, host: host, write: write}
+	return true
+}
+
+// removeDownlinks 移除连接的下行通道，已被新连接替换的保留
+func (s *Server) removeDownlinks(keys map[StreamKey]struct{}, owner interface{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for key := range keys {
+		if link := s.downlinks[key]; nil != link && link.owner == owner {
+			delete(s.downlinks, key)
+		}
+	}
+}
+
+// streamHandler TCP推流处理，一个连接一个
+type streamHandler struct {
+	server    *Server
+	assembler *assembler
+	keys      map[StreamKey]struct{} // 该连接推流的终端通道
+}
+
+func (h *streamHandler) HandleActive(ctx netty.ActiveContext) {
+	log.Printf("音视频终端[%s]已接入", ctx.Channel().RemoteAddr())
+	ctx.HandleActive()
+}
+
+func (h *streamHandler) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	packet := message.(*protocol.RTPPacket)
+
+	key := NewStreamKey(packet.Phone, packet.Channel)
+	if _, ok := h.keys[key]; !ok {
+		channel := ctx.Channel()
+		if h.server.setDownlink(key, h, channel.RemoteAddr(), func(packet *protocol.RTPPacket) error {
+			if !channel.Write(packet) {
+				return ErrStreamOffline
+			}
+			return nil
+		}) {
+			h.keys[key] = struct{}{}
+		}
+	}
+
+	if frame := h.assembler.push(packet); nil != frame {
+		h.server.hub.Publish(frame)
+	}
+}
+
+func (h *streamHandler) HandleInactive(ctx netty.InactiveContext, ex netty.Exception) {
+	h.server.removeDownlinks(h.keys, h)
+	log.Printf("音视频终端[%s]已断开，丢弃分包%d个", ctx.Channel().RemoteAddr(), h.assembler.dropped)
+	ctx.HandleInactive(ex)
+}
+
+func (h *streamHandler) HandleException(ctx netty.ExceptionContext, ex netty.Exception) {
+	if _, ok := ex.Unwrap().(*net.OpError); !ok && io.EOF != ex.Unwrap() {
+		log.Println(ex)
+	}
+	ctx.Close(ex)
+}
+
+func isClosedErr(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
@@ -0,0 +1,232 @@
+package media
+
+import (
+	"JTTServer/terminal"
+	"common/protocol"
+	"errors"
+	"log"
+	"sync"
+)
+
+var (
+	// TalkApp 默认的对讲监听服务，由SetupTalk初始化
+	TalkApp *Talk
+)
+
+var (
+	// ErrTalkBusy 该终端通道正在对讲或监听
+	ErrTalkBusy = errors.New("the channel is busy with another talk")
+	// ErrAudioUnsupported 终端音频编码方式不支持下行
+	ErrAudioUnsupported = errors.New("the terminal audio encoding is not supported")
+)
+
+// SetupTalk 使用默认音视频接入服务初始化对讲监听服务
+func SetupTalk(request terminal.Requester, config LiveConfig) {
+	TalkApp = NewTalk(MediaApp, request, config)
+}
+
+// 对讲监听类型，对应0x9101音视频类型
+const (
+	TalkModeTalk    = byte(2) // 双向对讲
+	TalkModeMonitor = byte(3) // 监听
+)
+
+// Talk 对讲监听服务。
+//
+// 平台以0x9101请求终端建立音频传输，终端上行音频解码为PCM交给调用方；
+// 双向对讲时调用方的PCM音频按终端音频编码方式（0x1003）编码后通过终端推流连接下发。
+type Talk struct {
+	server   *Server
+	request  terminal.Requester
+	config   LiveConfig
+	mtx      sync.Mutex
+	sessions map[StreamKey]*TalkSession
+}
+
+// NewTalk 新建对讲监听服务，config中的IP、端口下发给终端
+func NewTalk(server *Server, request terminal.Requester, config LiveConfig) *Talk {
+	if 0 == config.ViewerBuffer {
+		config.ViewerBuffer = 256
+	}
+
+	return &Talk{
+		server:   server,
+		request:  request,
+		config:   config,
+		sessions: make(map[StreamKey]*TalkSession),
+	}
+}
+
+// Open 开始对讲或监听，mode见TalkModeXXX，inputRate为调用方下行PCM的采样率
+func (t *Talk) Open(phone string, channel byte, mode byte, inputRate int) (*TalkSession, error) {
+	key := NewStreamKey(phone, channel)
+
+	t.mtx.Lock()
+	if _, ok := t.sessions[key]; ok {
+		t.mtx.Unlock()
+		return nil, ErrTalkBusy
+	}
+	session := &TalkSession{
+		talk:      t,
+		key:       key,
+		mode:      mode,
+		resampler: newResampler(inputRate),
+		audio:     make(chan []byte, t.config.ViewerBuffer),
+	}
+	t.sessions[key] = session
+	t.mtx.Unlock()
+
+	err := session.open()
+	if nil != err {
+		t.mtx.Lock()
+		delete(t.sessions, key)
+		t.mtx.Unlock()
+		return nil, err
+	}
+	return session, nil
+}
+
+// open 查询终端音频编码方式并请求终端建立音频传输
+func (s *TalkSession) open() error {
+	t := s.talk
+
+	if TalkModeTalk == s.mode {
+		input, err := t.request(s.key.Phone, protocol.NewMsgMediaProperty(), terminal.RequestTimeout, protocol.MsgIDMediaPropertyReport)
+		if nil != err {
+			return err
+		}
+		property := input.(*protocol.MsgMediaPropertyReply)
+		if !supportedAudio(property.AudioEncodeMode) {
+			return ErrAudioUnsupported
+		}
+		s.encoder.payloadType = property.AudioEncodeMode
+	}
+
+	s.sub = t.server.Hub().Subscribe(s.key.Phone, s.key.Channel, t.config.ViewerBuffer)
+
+	request := protocol.NewMsgRealMediaRequest()
+	request.IP = t.config.IP
+	request.TCPPort = t.config.TCPPort
+	request.UDPPort = t.config.UDPPort
+	request.Channel = s.key.Channel
+	request.MediaType = s.mode
+	request.StreamType = 0
+	input, err := t.request(s.key.Phone, request, terminal.RequestTimeout)
+	if nil == err {
+		err = terminal.CheckResult(input)
+	}
+	if nil != err {
+		s.sub.Close()
+		return err
+	}
+
+	go s.receive()
+	return nil
+}
+
+// TalkSession 对讲监听会话
+type TalkSession struct {
+	talk      *Talk
+	key       StreamKey
+	mode      byte
+	sub       *Subscriber
+	audio     chan []byte // 上行音频，8kHz 16位小端PCM
+	once      sync.Once
+	mtx       sync.Mutex // 保护下行编码状态
+	resampler *resampler
+	encoder   audioEncoder
+	pending   []int16 // 不足一帧的下行采样
+	sequence  uint16
+	timestamp uint64
+}
+
+// Codec 下行音频的负载类型，见protocol.PayloadTypeXXX，监听时为0
+func (s *TalkSession) Codec() byte {
+	return s.encoder.payloadType
+}
+
+// Audio 终端上行音频，8kHz单声道16位小端PCM；会话关闭后通道关闭
+func (s *TalkSession) Audio() <-chan []byte {
+	return s.audio
+}
+
+// receive 解码终端上行音频，订阅关闭后退出
+func (s *TalkSession) receive() {
+	defer close(s.audio)
+
+	for frame := range s.sub.Frames() {
+		if !frame.IsAudio() {
+			continue
+		}
+		samples := decodeAudio(frame.PayloadType, frame.Data)
+		if 0 == len(samples) {
+			continue
+		}
+		select {
+		case s.audio <- pcmBytes(samples):
+		default:
+		}
+	}
+}
+
+// Write 下发调用方的16位小端单声道PCM，按20ms分帧编码为终端音频并打包为RTP；
+// 监听时忽略，终端尚未推流时返回ErrStreamOffline并丢弃数据
+func (s *TalkSession) Write(pcm []byte) error {
+	if TalkModeTalk != s.mode {
+		return nil
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.pending = append(s.pending, s.resampler.resample(pcmSamples(pcm))...)
+
+	var err error
+	for len(s.pending) >= audioFrameSamples {
+		data := s.encoder.encode(s.pending[:audioFrameSamples])
+		s.pending = s.pending[audioFrameSamples:]
+
+		// 海思音频头：00 01 数据长度/2 00
+		body := append([]byte{0x00, 0x01, byte(len(data) / 2), 0x00}, data...)
+		packet := &protocol.RTPPacket{
+			Marker:      true,
+			PayloadType: s.encoder.payloadType,
+			Sequence:    s.sequence,
+			Phone:       s.key.Phone,
+			Channel:     s.key.Channel,
+			DataType:    protocol.RTPDataTypeAudio,
+			Subpackage:  protocol.RTPSubpackageAtomic,
+			Timestamp:   s.timestamp,
+			Body:        body,
+		}
+		s.sequence++
+		s.timestamp += 1000 * audioFrameSamples / audioSampleRate
+
+		if e := s.talk.server.SendRTP(s.key.Phone, s.key.Channel, packet); nil != e {
+			err = e
+		}
+	}
+	s.pending = append([]int16(nil), s.pending...)
+	return err
+}
+
+// Close 结束对讲（0x9102控制指令4）或监听（控制指令0），可重复调用
+func (s *TalkSession) Close() {
+	s.once.Do(func() {
+		t := s.talk
+		t.mtx.Lock()
+		delete(t.sessions, s.key)
+		t.mtx.Unlock()
+
+		s.sub.Close()
+
+		control := protocol.NewMsgRealMediaControl()
+		control.Channel = s.key.Channel
+		if TalkModeTalk == s.mode {
+			control.Command = 4
+		}
+		if _, err := t.request(s.key.Phone, control, terminal.RequestTimeout); nil != err {
+			log.Printf("结束终端[%s]通道[%d]对讲监听失败：%s", s.key.Phone, s.key.Channel, err)
+		}
+	})
+}
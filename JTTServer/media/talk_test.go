@@ -0,0 +1,148 @@
+package media
+
+import (
+	"bytes"
+	"common/protocol"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testSine(n int) []int16 {
+	samples := make([]int16, n)
+	for idx := range samples {
+		samples[idx] = int16(8000 * math.Sin(float64(idx)*2*math.Pi*440/audioSampleRate))
+	}
+	return samples
+}
+
+func TestAudioCodec(t *testing.T) {
+	signal := testSine(audioFrameSamples * 2)
+	samples := signal[audioFrameSamples:]
+
+	for _, payloadType := range []byte{protocol.PayloadTypeG711A, protocol.PayloadTypeG711U, protocol.PayloadTypeADPCMA} {
+		// ADPCM从最小步长开始自适应，比较第二帧
+		encoder := audioEncoder{payloadType: payloadType}
+		encoder.encode(signal[:audioFrameSamples])
+		decoded := decodeAudio(payloadType, encoder.encode(samples))
+		if len(decoded) != len(samples) {
+			t.Fatalf("payload type %d: got %d samples", payloadType, len(decoded))
+		}
+		for idx := range samples {
+			if diff := math.Abs(float64(decoded[idx]) - float64(samples[idx])); diff > 600 {
+				t.Fatalf("payload type %d: sample %d differs by %f", payloadType, idx, diff)
+			}
+		}
+	}
+
+	// 已知码值
+	if 0xD5 != encodeALaw(0) || 0xFF != encodeULaw(0) || 0 != decodeULaw(0xFF) {
+		t.Fatal("unexpected G.711 silence")
+	}
+}
+
+func TestResampler(t *testing.T) {
+	r := newResampler(48000)
+	total := 0
+	for idx := 0; idx < 10; idx++ {
+		total += len(r.resample(make([]int16, 480)))
+	}
+	if total < 799 || total > 801 {
+		t.Fatalf("got %d samples, want 800", total)
+	}
+}
+
+type testTalkRequester struct {
+	mtx     sync.Mutex
+	outputs []protocol.Output
+}
+
+func (r *testTalkRequester) request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.outputs = append(r.outputs, output)
+
+	if _, ok := output.(*protocol.MsgMediaProperty); ok {
+		return &protocol.MsgMediaPropertyReply{AudioEncodeMode: protocol.PayloadTypeG711A}, nil
+	}
+	return &protocol.MsgTerminalResponse{}, nil
+}
+
+func TestTalk(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	server := NewServer(NewHub())
+	go server.ServeUDP(conn)
+	defer conn.Close()
+
+	requester := &testTalkRequester{}
+	talk := NewTalk(server, requester.request, LiveConfig{IP: "10.0.0.1", UDPPort: 1078})
+
+	session, err := talk.Open("013912345678", 1, TalkModeTalk, 16000)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := talk.Open("13912345678", 1, TalkModeTalk, 16000); ErrTalkBusy != err {
+		t.Fatalf("got %v, want ErrTalkBusy", err)
+	}
+	if request := requester.outputs[1].(*protocol.MsgRealMediaRequest); TalkModeTalk != request.MediaType {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+
+	// 终端未推流时无法下发，丢弃的帧仍占用包序号
+	if ErrStreamOffline != session.Write(make([]byte, 640)) {
+		t.Fatal("ErrStreamOffline expected")
+	}
+
+	// 终端推送上行音频
+	terminal, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer terminal.Close()
+
+	var buf bytes.Buffer
+	(&protocol.RTPPacket{PayloadType: protocol.PayloadTypeG711A, Phone: "13912345678", Channel: 1, DataType: protocol.RTPDataTypeAudio,
+		Body: append([]byte{0x00, 0x01, 0x02, 0x00}, 0xD5, 0xD5, 0xD5, 0xD5)}).WriteTo(&buf)
+	terminal.WriteTo(buf.Bytes(), conn.LocalAddr())
+
+	select {
+	case pcm := <-session.Audio():
+		if 8 != len(pcm) {
+			t.Fatalf("got %d bytes of pcm", len(pcm))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("audio not received")
+	}
+
+	// 40ms的16kHz PCM下发为2个G.711A帧
+	if err := session.Write(pcmBytes(testSine(640))); nil != err {
+		t.Fatal(err)
+	}
+	data := make([]byte, 2048)
+	for idx := 0; idx < 2; idx++ {
+		terminal.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := terminal.ReadFrom(data)
+		if nil != err {
+			t.Fatal(err)
+		}
+		packet, _, err := protocol.ParseRTPPacket(data[:n])
+		if nil != err || protocol.PayloadTypeG711A != packet.PayloadType || uint16(idx+1) != packet.Sequence || 4+audioFrameSamples != len(packet.Body) {
+			t.Fatalf("unexpected packet: %+v %v", packet, err)
+		}
+	}
+
+	session.Close()
+	session.Close()
+	outputs := requester.outputs
+	if control := outputs[len(outputs)-1].(*protocol.MsgRealMediaControl); 4 != control.Command || 1 != control.Channel {
+		t.Fatalf("unexpected control: %+v", control)
+	}
+	if _, ok := <-session.Audio(); ok {
+		t.Fatal("audio must be closed")
+	}
+}
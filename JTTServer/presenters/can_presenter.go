@@ -0,0 +1,27 @@
+package presenters
+
+import (
+	"JTTServer/canbus"
+	"JTTServer/jtt"
+	"common/protocol"
+	"log"
+)
+
+// CanPresenter CAN总线数据
+type CanPresenter struct {
+	jtt.BasePresenter
+}
+
+// CANDataReport CAN总线数据上传
+func (l *CanPresenter) CANDataReport() {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgCANDataReport); ok {
+		log.Printf("%s->%s CAN总线数据上传 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		l.Ctx.Response(resp)
+		if nil != canbus.CanApp {
+			for _, value := range canbus.CanApp.OnReport(l.Ctx.Client().Phone(), msg) {
+				log.Printf("终端[%s] CAN信号 %s.%s=%v%s", l.Ctx.Client().Phone(), value.Message, value.Signal, value.Value, value.Unit)
+			}
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package presenters
+
+import (
+	"JTTServer/contacts"
+	"JTTServer/jtt"
+	"common/protocol"
+	"log"
+)
+
+// DriverPresenter 驾驶员身份信息
+type DriverPresenter struct {
+	jtt.BasePresenter
+}
+
+// DriverIdentityReport 驾驶员身份信息上报，驾驶员插卡换车后同步电话本
+func (d *DriverPresenter) DriverIdentityReport() {
+	if msg, ok := d.Ctx.Message().(*protocol.MsgICCardReport); ok {
+		log.Printf("%s->%s 驾驶员身份信息上报 %v", d.Ctx.Client().RemoteAddr(), d.Ctx.Client().LocalAddr(), msg)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		d.Ctx.Response(resp)
+		if nil != contacts.ContactsApp {
+			contacts.ContactsApp.OnDriver(d.Ctx.Client().Phone(), msg)
+		}
+	}
+}
@@ -1,7 +1,15 @@
 package presenters
 
 import (
+	"JTTServer/attach"
+	"JTTServer/contacts"
+	"JTTServer/control"
+	"JTTServer/geofence"
+	"JTTServer/inventory"
 	"JTTServer/jtt"
+	"JTTServer/profile"
+	"JTTServer/text"
+	"common/protocol"
 	"log"
 )
 
@@ -9,34 +17,84 @@ type LoginPresenter struct {
 	jtt.BasePresenter
 }
 
+// TerminalRegister 终端注册，只通知各服务终端已注册，不应答注册
+func (l *LoginPresenter) TerminalRegister() {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgTerminalLogin); ok {
+		log.Printf("%s->%s 终端注册 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
+		if nil != inventory.InventoryApp {
+			inventory.InventoryApp.OnRegister(l.Ctx.Client().Phone(), msg)
+		}
+		if nil != geofence.GeofenceApp {
+			geofence.GeofenceApp.OnRegister(l.Ctx.Client().Phone())
+		}
+		if nil != contacts.ContactsApp {
+			contacts.ContactsApp.OnRegister(l.Ctx.Client().Phone())
+		}
+	}
+}
+
 func (l *LoginPresenter) TerminalAuth() {
-	if msg, ok := l.Ctx.Message().(*jtt.MsgTerminalAuth); ok {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgTerminalAuth); ok {
 		log.Printf("%s->%s 终端鉴权 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
-		resp := jtt.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
 		l.Ctx.Response(resp)
+		if nil != profile.ProfileApp {
+			profile.ProfileApp.OnAuth(l.Ctx.Client().Phone())
+		}
+		if nil != inventory.InventoryApp {
+			inventory.InventoryApp.OnAuth(l.Ctx.Client().Phone(), msg)
+		}
+		if nil != control.ControlApp {
+			control.ControlApp.OnAuth(l.Ctx.Client().Phone())
+		}
+		if nil != geofence.GeofenceApp {
+			geofence.GeofenceApp.OnAuth(l.Ctx.Client().Phone())
+		}
+		if nil != text.TextApp {
+			text.TextApp.OnAuth(l.Ctx.Client().Phone())
+		}
+		if nil != contacts.ContactsApp {
+			contacts.ContactsApp.OnAuth(l.Ctx.Client().Phone())
+		}
 	}
 }
 
 func (l *LoginPresenter) PositionReport() {
-	if msg, ok := l.Ctx.Message().(*jtt.MsgPositionReport); ok {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgPositionReport); ok {
 		log.Printf("%s->%s 终端位置上报 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
-		resp := jtt.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
 		l.Ctx.Response(resp)
+		if nil != attach.AttachApp {
+			attach.AttachApp.OnPosition(l.Ctx.Client().Phone(), &msg.Position)
+		}
+		if nil != geofence.GeofenceApp {
+			geofence.GeofenceApp.OnPosition(l.Ctx.Client().Phone(), &msg.Position)
+		}
 	}
 }
 
 func (l *LoginPresenter) PositionBatchReport() {
-	if msg, ok := l.Ctx.Message().(*jtt.MsgPosBatchReport); ok {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgPosBatchReport); ok {
 		log.Printf("%s->%s 终端位置批量上报 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
-		resp := jtt.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
 		l.Ctx.Response(resp)
+		if nil != attach.AttachApp {
+			for idx := range msg.Positions {
+				attach.AttachApp.OnPosition(l.Ctx.Client().Phone(), &msg.Positions[idx])
+			}
+		}
+		if nil != geofence.GeofenceApp {
+			for idx := range msg.Positions {
+				geofence.GeofenceApp.OnPosition(l.Ctx.Client().Phone(), &msg.Positions[idx])
+			}
+		}
 	}
 }
 
 func (l *LoginPresenter) TerminalHeatbeat() {
-	if msg, ok := l.Ctx.Message().(*jtt.MsgTerminalHeartbeat); ok {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgTerminalHeartbeat); ok {
 		log.Printf("%s->%s 终端心跳，%v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
-		resp := jtt.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
 		l.Ctx.Response(resp)
 	}
 }
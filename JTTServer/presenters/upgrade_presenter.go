@@ -0,0 +1,29 @@
+package presenters
+
+import (
+	"JTTServer/inventory"
+	"JTTServer/jtt"
+	"JTTServer/upgrade"
+	"common/protocol"
+	"log"
+)
+
+// UpgradePresenter 终端升级
+type UpgradePresenter struct {
+	jtt.BasePresenter
+}
+
+// TerminalUpgradeResult 终端升级结果应答
+func (u *UpgradePresenter) TerminalUpgradeResult() {
+	if msg, ok := u.Ctx.Message().(*protocol.MsgTerminalUpgradeResp); ok {
+		log.Printf("%s->%s 终端升级结果应答 %v", u.Ctx.Client().RemoteAddr(), u.Ctx.Client().LocalAddr(), msg)
+		if nil != upgrade.UpgradeApp {
+			upgrade.UpgradeApp.OnUpgradeResult(u.Ctx.Client().Phone(), msg)
+		}
+		if nil != inventory.InventoryApp {
+			inventory.InventoryApp.OnUpgradeResult(u.Ctx.Client().Phone(), msg)
+		}
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		u.Ctx.Response(resp)
+	}
+}
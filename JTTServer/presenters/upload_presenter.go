@@ -0,0 +1,25 @@
+package presenters
+
+import (
+	"JTTServer/jtt"
+	"JTTServer/upload"
+	"common/protocol"
+	"log"
+)
+
+// UploadPresenter 终端文件上传
+type UploadPresenter struct {
+	jtt.BasePresenter
+}
+
+// FileUploadFinish 终端文件上传完成通知
+func (l *UploadPresenter) FileUploadFinish() {
+	if msg, ok := l.Ctx.Message().(*protocol.MsgFileUploadFinish); ok {
+		log.Printf("%s->%s 文件上传完成通知 %v", l.Ctx.Client().RemoteAddr(), l.Ctx.Client().LocalAddr(), msg)
+		if nil != upload.UploadApp && !upload.UploadApp.Finish(l.Ctx.Client().Phone(), msg) {
+			log.Printf("终端[%s]上传完成通知未关联到任务，流水号：%d", l.Ctx.Client().Phone(), msg.ReqNum)
+		}
+		resp := protocol.NewMsgServerResponse(msg.Number, msg.ID, 0)
+		l.Ctx.Response(resp)
+	}
+}
@@ -0,0 +1,444 @@
+package profile
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ProfileApp 默认的终端参数配置服务，由Setup初始化
+	ProfileApp *Manager
+)
+
+var (
+	// ErrProfileNotFound 参数配置不存在
+	ErrProfileNotFound = errors.New("the profile does not exist")
+	// ErrInvalidProfile 参数配置无效
+	ErrInvalidProfile = errors.New("the profile is invalid")
+)
+
+// Setup 初始化默认的终端参数配置服务，offline为终端不在线时Requester返回的错误，
+// file为参数配置及下发结果的存储文件，为空时不持久化
+//
+// profile.Setup(jtt.Request, jtt.ErrClientOffline, "profiles.json")
+func Setup(request terminal.Requester, offline error, file string) error {
+	m := NewManager(request, offline, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	ProfileApp = m
+	return nil
+}
+
+// 并发下发的终端数
+const concurrency = 10
+
+// 下发结果状态
+const (
+	StateQueued   = "queued"   // 终端不在线，等待终端鉴权后下发
+	StateApplying = "applying" // 下发中
+	StateApplied  = "applied"  // 下发成功，读回的参数与配置一致
+	StateFailed   = "failed"   // 下发失败
+)
+
+// Profile 命名的终端参数配置
+type Profile struct {
+	// 配置名称
+	Name string `json:"name"`
+	// 配置说明
+	Description string `json:"description"`
+	// 参数项列表，参数值类型见protocol.ParamDefs
+	Params protocol.Params `json:"params"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// ParamChange 下发的参数变更
+type ParamChange struct {
+	// 参数id
+	ID uint32 `json:"id"`
+	// 终端原有的参数值，终端未返回时为空
+	Old interface{} `json:"old"`
+	// 下发的参数值
+	New interface{} `json:"new"`
+}
+
+// Result 终端的参数配置下发结果
+type Result struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 配置名称
+	Profile string `json:"profile"`
+	// 状态，见StateXXX
+	State string `json:"state"`
+	// 下发的参数变更，参数与配置一致时为空
+	Changes []ParamChange `json:"changes"`
+	// 读回校验不一致的参数id
+	Mismatched []uint32 `json:"mismatched,omitempty"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Manager 终端参数配置服务。
+//
+// 下发配置时先以0x8106查询配置中的参数，只以0x8103下发取值不同的参数，
+// 下发后再次查询读回校验；终端不在线时记录为排队状态，终端鉴权后自动下发。
+// 参数配置及下发结果保存在文件中，重启后排队的终端仍在鉴权后下发。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	file    string
+
+	mtx      sync.Mutex
+	profiles map[string]*Profile
+	results  map[string]*Result
+	// 在新的goroutine中进行的下发
+	applying sync.WaitGroup
+}
+
+// NewManager 新建终端参数配置服务
+func NewManager(request terminal.Requester, offline error, file string) *Manager {
+	return &Manager{
+		request:  request,
+		offline:  offline,
+		file:     file,
+		profiles: make(map[string]*Profile),
+		results:  make(map[string]*Result),
+	}
+}
+
+// SaveProfile 新建或更新参数配置，参数值按参数定义校验并转换类型
+func (m *Manager) SaveProfile(profile Profile) (Profile, error) {
+	if "" == profile.Name || 0 == len(profile.Params) {
+		return Profile{}, ErrInvalidProfile
+	}
+
+	params, err := normalize(profile.Params)
+	if nil != err {
+		return Profile{}, err
+	}
+	profile.Params = params
+	profile.Updated = time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.profiles[profile.Name] = &profile
+	m.save()
+	return profile, nil
+}
+
+// normalize 按参数定义校验参数值并转换类型
+func normalize(params protocol.Params) (protocol.Params, error) {
+	var result protocol.Params
+	for _, param := range params {
+		if _, ok := result.Get(param.ID); ok {
+			return nil, fmt.Errorf("%w: duplicate param %#04x", ErrInvalidProfile, param.ID)
+		}
+		if err := result.Set(param.ID, param.Value); nil != err {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProfile, err)
+		}
+	}
+	return result, nil
+}
+
+// Profile 获取参数配置
+func (m *Manager) Profile(name string) (Profile, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	profile, ok := m.profiles[name]
+	if !ok {
+		return Profile{}, false
+	}
+	return *profile, true
+}
+
+// Profiles 获取所有参数配置，按名称排列
+func (m *Manager) Profiles() []Profile {
+	m.mtx.Lock()
+	profiles := make([]Profile, 0, len(m.profiles))
+	for _, profile := range m.profiles {
+		profiles = append(profiles, *profile)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles
+}
+
+// DeleteProfile 删除参数配置，排队等待下发该配置的终端一并取消
+func (m *Manager) DeleteProfile(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.profiles[name]; !ok {
+		return ErrProfileNotFound
+	}
+	delete(m.profiles, name)
+	for phone, result := range m.results {
+		if name == result.Profile && StateQueued == result.State {
+			delete(m.results, phone)
+		}
+	}
+	m.save()
+	return nil
+}
+
+// Apply 向一组终端下发参数配置，在新的goroutine中执行，最多同时下发concurrency个终端，
+// 返回各终端下发中的结果，通过Result查询最终结果；终端不在线时结果为排队状态，终端鉴权后自动下发
+func (m *Manager) Apply(name string, phones []string) ([]Result, error) {
+	m.mtx.Lock()
+	profile, ok := m.profiles[name]
+	if !ok {
+		m.mtx.Unlock()
+		return nil, ErrProfileNotFound
+	}
+	results := make([]Result, len(phones))
+	for idx, phone := range phones {
+		result := Result{Phone: phone, Profile: name, State: StateApplying, Updated: time.Now()}
+		m.results[phone], results[idx] = &result, result
+	}
+	m.save()
+	m.mtx.Unlock()
+
+	m.applying.Add(1)
+	go func(profile Profile) {
+		defer m.applying.Done()
+		m.applyAll(profile, phones)
+	}(*profile)
+	return results, nil
+}
+
+// applyAll 向一组终端下发参数配置，最多同时下发concurrency个终端，完成后保存结果
+func (m *Manager) applyAll(profile Profile, phones []string) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(phones); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for phone := range jobs {
+				m.apply(phone, profile)
+			}
+		}()
+	}
+	for _, phone := range phones {
+		jobs <- phone
+	}
+	close(jobs)
+	wg.Wait()
+	m.flush()
+}
+
+// OnAuth 终端鉴权成功，下发排队的参数配置，在新的goroutine中执行；配置已删除时记为失败
+func (m *Manager) OnAuth(phone string) {
+	m.mtx.Lock()
+	var profile *Profile
+	if result, ok := m.results[phone]; ok && StateQueued == result.State {
+		if profile = m.profiles[result.Profile]; nil == profile {
+			result.State, result.Error, result.Updated = StateFailed, ErrProfileNotFound.Error(), time.Now()
+			m.save()
+		} else {
+			// 标记为下发中，避免终端重复鉴权时重复下发
+			result.State = StateApplying
+		}
+	}
+	m.mtx.Unlock()
+
+	if nil != profile {
+		m.applying.Add(1)
+		go func() {
+			defer m.applying.Done()
+			m.apply(phone, *profile)
+			m.flush()
+		}()
+	}
+}
+
+// Result 获取终端最近一次的下发结果
+func (m *Manager) Result(phone string) (Result, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	result, ok := m.results[phone]
+	if !ok {
+		return Result{}, false
+	}
+	return *result, true
+}
+
+// Results 获取所有终端最近一次的下发结果，按手机号排列
+func (m *Manager) Results() []Result {
+	m.mtx.Lock()
+	results := make([]Result, 0, len(m.results))
+	for _, result := range m.results {
+		results = append(results, *result)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Phone < results[j].Phone
+	})
+	return results
+}
+
+// apply 向终端下发参数配置：查询、比较、下发变更、读回校验，由调用方保存结果
+func (m *Manager) apply(phone string, profile Profile) Result {
+	result := Result{Phone: phone, Profile: profile.Name, State: StateApplying}
+	m.record(result)
+
+	current, err := m.query(phone, profile.Params.IDs())
+	if nil == err {
+		diff := profile.Params.Diff(current)
+		for _, param := range diff {
+			change := ParamChange{ID: param.ID, New: param.Value}
+			if old, ok := current.Get(param.ID); ok {
+				change.Old = old.Value
+			}
+			result.Changes = append(result.Changes, change)
+		}
+		if 0 != len(diff) {
+			err = m.push(phone, diff)
+		}
+		if nil == err && 0 != len(diff) {
+			// 读回校验，终端未生效的参数记为不一致
+			var readBack protocol.Params
+			if readBack, err = m.query(phone, diff.IDs()); nil == err {
+				result.Mismatched = diff.Diff(readBack).IDs()
+				if 0 != len(result.Mismatched) {
+					err = fmt.Errorf("%d params mismatched after read-back", len(result.Mismatched))
+				}
+			}
+		}
+	}
+
+	switch {
+	case nil == err:
+		result.State = StateApplied
+	case nil != m.offline && errors.Is(err, m.offline):
+		result.State, result.Changes = StateQueued, nil
+	default:
+		result.State, result.Error = StateFailed, err.Error()
+	}
+	m.record(result)
+	return result
+}
+
+// query 查询指定的终端参数
+func (m *Manager) query(phone string, ids []uint32) (protocol.Params, error) {
+	msg := protocol.NewMsgGetTerSpecParams()
+	msg.ParamIDs = ids
+	input, err := m.request(phone, msg, terminal.RequestTimeout, protocol.MsgIDGetTerminalParamsResp)
+	if nil != err {
+		return nil, err
+	}
+	resp, ok := input.(*protocol.MsgGetTerminalParamsResp)
+	if !ok {
+		return nil, terminal.ErrUnexpectedData
+	}
+	return resp.Params, nil
+}
+
+// push 下发终端参数
+func (m *Manager) push(phone string, params protocol.Params) error {
+	msg := protocol.NewMsgTerParamsSettings()
+	msg.Params = params
+	return m.request.Send(phone, msg)
+}
+
+// record 记录下发结果
+func (m *Manager) record(result Result) {
+	result.Updated = time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.results[result.Phone] = &result
+}
+
+// flush 保存当前数据
+func (m *Manager) flush() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.save()
+}
+
+// store 持久化的参数配置数据
+type store struct {
+	Profiles []Profile `json:"profiles"`
+	Results  []Result  `json:"results"`
+}
+
+// save 保存参数配置及下发结果，先写入临时文件再替换，调用方需持有锁
+func (m *Manager) save() {
+	if "" == m.file {
+		return
+	}
+
+	var s store
+	for _, profile := range m.profiles {
+		s.Profiles = append(s.Profiles, *profile)
+	}
+	for _, result := range m.results {
+		s.Results = append(s.Results, *result)
+	}
+	sort.Slice(s.Profiles, func(i, j int) bool { return s.Profiles[i].Name < s.Profiles[j].Name })
+	sort.Slice(s.Results, func(i, j int) bool { return s.Results[i].Phone < s.Results[j].Phone })
+
+	if err := util.SaveJSON(m.file, &s); nil != err {
+		log.Printf("保存终端参数配置失败：%v", err)
+	}
+}
+
+// load 加载已保存的数据，文件不存在时忽略。参数值按参数定义重新转换类型，
+// 上次退出时下发中的终端记为排队，终端鉴权后重新下发
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var s store
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for idx := range s.Profiles {
+		profile := s.Profiles[idx]
+		params, err := normalize(profile.Params)
+		if nil != err {
+			return fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		profile.Params = params
+		m.profiles[profile.Name] = &profile
+	}
+	for idx := range s.Results {
+		result := s.Results[idx]
+		if StateApplying == result.State {
+			result.State = StateQueued
+		}
+		m.results[result.Phone] = &result
+	}
+	return nil
+}
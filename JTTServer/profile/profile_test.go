@@ -0,0 +1,152 @@
+package profile
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"path/filepath"
+	"testing"
+)
+
+// fakeParams 模拟终端参数存储，readOnly中的参数设置后不生效
+type fakeParams struct {
+	params   protocol.Params
+	readOnly map[uint32]bool
+	pushed   []protocol.Params
+}
+
+func (f *fakeParams) handle(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+	switch msg := output.(type) {
+	case *protocol.MsgGetTerSpecParams:
+		resp := &protocol.MsgGetTerminalParamsResp{}
+		for _, id := range msg.ParamIDs {
+			if param, ok := f.params.Get(id); ok {
+				resp.Params = append(resp.Params, param)
+			}
+		}
+		return resp, nil
+	case *protocol.MsgTerParamsSettings:
+		f.pushed = append(f.pushed, msg.Params)
+		for _, param := range msg.Params {
+			if !f.readOnly[param.ID] {
+				f.params.Set(param.ID, param.Value)
+			}
+		}
+		return &protocol.MsgTerminalResponse{}, nil
+	}
+	return nil, terminaltest.ErrUnexpectedMessage
+}
+
+func TestApply(t *testing.T) {
+	terminal := &fakeParams{}
+	terminal.params.Set(protocol.ParamIDHeartbeat, 30)
+	terminal.params.Set(protocol.ParamIDMaxSpeed, 80)
+
+	m := NewManager(terminaltest.New(terminal.handle).Request, terminaltest.ErrOffline, "")
+	profile := Profile{Name: "city-bus"}
+	profile.Params.Set(protocol.ParamIDHeartbeat, 30)
+	profile.Params.Set(protocol.ParamIDMaxSpeed, 60)
+	profile.Params.Set(protocol.ParamIDLocTimeDefault, 10)
+	if _, err := m.SaveProfile(profile); nil != err {
+		t.Fatal(err)
+	}
+
+	results, err := m.Apply("city-bus", []string{"13912345678"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if StateApplying != results[0].State {
+		t.Fatalf("unexpected result: %+v", results[0])
+	}
+	// 只下发取值不同的参数
+	if result := waitResult(t, m, "13912345678"); StateApplied != result.State || 2 != len(result.Changes) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if 1 != len(terminal.pushed) || 2 != len(terminal.pushed[0]) {
+		t.Fatalf("unexpected push: %+v", terminal.pushed)
+	}
+
+	// 参数一致时不再下发
+	m.Apply("city-bus", []string{"13912345678"})
+	if result := waitResult(t, m, "13912345678"); StateApplied != result.State || 0 != len(result.Changes) || 1 != len(terminal.pushed) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	// 读回不一致
+	terminal.readOnly = map[uint32]bool{protocol.ParamIDMaxSpeed: true}
+	profile.Params.Set(protocol.ParamIDMaxSpeed, 50)
+	m.SaveProfile(profile)
+	m.Apply("city-bus", []string{"13912345678"})
+	if result := waitResult(t, m, "13912345678"); StateFailed != result.State || 1 != len(result.Mismatched) || protocol.ParamIDMaxSpeed != result.Mismatched[0] {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+// waitResult 等待终端的下发完成，返回最终结果
+func waitResult(t *testing.T, m *Manager, phone string) (result Result) {
+	t.Helper()
+	terminaltest.Wait(t, "profile was not applied", func() bool {
+		result, _ = m.Result(phone)
+		return StateApplying != result.State
+	})
+	return result
+}
+
+func TestApplyQueued(t *testing.T) {
+	terminal := &fakeParams{}
+	terminals := terminaltest.New(terminal.handle)
+	terminals.SetOffline("13912345678", true)
+	file := filepath.Join(t.TempDir(), "profiles.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, file)
+	// 后台下发结束后才能删除临时目录
+	t.Cleanup(m.applying.Wait)
+	profile := Profile{Name: "city-bus"}
+	profile.Params.Set(protocol.ParamIDHeartbeat, 30)
+	m.SaveProfile(profile)
+
+	m.Apply("city-bus", []string{"13912345678"})
+	if result := waitResult(t, m, "13912345678"); StateQueued != result.State {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	// 重启后排队的终端仍在鉴权后下发
+	m.applying.Wait()
+	m = NewManager(terminals.Request, terminaltest.ErrOffline, file)
+	t.Cleanup(m.applying.Wait)
+	if err := m.load(); nil != err {
+		t.Fatal(err)
+	}
+	if loaded, ok := m.Profile("city-bus"); !ok || 1 != len(loaded.Params) {
+		t.Fatalf("unexpected loaded profile: %+v", loaded)
+	}
+	if result, _ := m.Result("13912345678"); StateQueued != result.State {
+		t.Fatalf("unexpected loaded result: %+v", result)
+	}
+
+	terminals.SetOffline("13912345678", false)
+	m.OnAuth("13912345678")
+	terminaltest.Wait(t, "queued profile was not applied after auth", func() bool {
+		result, _ := m.Result("13912345678")
+		return StateApplied == result.State
+	})
+	terminals.Do(func() {
+		if v, ok := terminal.params.Uint32(protocol.ParamIDHeartbeat); !ok || 30 != v {
+			t.Fatalf("unexpected heartbeat: %v", v)
+		}
+	})
+}
+
+func TestApplyQueuedDeleted(t *testing.T) {
+	terminal := &fakeParams{}
+	terminals := terminaltest.New(terminal.handle)
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, "")
+
+	// 加载的排队结果对应的配置已不存在
+	m.results["13912345678"] = &Result{Phone: "13912345678", Profile: "city-bus", State: StateQueued}
+	m.OnAuth("13912345678")
+	if result, _ := m.Result("13912345678"); StateFailed != result.State || ErrProfileNotFound.Error() != result.Error {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if 0 != len(terminals.Received("13912345678")) {
+		t.Fatal("nothing should be sent for a deleted profile")
+	}
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"JTTServer/terminal"
+	"common/protocol"
+	"sort"
+	"time"
+)
+
+var (
+	// RecorderApp 默认的行驶记录仪数据采集服务，由Setup初始化
+	RecorderApp *Recorder
+)
+
+// Setup 初始化默认的行驶记录仪数据采集服务
+//
+// recorder.Setup(jtt.Request)
+func Setup(request terminal.Requester) {
+	RecorderApp = NewRecorder(request)
+}
+
+// 终端应答超时时间，记录数据较大时终端分包应答，需要较长时间
+const requestTimeout = time.Second * 30
+
+// 按时间范围采集时单次请求的最大数据块个数
+const collectBlocks = uint16(10)
+
+// Recorder 行驶记录仪（GB/T 19056）数据采集服务。
+//
+// 平台通过0x8700下发采集命令，终端以0x0700应答记录仪的55 7A数据帧，分包应答由协议层重组。
+// 按时间范围采集记录时，单次应答的数据块个数有上限，记录仪按时间倒序输出，
+// 因此以已收到的最早记录时间为新的结束时间继续请求，直到数据块个数不足上限。
+type Recorder struct {
+	request terminal.Requester
+}
+
+// NewRecorder 新建行驶记录仪数据采集服务
+func NewRecorder(request terminal.Requester) *Recorder {
+	return &Recorder{request: request}
+}
+
+// Query 采集单项数据（0x00~0x07），返回值类型见protocol.DecodeRecorderData
+func (r *Recorder) Query(phone string, cmd byte) (interface{}, error) {
+	return r.gather(phone, protocol.NewMsgRecorderGather(cmd, nil))
+}
+
+// Collect 采集时间范围内的记录（0x08~0x15），多次请求的结果合并后按记录时间升序排列
+func (r *Recorder) Collect(phone string, cmd byte, start, end time.Time) ([]protocol.RecorderRecord, error) {
+	var records []protocol.RecorderRecord
+	for {
+		value, err := r.gather(phone, protocol.NewMsgRecorderRange(cmd, start, end, collectBlocks))
+		if nil != err {
+			return nil, err
+		}
+		list, ok := value.([]protocol.RecorderRecord)
+		if !ok {
+			return nil, terminal.ErrUnexpectedData
+		}
+		records = append(records, list...)
+		if len(list) < int(collectBlocks) {
+			break
+		}
+
+		// 以本次最早的记录时间为新的结束时间，时间没有前移时结束，避免重复请求
+		next := end
+		for _, record := range list {
+			if t := record.RecordTime().Add(-time.Second); t.Before(next) {
+				next = t
+			}
+		}
+		if !next.Before(end) || next.Before(start) {
+			break
+		}
+		end = next
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].RecordTime().Before(records[j].RecordTime())
+	})
+	return records, nil
+}
+
+// Set 下发行驶记录参数（0x8701），data的格式与对应的采集命令相同，如设置记录仪时间使用protocol.RecorderTime
+func (r *Recorder) Set(phone string, cmd byte, data protocol.RecorderData) error {
+	input, err := r.request(phone, protocol.NewMsgRecorderSetting(cmd, data), requestTimeout,
+		protocol.MsgIDTerminalResponse, protocol.MsgIDDrivingRecordReport)
+	if nil != err {
+		return err
+	}
+
+	switch msg := input.(type) {
+	case *protocol.MsgTerminalResponse:
+		if 0 != msg.Result {
+			return terminal.ErrRejected
+		}
+	case *protocol.MsgDrivingRecordReport:
+		_, err = msg.Frame()
+	}
+	return err
+}
+
+// gather 下发采集命令并解码终端应答的数据
+func (r *Recorder) gather(phone string, msg *protocol.MsgGatherDrivingRecord) (interface{}, error) {
+	input, err := r.request(phone, msg, requestTimeout, protocol.MsgIDDrivingRecordReport)
+	if nil != err {
+		return nil, err
+	}
+
+	report, ok := input.(*protocol.MsgDrivingRecordReport)
+	if !ok {
+		return nil, terminal.ErrUnexpectedData
+	}
+	frame, err := report.Frame()
+	if nil != err {
+		return nil, err
+	}
+	if frame.CMD != msg.CMD {
+		return nil, terminal.ErrUnexpectedData
+	}
+	return protocol.DecodeRecorderData(frame.CMD, frame.Data)
+}
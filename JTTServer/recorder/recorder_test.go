@@ -0,0 +1,68 @@
+package recorder
+
+import (
+	"bytes"
+	"common/protocol"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// fakeRecorder 模拟记录仪，按时间倒序输出时间范围内的驾驶人身份记录
+type fakeRecorder struct {
+	logs     []*protocol.RecorderDriverLog
+	requests int
+}
+
+func (f *fakeRecorder) request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	f.requests++
+	msg := output.(*protocol.MsgGatherDrivingRecord)
+
+	// 命令帧数据块：开始时间、结束时间、最大数据块个数
+	data := msg.Data[6 : len(msg.Data)-1]
+	start, end := parseTime(data[:6]), parseTime(data[6:12])
+	max := int(binary.BigEndian.Uint16(data[12:]))
+
+	var records protocol.RecorderRecords
+	for idx := len(f.logs) - 1; idx >= 0 && len(records) < max; idx-- {
+		if t := f.logs[idx].Time; !t.Before(start) && !t.After(end) {
+			records = append(records, f.logs[idx])
+		}
+	}
+	return &protocol.MsgDrivingRecordReport{CMD: msg.CMD, Data: protocol.EncodeRecorderAnswer(msg.CMD, records)}, nil
+}
+
+func parseTime(bcd []byte) time.Time {
+	var value bytes.Buffer
+	for _, b := range bcd {
+		value.WriteByte('0' + b>>4)
+		value.WriteByte('0' + b&0x0F)
+	}
+	t, _ := time.ParseInLocation("060102150405", value.String(), time.FixedZone("CST", 28800))
+	return t
+}
+
+func TestCollect(t *testing.T) {
+	cst := time.FixedZone("CST", 28800)
+	start := time.Date(2021, 10, 19, 0, 0, 0, 0, cst)
+
+	f := &fakeRecorder{}
+	for idx := 0; idx < 25; idx++ {
+		f.logs = append(f.logs, &protocol.RecorderDriverLog{Time: start.Add(time.Duration(idx) * time.Minute), License: "440301199001011234", Type: protocol.RecorderDriverLogin})
+	}
+
+	r := NewRecorder(f.request)
+	records, err := r.Collect("13912345678", protocol.RecorderCmdDriverLog, start.Add(time.Minute), start.Add(time.Hour))
+	if nil != err {
+		t.Fatal(err)
+	}
+	// 24条记录分3次请求，结果按时间升序排列
+	if 24 != len(records) || 3 != f.requests {
+		t.Fatalf("got %d records in %d requests", len(records), f.requests)
+	}
+	for idx, record := range records {
+		if want := start.Add(time.Duration(idx+1) * time.Minute); !record.RecordTime().Equal(want) {
+			t.Fatalf("record %d: got %v, want %v", idx, record.RecordTime(), want)
+		}
+	}
+}
@@ -4,15 +4,74 @@ import (
 	"JTTServer/controllers"
 	"JTTServer/jtt"
 	"JTTServer/presenters"
+	"common/protocol"
 
 	beego "github.com/beego/beego/v2/server/web"
 )
 
 func init() {
 	beego.Router("/", &controllers.MainController{})
+	beego.Router("/live/:phone/:channel", &controllers.LiveController{}, "get:Flv")
+	beego.Router("/playback/sessions", &controllers.PlaybackController{}, "get:Sessions")
+	beego.Router("/playback/sessions/:id", &controllers.PlaybackController{}, "get:Flv;delete:Stop")
+	beego.Router("/playback/sessions/:id/control", &controllers.PlaybackController{}, "post:Control")
+	beego.Router("/playback/:phone/records", &controllers.PlaybackController{}, "get:Records")
+	beego.Router("/playback/:phone", &controllers.PlaybackController{}, "post:Start")
+	beego.Router("/talk/:phone/:channel", &controllers.TalkController{}, "get:Talk")
+	beego.Router("/uploads", &controllers.UploadController{}, "get:Tasks")
+	beego.Router("/uploads/:id", &controllers.UploadController{}, "get:Task")
+	beego.Router("/uploads/:id/control", &controllers.UploadController{}, "post:Control")
+	beego.Router("/uploads/:phone", &controllers.UploadController{}, "post:Start")
+	beego.Router("/attachments", &controllers.AttachController{}, "get:Alarms")
+	beego.Router("/attachments/:alarm_no", &controllers.AttachController{}, "get:Alarm")
+	beego.Router("/recorders/:phone/:cmd", &controllers.RecorderController{}, "get:Gather")
+	beego.Router("/can/:phone", &controllers.CanController{}, "get:Latest")
+	beego.Router("/profiles", &controllers.ProfileController{}, "get:Profiles")
+	beego.Router("/profiles/results", &controllers.ProfileController{}, "get:Results")
+	beego.Router("/profiles/results/:phone", &controllers.ProfileController{}, "get:Result")
+	beego.Router("/profiles/:name", &controllers.ProfileController{}, "get:Profile;put:Save;delete:Delete")
+	beego.Router("/profiles/:name/apply", &controllers.ProfileController{}, "post:Apply")
+	beego.Router("/firmwares", &controllers.UpgradeController{}, "get:Firmwares;post:AddFirmware")
+	beego.Router("/firmwares/:id", &controllers.UpgradeController{}, "get:Firmware;delete:DeleteFirmware")
+	beego.Router("/upgrades", &controllers.UpgradeController{}, "get:Campaigns;post:StartCampaign")
+	beego.Router("/upgrades/:id", &controllers.UpgradeController{}, "get:Campaign")
+	beego.Router("/upgrades/:id/control", &controllers.UpgradeController{}, "post:Control")
+	beego.Router("/terminals", &controllers.InventoryController{}, "get:Terminals")
+	beego.Router("/terminals/:phone", &controllers.InventoryController{}, "get:Terminal")
+	beego.Router("/terminals/:phone/refresh", &controllers.InventoryController{}, "post:Refresh")
+	beego.Router("/terminals/:phone/geofences", &controllers.GeofenceController{}, "get:Terminal;post:Assign")
+	beego.Router("/terminals/:phone/geofences/reconcile", &controllers.GeofenceController{}, "post:Reconcile")
+	beego.Router("/geofences", &controllers.GeofenceController{}, "get:Fences;post:Save")
+	beego.Router("/geofences/events", &controllers.GeofenceController{}, "get:Events")
+	beego.Router("/geofences/geojson", &controllers.GeofenceController{}, "get:Export;post:Import")
+	beego.Router("/geofences/:kind/:id", &controllers.GeofenceController{}, "get:Fence;delete:Delete")
+	beego.Router("/texts", &controllers.TextController{}, "get:Dispatches;post:Send")
+	beego.Router("/texts/templates", &controllers.TextController{}, "get:Templates;post:SaveTemplate")
+	beego.Router("/texts/templates/:name", &controllers.TextController{}, "delete:DeleteTemplate")
+	beego.Router("/texts/groups", &controllers.TextController{}, "get:Groups;post:SaveGroup")
+	beego.Router("/texts/groups/:name", &controllers.TextController{}, "delete:DeleteGroup")
+	beego.Router("/texts/:id", &controllers.TextController{}, "get:Dispatch")
+	beego.Router("/contacts/fleets", &controllers.ContactsController{}, "get:Fleets;post:SaveFleet")
+	beego.Router("/contacts/fleets/:name", &controllers.ContactsController{}, "get:Fleet;delete:DeleteFleet")
+	beego.Router("/contacts/fleets/:name/contacts", &controllers.ContactsController{}, "post:Apply")
+	beego.Router("/contacts/drivers", &controllers.ContactsController{}, "get:Drivers;post:SaveDriver")
+	beego.Router("/contacts/drivers/:credential", &controllers.ContactsController{}, "delete:DeleteDriver")
+	beego.Router("/contacts/terminals", &controllers.ContactsController{}, "get:Terminals")
+	beego.Router("/terminals/:phone/contacts", &controllers.ContactsController{}, "get:Terminal")
+	beego.Router("/terminals/:phone/contacts/sync", &controllers.ContactsController{}, "post:Sync")
+	beego.Router("/terminals/:phone/callback", &controllers.ContactsController{}, "post:Callback")
+	beego.Router("/controls", &controllers.ControlController{}, "get:Entries")
+	beego.Router("/controls/:phone", &controllers.ControlController{}, "post:Execute")
+	beego.Router("/vehicles/controls", &controllers.VehicleController{}, "get:Defs")
+	beego.Router("/vehicles/:phone/control", &controllers.VehicleController{}, "get:Result;post:Control")
 
-	jtt.Router(jtt.MsgIDTerminalAuth, &presenters.LoginPresenter{}, "TerminalAuth")
-	jtt.Router(jtt.MsgIDPositionReport, &presenters.LoginPresenter{}, "PositionReport")
-	jtt.Router(jtt.MsgIDPositionBatchReport, &presenters.LoginPresenter{}, "PositionBatchReport")
-	jtt.Router(jtt.MsgIDTerminalHeartbeat, &presenters.LoginPresenter{}, "TerminalHeatbeat")
+	jtt.Router(protocol.MsgIDTerminalLogin, &presenters.LoginPresenter{}, "TerminalRegister")
+	jtt.Router(protocol.MsgIDTerminalAuth, &presenters.LoginPresenter{}, "TerminalAuth")
+	jtt.Router(protocol.MsgIDPositionReport, &presenters.LoginPresenter{}, "PositionReport")
+	jtt.Router(protocol.MsgIDPositionBatchReport, &presenters.LoginPresenter{}, "PositionBatchReport")
+	jtt.Router(protocol.MsgIDTerminalHeartbeat, &presenters.LoginPresenter{}, "TerminalHeatbeat")
+	jtt.Router(protocol.MsgIDFileUploadFinish, &presenters.UploadPresenter{}, "FileUploadFinish")
+	jtt.Router(protocol.MsgIDCANDataReport, &presenters.CanPresenter{}, "CANDataReport")
+	jtt.Router(protocol.MsgIDDriverIdentityReport, &presenters.DriverPresenter{}, "DriverIdentityReport")
+	jtt.Router(protocol.MsgIDTerminalUpgradeResp, &presenters.UpgradePresenter{}, "TerminalUpgradeResult")
 }
@@ -0,0 +1,44 @@
+// Package terminal 业务服务与终端交互的公共定义
+package terminal
+
+import (
+	"common/protocol"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRejected 终端应答失败
+	ErrRejected = errors.New("the terminal rejected the request")
+	// ErrUnexpectedData 终端应答的消息类型不符
+	ErrUnexpectedData = errors.New("unexpected response from the terminal")
+)
+
+// Requester 向终端发送消息并等待应答，replyIDs为可接受的应答消息ID，为空时等待终端通用应答
+type Requester func(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error)
+
+// RequestTimeout 终端应答超时时间
+const RequestTimeout = time.Second * 10
+
+// Send 下发消息并等待终端通用应答，终端应答失败时返回ErrRejected
+func (r Requester) Send(phone string, output protocol.Output) error {
+	input, err := r(phone, output, RequestTimeout)
+	if nil != err {
+		return err
+	}
+	if _, ok := input.(*protocol.MsgTerminalResponse); !ok {
+		return ErrUnexpectedData
+	}
+	return CheckResult(input)
+}
+
+// CST 终端时间的时区，协议中的BCD时间均为北京时间
+var CST = time.FixedZone("CST", 28800)
+
+// CheckResult 检查终端通用应答结果，应答失败时返回ErrRejected，其他应答消息不检查
+func CheckResult(input protocol.Input) error {
+	if resp, ok := input.(*protocol.MsgTerminalResponse); ok && 0 != resp.Result {
+		return ErrRejected
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package terminaltest 业务服务测试用的模拟终端
+package terminaltest
+
+import (
+	"common/protocol"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	// ErrOffline 模拟终端不在线时返回的错误
+	ErrOffline = errors.New("offline")
+	// ErrUnexpectedMessage 模拟终端不处理的消息返回的错误
+	ErrUnexpectedMessage = errors.New("unexpected message")
+)
+
+// Handler 模拟终端处理平台消息并返回应答，在Terminals的锁内执行
+type Handler func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error)
+
+// Terminals 模拟一组终端，记录在线终端收到的消息并交由Handler应答，不在线的终端返回ErrOffline
+type Terminals struct {
+	mtx      sync.Mutex
+	handle   Handler
+	offline  map[string]bool
+	received map[string][]protocol.Output
+}
+
+// New 新建模拟终端，handle为空时终端以通用应答成功应答所有消息
+func New(handle Handler) *Terminals {
+	return &Terminals{
+		handle:   handle,
+		offline:  make(map[string]bool),
+		received: make(map[string][]protocol.Output),
+	}
+}
+
+// Request 向模拟终端发送消息并返回应答，签名与terminal.Requester一致
+func (t *Terminals) Request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.offline[phone] {
+		return nil, ErrOffline
+	}
+	t.received[phone] = append(t.received[phone], output)
+	if nil == t.handle {
+		return &protocol.MsgTerminalResponse{}, nil
+	}
+	return t.handle(phone, output, replyIDs)
+}
+
+// SetOffline 设置终端是否在线
+func (t *Terminals) SetOffline(phone string, offline bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.offline[phone] = offline
+}
+
+// Received 终端在线时收到的消息
+func (t *Terminals) Received(phone string) []protocol.Output {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return append([]protocol.Output{}, t.received[phone]...)
+}
+
+// Do 在锁内执行fn，用于读写Handler使用的模拟终端状态
+func (t *Terminals) Do(fn func()) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	fn()
+}
+
+// Wait 等待done返回true，5秒后仍未满足时以msg结束测试
+func Wait(t testing.TB, msg string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second * 5); !done(); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+	}
+}
@@ -0,0 +1,718 @@
+package text
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"bytes"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var (
+	// TextApp 默认的文本信息服务，由Setup初始化
+	TextApp *Manager
+)
+
+var (
+	// ErrTemplateNotFound 模板不存在
+	ErrTemplateNotFound = errors.New("the template does not exist")
+	// ErrGroupNotFound 分组不存在
+	ErrGroupNotFound = errors.New("the group does not exist")
+	// ErrNoTarget 没有下发的终端
+	ErrNoTarget = errors.New("no terminal to send to")
+)
+
+// Setup 初始化默认的文本信息服务，offline为终端不在线时Requester返回的错误，
+// online获取在线终端手机号，file为模板、分组及下发记录的存储文件，为空时不持久化
+//
+// text.Setup(jtt.Request, jtt.ErrClientOffline, jtt.OnlinePhones, "texts.json")
+func Setup(request terminal.Requester, offline error, online func() []string, file string) error {
+	m := NewManager(request, offline, online, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	TextApp = m
+	return nil
+}
+
+const (
+	// 并发下发的终端数
+	concurrency = 10
+	// 单个终端的最大下发次数，终端不在线不计入
+	maxAttempts = 3
+	// 应答超时后首次重发的等待时间，之后每次加倍
+	retryDelay = time.Second * 30
+	// 默认的离线重发有效期
+	defaultExpire = time.Hour * 24
+	// 保留的下发记录数
+	maxDispatches = 500
+)
+
+// 终端接收状态
+const (
+	DeliveryPending   = "pending"   // 下发中
+	DeliveryWaiting   = "waiting"   // 终端不在线，终端鉴权后重发
+	DeliveryRetrying  = "retrying"  // 终端应答超时，等待后重发
+	DeliveryDelivered = "delivered" // 终端应答成功
+	DeliveryRejected  = "rejected"  // 终端应答失败、消息有误或不支持
+	DeliveryFailed    = "failed"    // 多次应答超时
+	DeliveryExpired   = "expired"   // 有效期内终端未上线
+)
+
+// Template 文本模板，文本为text/template格式，如“{{.plate}}请于{{.time}}前返回”
+type Template struct {
+	// 模板名称
+	Name string `json:"name"`
+	// 模板文本
+	Text string `json:"text"`
+	// 默认的下发选项
+	Options protocol.TextOptions `json:"options"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// render 以vars替换模板中的变量，缺少变量时返回错误
+func (t *Template) render(vars map[string]string) (string, error) {
+	tpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Text)
+	if nil != err {
+		return "", err
+	}
+	if nil == vars {
+		vars = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, vars); nil != err {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Group 终端分组
+type Group struct {
+	// 分组名称
+	Name string `json:"name"`
+	// 终端手机号
+	Phones []string `json:"phones"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Request 文本信息下发请求，Phones、Group、All只能指定一个
+type Request struct {
+	// 指定的终端手机号
+	Phones []string `json:"phones"`
+	// 终端分组
+	Group string `json:"group"`
+	// 全部在线终端
+	All bool `json:"all"`
+	// 文本，指定模板时忽略
+	Text string `json:"text"`
+	// 模板名称
+	Template string `json:"template"`
+	// 模板变量
+	Vars map[string]string `json:"vars"`
+	// 下发选项，为空时使用模板的选项
+	Options *protocol.TextOptions `json:"options"`
+	// 离线重发有效期，单位：分钟，为0时为24小时
+	Expire int `json:"expire"`
+	// 操作人
+	Operator string `json:"operator"`
+}
+
+// Delivery 终端接收状态
+type Delivery struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 状态，见DeliveryXXX
+	Status string `json:"status"`
+	// 下发次数
+	Attempts int `json:"attempts"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 更新时间
+	Updated time.Time `json:"updated"`
+}
+
+// Dispatch 文本信息下发记录
+type Dispatch struct {
+	// 下发ID
+	ID string `json:"id"`
+	// 文本
+	Text string `json:"text"`
+	// 下发选项
+	Options protocol.TextOptions `json:"options"`
+	// 模板名称
+	Template string `json:"template,omitempty"`
+	// 终端分组
+	Group string `json:"group,omitempty"`
+	// 是否下发给全部在线终端
+	All bool `json:"all,omitempty"`
+	// 操作人
+	Operator string `json:"operator,omitempty"`
+	// 各终端接收状态
+	Deliveries []Delivery `json:"deliveries"`
+	// 创建时间
+	Created time.Time `json:"created"`
+	// 离线重发截止时间
+	Expires time.Time `json:"expires"`
+}
+
+// snapshot 复制下发记录，调用方需持有锁
+func (d *Dispatch) snapshot() Dispatch {
+	result := *d
+	result.Deliveries = append([]Delivery{}, d.Deliveries...)
+	return result
+}
+
+// Manager 文本信息服务。
+//
+// 以0x8300向单个终端、终端分组或全部在线终端下发文本信息，文本可由模板生成；
+// 以终端通用应答跟踪接收状态，终端不在线时在有效期内等待终端鉴权后重发，
+// 应答超时时在后台按递增的间隔重发，超过最大次数记为失败。
+type Manager struct {
+	request    terminal.Requester
+	offline    error
+	online     func() []string
+	file       string
+	retryDelay time.Duration
+
+	mtx        sync.Mutex
+	templates  map[string]*Template
+	groups     map[string]*Group
+	dispatches map[string]*Dispatch
+	// 正在下发的终端，键为下发ID与手机号
+	sending map[string]bool
+}
+
+// NewManager 新建文本信息服务
+func NewManager(request terminal.Requester, offline error, online func() []string, file string) *Manager {
+	return &Manager{
+		request:    request,
+		offline:    offline,
+		online:     online,
+		file:       file,
+		retryDelay: retryDelay,
+		templates:  make(map[string]*Template),
+		groups:     make(map[string]*Group),
+		dispatches: make(map[string]*Dispatch),
+		sending:    make(map[string]bool),
+	}
+}
+
+// Send 新建下发记录并在新的goroutine中下发，返回的记录中各终端为下发中
+func (m *Manager) Send(req Request) (Dispatch, error) {
+	text, options := req.Text, protocol.TextOptions{}
+	if "" != req.Template {
+		m.mtx.Lock()
+		tpl, ok := m.templates[req.Template]
+		var t Template
+		if ok {
+			t = *tpl
+		}
+		m.mtx.Unlock()
+		if !ok {
+			return Dispatch{}, ErrTemplateNotFound
+		}
+
+		var err error
+		if text, err = t.render(req.Vars); nil != err {
+			return Dispatch{}, err
+		}
+		options = t.Options
+	}
+	if nil != req.Options {
+		options = *req.Options
+	}
+	if 0 == options.Type {
+		options.Type = protocol.TextTypeNotice
+	}
+	if _, err := protocol.NewMsgText(text, options); nil != err {
+		return Dispatch{}, err
+	}
+
+	phones, err := m.targets(req)
+	if nil != err {
+		return Dispatch{}, err
+	}
+	expire := defaultExpire
+	if req.Expire > 0 {
+		expire = time.Duration(req.Expire) * time.Minute
+	}
+
+	now := time.Now()
+	d := &Dispatch{
+		ID:       util.RandomID(),
+		Text:     text,
+		Options:  options,
+		Template: req.Template,
+		Group:    req.Group,
+		All:      req.All,
+		Operator: req.Operator,
+		Created:  now,
+		Expires:  now.Add(expire),
+	}
+	for _, phone := range phones {
+		d.Deliveries = append(d.Deliveries, Delivery{Phone: phone, Status: DeliveryPending, Updated: now})
+	}
+
+	m.mtx.Lock()
+	for _, phone := range phones {
+		m.sending[d.ID+phone] = true
+	}
+	m.dispatches[d.ID] = d
+	m.trim()
+	m.save()
+	m.scheduleExpire(d)
+	info := d.snapshot()
+	m.mtx.Unlock()
+
+	go m.run(d.ID, phones)
+	return info, nil
+}
+
+// Dispatch 获取下发记录
+func (m *Manager) Dispatch(id string) (Dispatch, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	d, ok := m.dispatches[id]
+	if !ok {
+		return Dispatch{}, false
+	}
+	return d.snapshot(), true
+}
+
+// Dispatches 获取全部下发记录，按创建时间排列
+func (m *Manager) Dispatches() []Dispatch {
+	m.mtx.Lock()
+	dispatches := make([]Dispatch, 0, len(m.dispatches))
+	for _, d := range m.dispatches {
+		dispatches = append(dispatches, d.snapshot())
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(dispatches, func(i, j int) bool {
+		return dispatches[i].Created.Before(dispatches[j].Created)
+	})
+	return dispatches
+}
+
+// OnAuth 终端鉴权成功，在新的goroutine中重发有效期内等待的文本信息
+func (m *Manager) OnAuth(phone string) {
+	m.mtx.Lock()
+	changed := m.expire(time.Now())
+	var ids []string
+	for id, d := range m.dispatches {
+		for idx := range d.Deliveries {
+			delivery := &d.Deliveries[idx]
+			if phone == delivery.Phone && DeliveryWaiting == delivery.Status && !m.sending[id+phone] {
+				delivery.Status, delivery.Updated = DeliveryPending, time.Now()
+				m.sending[id+phone] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if changed && 0 == len(ids) {
+		m.save()
+	}
+	m.mtx.Unlock()
+
+	if 0 != len(ids) {
+		go func() {
+			for _, id := range ids {
+				m.deliver(id, phone)
+			}
+			m.flush()
+		}()
+	}
+}
+
+// SaveTemplate 新建或更新模板
+func (m *Manager) SaveTemplate(t Template) (Template, error) {
+	if "" == t.Name {
+		return Template{}, errors.New("the template name is required")
+	}
+	if _, err := template.New(t.Name).Parse(t.Text); nil != err {
+		return Template{}, err
+	}
+	t.Updated = time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.templates[t.Name] = &t
+	m.save()
+	return t, nil
+}
+
+// DeleteTemplate 删除模板
+func (m *Manager) DeleteTemplate(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.templates[name]; !ok {
+		return ErrTemplateNotFound
+	}
+	delete(m.templates, name)
+	m.save()
+	return nil
+}
+
+// Templates 获取全部模板，按名称排列
+func (m *Manager) Templates() []Template {
+	m.mtx.Lock()
+	templates := make([]Template, 0, len(m.templates))
+	for _, t := range m.templates {
+		templates = append(templates, *t)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// SaveGroup 新建或更新终端分组
+func (m *Manager) SaveGroup(g Group) (Group, error) {
+	if "" == g.Name {
+		return Group{}, errors.New("the group name is required")
+	}
+	g.Phones = unique(g.Phones)
+	g.Updated = time.Now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.groups[g.Name] = &g
+	m.save()
+	return g, nil
+}
+
+// DeleteGroup 删除终端分组
+func (m *Manager) DeleteGroup(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.groups[name]; !ok {
+		return ErrGroupNotFound
+	}
+	delete(m.groups, name)
+	m.save()
+	return nil
+}
+
+// Groups 获取全部终端分组，按名称排列
+func (m *Manager) Groups() []Group {
+	m.mtx.Lock()
+	groups := make([]Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		group := *g
+		group.Phones = append([]string{}, g.Phones...)
+		groups = append(groups, group)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// targets 下发的终端手机号
+func (m *Manager) targets(req Request) ([]string, error) {
+	selected := 0
+	for _, ok := range []bool{0 != len(req.Phones), "" != req.Group, req.All} {
+		if ok {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return nil, errors.New("only one of phones, group and all can be specified")
+	}
+
+	var phones []string
+	switch {
+	case "" != req.Group:
+		m.mtx.Lock()
+		g, ok := m.groups[req.Group]
+		if ok {
+			phones = append(phones, g.Phones...)
+		}
+		m.mtx.Unlock()
+		if !ok {
+			return nil, ErrGroupNotFound
+		}
+	case req.All:
+		if nil != m.online {
+			phones = m.online()
+		}
+	default:
+		phones = req.Phones
+	}
+
+	phones = unique(phones)
+	if 0 == len(phones) {
+		return nil, ErrNoTarget
+	}
+	return phones, nil
+}
+
+// run 并发下发到各终端，全部下发后保存一次
+func (m *Manager) run(id string, phones []string) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(phones); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for phone := range jobs {
+				m.deliver(id, phone)
+			}
+		}()
+	}
+	for _, phone := range phones {
+		jobs <- phone
+	}
+	close(jobs)
+	wg.Wait()
+	m.flush()
+}
+
+// retry 重发应答超时的终端，有效期已过或已在下发时忽略
+func (m *Manager) retry(id string, phone string) {
+	m.mtx.Lock()
+	now := time.Now()
+	changed := m.expire(now)
+	found := false
+	if d, ok := m.dispatches[id]; ok && !m.sending[id+phone] {
+		for idx := range d.Deliveries {
+			delivery := &d.Deliveries[idx]
+			if phone == delivery.Phone && DeliveryRetrying == delivery.Status {
+				delivery.Status, delivery.Updated = DeliveryPending, now
+				m.sending[id+phone] = true
+				found = true
+			}
+		}
+	}
+	if changed && !found {
+		m.save()
+	}
+	m.mtx.Unlock()
+
+	if found {
+		m.deliver(id, phone)
+		m.flush()
+	}
+}
+
+// flush 保存当前数据
+func (m *Manager) flush() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.save()
+}
+
+// deliver 向终端下发并记录接收状态，应答超时时安排后台重发，由调用方保存
+func (m *Manager) deliver(id string, phone string) {
+	m.mtx.Lock()
+	d, ok := m.dispatches[id]
+	var text string
+	var options protocol.TextOptions
+	if ok {
+		text, options = d.Text, d.Options
+	}
+	m.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	status := DeliveryDelivered
+	msg, err := protocol.NewMsgText(text, options)
+	if nil == err {
+		var input protocol.Input
+		input, err = m.request(phone, msg, terminal.RequestTimeout)
+		if nil == err {
+			if resp, ok := input.(*protocol.MsgTerminalResponse); !ok {
+				err = terminal.ErrUnexpectedData
+			} else if 0 != resp.Result {
+				status, err = DeliveryRejected, fmt.Errorf("the terminal responded with result %d", resp.Result)
+			}
+		}
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.sending, id+phone)
+	if d, ok = m.dispatches[id]; !ok {
+		return
+	}
+	for idx := range d.Deliveries {
+		delivery := &d.Deliveries[idx]
+		if phone != delivery.Phone {
+			continue
+		}
+
+		offline := nil != err && nil != m.offline && errors.Is(err, m.offline)
+		if !offline {
+			delivery.Attempts++
+		}
+		switch {
+		case nil == err || DeliveryRejected == status:
+		case offline:
+			status = DeliveryWaiting
+		case delivery.Attempts < maxAttempts:
+			status = DeliveryRetrying
+			delay := m.retryDelay << uint(delivery.Attempts-1)
+			time.AfterFunc(delay, func() { m.retry(id, phone) })
+		default:
+			status = DeliveryFailed
+		}
+		delivery.Status, delivery.Error, delivery.Updated = status, "", time.Now()
+		if nil != err {
+			delivery.Error = err.Error()
+		}
+	}
+}
+
+// expire 有效期已过的等待终端记为过期，返回是否有变化，由调用方保存，调用方需持有锁
+func (m *Manager) expire(now time.Time) bool {
+	changed := false
+	for _, d := range m.dispatches {
+		if now.Before(d.Expires) {
+			continue
+		}
+		for idx := range d.Deliveries {
+			if delivery := &d.Deliveries[idx]; DeliveryWaiting == delivery.Status || DeliveryRetrying == delivery.Status {
+				delivery.Status, delivery.Updated = DeliveryExpired, now
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// scheduleExpire 在下发记录有效期截止时将等待的终端记为过期并保存，调用方需持有锁
+func (m *Manager) scheduleExpire(d *Dispatch) {
+	time.AfterFunc(time.Until(d.Expires), func() {
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		if m.expire(time.Now()) {
+			m.save()
+		}
+	})
+}
+
+// trim 下发记录过多时删除最早的记录，调用方需持有锁
+func (m *Manager) trim() {
+	if len(m.dispatches) <= maxDispatches {
+		return
+	}
+	dispatches := make([]*Dispatch, 0, len(m.dispatches))
+	for _, d := range m.dispatches {
+		dispatches = append(dispatches, d)
+	}
+	sort.Slice(dispatches, func(i, j int) bool {
+		return dispatches[i].Created.Before(dispatches[j].Created)
+	})
+	for _, d := range dispatches[:len(dispatches)-maxDispatches] {
+		delete(m.dispatches, d.ID)
+	}
+}
+
+// store 持久化的文本信息数据
+type store struct {
+	Templates  []Template `json:"templates"`
+	Groups     []Group    `json:"groups"`
+	Dispatches []Dispatch `json:"dispatches"`
+}
+
+// save 保存模板、分组及下发记录，先写入临时文件再替换，调用方需持有锁
+func (m *Manager) save() {
+	if "" == m.file {
+		return
+	}
+
+	var s store
+	for _, t := range m.templates {
+		s.Templates = append(s.Templates, *t)
+	}
+	for _, g := range m.groups {
+		s.Groups = append(s.Groups, *g)
+	}
+	for _, d := range m.dispatches {
+		s.Dispatches = append(s.Dispatches, *d)
+	}
+	sort.Slice(s.Templates, func(i, j int) bool { return s.Templates[i].Name < s.Templates[j].Name })
+	sort.Slice(s.Groups, func(i, j int) bool { return s.Groups[i].Name < s.Groups[j].Name })
+	sort.Slice(s.Dispatches, func(i, j int) bool { return s.Dispatches[i].Created.Before(s.Dispatches[j].Created) })
+
+	if err := util.SaveJSON(m.file, &s); nil != err {
+		log.Printf("保存文本信息失败：%v", err)
+	}
+}
+
+// load 加载已保存的数据，文件不存在时忽略。上次退出时下发中及等待超时重发的终端在后台重发
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var s store
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for idx := range s.Templates {
+		t := s.Templates[idx]
+		m.templates[t.Name] = &t
+	}
+	for idx := range s.Groups {
+		g := s.Groups[idx]
+		m.groups[g.Name] = &g
+	}
+	for idx := range s.Dispatches {
+		d := s.Dispatches[idx]
+		for i := range d.Deliveries {
+			if status := d.Deliveries[i].Status; DeliveryPending == status || DeliveryRetrying == status {
+				d.Deliveries[i].Status = DeliveryRetrying
+				id, phone := d.ID, d.Deliveries[i].Phone
+				time.AfterFunc(m.retryDelay, func() { m.retry(id, phone) })
+			}
+		}
+		m.dispatches[d.ID] = &d
+		m.scheduleExpire(&d)
+	}
+	return nil
+}
+
+// unique 去掉空白及重复的手机号，保持顺序
+func unique(phones []string) []string {
+	var result []string
+	seen := make(map[string]bool, len(phones))
+	for _, phone := range phones {
+		phone = strings.TrimSpace(phone)
+		if "" != phone && !seen[phone] {
+			seen[phone] = true
+			result = append(result, phone)
+		}
+	}
+	return result
+}
@@ -0,0 +1,227 @@
+package text
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// textTerminals 模拟终端，rejected中的终端以失败应答文本信息
+func textTerminals(rejected ...string) *terminaltest.Terminals {
+	return terminaltest.New(func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+		if _, ok := output.(*protocol.MsgTextIssued); !ok || 0 != len(replyIDs) {
+			return nil, terminaltest.ErrUnexpectedMessage
+		}
+		for _, p := range rejected {
+			if p == phone {
+				return &protocol.MsgTerminalResponse{Result: 3}, nil
+			}
+		}
+		return &protocol.MsgTerminalResponse{}, nil
+	})
+}
+
+// received 终端收到的文本信息
+func received(terminals *terminaltest.Terminals, phone string) []*protocol.MsgTextIssued {
+	var result []*protocol.MsgTextIssued
+	for _, output := range terminals.Received(phone) {
+		result = append(result, output.(*protocol.MsgTextIssued))
+	}
+	return result
+}
+
+// wait 等待所有终端不再处于下发中
+func wait(t *testing.T, m *Manager, id string) Dispatch {
+	var d Dispatch
+	terminaltest.Wait(t, "the dispatch is still pending", func() bool {
+		d, _ = m.Dispatch(id)
+		for _, delivery := range d.Deliveries {
+			if DeliveryPending == delivery.Status {
+				return false
+			}
+		}
+		return true
+	})
+	return d
+}
+
+func statuses(d Dispatch) map[string]string {
+	result := make(map[string]string)
+	for _, delivery := range d.Deliveries {
+		result[delivery.Phone] = delivery.Status
+	}
+	return result
+}
+
+func TestSendGroup(t *testing.T) {
+	terminals := textTerminals("3")
+	terminals.SetOffline("2", true)
+	file := filepath.Join(t.TempDir(), "texts.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+
+	m.SaveTemplate(Template{Name: "return", Text: "{{.plate}}请于{{.time}}前返回", Options: protocol.TextOptions{Display: true, TTS: true}})
+	m.SaveGroup(Group{Name: "fleet", Phones: []string{"1", "2", "3", "1"}})
+
+	if _, err := m.Send(Request{Group: "fleet", Template: "return", Vars: map[string]string{"plate": "粤B12345"}}); nil == err {
+		t.Fatal("expected an error for a missing variable")
+	}
+	if _, err := m.Send(Request{Group: "fleet", Phones: []string{"1"}, Text: "hi"}); nil == err {
+		t.Fatal("expected an error for multiple targets")
+	}
+
+	d, err := m.Send(Request{Group: "fleet", Template: "return", Vars: map[string]string{"plate": "粤B12345", "time": "18:00"}})
+	if nil != err || "粤B12345请于18:00前返回" != d.Text || 3 != len(d.Deliveries) {
+		t.Fatalf("unexpected dispatch: %+v, %v", d, err)
+	}
+	d = wait(t, m, d.ID)
+	want := map[string]string{"1": DeliveryDelivered, "2": DeliveryWaiting, "3": DeliveryRejected}
+	for phone, status := range statuses(d) {
+		if want[phone] != status {
+			t.Fatalf("got statuses %v, want %v", statuses(d), want)
+		}
+	}
+	if msg := received(terminals, "1")[0]; !msg.Flag.IsShow() || !msg.Flag.IsTTS() || protocol.TextTypeNotice != msg.Type {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	// 离线终端上线后重发
+	terminals.SetOffline("2", false)
+	m.OnAuth("2")
+	terminaltest.Wait(t, "the offline terminal was not retried", func() bool {
+		got, _ := m.Dispatch(d.ID)
+		return DeliveryDelivered == statuses(got)["2"]
+	})
+
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if got, ok := loaded.Dispatch(d.ID); !ok || DeliveryDelivered != statuses(got)["2"] || 1 != len(loaded.Templates()) || 1 != len(loaded.Groups()) {
+		t.Fatalf("unexpected loaded dispatch: %+v", got)
+	}
+}
+
+func TestSendAllExpire(t *testing.T) {
+	terminals := textTerminals()
+	terminals.SetOffline("2", true)
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, func() []string { return []string{"1", "2"} }, "")
+
+	d, err := m.Send(Request{All: true, Text: "紧急通知", Options: &protocol.TextOptions{Emergency: true}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	d = wait(t, m, d.ID)
+	if DeliveryDelivered != statuses(d)["1"] || DeliveryWaiting != statuses(d)["2"] || !received(terminals, "1")[0].Flag.IsEmergency() {
+		t.Fatalf("unexpected dispatch: %+v", d)
+	}
+
+	// 有效期已过不再重发
+	m.mtx.Lock()
+	m.dispatches[d.ID].Expires = time.Now().Add(-time.Second)
+	m.mtx.Unlock()
+	terminals.SetOffline("2", false)
+	m.OnAuth("2")
+	if got, _ := m.Dispatch(d.ID); DeliveryExpired != statuses(got)["2"] || 0 != len(received(terminals, "2")) {
+		t.Fatalf("unexpected dispatch: %+v", got)
+	}
+
+	if _, err := m.Send(Request{Phones: []string{"1"}, Text: "😀"}); nil == err {
+		t.Fatal("expected an error for a text that cannot be encoded in GBK")
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	terminals := terminaltest.New(func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+		return nil, errTimeout
+	})
+	file := filepath.Join(t.TempDir(), "texts.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+	m.retryDelay = time.Millisecond * 10
+
+	d, err := m.Send(Request{Phones: []string{"1"}, Text: "hi"})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// 在线终端应答超时在后台重发，不等待终端鉴权
+	terminaltest.Wait(t, "the timed out terminal was not retried", func() bool {
+		got, _ := m.Dispatch(d.ID)
+		return DeliveryFailed == statuses(got)["1"]
+	})
+	if got, _ := m.Dispatch(d.ID); maxAttempts != got.Deliveries[0].Attempts || maxAttempts != len(terminals.Received("1")) {
+		t.Fatalf("unexpected dispatch: %+v", got)
+	}
+
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	if got, ok := loaded.Dispatch(d.ID); !ok || DeliveryFailed != statuses(got)["1"] {
+		t.Fatalf("unexpected loaded dispatch: %+v", got)
+	}
+}
+
+func TestQueryDoesNotSave(t *testing.T) {
+	terminals := textTerminals()
+	terminals.SetOffline("1", true)
+	file := filepath.Join(t.TempDir(), "texts.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+
+	d, err := m.Send(Request{Phones: []string{"1"}, Text: "hi"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wait(t, m, d.ID)
+
+	// 查询不写入存储文件，有效期截止由定时器处理
+	m.mtx.Lock()
+	m.dispatches[d.ID].Expires = time.Now().Add(-time.Second)
+	m.mtx.Unlock()
+	os.Remove(file)
+	m.Dispatch(d.ID)
+	m.Dispatches()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("the store was written by a query: %v", err)
+	}
+}
+
+func TestLoadRetry(t *testing.T) {
+	timeout := true
+	terminals := terminaltest.New(func(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+		if timeout {
+			return nil, errors.New("timeout")
+		}
+		return &protocol.MsgTerminalResponse{}, nil
+	})
+	file := filepath.Join(t.TempDir(), "texts.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+	m.retryDelay = time.Hour
+
+	d, err := m.Send(Request{Phones: []string{"1"}, Text: "hi"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	terminaltest.Wait(t, "the timed out terminal was not scheduled for retry", func() bool {
+		got, _ := m.Dispatch(d.ID)
+		return DeliveryRetrying == statuses(got)["1"]
+	})
+
+	m.flush()
+
+	// 重启后等待重发的终端在后台重发，不等待终端鉴权
+	terminals.Do(func() { timeout = false })
+	loaded := NewManager(terminals.Request, terminaltest.ErrOffline, nil, file)
+	loaded.retryDelay = time.Millisecond * 10
+	if err := loaded.load(); nil != err {
+		t.Fatal(err)
+	}
+	terminaltest.Wait(t, "the loaded delivery was not retried", func() bool {
+		got, _ := loaded.Dispatch(d.ID)
+		return DeliveryDelivered == statuses(got)["1"]
+	})
+}
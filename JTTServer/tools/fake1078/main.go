@@ -0,0 +1,45 @@
+// fake1078 模拟JT/T1078终端，将录制的原始流推送到音视频接入服务
+//
+//	go run ./tools/fake1078 -addr 127.0.0.1:1078 -file dump.bin -phone 13912345678 -channel 1
+package main
+
+import (
+	"JTTServer/media"
+	"flag"
+	"log"
+	"net"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:1078", "音视频接入服务地址")
+	network := flag.String("network", "tcp", "推流方式，tcp或udp")
+	file := flag.String("file", "", "录制的JT/T1078原始流文件")
+	phone := flag.String("phone", "", "改写RTP包中的终端手机号，为空时不改写")
+	channel := flag.Uint("channel", 0, "改写RTP包中的逻辑通道号，为0时不改写")
+	loop := flag.Bool("loop", false, "是否循环推送")
+	flag.Parse()
+
+	conn, err := net.Dial(*network, *addr)
+	if nil != err {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	for {
+		f, err := os.Open(*file)
+		if nil != err {
+			log.Fatal(err)
+		}
+
+		err = media.PushDump(conn, f, *phone, byte(*channel), true)
+		f.Close()
+		if nil != err {
+			log.Fatal(err)
+		}
+
+		if !*loop {
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package upgrade
+
+import (
+	"JTTServer/util"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Firmware 升级包信息
+type Firmware struct {
+	// 升级包ID
+	ID string `json:"id"`
+	// 升级包名称
+	Name string `json:"name"`
+	// 升级类型，见protocol.UpgradeTypeXXX
+	Type byte `json:"type"`
+	// 制造商ID
+	VendorID string `json:"vendor_id"`
+	// 适用的终端型号，为空时适用该制造商的所有型号
+	Models []string `json:"models"`
+	// 固件版本号
+	Version string `json:"version"`
+	// 升级包大小
+	Size int64 `json:"size"`
+	// 升级包MD5
+	MD5 string `json:"md5"`
+	// 上传时间
+	Created time.Time `json:"created"`
+}
+
+// matchModel 终端型号是否适用
+func (f *Firmware) matchModel(model string) bool {
+	if 0 == len(f.Models) {
+		return true
+	}
+	for _, m := range f.Models {
+		if strings.EqualFold(m, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFirmware 保存升级包，升级包数据与信息分别存储为<id>.bin与<id>.json
+func (m *Manager) AddFirmware(info Firmware, data []byte) (Firmware, error) {
+	if "" == info.VendorID || "" == info.Version || 0 == len(data) {
+		return Firmware{}, ErrInvalidFirmware
+	}
+	if len(info.Version) > 255 {
+		return Firmware{}, ErrInvalidFirmware
+	}
+
+	sum := md5.Sum(data)
+	info.ID = util.RandomID()
+	info.Size = int64(len(data))
+	info.MD5 = hex.EncodeToString(sum[:])
+	info.Created = time.Now()
+	if "" == info.Name {
+		info.Name = info.VendorID + " " + info.Version
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); nil != err {
+		return Firmware{}, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(m.dir, info.ID+".bin"), data, 0644); nil != err {
+		return Firmware{}, err
+	}
+	meta, _ := json.MarshalIndent(&info, "", "  ")
+	if err := ioutil.WriteFile(filepath.Join(m.dir, info.ID+".json"), meta, 0644); nil != err {
+		os.Remove(filepath.Join(m.dir, info.ID+".bin"))
+		return Firmware{}, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.firmwares[info.ID] = &info
+	return info, nil
+}
+
+// Firmware 获取升级包信息
+func (m *Manager) Firmware(id string) (Firmware, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	info, ok := m.firmwares[id]
+	if !ok {
+		return Firmware{}, false
+	}
+	return *info, true
+}
+
+// Firmwares 获取所有升级包信息，按上传时间排列
+func (m *Manager) Firmwares() []Firmware {
+	m.mtx.Lock()
+	firmwares := make([]Firmware, 0, len(m.firmwares))
+	for _, info := range m.firmwares {
+		firmwares = append(firmwares, *info)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(firmwares, func(i, j int) bool {
+		return firmwares[i].Created.Before(firmwares[j].Created)
+	})
+	return firmwares
+}
+
+// DeleteFirmware 删除升级包，进行中的升级计划使用的升级包不能删除
+func (m *Manager) DeleteFirmware(id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.firmwares[id]; !ok {
+		return ErrFirmwareNotFound
+	}
+	for _, c := range m.campaigns {
+		if id == c.info.Firmware.ID && !c.done() {
+			return ErrFirmwareInUse
+		}
+	}
+	delete(m.firmwares, id)
+	os.Remove(filepath.Join(m.dir, id+".json"))
+	os.Remove(filepath.Join(m.dir, id+".bin"))
+	return nil
+}
+
+// loadFirmwares 加载升级包目录中已保存的升级包信息
+func (m *Manager) loadFirmwares() {
+	names, _ := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if nil != err {
+			continue
+		}
+		var info Firmware
+		if nil != json.Unmarshal(data, &info) || "" == info.ID {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(m.dir, info.ID+".bin")); nil != err {
+			continue
+		}
+		m.firmwares[info.ID] = &info
+	}
+}
+
+// readFirmware 读取升级包数据
+func (m *Manager) readFirmware(id string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(m.dir, id+".bin"))
+}
@@ -0,0 +1,627 @@
+package upgrade
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// UpgradeApp 默认的终端升级服务，由Setup初始化
+	UpgradeApp *Manager
+)
+
+var (
+	// ErrFirmwareNotFound 升级包不存在
+	ErrFirmwareNotFound = errors.New("the firmware does not exist")
+	// ErrInvalidFirmware 升级包信息不完整
+	ErrInvalidFirmware = errors.New("vendor_id, version and package data are required")
+	// ErrFirmwareInUse 升级包正在被升级计划使用
+	ErrFirmwareInUse = errors.New("the firmware is used by a running campaign")
+	// ErrCampaignNotFound 升级计划不存在
+	ErrCampaignNotFound = errors.New("the campaign does not exist")
+	// ErrCampaignDone 升级计划已结束
+	ErrCampaignDone = errors.New("the campaign is already done")
+	// ErrNoTargets 升级计划没有目标终端
+	ErrNoTargets = errors.New("phones are required")
+)
+
+// 服务重启时终端正在升级，升级结果未知
+var errInterrupted = errors.New("interrupted by a server restart")
+
+// Setup 初始化默认的终端升级服务，dir为升级包存储目录，offline为终端不在线时Requester返回的错误，
+// file为升级计划的存储文件，为空时不持久化
+//
+// upgrade.Setup(jtt.Request, jtt.ErrClientOffline, "firmwares", "upgrades.json")
+func Setup(request terminal.Requester, offline error, dir, file string) error {
+	m := NewManager(request, offline, dir, file)
+	if err := m.load(); nil != err {
+		return err
+	}
+	UpgradeApp = m
+	return nil
+}
+
+const (
+	// 升级包下发超时时间，升级包分包发送，终端收齐后才应答
+	sendTimeout = time.Minute * 5
+	// 等待终端升级结果应答（0x0108）的超时时间，终端升级后通常需要重启
+	resultTimeout = time.Minute * 30
+	// 默认的并发升级终端数
+	defaultConcurrency = 10
+)
+
+// 升级计划状态
+const (
+	CampaignRunning  = "running"  // 执行中
+	CampaignPaused   = "paused"   // 已暂停，执行中的终端继续完成，不再开始新的终端
+	CampaignFinished = "finished" // 已完成
+	CampaignAborted  = "aborted"  // 已中止
+)
+
+// 终端升级状态
+const (
+	TargetPending   = "pending"   // 等待升级
+	TargetSkipped   = "skipped"   // 制造商、型号或版本不符，不升级
+	TargetOffline   = "offline"   // 终端不在线
+	TargetSending   = "sending"   // 下发升级包中
+	TargetUpgrading = "upgrading" // 终端已接收升级包，等待升级结果
+	TargetSucceeded = "succeeded" // 升级成功
+	TargetFailed    = "failed"    // 升级失败
+	TargetCancelled = "cancelled" // 终端取消升级
+	TargetAborted   = "aborted"   // 升级计划中止，未升级
+)
+
+// 升级计划控制
+const (
+	ControlPause  = "pause"  // 暂停
+	ControlResume = "resume" // 继续
+	ControlAbort  = "abort"  // 中止
+)
+
+// CampaignRequest 升级计划
+type CampaignRequest struct {
+	// 升级包ID
+	Firmware string `json:"firmware"`
+	// 候选终端手机号，按升级包的制造商、型号筛选
+	Phones []string `json:"phones"`
+	// 只升级当前固件版本在列表中的终端，为空时升级所有版本与升级包不同的终端
+	FromVersions []string `json:"from_versions"`
+	// 分阶段升级，各阶段的终端数，剩余终端为最后一个阶段，为空时不分阶段
+	Stages []int `json:"stages"`
+	// 并发升级的终端数，默认10
+	Concurrency int `json:"concurrency"`
+	// 单个阶段失败的终端数超过该值时暂停升级计划，小于0时不暂停
+	MaxFailures int `json:"max_failures"`
+}
+
+// Target 终端升级状态
+type Target struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 所属阶段，从0开始
+	Stage int `json:"stage"`
+	// 状态，见TargetXXX
+	State string `json:"state"`
+	// 升级前的固件版本号
+	FromVersion string `json:"from_version,omitempty"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 开始时间
+	Started time.Time `json:"started,omitempty"`
+	// 结束时间
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// CampaignInfo 升级计划信息
+type CampaignInfo struct {
+	// 升级计划ID
+	ID string `json:"id"`
+	// 升级包
+	Firmware Firmware `json:"firmware"`
+	// 升级计划
+	Request CampaignRequest `json:"request"`
+	// 状态，见CampaignXXX
+	State string `json:"state"`
+	// 当前阶段
+	Stage int `json:"stage"`
+	// 各终端升级状态
+	Targets []Target `json:"targets"`
+	// 创建时间
+	Created time.Time `json:"created"`
+	// 结束时间
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// campaign 升级计划，状态由Manager的锁保护
+type campaign struct {
+	info CampaignInfo
+	cond *sync.Cond
+}
+
+// done 升级计划是否已结束，调用方需持有锁
+func (c *campaign) done() bool {
+	return CampaignFinished == c.info.State || CampaignAborted == c.info.State
+}
+
+// snapshot 复制升级计划信息，调用方需持有锁
+func (c *campaign) snapshot() CampaignInfo {
+	info := c.info
+	info.Targets = append([]Target{}, c.info.Targets...)
+	return info
+}
+
+// Manager 终端升级服务。
+//
+// 升级包按制造商、型号与版本号管理，升级计划以0x8107查询终端属性筛选目标终端，
+// 以0x8108下发升级包（由协议层分包发送并响应终端的补传请求），终端通用应答后等待0x0108升级结果。
+// 升级计划可分阶段执行并限制并发数，单阶段失败过多时自动暂停，可手动暂停、继续或中止。
+// 升级计划保存在文件中，重启后未结束的升级计划继续执行，重启时升级中的终端记为失败。
+type Manager struct {
+	request terminal.Requester
+	offline error
+	dir     string
+	file    string
+
+	sendTimeout   time.Duration
+	resultTimeout time.Duration
+
+	mtx       sync.Mutex
+	firmwares map[string]*Firmware
+	campaigns map[string]*campaign
+	waiting   map[string]chan byte // 等待升级结果的终端，键为手机号
+}
+
+// NewManager 新建终端升级服务，加载dir中已保存的升级包
+func NewManager(request terminal.Requester, offline error, dir, file string) *Manager {
+	m := &Manager{
+		request:       request,
+		offline:       offline,
+		dir:           dir,
+		file:          file,
+		sendTimeout:   sendTimeout,
+		resultTimeout: resultTimeout,
+		firmwares:     make(map[string]*Firmware),
+		campaigns:     make(map[string]*campaign),
+		waiting:       make(map[string]chan byte),
+	}
+	m.loadFirmwares()
+	return m
+}
+
+// StartCampaign 新建并开始执行升级计划
+func (m *Manager) StartCampaign(req CampaignRequest) (CampaignInfo, error) {
+	firmware, ok := m.Firmware(req.Firmware)
+	if !ok {
+		return CampaignInfo{}, ErrFirmwareNotFound
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = defaultConcurrency
+	}
+
+	c := &campaign{
+		info: CampaignInfo{
+			ID:       util.RandomID(),
+			Firmware: firmware,
+			Request:  req,
+			State:    CampaignRunning,
+			Targets:  []Target{},
+			Created:  time.Now(),
+		},
+		cond: sync.NewCond(&m.mtx),
+	}
+	seen := make(map[string]bool)
+	for _, phone := range req.Phones {
+		if "" == phone || seen[phone] {
+			continue
+		}
+		seen[phone] = true
+		c.info.Targets = append(c.info.Targets, Target{Phone: phone, State: TargetPending})
+	}
+	if 0 == len(c.info.Targets) {
+		return CampaignInfo{}, ErrNoTargets
+	}
+
+	// 划分阶段
+	stage, size := 0, -1
+	if 0 != len(req.Stages) {
+		size = req.Stages[0]
+	}
+	for idx := range c.info.Targets {
+		for 0 == size {
+			stage++
+			size = -1
+			if stage < len(req.Stages) {
+				size = req.Stages[stage]
+			}
+		}
+		c.info.Targets[idx].Stage = stage
+		size--
+	}
+
+	m.mtx.Lock()
+	m.campaigns[c.info.ID] = c
+	info := c.snapshot()
+	m.save()
+	m.mtx.Unlock()
+
+	go m.run(c)
+	return info, nil
+}
+
+// Campaign 获取升级计划信息
+func (m *Manager) Campaign(id string) (CampaignInfo, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, ok := m.campaigns[id]
+	if !ok {
+		return CampaignInfo{}, false
+	}
+	return c.snapshot(), true
+}
+
+// Campaigns 获取所有升级计划信息，按创建时间排列
+func (m *Manager) Campaigns() []CampaignInfo {
+	m.mtx.Lock()
+	campaigns := make([]CampaignInfo, 0, len(m.campaigns))
+	for _, c := range m.campaigns {
+		campaigns = append(campaigns, c.snapshot())
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].Created.Before(campaigns[j].Created)
+	})
+	return campaigns
+}
+
+// Control 暂停、继续或中止升级计划，见ControlXXX；中止时未开始的终端不再升级，已下发的终端继续等待结果
+func (m *Manager) Control(id string, action string) (CampaignInfo, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c, ok := m.campaigns[id]
+	if !ok {
+		return CampaignInfo{}, ErrCampaignNotFound
+	}
+	if c.done() {
+		return c.snapshot(), ErrCampaignDone
+	}
+
+	switch action {
+	case ControlPause:
+		c.info.State = CampaignPaused
+	case ControlResume:
+		c.info.State = CampaignRunning
+	case ControlAbort:
+		c.info.State = CampaignAborted
+		c.info.Finished = time.Now()
+		for idx := range c.info.Targets {
+			if target := &c.info.Targets[idx]; TargetPending == target.State {
+				target.State = TargetAborted
+			}
+		}
+	default:
+		return c.snapshot(), fmt.Errorf("unknown control %q", action)
+	}
+	m.save()
+	c.cond.Broadcast()
+	return c.snapshot(), nil
+}
+
+// OnUpgradeResult 终端升级结果应答（0x0108）
+func (m *Manager) OnUpgradeResult(phone string, msg *protocol.MsgTerminalUpgradeResp) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if result, ok := m.waiting[phone]; ok {
+		select {
+		case result <- msg.Result:
+		default:
+		}
+	}
+}
+
+// run 按阶段执行升级计划，没有等待升级终端的阶段跳过
+func (m *Manager) run(c *campaign) {
+	pkg, err := m.readFirmware(c.info.Firmware.ID)
+
+	m.mtx.Lock()
+	stages := 0
+	if n := len(c.info.Targets); 0 != n {
+		stages = c.info.Targets[n-1].Stage + 1
+	}
+	m.mtx.Unlock()
+
+	for stage := 0; stage < stages; stage++ {
+		m.mtx.Lock()
+		var targets []int
+		pending := false
+		for idx, target := range c.info.Targets {
+			if stage == target.Stage {
+				targets = append(targets, idx)
+				pending = pending || TargetPending == target.State
+			}
+		}
+		// 重启后继续执行时跳过已完成的阶段
+		if pending {
+			c.info.Stage = stage
+		}
+		m.mtx.Unlock()
+		if !pending {
+			continue
+		}
+
+		m.runStage(c, targets, pkg, err)
+
+		m.mtx.Lock()
+		failures := 0
+		for _, idx := range targets {
+			if TargetFailed == c.info.Targets[idx].State {
+				failures++
+			}
+		}
+		// 失败过多时暂停，等待人工确认后继续下一阶段
+		if max := c.info.Request.MaxFailures; max >= 0 && failures > max && stage+1 < stages && CampaignRunning == c.info.State {
+			c.info.State = CampaignPaused
+			m.save()
+		}
+		m.mtx.Unlock()
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if !c.done() {
+		c.info.State = CampaignFinished
+		c.info.Finished = time.Now()
+		m.save()
+	}
+}
+
+// runStage 并发执行一个阶段的终端升级，pkgErr为读取升级包的错误
+func (m *Manager) runStage(c *campaign, targets []int, pkg []byte, pkgErr error) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.info.Request.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if !m.begin(c, idx) {
+					continue
+				}
+				if nil != pkgErr {
+					m.finish(c, idx, TargetFailed, pkgErr)
+					continue
+				}
+				m.upgrade(c, idx, pkg)
+			}
+		}()
+	}
+	for _, idx := range targets {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// begin 等待升级计划继续执行并标记终端开始升级，升级计划已中止时返回false
+func (m *Manager) begin(c *campaign, idx int) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for CampaignPaused == c.info.State {
+		c.cond.Wait()
+	}
+	target := &c.info.Targets[idx]
+	if CampaignRunning != c.info.State || TargetPending != target.State {
+		return false
+	}
+	target.State = TargetSending
+	target.Started = time.Now()
+	m.save()
+	return true
+}
+
+// upgrade 升级终端：查询终端属性、筛选、下发升级包并等待升级结果
+func (m *Manager) upgrade(c *campaign, idx int, pkg []byte) {
+	firmware := c.info.Firmware
+	m.mtx.Lock()
+	phone := c.info.Targets[idx].Phone
+	fromVersions := c.info.Request.FromVersions
+	m.mtx.Unlock()
+
+	attr, err := m.queryAttr(phone)
+	if nil != err {
+		m.finish(c, idx, TargetFailed, err)
+		return
+	}
+	version := trim(attr.FWVersion)
+	m.mtx.Lock()
+	c.info.Targets[idx].FromVersion = version
+	m.mtx.Unlock()
+
+	switch {
+	case !strings.EqualFold(trim(attr.VendorID), firmware.VendorID):
+		m.finish(c, idx, TargetSkipped, fmt.Errorf("vendor %s does not match", trim(attr.VendorID)))
+		return
+	case !firmware.matchModel(trim(attr.Model)):
+		m.finish(c, idx, TargetSkipped, fmt.Errorf("model %s does not match", trim(attr.Model)))
+		return
+	case version == firmware.Version:
+		m.finish(c, idx, TargetSkipped, errors.New("already up to date"))
+		return
+	case 0 != len(fromVersions) && !contains(fromVersions, version):
+		m.finish(c, idx, TargetSkipped, fmt.Errorf("version %s is not targeted", version))
+		return
+	}
+
+	// 先登记等待升级结果，终端可能在通用应答后立即上报
+	result := make(chan byte, 1)
+	m.mtx.Lock()
+	if _, busy := m.waiting[phone]; busy {
+		m.mtx.Unlock()
+		m.finish(c, idx, TargetFailed, errors.New("another upgrade is in progress"))
+		return
+	}
+	m.waiting[phone] = result
+	m.mtx.Unlock()
+	defer func() {
+		m.mtx.Lock()
+		delete(m.waiting, phone)
+		m.mtx.Unlock()
+	}()
+
+	msg := protocol.NewMsgTerminalUpgrade()
+	msg.Type = firmware.Type
+	msg.VendorID = firmware.VendorID
+	msg.Version = firmware.Version
+	msg.Pkg = pkg
+	input, err := m.request(phone, msg, m.sendTimeout)
+	if nil != err {
+		m.finish(c, idx, TargetFailed, err)
+		return
+	}
+	if resp, ok := input.(*protocol.MsgTerminalResponse); !ok {
+		m.finish(c, idx, TargetFailed, terminal.ErrUnexpectedData)
+		return
+	} else if 0 != resp.Result {
+		m.finish(c, idx, TargetFailed, terminal.ErrRejected)
+		return
+	}
+
+	m.mtx.Lock()
+	c.info.Targets[idx].State = TargetUpgrading
+	m.save()
+	m.mtx.Unlock()
+
+	select {
+	case code := <-result:
+		switch code {
+		case protocol.UpgradeResultSuccess:
+			m.finish(c, idx, TargetSucceeded, nil)
+		case protocol.UpgradeResultCancelled:
+			m.finish(c, idx, TargetCancelled, nil)
+		default:
+			m.finish(c, idx, TargetFailed, fmt.Errorf("upgrade result %d", code))
+		}
+	case <-time.After(m.resultTimeout):
+		m.finish(c, idx, TargetFailed, errors.New("timed out waiting for the upgrade result"))
+	}
+}
+
+// queryAttr 查询终端属性
+func (m *Manager) queryAttr(phone string) (*protocol.TerminalAttr, error) {
+	input, err := m.request(phone, protocol.NewMsgGetTerminalAttr(), terminal.RequestTimeout, protocol.MsgIDGetTerminalAttrResp)
+	if nil != err {
+		return nil, err
+	}
+	switch msg := input.(type) {
+	case *protocol.MsgGetTerminalAttrResp:
+		return &msg.TerminalAttr, nil
+	case *protocol.MsgGetTerminalAttrResp2011:
+		return &msg.TerminalAttr, nil
+	default:
+		return nil, terminal.ErrUnexpectedData
+	}
+}
+
+// finish 记录终端升级结果，终端不在线时记为TargetOffline
+func (m *Manager) finish(c *campaign, idx int, state string, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	target := &c.info.Targets[idx]
+	if TargetFailed == state && nil != m.offline && errors.Is(err, m.offline) {
+		state = TargetOffline
+	}
+	target.State = state
+	target.Finished = time.Now()
+	if nil != err {
+		target.Error = err.Error()
+	}
+	m.save()
+}
+
+// store 持久化的升级计划数据
+type store struct {
+	Campaigns []CampaignInfo `json:"campaigns"`
+}
+
+// save 保存升级计划，先写入临时文件再替换，调用方需持有锁
+func (m *Manager) save() {
+	if "" == m.file {
+		return
+	}
+
+	var s store
+	for _, c := range m.campaigns {
+		s.Campaigns = append(s.Campaigns, c.info)
+	}
+	sort.Slice(s.Campaigns, func(i, j int) bool { return s.Campaigns[i].Created.Before(s.Campaigns[j].Created) })
+
+	if err := util.SaveJSON(m.file, &s); nil != err {
+		log.Printf("保存升级计划失败：%v", err)
+	}
+}
+
+// load 加载已保存的升级计划，文件不存在时忽略。上次退出时下发中及等待升级结果的终端记为失败，
+// 未结束的升级计划在后台继续执行
+func (m *Manager) load() error {
+	if "" == m.file {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if nil != err {
+		return err
+	}
+
+	var s store
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, info := range s.Campaigns {
+		c := &campaign{info: info, cond: sync.NewCond(&m.mtx)}
+		for idx := range c.info.Targets {
+			if target := &c.info.Targets[idx]; TargetSending == target.State || TargetUpgrading == target.State {
+				target.State, target.Error, target.Finished = TargetFailed, errInterrupted.Error(), time.Now()
+			}
+		}
+		m.campaigns[info.ID] = c
+		if !c.done() {
+			go m.run(c)
+		}
+	}
+	return nil
+}
+
+// trim 去除定长字段末尾的0与空格
+func trim(value string) string {
+	return strings.TrimRight(value, "\x00 ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package upgrade
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"bytes"
+	"common/protocol"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeTerminal 模拟终端，result为升级结果
+type fakeTerminal struct {
+	vendor, model, version string
+	result                 byte
+}
+
+// fakeFleet 模拟一组终端
+type fakeFleet struct {
+	manager   *Manager
+	terminals map[string]*fakeTerminal
+	packages  map[string][]byte
+}
+
+func (f *fakeFleet) handle(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+	terminal := f.terminals[phone]
+	if nil == terminal {
+		return nil, terminaltest.ErrOffline
+	}
+	switch msg := output.(type) {
+	case *protocol.MsgGetTerminalAttr:
+		resp := &protocol.MsgGetTerminalAttrResp{}
+		resp.VendorID = terminal.vendor + "\x00\x00\x00\x00\x00\x00"
+		resp.Model = terminal.model
+		resp.FWVersion = terminal.version
+		return resp, nil
+	case *protocol.MsgTerminalUpgrade:
+		f.packages[phone] = msg.Pkg
+		result := terminal.result
+		go f.manager.OnUpgradeResult(phone, &protocol.MsgTerminalUpgradeResp{Result: result})
+		return &protocol.MsgTerminalResponse{}, nil
+	}
+	return nil, terminaltest.ErrUnexpectedMessage
+}
+
+func waitCampaign(t *testing.T, m *Manager, id string, state string) CampaignInfo {
+	var info CampaignInfo
+	terminaltest.Wait(t, "campaign state is not "+state, func() bool {
+		info, _ = m.Campaign(id)
+		return state == info.State
+	})
+	return info
+}
+
+func TestCampaign(t *testing.T) {
+	fleet := &fakeFleet{
+		terminals: map[string]*fakeTerminal{
+			"1": {vendor: "ACME", model: "T1", version: "1.0", result: protocol.UpgradeResultFailed},
+			"2": {vendor: "ACME", model: "T1", version: "1.0"},
+			"3": {vendor: "OTHER", model: "T1", version: "1.0"},
+			"4": {vendor: "ACME", model: "T1", version: "2.0"},
+			"5": {vendor: "ACME", model: "T1", version: "1.0"},
+			"6": {vendor: "ACME", model: "T2", version: "1.0"},
+		},
+		packages: make(map[string][]byte),
+	}
+	terminals := terminaltest.New(fleet.handle)
+	terminals.SetOffline("5", true)
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, t.TempDir(), "")
+	m.resultTimeout = time.Second
+	fleet.manager = m
+
+	pkg := bytes.Repeat([]byte{0x7E}, 3000)
+	firmware, err := m.AddFirmware(Firmware{Type: protocol.UpgradeTypeTerminal, VendorID: "ACME", Models: []string{"T1"}, Version: "2.0"}, pkg)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// 第一阶段1台终端失败后暂停
+	info, err := m.StartCampaign(CampaignRequest{
+		Firmware:    firmware.ID,
+		Phones:      []string{"1", "2", "3", "4", "5", "6", "2"},
+		Stages:      []int{1},
+		Concurrency: 2,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 6 != len(info.Targets) || 0 != info.Targets[0].Stage || 1 != info.Targets[5].Stage {
+		t.Fatalf("unexpected targets: %+v", info.Targets)
+	}
+	info = waitCampaign(t, m, info.ID, CampaignPaused)
+	if TargetFailed != info.Targets[0].State || TargetPending != info.Targets[1].State {
+		t.Fatalf("unexpected targets after stage 0: %+v", info.Targets)
+	}
+
+	if _, err := m.Control(info.ID, ControlResume); nil != err {
+		t.Fatal(err)
+	}
+	info = waitCampaign(t, m, info.ID, CampaignFinished)
+	want := []string{TargetFailed, TargetSucceeded, TargetSkipped, TargetSkipped, TargetOffline, TargetSkipped}
+	for idx, target := range info.Targets {
+		if want[idx] != target.State {
+			t.Fatalf("target %s: got %s, want %s (%s)", target.Phone, target.State, want[idx], target.Error)
+		}
+	}
+	terminals.Do(func() {
+		if !bytes.Equal(pkg, fleet.packages["2"]) || "1.0" != info.Targets[1].FromVersion {
+			t.Fatalf("unexpected package for terminal 2")
+		}
+	})
+	if _, err := m.Control(info.ID, ControlAbort); ErrCampaignDone != err {
+		t.Fatalf("got %v, want ErrCampaignDone", err)
+	}
+
+	// 重新加载已保存的升级包
+	if loaded := NewManager(terminals.Request, terminaltest.ErrOffline, m.dir, "").Firmwares(); 1 != len(loaded) || firmware.MD5 != loaded[0].MD5 {
+		t.Fatalf("unexpected loaded firmwares: %+v", loaded)
+	}
+}
+
+func TestCampaignAbort(t *testing.T) {
+	fleet := &fakeFleet{
+		terminals: map[string]*fakeTerminal{
+			"1": {vendor: "ACME", model: "T1", version: "1.0", result: protocol.UpgradeResultFailed},
+			"2": {vendor: "ACME", model: "T1", version: "1.0"},
+		},
+		packages: make(map[string][]byte),
+	}
+	terminals := terminaltest.New(fleet.handle)
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, t.TempDir(), "")
+	fleet.manager = m
+
+	firmware, _ := m.AddFirmware(Firmware{VendorID: "ACME", Version: "2.0"}, []byte{1})
+	info, _ := m.StartCampaign(CampaignRequest{Firmware: firmware.ID, Phones: []string{"1", "2"}, Stages: []int{1}})
+	info = waitCampaign(t, m, info.ID, CampaignPaused)
+	if err := m.DeleteFirmware(firmware.ID); ErrFirmwareInUse != err {
+		t.Fatalf("got %v, want ErrFirmwareInUse", err)
+	}
+
+	info, err := m.Control(info.ID, ControlAbort)
+	if nil != err || TargetAborted != info.Targets[1].State {
+		t.Fatalf("unexpected abort: %v %+v", err, info.Targets)
+	}
+	waitCampaign(t, m, info.ID, CampaignAborted)
+	for _, output := range terminals.Received("2") {
+		if _, ok := output.(*protocol.MsgTerminalUpgrade); ok {
+			t.Fatal("aborted target should not be upgraded")
+		}
+	}
+}
+
+func TestCampaignReload(t *testing.T) {
+	fleet := &fakeFleet{
+		terminals: map[string]*fakeTerminal{
+			"1": {vendor: "ACME", model: "T1", version: "1.0", result: protocol.UpgradeResultFailed},
+			"2": {vendor: "ACME", model: "T1", version: "1.0"},
+		},
+		packages: make(map[string][]byte),
+	}
+	terminals := terminaltest.New(fleet.handle)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "upgrades.json")
+	m := NewManager(terminals.Request, terminaltest.ErrOffline, dir, file)
+	fleet.manager = m
+
+	firmware, _ := m.AddFirmware(Firmware{VendorID: "ACME", Version: "2.0"}, []byte{1})
+	info, _ := m.StartCampaign(CampaignRequest{Firmware: firmware.ID, Phones: []string{"1", "2"}, Stages: []int{1}})
+	info = waitCampaign(t, m, info.ID, CampaignPaused)
+
+	// 模拟重启前终端2正在升级
+	m.mtx.Lock()
+	m.campaigns[info.ID].info.Targets[1].State = TargetUpgrading
+	m.save()
+	m.mtx.Unlock()
+
+	m = NewManager(terminals.Request, terminaltest.ErrOffline, dir, file)
+	fleet.manager = m
+	if err := m.load(); nil != err {
+		t.Fatal(err)
+	}
+	loaded, ok := m.Campaign(info.ID)
+	if !ok || CampaignPaused != loaded.State || TargetFailed != loaded.Targets[0].State {
+		t.Fatalf("unexpected loaded campaign: %+v", loaded)
+	}
+	if target := loaded.Targets[1]; TargetFailed != target.State || errInterrupted.Error() != target.Error {
+		t.Fatalf("unexpected interrupted target: %+v", target)
+	}
+
+	// 继续执行后结束，重启时中断的终端不再升级
+	if _, err := m.Control(info.ID, ControlResume); nil != err {
+		t.Fatal(err)
+	}
+	waitCampaign(t, m, info.ID, CampaignFinished)
+	if 0 != len(terminals.Received("2")) {
+		t.Fatal("interrupted target should not be upgraded again")
+	}
+}
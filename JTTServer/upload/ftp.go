@@ -0,0 +1,445 @@
+package upload
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 数据连接建立超时时间
+const dataTimeout = time.Second * 30
+
+// ftpAccount FTP账户，由ftpAuth提供
+type ftpAccount interface {
+	// 账户根目录
+	Root() string
+
+	// 文件开始上传，name为相对根目录的路径
+	OnStoring(name string)
+
+	// 文件上传结束，err为nil时上传成功
+	OnStored(name string, size int64, err error)
+}
+
+// ftpAuth FTP登录验证，失败时返回nil
+type ftpAuth func(user, pass string) ftpAccount
+
+// FTPServer 只接收上传的精简FTP服务，支持被动模式（PASV/EPSV）与主动模式（PORT），
+// 每个账户只能访问自己的根目录
+type FTPServer struct {
+	auth ftpAuth
+	// 被动模式下告知终端的IP地址，为空时使用控制连接的本地地址
+	PassiveIP string
+
+	mtx      sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// newFTPServer 新建FTP服务
+func newFTPServer(auth ftpAuth) *FTPServer {
+	return &FTPServer{
+		auth:  auth,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe 监听并服务，阻塞直到服务关闭
+func (s *FTPServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if nil != err {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在已建立的监听上服务，阻塞直到服务关闭
+func (s *FTPServer) Serve(l net.Listener) error {
+	s.mtx.Lock()
+	s.listener = l
+	s.mtx.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if nil != err {
+			return err
+		}
+
+		s.mtx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mtx.Unlock()
+
+		go func() {
+			newFTPSession(s, conn).serve()
+
+			s.mtx.Lock()
+			delete(s.conns, conn)
+			s.mtx.Unlock()
+		}()
+	}
+}
+
+// Close 关闭服务及所有连接
+func (s *FTPServer) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if nil != s.listener {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+// ftpSession 一个FTP控制连接
+type ftpSession struct {
+	server  *FTPServer
+	conn    net.Conn
+	reader  *bufio.Reader
+	user    string
+	account ftpAccount
+	cwd     string // 当前目录，相对账户根目录，以/开头
+	offset  int64  // REST断点位置
+
+	passive net.Listener // 被动模式数据监听
+	active  string       // 主动模式数据地址
+}
+
+func newFTPSession(server *FTPServer, conn net.Conn) *ftpSession {
+	return &ftpSession{
+		server: server,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		cwd:    "/",
+	}
+}
+
+func (s *ftpSession) reply(code int, message string) {
+	fmt.Fprintf(s.conn, "%d %s\r\n", code, message)
+}
+
+func (s *ftpSession) serve() {
+	defer s.conn.Close()
+	defer s.closeData()
+
+	s.reply(220, "JTT upload service ready")
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(time.Minute * 5))
+		line, err := s.reader.ReadString('\n')
+		if nil != err {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg := line, ""
+		if idx := strings.IndexByte(line, ' '); idx >= 0 {
+			cmd, arg = line[:idx], line[idx+1:]
+		}
+		cmd = strings.ToUpper(cmd)
+
+		if nil == s.account && !s.anonymousAllowed(cmd) {
+			s.reply(530, "Please login with USER and PASS")
+			continue
+		}
+		if !s.handle(cmd, arg) {
+			return
+		}
+	}
+}
+
+// 登录前允许的命令
+func (s *ftpSession) anonymousAllowed(cmd string) bool {
+	switch cmd {
+	case "USER", "PASS", "QUIT", "FEAT", "SYST", "NOOP", "OPTS", "AUTH":
+		return true
+	}
+	return false
+}
+
+// handle 处理一条命令，返回false时关闭连接
+func (s *ftpSession) handle(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		s.user, s.account = arg, nil
+		s.reply(331, "User name okay, need password")
+	case "PASS":
+		if s.account = s.server.auth(s.user, arg); nil == s.account {
+			s.reply(530, "Login incorrect")
+		} else {
+			s.reply(230, "User logged in")
+		}
+	case "QUIT":
+		s.reply(221, "Goodbye")
+		return false
+	case "AUTH":
+		s.reply(502, "TLS is not supported")
+	case "SYST":
+		s.reply(215, "UNIX Type: L8")
+	case "FEAT":
+		fmt.Fprint(s.conn, "211-Features:\r\n SIZE\r\n REST STREAM\r\n EPSV\r\n UTF8\r\n211 End\r\n")
+	case "NOOP", "OPTS", "MODE", "STRU", "ALLO":
+		s.reply(200, "OK")
+	case "TYPE":
+		s.reply(200, "Type set to "+arg)
+	case "PWD", "XPWD":
+		s.reply(257, strconv.Quote(s.cwd))
+	case "CWD", "XCWD":
+		s.changeDir(arg)
+	case "CDUP", "XCUP":
+		s.changeDir("..")
+	case "MKD", "XMKD":
+		if err := os.MkdirAll(s.localPath(arg), 0755); nil != err {
+			s.reply(550, "Create directory failed")
+		} else {
+			s.reply(257, strconv.Quote(s.virtualPath(arg)))
+		}
+	case "SIZE":
+		if info, err := os.Stat(s.localPath(arg)); nil != err || info.IsDir() {
+			s.reply(550, "File not found")
+		} else {
+			s.reply(213, strconv.FormatInt(info.Size(), 10))
+		}
+	case "REST":
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if nil != err || offset < 0 {
+			s.reply(501, "Invalid offset")
+		} else {
+			s.offset = offset
+			s.reply(350, "Restarting at "+arg)
+		}
+	case "PASV":
+		s.enterPassive(false)
+	case "EPSV":
+		s.enterPassive(true)
+	case "PORT":
+		s.enterActive(arg)
+	case "STOR":
+		s.store(arg, false)
+	case "APPE":
+		s.store(arg, true)
+	case "LIST", "NLST":
+		s.list(cmd, arg)
+	default:
+		s.reply(502, "Command not implemented")
+	}
+	return true
+}
+
+// virtualPath 将参数转为相对账户根目录的绝对路径
+func (s *ftpSession) virtualPath(arg string) string {
+	if strings.HasPrefix(arg, "/") {
+		return path.Clean(arg)
+	}
+	return path.Clean(path.Join(s.cwd, arg))
+}
+
+// localPath 将参数转为本地路径，不会超出账户根目录
+func (s *ftpSession) localPath(arg string) string {
+	return filepath.Join(s.account.Root(), filepath.FromSlash(s.virtualPath(arg)))
+}
+
+// changeDir 切换目录，目录不存在时创建（终端通常直接切换到上传路径）
+func (s *ftpSession) changeDir(arg string) {
+	if err := os.MkdirAll(s.localPath(arg), 0755); nil != err {
+		s.reply(550, "Change directory failed")
+		return
+	}
+	s.cwd = s.virtualPath(arg)
+	s.reply(250, "Directory changed to "+s.cwd)
+}
+
+func (s *ftpSession) enterPassive(extended bool) {
+	s.closeData()
+
+	host, _, _ := net.SplitHostPort(s.conn.LocalAddr().String())
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if nil != err {
+		s.reply(425, "Can't open data connection")
+		return
+	}
+	s.passive = l
+	port := l.Addr().(*net.TCPAddr).Port
+
+	if extended {
+		s.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+		return
+	}
+
+	ip := net.ParseIP(s.server.PassiveIP)
+	if nil == ip {
+		ip = net.ParseIP(host)
+	}
+	ip4 := ip.To4()
+	if nil == ip4 {
+		s.reply(425, "Use EPSV for IPv6")
+		return
+	}
+	s.reply(227, fmt.Sprintf("Entering Passive Mode (%d,%d,%d,%d,%d,%d)", ip4[0], ip4[1], ip4[2], ip4[3], port>>8, port&0xFF))
+}
+
+func (s *ftpSession) enterActive(arg string) {
+	s.closeData()
+
+	parts := strings.Split(arg, ",")
+	if 6 != len(parts) {
+		s.reply(501, "Invalid PORT")
+		return
+	}
+	hi, err1 := strconv.Atoi(parts[4])
+	lo, err2 := strconv.Atoi(parts[5])
+	ip := net.ParseIP(strings.Join(parts[:4], "."))
+	if nil != err1 || nil != err2 || nil == ip || hi < 0 || hi > 255 || lo < 0 || lo > 255 || 0 == hi<<8|lo {
+		s.reply(501, "Invalid PORT")
+		return
+	}
+	// 只连接控制连接的对端，避免FTP跳板攻击
+	if !s.isPeer(ip) {
+		s.reply(500, "Illegal PORT command")
+		return
+	}
+	s.active = net.JoinHostPort(ip.String(), strconv.Itoa(hi<<8|lo))
+	s.reply(200, "PORT command successful")
+}
+
+// isPeer 是否为控制连接的对端地址
+func (s *ftpSession) isPeer(ip net.IP) bool {
+	addr, ok := s.conn.RemoteAddr().(*net.TCPAddr)
+	return ok && addr.IP.Equal(ip)
+}
+
+// openData 建立数据连接
+func (s *ftpSession) openData() (net.Conn, error) {
+	defer s.closeData()
+
+	if nil != s.passive {
+		s.passive.(*net.TCPListener).SetDeadline(time.Now().Add(dataTimeout))
+		for {
+			conn, err := s.passive.Accept()
+			if nil != err {
+				return nil, err
+			}
+			// 拒绝控制连接对端以外的数据连接
+			if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && s.isPeer(addr.IP) {
+				return conn, nil
+			}
+			log.Printf("FTP拒绝来自[%s]的数据连接", conn.RemoteAddr())
+			conn.Close()
+		}
+	}
+	if "" != s.active {
+		return net.DialTimeout("tcp", s.active, dataTimeout)
+	}
+	return nil, fmt.Errorf("no data connection")
+}
+
+func (s *ftpSession) closeData() {
+	if nil != s.passive {
+		s.passive.Close()
+		s.passive = nil
+	}
+	s.active = ""
+}
+
+// store 接收上传文件，appendMode为true时追加，否则从REST位置写入
+func (s *ftpSession) store(arg string, appendMode bool) {
+	offset := s.offset
+	s.offset = 0
+
+	name := s.virtualPath(arg)
+	local := s.localPath(arg)
+	if err := os.MkdirAll(filepath.Dir(local), 0755); nil != err {
+		s.reply(550, "Create directory failed")
+		return
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if appendMode {
+		flag |= os.O_APPEND
+	} else if 0 == offset {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(local, flag, 0644)
+	if nil != err {
+		s.reply(550, "Open file failed")
+		return
+	}
+	defer f.Close()
+	if !appendMode && offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); nil != err {
+			s.reply(550, "Seek failed")
+			return
+		}
+	}
+
+	s.reply(150, "Opening data connection")
+	data, err := s.openData()
+	if nil != err {
+		s.reply(425, "Can't open data connection")
+		return
+	}
+
+	s.account.OnStoring(name)
+	_, err = io.Copy(f, data)
+	data.Close()
+
+	size := int64(0)
+	if info, serr := f.Stat(); nil == serr {
+		size = info.Size()
+	}
+	s.account.OnStored(name, size, err)
+
+	if nil != err {
+		log.Printf("FTP用户[%s]上传[%s]失败：%s", s.user, name, err)
+		s.reply(426, "Transfer aborted")
+		return
+	}
+	s.reply(226, "Transfer complete")
+}
+
+// list 列出目录，只输出文件名（NLST）或简单的长格式（LIST）
+func (s *ftpSession) list(cmd, arg string) {
+	if strings.HasPrefix(arg, "-") {
+		arg = ""
+	}
+	entries, err := os.ReadDir(s.localPath(arg))
+	if nil != err && !os.IsNotExist(err) {
+		s.reply(550, "List failed")
+		return
+	}
+
+	s.reply(150, "Opening data connection")
+	data, err := s.openData()
+	if nil != err {
+		s.reply(425, "Can't open data connection")
+		return
+	}
+	defer data.Close()
+
+	for _, entry := range entries {
+		if "NLST" == cmd {
+			fmt.Fprintf(data, "%s\r\n", entry.Name())
+			continue
+		}
+		info, err := entry.Info()
+		if nil != err {
+			continue
+		}
+		mode := "-rw-r--r--"
+		if info.IsDir() {
+			mode = "drwxr-xr-x"
+		}
+		fmt.Fprintf(data, "%s 1 ftp ftp %d %s %s\r\n", mode, info.Size(), info.ModTime().Format("Jan 02 15:04"), entry.Name())
+	}
+	s.reply(226, "Transfer complete")
+}
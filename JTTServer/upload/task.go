@@ -0,0 +1,400 @@
+package upload
+
+import (
+	"JTTServer/terminal"
+	"JTTServer/util"
+	"common/protocol"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// UploadApp 默认的文件上传服务，由Run初始化
+	UploadApp *Manager
+)
+
+var (
+	// ErrTaskNotFound 上传任务不存在
+	ErrTaskNotFound = errors.New("the upload task does not exist")
+	// ErrTaskDone 上传任务已结束
+	ErrTaskDone = errors.New("the upload task is already done")
+)
+
+// Run 运行文件上传服务，addr为FTP监听地址
+//
+// upload.Run("0.0.0.0:2121", jtt.Request, config)
+func Run(addr string, request terminal.Requester, config Config) {
+	UploadApp = NewManager(request, config)
+	go func() {
+		if err := UploadApp.FTP().ListenAndServe(addr); nil != err {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// Config 文件上传服务配置
+type Config struct {
+	// 上传文件存储目录，按终端手机号与任务ID分目录存放
+	Dir string
+	// 下发给终端的FTP服务地址
+	IP string
+	// 下发给终端的FTP服务端口
+	Port uint16
+}
+
+// 上传任务状态
+const (
+	TaskUploading = "uploading" // 上传中
+	TaskPaused    = "paused"    // 已暂停
+	TaskFinished  = "finished"  // 终端通知上传成功
+	TaskFailed    = "failed"    // 终端通知上传失败
+	TaskCancelled = "cancelled" // 已取消
+)
+
+// 上传控制，对应0x9207上传控制字段
+const (
+	ControlPause  = byte(0) // 暂停
+	ControlResume = byte(1) // 继续
+	ControlCancel = byte(2) // 取消
+)
+
+// UploadRequest 文件上传请求，对应0x9206中的资源条件
+type UploadRequest struct {
+	// 逻辑通道号
+	Channel byte `json:"channel"`
+	// 开始时间
+	STime time.Time `json:"start_time"`
+	// 结束时间
+	ETime time.Time `json:"end_time"`
+	// 报警标志
+	Alarm uint64 `json:"alarm"`
+	// 音视频资源类型，0-音视频，1-音频，2-视频，3-视频或音频
+	MediaType byte `json:"media_type"`
+	// 码流类型，0-主码流或子码流，1-主码流，2-子码流
+	StreamType byte `json:"stream_type"`
+	// 存储位置，0-主存储器或灾备存储器，1-主存储器，2-灾备存储器
+	StorageType byte `json:"storage_type"`
+	// 任务执行条件，按位：0-WIFI下可下载，1-LAN连接时可下载，2-3G/4G连接时可下载
+	ExeCondition byte `json:"exe_condition"`
+}
+
+// FileInfo 终端已上传的文件
+type FileInfo struct {
+	// 相对任务目录的文件路径
+	Name string `json:"name"`
+	// 文件大小
+	Size int64 `json:"size"`
+	// 是否接收完整
+	Done bool `json:"done"`
+	// 接收失败原因
+	Error string `json:"error,omitempty"`
+}
+
+// TaskInfo 上传任务信息
+type TaskInfo struct {
+	// 任务ID
+	ID string `json:"id"`
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 0x9206消息流水号，终端以此关联0x1206与0x9207
+	Number uint16 `json:"number"`
+	// 上传请求
+	Request UploadRequest `json:"request"`
+	// 任务状态，见TaskXXX
+	State string `json:"state"`
+	// 任务目录
+	Dir string `json:"dir"`
+	// 已上传的文件
+	Files []FileInfo `json:"files"`
+	// 创建时间
+	Created time.Time `json:"created"`
+	// 结束时间
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// uploadTask 上传任务，同时作为该任务的FTP账户
+type uploadTask struct {
+	manager  *Manager
+	info     TaskInfo
+	user     string
+	password string
+}
+
+// Root 任务目录
+func (t *uploadTask) Root() string {
+	return t.info.Dir
+}
+
+// OnStoring 终端开始上传文件
+func (t *uploadTask) OnStoring(name string) {
+	m := t.manager
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	t.file(name).Done = false
+}
+
+// OnStored 终端上传文件结束
+func (t *uploadTask) OnStored(name string, size int64, err error) {
+	m := t.manager
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	file := t.file(name)
+	file.Size = size
+	file.Done = nil == err
+	file.Error = ""
+	if nil != err {
+		file.Error = err.Error()
+	}
+}
+
+// file 获取文件记录，不存在时新建，调用方需持有锁
+func (t *uploadTask) file(name string) *FileInfo {
+	name = strings.TrimPrefix(name, "/")
+	for idx := range t.info.Files {
+		if name == t.info.Files[idx].Name {
+			return &t.info.Files[idx]
+		}
+	}
+	t.info.Files = append(t.info.Files, FileInfo{Name: name})
+	return &t.info.Files[len(t.info.Files)-1]
+}
+
+// snapshot 复制任务信息，调用方需持有锁
+func (t *uploadTask) snapshot() TaskInfo {
+	info := t.info
+	info.Files = append([]FileInfo{}, t.info.Files...)
+	return info
+}
+
+// taskKey 终端与0x9206流水号，用于关联0x1206
+type taskKey struct {
+	phone  string
+	number uint16
+}
+
+// Manager 文件上传服务。
+//
+// 每个0x9206任务生成独立的FTP账户与目录，终端上传到内置FTP服务；
+// 终端以0x1206通知上传完成时按0x9206流水号关联任务，任务结束后账户失效。
+type Manager struct {
+	request  terminal.Requester
+	config   Config
+	ftp      *FTPServer
+	mtx      sync.Mutex
+	tasks    map[string]*uploadTask
+	users    map[string]*uploadTask
+	numbers  map[taskKey]*uploadTask
+	sequence uint32
+}
+
+// NewManager 新建文件上传服务
+func NewManager(request terminal.Requester, config Config) *Manager {
+	if "" == config.Dir {
+		config.Dir = "uploads"
+	}
+
+	m := &Manager{
+		request: request,
+		config:  config,
+		tasks:   make(map[string]*uploadTask),
+		users:   make(map[string]*uploadTask),
+		numbers: make(map[taskKey]*uploadTask),
+	}
+	m.ftp = newFTPServer(m.auth)
+	m.ftp.PassiveIP = config.IP
+	return m
+}
+
+// FTP 内置FTP服务
+func (m *Manager) FTP() *FTPServer {
+	return m.ftp
+}
+
+// auth FTP登录验证，只接受未结束任务的账户
+func (m *Manager) auth(user, pass string) ftpAccount {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	task, ok := m.users[user]
+	if !ok || pass != task.password {
+		return nil
+	}
+	return task
+}
+
+// Start 新建上传任务并下发文件上传指令（0x9206），终端通用应答成功后返回任务信息
+func (m *Manager) Start(phone string, req UploadRequest) (TaskInfo, error) {
+	phone = strings.TrimLeft(phone, "0")
+
+	id := fmt.Sprintf("%s-%d", phone, atomic.AddUint32(&m.sequence, 1))
+	task := &uploadTask{
+		manager:  m,
+		user:     "u" + strings.ReplaceAll(id, "-", ""),
+		password: util.RandomID(),
+		info: TaskInfo{
+			ID:      id,
+			Phone:   phone,
+			Request: req,
+			State:   TaskUploading,
+			Dir:     filepath.Join(m.config.Dir, phone, id),
+			Files:   []FileInfo{},
+			Created: time.Now(),
+		},
+	}
+	if err := os.MkdirAll(task.info.Dir, 0755); nil != err {
+		return TaskInfo{}, err
+	}
+
+	// 先开通账户，终端可能在应答前即开始上传
+	m.mtx.Lock()
+	m.tasks[id] = task
+	m.users[task.user] = task
+	m.mtx.Unlock()
+
+	msg := protocol.NewMsgFileUploadCmd()
+	msg.ServerAddr = m.config.IP
+	msg.ServerPort = m.config.Port
+	msg.UserName = task.user
+	msg.Password = task.password
+	msg.Path = "/"
+	msg.Channel = req.Channel
+	if !req.STime.IsZero() {
+		msg.STime = req.STime.In(terminal.CST)
+	}
+	if !req.ETime.IsZero() {
+		msg.ETime = req.ETime.In(terminal.CST)
+	}
+	msg.Alarm = req.Alarm
+	msg.MediaType = req.MediaType
+	msg.StreamType = req.StreamType
+	msg.StorageType = req.StorageType
+	msg.ExeCondition = req.ExeCondition
+
+	input, err := m.request(phone, msg, terminal.RequestTimeout)
+	if nil == err {
+		err = terminal.CheckResult(input)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if nil != err {
+		delete(m.tasks, id)
+		delete(m.users, task.user)
+		return TaskInfo{}, err
+	}
+
+	// 通用应答的应答流水号即0x9206的流水号
+	task.info.Number, _ = protocol.ReplyNumber(input)
+	m.numbers[taskKey{phone, task.info.Number}] = task
+	return task.snapshot(), nil
+}
+
+// Control 暂停、继续或取消上传任务（0x9207），ctl见ControlXXX
+func (m *Manager) Control(id string, ctl byte) (TaskInfo, error) {
+	m.mtx.Lock()
+	task, ok := m.tasks[id]
+	if !ok {
+		m.mtx.Unlock()
+		return TaskInfo{}, ErrTaskNotFound
+	}
+	if !task.running() {
+		m.mtx.Unlock()
+		return TaskInfo{}, ErrTaskDone
+	}
+	phone, number := task.info.Phone, task.info.Number
+	m.mtx.Unlock()
+
+	msg := protocol.NewMsgFileUploadCtl()
+	msg.ReqNum = number
+	msg.Ctl = ctl
+	input, err := m.request(phone, msg, terminal.RequestTimeout)
+	if nil == err {
+		err = terminal.CheckResult(input)
+	}
+	if nil != err {
+		return TaskInfo{}, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if task.running() {
+		switch ctl {
+		case ControlPause:
+			task.info.State = TaskPaused
+		case ControlResume:
+			task.info.State = TaskUploading
+		case ControlCancel:
+			m.finishLocked(task, TaskCancelled)
+		}
+	}
+	return task.snapshot(), nil
+}
+
+// Finish 处理终端文件上传完成通知（0x1206），按0x9206流水号关联任务，未关联到任务时返回false
+func (m *Manager) Finish(phone string, msg *protocol.MsgFileUploadFinish) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	task, ok := m.numbers[taskKey{strings.TrimLeft(phone, "0"), msg.ReqNum}]
+	if !ok || !task.running() {
+		return false
+	}
+
+	state := TaskFinished
+	if 0 != msg.Result {
+		state = TaskFailed
+	}
+	m.finishLocked(task, state)
+	log.Printf("终端[%s]上传任务[%s]结束：%s", task.info.Phone, task.info.ID, state)
+	return true
+}
+
+// finishLocked 结束任务并注销FTP账户，调用方需持有锁
+func (m *Manager) finishLocked(task *uploadTask, state string) {
+	task.info.State = state
+	task.info.Finished = time.Now()
+	delete(m.users, task.user)
+	delete(m.numbers, taskKey{task.info.Phone, task.info.Number})
+}
+
+// Task 获取上传任务信息
+func (m *Manager) Task(id string) (TaskInfo, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	task, ok := m.tasks[id]
+	if !ok {
+		return TaskInfo{}, false
+	}
+	return task.snapshot(), true
+}
+
+// Tasks 获取所有上传任务信息，按创建时间排序
+func (m *Manager) Tasks() []TaskInfo {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	list := make([]TaskInfo, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		list = append(list, task.snapshot())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
+
+// running 任务是否未结束
+func (t *uploadTask) running() bool {
+	return TaskUploading == t.info.State || TaskPaused == t.info.State
+}
@@ -0,0 +1,177 @@
+package upload
+
+import (
+	"common/protocol"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRequester struct {
+	mtx     sync.Mutex
+	outputs []protocol.Output
+}
+
+func (r *testRequester) request(phone string, output protocol.Output, timeout time.Duration, replyIDs ...uint16) (protocol.Input, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.outputs = append(r.outputs, output)
+
+	resp := &protocol.MsgTerminalResponse{}
+	resp.ReqNum = uint16(len(r.outputs) + 100)
+	return resp, nil
+}
+
+func (r *testRequester) last() protocol.Output {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.outputs[len(r.outputs)-1]
+}
+
+// ftpStore 以被动模式登录并上传一个文件
+func ftpStore(t *testing.T, addr, user, pass, name string, data []byte) error {
+	conn, err := textproto.Dial("tcp", addr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, _, err := conn.ReadResponse(220); nil != err {
+		t.Fatal(err)
+	}
+	cmd := func(expect int, format string, args ...interface{}) (string, error) {
+		if _, err := conn.Cmd(format, args...); nil != err {
+			return "", err
+		}
+		_, message, err := conn.ReadResponse(expect)
+		return message, err
+	}
+
+	if _, err := cmd(331, "USER %s", user); nil != err {
+		return err
+	}
+	if _, err := cmd(230, "PASS %s", pass); nil != err {
+		return err
+	}
+	if _, err := cmd(250, "CWD /video"); nil != err {
+		return err
+	}
+	message, err := cmd(227, "PASV")
+	if nil != err {
+		return err
+	}
+	var h1, h2, h3, h4, p1, p2 int
+	if _, err := fmt.Sscanf(message, "Entering Passive Mode (%d,%d,%d,%d,%d,%d)", &h1, &h2, &h3, &h4, &p1, &p2); nil != err {
+		t.Fatal(err)
+	}
+
+	data1, err := net.Dial("tcp", fmt.Sprintf("%d.%d.%d.%d:%d", h1, h2, h3, h4, p1<<8|p2))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := cmd(150, "STOR %s", name); nil != err {
+		return err
+	}
+	data1.Write(data)
+	data1.Close()
+	if _, _, err := conn.ReadResponse(226); nil != err {
+		return err
+	}
+	_, err = cmd(221, "QUIT")
+	return err
+}
+
+func TestUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	requester := &testRequester{}
+	m := NewManager(requester.request, Config{Dir: dir, IP: "127.0.0.1", Port: 2121})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	go m.FTP().Serve(l)
+	defer m.FTP().Close()
+
+	info, err := m.Start("013912345678", UploadRequest{Channel: 1})
+	if nil != err {
+		t.Fatal(err)
+	}
+	cmd := requester.last().(*protocol.MsgFileUploadCmd)
+	if "127.0.0.1" != cmd.ServerAddr || 2121 != cmd.ServerPort || 1 != cmd.Channel || 101 != info.Number {
+		t.Fatalf("unexpected command: %+v %+v", cmd, info)
+	}
+
+	// 错误密码无法登录
+	if nil == ftpStore(t, l.Addr().String(), cmd.UserName, "bad", "a.mp4", nil) {
+		t.Fatal("login must fail")
+	}
+	if err := ftpStore(t, l.Addr().String(), cmd.UserName, cmd.Password, "a.mp4", []byte("0123456789")); nil != err {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "13912345678", info.ID, "video", "a.mp4"))
+	if nil != err || "0123456789" != string(data) {
+		t.Fatalf("unexpected file: %q %v", data, err)
+	}
+
+	// 主动模式只连接控制连接的对端
+	conn, err := textproto.Dial("tcp", l.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.ReadResponse(220)
+	for _, step := range []struct {
+		cmd    string
+		expect int
+	}{
+		{"USER " + cmd.UserName, 331},
+		{"PASS " + cmd.Password, 230},
+		{"PORT 10,0,0,1,0,80", 500},
+		{"PORT 127,0,0,1,300,80", 501},
+		{"PORT 127,0,0,1,0,80", 200},
+	} {
+		conn.Cmd("%s", step.cmd)
+		if _, message, err := conn.ReadResponse(step.expect); nil != err {
+			t.Fatalf("%s: %s %v", step.cmd, message, err)
+		}
+	}
+	conn.Close()
+
+	// 暂停下发0x9207，应答流水号为0x9206的流水号
+	if info, err = m.Control(info.ID, ControlPause); nil != err || TaskPaused != info.State {
+		t.Fatalf("unexpected pause: %+v %v", info, err)
+	}
+	if ctl := requester.last().(*protocol.MsgFileUploadCtl); 101 != ctl.ReqNum || ControlPause != ctl.Ctl {
+		t.Fatalf("unexpected control: %+v", ctl)
+	}
+
+	// 流水号不匹配的完成通知不关联
+	if m.Finish("13912345678", &protocol.MsgFileUploadFinish{ReqNum: 1}) {
+		t.Fatal("unexpected correlation")
+	}
+	if !m.Finish("13912345678", &protocol.MsgFileUploadFinish{ReqNum: 101}) {
+		t.Fatal("finish not correlated")
+	}
+	info, _ = m.Task(info.ID)
+	if TaskFinished != info.State || 1 != len(info.Files) || "video/a.mp4" != info.Files[0].Name || 10 != info.Files[0].Size || !info.Files[0].Done {
+		t.Fatalf("unexpected task: %+v", info)
+	}
+
+	// 任务结束后账户失效
+	if nil == ftpStore(t, l.Addr().String(), cmd.UserName, cmd.Password, "b.mp4", nil) {
+		t.Fatal("login must fail after finish")
+	}
+	if _, err := m.Control(info.ID, ControlResume); ErrTaskDone != err {
+		t.Fatalf("got %v, want ErrTaskDone", err)
+	}
+}
@@ -0,0 +1,39 @@
+// Package util 业务服务的公共工具函数
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// RandomID 生成随机ID
+func RandomID() string {
+	return RandomHex(8)
+}
+
+// RandomHex 生成size个随机字节，返回其十六进制字符串
+func RandomHex(size int) string {
+	value := make([]byte, size)
+	rand.Read(value)
+	return hex.EncodeToString(value)
+}
+
+// SaveJSON 将v以JSON格式保存到文件，先写入临时文件再替换，避免写入中断时损坏原文件
+func SaveJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if nil != err {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); nil != err {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); nil != err {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
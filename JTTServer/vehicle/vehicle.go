@@ -0,0 +1,128 @@
+package vehicle
+
+import (
+	"JTTServer/terminal"
+	"common/protocol"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// VehicleApp 默认的车辆控制服务，由Setup初始化
+	VehicleApp *Manager
+)
+
+var (
+	// ErrNoParams 未指定控制类型
+	ErrNoParams = errors.New("at least one control type is required")
+)
+
+// Setup 初始化默认的车辆控制服务
+//
+// vehicle.Setup(jtt.Request)
+func Setup(request terminal.Requester) {
+	VehicleApp = NewManager(request)
+}
+
+// 终端执行车辆控制后才应答，等待时间较长
+const requestTimeout = time.Second * 30
+
+// Result 车辆控制结果
+type Result struct {
+	// 终端手机号
+	Phone string `json:"phone"`
+	// 下发的控制参数
+	Params []protocol.VehCtrlParam `json:"params"`
+	// 应答的位置信息
+	Position *protocol.Position `json:"position,omitempty"`
+	// 位置信息状态位与控制参数是否一致，未定义状态位的控制类型不参与校验
+	Verified bool `json:"verified"`
+	// 状态位与控制参数不一致的控制类型ID
+	Mismatched []uint16 `json:"mismatched,omitempty"`
+	// 失败原因
+	Error string `json:"error,omitempty"`
+	// 控制时间
+	Time time.Time `json:"time"`
+}
+
+// Manager 车辆控制服务。
+//
+// 以0x8500下发车辆控制，等待终端0x0500应答，并以应答位置信息中的状态位
+// （车门加锁、油路断开、电路断开等）确认控制已生效。
+type Manager struct {
+	request terminal.Requester
+
+	mtx     sync.Mutex
+	results map[string]*Result
+}
+
+// NewManager 新建车辆控制服务
+func NewManager(request terminal.Requester) *Manager {
+	return &Manager{
+		request: request,
+		results: make(map[string]*Result),
+	}
+}
+
+// Control 下发车辆控制并校验结果，控制参数按protocol.VehCtrlDefs中的定义转换类型
+func (m *Manager) Control(phone string, params []protocol.VehCtrlParam) (Result, error) {
+	if 0 == len(params) {
+		return Result{}, ErrNoParams
+	}
+
+	msg := protocol.NewMsgVehicleControl()
+	for _, param := range params {
+		for _, added := range msg.Params {
+			if added.ID == param.ID {
+				return Result{}, fmt.Errorf("duplicate control type %#04x", param.ID)
+			}
+		}
+		param, err := protocol.NewVehCtrlParam(param.ID, param.Value)
+		if nil != err {
+			return Result{}, err
+		}
+		msg.Params = append(msg.Params, param)
+	}
+
+	result := Result{Phone: phone, Params: msg.Params, Time: time.Now()}
+	err := m.control(phone, msg, &result)
+	if nil != err {
+		result.Error = err.Error()
+	}
+
+	m.mtx.Lock()
+	m.results[phone] = &result
+	m.mtx.Unlock()
+	return result, err
+}
+
+// Result 获取终端最近一次的车辆控制结果
+func (m *Manager) Result(phone string) (Result, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	result, ok := m.results[phone]
+	if !ok {
+		return Result{}, false
+	}
+	return *result, true
+}
+
+// control 下发车辆控制，等待应答并校验状态位
+func (m *Manager) control(phone string, msg *protocol.MsgVehicleControl, result *Result) error {
+	input, err := m.request(phone, msg, requestTimeout, protocol.MsgIDVehicleControlResp)
+	if nil != err {
+		return err
+	}
+	resp, ok := input.(*protocol.MsgVehicleControlResp)
+	if !ok {
+		return terminal.ErrUnexpectedData
+	}
+
+	result.Position = &resp.Position
+	result.Mismatched = protocol.VerifyVehCtrl(msg.Params, resp.Position.Status)
+	result.Verified = 0 == len(result.Mismatched)
+	return nil
+}
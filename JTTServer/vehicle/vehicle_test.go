@@ -0,0 +1,63 @@
+package vehicle
+
+import (
+	"JTTServer/terminal/terminaltest"
+	"common/protocol"
+	"testing"
+)
+
+// fakeVehicle 模拟终端，按控制参数修改状态位，ignore中的控制类型不生效
+type fakeVehicle struct {
+	status protocol.StatusFlag
+	ignore uint16
+}
+
+func (f *fakeVehicle) handle(phone string, output protocol.Output, replyIDs []uint16) (protocol.Input, error) {
+	msg, ok := output.(*protocol.MsgVehicleControl)
+	if !ok || 1 != len(replyIDs) || protocol.MsgIDVehicleControlResp != replyIDs[0] {
+		return nil, terminaltest.ErrUnexpectedMessage
+	}
+	for _, param := range msg.Params {
+		if param.ID == f.ignore {
+			continue
+		}
+		switch param.ID {
+		case protocol.VehCtrlIDDoor:
+			f.status.DoorLock(protocol.VehCtrlDoorLock == param.Value)
+		case protocol.VehCtrlIDOil:
+			f.status.OffOil(protocol.VehCtrlCut == param.Value)
+		}
+	}
+	resp := &protocol.MsgVehicleControlResp{}
+	resp.Position.Status = f.status
+	return resp, nil
+}
+
+func TestControl(t *testing.T) {
+	vehicle := &fakeVehicle{ignore: protocol.VehCtrlIDCircuit}
+	m := NewManager(terminaltest.New(vehicle.handle).Request)
+
+	// JSON解析的数值为float64
+	result, err := m.Control("1", []protocol.VehCtrlParam{
+		{ID: protocol.VehCtrlIDDoor, Value: float64(0)},
+		{ID: protocol.VehCtrlIDOil, Value: float64(1)},
+	})
+	if nil != err || !result.Verified || !vehicle.status.IsDoorLock() || !vehicle.status.IsOffOil() {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+
+	result, err = m.Control("1", []protocol.VehCtrlParam{{ID: protocol.VehCtrlIDCircuit, Value: 1}})
+	if nil != err || result.Verified || 1 != len(result.Mismatched) {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+	if last, ok := m.Result("1"); !ok || last.Verified {
+		t.Fatalf("unexpected last result: %+v", last)
+	}
+
+	if _, err := m.Control("1", []protocol.VehCtrlParam{{ID: 1, Value: 0}, {ID: 1, Value: 1}}); nil == err {
+		t.Fatal("duplicate control type must be rejected")
+	}
+	if _, err := m.Control("1", nil); ErrNoParams != err {
+		t.Fatalf("got %v, want ErrNoParams", err)
+	}
+}
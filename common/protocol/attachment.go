@@ -0,0 +1,207 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+func init() {
+	RegisterUnmarshals(&Unmarshal{
+		Cmd: MsgIDAlarmAttachInfo,
+		NewUnmarshaler: func() Unmarshaler {
+			return alarmAttachInfoUnmarshal
+		},
+	}, &Unmarshal{
+		Cmd: MsgIDAttachFileInfo,
+		NewUnmarshaler: func() Unmarshaler {
+			return attachFileInfoUnmarshal
+		},
+	}, &Unmarshal{
+		Cmd: MsgIDAttachFileFinish,
+		NewUnmarshaler: func() Unmarshaler {
+			return attachFileFinishUnmarshal
+		},
+	})
+
+	RegisterMarshals(&Marshal{
+		Cmd: msgIDAttachUpload,
+		NewMarshaler: func() Marshaler {
+			return attachUploadMarshal
+		},
+	}, &Marshal{
+		Cmd: msgIDAttachFileFinishResp,
+		NewMarshaler: func() Marshaler {
+			return attachFileFinishRespMarshal
+		},
+	})
+}
+
+// 报警附件信息消息
+func alarmAttachInfoUnmarshal(buf *bytes.Buffer, version byte) (Input, error) {
+	var msg MsgAlarmAttachInfo
+
+	// 终端ID、报警标识号、报警编号、信息类型、附件数量
+	if buf.Len() < 7+16+32+2 {
+		return nil, errors.New("the bad protocol data:body error")
+	}
+	// 每个附件至少包含文件名称长度与文件大小
+	bts := buf.Bytes()
+	off := 7 + 16 + 32 + 2
+	for count := int(bts[off-1]); count > 0; count-- {
+		if off >= len(bts) {
+			return nil, errors.New("the bad protocol data:body error")
+		}
+		off += 1 + int(bts[off]) + 4
+	}
+	if off != len(bts) {
+		return nil, errors.New("the bad protocol data:body error")
+	}
+
+	msg.readBy(buf)
+
+	return &msg, nil
+}
+
+// 文件信息上传
+func attachFileInfoUnmarshal(buf *bytes.Buffer, version byte) (Input, error) {
+	var msg MsgAttachFileInfo
+
+	if bts := buf.Bytes(); 0 == len(bts) || len(bts) != 1+int(bts[0])+1+4 {
+		return nil, errors.New("the bad protocol data:body error")
+	}
+
+	msg.readBy(buf)
+
+	return &msg, nil
+}
+
+// 文件上传完成消息
+func attachFileFinishUnmarshal(buf *bytes.Buffer, version byte) (Input, error) {
+	var msg MsgAttachFileFinish
+
+	if bts := buf.Bytes(); 0 == len(bts) || len(bts) != 1+int(bts[0])+1+4 {
+		return nil, errors.New("the bad protocol data:body error")
+	}
+
+	msg.readBy(buf)
+
+	return &msg, nil
+}
+
+// 报警附件上传指令
+func attachUploadMarshal(output Output, version byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	_, ok := output.(*MsgAttachUploadSb)
+	if !ok {
+		return nil, errors.New("消息体数据与消息ID不符")
+	}
+	output.writeTo(&buf)
+
+	return buf.Bytes(), nil
+}
+
+// 文件上传完成消息应答
+func attachFileFinishRespMarshal(output Output, version byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	_, ok := output.(*MsgAttachFileFinishResp)
+	if !ok {
+		return nil, errors.New("消息体数据与消息ID不符")
+	}
+	output.writeTo(&buf)
+
+	return buf.Bytes(), nil
+}
+
+// readFixedString 读取定长字符串，去除末尾填充的0
+func readFixedString(buf *bytes.Buffer, size int) string {
+	return string(bytes.TrimRight(buf.Next(size), "\x00"))
+}
+
+// writeFixedString 写入定长字符串，超长截断，不足时末尾补0
+func writeFixedString(buf *bytes.Buffer, value string, size int) {
+	if l := len(value); l >= size {
+		buf.Write([]byte(value)[:size])
+	} else {
+		buf.Write([]byte(value))
+		buf.Write(make([]byte, size-l))
+	}
+}
+
+// AttachDataHeaderSize 附件数据包头字节数：帧头标识4字节、文件名称50字节、数据偏移量4字节、数据长度4字节
+const AttachDataHeaderSize = 62
+
+// AttachMaxBodySize 附件数据包数据体最大字节数
+const AttachMaxBodySize = 1 << 20
+
+// ErrAttachMagic 附件数据包帧头标识不匹配
+var ErrAttachMagic = errors.New("the bad protocol data: attachment magic mismatch")
+
+// AttachData 附件数据包（苏标），帧头标识与JT/T1078 RTP包相同（30 31 63 64），
+// 与0x1210、0x1211、0x1212消息在同一连接上传输
+type AttachData struct {
+	// 文件名称
+	Name string
+	// 数据偏移量
+	Offset uint32
+	// 数据体
+	Body []byte
+}
+
+// ParseAttachDataHeader 解析附件数据包头，返回不含数据体的数据包及数据长度，数据不足包头时返回nil
+func ParseAttachDataHeader(bts []byte) (*AttachData, int, error) {
+	if len(bts) < AttachDataHeaderSize {
+		return nil, 0, nil
+	}
+	if !bytes.Equal(rtpMagic, bts[:4]) {
+		return nil, 0, ErrAttachMagic
+	}
+
+	var d AttachData
+	// 文件名称
+	d.Name = string(bytes.TrimRight(bts[4:54], "\x00"))
+	// 数据偏移量
+	d.Offset = binary.BigEndian.Uint32(bts[54:])
+	// 数据长度
+	length := binary.BigEndian.Uint32(bts[58:])
+	if length > AttachMaxBodySize {
+		return nil, 0, errors.New("the bad protocol data: attachment data too large")
+	}
+	return &d, int(length), nil
+}
+
+// ParseAttachData 从字节序中解析一个附件数据包，返回数据包及其占用的字节数；
+// 数据不足一个完整数据包时返回nil和0，数据体引用传入的字节序
+func ParseAttachData(bts []byte) (*AttachData, int, error) {
+	d, length, err := ParseAttachDataHeader(bts)
+	if nil == d {
+		return nil, 0, err
+	}
+	size := AttachDataHeaderSize + length
+	if len(bts) < size {
+		return nil, 0, nil
+	}
+
+	d.Body = bts[AttachDataHeaderSize:size]
+	return d, size, nil
+}
+
+// WriteTo 写入缓存中
+func (d *AttachData) WriteTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+
+	// 帧头标识
+	buf.Write(rtpMagic)
+	// 文件名称
+	writeFixedString(buf, d.Name, 50)
+	// 数据偏移量
+	binary.BigEndian.PutUint32(value, d.Offset)
+	buf.Write(value)
+	// 数据长度
+	binary.BigEndian.PutUint32(value, uint32(len(d.Body)))
+	buf.Write(value)
+	// 数据体
+	buf.Write(d.Body)
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestAlarmAttachInfo(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("T000001")
+	marking := AlarmMarking{TerminalId: "T000001", Time: time.Date(2021, 10, 19, 8, 30, 0, 0, cstZone), Number: 2, Count: 2}
+	marking.writeTo(&buf)
+	writeFixedString(&buf, "A0123456789", 32)
+	buf.Write([]byte{0, 2})
+	for _, file := range []AttachFile{{"00_65_6500_0_A0123456789.jpg", 0x1234}, {"02_65_6500_1_A0123456789.mp4", 0x0A0000}} {
+		buf.WriteByte(byte(len(file.Name)))
+		buf.WriteString(file.Name)
+		buf.Write([]byte{byte(file.Size >> 24), byte(file.Size >> 16), byte(file.Size >> 8), byte(file.Size)})
+	}
+
+	input, err := DecodeBody(Header{ID: MsgIDAlarmAttachInfo, Number: 3}, buf.Bytes())
+	if nil != err {
+		t.Fatal(err)
+	}
+	msg := input.(*MsgAlarmAttachInfo)
+	if "T000001" != msg.TerminalID || "A0123456789" != msg.AlarmNo || 2 != len(msg.Files) ||
+		"02_65_6500_1_A0123456789.mp4" != msg.Files[1].Name || 0x0A0000 != msg.Files[1].Size {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if !msg.Marking.Time.Equal(marking.Time) || 2 != msg.Marking.Number || 2 != msg.Marking.Count {
+		t.Fatalf("unexpected marking: %+v", msg.Marking)
+	}
+
+	// 附件列表长度与附件数量不符
+	if _, err := DecodeBody(Header{ID: MsgIDAlarmAttachInfo}, buf.Bytes()[:buf.Len()-1]); nil == err {
+		t.Fatal("truncated message must fail")
+	}
+}
+
+func TestAttachData(t *testing.T) {
+	var buf bytes.Buffer
+	(&AttachData{Name: "00_65_6500_0_A0123456789.jpg", Offset: 65536, Body: []byte{1, 2, 3}}).WriteTo(&buf)
+	if AttachDataHeaderSize+3 != buf.Len() || "30316364" != hex.EncodeToString(buf.Bytes()[:4]) {
+		t.Fatalf("unexpected data: %x", buf.Bytes())
+	}
+
+	if d, n, err := ParseAttachData(buf.Bytes()[:buf.Len()-1]); nil != d || 0 != n || nil != err {
+		t.Fatalf("partial data: %v, %d, %v", d, n, err)
+	}
+	d, n, err := ParseAttachData(buf.Bytes())
+	if nil != err || buf.Len() != n || "00_65_6500_0_A0123456789.jpg" != d.Name || 65536 != d.Offset || !bytes.Equal([]byte{1, 2, 3}, d.Body) {
+		t.Fatalf("unexpected data: %+v, %d, %v", d, n, err)
+	}
+	if _, _, err := ParseAttachData(append([]byte{0x7E}, buf.Bytes()...)); ErrAttachMagic != err {
+		t.Fatalf("got %v, want ErrAttachMagic", err)
+	}
+}
+
+func TestAttachFileFinishResp(t *testing.T) {
+	msg := NewMsgAttachFileFinishResp()
+	msg.Name = "a.jpg"
+	msg.Type = AttachFileTypeImage
+	msg.Result = 1
+	msg.Resends = []AttachRange{{Offset: 0x100, Length: 0x200}}
+
+	var buf bytes.Buffer
+	msg.writeTo(&buf)
+	if got, want := hex.EncodeToString(buf.Bytes()), "05"+hex.EncodeToString([]byte("a.jpg"))+"000101"+"00000100"+"00000200"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
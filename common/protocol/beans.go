@@ -1,8 +1,8 @@
-package jtt
+package protocol
 
 import (
-	"JTTServer/util"
 	"bytes"
+	"common/protocol/util"
 	"encoding/binary"
 	"time"
 
@@ -17,6 +17,12 @@ const (
 	version2019 = byte(1)
 )
 
+// 协议时间所在时区，GMT+8
+var cstZone = time.FixedZone("CST", 28800)
+
+// GBK解码器，GBK解码无状态，可以共享使用
+var gbkDecoder = mahonia.NewDecoder("gbk")
+
 // Param 终端参数项
 type Param struct {
 	// 参数id
@@ -282,151 +288,39 @@ const (
 	ParamIDBSD = uint32(0xF367)
 )
 
-// 终端参数类型对照表
-var paramTypeMap = map[uint32]string{
-	uint32(0x0001): "uint32", // 终端心跳发送间隔，单位为（m）
-	uint32(0x0002): "uint32", // TCP消息应答超时时间，单位为（m）
-	// Someone is needed to complete these comments! I have no time to do this shit!
-	uint32(0x0003): "uint32",
-	uint32(0x0004): "uint32",
-	uint32(0x0005): "uint32",
-	uint32(0x0006): "uint32",
-	uint32(0x0007): "uint32",
-	uint32(0x0010): "string",
-	uint32(0x0011): "string",
-	uint32(0x0012): "string",
-	uint32(0x0013): "string",
-	uint32(0x0014): "string",
-	uint32(0x0015): "string",
-	uint32(0x0016): "string",
-	uint32(0x0017): "string",
-	uint32(0x001A): "string",
-	uint32(0x001B): "uint32",
-	uint32(0x001C): "uint32",
-	uint32(0x001D): "string",
-	uint32(0x0020): "uint32",
-	uint32(0x0021): "uint32",
-	uint32(0x0022): "uint32",
-	uint32(0x0023): "string",
-	uint32(0x0024): "string",
-	uint32(0x0025): "string",
-	uint32(0x0026): "string",
-	uint32(0x0027): "uint32",
-	uint32(0x0028): "uint32",
-	uint32(0x0029): "uint32",
-	uint32(0x002C): "uint32",
-	uint32(0x002D): "uint32",
-	uint32(0x002E): "uint32",
-	uint32(0x002F): "uint32",
-	uint32(0x0030): "uint32",
-	uint32(0x0031): "uint16",
-	uint32(0x0032): "[]uint8",
-	uint32(0x0040): "string",
-	uint32(0x0041): "string",
-	uint32(0x0042): "string",
-	uint32(0x0043): "string",
-	uint32(0x0044): "string",
-	uint32(0x0045): "uint32",
-	uint32(0x0046): "uint32",
-	uint32(0x0047): "uint32",
-	uint32(0x0048): "string",
-	uint32(0x0049): "string",
-	uint32(0x0050): "uint32",
-	uint32(0x0051): "uint32",
-	uint32(0x0052): "uint32",
-	uint32(0x0053): "uint32",
-	uint32(0x0054): "uint32",
-	uint32(0x0055): "uint32",
-	uint32(0x0056): "uint32",
-	uint32(0x0057): "uint32",
-	uint32(0x0058): "uint32",
-	uint32(0x0059): "uint32",
-	uint32(0x005A): "uint32",
-	uint32(0x005B): "uint16",
-	uint32(0x005C): "uint16",
-	uint32(0x005D): "uint16",
-	uint32(0x005E): "uint16",
-	uint32(0x0064): "uint32",
-	uint32(0x0065): "uint32",
-	uint32(0x0070): "uint32",
-	uint32(0x0071): "uint32",
-	uint32(0x0072): "uint32",
-	uint32(0x0073): "uint32",
-	uint32(0x0074): "uint32",
-	uint32(0x0075): "[]uint8",
-	uint32(0x0076): "[]uint8",
-	uint32(0x0077): "[]uint8",
-	uint32(0x0079): "[]uint8",
-	uint32(0x007A): "uint32",
-	uint32(0x007B): "[]uint8",
-	uint32(0x007C): "[]uint8",
-	uint32(0x0080): "uint32",
-	uint32(0x0081): "uint16",
-	uint32(0x0082): "uint16",
-	uint32(0x0083): "string",
-	uint32(0x0084): "uint8",
-	uint32(0x0090): "uint8",
-	uint32(0x0091): "uint8",
-	uint32(0x0092): "uint8",
-	uint32(0x0093): "uint32",
-	uint32(0x0094): "uint8",
-	uint32(0x0095): "uint32",  // GNSS模块详细定位数据上传设置
-	uint32(0x0100): "uint32",  // CAN总线通道1采集时间间隔，单位为毫秒（s）
-	uint32(0x0101): "uint16",  // CAN总线通道1上传时间间隔，单位为秒（s）
-	uint32(0x0102): "uint32",  // CAN总线通道2采集时间间隔，单位为毫秒（s）
-	uint32(0x0103): "uint16",  // CAN总线通道2上传时间间隔，单位为秒（s）
-	uint32(0x0110): "[]uint8", // CAN总线ID单独采集设置
-	// 0x0111~0x01FF 用于其他CAN总线ID单独采集设置，此处有待优化
-	uint32(0x0111): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0112): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0113): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0114): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0115): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0116): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0117): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0118): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0x0119): "[]uint8", // 其他CAN总线ID单独采集设置
-	uint32(0xF000): "uint16",  // 硬控通信端口号
-	uint32(0xF001): "string",  // 车架号
-	uint32(0xF003): "uint8",   // 车牌种类
-	uint32(0xF004): "string",  // CMS平台地址
-	uint32(0xF101): "uint32",  //DMS告警分级速度阈值
-	uint32(0xF102): "uint32",  //人脸朝向左阈值
-	uint32(0xF103): "uint32",  //人脸朝向右阈值
-	uint32(0xF104): "uint32",  //人脸朝向上阈值
-	uint32(0xF105): "uint32",  //人脸朝向下阈值
-	uint32(0xF106): "string",  //警告文本1"人脸丢失"
-	uint32(0xF107): "string",  //警告文本2"请勿打电话"
-	uint32(0xF108): "string",  //警告文本3"请勿喝水"
-	uint32(0xF109): "string",  //警告文本4"请勿抽烟"
-	uint32(0xF10A): "string",  //警告文本5"请目视前方"
-	uint32(0xF10B): "string",  //警告文本6"请勿过度抬头"
-	uint32(0xF10C): "string",  //警告文本7"请勿过度低头"
-	uint32(0xF10D): "string",  //警告文本8"请勿打瞌睡"
-	uint32(0xF10E): "string",  //警告文本9"请勿疲劳驾驶"
-	uint32(0xF10F): "uint32",  //眼睛的高宽比阈值
-	uint32(0xF110): "uint32",  //嘴巴的高宽比阈值
-	uint32(0xF111): "uint32",  //人脸质量分数阈值
-	uint32(0xF112): "uint32",  //获取人脸质量分数超时时间阈值
-	uint32(0xF113): "uint32",  //ADAS告警分级速度阈值
-	uint32(0xF114): "uint32",  //判定车辆是否属于当前车道线比值阈值
-	uint32(0xF115): "uint32",  //车距过近的车距阈值
-	uint32(0xF116): "uint32",  //碰撞预警时间阈值
-	uint32(0xF200): "uint32",  // 脉冲系数
-	uint32(0xF364): "[]uint8", // 高级驾驶辅助系统参数
-	uint32(0xF365): "[]uint8", // 驾驶员状态监测系统参数
-	uint32(0xF366): "[]uint8", // 胎压监测系统参数
-	uint32(0xF367): "[]uint8", // 盲区监测系统参数
-}
-
-// GetParamTypeMap 获取终端参数类型映射表
+// GetParamTypeMap 获取终端参数类型映射表，由终端参数定义生成，见ParamDefs
 func GetParamTypeMap() map[uint32]string {
-	return paramTypeMap
+	defs := ParamDefs()
+	types := make(map[uint32]string, len(defs))
+	for _, def := range defs {
+		types[def.ID] = def.Type
+	}
+	return types
 }
 
 // GNSSAttr GNSS模块属性
 type GNSSAttr byte
 
+// IsGPS 是否支持GPS定位
+func (g GNSSAttr) IsGPS() bool {
+	return g&1 > 0
+}
+
+// IsBDS 是否支持北斗定位
+func (g GNSSAttr) IsBDS() bool {
+	return g&2 > 0
+}
+
+// IsGLONASS 是否支持GLONASS定位
+func (g GNSSAttr) IsGLONASS() bool {
+	return g&4 > 0
+}
+
+// IsGalileo 是否支持Galileo定位
+func (g GNSSAttr) IsGalileo() bool {
+	return g&8 > 0
+}
+
 // COMMAttr 通信模块属性
 type COMMAttr byte
 
@@ -475,8 +369,8 @@ func (t *TerminalAttr) readBy(buf *bytes.Buffer, version byte) {
 		// 终端id
 		t.TerID = string(buf.Next(30))
 	}
-	// 终端SIM卡ICCID号
-	t.ICCID = string(buf.Next(10))
+	// 终端SIM卡ICCID号，10字节BCD码
+	t.ICCID = string(util.ParseBCD(buf.Next(10)))
 	// 终端硬件版本号
 	decoder := mahonia.NewDecoder("gbk")
 	len, _ := buf.ReadByte()
@@ -552,6 +446,8 @@ const (
 	// GNSS定位卫星数
 	AuxIDGnssStvCnt = byte(0x31)
 
+	// 后续自定义信息长度
+	AuxIDCustomLength = byte(0xE0)
 	// AI报警
 	AuxIDAIAlarm = byte(0xE2)
 	// ADAS报警
@@ -1021,9 +917,9 @@ func (a *AlarmFlag) OtherAlarm(enabled bool) {
 // SpeedAlarm 超速报警附加信息项
 type SpeedAlarm struct {
 	// 位置类型
-	Shape ShapeType
+	Shape ShapeType `json:"shape"`
 	// 区域或路段id
-	TagID uint32
+	TagID uint32 `json:"tag_id"`
 }
 
 // ShapeType 超速报警区域的类型枚举
@@ -1077,11 +973,11 @@ func (s *SpeedAlarm) size() byte {
 // LocalAlarm 进出区域/路线报警附加信息项
 type LocalAlarm struct {
 	// 位置类型
-	Shape ShapeType
+	Shape ShapeType `json:"shape"`
 	// 区域或路线id
-	TagID uint32
+	TagID uint32 `json:"tag_id"`
 	// 方向，0-进，1-出
-	Direction byte
+	Direction byte `json:"direction"`
 }
 
 // readBy 从缓存中读
@@ -1113,11 +1009,11 @@ func (l *LocalAlarm) size() byte {
 // RuntimeAlarm 路线行驶时间不足/过长报警附加信息项
 type RuntimeAlarm struct {
 	// 路段id
-	TagID uint32
+	TagID uint32 `json:"tag_id"`
 	// 路段行驶时间，单位（s）
-	Duration uint16
+	Duration uint16 `json:"duration"`
 	// 结果，0-不足，1-过长
-	Verdict byte
+	Verdict byte `json:"verdict"`
 }
 
 // readBy 从缓存中读
@@ -1638,98 +1534,157 @@ func (s *Signal) ClutchReleased(truth bool) {
 
 // PosAuxMap 位置附加信息对照表
 var PosAuxMap = map[byte]string{
-	byte(0x01): "uint32",       // 里程，单位为1/10km，对应车上的里程表读数
-	byte(0x02): "uint16",       // 油量，单位为1/10L，对应车上油量表读数
-	byte(0x03): "uint16",       // 行驶记录功能获取的速度，单位为1/10km/h
-	byte(0x04): "uint16",       // 需要人工确认报警事件的ID，从1开始计数
-	byte(0x05): "[]uint8",      // 胎压，单位为Pa，标定轮子的顺序为从车头开始从左到右顺序排列，多余的字节为0xFF，表示无效数据
-	byte(0x06): "int16",        // 车厢温度，单位为摄氏度，取值范围为-32767~+32767，最高位为1表示负数
-	byte(0x07): "int16",        // 冷链货仓温度（传感器1），单位为1/10摄氏度，取值范围为-32768~+32767，最高位为1表示负数
-	byte(0x08): "int16",        // 冷链货仓温度（传感器2），单位为1/10摄氏度，取值范围为-32768~+32767，最高位为1表示负数
-	byte(0x09): "uint8",        // 冷链货仓湿度1，单位为百分比，取值范围为0-100
-	byte(0x0A): "uint8",        // 冷链货仓湿度2，单位为百分比，取值范围为0-100
-	byte(0x0B): "uint8",        // 附加的自定义故障报警，包括门磁和温湿度传感器
-	byte(0x0C): "uint8",        // 门磁1（未安装时不发送此ID）0：门磁1解锁；1：门磁1加锁（货仓后门）
-	byte(0x0D): "uint8",        // 门磁2（未安装时不发送此ID）0：门磁2解锁；1：门磁2加锁（货仓侧门）
-	byte(0x11): "SpeedAlarm",   // 超速报警附加信息
-	byte(0x12): "LocalAlarm",   // 进出区域/路线报警附加信息
-	byte(0x13): "RuntimeAlarm", // 路段行驶时间不足/过长报警附加信息
-	byte(0x25): "uint32",       // 扩展车辆信号状态位
-	byte(0x2A): "uint16",       // IO状态位
-	byte(0x2B): "uint32",       // 模拟量，bit0-15，AD0；bit16-31，AD1
-	byte(0x30): "uint8",        // 无线通信网络信号强度
-	byte(0x31): "uint8",        // GNSS定位卫星数
-	byte(0xE0): "unknown",      // 后续自定义信息长度
-	byte(0xE2): "AIAlarm",      // AI报警
-	byte(0x65): "DMSAlarm",     // DMS报警
-	byte(0x66): "TPMSAlarm",    // 轮胎状态监测报警
+	byte(0x01): "uint32",        // 里程，单位为1/10km，对应车上的里程表读数
+	byte(0x02): "uint16",        // 油量，单位为1/10L，对应车上油量表读数
+	byte(0x03): "uint16",        // 行驶记录功能获取的速度，单位为1/10km/h
+	byte(0x04): "uint16",        // 需要人工确认报警事件的ID，从1开始计数
+	byte(0x05): "[]uint8",       // 胎压，单位为Pa，标定轮子的顺序为从车头开始从左到右顺序排列，多余的字节为0xFF，表示无效数据
+	byte(0x06): "int16",         // 车厢温度，单位为摄氏度，取值范围为-32767~+32767，最高位为1表示负数
+	byte(0x07): "int16",         // 冷链货仓温度（传感器1），单位为1/10摄氏度，取值范围为-32768~+32767，最高位为1表示负数
+	byte(0x08): "int16",         // 冷链货仓温度（传感器2），单位为1/10摄氏度，取值范围为-32768~+32767，最高位为1表示负数
+	byte(0x09): "uint8",         // 冷链货仓湿度1，单位为百分比，取值范围为0-100
+	byte(0x0A): "uint8",         // 冷链货仓湿度2，单位为百分比，取值范围为0-100
+	byte(0x0B): "uint8",         // 附加的自定义故障报警，包括门磁和温湿度传感器
+	byte(0x0C): "uint8",         // 门磁1（未安装时不发送此ID）0：门磁1解锁；1：门磁1加锁（货仓后门）
+	byte(0x0D): "uint8",         // 门磁2（未安装时不发送此ID）0：门磁2解锁；1：门磁2加锁（货仓侧门）
+	byte(0x11): "SpeedAlarm",    // 超速报警附加信息
+	byte(0x12): "LocalAlarm",    // 进出区域/路线报警附加信息
+	byte(0x13): "RuntimeAlarm",  // 路段行驶时间不足/过长报警附加信息
+	byte(0x25): "uint32",        // 扩展车辆信号状态位
+	byte(0x2A): "uint16",        // IO状态位
+	byte(0x2B): "uint32",        // 模拟量，bit0-15，AD0；bit16-31，AD1
+	byte(0x30): "uint8",         // 无线通信网络信号强度
+	byte(0x31): "uint8",         // GNSS定位卫星数
+	byte(0xE0): "CustomAuxInfo", // 后续自定义信息长度
+	byte(0xE2): "AIAlarm",       // AI报警
+	byte(0x64): "ADASAlarm",     // 高级驾驶辅助系统报警
+	byte(0x65): "DMSAlarm",      // DMS报警
+	byte(0x66): "TPMSAlarm",     // 轮胎状态监测报警
+	byte(0x67): "BSDAlarm",      // 盲区监测系统报警
 }
 
 // PositionAux 位置附加信息项
 type PositionAux struct {
 	// 附加项id
-	ID byte
+	ID byte `json:"id"`
 	// 附加项长度
-	Len byte
+	Len byte `json:"len"`
 	// 附加项内容
-	Value interface{}
+	Value interface{} `json:"value"`
 }
 
-// ReadBy 从缓存中度
+// ReadBy 从缓存中读，始终按附加项长度读取内容，无法解析的附加项保留原始字节
 func (p *PositionAux) ReadBy(buf *bytes.Buffer) {
 	// 附加项id
 	p.ID, _ = buf.ReadByte()
 	// 附加项长度
 	p.Len, _ = buf.ReadByte()
 	// 附加项内容
-	switch PosAuxMap[p.ID] {
+	data := buf.Next(int(p.Len))
+	if AuxIDCustomLength == p.ID {
+		p.Value = readCustomAuxInfo(data, buf)
+		return
+	}
+	if tp := lookupPosAuxType(p.ID); nil != tp && nil != tp.Decode {
+		if value, err := tp.Decode(data); nil == err {
+			p.Value = value
+			return
+		}
+		p.Value = append([]byte(nil), data...)
+		return
+	}
+	p.Value = decodeAuxValue(posAuxTypeName(p.ID), data)
+}
+
+// 整型附加项的长度，长度不符时按原始字节保留，避免重新编码改变长度
+var auxIntSize = map[string]int{
+	"uint8":  1,
+	"int16":  2,
+	"uint16": 2,
+	"uint32": 4,
+}
+
+// 结构体附加项内容的最小长度，长度不足时按原始字节保留
+var auxMinSize = map[string]int{
+	"SpeedAlarm":   1,
+	"LocalAlarm":   6,
+	"RuntimeAlarm": 7,
+	"ADASAlarm":    47,
+	"DMSAlarm":     47,
+	"TPMSAlarm":    41,
+	"BSDAlarm":     41,
+	"AIAlarm":      42,
+}
+
+// decodeAuxValue 按类型名称解码附加项内容
+func decodeAuxValue(tpName string, data []byte) interface{} {
+	if size, ok := auxIntSize[tpName]; ok && len(data) != size {
+		tpName = "[]uint8"
+	}
+	if size, ok := auxMinSize[tpName]; ok && len(data) < size {
+		tpName = "[]uint8"
+	}
+
+	switch tpName {
 	case "uint8":
-		p.Value, _ = buf.ReadByte()
+		return data[0]
 	case "int16":
-		bts := make([]byte, 2)
-		buf.Read(bts)
-		p.Value = int16(binary.BigEndian.Uint16(bts))
+		return int16(binary.BigEndian.Uint16(data))
 	case "uint16":
-		bts := make([]byte, 2)
-		buf.Read(bts)
-		p.Value = binary.BigEndian.Uint16(bts)
+		return binary.BigEndian.Uint16(data)
 	case "uint32":
-		bts := make([]byte, 4)
-		buf.Read(bts)
-		p.Value = binary.BigEndian.Uint32(bts)
-	case "[]uint8":
-		bts := make([]byte, p.Len)
-		buf.Read(bts)
-		p.Value = bts
+		return binary.BigEndian.Uint32(data)
 	case "string":
-		decoder := mahonia.NewDecoder("gbk")
-		bts := make([]byte, p.Len)
-		buf.Read(bts)
-		p.Value = decoder.ConvertString(string(bts))
+		return gbkDecoder.ConvertString(string(data))
 	case "SpeedAlarm":
 		var alarm SpeedAlarm
-		alarm.readBy(buf)
-		p.Value = &alarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
 	case "LocalAlarm":
 		var alarm LocalAlarm
-		alarm.readBy(buf)
-		p.Value = &alarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
 	case "RuntimeAlarm":
 		var alarm RuntimeAlarm
-		alarm.readBy(buf)
-		p.Value = &alarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
+	case "ADASAlarm":
+		var alarm ADASAlarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
 	case "DMSAlarm":
 		var alarm DMSAlarm
-		alarm.readBy(buf)
-		p.Value = &alarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
 	case "TPMSAlarm":
-		// todo
+		var alarm TPMSAlarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
+	case "BSDAlarm":
+		var alarm BSDAlarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
+	case "AIAlarm":
+		var alarm AIAlarm
+		alarm.readBy(bytes.NewBuffer(data))
+		return &alarm
 	default:
+		// 未知附加项保留原始字节，避免后续附加项错位
+		return append([]byte(nil), data...)
 	}
 }
 
 // WriteTo 写入缓存中
 func (p *PositionAux) WriteTo(buf *bytes.Buffer) {
+	if tp := lookupPosAuxType(p.ID); nil != tp && nil != tp.Encode {
+		if data, err := tp.Encode(p.Value); nil == err {
+			buf.WriteByte(p.ID)
+			buf.WriteByte(byte(len(data)))
+			buf.Write(data)
+			return
+		}
+	}
+
 	// 附加项id
 	buf.WriteByte(p.ID)
 	// 附加项长度和内容
@@ -1776,16 +1731,56 @@ func (p *PositionAux) WriteTo(buf *bytes.Buffer) {
 		alarm := p.Value.(RuntimeAlarm)
 		buf.WriteByte(alarm.size())
 		alarm.writeTo(buf)
+	case *SpeedAlarm:
+		writeSized(buf, p.Value.(*SpeedAlarm))
+	case *LocalAlarm:
+		writeSized(buf, p.Value.(*LocalAlarm))
+	case *RuntimeAlarm:
+		writeSized(buf, p.Value.(*RuntimeAlarm))
+	case ADASAlarm:
+		alarm := p.Value.(ADASAlarm)
+		writeSized(buf, &alarm)
+	case *ADASAlarm:
+		writeSized(buf, p.Value.(*ADASAlarm))
 	case DMSAlarm:
 		alarm := p.Value.(DMSAlarm)
-		buf.WriteByte(alarm.size())
-		alarm.writeTo(buf)
+		writeSized(buf, &alarm)
+	case *DMSAlarm:
+		writeSized(buf, p.Value.(*DMSAlarm))
 	case TPMSAlarm:
-		// todo
+		alarm := p.Value.(TPMSAlarm)
+		writeSized(buf, &alarm)
+	case *TPMSAlarm:
+		writeSized(buf, p.Value.(*TPMSAlarm))
+	case BSDAlarm:
+		alarm := p.Value.(BSDAlarm)
+		writeSized(buf, &alarm)
+	case *BSDAlarm:
+		writeSized(buf, p.Value.(*BSDAlarm))
+	case AIAlarm:
+		alarm := p.Value.(AIAlarm)
+		writeSized(buf, &alarm)
+	case *AIAlarm:
+		writeSized(buf, p.Value.(*AIAlarm))
+	case *CustomAuxInfo:
+		p.Value.(*CustomAuxInfo).writeTo(p.Len, buf)
+	case CustomAuxInfo:
+		info := p.Value.(CustomAuxInfo)
+		info.writeTo(p.Len, buf)
 	default:
+		// 无法编码的值写入空内容，保持附加项格式完整
+		buf.WriteByte(0)
 	}
 }
 
+// writeSized 写入变长附加信息项的长度和内容
+func writeSized(buf *bytes.Buffer, value interface{ writeTo(*bytes.Buffer) }) {
+	var body bytes.Buffer
+	value.writeTo(&body)
+	buf.WriteByte(byte(body.Len()))
+	buf.Write(body.Bytes())
+}
+
 // 载货状态
 const (
 	CargoStatusEmpty uint32 = 0 // 空载
@@ -1796,71 +1791,57 @@ const (
 // Position 位置信息
 type Position struct {
 	// 数据主键
-	PK int64 `orm:"column(id);pk;auto"`
+	PK int64 `orm:"column(id);pk;auto" json:"-"`
 	// 报警标识
-	Alarm AlarmFlag
+	Alarm AlarmFlag `json:"alarm"`
 	// 状态标识
-	Status StatusFlag
+	Status StatusFlag `json:"status"`
 	// 纬度，单位0.000001°
-	Latitude uint32
+	Latitude uint32 `json:"latitude"`
 	// 经度，单位0.000001°
-	Longitude uint32
+	Longitude uint32 `json:"longitude"`
 	// 高程，单位m
-	Altitude uint16
+	Altitude uint16 `json:"altitude"`
 	// 速度，单位0.1km/h
-	Speed uint16
+	Speed uint16 `json:"speed"`
 	// 方向，单位°
-	Bearing uint16
+	Bearing uint16 `json:"bearing"`
 	// 时间，单位s，GMT+8
-	Time time.Time `orm:"type(datetime)"`
-	// 附加信息项列表
-	Auxs map[byte]PositionAux `orm:"-"`
+	Time time.Time `orm:"type(datetime)" json:"time"`
+	// 附加信息项列表，按上报顺序排列
+	Auxs []PositionAux `orm:"-" json:"auxs"`
 	// 附加信息项字节数组
-	AuxsBts string
+	AuxsBts string `json:"-"`
 
 	//sync.Mutex // add by zhangxinyi0811,otherwise it will cause [pos.Auxs] rw failed
 }
 
 // ReadBy 从缓存中读
 func (p *Position) ReadBy(buf *bytes.Buffer) {
-	value := make([]byte, 6)
-
-	// 报警标志
-	buf.Read(value[:4])
-	p.Alarm = AlarmFlag(binary.BigEndian.Uint32(value))
-	// 状态
-	buf.Read(value[:4])
-	p.Status = StatusFlag(binary.BigEndian.Uint32(value))
-	// 纬度
-	buf.Read(value[:4])
-	p.Latitude = binary.BigEndian.Uint32(value)
-	// 经度
-	buf.Read(value[:4])
-	p.Longitude = binary.BigEndian.Uint32(value)
-	// 高程
-	buf.Read(value[:2])
-	p.Altitude = binary.BigEndian.Uint16(value)
-	// 速度
-	buf.Read(value[:2])
-	p.Speed = binary.BigEndian.Uint16(value)
-	// 方向
-	buf.Read(value[:2])
-	p.Bearing = binary.BigEndian.Uint16(value)
-	// 时间
-	buf.Read(value)
-	p.Time, _ = time.ParseInLocation("060102150405", string(util.ParseBCD(value)), time.FixedZone("CST", 28800))
-	// 附加项
-	if buf.Len() > 0 && nil == p.Auxs {
-		p.Auxs = make(map[byte]PositionAux)
+	// 位置基本信息
+	p.ReadBaseBy(buf)
+	// 附加项，预先统计附加项个数，避免列表多次扩容
+	if count := countPositionAux(buf.Bytes()); cap(p.Auxs) < count {
+		p.Auxs = make([]PositionAux, 0, count)
+	} else {
+		p.Auxs = p.Auxs[:0]
 	}
 	var aux PositionAux
 	for buf.Len() > 0 {
 		aux.ReadBy(buf)
-		p.Auxs[aux.ID] = aux
-		// p.Auxs = append(p.Auxs, aux)
+		p.Auxs = append(p.Auxs, aux)
 	}
 }
 
+// countPositionAux 统计附加信息项个数
+func countPositionAux(bts []byte) int {
+	count := 0
+	for off := 0; off+1 < len(bts); off += int(bts[off+1]) + 2 {
+		count++
+	}
+	return count
+}
+
 // ReadBaseBy 从缓存中读位置基本信息
 func (p *Position) ReadBaseBy(buf *bytes.Buffer) {
 	// 报警标志
@@ -1880,7 +1861,47 @@ func (p *Position) ReadBaseBy(buf *bytes.Buffer) {
 	// 方向
 	p.Bearing = binary.BigEndian.Uint16(buf.Next(2))
 	// 时间
-	p.Time, _ = time.ParseInLocation("060102150405", string(util.ParseBCD(buf.Next(6))), time.FixedZone("CST", 28800))
+	p.Time, _ = util.ParseBCDTime(buf.Next(6), cstZone)
+}
+
+// Aux 获取指定id的附加信息项
+func (p *Position) Aux(id byte) (PositionAux, bool) {
+	for _, aux := range p.Auxs {
+		if aux.ID == id {
+			return aux, true
+		}
+	}
+	return PositionAux{}, false
+}
+
+// AlarmMarkings 获取主动安全报警附加信息（苏标）中的报警标识号，按上报顺序排列
+func (p *Position) AlarmMarkings() []AlarmMarking {
+	var markings []AlarmMarking
+	for _, aux := range p.Auxs {
+		switch alarm := aux.Value.(type) {
+		case *ADASAlarm:
+			markings = append(markings, alarm.Marking)
+		case *DMSAlarm:
+			markings = append(markings, alarm.Marking)
+		case *TPMSAlarm:
+			markings = append(markings, alarm.Marking)
+		case *BSDAlarm:
+			markings = append(markings, alarm.Marking)
+		case *AIAlarm:
+			markings = append(markings, alarm.Marking)
+		case ADASAlarm:
+			markings = append(markings, alarm.Marking)
+		case DMSAlarm:
+			markings = append(markings, alarm.Marking)
+		case TPMSAlarm:
+			markings = append(markings, alarm.Marking)
+		case BSDAlarm:
+			markings = append(markings, alarm.Marking)
+		case AIAlarm:
+			markings = append(markings, alarm.Marking)
+		}
+	}
+	return markings
 }
 
 // WriteTo 写入缓存中
@@ -1997,12 +2018,9 @@ func (p *Position) Duplicate() *Position {
 	pos.Speed = p.Speed
 	pos.Bearing = p.Bearing
 	pos.Time = p.Time
-	pos.Auxs = make(map[byte]PositionAux)
 	if len(p.Auxs) > 0 {
-		//pos.Auxs = map[byte]PositionAux{}
-		for key, val := range p.Auxs {
-			pos.Auxs[key] = val
-		}
+		pos.Auxs = make([]PositionAux, len(p.Auxs))
+		copy(pos.Auxs, p.Auxs)
 	}
 	return pos
 }
@@ -2049,9 +2067,9 @@ type GnnsStatus struct {
 	// GPS卫星状态
 	GPSSates []Satellite `json:"gps_status"`
 	// 格勒纳斯卫星状态
-	GLSates []Satellite
+	GLSates []Satellite `json:"gl_status"`
 	// 伽利略卫星状态
-	GOSates []Satellite
+	GOSates []Satellite `json:"go_status"`
 }
 
 // WriteTo 写入缓存中
@@ -2175,11 +2193,21 @@ func (a *AlarmConfirm) ConfirmMove() bool {
 // TextFlag 文本标志信息
 type TextFlag byte
 
-// Type 文本信息类型，0-服务，2-紧急，3-通知
+// Type 文本信息级别（2019版），见TextLevelXXX
 func (t *TextFlag) Type() byte {
 	return byte(*t & 0x03)
 }
 
+// IsEmergency 是否紧急（2019版）
+func (t *TextFlag) IsEmergency() bool {
+	return TextLevelEmergency == t.Type()
+}
+
+// IsAdvertising 是否广告屏显示
+func (t *TextFlag) IsAdvertising() bool {
+	return *t&16 > 0
+}
+
 // IsShow 是否终端显示器显示
 func (t *TextFlag) IsShow() bool {
 	return *t&4 > 0
@@ -2198,19 +2226,19 @@ func (t *TextFlag) Kind() byte {
 // Contact 联系人项
 type Contact struct {
 	// 标识，1-呼入，2-呼出，3-呼入/呼出
-	Flag byte
+	Flag byte `json:"flag"`
 	// 电话号码
-	Phone string
+	Phone string `json:"phone"`
 	// 联系人姓名
-	Name string
+	Name string `json:"name"`
 }
 
 // VehCtrlParam 车辆控制参数
 type VehCtrlParam struct {
 	// ID
-	ID uint16
+	ID uint16 `json:"id"`
 	// 参数值
-	Value interface{}
+	Value interface{} `json:"value"`
 }
 
 // Area 区域接口
@@ -2379,27 +2407,27 @@ func (a *AreaAttr) SetGatherGNSSWithEnter(enable bool) {
 // RoundArea 圆形区域项
 type RoundArea struct {
 	// 区域ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// 区域属性
-	Attr AreaAttr
+	Attr AreaAttr `json:"attr"`
 	// 中心点纬度
-	CenterY uint32
+	CenterY uint32 `json:"center_y"`
 	// 中心点经度
-	CenterX uint32
+	CenterX uint32 `json:"center_x"`
 	// 半径
-	Radius uint32
+	Radius uint32 `json:"radius"`
 	// 起始时间
-	STime string //time.Time
+	STime string `json:"start_time"` //time.Time
 	// 结束时间
-	ETime string //time.Time
+	ETime string `json:"end_time"` //time.Time
 	// 最高速度，单位km/h
-	MaxSpeed uint16
+	MaxSpeed uint16 `json:"max_speed"`
 	// 超速持续时间，单位s
-	Duration byte
+	Duration byte `json:"duration"`
 	// 夜间最高速度，单位km/h
-	MaxSpeedInNight uint16
+	MaxSpeedInNight uint16 `json:"max_speed_in_night"`
 	// 区域名称
-	Name string
+	Name string `json:"name"`
 }
 
 // readBy 从缓存中读
@@ -2478,8 +2506,15 @@ func (r *RoundArea) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version
 		binary.BigEndian.PutUint16(value, r.MaxSpeed)
 		buf.Write(value[:2])
 		buf.WriteByte(r.Duration)
-		binary.BigEndian.PutUint16(value, r.MaxSpeedInNight)
-		buf.Write(value[:2])
+		if version2011 != version {
+			binary.BigEndian.PutUint16(value, r.MaxSpeedInNight)
+			buf.Write(value[:2])
+		}
+	}
+
+	// 2011版无区域名称
+	if version2011 == version {
+		return
 	}
 
 	bts := []byte(encoder.ConvertString(r.Name))
@@ -2492,29 +2527,29 @@ func (r *RoundArea) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version
 // RectArea 矩形区域项
 type RectArea struct {
 	// 区域ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// 区域属性
-	Attr AreaAttr
+	Attr AreaAttr `json:"attr"`
 	// 左上纬度（西北）
-	NWY uint32
+	NWY uint32 `json:"nw_y"`
 	// 左上经度（西北）
-	NWX uint32
+	NWX uint32 `json:"nw_x"`
 	// 右下纬度（东南）
-	SEY uint32
+	SEY uint32 `json:"se_y"`
 	// 右下经度（东南）
-	SEX uint32
+	SEX uint32 `json:"se_x"`
 	// 起始时间
-	STime string //time.Time
+	STime string `json:"start_time"` //time.Time
 	// 结束时间
-	ETime string //time.Time
+	ETime string `json:"end_time"` //time.Time
 	// 最高速度，单位km/h
-	MaxSpeed uint16
+	MaxSpeed uint16 `json:"max_speed"`
 	// 超速持续时间，单位s
-	Duration byte
+	Duration byte `json:"duration"`
 	// 夜间最高速度，单位km/h
-	MaxSpeedInNight uint16
+	MaxSpeedInNight uint16 `json:"max_speed_in_night"`
 	// 区域名称
-	Name string
+	Name string `json:"name"`
 }
 
 // readBy 从缓存中读
@@ -2598,8 +2633,15 @@ func (r *RectArea) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version b
 		binary.BigEndian.PutUint16(value, r.MaxSpeed)
 		buf.Write(value[:2])
 		buf.WriteByte(r.Duration)
-		binary.BigEndian.PutUint16(value, r.MaxSpeedInNight)
-		buf.Write(value[:2])
+		if version2011 != version {
+			binary.BigEndian.PutUint16(value, r.MaxSpeedInNight)
+			buf.Write(value[:2])
+		}
+	}
+
+	// 2011版无区域名称
+	if version2011 == version {
+		return
 	}
 
 	bts := []byte(encoder.ConvertString(r.Name))
@@ -2612,23 +2654,23 @@ func (r *RectArea) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version b
 // PolygonArea 多边形区域项
 type PolygonArea struct {
 	// 区域ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// 区域属性
-	Attr AreaAttr
+	Attr AreaAttr `json:"attr"`
 	// 顶点列表
-	Vertexs []uint32
+	Vertexs []uint32 `json:"vertexs"`
 	// 起始时间
-	STime string //time.Time
+	STime string `json:"start_time"` //time.Time
 	// 结束时间
-	ETime string //time.Time
+	ETime string `json:"end_time"` //time.Time
 	// 最高速度，单位km/h
-	MaxSpeed uint16
+	MaxSpeed uint16 `json:"max_speed"`
 	// 超速持续时间，单位s
-	Duration byte
+	Duration byte `json:"duration"`
 	// 夜间最高速度，单位km/h
-	MaxSpeedInNight uint16
+	MaxSpeedInNight uint16 `json:"max_speed_in_night"`
 	// 区域名称
-	Name string
+	Name string `json:"name"`
 }
 
 // readBy 从缓存中读
@@ -2656,9 +2698,13 @@ func (p *PolygonArea) readBy(buf *bytes.Buffer, decoder mahonia.Decoder, version
 	}
 
 	buf.Read(value[:2])
-	count := binary.BigEndian.Uint16(value) << 1
+	count := int(binary.BigEndian.Uint16(value)) << 1
+	// 顶点数来自终端，按剩余长度限制
+	if max := buf.Len() / 4; count > max {
+		count = max
+	}
 	p.Vertexs = make([]uint32, count)
-	for i := uint16(0); i < count; i++ {
+	for i := 0; i < count; i++ {
 		buf.Read(value[:4])
 		p.Vertexs[i] = binary.BigEndian.Uint32(value)
 	}
@@ -2715,6 +2761,10 @@ func (p *PolygonArea) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, versio
 		buf.Write(value[:4])
 	}
 
+	// 2011版无夜间最高速度及区域名称
+	if version2011 == version {
+		return
+	}
 	if p.Attr.HasSpeed() {
 		binary.BigEndian.PutUint16(value, p.MaxSpeedInNight)
 		buf.Write(value[:2])
@@ -2803,13 +2853,13 @@ func (a *PolylineAttr) SetExitAlarmToServer(enable bool) {
 // Vertex 路线拐点项
 type Vertex struct {
 	// 拐点id
-	ID uint32
+	ID uint32 `json:"id"`
 	// 纬度
-	Latitude uint32
+	Latitude uint32 `json:"latitude"`
 	// 经度
-	Longitude uint32
+	Longitude uint32 `json:"longitude"`
 	// 路段
-	Tag Segment
+	Tag Segment `json:"tag"`
 }
 
 // 从缓存中读
@@ -2851,7 +2901,7 @@ func (v *Vertex) readBy(buf *bytes.Buffer, version byte) {
 }
 
 // 写入缓存中
-func (v *Vertex) writeTo(buf *bytes.Buffer) {
+func (v *Vertex) writeTo(buf *bytes.Buffer, version byte) {
 	value := make([]byte, 4)
 
 	binary.BigEndian.PutUint32(value, v.ID)
@@ -2876,8 +2926,10 @@ func (v *Vertex) writeTo(buf *bytes.Buffer) {
 		binary.BigEndian.PutUint16(value, v.Tag.MaxSpeed)
 		buf.Write(value[:2])
 		buf.WriteByte(v.Tag.Duration)
-		binary.BigEndian.PutUint16(value, v.Tag.MaxSpeedInNight)
-		buf.Write(value[:2])
+		if version2011 != version {
+			binary.BigEndian.PutUint16(value, v.Tag.MaxSpeedInNight)
+			buf.Write(value[:2])
+		}
 	}
 }
 
@@ -2943,37 +2995,37 @@ func (s *SegmentAttr) SetWest(enable bool) {
 // Segment 路段项
 type Segment struct {
 	// id
-	ID uint32
+	ID uint32 `json:"id"`
 	// 路段宽度
-	Width byte
+	Width byte `json:"width"`
 	// 路段属性
-	Attr SegmentAttr
+	Attr SegmentAttr `json:"attr"`
 	// 路段行驶过长阈值
-	MaxDuration uint16
+	MaxDuration uint16 `json:"max_duration"`
 	// 路段行驶不足阈值
-	MinDuration uint16
+	MinDuration uint16 `json:"min_duration"`
 	// 路段最高速度
-	MaxSpeed uint16
+	MaxSpeed uint16 `json:"max_speed"`
 	// 路段超速持续时间
-	Duration byte
+	Duration byte `json:"duration"`
 	// 路段夜间最高速度
-	MaxSpeedInNight uint16
+	MaxSpeedInNight uint16 `json:"max_speed_in_night"`
 }
 
 // Polyline 路线项
 type Polyline struct {
 	// 区域ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// 区域属性
-	Attr PolylineAttr
+	Attr PolylineAttr `json:"attr"`
 	// 拐点列表
-	Vertexs []Vertex
+	Vertexs []Vertex `json:"vertexs"`
 	// 起始时间
-	STime string //time.Time
+	STime string `json:"start_time"` //time.Time
 	// 结束时间
-	ETime string //time.Time
+	ETime string `json:"end_time"` //time.Time
 	// 区域名称
-	Name string
+	Name string `json:"name"`
 }
 
 // readBy 从缓存中读
@@ -2995,9 +3047,13 @@ func (p *Polyline) readBy(buf *bytes.Buffer, decoder mahonia.Decoder, version by
 	}
 
 	buf.Read(value[:2])
-	count := binary.BigEndian.Uint16(value)
+	count := int(binary.BigEndian.Uint16(value))
+	// 拐点数来自终端，每个拐点至少18字节，按剩余长度限制
+	if max := buf.Len() / 18; count > max {
+		count = max
+	}
 	p.Vertexs = make([]Vertex, count)
-	for i := uint16(0); i < count; i++ {
+	for i := 0; i < count; i++ {
 		p.Vertexs[i].readBy(buf, version)
 	}
 
@@ -3034,7 +3090,12 @@ func (p *Polyline) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version b
 	binary.BigEndian.PutUint16(value, count)
 	buf.Write(value[:2])
 	for _, v := range p.Vertexs {
-		v.writeTo(buf)
+		v.writeTo(buf, version)
+	}
+
+	// 2011版无路线名称
+	if version2011 == version {
+		return
 	}
 
 	bts := []byte(encoder.ConvertString(p.Name))
@@ -3047,23 +3108,23 @@ func (p *Polyline) writeTo(buf *bytes.Buffer, encoder mahonia.Encoder, version b
 // CanData CAN总线数据
 type CanData struct {
 	// CAN ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// CAN DATA
-	Data [8]byte
+	Data [8]byte `json:"data"`
 }
 
 // Multimedia 多媒体信息项
 type Multimedia struct {
 	// 多媒体ID
-	ID uint32
+	ID uint32 `json:"id"`
 	// 多媒体类型，0-图像，1-音频，2-视频
-	MimeType byte
+	MimeType byte `json:"mime_type"`
 	// 通道ID
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 	// 事件项编码，0-平台下发指令，1-定时动作，2-抢劫报警触发，3-碰撞侧翻报警
-	EventCode byte
+	EventCode byte `json:"event_code"`
 	// 位置信息项
-	Pos Position
+	Pos Position `json:"pos"`
 }
 
 func (m *Multimedia) writeTo(buf *bytes.Buffer, version byte) {
@@ -3190,31 +3251,132 @@ func (m *MediaResource) readBy(buf *bytes.Buffer) {
 	m.FileSize = binary.BigEndian.Uint32(buf.Next(4))
 }
 
+// ADASAlarm 高级驾驶辅助系统报警信息（苏标）
+type ADASAlarm struct {
+	// 报警ID
+	AlarmID uint32 `json:"alarm_id"`
+	// 标志状态，0-不可用，1-开始标志，2-结束标志
+	SignState byte `json:"sign_state"`
+	// 报警/事件类型
+	AlarmType byte `json:"alarm_type"`
+	// 报警级别，1-一级报警，2-二级报警
+	AlarmLevel byte `json:"alarm_level"`
+	// 前车车速，单位km/h，仅前向碰撞和车距过近报警时有效
+	FrontSpeed byte `json:"front_speed"`
+	// 前车/行人距离，单位100ms，仅前向碰撞、车距过近和行人碰撞报警时有效
+	FrontDistance byte `json:"front_distance"`
+	// 偏离类型，1-左侧偏离，2-右侧偏离，仅车道偏离报警时有效
+	DeviateType byte `json:"deviate_type"`
+	// 道路标志识别类型，1-限速标志，2-限高标志，3-限重标志
+	RoadSignType byte `json:"road_sign_type"`
+	// 道路标志识别数据
+	RoadSignData byte `json:"road_sign_data"`
+	// 车速
+	Speed byte `json:"speed"`
+	// 高程
+	Altitude uint16 `json:"altitude"`
+	// 纬度
+	Latitude uint32 `json:"latitude"`
+	// 经度
+	Longitude uint32 `json:"longitude"`
+	// 日期时间
+	Time time.Time `json:"time"`
+	// 车辆状态
+	Status VehicleStatus `json:"status"`
+	// 报警标识号
+	Marking AlarmMarking `json:"marking"`
+}
+
+// 从缓存中读
+func (alarm *ADASAlarm) readBy(buf *bytes.Buffer) {
+	// 报警ID
+	alarm.AlarmID = binary.BigEndian.Uint32(buf.Next(4))
+	// 标志状态
+	alarm.SignState, _ = buf.ReadByte()
+	// 报警/事件类型
+	alarm.AlarmType, _ = buf.ReadByte()
+	// 报警级别
+	alarm.AlarmLevel, _ = buf.ReadByte()
+	// 前车车速
+	alarm.FrontSpeed, _ = buf.ReadByte()
+	// 前车/行人距离
+	alarm.FrontDistance, _ = buf.ReadByte()
+	// 偏离类型
+	alarm.DeviateType, _ = buf.ReadByte()
+	// 道路标志识别类型
+	alarm.RoadSignType, _ = buf.ReadByte()
+	// 道路标志识别数据
+	alarm.RoadSignData, _ = buf.ReadByte()
+	// 车速
+	alarm.Speed, _ = buf.ReadByte()
+	// 高程
+	alarm.Altitude = binary.BigEndian.Uint16(buf.Next(2))
+	// 纬度
+	alarm.Latitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 经度
+	alarm.Longitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 日期时间
+	alarm.Time, _ = util.ParseBCDTime(buf.Next(6), cstZone)
+	// 车辆状态
+	alarm.Status = VehicleStatus(binary.BigEndian.Uint16(buf.Next(2)))
+	// 报警标识号
+	alarm.Marking.readBy(buf)
+}
+
+// 写入缓存中
+func (alarm *ADASAlarm) writeTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+
+	// 报警ID
+	binary.BigEndian.PutUint32(value, alarm.AlarmID)
+	buf.Write(value)
+	// 标志状态、报警/事件类型、报警级别、前车车速、前车/行人距离、偏离类型、道路标志识别类型、道路标志识别数据、车速
+	buf.Write([]byte{alarm.SignState, alarm.AlarmType, alarm.AlarmLevel, alarm.FrontSpeed, alarm.FrontDistance,
+		alarm.DeviateType, alarm.RoadSignType, alarm.RoadSignData, alarm.Speed})
+	// 高程
+	binary.BigEndian.PutUint16(value, alarm.Altitude)
+	buf.Write(value[:2])
+	// 纬度
+	binary.BigEndian.PutUint32(value, alarm.Latitude)
+	buf.Write(value)
+	// 经度
+	binary.BigEndian.PutUint32(value, alarm.Longitude)
+	buf.Write(value)
+	// 日期时间
+	buf.Write(util.ToBCD([]byte(alarm.Time.In(cstZone).Format("060102150405"))))
+	// 车辆状态
+	binary.BigEndian.PutUint16(value, uint16(alarm.Status))
+	buf.Write(value[:2])
+	// 报警标识号
+	alarm.Marking.writeTo(buf)
+}
+
+// DMSAlarm 驾驶员状态监测系统报警信息（苏标）
 type DMSAlarm struct {
 	// 报警ID
-	AlarmID uint32
+	AlarmID uint32 `json:"alarm_id"`
 	// 标志状态
-	SignState byte
+	SignState byte `json:"sign_state"`
 	// 报警/事件类型
-	AlarmType byte
+	AlarmType byte `json:"alarm_type"`
 	// 报警级别
-	AlarmLevel byte
+	AlarmLevel byte `json:"alarm_level"`
 	// 疲劳程度
-	Fatigue byte
+	Fatigue byte `json:"fatigue"`
 	// 车速
-	Speed byte
+	Speed byte `json:"speed"`
 	// 高程
-	Altitude uint16
+	Altitude uint16 `json:"altitude"`
 	// 纬度
-	Latitude uint32
+	Latitude uint32 `json:"latitude"`
 	// 经度
-	Longitude uint32
+	Longitude uint32 `json:"longitude"`
 	// 日期时间
-	Time time.Time
+	Time time.Time `json:"time"`
 	// 车辆状态
-	Status VehicleStatus
+	Status VehicleStatus `json:"status"`
 	// 报警标识号
-	Marking AlarmMarking
+	Marking AlarmMarking `json:"marking"`
 }
 
 // 从缓存中读
@@ -3321,10 +3483,6 @@ func (da *DMSAlarm) writeTo(buf *bytes.Buffer) {
 	da.Marking.writeTo(buf)
 }
 
-func (da *DMSAlarm) size() byte {
-	return 0
-}
-
 // 车辆状态类型
 type VehicleStatus uint16
 
@@ -3415,14 +3573,25 @@ func (s *VehicleStatus) SetStatus(signal Signal, flag StatusFlag, login bool) {
 
 // AlarmMarking 报警标识（苏标）
 type AlarmMarking struct {
-	TerminalId string    // 终端id，7个字节
-	Time       time.Time // 时间
-	Number     byte      // 同一时间点报警的序号，从0开始循环累加
-	Count      byte      // 表示该报警对应的附件数量
+	TerminalId string    `json:"terminal_id"` // 终端id，7个字节
+	Time       time.Time `json:"time"`        // 时间
+	Number     byte      `json:"number"`      // 同一时间点报警的序号，从0开始循环累加
+	Count      byte      `json:"count"`       // 表示该报警对应的附件数量
 	reserved   byte      // 预留字段
 }
 
-func (a *AlarmMarking) readBy(buf *bytes.Buffer) {}
+func (a *AlarmMarking) readBy(buf *bytes.Buffer) {
+	// 终端id
+	a.TerminalId = readFixedString(buf, 7)
+	// 时间
+	a.Time, _ = util.ParseBCDTime(buf.Next(6), cstZone)
+	// 序号
+	a.Number, _ = buf.ReadByte()
+	// 附件数量
+	a.Count, _ = buf.ReadByte()
+	// 保留
+	a.reserved, _ = buf.ReadByte()
+}
 
 func (a *AlarmMarking) writeTo(buf *bytes.Buffer) {
 	// 终端id
@@ -3442,42 +3611,88 @@ func (a *AlarmMarking) writeTo(buf *bytes.Buffer) {
 	buf.WriteByte(a.reserved)
 }
 
+// 报警附件文件类型（苏标）
+const (
+	AttachFileTypeImage = byte(0) // 图片
+	AttachFileTypeAudio = byte(1) // 音频
+	AttachFileTypeVideo = byte(2) // 视频
+	AttachFileTypeText  = byte(3) // 文本
+	AttachFileTypeOther = byte(4) // 其它
+)
+
+// AttachFile 报警附件信息（苏标）
+type AttachFile struct {
+	// 文件名称，格式为：文件类型_通道号_报警类型_序号_报警编号.后缀名
+	Name string `json:"name"`
+	// 文件大小
+	Size uint32 `json:"size"`
+}
+
+func (f *AttachFile) readBy(buf *bytes.Buffer) {
+	// 文件名称长度
+	l, _ := buf.ReadByte()
+	// 文件名称
+	f.Name = string(buf.Next(int(l)))
+	// 文件大小
+	f.Size = binary.BigEndian.Uint32(buf.Next(4))
+}
+
+// AttachRange 附件数据区间（苏标），用于补传
+type AttachRange struct {
+	// 数据偏移量
+	Offset uint32 `json:"offset"`
+	// 数据长度
+	Length uint32 `json:"length"`
+}
+
+func (r *AttachRange) writeTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+	// 数据偏移量
+	binary.BigEndian.PutUint32(value, r.Offset)
+	buf.Write(value)
+	// 数据长度
+	binary.BigEndian.PutUint32(value, r.Length)
+	buf.Write(value)
+}
+
+// TPMSAlarm 轮胎状态监测系统报警信息（苏标）
 type TPMSAlarm struct {
 	// 报警ID
-	AlarmID uint32
+	AlarmID uint32 `json:"alarm_id"`
 	// 标志状态
-	SignState byte
+	SignState byte `json:"sign_state"`
 	// 车速
-	Speed byte
+	Speed byte `json:"speed"`
 	// 高程
-	Altitude uint16
+	Altitude uint16 `json:"altitude"`
 	// 纬度
-	Latitude uint32
+	Latitude uint32 `json:"latitude"`
 	// 经度
-	Longitude uint32
+	Longitude uint32 `json:"longitude"`
 	// 日期时间
-	Time time.Time
+	Time time.Time `json:"time"`
 	// 车辆状态
-	Status VehicleStatus
+	Status VehicleStatus `json:"status"`
 	// 报警标识号
-	Marking AlarmMarking
+	Marking AlarmMarking `json:"marking"`
 	// 报警/事件列表总数
-	ListCount byte
+	ListCount byte `json:"list_count"`
 	// 报警/事件信息列表
-	List []TPMSAlarmInfo
+	List []TPMSAlarmInfo `json:"list"`
 }
 
+// TPMSAlarmInfo 轮胎报警/事件信息
 type TPMSAlarmInfo struct {
 	// 胎压报警位置
-	TpAlaramPos byte
+	TpAlaramPos byte `json:"tp_alaram_pos"`
 	// 报警/事件类型
-	AlarmType uint16
+	AlarmType uint16 `json:"alarm_type"`
 	// 胎压
-	TirePressure uint16
+	TirePressure uint16 `json:"tire_pressure"`
 	// 胎温
-	TireTemperature uint16
+	TireTemperature uint16 `json:"tire_temperature"`
 	// 电池电量
-	Battery uint16
+	Battery uint16 `json:"battery"`
 }
 
 // 从缓存中读
@@ -3524,6 +3739,7 @@ func (alarm *TPMSAlarm) readBy(buf *bytes.Buffer) {
 	alarm.ListCount, _ = buf.ReadByte()
 
 	// 报警/事件信息列表
+	alarm.List = make([]TPMSAlarmInfo, alarm.ListCount)
 	for i := 0; i < int(alarm.ListCount); i++ {
 		// 胎压报警位置
 		alarm.List[i].TpAlaramPos, _ = buf.ReadByte()
@@ -3583,7 +3799,7 @@ func (alarm *TPMSAlarm) writeTo(buf *bytes.Buffer) {
 	buf.WriteByte(alarm.ListCount)
 
 	// 报警/事件信息列表
-	for i := 0; i < int(alarm.ListCount); i++ {
+	for i := 0; i < int(alarm.ListCount) && i < len(alarm.List); i++ {
 		// 胎压报警位置
 		buf.WriteByte(alarm.List[i].TpAlaramPos)
 		// 报警/事件类型
@@ -3600,3 +3816,167 @@ func (alarm *TPMSAlarm) writeTo(buf *bytes.Buffer) {
 		buf.Write(value[:2])
 	}
 }
+
+// BSDAlarm 盲区监测系统报警信息（苏标）
+type BSDAlarm struct {
+	// 报警ID
+	AlarmID uint32 `json:"alarm_id"`
+	// 标志状态，0-不可用，1-开始标志，2-结束标志
+	SignState byte `json:"sign_state"`
+	// 报警/事件类型，1-后方接近报警，2-左侧后方接近报警，3-右侧后方接近报警
+	AlarmType byte `json:"alarm_type"`
+	// 车速
+	Speed byte `json:"speed"`
+	// 高程
+	Altitude uint16 `json:"altitude"`
+	// 纬度
+	Latitude uint32 `json:"latitude"`
+	// 经度
+	Longitude uint32 `json:"longitude"`
+	// 日期时间
+	Time time.Time `json:"time"`
+	// 车辆状态
+	Status VehicleStatus `json:"status"`
+	// 报警标识号
+	Marking AlarmMarking `json:"marking"`
+}
+
+// 从缓存中读
+func (alarm *BSDAlarm) readBy(buf *bytes.Buffer) {
+	// 报警ID
+	alarm.AlarmID = binary.BigEndian.Uint32(buf.Next(4))
+	// 标志状态
+	alarm.SignState, _ = buf.ReadByte()
+	// 报警/事件类型
+	alarm.AlarmType, _ = buf.ReadByte()
+	// 车速
+	alarm.Speed, _ = buf.ReadByte()
+	// 高程
+	alarm.Altitude = binary.BigEndian.Uint16(buf.Next(2))
+	// 纬度
+	alarm.Latitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 经度
+	alarm.Longitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 日期时间
+	alarm.Time, _ = util.ParseBCDTime(buf.Next(6), cstZone)
+	// 车辆状态
+	alarm.Status = VehicleStatus(binary.BigEndian.Uint16(buf.Next(2)))
+	// 报警标识号
+	alarm.Marking.readBy(buf)
+}
+
+// 写入缓存中
+func (alarm *BSDAlarm) writeTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+
+	// 报警ID
+	binary.BigEndian.PutUint32(value, alarm.AlarmID)
+	buf.Write(value)
+	// 标志状态、报警/事件类型、车速
+	buf.Write([]byte{alarm.SignState, alarm.AlarmType, alarm.Speed})
+	// 高程
+	binary.BigEndian.PutUint16(value, alarm.Altitude)
+	buf.Write(value[:2])
+	// 纬度
+	binary.BigEndian.PutUint32(value, alarm.Latitude)
+	buf.Write(value)
+	// 经度
+	binary.BigEndian.PutUint32(value, alarm.Longitude)
+	buf.Write(value)
+	// 日期时间
+	buf.Write(util.ToBCD([]byte(alarm.Time.In(cstZone).Format("060102150405"))))
+	// 车辆状态
+	binary.BigEndian.PutUint16(value, uint16(alarm.Status))
+	buf.Write(value[:2])
+	// 报警标识号
+	alarm.Marking.writeTo(buf)
+}
+
+// AIAlarm 智能视频分析报警信息（厂商扩展）。
+//
+// 各厂商格式不一，按通用格式解析报警标识号之前的字段，其后的厂商自定义数据保留在Extra中。
+type AIAlarm struct {
+	// 报警ID
+	AlarmID uint32 `json:"alarm_id"`
+	// 标志状态，0-不可用，1-开始标志，2-结束标志
+	SignState byte `json:"sign_state"`
+	// 报警/事件类型
+	AlarmType byte `json:"alarm_type"`
+	// 报警级别
+	AlarmLevel byte `json:"alarm_level"`
+	// 车速
+	Speed byte `json:"speed"`
+	// 高程
+	Altitude uint16 `json:"altitude"`
+	// 纬度
+	Latitude uint32 `json:"latitude"`
+	// 经度
+	Longitude uint32 `json:"longitude"`
+	// 日期时间
+	Time time.Time `json:"time"`
+	// 车辆状态
+	Status VehicleStatus `json:"status"`
+	// 报警标识号
+	Marking AlarmMarking `json:"marking"`
+	// 厂商自定义数据
+	Extra []byte `json:"extra"`
+}
+
+// 从缓存中读，缓存中剩余的数据均作为厂商自定义数据
+func (alarm *AIAlarm) readBy(buf *bytes.Buffer) {
+	// 报警ID
+	alarm.AlarmID = binary.BigEndian.Uint32(buf.Next(4))
+	// 标志状态
+	alarm.SignState, _ = buf.ReadByte()
+	// 报警/事件类型
+	alarm.AlarmType, _ = buf.ReadByte()
+	// 报警级别
+	alarm.AlarmLevel, _ = buf.ReadByte()
+	// 车速
+	alarm.Speed, _ = buf.ReadByte()
+	// 高程
+	alarm.Altitude = binary.BigEndian.Uint16(buf.Next(2))
+	// 纬度
+	alarm.Latitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 经度
+	alarm.Longitude = binary.BigEndian.Uint32(buf.Next(4))
+	// 日期时间
+	alarm.Time, _ = util.ParseBCDTime(buf.Next(6), cstZone)
+	// 车辆状态
+	alarm.Status = VehicleStatus(binary.BigEndian.Uint16(buf.Next(2)))
+	// 报警标识号
+	alarm.Marking.readBy(buf)
+	// 厂商自定义数据
+	if buf.Len() > 0 {
+		alarm.Extra = append([]byte(nil), buf.Next(buf.Len())...)
+	}
+}
+
+// 写入缓存中
+func (alarm *AIAlarm) writeTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+
+	// 报警ID
+	binary.BigEndian.PutUint32(value, alarm.AlarmID)
+	buf.Write(value)
+	// 标志状态、报警/事件类型、报警级别、车速
+	buf.Write([]byte{alarm.SignState, alarm.AlarmType, alarm.AlarmLevel, alarm.Speed})
+	// 高程
+	binary.BigEndian.PutUint16(value, alarm.Altitude)
+	buf.Write(value[:2])
+	// 纬度
+	binary.BigEndian.PutUint32(value, alarm.Latitude)
+	buf.Write(value)
+	// 经度
+	binary.BigEndian.PutUint32(value, alarm.Longitude)
+	buf.Write(value)
+	// 日期时间
+	buf.Write(util.ToBCD([]byte(alarm.Time.In(cstZone).Format("060102150405"))))
+	// 车辆状态
+	binary.BigEndian.PutUint16(value, uint16(alarm.Status))
+	buf.Write(value[:2])
+	// 报警标识号
+	alarm.Marking.writeTo(buf)
+	// 厂商自定义数据
+	buf.Write(alarm.Extra)
+}
@@ -0,0 +1,343 @@
+package protocol
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCANTime 解析5字节BCD码的CAN总线数据接收时间（hh-mm-ss-msms）
+func parseCANTime(bts []byte) time.Time {
+	if len(bts) < 5 {
+		return time.Time{}
+	}
+
+	var fields [5]int
+	for idx, bt := range bts[:5] {
+		hi, lo := int(bt>>4), int(bt&0x0F)
+		if hi > 9 || lo > 9 {
+			return time.Time{}
+		}
+		fields[idx] = hi*10 + lo
+	}
+	// 毫秒占后两个字节，取值0~999
+	ms := fields[3]*100 + fields[4]
+	return time.Date(0, time.January, 1, fields[0], fields[1], fields[2], ms*int(time.Millisecond), cstZone)
+}
+
+// CAN ID字段的标志位
+const (
+	canChannelBit  = uint32(1) << 31 // 通道号，0-CAN1，1-CAN2
+	canExtendedBit = uint32(1) << 30 // 帧类型，0-标准帧，1-扩展帧
+	canAverageBit  = uint32(1) << 29 // 数据采集方式，0-原始数据，1-采集区间的平均值
+	canIDMask      = uint32(1)<<29 - 1
+)
+
+// Channel CAN通道号，0-CAN1，1-CAN2
+func (c *CanData) Channel() byte {
+	if 0 != c.ID&canChannelBit {
+		return 1
+	}
+	return 0
+}
+
+// Extended 是否扩展帧
+func (c *CanData) Extended() bool {
+	return 0 != c.ID&canExtendedBit
+}
+
+// Averaged 数据是否为采集区间的平均值，否则为原始数据
+func (c *CanData) Averaged() bool {
+	return 0 != c.ID&canAverageBit
+}
+
+// CANID CAN总线ID，bit28-bit0
+func (c *CanData) CANID() uint32 {
+	return c.ID & canIDMask
+}
+
+// DBC文件中扩展帧ID的标志位
+const dbcExtendedBit = uint32(1) << 31
+
+// DBC CAN总线数据库，由DBC文件加载，按CAN ID查找报文及信号定义
+type DBC struct {
+	// 报文定义，键为DBC中的报文ID，扩展帧的bit31为1
+	Messages map[uint32]*CanMessage
+}
+
+// CanMessage DBC报文定义
+type CanMessage struct {
+	// 报文ID，不含扩展帧标志
+	ID uint32 `json:"id"`
+	// 是否扩展帧
+	Extended bool `json:"extended"`
+	// 报文名称
+	Name string `json:"name"`
+	// 数据长度
+	Size int `json:"size"`
+	// 信号列表
+	Signals []*CanSignal `json:"signals"`
+}
+
+// CanSignal DBC信号定义
+type CanSignal struct {
+	// 信号名称
+	Name string `json:"name"`
+	// 起始位，Intel格式为最低位，Motorola格式为最高位
+	StartBit int `json:"start_bit"`
+	// 位长度
+	Length int `json:"length"`
+	// 是否Intel字节序（小端），否则为Motorola字节序（大端）
+	LittleEndian bool `json:"little_endian"`
+	// 是否有符号数
+	Signed bool `json:"signed"`
+	// 比例系数
+	Scale float64 `json:"scale"`
+	// 偏移量
+	Offset float64 `json:"offset"`
+	// 最小值
+	Min float64 `json:"min"`
+	// 最大值
+	Max float64 `json:"max"`
+	// 单位
+	Unit string `json:"unit"`
+	// 是否多路复用选择信号
+	Multiplexor bool `json:"multiplexor"`
+	// 多路复用值，Multiplexed为true时只在选择信号等于该值时有效
+	MultiplexValue uint64 `json:"multiplex_value"`
+	// 是否多路复用信号
+	Multiplexed bool `json:"multiplexed"`
+}
+
+// CanValue 解码得到的信号值
+type CanValue struct {
+	// CAN通道号，0-CAN1，1-CAN2
+	Channel byte `json:"channel"`
+	// CAN总线ID
+	CANID uint32 `json:"can_id"`
+	// 报文名称
+	Message string `json:"message"`
+	// 信号名称
+	Signal string `json:"signal"`
+	// 物理值
+	Value float64 `json:"value"`
+	// 单位
+	Unit string `json:"unit"`
+}
+
+var (
+	// BO_ 2364540158 EEC1: 8 Vector__XXX
+	dbcMessagePattern = regexp.MustCompile(`^BO_\s+(\d+)\s+(\w+)\s*:\s*(\d+)`)
+	// SG_ EngineSpeed m1 : 24|16@1+ (0.125,0) [0|8031.875] "rpm" Vector__XXX
+	dbcSignalPattern = regexp.MustCompile(`^SG_\s+(\w+)\s*(M|m\d+)?\s*:\s*(\d+)\|(\d+)@([01])([+-])\s*\(([^,]+),([^)]+)\)\s*\[([^|]+)\|([^\]]+)\]\s*"([^"]*)"`)
+)
+
+// LoadDBCFile 从文件加载DBC
+func LoadDBCFile(name string) (*DBC, error) {
+	f, err := os.Open(name)
+	if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadDBC(f)
+}
+
+// LoadDBC 加载DBC，只解析报文（BO_）和信号（SG_）定义，其他内容忽略
+func LoadDBC(r io.Reader) (*DBC, error) {
+	dbc := &DBC{Messages: make(map[uint32]*CanMessage)}
+
+	var message *CanMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(text, "BO_ "):
+			fields := dbcMessagePattern.FindStringSubmatch(text)
+			if nil == fields {
+				return nil, fmt.Errorf("dbc line %d: invalid message definition", line)
+			}
+			id, err := strconv.ParseUint(fields[1], 10, 32)
+			if nil != err {
+				return nil, fmt.Errorf("dbc line %d: %s", line, err)
+			}
+			size, _ := strconv.Atoi(fields[3])
+			message = &CanMessage{
+				ID:       uint32(id) &^ dbcExtendedBit,
+				Extended: 0 != uint32(id)&dbcExtendedBit,
+				Name:     fields[2],
+				Size:     size,
+			}
+			dbc.Messages[uint32(id)] = message
+		case strings.HasPrefix(text, "SG_ "):
+			if nil == message {
+				return nil, fmt.Errorf("dbc line %d: signal outside message", line)
+			}
+			signal, err := parseDBCSignal(text)
+			if nil != err {
+				return nil, fmt.Errorf("dbc line %d: %s", line, err)
+			}
+			message.Signals = append(message.Signals, signal)
+		case "" == text:
+			message = nil
+		}
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, err
+	}
+	return dbc, nil
+}
+
+// parseDBCSignal 解析信号定义
+func parseDBCSignal(text string) (*CanSignal, error) {
+	fields := dbcSignalPattern.FindStringSubmatch(text)
+	if nil == fields {
+		return nil, fmt.Errorf("invalid signal definition")
+	}
+
+	signal := &CanSignal{
+		Name:         fields[1],
+		LittleEndian: "1" == fields[5],
+		Signed:       "-" == fields[6],
+		Unit:         fields[11],
+	}
+	switch mux := fields[2]; {
+	case "M" == mux:
+		signal.Multiplexor = true
+	case "" != mux:
+		value, err := strconv.ParseUint(mux[1:], 10, 64)
+		if nil != err {
+			return nil, err
+		}
+		signal.Multiplexed, signal.MultiplexValue = true, value
+	}
+
+	var err error
+	if signal.StartBit, err = strconv.Atoi(fields[3]); nil != err {
+		return nil, err
+	}
+	if signal.Length, err = strconv.Atoi(fields[4]); nil != err {
+		return nil, err
+	}
+	if signal.Length < 1 || signal.Length > 64 || signal.StartBit > 63 {
+		return nil, fmt.Errorf("invalid signal %s bits %d|%d", signal.Name, signal.StartBit, signal.Length)
+	}
+	for idx, value := range []*float64{&signal.Scale, &signal.Offset, &signal.Min, &signal.Max} {
+		if *value, err = strconv.ParseFloat(strings.TrimSpace(fields[7+idx]), 64); nil != err {
+			return nil, err
+		}
+	}
+	return signal, nil
+}
+
+// Raw 从8字节数据中提取信号的原始值，有符号信号已做符号扩展
+func (s *CanSignal) Raw(data [8]byte) (uint64, bool) {
+	var value uint64
+	if s.LittleEndian {
+		// Intel格式，起始位为最低位
+		if s.StartBit+s.Length > 64 {
+			return 0, false
+		}
+		for idx := 7; idx >= 0; idx-- {
+			value = value<<8 | uint64(data[idx])
+		}
+		value >>= uint(s.StartBit)
+	} else {
+		// Motorola格式，起始位为最高位，按字节内位号7~0、字节号0~7顺序换算为线性位序
+		msb := s.StartBit/8*8 + 7 - s.StartBit%8
+		lsb := msb + s.Length - 1
+		if lsb > 63 {
+			return 0, false
+		}
+		for _, bt := range data {
+			value = value<<8 | uint64(bt)
+		}
+		value >>= uint(63 - lsb)
+	}
+
+	if s.Length < 64 {
+		value &= 1<<uint(s.Length) - 1
+		if s.Signed && 0 != value&(1<<uint(s.Length-1)) {
+			value |= math.MaxUint64 << uint(s.Length)
+		}
+	}
+	return value, true
+}
+
+// Decode 解码信号的物理值：原始值×比例系数+偏移量
+func (s *CanSignal) Decode(data [8]byte) (float64, bool) {
+	raw, ok := s.Raw(data)
+	if !ok {
+		return 0, false
+	}
+	if s.Signed {
+		return float64(int64(raw))*s.Scale + s.Offset, true
+	}
+	return float64(raw)*s.Scale + s.Offset, true
+}
+
+// Message 按CAN数据项查找报文定义，终端未标记扩展帧时按ID大小推断
+func (d *DBC) Message(data *CanData) (*CanMessage, bool) {
+	id := data.CANID()
+	if data.Extended() || id > 0x7FF {
+		id |= dbcExtendedBit
+	}
+	message, ok := d.Messages[id]
+	if !ok && !data.Extended() {
+		message, ok = d.Messages[data.CANID()]
+	}
+	return message, ok
+}
+
+// DecodeData 解码一个CAN数据项，未定义的报文返回空
+func (d *DBC) DecodeData(data *CanData) []CanValue {
+	message, ok := d.Message(data)
+	if !ok {
+		return nil
+	}
+
+	// 多路复用选择信号的值
+	var mux uint64
+	var hasMux bool
+	for _, signal := range message.Signals {
+		if signal.Multiplexor {
+			mux, hasMux = signal.Raw(data.Data)
+			break
+		}
+	}
+
+	values := make([]CanValue, 0, len(message.Signals))
+	for _, signal := range message.Signals {
+		if signal.Multiplexed && (!hasMux || mux != signal.MultiplexValue) {
+			continue
+		}
+		value, ok := signal.Decode(data.Data)
+		if !ok {
+			continue
+		}
+		values = append(values, CanValue{
+			Channel: data.Channel(),
+			CANID:   data.CANID(),
+			Message: message.Name,
+			Signal:  signal.Name,
+			Value:   value,
+			Unit:    signal.Unit,
+		})
+	}
+	return values
+}
+
+// Decode 解码CAN总线数据上传消息中的所有数据项，按上报顺序排列
+func (d *DBC) Decode(msg *MsgCANDataReport) []CanValue {
+	var values []CanValue
+	for idx := range msg.Datas {
+		values = append(values, d.DecodeData(&msg.Datas[idx])...)
+	}
+	return values
+}
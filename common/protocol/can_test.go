@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDBC = `VERSION ""
+
+BO_ 2364540158 EEC1: 8 Vector__XXX
+ SG_ EngineSpeed : 24|16@1+ (0.125,0) [0|8031.875] "rpm" Vector__XXX
+
+BO_ 2566844158 ET1: 8 Vector__XXX
+ SG_ EngineCoolantTemperature : 0|8@1+ (1,-40) [-40|210] "degC" Vector__XXX
+
+BO_ 291 Test: 8 Vector__XXX
+ SG_ Mode M : 0|8@1+ (1,0) [0|255] "" Vector__XXX
+ SG_ Torque m1 : 15|16@0- (0.1,0) [-3276.8|3276.7] "Nm" Vector__XXX
+ SG_ Pressure m2 : 15|16@0+ (1,0) [0|65535] "kPa" Vector__XXX
+
+CM_ BO_ 291 "test message";
+`
+
+func TestCANReport(t *testing.T) {
+	dbc, err := LoadDBC(strings.NewReader(testDBC))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 3 != len(dbc.Messages) || !dbc.Messages[2364540158].Extended || 0x0CF004FE != dbc.Messages[2364540158].ID {
+		t.Fatalf("unexpected dbc: %+v", dbc.Messages)
+	}
+
+	// 3个数据项，接收时间08:30:15.123
+	body := []byte{0x00, 0x03, 0x08, 0x30, 0x15, 0x01, 0x23}
+	// CAN2扩展帧，转速1500rpm
+	body = append(body, 0xCC, 0xF0, 0x04, 0xFE, 0, 0, 0, 0xE0, 0x2E, 0, 0, 0)
+	// CAN1扩展帧未标记，冷却液温度90℃
+	body = append(body, 0x18, 0xFE, 0xEE, 0xFE, 130, 0, 0, 0, 0, 0, 0, 0)
+	// 标准帧多路复用，扭矩-12.3Nm
+	body = append(body, 0x00, 0x00, 0x01, 0x23, 0x01, 0xFF, 0x85, 0, 0, 0, 0, 0)
+
+	input, err := canDataReportUnmarshal(bytes.NewBuffer(body), version2011)
+	if nil != err {
+		t.Fatal(err)
+	}
+	msg := input.(*MsgCANDataReport)
+	want := time.Date(0, time.January, 1, 8, 30, 15, 123*int(time.Millisecond), cstZone)
+	if !msg.Time.Equal(want) || 3 != len(msg.Datas) {
+		t.Fatalf("unexpected report: %+v", msg)
+	}
+	if data := msg.Datas[0]; 1 != data.Channel() || !data.Extended() || data.Averaged() || 0x0CF004FE != data.CANID() {
+		t.Fatalf("unexpected id bits: %#x", data.ID)
+	}
+
+	values := dbc.Decode(msg)
+	expects := []CanValue{
+		{Channel: 1, CANID: 0x0CF004FE, Message: "EEC1", Signal: "EngineSpeed", Value: 1500, Unit: "rpm"},
+		{CANID: 0x18FEEEFE, Message: "ET1", Signal: "EngineCoolantTemperature", Value: 90, Unit: "degC"},
+		{CANID: 0x123, Message: "Test", Signal: "Mode", Value: 1},
+		{CANID: 0x123, Message: "Test", Signal: "Torque", Value: -12.3, Unit: "Nm"},
+	}
+	if len(values) != len(expects) {
+		t.Fatalf("got %d values, want %d: %+v", len(values), len(expects), values)
+	}
+	for idx, value := range values {
+		expect := expects[idx]
+		if diff := value.Value - expect.Value; diff > 1e-9 || diff < -1e-9 {
+			t.Fatalf("value %d: got %v, want %v", idx, value.Value, expect.Value)
+		}
+		value.Value = expect.Value
+		if value != expect {
+			t.Fatalf("value %d: got %+v, want %+v", idx, value, expect)
+		}
+	}
+}
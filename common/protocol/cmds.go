@@ -1,26 +1,26 @@
-package jtt
+package protocol
 
 const (
-	msgIDTerminalResponse          = uint16(1)      // 终端通用应答
+	MsgIDTerminalResponse          = uint16(1)      // 终端通用应答
 	MsgIDTerminalHeartbeat         = uint16(2)      // 终端心跳
 	msgIDTerminalLogout            = uint16(3)      // 终端注销
 	msgIDServerTime                = uint16(4)      // 查询服务器时间
 	msgIDTerminalPackResend        = uint16(5)      // 终端补传分包请求
-	msgIDTerminalLogin             = uint16(0x0100) // 终端注册
+	MsgIDTerminalLogin             = uint16(0x0100) // 终端注册
 	MsgIDTerminalAuth              = uint16(0x0102) // 终端鉴权
-	msgIDGetTerminalParamsResp     = uint16(0x0104) // 查询终端参数应答
-	msgIDGetTerminalAttrResp       = uint16(0x0107) // 查询终端属性应答
-	msgIDTerminalUpgradeResp       = uint16(0x0108) // 终端升级应答
+	MsgIDGetTerminalParamsResp     = uint16(0x0104) // 查询终端参数应答
+	MsgIDGetTerminalAttrResp       = uint16(0x0107) // 查询终端属性应答
+	MsgIDTerminalUpgradeResp       = uint16(0x0108) // 终端升级应答
 	MsgIDPositionReport            = uint16(0x0200) // 位置信息汇报
 	msgIDGetPositionResp           = uint16(0x0201) // 位置信息查询应答
 	msgIDBDLocationCheck           = uint16(0x0205) // 北斗验真上报
-	msgIDVehicleControlResp        = uint16(0x0500) // 车辆控制应答
-	msgIDGetAreaResp               = uint16(0x0608) // 查询区域或路线数据应答
-	msgIDDrivingRecordReport       = uint16(0x0700) // 行驶记录数据上传
+	MsgIDVehicleControlResp        = uint16(0x0500) // 车辆控制应答
+	MsgIDGetAreaResp               = uint16(0x0608) // 查询区域或路线数据应答
+	MsgIDDrivingRecordReport       = uint16(0x0700) // 行驶记录数据上传
 	msgIDWaybillReport             = uint16(0x0701) // 电子运单上报
-	msgIDDriverIdentityReport      = uint16(0x0702) // 驾驶员身份信息上报
+	MsgIDDriverIdentityReport      = uint16(0x0702) // 驾驶员身份信息上报
 	MsgIDPositionBatchReport       = uint16(0x0704) // 定位数据批量上传
-	msgIDCANDataReport             = uint16(0x0705) // CAN总线数据上传
+	MsgIDCANDataReport             = uint16(0x0705) // CAN总线数据上传
 	msgIDCanDataUpload             = uint16(0x0705) // CAN总线数据上传
 	msgIDMultimediaEventReport     = uint16(0x0800) // 多媒体事件信息上传
 	msgIDMultimediaDataReport      = uint16(0x0801) // 多媒体数据上传
@@ -30,9 +30,12 @@ const (
 	msgIDDataUpPenetrate           = uint16(0x0900) // 数据上行透传
 	msgIDDataCompressionReport     = uint16(0x0901) // 数据压缩上报
 	msgIDTerminalRSAPublickey      = uint16(0x0A00) // 终端RSA公钥
-	msgIDMediaPropertyReport       = uint16(0x1003) // 终端上传音视频属性
-	msgIDMediaResourceListReport   = uint16(0x1205) // 终端上传音视频资源列表
-	msgIDFileUploadFinish          = uint16(0x1206) // 文件上传完成通知
+	MsgIDMediaPropertyReport       = uint16(0x1003) // 终端上传音视频属性
+	MsgIDMediaResourceListReport   = uint16(0x1205) // 终端上传音视频资源列表
+	MsgIDFileUploadFinish          = uint16(0x1206) // 文件上传完成通知
+	MsgIDAlarmAttachInfo           = uint16(0x1210) // 报警附件信息消息
+	MsgIDAttachFileInfo            = uint16(0x1211) // 文件信息上传
+	MsgIDAttachFileFinish          = uint16(0x1212) // 文件上传完成消息
 
 	msgIDServerResponse           = uint16(0x8001) // 平台通用应答
 	msgIDServerPackResend         = uint16(0x8003) // 服务器补传分包请求
@@ -82,6 +85,7 @@ const (
 	msgIDFileUploadCmd            = uint16(0x9206) // 文件上传指令
 	msgIDFileUploadCtl            = uint16(0x9207) // 文件上传控制
 	msgIDAttachUpload             = uint16(0x9208) // 附件上传
+	msgIDAttachFileFinishResp     = uint16(0x9212) // 文件上传完成消息应答
 	msgIDPtzTurn                  = uint16(0x9301) // 云台转动控制
 	msgIDPtzFocus                 = uint16(0x9302) // 云台焦距控制
 	msgIDPtzAperture              = uint16(0x9303) // 云台光圈控制
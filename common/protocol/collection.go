@@ -1,5 +1,5 @@
 // Package jtt 信息采集类协议
-package jtt
+package protocol
 
 import (
 	"bytes"
@@ -13,17 +13,17 @@ func init() {
 			return waybillReportUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDDriverIdentityReport,
+		Cmd: MsgIDDriverIdentityReport,
 		NewUnmarshaler: func() Unmarshaler {
 			return driverIdentityReportUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDDrivingRecordReport,
+		Cmd: MsgIDDrivingRecordReport,
 		NewUnmarshaler: func() Unmarshaler {
 			return drivingRecordReportUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDCANDataReport,
+		Cmd: MsgIDCANDataReport,
 		NewUnmarshaler: func() Unmarshaler {
 			return canDataReportUnmarshal
 		},
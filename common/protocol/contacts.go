@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 联系人标志
+const (
+	ContactIn   = byte(1) // 呼入
+	ContactOut  = byte(2) // 呼出
+	ContactBoth = byte(3) // 呼入/呼出
+)
+
+// 设置电话本类型
+const (
+	ContactsDelete = byte(0) // 删除终端上所有存储的联系人
+	ContactsUpdate = byte(1) // 更新电话本（删除终端中已有全部联系人并追加消息中的联系人）
+	ContactsAppend = byte(2) // 追加电话本
+	ContactsModify = byte(3) // 修改电话本（以联系人为索引）
+)
+
+// 电话回拨标志
+const (
+	CallbackNormal = byte(0) // 普通通话
+	CallbackListen = byte(1) // 监听
+)
+
+const (
+	// MaxContacts 单条设置电话本消息的最大联系人数
+	MaxContacts = 255
+	// MaxContactPhoneLength 电话号码的最大长度
+	MaxContactPhoneLength = 20
+)
+
+// Validate 校验联系人标志、电话号码及GBK编码后的姓名长度
+func (c *Contact) Validate() error {
+	if c.Flag < ContactIn || c.Flag > ContactBoth {
+		return fmt.Errorf("unknown contact flag %d", c.Flag)
+	}
+	if "" == c.Phone || len(c.Phone) > MaxContactPhoneLength {
+		return fmt.Errorf("the phone of %s must be 1 to %d characters", c.Name, MaxContactPhoneLength)
+	}
+	if "" == c.Name {
+		return errors.New("the contact name is required")
+	}
+	length, err := TextLength(c.Name)
+	if nil != err {
+		return err
+	}
+	if length > 255 {
+		return fmt.Errorf("the contact name %s exceeds 255 bytes in GBK", c.Name)
+	}
+	return nil
+}
+
+// NewMsgContacts 新建设置电话本消息，删除时忽略联系人
+func NewMsgContacts(operation byte, contacts []Contact) (*MsgContactsSettings, error) {
+	msg := NewMsgContactsSettings()
+	msg.Operation = operation
+	switch operation {
+	case ContactsDelete:
+		return msg, nil
+	case ContactsUpdate, ContactsAppend, ContactsModify:
+	default:
+		return nil, fmt.Errorf("unknown contacts operation %d", operation)
+	}
+
+	if 0 == len(contacts) || len(contacts) > MaxContacts {
+		return nil, fmt.Errorf("the contacts must be 1 to %d", MaxContacts)
+	}
+	for idx := range contacts {
+		if err := contacts[idx].Validate(); nil != err {
+			return nil, err
+		}
+	}
+	msg.Contacts = contacts
+	return msg, nil
+}
+
+// NewMsgCallback 新建电话回拨消息，listen为true时监听，否则为普通通话
+func NewMsgCallback(phone string, listen bool) (*MsgTELCallback, error) {
+	if "" == phone || len(phone) > MaxContactPhoneLength {
+		return nil, fmt.Errorf("the phone must be 1 to %d characters", MaxContactPhoneLength)
+	}
+	msg := NewMsgTELCallback()
+	msg.Phone = phone
+	if listen {
+		msg.Flag = CallbackListen
+	}
+	return msg, nil
+}
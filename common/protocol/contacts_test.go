@@ -0,0 +1,57 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContactsMarshal(t *testing.T) {
+	msg, err := NewMsgContacts(ContactsUpdate, []Contact{
+		{Flag: ContactBoth, Phone: "110", Name: "中心"},
+		{Flag: ContactIn, Phone: "12", Name: "a"},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	body, err := setContactsMarshal(msg, version2019)
+	// 姓名长度为GBK编码后的字节数
+	want := []byte{ContactsUpdate, 2,
+		ContactBoth, 3, '1', '1', '0', 4, 0xD6, 0xD0, 0xD0, 0xC4,
+		ContactIn, 2, '1', '2', 1, 'a'}
+	if nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("got % x, want % x", body, want)
+	}
+
+	msg, _ = NewMsgContacts(ContactsDelete, []Contact{{Flag: 9}})
+	if body, _ = setContactsMarshal(msg, version2019); !bytes.Equal([]byte{ContactsDelete}, body) {
+		t.Fatalf("unexpected delete body % x", body)
+	}
+
+	for _, contacts := range [][]Contact{
+		nil,
+		{{Flag: 0, Phone: "1", Name: "a"}},
+		{{Flag: ContactOut, Phone: "123456789012345678901", Name: "a"}},
+		{{Flag: ContactOut, Phone: "1"}},
+	} {
+		if _, err := NewMsgContacts(ContactsAppend, contacts); nil == err {
+			t.Fatalf("expected an error for %+v", contacts)
+		}
+	}
+	if _, err := NewMsgContacts(4, []Contact{{Flag: ContactIn, Phone: "1", Name: "a"}}); nil == err {
+		t.Fatal("expected an error for an unknown operation")
+	}
+}
+
+func TestCallbackMarshal(t *testing.T) {
+	msg, err := NewMsgCallback("13800138000", true)
+	if nil != err {
+		t.Fatal(err)
+	}
+	body, err := telCallbackMarshal(msg, version2019)
+	if want := append([]byte{CallbackListen}, "13800138000"...); nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("got % x, want % x", body, want)
+	}
+	if _, err := NewMsgCallback("", false); nil == err {
+		t.Fatal("expected an error for an empty phone")
+	}
+}
@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/codec"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// MaxFrameSize 协议帧最大字节数：消息头（含分包项）、消息体、校验码全部转义后加上首尾标识位
+const MaxFrameSize = 2*(21+maxBodySize+1) + 2
+
+// 所有连接累计丢弃的字节数
+var discardedBytes uint64
+
+// DiscardedBytes 获取因帧过长或帧外垃圾数据而丢弃的累计字节数
+func DiscardedBytes() uint64 {
+	return atomic.LoadUint64(&discardedBytes)
+}
+
+// DelimiterCodec create delimiter codec
+//
+// bufSize 为读缓存初始大小，读缓存按需增长，但不超过maxFrameSize；
+// 超过maxFrameSize仍未收到结束定界符的帧会被丢弃，直到下一个定界符重新同步。
+func DelimiterCodec(delimiter byte, stripDelimiter bool, bufSize int, maxFrameSize int) codec.Codec {
+	utils.AssertIf(bufSize <= 0, "bufSize must be a positive integer")
+	utils.AssertIf(maxFrameSize < bufSize, "maxFrameSize must be not less than bufSize")
+	return &delimiterCodec{
+		delimiter:      delimiter,
+		stripDelimiter: stripDelimiter,
+		maxFrameSize:   maxFrameSize,
+		readBuf:        make([]byte, bufSize),
+	}
+}
+
+type delimiterCodec struct {
+	delimiter      byte   // 定界符
+	stripDelimiter bool   // 是否剥离定界符
+	maxFrameSize   int    // 帧最大字节数（含定界符）
+	readBuf        []byte // 读缓存
+	readIdx        int    // 读索引，readBuf[:readIdx]为未处理的数据
+	discarding     bool   // 是否正在丢弃过长的帧
+}
+
+func (*delimiterCodec) CodecName() string {
+	return "delimiter-codec"
+}
+
+func (d *delimiterCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	// 读缓存已满，说明未完成的帧占满了缓存，需要扩容或丢弃
+	if d.readIdx == len(d.readBuf) {
+		d.grow()
+	}
+
+	// message 为tcptransport，即tcp socket，直接可从里面读数据
+	reader := utils.MustToReader(message)
+	read := utils.AssertLength(reader.Read(d.readBuf[d.readIdx:]))
+	d.readIdx += read
+
+	d.split(func(frame []byte) {
+		ctx.HandleRead(frame)
+	})
+}
+
+func (d *delimiterCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	bts := message.([]byte)
+	ctx.HandleWrite([][]byte{{d.delimiter}, bts, {d.delimiter}})
+}
+
+// split 从读缓存中切分出完整的帧，剩余未完成的帧移动到读缓存头部
+func (d *delimiterCodec) split(handle func([]byte)) {
+	mark, start := 0, -1
+	for idx, bt := range d.readBuf[:d.readIdx] {
+		if d.delimiter != bt {
+			continue
+		}
+
+		// 丢弃过长帧直到遇到定界符
+		if d.discarding {
+			d.discard(idx + 1 - mark)
+			d.discarding = false
+			mark = idx + 1
+			continue
+		}
+
+		if -1 == start {
+			// 帧头前的垃圾数据
+			d.discard(idx - mark)
+			start = idx
+			continue
+		}
+
+		// 连续两个定界符，前一个视为残帧的结束符，当前定界符重新作为帧头
+		if idx == start+1 {
+			d.discard(1)
+			start = idx
+			continue
+		}
+
+		// 取出完整协议包
+		mark = idx + 1
+		if d.stripDelimiter {
+			handle(d.readBuf[start+1 : mark-1])
+		} else {
+			handle(d.readBuf[start:mark])
+		}
+		start = -1
+	}
+
+	switch {
+	case d.discarding:
+		// 仍在丢弃过长帧
+		d.discard(d.readIdx - mark)
+		d.readIdx = 0
+	case start < 0:
+		// 没有未完成的帧，剩余数据均为帧外垃圾
+		d.discard(d.readIdx - mark)
+		d.readIdx = 0
+	case d.readIdx-start >= d.maxFrameSize:
+		// 未完成的帧已达到上限仍未结束，丢弃至下一个定界符
+		d.discard(d.readIdx - start)
+		d.discarding = true
+		d.readIdx = 0
+	default:
+		// 移动剩余字节到读缓存头部
+		d.readIdx = copy(d.readBuf, d.readBuf[start:d.readIdx])
+	}
+}
+
+// grow 扩容读缓存，已达到上限时丢弃缓存中的数据
+func (d *delimiterCodec) grow() {
+	size := len(d.readBuf) << 1
+	if size > d.maxFrameSize {
+		size = d.maxFrameSize
+	}
+
+	if size <= len(d.readBuf) {
+		d.discard(d.readIdx)
+		d.discarding = true
+		d.readIdx = 0
+		return
+	}
+
+	buf := make([]byte, size)
+	copy(buf, d.readBuf[:d.readIdx])
+	d.readBuf = buf
+}
+
+// discard 记录丢弃的字节数
+func (d *delimiterCodec) discard(n int) {
+	if n <= 0 {
+		return
+	}
+	atomic.AddUint64(&discardedBytes, uint64(n))
+}
@@ -0,0 +1,84 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-netty/go-netty"
+)
+
+// inboundContext 记录解码器向后传递的帧
+type inboundContext struct {
+	netty.InboundContext
+	frames [][]byte
+}
+
+func (c *inboundContext) HandleRead(message netty.Message) {
+	c.frames = append(c.frames, append([]byte(nil), message.([]byte)...))
+}
+
+// feed 以data作为socket数据调用HandleRead，直到数据读完，返回切分出的帧
+func feed(d *delimiterCodec, data []byte) [][]byte {
+	ctx := &inboundContext{}
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		d.HandleRead(ctx, reader)
+	}
+	return ctx.frames
+}
+
+func TestDelimiterCodecPartialRead(t *testing.T) {
+	d := DelimiterCodec(0x7E, true, 8, 64).(*delimiterCodec)
+
+	discarded := DiscardedBytes()
+	frames := feed(d, []byte{0x7E, 0x01, 0x02})
+	if len(frames) != 0 {
+		t.Fatalf("unexpected frames: %x", frames)
+	}
+
+	frames = feed(d, []byte{0x03, 0x7E, 0x7E, 0x04, 0x7E})
+	if len(frames) != 2 || !bytes.Equal(frames[0], []byte{1, 2, 3}) || !bytes.Equal(frames[1], []byte{4}) {
+		t.Fatalf("unexpected frames: %x", frames)
+	}
+	if n := DiscardedBytes() - discarded; n != 0 {
+		t.Fatalf("discarded %d bytes, want 0", n)
+	}
+}
+
+func TestDelimiterCodecGrow(t *testing.T) {
+	d := DelimiterCodec(0x7E, true, 4, 64).(*delimiterCodec)
+
+	body := bytes.Repeat([]byte{0x55}, 40)
+	data := append(append([]byte{0x7E}, body...), 0x7E)
+
+	frames := feed(d, data)
+	if len(frames) != 1 || !bytes.Equal(frames[0], body) {
+		t.Fatalf("unexpected frames: %x", frames)
+	}
+}
+
+func TestDelimiterCodecResync(t *testing.T) {
+	d := DelimiterCodec(0x7E, true, 8, 16).(*delimiterCodec)
+
+	// 帧外垃圾数据
+	discarded := DiscardedBytes()
+	frames := feed(d, []byte{0xAA, 0xBB, 0x7E, 0x01, 0x7E})
+	if len(frames) != 1 || !bytes.Equal(frames[0], []byte{1}) {
+		t.Fatalf("unexpected frames: %x", frames)
+	}
+	if n := DiscardedBytes() - discarded; n != 2 {
+		t.Fatalf("discarded %d bytes, want 2", n)
+	}
+
+	// 超长帧被丢弃，直到下一个定界符重新同步
+	discarded = DiscardedBytes()
+	long := append([]byte{0x7E}, bytes.Repeat([]byte{0x55}, 30)...)
+	long = append(long, 0x7E, 0x7E, 0x02, 0x7E)
+	frames = feed(d, long)
+	if len(frames) != 1 || !bytes.Equal(frames[0], []byte{2}) {
+		t.Fatalf("unexpected frames: %x", frames)
+	}
+	if n := DiscardedBytes() - discarded; n != 32 {
+		t.Fatalf("discarded %d bytes, want 32", n)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+
 	"github.com/go-netty/go-netty"
 	"github.com/go-netty/go-netty/codec"
 	"github.com/go-netty/go-netty/utils"
@@ -27,7 +28,6 @@ type escapeCodec struct {
 	unescapeChar byte          // 反转义符
 	unescapeMap  map[byte]byte // 反转义表
 	escapeBuf    bytes.Buffer  // 转义缓存
-	unescapeBuf  bytes.Buffer  // 反转义缓存
 }
 
 func (*escapeCodec) CodecName() string {
@@ -37,7 +37,15 @@ func (*escapeCodec) CodecName() string {
 func (e *escapeCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
 	bts := message.([]byte)
 
-	hasEscape := false
+	n, err := e.unescape(bts)
+	utils.Assert(err)
+
+	ctx.HandleRead(bts[:n])
+}
+
+// unescape 原地反转义，反转义后的数据不会比原数据长，返回反转义后的长度
+func (e *escapeCodec) unescape(bts []byte) (int, error) {
+	n, hasEscape := 0, false
 	for idx, bt := range bts {
 		if e.unescapeChar == bt {
 			hasEscape = true
@@ -45,37 +53,42 @@ func (e *escapeCodec) HandleRead(ctx netty.InboundContext, message netty.Message
 		}
 
 		if hasEscape {
-			if bt, ok := e.unescapeMap[bt]; ok {
-				e.unescapeBuf.WriteByte(bt)
-			} else {
-				e.unescapeBuf.Reset()
-				panic(fmt.Errorf("接收的数据协议转义报错，数据：%s,位置：%d", hex.EncodeToString(bts), idx+1))
+			unescaped, ok := e.unescapeMap[bt]
+			if !ok {
+				return 0, fmt.Errorf("接收的数据协议转义报错，数据：%s,位置：%d", hex.EncodeToString(bts), idx+1)
 			}
 
+			bts[n] = unescaped
+			n++
 			hasEscape = false
 			continue
 		}
 
-		e.unescapeBuf.WriteByte(bt)
+		bts[n] = bt
+		n++
 	}
 
-	ctx.HandleRead(e.unescapeBuf.Bytes())
-	e.unescapeBuf.Reset()
+	return n, nil
 }
 
 func (e *escapeCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
 	bts := message.([]byte)
 
+	e.escape(&e.escapeBuf, bts)
+
+	ctx.HandleWrite(e.escapeBuf.Bytes())
+	e.escapeBuf.Reset()
+}
+
+// escape 转义后写入缓存
+func (e *escapeCodec) escape(buf *bytes.Buffer, bts []byte) {
 	for _, bt := range bts {
 		if bt, ok := e.escapeMap[bt]; ok {
-			e.escapeBuf.WriteByte(e.escapeChar)
-			e.escapeBuf.WriteByte(bt)
+			buf.WriteByte(e.escapeChar)
+			buf.WriteByte(bt)
 			continue
 		}
 
-		e.escapeBuf.WriteByte(bt)
+		buf.WriteByte(bt)
 	}
-
-	ctx.HandleWrite(e.escapeBuf.Bytes())
-	e.escapeBuf.Reset()
 }
@@ -0,0 +1,210 @@
+package protocol
+
+import (
+	"bytes"
+	"common/protocol/util"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 协议帧标识位
+const frameDelimiter = byte(0x7E)
+
+// 协议帧转义器，只使用无状态的转义、反转义方法，可以共享
+var frameEscaper = EscapeCodec(0x7D, map[byte]byte{0x7D: 0x01, 0x7E: 0x02}, 0x7D, map[byte]byte{0x01: 0x7D, 0x02: 0x7E}).(*escapeCodec)
+
+// ErrSubpackage 分包消息的单个子包无法解码消息体，需由调用方重组后调用DecodeBody
+var ErrSubpackage = errors.New("the subpackage must be reassembled before decoding")
+
+// Header 协议消息头
+type Header struct {
+	// 消息ID
+	ID uint16 `json:"id"`
+	// 协议版本号，0-2011版（无版本标识），1-2019版
+	Version byte `json:"version"`
+	// 终端手机号，2011版为12位数字，2019版为20位数字
+	Phone string `json:"phone"`
+	// 消息流水号
+	Number uint16 `json:"number"`
+	// 加密方式，0-不加密，1-RSA
+	Encryption byte `json:"encryption"`
+	// 消息总包数，不分包时为0
+	Total uint16 `json:"total"`
+	// 包序号，从1开始，不分包时为0
+	Index uint16 `json:"index"`
+}
+
+// header 转换为公开的消息头
+func (h *head) header() Header {
+	header := Header{
+		ID:         h.id,
+		Version:    h.version,
+		Number:     h.number,
+		Encryption: h.attr.getEncryption(),
+		Total:      h.pack.total,
+		Index:      h.pack.index,
+	}
+
+	if h.attr.hasVersionTag() {
+		header.Phone = string(util.ParseBCD(h.phone[:]))
+	} else {
+		header.Phone = string(util.ParseBCD(h.phone[4:]))
+	}
+
+	return header
+}
+
+// newHead 由公开的消息头创建消息头，分包信息由打包时计算
+func newHead(header Header) (head, error) {
+	h := head{
+		id:      header.ID,
+		version: header.Version,
+		number:  header.Number,
+	}
+	h.attr.setEncryption(header.Encryption)
+
+	// 指定版本号
+	if version2011 != h.version {
+		h.attr.versionTag()
+	}
+
+	// 手机号不足20位前面补0
+	phone := header.Phone
+	if len(phone) > 20 {
+		return h, fmt.Errorf("终端手机号[%s]超过20位", phone)
+	}
+	for _, ch := range phone {
+		if ch < '0' || ch > '9' {
+			return h, fmt.Errorf("终端手机号[%s]必须为数字", phone)
+		}
+	}
+	copy(h.phone[:], util.ToBCD([]byte(strings.Repeat("0", 20-len(phone))+phone)))
+
+	return h, nil
+}
+
+// DecodeFrame 解码一个完整的协议帧，帧可以带首尾标识位，也可以不带。
+//
+// 解码过程包括反转义、校验码检查和消息头解析，并按消息头中的版本号解码消息体。
+// 传入的字节序不会被修改。分包消息返回消息头和ErrSubpackage。
+func DecodeFrame(frame []byte) (Header, Input, error) {
+	// 去除首尾标识位
+	if l := len(frame); l >= 2 && frameDelimiter == frame[0] && frameDelimiter == frame[l-1] {
+		frame = frame[1 : l-1]
+	}
+
+	bts := make([]byte, len(frame))
+	copy(bts, frame)
+
+	// 反转义
+	n, err := frameEscaper.unescape(bts)
+	if nil != err {
+		return Header{}, nil, err
+	}
+
+	// 校验码及消息头
+	var p packet
+	if err := p.unpack(bts[:n]); nil != err {
+		return Header{}, nil, err
+	}
+
+	header := p.head.header()
+	if int(p.head.attr.getBodySize()) != len(p.body) {
+		return header, nil, fmt.Errorf("the bad protocol data: body length %d, want %d", len(p.body), p.head.attr.getBodySize())
+	}
+
+	if p.head.attr.isSubpackage() {
+		return header, nil, ErrSubpackage
+	}
+
+	input, err := DecodeBody(header, p.body)
+	return header, input, err
+}
+
+// DecodeBody 按消息头解码消息体，用于调用方自行重组的分包消息。
+//
+// 消息体来自终端，长度与内容均不可信，解码器越界等错误以error返回。
+func DecodeBody(header Header, body []byte) (input Input, err error) {
+	unmarshaler := NewUnmarshaler(header.ID)
+	if nil == unmarshaler {
+		return nil, fmt.Errorf("协议[%#x]解码器不存在！", header.ID)
+	}
+
+	defer func() {
+		if r := recover(); nil != r {
+			input, err = nil, fmt.Errorf("the bad protocol data: message %#x: %v", header.ID, r)
+		}
+	}()
+
+	input, err = unmarshaler(bytes.NewBuffer(body), header.Version)
+	if nil != err {
+		return nil, err
+	}
+
+	// 将消息id和流水号带上
+	input.setIDAndNumber(header.ID, header.Number)
+
+	return input, nil
+}
+
+// EncodeFrame 将消息编码为一个完整的协议帧（含首尾标识位）。
+//
+// 消息ID取自消息本身，版本号、手机号、流水号和加密方式取自header；
+// 消息体超过单包大小时返回错误，需要分包的消息使用EncodeFrames。
+func EncodeFrame(output Output, header Header) ([]byte, error) {
+	frames, err := EncodeFrames(output, header)
+	if nil != err {
+		return nil, err
+	}
+
+	if len(frames) > 1 {
+		return nil, fmt.Errorf("协议[%#x]消息体过大，需要分为%d包发送", output.msgID(), len(frames))
+	}
+
+	return frames[0], nil
+}
+
+// EncodeFrames 将消息编码为协议帧（含首尾标识位），消息体过大时自动分包，
+// 各子包的流水号自header.Number起依次递增
+func EncodeFrames(output Output, header Header) ([][]byte, error) {
+	// 获取对应消息体打包函数
+	header.ID = output.msgID()
+	marshal := NewMarshaler(header.ID)
+	if nil == marshal {
+		return nil, fmt.Errorf("协议[%#x]编码器不存在！", header.ID)
+	}
+
+	// 打包消息体
+	body, err := marshal(output, header.Version)
+	if nil != err {
+		return nil, err
+	}
+
+	h, err := newHead(header)
+	if nil != err {
+		return nil, err
+	}
+
+	packet := &packet{
+		head: h,
+		body: body,
+	}
+
+	packs, err := packet.packs()
+	if nil != err {
+		return nil, err
+	}
+
+	// 转义并添加首尾标识位
+	frames := make([][]byte, 0, len(packs))
+	for _, pack := range packs {
+		var buf bytes.Buffer
+		buf.WriteByte(frameDelimiter)
+		frameEscaper.escape(&buf, pack)
+		buf.WriteByte(frameDelimiter)
+		frames = append(frames, buf.Bytes())
+	}
+
+	return frames, nil
+}
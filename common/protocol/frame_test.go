@@ -0,0 +1,124 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+func TestDecodeFrame(t *testing.T) {
+	frame := append(append([]byte{0x7E}, positionFrame...), 0x7E)
+	origin := append([]byte(nil), frame...)
+
+	header, input, err := DecodeFrame(frame)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, origin) {
+		t.Fatal("DecodeFrame must not modify the frame")
+	}
+
+	if header.ID != MsgIDPositionReport || header.Version != version2011 || header.Phone != "012345678901" || header.Number != 1 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+
+	msg, ok := input.(*MsgPositionReport)
+	if !ok || msg.Number != 1 || len(msg.Auxs) != 7 {
+		t.Fatalf("unexpected input: %+v", input)
+	}
+}
+
+func TestDecodeBodyTruncated(t *testing.T) {
+	ids := []uint16{msgIDWaybillReport, MsgIDPositionBatchReport, msgIDGetMultimediaSaveInfoResp, MsgIDMediaResourceListReport}
+	for id := range unmarshals {
+		ids = append(ids, id)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, id := range ids {
+		for size := 0; size < 64; size++ {
+			body := make([]byte, size)
+			random.Read(body)
+			// 解码失败只能返回错误，不能panic
+			if input, err := DecodeBody(Header{ID: id}, body); nil != err && nil != input {
+				t.Fatalf("%#x: unexpected input %+v with error %v", id, input, err)
+			}
+		}
+	}
+
+	// 2字节的电子运单消息体，不足4字节的运单长度
+	var buf bytes.Buffer
+	raw, _ := hex.DecodeString("0701400201" + "00000000013912345678" + "0001" + "0010")
+	buf.Write(raw)
+	addChecksum(&buf)
+	frame := append(append([]byte{0x7E}, buf.Bytes()...), 0x7E)
+	if header, _, err := DecodeFrame(frame); nil == err || msgIDWaybillReport != header.ID {
+		t.Fatalf("expected an error for a truncated body, header %+v", header)
+	}
+	for _, frame := range [][]byte{nil, {0x7E}, {0x7E, 0x7E}, {0x07, 0x01, 0x00}} {
+		if _, _, err := DecodeFrame(frame); nil == err {
+			t.Fatalf("expected an error for frame %x", frame)
+		}
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	header := Header{Version: version2019, Phone: "13912345678", Number: 0x7E}
+
+	frame, err := EncodeFrame(NewMsgServerResponse(0x7D, MsgIDPositionReport, 0), header)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// 消息头、消息体及校验码
+	var buf bytes.Buffer
+	raw, _ := hex.DecodeString("8001400501" + "00000000013912345678" + "007e" + "007d020000")
+	buf.Write(raw)
+	addChecksum(&buf)
+	want := "7e" + "8001400501" + "00000000013912345678" + "007d02" + "007d01020000" + hex.EncodeToString(buf.Bytes()[buf.Len()-1:]) + "7e"
+
+	if got := hex.EncodeToString(frame); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestEncodeFramesSubpackage(t *testing.T) {
+	msg := NewMsgDataDownlink()
+	msg.Content = bytes.Repeat([]byte{0x7E}, 2*maxBodySize)
+
+	frames, err := EncodeFrames(msg, Header{Phone: "013912345678", Number: 9})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+
+	if _, err := EncodeFrame(msg, Header{Phone: "013912345678"}); nil == err {
+		t.Fatal("EncodeFrame must reject oversized body")
+	}
+
+	var body []byte
+	for idx, frame := range frames {
+		header, _, err := DecodeFrame(frame)
+		if ErrSubpackage != err {
+			t.Fatalf("frame %d: got %v, want ErrSubpackage", idx, err)
+		}
+		if header.Total != 3 || header.Index != uint16(idx+1) || header.Number != 9+uint16(idx) {
+			t.Fatalf("frame %d: unexpected header %+v", idx, header)
+		}
+
+		bts := append([]byte(nil), frame[1:len(frame)-1]...)
+		n, _ := frameEscaper.unescape(bts)
+		var p packet
+		if err := p.unpack(bts[:n]); nil != err {
+			t.Fatal(err)
+		}
+		body = append(body, p.body...)
+	}
+
+	if !bytes.Equal(body[1:], msg.Content) {
+		t.Fatal("reassembled body mismatch")
+	}
+}
@@ -1,4 +1,4 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
@@ -7,7 +7,7 @@ import (
 
 func init() {
 	RegisterUnmarshals(&Unmarshal{
-		Cmd: msgIDTerminalResponse,
+		Cmd: MsgIDTerminalResponse,
 		NewUnmarshaler: func() Unmarshaler {
 			return terminalResponseUnmarshal
 		},
@@ -1,5 +1,8 @@
-module protocol
+module common/protocol
 
 go 1.15
 
-require github.com/go-netty/go-netty v0.0.0-20220104093642-a83877336e91
+require (
+	github.com/axgle/mahonia v0.0.0-20180208002826-3358181d7394
+	github.com/go-netty/go-netty v0.0.0-20210318115346-68000ac9a2c6
+)
@@ -1,4 +1,4 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
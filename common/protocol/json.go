@@ -0,0 +1,842 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+func init() {
+	registerOutputs(
+		func() Output { return NewMsgServerResponse(0, 0, 0) },
+		func() Output { return NewMsgServerTimeResp() },
+		func() Output { return NewMsgSerGetSubpacket() },
+		func() Output { return NewMsgTerminalLoginResp() },
+		func() Output { return NewMsgTerParamsSettings() },
+		func() Output { return NewMsgGetTerminalParams() },
+		func() Output { return NewMsgGetTerSpecParams() },
+		func() Output { return NewMsgTerminalControl() },
+		func() Output { return NewMsgGetTerminalAttr() },
+		func() Output { return NewMsgTerminalUpgrade() },
+		func() Output { return NewMsgGetPosition() },
+		func() Output { return NewMsgTrackControl() },
+		func() Output { return NewMsgManualConfirmAlarm() },
+		func() Output { return NewMsgLinkCheck() },
+		func() Output { return NewMsgTextIssued() },
+		func() Output { return NewMsgTELCallback() },
+		func() Output { return NewMsgContactsSettings() },
+		func() Output { return NewMsgVehicleControl() },
+		func() Output { return NewMsgRoundAreaSettings() },
+		func() Output { return NewMsgRoundAreaDelete() },
+		func() Output { return NewMsgRectAreaSettings() },
+		func() Output { return NewMsgRectAreaDelete() },
+		func() Output { return NewMsgPolygonAreaSettings() },
+		func() Output { return NewMsgPolygonAreaDelete() },
+		func() Output { return NewMsgPathSettings() },
+		func() Output { return NewMsgPathDelete() },
+		func() Output { return NewMsgGetAreaOrPath() },
+		func() Output { return NewMsgGatherDrivingRecord() },
+		func() Output { return NewMsgDrivingRecordParamsIssued() },
+		func() Output { return NewMsgMultimediaReportResp() },
+		func() Output { return NewMsgSnapshoot() },
+		func() Output { return NewMsgSearchLocalMultimedia() },
+		func() Output { return NewMsgPullLocalMultimedia() },
+		func() Output { return NewMsgRecording() },
+		func() Output { return NewMsgGetLocalMultimedia() },
+		func() Output { return NewMsgDataDownlink() },
+		func() Output { return NewMsgServerRSAPublicKey() },
+		func() Output { return NewMsgGetDriverIdentity() },
+		func() Output { return NewMsgRemoteVideoReplay() },
+		func() Output { return NewMsgRemoteReplayControl() },
+		func() Output { return NewMsgMediaResourceSelect() },
+		func() Output { return NewMsgMediaProperty() },
+		func() Output { return NewMsgRealMediaRequest() },
+		func() Output { return NewMsgRealMediaControl() },
+		func() Output { return NewMsgRealMediaStatusNotice() },
+		func() Output { return NewMsgFileUploadCmd() },
+		func() Output { return NewMsgFileUploadCtl() },
+		func() Output { return NewMsgAttachUploadSb() },
+		func() Output { return NewMsgAttachFileFinishResp() },
+		func() Output { return NewMsgPtzTurn() },
+		func() Output { return NewMsgPtzFocus() },
+		func() Output { return NewMsgPtzAperture() },
+		func() Output { return NewMsgPtzWiper() },
+		func() Output { return NewMsgPtzFillLight() },
+		func() Output { return NewMsgPtzZoom() },
+	)
+}
+
+// 下行消息构造表，用于由JSON还原下行消息
+var outputs = make(map[uint16]func() Output)
+
+// registerOutputs 注册下行消息构造函数，消息ID取自构造出的消息
+func registerOutputs(newOutputs ...func() Output) {
+	for _, newOutput := range newOutputs {
+		outputs[newOutput().msgID()] = newOutput
+	}
+}
+
+// NewOutput 按消息ID新建下行消息，消息ID未注册时返回nil
+func NewOutput(id uint16) Output {
+	newOutput := outputs[id]
+	if nil == newOutput {
+		return nil
+	}
+	return newOutput()
+}
+
+// UnmarshalOutput 由JSON还原下行消息，用于通用的下行指令接口。
+//
+// JSON中的字段名与消息序列化为JSON时一致，未出现的字段保持构造函数的默认值。
+func UnmarshalOutput(id uint16, data []byte) (Output, error) {
+	output := NewOutput(id)
+	if nil == output {
+		return nil, fmt.Errorf("协议[%#x]下行消息不存在！", id)
+	}
+
+	if err := json.Unmarshal(data, output); nil != err {
+		return nil, err
+	}
+
+	if id != output.msgID() {
+		return nil, errors.New("消息体数据与消息ID不符")
+	}
+
+	return output, nil
+}
+
+// flagBit 标志位字段，width为1时表示为布尔值，否则表示为数值
+type flagBit struct {
+	name  string
+	shift uint
+	width uint
+}
+
+func (f *flagBit) mask() uint32 {
+	return (1<<f.width - 1) << f.shift
+}
+
+// marshalFlags 将标志位展开为以名称为键的JSON对象
+func marshalFlags(value uint32, bits []flagBit) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for idx := range bits {
+		bit := &bits[idx]
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(bit.name))
+		buf.WriteByte(':')
+
+		v := value & bit.mask() >> bit.shift
+		if 1 == bit.width {
+			buf.WriteString(strconv.FormatBool(v > 0))
+		} else {
+			buf.WriteString(strconv.FormatUint(uint64(v), 10))
+		}
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// unmarshalFlags 由JSON对象或原始数值解析标志位，对象中未出现的标志位为0
+func unmarshalFlags(data []byte, bits []flagBit) (uint32, error) {
+	// 原始数值
+	var value uint32
+	if err := json.Unmarshal(data, &value); nil == err {
+		return value, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); nil != err {
+		return 0, err
+	}
+
+	for name, field := range fields {
+		bit := findFlagBit(bits, name)
+		if nil == bit {
+			return 0, fmt.Errorf("未知的标志位[%s]", name)
+		}
+
+		var v uint32
+		if 1 == bit.width {
+			var truth bool
+			if err := json.Unmarshal(field, &truth); nil != err {
+				return 0, err
+			}
+			if truth {
+				v = 1
+			}
+		} else if err := json.Unmarshal(field, &v); nil != err {
+			return 0, err
+		}
+
+		value |= v << bit.shift & bit.mask()
+	}
+
+	return value, nil
+}
+
+// findFlagBit 按名称查找标志位字段
+func findFlagBit(bits []flagBit, name string) *flagBit {
+	for idx := range bits {
+		if name == bits[idx].name {
+			return &bits[idx]
+		}
+	}
+	return nil
+}
+
+// flagNames 置位的布尔标志位名称
+func flagNames(value uint32, bits []flagBit) []string {
+	var names []string
+	for idx := range bits {
+		if 1 == bits[idx].width && 0 != value&bits[idx].mask() {
+			names = append(names, bits[idx].name)
+		}
+	}
+	return names
+}
+
+// 报警标识位
+var alarmFlagBits = []flagBit{
+	{"emergency", 0, 1},
+	{"over_speed", 1, 1},
+	{"tired", 2, 1},
+	{"dangerous", 3, 1},
+	{"gnss_breakdown", 4, 1},
+	{"gnss_unhook", 5, 1},
+	{"gnss_short_out", 6, 1},
+	{"main_low_voltage", 7, 1},
+	{"main_power_down", 8, 1},
+	{"display_breakdown", 9, 1},
+	{"tts_breakdown", 10, 1},
+	{"camera_breakdown", 11, 1},
+	{"ic_card_breakdown", 12, 1},
+	{"over_speed_early", 13, 1},
+	{"tired_early", 14, 1},
+	{"violation", 15, 1},
+	{"tire_pressure_early", 16, 1},
+	{"right_blind_abnormal", 17, 1},
+	{"timeout", 18, 1},
+	{"timeout_parking", 19, 1},
+	{"pass_area", 20, 1},
+	{"pass_path", 21, 1},
+	{"duration_err_in_segment", 22, 1},
+	{"deviate_path", 23, 1},
+	{"vss_breakdown", 24, 1},
+	{"oil_mass_abnormal", 25, 1},
+	{"be_stolen", 26, 1},
+	{"illegal_boot", 27, 1},
+	{"illegal_move", 28, 1},
+	{"rollover", 29, 1},
+	{"rollover_early", 30, 1},
+	{"other_alarm", 31, 1},
+}
+
+// MarshalJSON 报警标识展开为命名的布尔值
+func (a AlarmFlag) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(a), alarmFlagBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析报警标识
+func (a *AlarmFlag) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, alarmFlagBits)
+	*a = AlarmFlag(value)
+	return err
+}
+
+// MarshalJSON 多媒体报警标识展开为命名的布尔值，与位置报警标识定义相同
+func (m MediaAlarmFlag) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(m), alarmFlagBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析多媒体报警标识
+func (m *MediaAlarmFlag) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, alarmFlagBits)
+	*m = MediaAlarmFlag(value)
+	return err
+}
+
+// 状态标识位
+var statusFlagBits = []flagBit{
+	{"acc_open", 0, 1},
+	{"fixed", 1, 1},
+	{"south", 2, 1},
+	{"west", 3, 1},
+	{"outage", 4, 1},
+	{"encrypted", 5, 1},
+	{"crash_early", 6, 1},
+	{"lane_shift_early", 7, 1},
+	{"cargo_status", 8, 2},
+	{"off_oil", 10, 1},
+	{"off_circuit", 11, 1},
+	{"door_lock", 12, 1},
+	{"front_door_opened", 13, 1},
+	{"middle_door_opened", 14, 1},
+	{"back_door_opened", 15, 1},
+	{"side_door_opened", 16, 1},
+	{"other_door_opened", 17, 1},
+	{"gps_opened", 18, 1},
+	{"bd_opened", 19, 1},
+	{"gl_opened", 20, 1},
+	{"ga_opened", 21, 1},
+	{"running", 22, 1},
+}
+
+// MarshalJSON 状态标识展开为命名的布尔值，载货状态为数值
+func (s StatusFlag) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(s), statusFlagBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析状态标识
+func (s *StatusFlag) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, statusFlagBits)
+	*s = StatusFlag(value)
+	return err
+}
+
+// 扩展车辆信号状态位
+var signalBits = []flagBit{
+	{"low_beam", 0, 1},
+	{"high_beam", 1, 1},
+	{"right_turn", 2, 1},
+	{"left_turn", 3, 1},
+	{"brake", 4, 1},
+	{"reverse_gear", 5, 1},
+	{"fog_lamp", 6, 1},
+	{"marker_lamp", 7, 1},
+	{"blow", 8, 1},
+	{"air_cond_opened", 9, 1},
+	{"neutral_gear", 10, 1},
+	{"retarder_working", 11, 1},
+	{"abs_working", 12, 1},
+	{"heater_working", 13, 1},
+	{"clutch_released", 14, 1},
+}
+
+// MarshalJSON 扩展车辆信号状态展开为命名的布尔值
+func (s Signal) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(s), signalBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析扩展车辆信号状态
+func (s *Signal) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, signalBits)
+	*s = Signal(value)
+	return err
+}
+
+// 报警确认标识位
+var alarmConfirmBits = []flagBit{
+	{"emergency", 0, 1},
+	{"danger", 3, 1},
+	{"in_out_area", 20, 1},
+	{"in_out_path", 21, 1},
+	{"road_time", 22, 1},
+	{"ignition", 27, 1},
+	{"move", 28, 1},
+}
+
+// MarshalJSON 报警确认信息展开为命名的布尔值
+func (a AlarmConfirm) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(a), alarmConfirmBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析报警确认信息
+func (a *AlarmConfirm) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, alarmConfirmBits)
+	*a = AlarmConfirm(value)
+	return err
+}
+
+// 文本标志位
+var textFlagBits = []flagBit{
+	{"type", 0, 2},
+	{"show", 2, 1},
+	{"tts", 3, 1},
+	{"advertising", 4, 1},
+	{"can_fault", 5, 1},
+}
+
+// MarshalJSON 文本标志展开为命名的布尔值，文本类型为数值
+func (t TextFlag) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(t), textFlagBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析文本标志
+func (t *TextFlag) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, textFlagBits)
+	*t = TextFlag(value)
+	return err
+}
+
+// 区域属性位
+var areaAttrBits = []flagBit{
+	{"has_time", 0, 1},
+	{"has_speed", 1, 1},
+	{"enter_alarm_to_driver", 2, 1},
+	{"enter_alarm_to_server", 3, 1},
+	{"exit_alarm_to_driver", 4, 1},
+	{"exit_alarm_to_server", 5, 1},
+	{"south", 6, 1},
+	{"west", 7, 1},
+	{"door_forbidden", 8, 1},
+	{"close_comm_with_enter", 14, 1},
+	{"gather_gnss_with_enter", 15, 1},
+}
+
+// MarshalJSON 区域属性展开为命名的布尔值
+func (a AreaAttr) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(a), areaAttrBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析区域属性
+func (a *AreaAttr) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, areaAttrBits)
+	*a = AreaAttr(value)
+	return err
+}
+
+// 路线属性位
+var polylineAttrBits = []flagBit{
+	{"has_time", 0, 1},
+	{"enter_alarm_to_driver", 2, 1},
+	{"enter_alarm_to_server", 3, 1},
+	{"exit_alarm_to_driver", 4, 1},
+	{"exit_alarm_to_server", 5, 1},
+}
+
+// MarshalJSON 路线属性展开为命名的布尔值
+func (a PolylineAttr) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(a), polylineAttrBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析路线属性
+func (a *PolylineAttr) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, polylineAttrBits)
+	*a = PolylineAttr(value)
+	return err
+}
+
+// 路段属性位
+var segmentAttrBits = []flagBit{
+	{"has_time", 0, 1},
+	{"has_speed_limit", 1, 1},
+	{"south", 2, 1},
+	{"west", 3, 1},
+}
+
+// MarshalJSON 路段属性展开为命名的布尔值
+func (s SegmentAttr) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(s), segmentAttrBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析路段属性
+func (s *SegmentAttr) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, segmentAttrBits)
+	*s = SegmentAttr(value)
+	return err
+}
+
+// GNSS模块属性位
+var gnssAttrBits = []flagBit{
+	{"gps", 0, 1},
+	{"bd", 1, 1},
+	{"glonass", 2, 1},
+	{"galileo", 3, 1},
+}
+
+// MarshalJSON GNSS模块属性展开为命名的布尔值
+func (g GNSSAttr) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(g), gnssAttrBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析GNSS模块属性
+func (g *GNSSAttr) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, gnssAttrBits)
+	*g = GNSSAttr(value)
+	return err
+}
+
+// Capabilities 支持的定位系统名称
+func (g GNSSAttr) Capabilities() []string {
+	return flagNames(uint32(g), gnssAttrBits)
+}
+
+// 通信模块属性位
+var commAttrBits = []flagBit{
+	{"gprs", 0, 1},
+	{"cdma", 1, 1},
+	{"td_scdma", 2, 1},
+	{"wcdma", 3, 1},
+	{"cdma2000", 4, 1},
+	{"td_lte", 5, 1},
+	{"other", 7, 1},
+}
+
+// MarshalJSON 通信模块属性展开为命名的布尔值
+func (c COMMAttr) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(c), commAttrBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析通信模块属性
+func (c *COMMAttr) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, commAttrBits)
+	*c = COMMAttr(value)
+	return err
+}
+
+// Capabilities 支持的通信方式名称
+func (c COMMAttr) Capabilities() []string {
+	return flagNames(uint32(c), commAttrBits)
+}
+
+// 车辆状态位（苏标）
+var vehicleStatusBits = []flagBit{
+	{"acc_open", 0, 1},
+	{"left_turn", 1, 1},
+	{"right_turn", 2, 1},
+	{"wiper_open", 3, 1},
+	{"brake", 4, 1},
+	{"card_in", 5, 1},
+	{"located", 10, 1},
+}
+
+// MarshalJSON 车辆状态展开为命名的布尔值
+func (s VehicleStatus) MarshalJSON() ([]byte, error) {
+	return marshalFlags(uint32(s), vehicleStatusBits)
+}
+
+// UnmarshalJSON 由命名的布尔值或原始数值解析车辆状态
+func (s *VehicleStatus) UnmarshalJSON(data []byte) error {
+	value, err := unmarshalFlags(data, vehicleStatusBits)
+	*s = VehicleStatus(value)
+	return err
+}
+
+// positionJSON 位置信息的JSON表示，经纬度为十进制度数，南纬、西经为负数
+type positionJSON struct {
+	Alarm     AlarmFlag     `json:"alarm"`
+	Status    StatusFlag    `json:"status"`
+	Latitude  float64       `json:"latitude"`
+	Longitude float64       `json:"longitude"`
+	Altitude  uint16        `json:"altitude"`
+	Speed     uint16        `json:"speed"`
+	Bearing   uint16        `json:"bearing"`
+	Time      time.Time     `json:"time"`
+	Auxs      []PositionAux `json:"auxs"`
+}
+
+// MarshalJSON 位置信息序列化，经纬度转换为十进制度数
+func (p Position) MarshalJSON() ([]byte, error) {
+	pos := positionJSON{
+		Alarm:     p.Alarm,
+		Status:    p.Status,
+		Latitude:  float64(p.Latitude) / 1e6,
+		Longitude: float64(p.Longitude) / 1e6,
+		Altitude:  p.Altitude,
+		Speed:     p.Speed,
+		Bearing:   p.Bearing,
+		Time:      p.Time,
+		Auxs:      p.Auxs,
+	}
+	if p.Status.IsSourth() {
+		pos.Latitude = -pos.Latitude
+	}
+	if p.Status.IsWest() {
+		pos.Longitude = -pos.Longitude
+	}
+
+	return json.Marshal(&pos)
+}
+
+// UnmarshalJSON 位置信息反序列化，经纬度的正负决定南北纬、东西经状态
+func (p *Position) UnmarshalJSON(data []byte) error {
+	var pos positionJSON
+	if err := json.Unmarshal(data, &pos); nil != err {
+		return err
+	}
+
+	p.Alarm = pos.Alarm
+	p.Status = pos.Status
+	p.Status.Sourth(pos.Latitude < 0)
+	p.Status.West(pos.Longitude < 0)
+	p.Latitude = uint32(math.Round(math.Abs(pos.Latitude) * 1e6))
+	p.Longitude = uint32(math.Round(math.Abs(pos.Longitude) * 1e6))
+	p.Altitude = pos.Altitude
+	p.Speed = pos.Speed
+	p.Bearing = pos.Bearing
+	p.Time = pos.Time
+	p.Auxs = pos.Auxs
+
+	return nil
+}
+
+// joinJSONObjects 将多个结构体序列化后合并为一个JSON对象，用于内嵌了位置信息的消息
+func joinJSONObjects(values ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, value := range values {
+		bts, err := json.Marshal(value)
+		if nil != err {
+			return nil, err
+		}
+
+		// 去掉首尾花括号，跳过空对象
+		bts = bytes.TrimSpace(bts)
+		if len(bts) <= 2 {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(bts[1 : len(bts)-1])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON 位置汇报序列化，消息标签与位置信息平铺在同一对象中
+func (m MsgPositionReport) MarshalJSON() ([]byte, error) {
+	return joinJSONObjects(&m.InputMark, m.Position)
+}
+
+// UnmarshalJSON 位置汇报反序列化
+func (m *MsgPositionReport) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.InputMark); nil != err {
+		return err
+	}
+	return json.Unmarshal(data, &m.Position)
+}
+
+// MarshalJSON 北斗验真上报序列化，消息标签、位置信息与卫星状态平铺在同一对象中
+func (m MsgBDLocCheck) MarshalJSON() ([]byte, error) {
+	return joinJSONObjects(&m.InputMark, m.Position, &m.GnnsStatus)
+}
+
+// UnmarshalJSON 北斗验真上报反序列化
+func (m *MsgBDLocCheck) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.InputMark); nil != err {
+		return err
+	}
+	if err := json.Unmarshal(data, &m.Position); nil != err {
+		return err
+	}
+	return json.Unmarshal(data, &m.GnnsStatus)
+}
+
+// typedJSON 带类型信息的参数项JSON表示
+type typedJSON struct {
+	ID    uint32          `json:"id"`
+	Len   byte            `json:"len"`
+	Value json.RawMessage `json:"value"`
+}
+
+// unmarshalTypedValue 按类型名称解析参数值，类型名称与参数、附加信息对照表一致，
+// 解析得到的值类型与协议解码得到的值类型相同，可直接用于编码
+func unmarshalTypedValue(data json.RawMessage, tpName string) (interface{}, error) {
+	switch tpName {
+	case "uint8", "byte":
+		var v uint8
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "int16":
+		var v int16
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "uint16":
+		var v uint16
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "uint32":
+		var v uint32
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "string":
+		var v string
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "[]uint8", "[]byte":
+		var v []byte
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "SpeedAlarm":
+		var v SpeedAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "LocalAlarm":
+		var v LocalAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "RuntimeAlarm":
+		var v RuntimeAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "DMSAlarm":
+		var v DMSAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "TPMSAlarm":
+		var v TPMSAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "CustomAuxInfo":
+		var v CustomAuxInfo
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "ADASAlarm":
+		var v ADASAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "BSDAlarm":
+		var v BSDAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "AIAlarm":
+		var v AIAlarm
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "AVParam":
+		var v AVParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "AVChannelList":
+		var v AVChannelList
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "VideoChannelParams":
+		var v VideoChannelParams
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "AlarmRecordParam":
+		var v AlarmRecordParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "ImageAnalysisParam":
+		var v ImageAnalysisParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "WakeUpParam":
+		var v WakeUpParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "ADASParam":
+		var v ADASParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "DSMParam":
+		var v DSMParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "TPMSParam":
+		var v TPMSParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	case "BSDParam":
+		var v BSDParam
+		err := json.Unmarshal(data, &v)
+		return v, err
+	default:
+		return nil, fmt.Errorf("未知的参数类型[%s]", tpName)
+	}
+}
+
+// UnmarshalJSON 终端参数项反序列化，参数值类型取自终端参数定义，
+// 未定义的参数及不能按定义解析的值按原始字节（base64）解析
+func (p *Param) UnmarshalJSON(data []byte) error {
+	var param typedJSON
+	if err := json.Unmarshal(data, &param); nil != err {
+		return err
+	}
+
+	var value interface{}
+	err := fmt.Errorf("终端参数[%#x]类型未知", param.ID)
+	if tpName := paramTypeName(param.ID); "" != tpName {
+		value, err = unmarshalTypedValue(param.Value, tpName)
+	}
+	if nil != err {
+		var raw []byte
+		if nil != json.Unmarshal(param.Value, &raw) {
+			return err
+		}
+		value = raw
+	}
+
+	p.ID, p.Len, p.Value = param.ID, param.Len, value
+	return nil
+}
+
+// MarshalJSON 位置附加信息项序列化，扩展车辆信号状态位展开为命名的布尔值
+func (p PositionAux) MarshalJSON() ([]byte, error) {
+	type positionAux PositionAux
+	aux := positionAux(p)
+	if v, ok := p.Value.(uint32); ok && AuxIDVehicleStatus == p.ID {
+		aux.Value = Signal(v)
+	}
+	return json.Marshal(&aux)
+}
+
+// UnmarshalJSON 位置附加信息项反序列化，值类型取自自定义附加信息项或位置附加信息对照表，未知附加项按字节数组解析
+func (p *PositionAux) UnmarshalJSON(data []byte) error {
+	var aux typedJSON
+	if err := json.Unmarshal(data, &aux); nil != err {
+		return err
+	}
+
+	tpName := posAuxTypeName(byte(aux.ID))
+	if "" == tpName || "unknown" == tpName {
+		tpName = "[]uint8"
+	}
+
+	var value interface{}
+	var err error
+	if tp := lookupPosAuxType(byte(aux.ID)); nil != tp && nil != tp.New {
+		value = tp.New()
+		err = json.Unmarshal(aux.Value, value)
+	} else if AuxIDVehicleStatus == byte(aux.ID) {
+		var signal Signal
+		err = json.Unmarshal(aux.Value, &signal)
+		value = uint32(signal)
+	} else {
+		value, err = unmarshalTypedValue(aux.Value, tpName)
+	}
+	if nil != err {
+		return err
+	}
+
+	p.ID, p.Len, p.Value = byte(aux.ID), aux.Len, value
+	return nil
+}
+
+// 车辆控制参数类型对照表
+var vehCtrlParamTypeMap = map[uint16]string{
+	uint16(0x0001): "uint8", // 车门，0-车门锁闭，1-车门开启
+}
+
+// UnmarshalJSON 车辆控制参数反序列化，参数值类型取自车辆控制参数类型对照表
+func (v *VehCtrlParam) UnmarshalJSON(data []byte) error {
+	var param typedJSON
+	if err := json.Unmarshal(data, &param); nil != err {
+		return err
+	}
+
+	tpName, ok := vehCtrlParamTypeMap[uint16(param.ID)]
+	if !ok {
+		return fmt.Errorf("车辆控制参数[%#x]类型未知", param.ID)
+	}
+
+	value, err := unmarshalTypedValue(param.Value, tpName)
+	if nil != err {
+		return err
+	}
+
+	v.ID, v.Value = uint16(param.ID), value
+	return nil
+}
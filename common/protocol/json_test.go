@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPositionReportJSON(t *testing.T) {
+	_, input, err := DecodeFrame(positionFrame)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	bts, err := json.Marshal(input)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bts, &fields); nil != err {
+		t.Fatal(err)
+	}
+	if fields["id"] != float64(MsgIDPositionReport) || fields["number"] != float64(1) {
+		t.Fatalf("unexpected mark: %s", bts)
+	}
+	if fields["latitude"] != 30.996165 || fields["longitude"] != 120.169441 {
+		t.Fatalf("unexpected lat/lon: %s", bts)
+	}
+	if fields["time"] != "2021-10-19T10:20:30+08:00" {
+		t.Fatalf("unexpected time: %s", bts)
+	}
+	status := fields["status"].(map[string]interface{})
+	if status["fixed"] != true || status["acc_open"] != true || status["bd_opened"] != true || status["cargo_status"] != float64(0) {
+		t.Fatalf("unexpected status: %s", bts)
+	}
+
+	// 反序列化后与原消息一致
+	var msg MsgPositionReport
+	if err := json.Unmarshal(bts, &msg); nil != err {
+		t.Fatal(err)
+	}
+	origin := input.(*MsgPositionReport)
+	if msg.ID != origin.ID || msg.Status != origin.Status || msg.Latitude != origin.Latitude ||
+		msg.Longitude != origin.Longitude || !msg.Time.Equal(origin.Time) || len(msg.Auxs) != len(origin.Auxs) {
+		t.Fatalf("got %+v, want %+v", msg, *origin)
+	}
+	if aux, _ := msg.Aux(0x01); aux.Value != uint32(0x007ea1b2) {
+		t.Fatalf("unexpected mileage: %+v", aux)
+	}
+}
+
+func TestPositionSouthWestJSON(t *testing.T) {
+	var pos Position
+	if err := json.Unmarshal([]byte(`{"latitude":-33.8688,"longitude":-70.5,"status":{"fixed":true}}`), &pos); nil != err {
+		t.Fatal(err)
+	}
+	if !pos.Status.IsSourth() || !pos.Status.IsWest() || !pos.Status.IsFixed() || pos.Latitude != 33868800 || pos.Longitude != 70500000 {
+		t.Fatalf("unexpected position: %+v", pos)
+	}
+}
+
+func TestFlagsJSON(t *testing.T) {
+	signal := Signal(0x0011)
+	bts, _ := json.Marshal(signal)
+	if !strings.Contains(string(bts), `"low_beam":true`) || !strings.Contains(string(bts), `"brake":true`) ||
+		!strings.Contains(string(bts), `"high_beam":false`) {
+		t.Fatalf("unexpected signal: %s", bts)
+	}
+
+	var alarm AlarmFlag
+	if err := json.Unmarshal([]byte(`{"emergency":true,"rollover":true}`), &alarm); nil != err || !alarm.IsEmergency() || !alarm.IsRollover() {
+		t.Fatalf("unexpected alarm: %#x, %v", alarm, err)
+	}
+	if err := json.Unmarshal([]byte(`5`), &alarm); nil != err || AlarmFlag(5) != alarm {
+		t.Fatalf("unexpected alarm: %#x, %v", alarm, err)
+	}
+	if err := json.Unmarshal([]byte(`{"unknown":true}`), &alarm); nil == err {
+		t.Fatal("unknown flag must be rejected")
+	}
+}
+
+func TestUnmarshalOutput(t *testing.T) {
+	output, err := UnmarshalOutput(0x8103, []byte(`{"params":[{"id":1,"value":30},{"id":19,"value":"127.0.0.1"}]}`))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	frame, err := EncodeFrame(output, Header{Phone: "012345678901"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	// 参数总数2，0x0001长度4值30，0x0013长度9值127.0.0.1
+	body := "02" + "00000001" + "04" + "0000001e" + "00000013" + "09" + hex.EncodeToString([]byte("127.0.0.1"))
+	if !strings.Contains(hex.EncodeToString(frame), body) {
+		t.Fatalf("unexpected frame: %x", frame)
+	}
+
+	output, err = UnmarshalOutput(0x8300, []byte(`{"flag":{"show":true,"tts":true},"text":"hello"}`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if msg := output.(*MsgTextIssued); !msg.Flag.IsShow() || !msg.Flag.IsTTS() || "hello" != msg.Text {
+		t.Fatalf("unexpected output: %+v", msg)
+	}
+
+	if _, err := UnmarshalOutput(0x8300, []byte(`{"id":33027}`)); nil == err {
+		t.Fatal("mismatched id must be rejected")
+	}
+	if _, err := UnmarshalOutput(0x0200, []byte(`{}`)); nil == err {
+		t.Fatal("uplink message must be rejected")
+	}
+}
+
+func TestTerminalAttrJSON(t *testing.T) {
+	attr := TerminalAttr{GnssAttr: GNSSAttr(0x03), CommAttr: COMMAttr(0x21)}
+	bts, _ := json.Marshal(&attr)
+	if !strings.Contains(string(bts), `"gnss_attr":{"gps":true,"bd":true,"glonass":false,"galileo":false}`) ||
+		!strings.Contains(string(bts), `"td_lte":true`) {
+		t.Fatalf("unexpected attr: %s", bts)
+	}
+	if names := attr.CommAttr.Capabilities(); 2 != len(names) || "gprs" != names[0] || "td_lte" != names[1] {
+		t.Fatalf("unexpected capabilities: %v", names)
+	}
+	if !attr.GnssAttr.IsBDS() || attr.GnssAttr.IsGalileo() {
+		t.Fatalf("unexpected gnss attr: %#x", attr.GnssAttr)
+	}
+
+	var decoded TerminalAttr
+	if err := json.Unmarshal(bts, &decoded); nil != err || attr != decoded {
+		t.Fatalf("unexpected decoded attr: %+v, %v", decoded, err)
+	}
+	if err := json.Unmarshal([]byte(`{"gnss_attr":9}`), &decoded); nil != err || !decoded.GnssAttr.IsGalileo() {
+		t.Fatalf("unexpected decoded attr: %+v, %v", decoded, err)
+	}
+}
@@ -3,6 +3,8 @@ package protocol
 import (
 	"bytes"
 	"fmt"
+	"sync"
+
 	"github.com/go-netty/go-netty"
 	"github.com/go-netty/go-netty/codec"
 	"github.com/go-netty/go-netty/utils"
@@ -16,18 +18,38 @@ type (
 	InactiveEvent struct {
 		netty.Exception
 	}
+
+	// 消息已编码事件，平台消息分配流水号后触发，在发送消息的协程中同步执行
+	SentEvent struct {
+		// 平台消息
+		Output Output
+		// 消息ID
+		ID uint16
+		// 消息流水号，分包发送时为首包流水号
+		Number uint16
+		// 分包总数，各子包占用连续的流水号，不分包时为1
+		Total uint16
+	}
+
+	// 终端身份事件，收到终端第一条消息时触发
+	IdentifiedEvent struct {
+		// 终端手机号
+		Phone string
+		// 协议版本号
+		Version byte
+	}
 )
 
 // Counter 计数器，用来消息计数
 type Counter func() uint16
 
-// presenter 消息接收
-type presenter interface {
+// Receiver 消息接收者，作为通道的附件接收解码后的消息和通道事件
+type Receiver interface {
 	// 接收到消息
-	onReceive(Input)
+	OnReceive(Input)
 
 	// 事件通知
-	onEvent(netty.Event)
+	OnEvent(netty.Event)
 }
 
 // MessageCodec create packet codec
@@ -41,9 +63,11 @@ func MessageCodec(version byte, phone []byte, counter Counter) codec.Codec {
 }
 
 type messageCodec struct {
-	version byte    // 协议版本号
-	phone   []byte  // 电话号码BCD码
-	count   Counter // 计数器，计数消息序号
+	version byte         // 协议版本号
+	phone   []byte       // 电话号码BCD码
+	count   Counter      // 计数器，计数消息序号
+	countMu sync.Mutex   // 写操作可能并发执行，保证分包消息占用连续的流水号
+	readBuf bytes.Buffer // 消息体读缓存，避免每条消息分配
 }
 
 // CodecName 编码器名称
@@ -57,12 +81,11 @@ func (m *messageCodec) HandleRead(ctx netty.InboundContext, message netty.Messag
 	// 接收到终端第一条协议消息
 	if nil == m.phone {
 		m.version = packet.head.version
-		if version2011 == m.version {
-			m.phone = make([]byte, 10)
-			copy(m.phone[4:], packet.head.phone)
-		} else {
-			m.phone = packet.head.phone
-		}
+		m.phone = make([]byte, 10)
+		copy(m.phone, packet.head.phone[:])
+
+		header := packet.head.header()
+		ctx.Channel().Attachment().(Receiver).OnEvent(IdentifiedEvent{Phone: header.Phone, Version: header.Version})
 	}
 
 	// 解析协议消息
@@ -72,14 +95,15 @@ func (m *messageCodec) HandleRead(ctx netty.InboundContext, message netty.Messag
 		utils.Assert(fmt.Errorf("协议[%#x]解码器不存在！", packet.head.id))
 	}
 
-	buf := bytes.NewBuffer(packet.body)
-	input, err := unmarshaler(buf, packet.head.version)
+	m.readBuf.Reset()
+	m.readBuf.Write(packet.body)
+	input, err := unmarshaler(&m.readBuf, packet.head.version)
 	utils.Assert(err)
 
 	// 将消息id和流水号带上
 	input.setIDAndNumber(packet.head.id, packet.head.number)
 
-	ctx.Channel().Attachment().(presenter).onReceive(input)
+	ctx.Channel().Attachment().(Receiver).OnReceive(input)
 }
 
 func (m *messageCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
@@ -96,37 +120,51 @@ func (m *messageCodec) HandleWrite(ctx netty.OutboundContext, message netty.Mess
 	body, err := marshal(output, m.version)
 	utils.Assert(err)
 
+	total := packCount(len(body))
 	packet := &packet{
 		head: head{
 			id:      reqID,
-			number:  m.count(),
-			phone:   m.phone,
+			number:  m.number(total),
 			version: m.version,
 		},
 		body: body,
 	}
+	copy(packet.head.phone[:], m.phone)
 
 	// 指定版本号
 	if packet.head.version != 0 {
 		packet.head.attr.versionTag()
 	}
 
+	ctx.Channel().Attachment().(Receiver).OnEvent(SentEvent{Output: output, ID: reqID, Number: packet.head.number, Total: uint16(total)})
 	ctx.HandleWrite(packet)
 }
 
+// number 为包数为total的消息分配流水号，各子包占用连续的流水号，返回首包流水号
+func (m *messageCodec) number(total int) uint16 {
+	m.countMu.Lock()
+	defer m.countMu.Unlock()
+
+	number := m.count()
+	for idx := 1; idx < total; idx++ {
+		m.count()
+	}
+	return number
+}
+
 func (m *messageCodec) HandleEvent(ctx netty.EventContext, event netty.Event) {
-	ctx.Attachment().(presenter).onEvent(event)
+	ctx.Attachment().(Receiver).OnEvent(event)
 }
 
 func (m *messageCodec) HandleActive(ctx netty.ActiveContext) {
-	ctx.Attachment().(presenter).onEvent(ActiveEvent{})
+	ctx.Attachment().(Receiver).OnEvent(ActiveEvent{})
 }
 
 func (m *messageCodec) HandleInActive(ctx netty.InactiveContext, ex netty.Exception) {
-	ctx.Attachment().(presenter).onEvent(InactiveEvent{Exception: ex})
+	ctx.Attachment().(Receiver).OnEvent(InactiveEvent{Exception: ex})
 	ctx.HandleInactive(ex)
 }
 
 func (m *messageCodec) HandleException(ctx netty.ExceptionContext, ex netty.Exception) {
-	ctx.Attachment().(presenter).onEvent(ex)
+	ctx.Attachment().(Receiver).OnEvent(ex)
 }
@@ -1,11 +1,11 @@
-package jtt
+package protocol
 
 import (
-	"JTTServer/util"
 	"bytes"
+	"common/protocol/util"
 	"encoding/binary"
+	"fmt"
 	"log"
-	"reflect"
 	"time"
 
 	"github.com/axgle/mahonia"
@@ -17,11 +17,11 @@ import (
 type MsgTerminalResponse struct {
 	ResponseMark
 	// 应答流水号
-	Number uint16
+	Number uint16 `json:"number"`
 	// 应答消息ID
-	MsgID uint16
+	MsgID uint16 `json:"msg_id"`
 	// 结果
-	Result byte
+	Result byte `json:"result"`
 }
 
 // 写入缓存中
@@ -32,6 +32,8 @@ func (m *MsgTerminalResponse) readBy(buf *bytes.Buffer) {
 	m.MsgID = binary.BigEndian.Uint16(buf.Next(2))
 	// 结果
 	m.Result, _ = buf.ReadByte()
+
+	m.ReqNum, m.ReqID = m.Number, m.MsgID
 }
 
 // MsgTerminalHeartbeat 终端心跳
@@ -56,9 +58,9 @@ func (m *MsgGetServerTime) readBy(buf *bytes.Buffer) {
 type MsgTerGetSubpacket struct {
 	InputMark
 	// 原始流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 重传包ID列表
-	IDs []uint16
+	IDs []uint16 `json:"ids"`
 }
 
 func (m *MsgTerGetSubpacket) readBy(buf *bytes.Buffer) {
@@ -104,19 +106,19 @@ func (m *MsgTerminalLogin) base() Input {
 type MsgTerminalLogin2011 struct {
 	InputMark
 	// 省域id
-	Province uint16
+	Province uint16 `json:"province"`
 	// 市域id
-	City uint16
+	City uint16 `json:"city"`
 	// 制造商id
-	Vendor string
+	Vendor string `json:"vendor"`
 	// 终端型号
-	Model string
+	Model string `json:"model"`
 	// 终端id
-	TerID string
+	TerID string `json:"ter_id"`
 	// 车牌颜色
-	Color byte
+	Color byte `json:"color"`
 	// 车牌
-	LicencePlate string
+	LicencePlate string `json:"licence_plate"`
 }
 
 // 写入缓存中
@@ -152,9 +154,9 @@ func (m *MsgTerminalLogout) readBy(buf *bytes.Buffer) {
 type MsgTerminalAuth struct {
 	MsgTerminalAuth2011
 	// 终端IMEI
-	IMEI string
+	IMEI string `json:"imei"`
 	// 软件版本号
-	Version string
+	Version string `json:"version"`
 }
 
 func (m *MsgTerminalAuth) readBy(buf *bytes.Buffer) {
@@ -175,7 +177,7 @@ func (m *MsgTerminalAuth) base() Input {
 type MsgTerminalAuth2011 struct {
 	InputMark
 	// 鉴权码
-	Token string
+	Token string `json:"token"`
 }
 
 func (m *MsgTerminalAuth2011) readBy(buf *bytes.Buffer) {
@@ -186,9 +188,9 @@ func (m *MsgTerminalAuth2011) readBy(buf *bytes.Buffer) {
 type MsgGetTerminalParamsResp struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 参数项列表
-	Params []Param
+	Params Params `json:"params"`
 }
 
 // 写入缓存中
@@ -197,46 +199,13 @@ func (m *MsgGetTerminalParamsResp) readBy(buf *bytes.Buffer) {
 	m.ReqNum = binary.BigEndian.Uint16(buf.Next(2))
 	// 应答参数个数
 	count, _ := buf.ReadByte()
-	// 参数项列表
+	// 参数项列表，未定义的参数保留原始字节
 	var param Param
-	for i := byte(0); i < count; i++ {
+	for i := byte(0); i < count && buf.Len() >= 5; i++ {
 		param.ID = binary.BigEndian.Uint32(buf.Next(4))
 		param.Len, _ = buf.ReadByte()
-		param.Value = readParamValue(buf, param.Len, paramTypeMap[param.ID])
-		if nil != param.Value {
-			m.Params = append(m.Params, param)
-		}
-	}
-}
-
-// readParamValue 读取参数值
-func readParamValue(buf *bytes.Buffer, len byte, tpName string) interface{} {
-	switch tpName {
-	case "uint8":
-		bt, _ := buf.ReadByte()
-		return bt
-	case "byte":
-		bt, _ := buf.ReadByte()
-		return bt
-	case "uint32":
-		return binary.BigEndian.Uint32(buf.Next(4))
-	case "uint16":
-		return binary.BigEndian.Uint16(buf.Next(2))
-	case "string":
-		decoder := mahonia.NewDecoder("gbk")
-		_, bts, _ := decoder.Translate(buf.Next(int(len)), true)
-		return string(bts)
-	case "[]uint8":
-		//fallthrough
-		bts := make([]byte, len)
-		buf.Read(bts)
-		return bts
-	case "[]byte":
-		bts := make([]byte, len)
-		buf.Read(bts)
-		return bts
-	default:
-		return nil
+		param.Value = decodeParamValue(paramTypeName(param.ID), buf.Next(int(param.Len)))
+		m.Params = append(m.Params, param)
 	}
 }
 
@@ -267,9 +236,9 @@ func (m *MsgGetTerminalAttrResp2011) readBy(buf *bytes.Buffer) {
 type MsgTerminalUpgradeResp struct {
 	InputMark
 	// 升级类型
-	Type byte
+	Type byte `json:"type"`
 	// 升级结果
-	Result byte
+	Result byte `json:"result"`
 }
 
 // 写入缓存中
@@ -294,27 +263,35 @@ func (m *MsgPositionReport) readBy(buf *bytes.Buffer) {
 type MsgPosBatchReport struct {
 	InputMark
 	// 位置数据类型，0-正常批量汇报，1-盲区补报
-	Type byte
+	Type byte `json:"type"`
 	// 位置信息项
-	Positions []Position
+	Positions []Position `json:"positions"`
 }
 
 func (m *MsgPosBatchReport) readBy(buf *bytes.Buffer) {
+	if buf.Len() < 3 {
+		return
+	}
 	// 定位数据项个数
-	count := binary.BigEndian.Uint16(buf.Next(2))
+	count := int(binary.BigEndian.Uint16(buf.Next(2)))
 	// 汇报类型，0-批量汇报，1-盲区补报
-	buf.WriteByte(m.Type)
 	m.Type, _ = buf.ReadByte()
+	// 个数不可信，每个数据项至少包含2字节长度和28字节位置基本信息，按剩余字节数限制
+	if max := buf.Len() / 30; count > max {
+		count = max
+	}
 	// 位置数据
-	var len uint16
-	var position Position
-	for i := uint16(0); i < count; i++ {
+	m.Positions = make([]Position, 0, count)
+	for i := 0; i < count && buf.Len() >= 2; i++ {
 		// 位置汇报数据体长度
-		len = binary.BigEndian.Uint16(buf.Next(2))
+		len := int(binary.BigEndian.Uint16(buf.Next(2)))
+		if len < 28 || len > buf.Len() {
+			break
+		}
 		// 位置汇报数据体
-		position.ReadBy(bytes.NewBuffer(buf.Next(int(len))))
-
-		m.Positions = append(m.Positions, position)
+		var pos Position
+		pos.ReadBy(bytes.NewBuffer(buf.Next(len)))
+		m.Positions = append(m.Positions, pos)
 	}
 }
 
@@ -322,9 +299,9 @@ func (m *MsgPosBatchReport) readBy(buf *bytes.Buffer) {
 type MsgPositionResp struct {
 	InputMark
 	// 应答消息流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 位置信息
-	Position Position
+	Position Position `json:"position"`
 }
 
 func (m *MsgPositionResp) readBy(buf *bytes.Buffer) {
@@ -350,9 +327,9 @@ func (m *MsgBDLocCheck) readBy(buf *bytes.Buffer) {
 type MsgVehicleControlResp struct {
 	InputMark
 	// 应答消息流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 位置信息
-	Position Position
+	Position Position `json:"position"`
 }
 
 func (m *MsgVehicleControlResp) readBy(buf *bytes.Buffer) {
@@ -368,26 +345,8 @@ type MsgGetAreaOrPathResp struct {
 func (m *MsgGetAreaOrPathResp) readBy(buf *bytes.Buffer) {
 	// 查询类型
 	m.Type, _ = buf.ReadByte()
-	// 查询数量
-	count := binary.BigEndian.Uint32(buf.Next(4))
 	// 查询数据列表
-	var area Area
-	decoder := mahonia.NewDecoder("gbk")
-	for i := uint32(0); i < count; i++ {
-		switch m.Type {
-		case ShapeTypeCircle:
-			area = &RectArea{}
-		case ShapeTypeRect:
-			area = &RectArea{}
-		case ShapeTypePolygon:
-			area = &PolygonArea{}
-		case ShapeTypeRoad:
-			area = &Polyline{}
-		}
-		area.readBy(buf, decoder, version2019)
-
-		m.Areas = append(m.Areas, area)
-	}
+	m.Areas = readAreas(buf, m.Type, version2019)
 }
 
 func (m *MsgGetAreaOrPathResp) base() Input {
@@ -398,33 +357,63 @@ func (m *MsgGetAreaOrPathResp) base() Input {
 type MsgGetAreaOrPathResp2011 struct {
 	InputMark
 	// 查询类型
-	Type ShapeType
+	Type ShapeType `json:"type"`
 	// 查询数据列表
-	Areas []Area
+	Areas []Area `json:"areas"`
 }
 
 func (m *MsgGetAreaOrPathResp2011) readBy(buf *bytes.Buffer) {
 	// 查询类型
 	m.Type, _ = buf.ReadByte()
-	// 查询数量
-	count := binary.BigEndian.Uint32(buf.Next(4))
 	// 查询数据列表
-	var area Area
+	m.Areas = readAreas(buf, m.Type, version2011)
+}
+
+// readAreas 读取查询区域或路线应答中的数量及数据列表。
+//
+// 数量来自终端，为DWORD，按消息体剩余长度及每项的最小长度限制，剩余长度不足一项时结束
+func readAreas(buf *bytes.Buffer, shape ShapeType, version byte) []Area {
+	size := areaMinSize(shape)
+	if 0 == size || buf.Len() < 4 {
+		return nil
+	}
+	count := binary.BigEndian.Uint32(buf.Next(4))
+	if max := uint32(buf.Len() / size); count > max {
+		count = max
+	}
+
+	areas := make([]Area, 0, count)
 	decoder := mahonia.NewDecoder("gbk")
-	for i := uint32(0); i < count; i++ {
-		switch m.Type {
+	for i := uint32(0); i < count && buf.Len() >= size; i++ {
+		var area Area
+		switch shape {
 		case ShapeTypeCircle:
-			area = &RectArea{}
+			area = &RoundArea{}
 		case ShapeTypeRect:
 			area = &RectArea{}
 		case ShapeTypePolygon:
 			area = &PolygonArea{}
-		case ShapeTypeRoad:
+		default:
 			area = &Polyline{}
 		}
-		area.readBy(buf, decoder, version2011)
+		area.readBy(buf, decoder, version)
 
-		m.Areas = append(m.Areas, area)
+		areas = append(areas, area)
+	}
+	return areas
+}
+
+// areaMinSize 区域或路线数据项的最小字节数：ID、属性及必有的坐标或顶点数，未知类型返回0
+func areaMinSize(shape ShapeType) int {
+	switch shape {
+	case ShapeTypeCircle:
+		return 18
+	case ShapeTypeRect:
+		return 22
+	case ShapeTypePolygon, ShapeTypeRoad:
+		return 8
+	default:
+		return 0
 	}
 }
 
@@ -432,11 +421,11 @@ func (m *MsgGetAreaOrPathResp2011) readBy(buf *bytes.Buffer) {
 type MsgDrivingRecordReport struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 命令字
-	CMD byte
+	CMD byte `json:"cmd"`
 	// 数据块
-	Data []byte
+	Data []byte `json:"data"`
 }
 
 func (m *MsgDrivingRecordReport) readBy(buf *bytes.Buffer) {
@@ -453,22 +442,21 @@ func (m *MsgDrivingRecordReport) readBy(buf *bytes.Buffer) {
 type MsgWaybillReport struct {
 	InputMark
 	// 电子运单数据包
-	Packet []byte
+	Packet []byte `json:"packet"`
 }
 
 func (m *MsgWaybillReport) readBy(buf *bytes.Buffer) {
-	// 电子运单长度
+	// 电子运单长度，不可信，最多读取剩余字节
 	len := binary.BigEndian.Uint32(buf.Next(4))
 	// 电子运单内容
-	m.Packet = make([]byte, len)
-	buf.Read(m.Packet)
+	m.Packet = append([]byte(nil), buf.Next(int(len))...)
 }
 
 // MsgICCardReport 驾驶员身份信息上报
 type MsgICCardReport struct {
 	MsgICCardReport2011
 	// 驾驶员身份证号
-	IDCard string
+	IDCard string `json:"id_card"`
 }
 
 // 写入缓存中
@@ -508,19 +496,19 @@ func (m *MsgICCardReport) base() Input {
 type MsgICCardReport2011 struct {
 	InputMark
 	// 操作，1-插卡，2-拔卡
-	Operation byte
+	Operation byte `json:"operation"`
 	// 时间
-	Time time.Time
+	Time time.Time `json:"time"`
 	// IC卡读取结果
-	Result byte
+	Result byte `json:"result"`
 	// 驾驶员姓名
-	Name string
+	Name string `json:"name"`
 	// 从业资格证编码
-	Credential string
+	Credential string `json:"credential"`
 	// 发证机构
-	Agency string
+	Agency string `json:"agency"`
 	// 证件有效期
-	Expire time.Time
+	Expire time.Time `json:"expire"`
 }
 
 // 写入缓存中
@@ -553,20 +541,20 @@ func (m *MsgICCardReport2011) readBy(buf *bytes.Buffer) {
 // MsgCANDataReport CAN总线数据上传
 type MsgCANDataReport struct {
 	InputMark
-	// CAN总线数据接收时间
-	Time time.Time
+	// 第1条CAN总线数据的接收时间，只有时分秒和毫秒，日期为零值
+	Time time.Time `json:"time"`
 	// CAN数据项列表
-	Datas []CanData
+	Datas []CanData `json:"datas"`
 }
 
 func (m *MsgCANDataReport) readBy(buf *bytes.Buffer) {
 	// 数据项个数
 	count := binary.BigEndian.Uint16(buf.Next(2))
-	// 数据接收时间
-	m.Time, _ = time.ParseInLocation("150405000", string(util.ParseBCD(buf.Next(5))), time.FixedZone("CST", 28800))
+	// 数据接收时间，hh-mm-ss-msms，毫秒为4位BCD码
+	m.Time = parseCANTime(buf.Next(5))
 	// CAN总线数据项
 	var data CanData
-	for i := uint16(0); i < count; i++ {
+	for i := uint16(0); i < count && buf.Len() >= 12; i++ {
 		data.ID = binary.BigEndian.Uint32(buf.Next(4))
 		buf.Read(data.Data[:])
 		m.Datas = append(m.Datas, data)
@@ -577,16 +565,16 @@ func (m *MsgCANDataReport) readBy(buf *bytes.Buffer) {
 type MsgMultimediaEventReport struct {
 	InputMark
 	// 多媒体数据ID
-	DataID uint32
+	DataID uint32 `json:"data_id"`
 	// 多媒体类型,0-图像，1-音频，2-视频
-	MimeType byte
+	MimeType byte `json:"mime_type"`
 	// 多媒体格式编码，0-JPEG，1-TIF，2-MP3，3-WAV，4-WMV
-	MediaFmt byte
+	MediaFmt byte `json:"media_fmt"`
 	// 事件项编码，0-平台下发指令，1-定时动作，2-抢劫报警触发，3-碰撞侧翻报警触发，
 	// 4-门开拍照，5-门关拍照，6-车门由开变关，车速从小于20km/h到超过20km/h，7-定距拍照
-	EventCode byte
+	EventCode byte `json:"event_code"`
 	// 通道ID
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 }
 
 func (m *MsgMultimediaEventReport) readBy(buf *bytes.Buffer) {
@@ -606,20 +594,20 @@ func (m *MsgMultimediaEventReport) readBy(buf *bytes.Buffer) {
 type MsgMultimediaDataReport struct {
 	InputMark
 	// 多媒体ID
-	DataID uint32
+	DataID uint32 `json:"data_id"`
 	// 多媒体类型,0-图像，1-音频，2-视频
-	MimeType byte
+	MimeType byte `json:"mime_type"`
 	// 多媒体格式编码，0-JPEG，1-TIF，2-MP3，3-WAV，4-WMV
-	MediaFmt byte
+	MediaFmt byte `json:"media_fmt"`
 	// 事件项编码，0-平台下发指令，1-定时动作，2-抢劫报警触发，3-碰撞侧翻报警触发，
 	// 4-打开车门，5-关闭车门
-	EventCode byte
+	EventCode byte `json:"event_code"`
 	// 通道ID
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 	// 位置信息
-	Pos Position
+	Pos Position `json:"pos"`
 	// 多媒体数据包
-	Media []byte
+	Media []byte `json:"media"`
 }
 
 func (m *MsgMultimediaDataReport) readBy(buf *bytes.Buffer) {
@@ -644,11 +632,11 @@ func (m *MsgMultimediaDataReport) readBy(buf *bytes.Buffer) {
 type MsgSnapshootResp struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 结果，0-成功，1-失败，2-通道不支持
-	Result byte
+	Result byte `json:"result"`
 	// 拍摄成功的多媒体个数
-	MediaIDs []uint32
+	MediaIDs []uint32 `json:"media_ids"`
 }
 
 func (m *MsgSnapshootResp) readBy(buf *bytes.Buffer) {
@@ -688,9 +676,9 @@ func (m *MsgSearchLocalMultimediaResp) readBy(buf *bytes.Buffer) {
 type MsgSearchLocalMultimediaResp2011 struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 多媒体项列表
-	Multimedias []Multimedia
+	Multimedias []Multimedia `json:"multimedias"`
 }
 
 func (m *MsgSearchLocalMultimediaResp) base() Input {
@@ -715,9 +703,9 @@ func (m *MsgSearchLocalMultimediaResp2011) readBy(buf *bytes.Buffer) {
 type MsgDataUplink struct {
 	InputMark
 	// 透传消息类型，0-GNSS详细数据，0x0B-IC卡信息，0x41-串口1数据，0x42-串口2数据，≥0xF0-自定义
-	DataType byte
+	DataType byte `json:"data_type"`
 	// 透传消息内容
-	Content []byte
+	Content []byte `json:"content"`
 }
 
 func (m *MsgDataUplink) readBy(buf *bytes.Buffer) {
@@ -730,24 +718,23 @@ func (m *MsgDataUplink) readBy(buf *bytes.Buffer) {
 type MsgDataCompress struct {
 	InputMark
 	// 压缩后的消息体
-	Body []byte
+	Body []byte `json:"body"`
 }
 
 func (m *MsgDataCompress) readBy(buf *bytes.Buffer) {
-	// 压缩消息长度
+	// 压缩消息长度，不可信，最多读取剩余字节
 	len := binary.BigEndian.Uint32(buf.Next(4))
 	// 压缩后的消息体
-	m.Body = make([]byte, len)
-	buf.Read(m.Body)
+	m.Body = append([]byte(nil), buf.Next(int(len))...)
 }
 
 // MsgTerRSAPublicKey 终端RSA公钥
 type MsgTerRSAPublicKey struct {
 	InputMark
 	// 密钥中的e
-	E uint32
+	E uint32 `json:"e"`
 	// 密钥中的n
-	N [128]byte
+	N [128]byte `json:"n"`
 }
 
 func (m *MsgTerRSAPublicKey) readBy(buf *bytes.Buffer) {
@@ -759,17 +746,17 @@ func (m *MsgTerRSAPublicKey) readBy(buf *bytes.Buffer) {
 type MsgDriverFaceReport struct {
 	InputMark
 	// 人脸采集时间
-	Time time.Time
+	Time time.Time `json:"time"`
 	// 驾驶员身份识别码
-	IDUID string
+	IDUID string `json:"iduid"`
 	// 位置基础信息
-	Pos Position
+	Pos Position `json:"pos"`
 	// 多媒体类型，0-图像，1-音频，2-视频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 多媒体格式编码，0-JPEG,1-TIF,2-MP3,3-WAV,4-WMV
-	MediaFmt byte
+	MediaFmt byte `json:"media_fmt"`
 	// 多媒体数据包
-	Media []byte
+	Media []byte `json:"media"`
 }
 
 func (m *MsgDriverFaceReport) readBy(buf *bytes.Buffer) {
@@ -793,9 +780,9 @@ func (m *MsgDriverFaceReport) readBy(buf *bytes.Buffer) {
 type MsgMediaResourceList struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 音视频资源列表
-	List []MediaResource
+	List []MediaResource `json:"list"`
 }
 
 func (m *MsgMediaResourceList) readBy(buf *bytes.Buffer) {
@@ -815,9 +802,9 @@ func (m *MsgMediaResourceList) readBy(buf *bytes.Buffer) {
 type MsgFileUploadFinish struct {
 	InputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 结果
-	Result byte
+	Result byte `json:"result"`
 }
 
 func (m *MsgFileUploadFinish) readBy(buf *bytes.Buffer) {
@@ -831,23 +818,23 @@ func (m *MsgFileUploadFinish) readBy(buf *bytes.Buffer) {
 type MsgMediaPropertyReply struct {
 	InputMark
 	// 音频编码方式
-	AudioEncodeMode byte
+	AudioEncodeMode byte `json:"audio_encode_mode"`
 	// 输入音频声道数
-	Channels byte
+	Channels byte `json:"channels"`
 	// 输入音频采样率
-	SamplingRate byte
+	SamplingRate byte `json:"sampling_rate"`
 	// 输入音频采样率位数
-	SamplingRateBits byte
+	SamplingRateBits byte `json:"sampling_rate_bits"`
 	//音频帧长度
-	AudioFrameLen uint16
+	AudioFrameLen uint16 `json:"audio_frame_len"`
 	//是否支持音频输出
-	SupportAudioOutput byte
+	SupportAudioOutput byte `json:"support_audio_output"`
 	// 视频编码方式
-	VideoEncodeMode byte
+	VideoEncodeMode byte `json:"video_encode_mode"`
 	//终端支持的最大音频物理通道数
-	MaxAudioPhysicalChannels byte
+	MaxAudioPhysicalChannels byte `json:"max_audio_physical_channels"`
 	//终端支持的最大视频物理通道数
-	MaxVideoPhysicalChannels byte
+	MaxVideoPhysicalChannels byte `json:"max_video_physical_channels"`
 }
 
 func (m *MsgMediaPropertyReply) readBy(buf *bytes.Buffer) {
@@ -868,11 +855,11 @@ func (m *MsgMediaPropertyReply) readBy(buf *bytes.Buffer) {
 type MsgServerResponse struct {
 	OutputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 应答消息ID
-	ReqID uint16
+	ReqID uint16 `json:"req_id"`
 	// 结果，0-成功/确认，1-失败，2-消息有误，3-不支持，4-报警处理确认
-	Result byte
+	Result byte `json:"result"`
 }
 
 func (m *MsgServerResponse) writeTo(buf *bytes.Buffer) {
@@ -904,7 +891,7 @@ func NewMsgServerResponse(reqNum, reqId uint16, result byte) *MsgServerResponse
 type MsgServerTimeResp struct {
 	OutputMark
 	// 服务器时间
-	Time time.Time
+	Time time.Time `json:"time"`
 }
 
 func (m *MsgServerTimeResp) writeTo(buf *bytes.Buffer) {
@@ -960,9 +947,9 @@ func NewMsgSerGetSubpacket() *MsgSerGetSubpacket {
 type MsgSerGetSubpacket2011 struct {
 	OutputMark
 	// 原始消息流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 重传包ID列表
-	IDs []uint16
+	IDs []uint16 `json:"ids"`
 }
 
 func (m *MsgSerGetSubpacket2011) writeTo(buf *bytes.Buffer) {
@@ -993,11 +980,11 @@ func (m *MsgSerGetSubpacket2011) writeTo(buf *bytes.Buffer) {
 type MsgTerminalLoginResp struct {
 	OutputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 应答结果
-	Result byte
+	Result byte `json:"result"`
 	// 鉴权码
-	Token string
+	Token string `json:"token"`
 }
 
 func (m *MsgTerminalLoginResp) writeTo(buf *bytes.Buffer) {
@@ -1018,7 +1005,7 @@ func (m *MsgTerminalLoginResp) writeTo(buf *bytes.Buffer) {
 func NewMsgTerminalLoginResp() *MsgTerminalLoginResp {
 	return &MsgTerminalLoginResp{
 		OutputMark: OutputMark{
-			ID: msgIDServerPackResend,
+			ID: msgIDTerminalLoginResp,
 		},
 	}
 }
@@ -1027,56 +1014,36 @@ func NewMsgTerminalLoginResp() *MsgTerminalLoginResp {
 type MsgTerParamsSettings struct {
 	OutputMark
 	// 参数项列表
-	Params []Param
+	Params Params `json:"params"`
 }
 
 func (m *MsgTerParamsSettings) writeTo(buf *bytes.Buffer) {
-	value := make([]byte, 4)
+	m.encode(buf)
+}
+
+// encode 写入参数总数及参数项列表，参数值按参数定义的编码类型编码，未定义的参数按值类型编码。
+// 任一参数值编码失败时返回错误且不写入
+func (m *MsgTerParamsSettings) encode(buf *bytes.Buffer) error {
+	values := make([][]byte, len(m.Params))
+	for idx, param := range m.Params {
+		data, err := encodeParamValue(paramTypeName(param.ID), param.Value)
+		if nil != err {
+			return fmt.Errorf("param %#04x: %s", param.ID, err)
+		}
+		values[idx] = data
+	}
 
+	value := make([]byte, 4)
 	// 参数总数
 	buf.WriteByte(byte(len(m.Params)))
 	// 参数项列表
-	for _, param := range m.Params {
+	for idx, param := range m.Params {
 		binary.BigEndian.PutUint32(value, param.ID)
 		buf.Write(value)
-		writeParamValue(buf, param.Value)
-	}
-}
-
-// writeParamValue 写入参数值
-func writeParamValue(buf *bytes.Buffer, value interface{}) {
-	switch reflect.ValueOf(value).Kind() {
-	case reflect.Uint8:
-		buf.WriteByte(byte(1))
-		buf.WriteByte(value.(byte))
-	case reflect.Uint16:
-		buf.WriteByte(byte(2))
-		bts := make([]byte, 2)
-		binary.BigEndian.PutUint16(bts, value.(uint16))
-		buf.Write(bts)
-	case reflect.Uint32:
-		buf.WriteByte(byte(4))
-		bts := make([]byte, 4)
-		binary.BigEndian.PutUint32(bts, value.(uint32))
-		buf.Write(bts)
-	case reflect.Array:
-		log.Println("终端参数类型不可为数组")
-	case reflect.Slice:
-		if reflect.TypeOf(value).Elem().Kind() != reflect.Uint8 {
-			log.Println("未知切片类型的终端参数")
-			break
-		}
-		bts := value.([]byte)
-		buf.WriteByte(byte(len(bts)))
-		buf.Write(bts)
-	case reflect.String:
-		encoder := mahonia.NewEncoder("gbk")
-		bts := []byte(encoder.ConvertString(value.(string)))
-		buf.WriteByte(byte(len(bts)))
-		buf.Write(bts)
-	default:
-		log.Println("类型未知的终端参数", reflect.ValueOf(value).Kind())
+		buf.WriteByte(byte(len(values[idx])))
+		buf.Write(values[idx])
 	}
+	return nil
 }
 
 // NewMsgTerParamsSettings 新建终端参数设置消息
@@ -1111,7 +1078,7 @@ func NewMsgGetTerminalParams() *MsgGetTerminalParams {
 type MsgGetTerSpecParams struct {
 	OutputMark
 	// 参数id列表
-	ParamIDs []uint32
+	ParamIDs []uint32 `json:"param_ids"`
 }
 
 // 从缓存中读
@@ -1139,17 +1106,17 @@ func NewMsgGetTerSpecParams() *MsgGetTerSpecParams {
 // MsgTerminalControl 终端控制
 type MsgTerminalControl struct {
 	OutputMark
-	// 命令字
-	Cmd byte
-	// 命令参数
-	Value string
+	// 命令字，见TerminalCtrlXXX
+	Cmd byte `json:"cmd"`
+	// 命令参数，仅无线升级与连接指定服务器有参数，各参数以半角分号分隔
+	Value string `json:"value"`
 }
 
 func (m *MsgTerminalControl) writeTo(buf *bytes.Buffer) {
 	// 命令字
 	buf.WriteByte(m.Cmd)
 	// 命令参数
-	if byte(2) == m.Cmd {
+	if TerminalCtrlUpgrade == m.Cmd || TerminalCtrlConnectServer == m.Cmd {
 		encoder := mahonia.NewEncoder("gbk")
 		buf.Write([]byte(encoder.ConvertString(m.Value)))
 	}
@@ -1182,51 +1149,80 @@ func NewMsgGetTerminalAttr() *MsgGetTerminalAttr {
 	}
 }
 
+// 升级类型
+const (
+	UpgradeTypeTerminal     = byte(0)  // 终端
+	UpgradeTypeICCardReader = byte(12) // 道路运输证IC卡读卡器
+	UpgradeTypeGNSS         = byte(52) // 北斗卫星定位模块
+)
+
+// 升级结果
+const (
+	UpgradeResultSuccess   = byte(0) // 成功
+	UpgradeResultFailed    = byte(1) // 失败
+	UpgradeResultCancelled = byte(2) // 取消
+)
+
 // MsgTerminalUpgrade 终端升级
 type MsgTerminalUpgrade struct {
+	MsgTerminalUpgrade2011
+}
+
+func (m *MsgTerminalUpgrade) writeTo(buf *bytes.Buffer) {
+	m.write(buf, 11)
+}
+
+func (m *MsgTerminalUpgrade) base() Output {
+	return &m.MsgTerminalUpgrade2011
+}
+
+// NewMsgTerminalUpgrade 新建终端升级消息
+func NewMsgTerminalUpgrade() *MsgTerminalUpgrade {
+	return &MsgTerminalUpgrade{
+		MsgTerminalUpgrade2011: MsgTerminalUpgrade2011{
+			OutputMark: OutputMark{
+				ID: msgIDTerminalUpgrade,
+			},
+		},
+	}
+}
+
+// MsgTerminalUpgrade2011 终端升级，制造商ID为5字节
+type MsgTerminalUpgrade2011 struct {
 	OutputMark
 	// 升级类型
-	Type byte
+	Type byte `json:"type"`
 	// 制造商ID
-	VendorID string
+	VendorID string `json:"vendor_id"`
 	// 终端固件版本号
-	Version string
+	Version string `json:"version"`
 	// 升级数据包
-	Pkg []byte
+	Pkg []byte `json:"pkg"`
 }
 
-func (m *MsgTerminalUpgrade) writeTo(buf *bytes.Buffer) {
+func (m *MsgTerminalUpgrade2011) writeTo(buf *bytes.Buffer) {
+	m.write(buf, 5)
+}
+
+// write 写入消息体，vendorSize为制造商ID的字节数
+func (m *MsgTerminalUpgrade2011) write(buf *bytes.Buffer, vendorSize int) {
 	// 升级类型
 	buf.WriteByte(m.Type)
 	// 制造商ID
-	bts := []byte(m.VendorID)
-	if l := len(bts); l < 11 {
-		buf.Write(bts)
-		buf.Write(make([]byte, 11-l))
-	} else {
-		buf.Write(bts[:11])
-	}
+	writeFixedString(buf, m.VendorID, vendorSize)
 	// 终端固件版本号长度
-	bts = []byte(m.Version)
+	bts := []byte(m.Version)
 	buf.WriteByte(byte(len(bts)))
 	// 终端固件版本号
 	buf.Write(bts)
 	// 升级数据包长度
 	value := make([]byte, 4)
 	binary.BigEndian.PutUint32(value, uint32(len(m.Pkg)))
+	buf.Write(value)
 	// 升级数据包
 	buf.Write(m.Pkg)
 }
 
-// NewMsgTerminalUpgrade 新建终端升级消息
-func NewMsgTerminalUpgrade() *MsgTerminalUpgrade {
-	return &MsgTerminalUpgrade{
-		OutputMark: OutputMark{
-			ID: msgIDTerminalUpgrade,
-		},
-	}
-}
-
 // MsgGetPosition 位置信息查询
 type MsgGetPosition struct {
 	OutputMark
@@ -1249,9 +1245,9 @@ func NewMsgGetPosition() *MsgGetPosition {
 type MsgTrackControl struct {
 	OutputMark
 	// 时间间隔
-	Duration uint16
+	Duration uint16 `json:"duration"`
 	// 位置跟踪有效期
-	Expire uint32
+	Expire uint32 `json:"expire"`
 }
 
 func (m *MsgTrackControl) writeTo(buf *bytes.Buffer) {
@@ -1280,9 +1276,9 @@ func NewMsgTrackControl() *MsgTrackControl {
 type MsgManualConfirmAlarm struct {
 	OutputMark
 	// 报警消息流水号
-	AlarmNum uint16
+	AlarmNum uint16 `json:"alarm_num"`
 	// 人工确认报警类型
-	AlarmType AlarmConfirm
+	AlarmType AlarmConfirm `json:"alarm_type"`
 }
 
 func (m *MsgManualConfirmAlarm) writeTo(buf *bytes.Buffer) {
@@ -1326,6 +1322,8 @@ func NewMsgLinkCheck() *MsgLinkCheck {
 // MsgTextIssued 文本信息下发
 type MsgTextIssued struct {
 	MsgTextIssued2011
+	// 2011版下发时的标志，由NewMsgText按选项生成，为空时与Flag相同
+	flag2011 *TextFlag
 }
 
 // 从缓存中读
@@ -1340,7 +1338,12 @@ func (m *MsgTextIssued) writeTo(buf *bytes.Buffer) {
 }
 
 func (m *MsgTextIssued) base() Output {
-	return &m.MsgTextIssued2011
+	if nil == m.flag2011 {
+		return &m.MsgTextIssued2011
+	}
+	base := m.MsgTextIssued2011
+	base.Flag = *m.flag2011
+	return &base
 }
 
 // NewMsgTextIssued 新建文本信息下发消息
@@ -1358,11 +1361,11 @@ func NewMsgTextIssued() *MsgTextIssued {
 type MsgTextIssued2011 struct {
 	OutputMark
 	// 标志
-	Flag TextFlag
-	// 类型，1-通知，2-服务
-	Type byte
+	Flag TextFlag `json:"flag"`
+	// 类型，见TextTypeXXX
+	Type byte `json:"type"`
 	// 文本
-	Text string
+	Text string `json:"text"`
 }
 
 // 从缓存中读
@@ -1387,9 +1390,9 @@ func (m *MsgTextIssued2011) writeTo(buf *bytes.Buffer) {
 type MsgTELCallback struct {
 	OutputMark
 	// 标志，0-普通通话，1-监听
-	Flag byte
+	Flag byte `json:"flag"`
 	// 电话号码
-	Phone string
+	Phone string `json:"phone"`
 }
 
 // 从缓存中读
@@ -1413,9 +1416,9 @@ func NewMsgTELCallback() *MsgTELCallback {
 type MsgContactsSettings struct {
 	OutputMark
 	// 操作类型，0-删除所有，1-删除所有并追加，2-追加，3-修改
-	Operation byte
+	Operation byte `json:"operation"`
 	// 联系人列表
-	Contacts []Contact
+	Contacts []Contact `json:"contacts"`
 }
 
 // 从缓存中读
@@ -1436,10 +1439,11 @@ func (m *MsgContactsSettings) writeTo(buf *bytes.Buffer) {
 			buf.WriteByte(byte(len(contact.Phone)))
 			// 电话号码
 			buf.Write([]byte(contact.Phone))
-			// 联系人长度
-			buf.WriteByte(byte(len(contact.Name)))
+			// 联系人长度，为GBK编码后的字节数
+			name := encoder.ConvertString(contact.Name)
+			buf.WriteByte(byte(len(name)))
 			// 联系人
-			buf.Write([]byte(encoder.ConvertString(contact.Name)))
+			buf.Write([]byte(name))
 		}
 	}
 }
@@ -1464,11 +1468,12 @@ func (m *MsgVehicleControl) writeTo(buf *bytes.Buffer) {
 	binary.BigEndian.PutUint16(value, uint16(len(m.Params)))
 	buf.Write(value)
 	// 控制参数项列表
-	for _, param := range m.Params {
+	for idx := range m.Params {
 		// 控制类型ID
-		binary.BigEndian.PutUint16(value, param.ID)
-		// 控制值
-		writeVehCtrlParamValue(buf, param.Value)
+		binary.BigEndian.PutUint16(value, m.Params[idx].ID)
+		buf.Write(value)
+		// 控制参数，按控制类型定义编码
+		writeVehCtrlParamValue(buf, vehCtrlValue(&m.Params[idx]))
 	}
 }
 
@@ -1490,14 +1495,19 @@ func NewMsgVehicleControl() *MsgVehicleControl {
 // MsgVehicleControl2011 车辆控制
 type MsgVehicleControl2011 struct {
 	OutputMark
-	// 控制类型参数
-	Params []VehCtrlParam
+	// 控制类型参数，见VehCtrlDefs
+	Params []VehCtrlParam `json:"params"`
 }
 
+// 2011版只支持车门控制，控制标志bit0：0-车门解锁，1-车门加锁
 func (m *MsgVehicleControl2011) writeTo(buf *bytes.Buffer) {
-	for _, param := range m.Params {
-		if param.ID == 1 {
-			buf.WriteByte(param.Value.(byte))
+	for idx := range m.Params {
+		if VehCtrlIDDoor == m.Params[idx].ID {
+			if value, err := paramUint(vehCtrlValue(&m.Params[idx]), 8); nil == err && uint64(VehCtrlDoorLock) == value {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
 			break
 		}
 	}
@@ -1537,6 +1547,13 @@ func NewMsgVehicleControl2011() *MsgVehicleControl2011 {
 	}
 }
 
+// 区域设置属性
+const (
+	AreaOpUpdate = byte(0) // 更新区域，删除终端已有的全部同类区域
+	AreaOpAppend = byte(1) // 追加区域
+	AreaOpModify = byte(2) // 修改区域
+)
+
 // MsgRoundAreaSettings 设置圆形区域消息
 type MsgRoundAreaSettings struct {
 	MsgRoundAreaSettings2011
@@ -1574,10 +1591,10 @@ func NewMsgRoundAreaSettings() *MsgRoundAreaSettings {
 // MsgRoundAreaSettings2011 设置圆形区域消息
 type MsgRoundAreaSettings2011 struct {
 	OutputMark
-	// 操作
-	Operation byte
+	// 操作，见AreaOpXXX
+	Operation byte `json:"operation"`
 	// 区域列表
-	Areas []RoundArea
+	Areas []RoundArea `json:"areas"`
 }
 
 // 从缓存中读
@@ -1607,7 +1624,7 @@ func (m *MsgRoundAreaSettings2011) writeTo(buf *bytes.Buffer) {
 type MsgRoundAreaDelete struct {
 	OutputMark
 	// 区域ID列表
-	IDs []uint32
+	IDs []uint32 `json:"ids"`
 }
 
 func (m *MsgRoundAreaDelete) writeTo(buf *bytes.Buffer) {
@@ -1674,9 +1691,9 @@ func NewMsgRectAreaSettings() *MsgRectAreaSettings {
 type MsgRectAreaSettings2011 struct {
 	OutputMark
 	// 操作
-	Operation byte
+	Operation byte `json:"operation"`
 	// 区域列表
-	Areas []RectArea
+	Areas []RectArea `json:"areas"`
 }
 
 // 从缓存中读
@@ -1706,7 +1723,7 @@ func (m *MsgRectAreaSettings2011) writeTo(buf *bytes.Buffer) {
 type MsgRectAreaDelete struct {
 	OutputMark
 	// 区域ID列表
-	IDs []uint32
+	IDs []uint32 `json:"ids"`
 }
 
 func (m *MsgRectAreaDelete) writeTo(buf *bytes.Buffer) {
@@ -1765,7 +1782,7 @@ func NewMsgPolygonAreaSettings() *MsgPolygonAreaSettings {
 type MsgPolygonAreaSettings2011 struct {
 	OutputMark
 	// 区域
-	Area PolygonArea
+	Area PolygonArea `json:"area"`
 }
 
 // 从缓存中读
@@ -1787,7 +1804,7 @@ func (m *MsgPolygonAreaSettings2011) writeTo(buf *bytes.Buffer) {
 type MsgPolygonAreaDelete struct {
 	OutputMark
 	// 区域ID列表
-	IDs []uint32
+	IDs []uint32 `json:"ids"`
 }
 
 func (m *MsgPolygonAreaDelete) writeTo(buf *bytes.Buffer) {
@@ -1845,13 +1862,13 @@ func NewMsgPathSettings() *MsgPathSettings {
 // MsgPathSettings2011 设置路线消息
 type MsgPathSettings2011 struct {
 	OutputMark
-	Path Polyline
+	Path Polyline `json:"path"`
 }
 
 // 从缓存中读
 func (m *MsgPathSettings2011) writeTo(buf *bytes.Buffer) {
 	encoder := mahonia.NewEncoder("gbk")
-	m.Path.writeTo(buf, encoder, version2019)
+	m.Path.writeTo(buf, encoder, version2011)
 }
 
 // // NewMsgPathSettings2011 新建设置路线消息
@@ -1867,7 +1884,7 @@ func (m *MsgPathSettings2011) writeTo(buf *bytes.Buffer) {
 type MsgPathDelete struct {
 	OutputMark
 	// 路线id列表
-	IDs []uint32
+	IDs []uint32 `json:"ids"`
 }
 
 // 从缓存中读
@@ -1901,9 +1918,9 @@ func NewMsgPathDelete() *MsgPathDelete {
 type MsgGetAreaOrPath struct {
 	OutputMark
 	// 查询类型
-	Type ShapeType
+	Type ShapeType `json:"type"`
 	// 查询项id列表，为空时表示查所有
-	IDs []uint32
+	IDs []uint32 `json:"ids"`
 }
 
 func (m *MsgGetAreaOrPath) writeTo(buf *bytes.Buffer) {
@@ -1934,9 +1951,9 @@ func NewMsgGetAreaOrPath() *MsgGetAreaOrPath {
 type MsgGatherDrivingRecord struct {
 	OutputMark
 	// 命令字
-	CMD byte
+	CMD byte `json:"cmd"`
 	// 数据块
-	Data []byte
+	Data []byte `json:"data"`
 }
 
 func (m *MsgGatherDrivingRecord) writeTo(buf *bytes.Buffer) {
@@ -1959,9 +1976,9 @@ func NewMsgGatherDrivingRecord() *MsgGatherDrivingRecord {
 type MsgDrivingRecordParamsIssued struct {
 	OutputMark
 	// 命令字
-	CMD byte
+	CMD byte `json:"cmd"`
 	// 数据块
-	Data []byte
+	Data []byte `json:"data"`
 }
 
 func (m *MsgDrivingRecordParamsIssued) writeTo(buf *bytes.Buffer) {
@@ -1984,9 +2001,9 @@ func NewMsgDrivingRecordParamsIssued() *MsgDrivingRecordParamsIssued {
 type MsgMultimediaReportResp struct {
 	OutputMark
 	// 多媒体ID
-	MediaID uint32
+	MediaID uint32 `json:"media_id"`
 	// 重传包ID列表
-	IDs []uint16
+	IDs []uint16 `json:"ids"`
 }
 
 func (m *MsgMultimediaReportResp) writeTo(buf *bytes.Buffer) {
@@ -2020,26 +2037,26 @@ func NewMsgMultimediaReportResp() *MsgMultimediaReportResp {
 type MsgSnapshoot struct {
 	OutputMark
 	// 通道ID
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 	// 拍摄命令，0-停止拍摄，0xFFFF-录像，其他-拍照张数
-	Order uint16
+	Order uint16 `json:"order"`
 	// 拍照间隔/录像时间，单位为s，0-表示按最小间隔拍照或一直录像
-	Duration uint16
+	Duration uint16 `json:"duration"`
 	// 保存标识，1-保存，0-实时上传
-	SaveTag byte
+	SaveTag byte `json:"save_tag"`
 	// 分辨率，0-最低分辨率，1-320×240，2-640×480，3-800×600，4-1024×768，
 	// 5-176×144[Qcif]，6-352×288[Cif]，7-704×288[HALF D1]，8-704×576[D1]，0xFF-最高分辨率
-	Resolution byte
+	Resolution byte `json:"resolution"`
 	// 图片/视频质量，取值范围1~10，1-代表质量损失最小，10-表示压缩比最大
-	Quality byte
+	Quality byte `json:"quality"`
 	// 亮度，0~255
-	Brightness byte
+	Brightness byte `json:"brightness"`
 	// 对比度，0~127
-	Contrast byte
+	Contrast byte `json:"contrast"`
 	// 饱和度，0~127
-	Saturation byte
+	Saturation byte `json:"saturation"`
 	// 色度，0~255
-	Chroma byte
+	Chroma byte `json:"chroma"`
 }
 
 func (m *MsgSnapshoot) writeTo(buf *bytes.Buffer) {
@@ -2082,15 +2099,15 @@ func NewMsgSnapshoot() *MsgSnapshoot {
 type MsgSearchLocalMultimedia struct {
 	OutputMark
 	// 多媒体类型，0-图像，1-音频，2-视频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 通道ID，0表示检索该媒体类型的所有通道
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 	// 事件项编码，0-平台下发指令，1-定时动作，2-抢劫报警触发，3-碰撞侧翻报警触发，其他保留
-	EventCode byte
+	EventCode byte `json:"event_code"`
 	// 起始时间
-	STime time.Time
+	STime time.Time `json:"start_time"`
 	// 结束时间
-	ETime time.Time
+	ETime time.Time `json:"end_time"`
 }
 
 func (m *MsgSearchLocalMultimedia) writeTo(buf *bytes.Buffer) {
@@ -2127,17 +2144,17 @@ func NewMsgSearchLocalMultimedia() *MsgSearchLocalMultimedia {
 type MsgPullLocalMultimedia struct {
 	OutputMark
 	// 多媒体类型，0-图像，1-音频，2-视频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 通道ID
-	ChannelID byte
+	ChannelID byte `json:"channel_id"`
 	// 事件项编码，0-平台下发指令，1-定时动作，2-抢劫报警触发，3-碰撞侧翻报警触发，其他保留
-	EventCode byte
+	EventCode byte `json:"event_code"`
 	// 起始时间
-	STime time.Time
+	STime time.Time `json:"start_time"`
 	// 结束时间
-	ETime time.Time
+	ETime time.Time `json:"end_time"`
 	// 删除标识，0-保留，1-删除
-	Deleted byte
+	Deleted byte `json:"deleted"`
 }
 
 func (m *MsgPullLocalMultimedia) writeTo(buf *bytes.Buffer) {
@@ -2176,13 +2193,13 @@ func NewMsgPullLocalMultimedia() *MsgPullLocalMultimedia {
 type MsgRecording struct {
 	OutputMark
 	// 录音命令，0-停止录音，1-开始录音
-	Operation byte
+	Operation byte `json:"operation"`
 	// 录音时间，0表示一直录着
-	Duration uint16
+	Duration uint16 `json:"duration"`
 	// 保存标识，0-实时上传，1-保存
-	SaveFlag byte
+	SaveFlag byte `json:"save_flag"`
 	// 音频采样率，0-8K，1-11K，2-23K，3-32K
-	SampleRate byte
+	SampleRate byte `json:"sample_rate"`
 }
 
 func (m *MsgRecording) writeTo(buf *bytes.Buffer) {
@@ -2212,9 +2229,9 @@ func NewMsgRecording() *MsgRecording {
 type MsgGetLocalMultimedia struct {
 	OutputMark
 	// 多媒体ID
-	MediaID uint32
+	MediaID uint32 `json:"media_id"`
 	// 删除标识，0-保留，1-删除
-	Deleted byte
+	Deleted byte `json:"deleted"`
 }
 
 func (m *MsgGetLocalMultimedia) writeTo(buf *bytes.Buffer) {
@@ -2240,9 +2257,9 @@ func NewMsgGetLocalMultimedia() *MsgGetLocalMultimedia {
 type MsgDataDownlink struct {
 	OutputMark
 	// 透传消息类型，0-GNSS详细数据，0x0B-IC卡信息，0x41-串口1数据，0x42-串口2数据，≥0xF0-自定义
-	DataType byte
+	DataType byte `json:"data_type"`
 	// 透传消息内容
-	Content []byte
+	Content []byte `json:"content"`
 }
 
 func (m *MsgDataDownlink) writeTo(buf *bytes.Buffer) {
@@ -2265,9 +2282,9 @@ func NewMsgDataDownlink() *MsgDataDownlink {
 type MsgServerRSAPublicKey struct {
 	OutputMark
 	// 密钥中的e
-	E uint32
+	E uint32 `json:"e"`
 	// 密钥中的n
-	N [128]byte
+	N [128]byte `json:"n"`
 }
 
 func (m *MsgServerRSAPublicKey) writeTo(buf *bytes.Buffer) {
@@ -2311,27 +2328,27 @@ func NewMsgGetDriverIdentity() *MsgGetDriverIdentity {
 type MsgRemoteVideoReplay struct {
 	OutputMark
 	// 服务器IP地址
-	IP string
+	IP string `json:"ip"`
 	// TCP端口号
-	TCPPort uint16
+	TCPPort uint16 `json:"tcp_port"`
 	// UDP端口号
-	UDPPort uint16
+	UDPPort uint16 `json:"udp_port"`
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 音视频类型：0-音视频，1-音频，2-视频，3-视频或音视频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 码流类型：0-主码流或子码流，1-主码流，2-子码流
-	StreamType byte
+	StreamType byte `json:"stream_type"`
 	// 存储器类型：0-主存储器或灾备存储器，1-主存储器，2-灾备存储器
-	StorageType byte
+	StorageType byte `json:"storage_type"`
 	// 回放方式：0-正常回放，1-快进回放，2-关键帧快退回放，3-关键帧播放，4-单帧上传
-	ReplayMode byte
+	ReplayMode byte `json:"replay_mode"`
 	// 快进/快退倍数：0-无效，1-1倍，2-2倍，3-4倍，4-8倍，5-16倍
-	Multiple byte
+	Multiple byte `json:"multiple"`
 	// 开始时间
-	STime time.Time
+	STime time.Time `json:"start_time"`
 	// 结束时间
-	ETime time.Time
+	ETime time.Time `json:"end_time"`
 }
 
 func (m *MsgRemoteVideoReplay) writeTo(buf *bytes.Buffer) {
@@ -2383,13 +2400,13 @@ func NewMsgRemoteVideoReplay() *MsgRemoteVideoReplay {
 type MsgRemoteReplayControl struct {
 	OutputMark
 	// 音视频通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 回放控制，0-开始回放，1-暂停回放，2-结束回放，3-快进回放，4-关键帧快退回放，5-拖动回放，6-关键帧播放
-	Operation byte
+	Operation byte `json:"operation"`
 	// 快进/快退倍数，0-无效，1-1倍，2-2倍，3-4倍，4-8倍，5-16倍
-	Multiple byte
+	Multiple byte `json:"multiple"`
 	// 拖动位置
-	Seek time.Time
+	Seek time.Time `json:"seek"`
 }
 
 func (m *MsgRemoteReplayControl) writeTo(buf *bytes.Buffer) {
@@ -2418,19 +2435,19 @@ func NewMsgRemoteReplayControl() *MsgRemoteReplayControl {
 type MsgMediaResourceSelect struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 开始时间
-	STime time.Time
+	STime time.Time `json:"start_time"`
 	// 结束时间
-	ETime time.Time
+	ETime time.Time `json:"end_time"`
 	// 报警标志
-	Alarm uint64
+	Alarm uint64 `json:"alarm"`
 	// 音视频类型：0-音视频，1-音频，2-视频,3-视频或音视频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 码流类型，0-所有码流，1-主码流，2-子码流
-	StreamType byte
+	StreamType byte `json:"stream_type"`
 	// 存储器类型，0-所有存储器，1-主存储器，2-灾备存储器
-	StorageType byte
+	StorageType byte `json:"storage_type"`
 }
 
 func (m *MsgMediaResourceSelect) writeTo(buf *bytes.Buffer) {
@@ -2469,38 +2486,148 @@ func NewMsgMediaResourceSelect() *MsgMediaResourceSelect {
 	}
 }
 
-// // MsgAttachUploadSb 苏标附件上传消息
-// type MsgAttachUploadSb struct {
-// 	OutputMark
-// 	ServerAddr string       // 服务器地址
-// 	TcpPort    uint16       // 服务器TCP端口号
-// 	UdpPort    uint16       // 服务器UDP端口号
-// 	AlarmId    AlarmMarking // 报警标识
-// 	AlarmCode  string       // 报警编号32byte
-// }
+// MsgAttachUploadSb 报警附件上传指令（苏标）
+type MsgAttachUploadSb struct {
+	OutputMark
+	// 附件服务器IP地址
+	ServerAddr string `json:"server_addr"`
+	// 附件服务器TCP端口
+	TCPPort uint16 `json:"tcp_port"`
+	// 附件服务器UDP端口
+	UDPPort uint16 `json:"udp_port"`
+	// 报警标识号
+	Marking AlarmMarking `json:"marking"`
+	// 报警编号，平台给报警分配的唯一编号，32字节
+	AlarmNo string `json:"alarm_no"`
+}
+
+func (m *MsgAttachUploadSb) writeTo(buf *bytes.Buffer) {
+	// 服务器地址长度
+	buf.WriteByte(byte(len(m.ServerAddr)))
+	// 服务器地址
+	buf.Write([]byte(m.ServerAddr))
+	// 服务器TCP端口
+	port := make([]byte, 2)
+	binary.BigEndian.PutUint16(port, m.TCPPort)
+	buf.Write(port)
+	// 服务器UDP端口
+	binary.BigEndian.PutUint16(port, m.UDPPort)
+	buf.Write(port)
+	// 报警标识号
+	m.Marking.writeTo(buf)
+	// 报警编号
+	writeFixedString(buf, m.AlarmNo, 32)
+	// 预留
+	buf.Write(make([]byte, 16))
+}
+
+// NewMsgAttachUploadSb 新建报警附件上传指令消息
+func NewMsgAttachUploadSb() *MsgAttachUploadSb {
+	return &MsgAttachUploadSb{
+		OutputMark: OutputMark{
+			ID: msgIDAttachUpload,
+		},
+	}
+}
 
-// func (m *MsgAttachUploadSb) readBy(buf *bytes.Buffer) {
-// 	//服务器地址长度
-// 	buf.WriteByte(byte(len(m.ServerAddr)))
-// 	//服务器地址
-// 	buf.Write([]byte(m.ServerAddr))
-// 	//服务器TCP端口
-// 	port := make([]byte, 2)
-// 	binary.BigEndian.PutUint16(port, m.TcpPort)
-// 	buf.Write(port)
-// 	//服务器UDP端口
-// 	binary.BigEndian.PutUint16(port, m.UdpPort)
-// 	buf.Write(port)
-// 	// 报警标识
-// 	m.AlarmId.writeTo(buf)
-// 	// 报警编号
-// 	if l := len(m.AlarmCode); l >= 32 {
-// 		buf.Write([]byte(m.AlarmCode)[:32])
-// 	} else {
-// 		buf.Write([]byte(m.AlarmCode))
-// 		buf.Write(make([]byte, 32-l))
-// 	}
-// }
+// MsgAlarmAttachInfo 报警附件信息消息（苏标），终端连接附件服务器后上报
+type MsgAlarmAttachInfo struct {
+	InputMark
+	// 终端ID
+	TerminalID string `json:"terminal_id"`
+	// 报警标识号
+	Marking AlarmMarking `json:"marking"`
+	// 报警编号
+	AlarmNo string `json:"alarm_no"`
+	// 信息类型，0-正常报警文件信息，1-补传报警文件信息
+	InfoType byte `json:"info_type"`
+	// 附件信息列表
+	Files []AttachFile `json:"files"`
+}
+
+func (m *MsgAlarmAttachInfo) readBy(buf *bytes.Buffer) {
+	// 终端ID
+	m.TerminalID = readFixedString(buf, 7)
+	// 报警标识号
+	m.Marking.readBy(buf)
+	// 报警编号
+	m.AlarmNo = readFixedString(buf, 32)
+	// 信息类型
+	m.InfoType, _ = buf.ReadByte()
+	// 附件数量
+	count, _ := buf.ReadByte()
+	// 附件信息列表
+	m.Files = make([]AttachFile, count)
+	for i := range m.Files {
+		m.Files[i].readBy(buf)
+	}
+}
+
+// MsgAttachFileInfo 文件信息上传（苏标），终端上传附件数据前发送
+type MsgAttachFileInfo struct {
+	InputMark
+	// 文件名称
+	Name string `json:"name"`
+	// 文件类型，见AttachFileTypeXXX
+	Type byte `json:"type"`
+	// 文件大小
+	Size uint32 `json:"size"`
+}
+
+func (m *MsgAttachFileInfo) readBy(buf *bytes.Buffer) {
+	// 文件名称长度
+	l, _ := buf.ReadByte()
+	// 文件名称
+	m.Name = string(buf.Next(int(l)))
+	// 文件类型
+	m.Type, _ = buf.ReadByte()
+	// 文件大小
+	m.Size = binary.BigEndian.Uint32(buf.Next(4))
+}
+
+// MsgAttachFileFinish 文件上传完成消息（苏标），终端发送完一个附件的数据后发送
+type MsgAttachFileFinish struct {
+	MsgAttachFileInfo
+}
+
+// MsgAttachFileFinishResp 文件上传完成消息应答（苏标）
+type MsgAttachFileFinishResp struct {
+	OutputMark
+	// 文件名称
+	Name string `json:"name"`
+	// 文件类型，见AttachFileTypeXXX
+	Type byte `json:"type"`
+	// 上传结果，0-完成，1-需要补传
+	Result byte `json:"result"`
+	// 补传数据包列表
+	Resends []AttachRange `json:"resends"`
+}
+
+func (m *MsgAttachFileFinishResp) writeTo(buf *bytes.Buffer) {
+	// 文件名称长度
+	buf.WriteByte(byte(len(m.Name)))
+	// 文件名称
+	buf.Write([]byte(m.Name))
+	// 文件类型
+	buf.WriteByte(m.Type)
+	// 上传结果
+	buf.WriteByte(m.Result)
+	// 补传数据包数量
+	buf.WriteByte(byte(len(m.Resends)))
+	// 补传数据包列表
+	for i := range m.Resends {
+		m.Resends[i].writeTo(buf)
+	}
+}
+
+// NewMsgAttachFileFinishResp 新建文件上传完成消息应答
+func NewMsgAttachFileFinishResp() *MsgAttachFileFinishResp {
+	return &MsgAttachFileFinishResp{
+		OutputMark: OutputMark{
+			ID: msgIDAttachFileFinishResp,
+		},
+	}
+}
 
 // // MsgAttachUpload 附件上传消息
 // type MsgAttachUpload struct {
@@ -2626,17 +2753,17 @@ func NewMsgMediaProperty() *MsgMediaProperty {
 type MsgRealMediaRequest struct {
 	OutputMark
 	// 服务器IP地址
-	IP string
+	IP string `json:"ip"`
 	// TCP端口号
-	TCPPort uint16
+	TCPPort uint16 `json:"tcp_port"`
 	// UDP端口号
-	UDPPort uint16
+	UDPPort uint16 `json:"udp_port"`
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 音视频类型：0-音视频，1-视频，2-双向对讲，3-监听，4-中心广播，5-透传
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 码流类型：0-主码流，1-子码流
-	StreamType byte
+	StreamType byte `json:"stream_type"`
 }
 
 func (m *MsgRealMediaRequest) writeTo(buf *bytes.Buffer) {
@@ -2671,13 +2798,13 @@ func NewMsgRealMediaRequest() *MsgRealMediaRequest {
 type MsgRealMediaControl struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 控制指令，0-关闭音视频传输，1-切换码流，2-暂停，3-恢复，4关闭双向对讲
-	Command byte
+	Command byte `json:"command"`
 	// 操作类型，0-关闭该通道，1-只关闭音频，2-只关闭视频
-	Operation byte
+	Operation byte `json:"operation"`
 	// 切换码流类型，0-主码流，1-子码流
-	StreamType byte
+	StreamType byte `json:"stream_type"`
 }
 
 func (m *MsgRealMediaControl) writeTo(buf *bytes.Buffer) {
@@ -2704,9 +2831,9 @@ func NewMsgRealMediaControl() *MsgRealMediaControl {
 type MsgRealMediaStatusNotice struct {
 	OutputMark
 	//逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	//丢包率
-	PacketLossRate byte
+	PacketLossRate byte `json:"packet_loss_rate"`
 }
 
 func (m *MsgRealMediaStatusNotice) writeTo(buf *bytes.Buffer) {
@@ -2729,31 +2856,31 @@ func NewMsgRealMediaStatusNotice() *MsgRealMediaStatusNotice {
 type MsgFileUploadCmd struct {
 	OutputMark
 	// 服务器地址
-	ServerAddr string
+	ServerAddr string `json:"server_addr"`
 	// 端口号
-	ServerPort uint16
+	ServerPort uint16 `json:"server_port"`
 	// 用户名
-	UserName string
+	UserName string `json:"user_name"`
 	// 密码
-	Password string
+	Password string `json:"password"`
 	// 文件上传路径
-	Path string
+	Path string `json:"path"`
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 开始时间
-	STime time.Time
+	STime time.Time `json:"start_time"`
 	// 结束时间
-	ETime time.Time
+	ETime time.Time `json:"end_time"`
 	// 报警标志
-	Alarm uint64
+	Alarm uint64 `json:"alarm"`
 	// 音视频资源类型，0-音视频，1-音频，2-视频，3-视频或音频
-	MediaType byte
+	MediaType byte `json:"media_type"`
 	// 码流类型，0-主码流或子码流，1-主码流，2-子码流
-	StreamType byte
+	StreamType byte `json:"stream_type"`
 	// 存储位置，0-主存储器或灾备存储器，1-主存储器，2-灾备存储器
-	StorageType byte
+	StorageType byte `json:"storage_type"`
 	// 任务执行条件
-	ExeCondition byte
+	ExeCondition byte `json:"exe_condition"`
 }
 
 func (m *MsgFileUploadCmd) writeTo(buf *bytes.Buffer) {
@@ -2818,9 +2945,9 @@ func NewMsgFileUploadCmd() *MsgFileUploadCmd {
 type MsgFileUploadCtl struct {
 	OutputMark
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 	// 上传控制
-	Ctl byte
+	Ctl byte `json:"ctl"`
 }
 
 func (m *MsgFileUploadCtl) writeTo(buf *bytes.Buffer) {
@@ -2845,11 +2972,11 @@ func NewMsgFileUploadCtl() *MsgFileUploadCtl {
 type MsgPtzTurn struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 方向
-	Direction byte
+	Direction byte `json:"direction"`
 	//速度
-	Speed byte
+	Speed byte `json:"speed"`
 }
 
 func (m *MsgPtzTurn) writeTo(buf *bytes.Buffer) {
@@ -2874,9 +3001,9 @@ func NewMsgPtzTurn() *MsgPtzTurn {
 type MsgPtzFocus struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 操作
-	Operate byte
+	Operate byte `json:"operate"`
 }
 
 func (m *MsgPtzFocus) writeTo(buf *bytes.Buffer) {
@@ -2899,9 +3026,9 @@ func NewMsgPtzFocus() *MsgPtzFocus {
 type MsgPtzAperture struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 操作
-	Operate byte
+	Operate byte `json:"operate"`
 }
 
 func (m *MsgPtzAperture) writeTo(buf *bytes.Buffer) {
@@ -2924,9 +3051,9 @@ func NewMsgPtzAperture() *MsgPtzAperture {
 type MsgPtzWiper struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 操作
-	Operate byte
+	Operate byte `json:"operate"`
 }
 
 func (m *MsgPtzWiper) writeTo(buf *bytes.Buffer) {
@@ -2949,9 +3076,9 @@ func NewMsgPtzWiper() *MsgPtzWiper {
 type MsgPtzFillLight struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 操作
-	Operate byte
+	Operate byte `json:"operate"`
 }
 
 func (m *MsgPtzFillLight) writeTo(buf *bytes.Buffer) {
@@ -2974,9 +3101,9 @@ func NewMsgPtzFillLight() *MsgPtzFillLight {
 type MsgPtzZoom struct {
 	OutputMark
 	// 逻辑通道号
-	Channel byte
+	Channel byte `json:"channel"`
 	// 操作
-	Operate byte
+	Operate byte `json:"operate"`
 }
 
 func (m *MsgPtzZoom) writeTo(buf *bytes.Buffer) {
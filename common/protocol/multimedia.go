@@ -1,4 +1,4 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
@@ -32,17 +32,17 @@ func init() {
 			return searchLocalMultimediaRespUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDMediaResourceListReport,
+		Cmd: MsgIDMediaResourceListReport,
 		NewUnmarshaler: func() Unmarshaler {
 			return mediaResourceListReportUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDMediaPropertyReport,
+		Cmd: MsgIDMediaPropertyReport,
 		NewUnmarshaler: func() Unmarshaler {
 			return mediaPropertyReportUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDFileUploadFinish,
+		Cmd: MsgIDFileUploadFinish,
 		NewUnmarshaler: func() Unmarshaler {
 			return fileUploadFinishUnmarshal
 		},
@@ -1,10 +1,11 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"reflect"
 )
 
 // 协议包体最大字节数
@@ -20,7 +21,7 @@ type Output interface {
 // OutputMark 输出消息标签
 type OutputMark struct {
 	// 消息ID
-	ID uint16
+	ID uint16 `json:"id"`
 }
 
 func (m *OutputMark) msgID() uint16 {
@@ -31,6 +32,11 @@ func (m *OutputMark) base() Output {
 	return nil
 }
 
+// OutputID 获取平台消息ID
+func OutputID(output Output) uint16 {
+	return output.msgID()
+}
+
 // Input 输入消息
 type Input interface {
 	msgID() uint16
@@ -39,12 +45,17 @@ type Input interface {
 	base() Input
 }
 
+// MessageID 获取终端消息ID
+func MessageID(input Input) uint16 {
+	return input.msgID()
+}
+
 // InputMark 输入包标签
 type InputMark struct {
 	// 消息ID
-	ID uint16
+	ID uint16 `json:"id"`
 	// 消息流水号
-	Number uint16
+	Number uint16 `json:"number"`
 }
 
 func (m *InputMark) msgID() uint16 {
@@ -67,13 +78,38 @@ type response interface {
 	reqNum() uint16
 }
 
+// ReplyNumber 获取终端应答消息对应的平台消息流水号，非应答消息返回false
+func ReplyNumber(input Input) (uint16, bool) {
+	if r, ok := input.(response); ok {
+		return r.reqNum(), true
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(input))
+	if reflect.Struct != value.Kind() {
+		return 0, false
+	}
+	field := value.FieldByName("ReqNum")
+	if !field.IsValid() || reflect.Uint16 != field.Kind() {
+		return 0, false
+	}
+	return uint16(field.Uint()), true
+}
+
+// ReplyID 获取终端通用应答对应的平台消息ID，其他消息返回false
+func ReplyID(input Input) (uint16, bool) {
+	if r, ok := input.(response); ok {
+		return r.reqID(), true
+	}
+	return 0, false
+}
+
 // ResponseMark 应答标签
 type ResponseMark struct {
 	InputMark
 	// 应答消息ID
-	ReqID uint16
+	ReqID uint16 `json:"req_id"`
 	// 应答流水号
-	ReqNum uint16
+	ReqNum uint16 `json:"req_num"`
 }
 
 func (m *ResponseMark) reqID() uint16 {
@@ -89,7 +125,7 @@ type msgAttr uint16
 
 // 设置消息体大小
 func (m *msgAttr) setBodySize(size uint16) {
-	*m |= msgAttr(size)
+	*m = *m&^0x03FF | msgAttr(size&0x03FF)
 }
 
 // 获取消息体大小
@@ -104,7 +140,7 @@ func (m *msgAttr) setEncryption(enc byte) {
 
 // 获取加密方式
 func (m *msgAttr) getEncryption() byte {
-	return byte(*m & 0x1C00)
+	return byte(*m>>10) & 0x07
 }
 
 // subpackage 指定分包
@@ -143,8 +179,8 @@ type head struct {
 	attr msgAttr
 	// 协议版本号
 	version byte
-	// 终端手机号,10位BCD码，不足前面补0
-	phone []byte
+	// 终端手机号,10位BCD码，不足前面补0；2011版本只使用后6位
+	phone [10]byte
 	// 消息流水号，从0开始循环累加
 	number uint16
 	// 仅消息体属性中明确有消息包处理时有效
@@ -153,84 +189,81 @@ type head struct {
 
 // len 消息头大小
 func (h *head) len() int {
-	if h.attr.isSubpackage() {
-		return 21
+	l := 12
+	if h.attr.hasVersionTag() {
+		l += 5
 	}
 
-	if h.attr.hasVersionTag() {
-		return 17
+	if h.attr.isSubpackage() {
+		l += 4
 	}
 
-	return 12
+	return l
 }
 
-// readBy 从缓存中读取消息头内容
-func (h *head) readBy(buf *bytes.Buffer) error {
-	l := buf.Len()
-	if l < h.len() {
-		return errors.New("the bad protocol data: < head.length")
+// readBy 从字节序中读取消息头内容，返回消息头大小
+func (h *head) readBy(bts []byte) (int, error) {
+	if len(bts) < 4 {
+		return 0, errors.New("the bad protocol data: < head.length")
 	}
 
-	value := make([]byte, 2)
+	h.id = binary.BigEndian.Uint16(bts[0:])
+	h.attr = msgAttr(binary.BigEndian.Uint16(bts[2:]))
 
-	buf.Read(value)
-	h.id = binary.BigEndian.Uint16(value)
-
-	buf.Read(value)
-	h.attr = msgAttr(binary.BigEndian.Uint16(value))
-
-	// 再次验证长度，分包数据，消息头长度大一些
-	if l < h.len() {
-		return errors.New("the bad protocol data: < head.length")
+	// 再次验证长度，分包数据和带版本号的消息头长度大一些
+	l := h.len()
+	if len(bts) < l {
+		return 0, errors.New("the bad protocol data: < head.length")
 	}
 
+	off := 4
 	if h.attr.hasVersionTag() {
-		h.version, _ = buf.ReadByte()
-		h.phone = make([]byte, 10)
+		h.version = bts[off]
+		off += copy(h.phone[:], bts[off+1:off+11]) + 1
 	} else {
-		h.phone = make([]byte, 6)
+		h.version = version2011
+		h.phone = [10]byte{}
+		off += copy(h.phone[4:], bts[off:off+6])
 	}
 
-	buf.Read(h.phone)
-
-	buf.Read(value)
-	h.number = binary.BigEndian.Uint16(value)
+	h.number = binary.BigEndian.Uint16(bts[off:])
+	off += 2
 
 	if h.attr.isSubpackage() {
-		buf.Read(value)
-		h.pack.total = binary.BigEndian.Uint16(value)
-		buf.Read(value)
-		h.pack.index = binary.BigEndian.Uint16(value)
+		h.pack.total = binary.BigEndian.Uint16(bts[off:])
+		h.pack.index = binary.BigEndian.Uint16(bts[off+2:])
+	} else {
+		h.pack = packIndex{}
 	}
 
-	return nil
+	return l, nil
 }
 
 // 写入缓存中
 func (h *head) writeTo(buf *bytes.Buffer) {
-	value := make([]byte, 2)
+	var value [2]byte
 
-	binary.BigEndian.PutUint16(value, h.id)
-	buf.Write(value)
+	binary.BigEndian.PutUint16(value[:], h.id)
+	buf.Write(value[:])
 
-	binary.BigEndian.PutUint16(value, uint16(h.attr))
-	buf.Write(value)
+	binary.BigEndian.PutUint16(value[:], uint16(h.attr))
+	buf.Write(value[:])
 
 	if h.attr.hasVersionTag() {
 		buf.WriteByte(h.version)
-		buf.Write(h.phone[:10])
+		buf.Write(h.phone[:])
 	} else {
-		buf.Write(h.phone[4:10])
+		buf.Write(h.phone[4:])
 	}
 
-	binary.BigEndian.PutUint16(value, h.number)
-	buf.Write(value)
+	binary.BigEndian.PutUint16(value[:], h.number)
+	buf.Write(value[:])
 
 	if h.attr.isSubpackage() {
-		binary.BigEndian.PutUint16(value, h.pack.total)
-		buf.Write(value)
-		binary.BigEndian.PutUint16(value, h.pack.index)
-		buf.Write(value)
+		binary.BigEndian.PutUint16(value[:], h.pack.total)
+		buf.Write(value[:])
+		binary.BigEndian.PutUint16(value[:], h.pack.index)
+		buf.Write(value[:])
 	}
 }
 
@@ -247,6 +280,7 @@ func (p *packet) pack() []byte {
 	var buf bytes.Buffer
 
 	// 写入协议头
+	p.head.attr.setBodySize(uint16(len(p.body)))
 	p.head.writeTo(&buf)
 	// 写入协议体
 	buf.Write(p.body)
@@ -260,20 +294,52 @@ func (p *packet) pack() []byte {
 func (p *packet) unpack(bts []byte) error {
 	// 校验码
 	l := len(bts)
+	if l < 2 {
+		return errors.New("the bad protocol data: < head.length")
+	}
 	if !checksum(bts[0:l-1], bts[l-1]) {
 		return errors.New("the bad protocol data:checksum error")
 	}
 
-	// 解析协议包
-	buf := bytes.NewBuffer(bts[:l-1])
-	if err := p.head.readBy(buf); nil != err {
+	// 解析协议包，协议体直接引用字节序，不做拷贝
+	n, err := p.head.readBy(bts[:l-1])
+	if nil != err {
 		return err
 	}
-	p.body = buf.Bytes()
+	p.body = bts[n : l-1]
 
 	return nil
 }
 
+// 打包协议包，消息体过大时自动分包，返回各子包的字节序
+func (p *packet) packs() ([][]byte, error) {
+	if len(p.body) <= maxBodySize {
+		return [][]byte{p.pack()}, nil
+	}
+
+	p.head.attr.subpackage()
+	p.head.pack.total = uint16(packCount(len(p.body)))
+
+	bts := make([][]byte, 0, p.head.pack.total)
+	for idx := uint16(1); idx <= p.head.pack.total; idx++ {
+		sub, err := p.subpacket(idx)
+		if nil != err {
+			return nil, err
+		}
+		bts = append(bts, sub.pack())
+	}
+
+	return bts, nil
+}
+
+// packCount 消息体需要分成的包数，不分包时为1
+func packCount(size int) int {
+	if size <= maxBodySize {
+		return 1
+	}
+	return (size + maxBodySize - 1) / maxBodySize
+}
+
 func (p *packet) setNumber(number uint16) {
 	p.head.number = number
 }
@@ -282,7 +348,7 @@ func (p *packet) hashCode() uint32 {
 	return uint32(p.head.id)<<16 | uint32(p.head.number)
 }
 
-// 获取子包（多协议包时有效）
+// 获取子包（多协议包时有效），各子包的流水号自首包流水号起依次递增
 func (p *packet) subpacket(idx uint16) (*packet, error) {
 	size, off := len(p.body), int(idx-1)*maxBodySize
 	if off < 0 || off > size {
@@ -292,6 +358,7 @@ func (p *packet) subpacket(idx uint16) (*packet, error) {
 	// 创建子包
 	var packet packet
 	packet.head = p.head
+	packet.head.number = p.head.number + idx - 1
 	packet.head.pack.index = idx
 	if (size - off) < maxBodySize {
 		packet.body = make([]byte, size-off)
@@ -0,0 +1,226 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/codec"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// PacketCodec create packet codec
+func PacketCodec() codec.Codec {
+	return &packetCodec{}
+}
+
+type packetCodec struct {
+	packet       packet                 // 读协议包，同一连接的读操作串行执行，可重复使用
+	pending      map[uint32]*subpackets // 接收中的分包消息，键为消息ID与首包流水号
+	pendingBytes int                    // 接收中的分包消息已缓存的字节数
+
+	sentMtx sync.Mutex             // 写操作可能并发执行
+	sent    map[uint16]*sentPacket // 已发送的分包消息，键为首包流水号，用于响应终端补传分包请求
+}
+
+// 分包消息的重组超时时间，超时未收齐的分包丢弃
+const subpackageTimeout = time.Minute
+
+// 每个连接同时接收中的分包消息数，超出时丢弃最早更新的分包消息
+const maxPendingMessages = 8
+
+// 每个连接接收中的分包消息最多缓存的字节数，超出时丢弃该分包消息
+const maxPendingBytes = 8 << 20
+
+// 已发送的分包消息的保留时间，超时后不再响应补传请求
+const sentPacketTimeout = time.Minute * 5
+
+// sentPacket 已发送的分包消息
+type sentPacket struct {
+	packet *packet
+	sent   time.Time
+}
+
+// subpackets 接收中的分包消息
+type subpackets struct {
+	head    head
+	bodies  [][]byte // 按包序号存放的消息体
+	count   int      // 已收到的子包个数
+	size    int      // 已收到的子包字节数
+	updated time.Time
+}
+
+// CodecName 编码器名称
+func (*packetCodec) CodecName() string {
+	return "packet-codec"
+}
+
+func (p *packetCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	bts := message.([]byte)
+
+	// 协议包只在后续Context中同步使用，不会被持有
+	utils.Assert(p.packet.unpack(bts))
+	if !p.packet.head.attr.isSubpackage() {
+		// 终端补传分包请求，重新发送缓存的子包，消息仍交由后续Context处理
+		if msgIDTerminalPackResend == p.packet.head.id {
+			for _, pack := range p.resend(p.packet.body) {
+				ctx.Write(pack)
+			}
+		}
+		ctx.HandleRead(&p.packet)
+		return
+	}
+
+	// 分包消息收齐后按首包流水号重组为一个协议包
+	if packet := p.reassemble(&p.packet); nil != packet {
+		ctx.HandleRead(packet)
+	}
+}
+
+// reassemble 缓存子包，收齐所有子包时返回重组后的协议包
+func (p *packetCodec) reassemble(sub *packet) *packet {
+	total, index := sub.head.pack.total, sub.head.pack.index
+	if 0 == total || 0 == index || index > total {
+		return nil
+	}
+
+	now := time.Now()
+	if nil == p.pending {
+		p.pending = make(map[uint32]*subpackets)
+	}
+	for key, pending := range p.pending {
+		if now.Sub(pending.updated) > subpackageTimeout {
+			p.drop(key)
+		}
+	}
+
+	// 各子包的流水号连续递增，由包序号推算首包流水号
+	key := uint32(sub.head.id)<<16 | uint32(sub.head.number-index+1)
+	pending, ok := p.pending[key]
+	if !ok || len(pending.bodies) != int(total) {
+		p.drop(key)
+		if len(p.pending) >= maxPendingMessages {
+			p.drop(p.oldest())
+		}
+		pending = &subpackets{head: sub.head, bodies: make([][]byte, total)}
+		p.pending[key] = pending
+	}
+	pending.updated = now
+	if nil == pending.bodies[index-1] {
+		if p.pendingBytes+len(sub.body) > maxPendingBytes {
+			p.drop(key)
+			return nil
+		}
+		// 子包消息体引用读缓存，需要拷贝
+		pending.bodies[index-1] = append([]byte{}, sub.body...)
+		pending.count++
+		pending.size += len(sub.body)
+		p.pendingBytes += len(sub.body)
+	}
+	if pending.count < int(total) {
+		return nil
+	}
+	p.drop(key)
+
+	packet := &packet{head: pending.head}
+	packet.head.number = sub.head.number - index + 1
+	packet.head.attr &^= 0x2000
+	packet.head.pack = packIndex{}
+	for _, body := range pending.bodies {
+		packet.body = append(packet.body, body...)
+	}
+	return packet
+}
+
+// drop 丢弃接收中的分包消息
+func (p *packetCodec) drop(key uint32) {
+	if pending, ok := p.pending[key]; ok {
+		p.pendingBytes -= pending.size
+		delete(p.pending, key)
+	}
+}
+
+// oldest 最早更新的接收中的分包消息
+func (p *packetCodec) oldest() (oldest uint32) {
+	var updated time.Time
+	for key, pending := range p.pending {
+		if updated.IsZero() || pending.updated.Before(updated) {
+			oldest, updated = key, pending.updated
+		}
+	}
+	return oldest
+}
+
+func (p *packetCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	packet := message.(*packet)
+
+	// 检查协议包是否需要分包，过大则分包循环发送
+	bts, err := packet.packs()
+	utils.Assert(err)
+	if len(bts) > 1 {
+		p.remember(packet)
+	}
+
+	for _, pack := range bts {
+		ctx.HandleWrite(pack)
+	}
+}
+
+// remember 缓存已发送的分包消息，终端补传请求中的原始消息流水号为首包流水号，同时清理超时的缓存
+func (p *packetCodec) remember(packet *packet) {
+	p.sentMtx.Lock()
+	defer p.sentMtx.Unlock()
+
+	now := time.Now()
+	if nil == p.sent {
+		p.sent = make(map[uint16]*sentPacket)
+	}
+	for number, sent := range p.sent {
+		if now.Sub(sent.sent) > sentPacketTimeout {
+			delete(p.sent, number)
+		}
+	}
+	p.sent[packet.head.number] = &sentPacket{packet: packet, sent: now}
+}
+
+// resend 按终端补传分包请求（0x0005）的消息体获取需要重传的子包，
+// 原始消息不在缓存中或请求格式错误时返回空
+func (p *packetCodec) resend(body []byte) [][]byte {
+	if len(body) < 4 {
+		return nil
+	}
+	number := binary.BigEndian.Uint16(body)
+	total := int(binary.BigEndian.Uint16(body[2:]))
+	if len(body) < 4+total*2 {
+		return nil
+	}
+
+	p.sentMtx.Lock()
+	sent, ok := p.sent[number]
+	p.sentMtx.Unlock()
+	if !ok || time.Since(sent.sent) > sentPacketTimeout {
+		return nil
+	}
+
+	var msg MsgTerGetSubpacket
+	msg.readBy(bytes.NewBuffer(body))
+	// 重传包列表为空时重传所有子包
+	if 0 == len(msg.IDs) {
+		for idx := uint16(1); idx <= sent.packet.head.pack.total; idx++ {
+			msg.IDs = append(msg.IDs, idx)
+		}
+	}
+
+	packs := make([][]byte, 0, len(msg.IDs))
+	for _, idx := range msg.IDs {
+		if 0 == idx || idx > sent.packet.head.pack.total {
+			continue
+		}
+		if sub, err := sent.packet.subpacket(idx); nil == err {
+			packs = append(packs, sub.pack())
+		}
+	}
+	return packs
+}
@@ -0,0 +1,1128 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/axgle/mahonia"
+)
+
+// 终端参数定义来源
+const (
+	ParamStd2013   = "JT/T 808-2013"
+	ParamStd2019   = "JT/T 808-2019"
+	ParamStd1078   = "JT/T 1078-2016"
+	ParamStdSafety = "JSATL12"
+	ParamStdVendor = "vendor"
+)
+
+// ParamDef 终端参数定义，用于参数编解码及界面按定义生成参数表单
+type ParamDef struct {
+	// 参数id
+	ID uint32 `json:"id"`
+	// 参数名称
+	Name string `json:"name"`
+	// 编码类型：uint8、uint16、uint32、string（GBK编码）、[]uint8（原始字节），
+	// 或结构化参数的类型名称，如AVParam、TPMSParam等
+	Type string `json:"type"`
+	// 单位，无单位时为空
+	Unit string `json:"unit,omitempty"`
+	// 参数说明
+	Description string `json:"description"`
+	// 定义来源，见ParamStdXxx
+	Standard string `json:"standard"`
+}
+
+// 内置的终端参数定义
+var builtinParamDefs = []ParamDef{
+	{0x0001, "终端心跳发送间隔", "uint32", "s", "", ParamStd2013},
+	{0x0002, "TCP消息应答超时时间", "uint32", "s", "", ParamStd2013},
+	{0x0003, "TCP消息重传次数", "uint32", "次", "", ParamStd2013},
+	{0x0004, "UDP消息应答超时时间", "uint32", "s", "", ParamStd2013},
+	{0x0005, "UDP消息重传次数", "uint32", "次", "", ParamStd2013},
+	{0x0006, "SMS消息应答超时时间", "uint32", "s", "", ParamStd2013},
+	{0x0007, "SMS消息重传次数", "uint32", "次", "", ParamStd2013},
+	{0x0010, "主服务器APN", "string", "", "无线通信拨号访问点，若网络制式为CDMA，则该处为PPP拨号号码", ParamStd2013},
+	{0x0011, "主服务器无线通信拨号用户名", "string", "", "", ParamStd2013},
+	{0x0012, "主服务器无线通信拨号密码", "string", "", "", ParamStd2013},
+	{0x0013, "主服务器地址", "string", "", "IP或域名，2019版以冒号分割主机和端口，多个服务器使用分号分割", ParamStd2013},
+	{0x0014, "备份服务器APN", "string", "", "", ParamStd2013},
+	{0x0015, "备份服务器无线通信拨号用户名", "string", "", "", ParamStd2013},
+	{0x0016, "备份服务器无线通信拨号密码", "string", "", "", ParamStd2013},
+	{0x0017, "备份服务器地址", "string", "", "IP或域名，2019版以冒号分割主机和端口，多个服务器使用分号分割", ParamStd2013},
+	{0x0018, "服务器TCP端口", "uint32", "", "2019版已删除，端口并入服务器地址", ParamStd2013},
+	{0x0019, "服务器UDP端口", "uint32", "", "2019版已删除，端口并入服务器地址", ParamStd2013},
+	{0x001A, "道路运输证IC卡认证主服务器地址", "string", "", "IP或域名", ParamStd2013},
+	{0x001B, "道路运输证IC卡认证主服务器TCP端口", "uint32", "", "", ParamStd2013},
+	{0x001C, "道路运输证IC卡认证主服务器UDP端口", "uint32", "", "", ParamStd2013},
+	{0x001D, "道路运输证IC卡认证备份服务器地址", "string", "", "IP或域名，端口同主服务器", ParamStd2013},
+	{0x0020, "位置汇报策略", "uint32", "", "0：定时汇报；1：定距汇报；2：定时和定距汇报", ParamStd2013},
+	{0x0021, "位置汇报方案", "uint32", "", "0：根据ACC状态；1：根据登录状态和ACC状态", ParamStd2013},
+	{0x0022, "驾驶员未登录汇报时间间隔", "uint32", "s", "", ParamStd2013},
+	{0x0023, "从服务器APN", "string", "", "该值为空时，终端应使用主服务器相同配置", ParamStd2019},
+	{0x0024, "从服务器无线通信拨号用户名", "string", "", "该值为空时，终端应使用主服务器相同配置", ParamStd2019},
+	{0x0025, "从服务器无线通信拨号密码", "string", "", "该值为空时，终端应使用主服务器相同配置", ParamStd2019},
+	{0x0026, "从服务器备份地址", "string", "", "IP或域名，主机和端口用冒号分割，多个服务器使用分号分割", ParamStd2019},
+	{0x0027, "休眠时汇报时间间隔", "uint32", "s", "", ParamStd2013},
+	{0x0028, "紧急报警时汇报时间间隔", "uint32", "s", "", ParamStd2013},
+	{0x0029, "缺省时间汇报间隔", "uint32", "s", "", ParamStd2013},
+	{0x002C, "缺省距离汇报间隔", "uint32", "m", "", ParamStd2013},
+	{0x002D, "驾驶员未登录汇报距离间隔", "uint32", "m", "", ParamStd2013},
+	{0x002E, "休眠时汇报距离间隔", "uint32", "m", "", ParamStd2013},
+	{0x002F, "紧急报警时汇报距离间隔", "uint32", "m", "", ParamStd2013},
+	{0x0030, "拐点补传角度", "uint32", "°", "小于180", ParamStd2013},
+	{0x0031, "电子围栏半径", "uint16", "m", "非法位移阈值", ParamStd2013},
+	{0x0032, "违规行驶时段范围", "[]uint8", "", "4字节BCD码：开始时、开始分、结束时、结束分", ParamStd2019},
+	{0x0040, "监控平台电话号码", "string", "", "", ParamStd2013},
+	{0x0041, "复位电话号码", "string", "", "可采用此电话号码拨打终端电话让终端复位", ParamStd2013},
+	{0x0042, "恢复出厂设置电话号码", "string", "", "可采用此电话号码拨打终端电话让终端恢复出厂设置", ParamStd2013},
+	{0x0043, "监控平台SMS电话号码", "string", "", "", ParamStd2013},
+	{0x0044, "接收终端SMS文本报警号码", "string", "", "", ParamStd2013},
+	{0x0045, "终端电话接听策略", "uint32", "", "0：自动接听；1：ACC ON时自动接听，OFF时手动接听", ParamStd2013},
+	{0x0046, "每次最长通话时间", "uint32", "s", "0为不允许通话，0xFFFFFFFF为不限制", ParamStd2013},
+	{0x0047, "当月最长通话时间", "uint32", "s", "0为不允许通话，0xFFFFFFFF为不限制", ParamStd2013},
+	{0x0048, "监听电话号码", "string", "", "", ParamStd2013},
+	{0x0049, "监管平台特权短信号码", "string", "", "", ParamStd2013},
+	{0x0050, "报警屏蔽字", "uint32", "", "与位置信息汇报消息中的报警标志相对应，相应位为1则相应报警被屏蔽", ParamStd2013},
+	{0x0051, "报警发送文本SMS开关", "uint32", "", "与报警标志相对应，相应位为1则相应报警时发送文本SMS", ParamStd2013},
+	{0x0052, "报警拍摄开关", "uint32", "", "与报警标志相对应，相应位为1则相应报警时摄像头拍摄", ParamStd2013},
+	{0x0053, "报警拍摄存储标志", "uint32", "", "与报警标志相对应，相应位为1则对相应报警时拍的照片进行存储，否则实时上传", ParamStd2013},
+	{0x0054, "关键标志", "uint32", "", "与报警标志相对应，相应位为1则对相应报警为关键报警", ParamStd2013},
+	{0x0055, "最高速度", "uint32", "km/h", "", ParamStd2013},
+	{0x0056, "超速持续时间", "uint32", "s", "", ParamStd2013},
+	{0x0057, "连续驾驶时间门限", "uint32", "s", "", ParamStd2013},
+	{0x0058, "当天累计驾驶时间门限", "uint32", "s", "", ParamStd2013},
+	{0x0059, "最小休息时间", "uint32", "s", "", ParamStd2013},
+	{0x005A, "最长停车时间", "uint32", "s", "", ParamStd2013},
+	{0x005B, "超速预警差值", "uint16", "0.1km/h", "", ParamStd2013},
+	{0x005C, "疲劳驾驶预警差值", "uint16", "s", "", ParamStd2013},
+	{0x005D, "碰撞报警参数", "uint16", "", "b7-b0：碰撞时间，单位4ms；b15-b8：碰撞加速度，单位0.1g", ParamStd2013},
+	{0x005E, "侧翻报警参数", "uint16", "°", "侧翻角度，默认30度", ParamStd2013},
+	{0x0064, "定时拍照控制", "uint32", "", "bit0-4：通道1-5定时拍照开关；bit8-12：通道1-5存储标志；bit16：时间单位，0秒1分；bit17-31：定时时间间隔", ParamStd2013},
+	{0x0065, "定距拍照控制", "uint32", "", "bit0-4：通道1-5定距拍照开关；bit8-12：通道1-5存储标志；bit16：距离单位，0米1公里；bit17-31：定距距离间隔", ParamStd2013},
+	{0x0070, "图像/视频质量", "uint32", "", "1-10，1最好", ParamStd2013},
+	{0x0071, "亮度", "uint32", "", "0-255", ParamStd2013},
+	{0x0072, "对比度", "uint32", "", "0-127", ParamStd2013},
+	{0x0073, "饱和度", "uint32", "", "0-127", ParamStd2013},
+	{0x0074, "色度", "uint32", "", "0-255", ParamStd2013},
+	{0x0075, "音视频参数设置", "AVParam", "", "实时流及存储流的编码模式、分辨率、帧率、码率等", ParamStd1078},
+	{0x0076, "音视频通道列表设置", "AVChannelList", "", "物理通道与逻辑通道对照表", ParamStd1078},
+	{0x0077, "单独视频通道参数设置", "VideoChannelParams", "", "需单独设置参数的视频通道列表", ParamStd1078},
+	{0x0079, "特殊报警录像参数设置", "AlarmRecordParam", "", "", ParamStd1078},
+	{0x007A, "视频相关报警屏蔽字", "uint32", "", "与视频报警标志位相对应，相应位为1则相应报警被屏蔽", ParamStd1078},
+	{0x007B, "图像分析报警参数设置", "ImageAnalysisParam", "", "", ParamStd1078},
+	{0x007C, "终端休眠唤醒模式设置", "WakeUpParam", "", "", ParamStd1078},
+	{0x0080, "车辆里程表读数", "uint32", "0.1km", "", ParamStd2013},
+	{0x0081, "车辆所在的省域ID", "uint16", "", "", ParamStd2013},
+	{0x0082, "车辆所在的市域ID", "uint16", "", "", ParamStd2013},
+	{0x0083, "机动车号牌", "string", "", "公安交通管理部门颁发的机动车号牌", ParamStd2013},
+	{0x0084, "车牌颜色", "uint8", "", "按照JT/T 697.7-2014中的规定，未上牌车辆填0", ParamStd2013},
+	{0x0090, "GNSS定位模式", "uint8", "", "bit0：GPS；bit1：北斗；bit2：GLONASS；bit3：Galileo", ParamStd2013},
+	{0x0091, "GNSS波特率", "uint8", "", "0x00：4800；0x01：9600；0x02：19200；0x03：38400；0x04：57600；0x05：115200", ParamStd2013},
+	{0x0092, "GNSS模块详细定位数据输出频率", "uint8", "", "0x00：500ms；0x01：1000ms；0x02：2000ms；0x03：3000ms；0x04：4000ms", ParamStd2013},
+	{0x0093, "GNSS模块详细定位数据采集频率", "uint32", "s", "", ParamStd2013},
+	{0x0094, "GNSS模块详细定位数据上传方式", "uint8", "", "0x00：本地存储不上传；0x01：按时间间隔；0x02：按距离间隔；0x0B：按累计时间；0x0C：按累计距离；0x0D：按累计条数", ParamStd2013},
+	{0x0095, "GNSS模块详细定位数据上传设置", "uint32", "", "单位与上传方式对应：秒、米或条", ParamStd2013},
+	{0x0100, "CAN总线通道1采集时间间隔", "uint32", "ms", "0表示不采集", ParamStd2013},
+	{0x0101, "CAN总线通道1上传时间间隔", "uint16", "s", "0表示不上传", ParamStd2013},
+	{0x0102, "CAN总线通道2采集时间间隔", "uint32", "ms", "0表示不采集", ParamStd2013},
+	{0x0103, "CAN总线通道2上传时间间隔", "uint16", "s", "0表示不上传", ParamStd2013},
+	{0x0110, "CAN总线ID单独采集设置", "[]uint8", "", "bit63-32：采集时间间隔(ms)；bit31：通道号；bit30：帧类型；bit29：采集方式；bit28-0：CAN总线ID", ParamStd2013},
+	{0xF000, "硬控通信端口号", "uint16", "", "", ParamStdVendor},
+	{0xF001, "车辆VIN码", "string", "", "", ParamStdVendor},
+	{0xF003, "车牌种类", "uint8", "", "", ParamStdVendor},
+	{0xF004, "CMS平台地址", "string", "", "", ParamStdVendor},
+	{0xF101, "DMS告警分级速度阈值", "uint32", "km/h", "", ParamStdVendor},
+	{0xF102, "人脸朝向左阈值", "uint32", "", "", ParamStdVendor},
+	{0xF103, "人脸朝向右阈值", "uint32", "", "", ParamStdVendor},
+	{0xF104, "人脸朝向上阈值", "uint32", "", "", ParamStdVendor},
+	{0xF105, "人脸朝向下阈值", "uint32", "", "", ParamStdVendor},
+	{0xF106, "警告文本1", "string", "", "默认：人脸丢失", ParamStdVendor},
+	{0xF107, "警告文本2", "string", "", "默认：请勿打电话", ParamStdVendor},
+	{0xF108, "警告文本3", "string", "", "默认：请勿喝水", ParamStdVendor},
+	{0xF109, "警告文本4", "string", "", "默认：请勿抽烟", ParamStdVendor},
+	{0xF10A, "警告文本5", "string", "", "默认：请目视前方", ParamStdVendor},
+	{0xF10B, "警告文本6", "string", "", "默认：请勿过度抬头", ParamStdVendor},
+	{0xF10C, "警告文本7", "string", "", "默认：请勿过度低头", ParamStdVendor},
+	{0xF10D, "警告文本8", "string", "", "默认：请勿打瞌睡", ParamStdVendor},
+	{0xF10E, "警告文本9", "string", "", "默认：请勿疲劳驾驶", ParamStdVendor},
+	{0xF10F, "眼睛的高宽比阈值", "uint32", "", "", ParamStdVendor},
+	{0xF110, "嘴巴的高宽比阈值", "uint32", "", "", ParamStdVendor},
+	{0xF111, "人脸质量分数阈值", "uint32", "", "", ParamStdVendor},
+	{0xF112, "获取人脸质量分数超时时间阈值", "uint32", "", "", ParamStdVendor},
+	{0xF113, "ADAS告警分级速度阈值", "uint32", "km/h", "", ParamStdVendor},
+	{0xF114, "判定车辆是否属于当前车道线比值阈值", "uint32", "", "", ParamStdVendor},
+	{0xF115, "车距过近的车距阈值", "uint32", "", "", ParamStdVendor},
+	{0xF116, "碰撞预警时间阈值", "uint32", "", "", ParamStdVendor},
+	{0xF200, "脉冲系数", "uint32", "", "", ParamStdVendor},
+	{0xF364, "高级驾驶辅助系统参数", "ADASParam", "", "", ParamStdSafety},
+	{0xF365, "驾驶员状态监测系统参数", "DSMParam", "", "", ParamStdSafety},
+	{0xF366, "胎压监测系统参数", "TPMSParam", "", "", ParamStdSafety},
+	{0xF367, "盲区监测系统参数", "BSDParam", "", "", ParamStdSafety},
+}
+
+var (
+	paramDefMtx sync.RWMutex
+	paramDefs   = make(map[uint32]*ParamDef)
+)
+
+func init() {
+	for idx := range builtinParamDefs {
+		def := builtinParamDefs[idx]
+		paramDefs[def.ID] = &def
+	}
+	// 0x0111~0x01FF用于其他CAN总线ID单独采集设置
+	for id := uint32(0x0111); id <= 0x01FF; id++ {
+		paramDefs[id] = &ParamDef{
+			ID:          id,
+			Name:        fmt.Sprintf("CAN总线ID单独采集设置%d", id-0x0110+1),
+			Type:        "[]uint8",
+			Description: paramDefs[ParamIDCANCollParam].Description,
+			Standard:    ParamStd2013,
+		}
+	}
+}
+
+// RegisterParams 注册厂商自定义终端参数，可在运行时调用，覆盖同id的已有定义
+func RegisterParams(defs ...ParamDef) {
+	paramDefMtx.Lock()
+	defer paramDefMtx.Unlock()
+
+	for idx := range defs {
+		def := defs[idx]
+		paramDefs[def.ID] = &def
+	}
+}
+
+// LookupParam 获取终端参数定义
+func LookupParam(id uint32) (ParamDef, bool) {
+	paramDefMtx.RLock()
+	defer paramDefMtx.RUnlock()
+
+	if def, ok := paramDefs[id]; ok {
+		return *def, true
+	}
+	return ParamDef{}, false
+}
+
+// ParamDefs 获取全部终端参数定义，按id升序排列
+func ParamDefs() []ParamDef {
+	paramDefMtx.RLock()
+	defs := make([]ParamDef, 0, len(paramDefs))
+	for _, def := range paramDefs {
+		defs = append(defs, *def)
+	}
+	paramDefMtx.RUnlock()
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].ID < defs[j].ID
+	})
+	return defs
+}
+
+// paramTypeName 获取终端参数的编码类型，未定义的参数返回空
+func paramTypeName(id uint32) string {
+	paramDefMtx.RLock()
+	defer paramDefMtx.RUnlock()
+
+	if def, ok := paramDefs[id]; ok {
+		return def.Type
+	}
+	return ""
+}
+
+// NewParam 按参数定义新建终端参数项，value可为任意整数类型，按定义转换为对应宽度；
+// []byte类型的值总是按原始字节编码，用于透传未知格式的参数
+func NewParam(id uint32, value interface{}) (Param, error) {
+	value, err := convertParamValue(paramTypeName(id), value)
+	if nil != err {
+		return Param{}, fmt.Errorf("param %#04x: %s", id, err)
+	}
+	data, err := encodeParamValue(paramTypeName(id), value)
+	if nil != err {
+		return Param{}, fmt.Errorf("param %#04x: %s", id, err)
+	}
+	return Param{ID: id, Len: byte(len(data)), Value: value}, nil
+}
+
+// convertParamValue 将参数值转换为参数定义的值类型，结构化参数统一为指针
+func convertParamValue(tpName string, value interface{}) (interface{}, error) {
+	if bts, ok := value.([]byte); ok {
+		return bts, nil
+	}
+
+	switch tpName {
+	case "uint8", "uint16", "uint32":
+		bits := map[string]int{"uint8": 8, "uint16": 16, "uint32": 32}[tpName]
+		v, err := paramUint(value, bits)
+		if nil != err {
+			return nil, err
+		}
+		switch bits {
+		case 8:
+			return uint8(v), nil
+		case 16:
+			return uint16(v), nil
+		default:
+			return uint32(v), nil
+		}
+	case "string":
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case "[]uint8":
+	case "":
+		// 未定义的参数按值类型编码
+		switch value.(type) {
+		case uint8, uint16, uint32, string:
+			return value, nil
+		}
+	default:
+		if w, ok := paramStructOf(value); ok && paramStructName(w) == tpName {
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot use %T as %s", value, paramTypeLabel(tpName))
+}
+
+// paramTypeLabel 编码类型的说明，未定义的参数为unknown
+func paramTypeLabel(tpName string) string {
+	if "" == tpName {
+		return "unknown"
+	}
+	return tpName
+}
+
+// paramUint 将整数或整数值的浮点数转换为无符号整数，超出bits位时返回错误
+func paramUint(value interface{}, bits int) (uint64, error) {
+	v := reflect.ValueOf(value)
+	var u uint64
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u = v.Uint()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return 0, fmt.Errorf("negative value %d", v.Int())
+		}
+		u = uint64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f < 0 || f != math.Trunc(f) || f > math.MaxUint64 {
+			return 0, fmt.Errorf("invalid integer %v", f)
+		}
+		u = uint64(f)
+	default:
+		return 0, fmt.Errorf("cannot use %T as uint%d", value, bits)
+	}
+	if u > uint64(1)<<uint(bits)-1 {
+		return 0, fmt.Errorf("value %d overflows uint%d", u, bits)
+	}
+	return u, nil
+}
+
+// decodeParamValue 按编码类型解码参数值，未定义的参数、长度与类型不符时保留原始字节
+func decodeParamValue(tpName string, data []byte) interface{} {
+	switch tpName {
+	case "uint8":
+		if 1 == len(data) {
+			return data[0]
+		}
+	case "uint16":
+		if 2 == len(data) {
+			return binary.BigEndian.Uint16(data)
+		}
+	case "uint32":
+		if 4 == len(data) {
+			return binary.BigEndian.Uint32(data)
+		}
+	case "string":
+		_, bts, _ := mahonia.NewDecoder("gbk").Translate(data, true)
+		return string(bts)
+	default:
+		if w := newParamStruct(tpName); nil != w && validParamStruct(tpName, data) {
+			w.readBy(bytes.NewBuffer(data))
+			return w
+		}
+	}
+	return append([]byte(nil), data...)
+}
+
+// encodeParamValue 按编码类型编码参数值，[]byte类型的值按原始字节编码
+func encodeParamValue(tpName string, value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	switch v := value.(type) {
+	case []byte:
+		buf.Write(v)
+	case string:
+		if "string" != tpName && "" != tpName {
+			return nil, fmt.Errorf("cannot use string as %s", tpName)
+		}
+		buf.WriteString(mahonia.NewEncoder("gbk").ConvertString(v))
+	default:
+		if w, ok := paramStructOf(value); ok {
+			if "" != tpName && paramStructName(w) != tpName {
+				return nil, fmt.Errorf("cannot use %T as %s", value, tpName)
+			}
+			w.writeTo(&buf)
+			break
+		}
+
+		if "" == tpName {
+			switch value.(type) {
+			case uint8:
+				tpName = "uint8"
+			case uint16:
+				tpName = "uint16"
+			case uint32:
+				tpName = "uint32"
+			}
+		}
+		bits := map[string]int{"uint8": 8, "uint16": 16, "uint32": 32}[tpName]
+		if 0 == bits {
+			return nil, fmt.Errorf("cannot use %T as %s", value, paramTypeLabel(tpName))
+		}
+		u, err := paramUint(value, bits)
+		if nil != err {
+			return nil, err
+		}
+		bts := make([]byte, 8)
+		binary.BigEndian.PutUint64(bts, u)
+		buf.Write(bts[8-bits/8:])
+	}
+
+	if buf.Len() > math.MaxUint8 {
+		return nil, fmt.Errorf("value too long: %d bytes", buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// Params 终端参数项列表，提供按参数id读写的方法
+type Params []Param
+
+// Get 获取参数项
+func (p Params) Get(id uint32) (Param, bool) {
+	for _, param := range p {
+		if id == param.ID {
+			return param, true
+		}
+	}
+	return Param{}, false
+}
+
+// Uint8 获取BYTE类型的参数值
+func (p Params) Uint8(id uint32) (uint8, bool) {
+	param, _ := p.Get(id)
+	v, ok := param.Value.(uint8)
+	return v, ok
+}
+
+// Uint16 获取WORD类型的参数值，BYTE类型的值按无符号扩展
+func (p Params) Uint16(id uint32) (uint16, bool) {
+	param, _ := p.Get(id)
+	switch v := param.Value.(type) {
+	case uint8:
+		return uint16(v), true
+	case uint16:
+		return v, true
+	}
+	return 0, false
+}
+
+// Uint32 获取DWORD类型的参数值，BYTE、WORD类型的值按无符号扩展
+func (p Params) Uint32(id uint32) (uint32, bool) {
+	param, _ := p.Get(id)
+	switch v := param.Value.(type) {
+	case uint8:
+		return uint32(v), true
+	case uint16:
+		return uint32(v), true
+	case uint32:
+		return v, true
+	}
+	return 0, false
+}
+
+// String 获取STRING类型的参数值
+func (p Params) String(id uint32) (string, bool) {
+	param, _ := p.Get(id)
+	v, ok := param.Value.(string)
+	return v, ok
+}
+
+// Bytes 获取原始字节参数值，包括未定义的参数及长度与定义不符的参数
+func (p Params) Bytes(id uint32) ([]byte, bool) {
+	param, _ := p.Get(id)
+	v, ok := param.Value.([]byte)
+	return v, ok
+}
+
+// Value 获取结构化参数值，如*AVParam、*TPMSParam，target为对应类型的指针
+//
+//	var av protocol.AVParam
+//	ok := params.Value(protocol.ParamIDAVParam, &av)
+func (p Params) Value(id uint32, target interface{}) bool {
+	param, _ := p.Get(id)
+	w, ok := paramStructOf(param.Value)
+	if !ok {
+		return false
+	}
+	dst := reflect.ValueOf(target)
+	src := reflect.ValueOf(w)
+	if reflect.Ptr != dst.Kind() || dst.IsNil() || dst.Type() != src.Type() {
+		return false
+	}
+	dst.Elem().Set(src.Elem())
+	return true
+}
+
+// Set 按参数定义设置参数值，已存在时替换，否则追加，值类型的转换规则见NewParam
+func (p *Params) Set(id uint32, value interface{}) error {
+	param, err := NewParam(id, value)
+	if nil != err {
+		return err
+	}
+	for idx := range *p {
+		if id == (*p)[idx].ID {
+			(*p)[idx] = param
+			return nil
+		}
+	}
+	*p = append(*p, param)
+	return nil
+}
+
+// IDs 获取参数id列表
+func (p Params) IDs() []uint32 {
+	ids := make([]uint32, 0, len(p))
+	for _, param := range p {
+		ids = append(ids, param.ID)
+	}
+	return ids
+}
+
+// Diff 获取与current中取值不同或current中不存在的参数项，按编码后的字节比较
+func (p Params) Diff(current Params) Params {
+	var diff Params
+	for _, param := range p {
+		old, ok := current.Get(param.ID)
+		if !ok || !param.equal(old) {
+			diff = append(diff, param)
+		}
+	}
+	return diff
+}
+
+// equal 编码后的参数值是否相同，不能编码的参数值视为不同
+func (p Param) equal(o Param) bool {
+	tpName := paramTypeName(p.ID)
+	a, err := encodeParamValue(tpName, p.Value)
+	if nil != err {
+		return false
+	}
+	b, err := encodeParamValue(tpName, o.Value)
+	return nil == err && p.ID == o.ID && bytes.Equal(a, b)
+}
+
+// paramStruct 结构化参数值
+type paramStruct interface {
+	readBy(buf *bytes.Buffer)
+	writeTo(buf *bytes.Buffer)
+}
+
+// newParamStruct 按类型名称新建结构化参数值，非结构化类型返回空
+func newParamStruct(tpName string) paramStruct {
+	switch tpName {
+	case "AVParam":
+		return &AVParam{}
+	case "AVChannelList":
+		return &AVChannelList{}
+	case "VideoChannelParams":
+		return &VideoChannelParams{}
+	case "AlarmRecordParam":
+		return &AlarmRecordParam{}
+	case "ImageAnalysisParam":
+		return &ImageAnalysisParam{}
+	case "WakeUpParam":
+		return &WakeUpParam{}
+	case "ADASParam":
+		return &ADASParam{}
+	case "DSMParam":
+		return &DSMParam{}
+	case "TPMSParam":
+		return &TPMSParam{}
+	case "BSDParam":
+		return &BSDParam{}
+	default:
+		return nil
+	}
+}
+
+// paramStructName 结构化参数值的类型名称
+func paramStructName(w paramStruct) string {
+	return reflect.TypeOf(w).Elem().Name()
+}
+
+// paramStructOf 获取结构化参数值，值类型转换为指针，JSON反序列化得到的是值类型
+func paramStructOf(value interface{}) (paramStruct, bool) {
+	if w, ok := value.(paramStruct); ok {
+		return w, !reflect.ValueOf(w).IsNil()
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || reflect.Struct != v.Kind() {
+		return nil, false
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	w, ok := ptr.Interface().(paramStruct)
+	return w, ok
+}
+
+// 定长结构化参数的字节数
+var paramStructSize = map[string]int{
+	"AVParam":            21,
+	"AlarmRecordParam":   3,
+	"ImageAnalysisParam": 2,
+	"WakeUpParam":        20,
+	"ADASParam":          56,
+	"DSMParam":           49,
+	"TPMSParam":          46,
+	"BSDParam":           2,
+}
+
+// validParamStruct 检查参数内容的长度是否与结构化参数类型一致
+func validParamStruct(tpName string, data []byte) bool {
+	if size, ok := paramStructSize[tpName]; ok {
+		return size == len(data)
+	}
+	switch tpName {
+	case "AVChannelList":
+		return len(data) >= 3 && len(data) == 3+avChannelSize*(int(data[0])+int(data[1])+int(data[2]))
+	case "VideoChannelParams":
+		return len(data) >= 1 && len(data) == 1+videoChannelParamSize*int(data[0])
+	}
+	return false
+}
+
+// AVEncoding 音视频流编码参数
+type AVEncoding struct {
+	// 编码模式，0：CBR（固定码率）；1：VBR（可变码率）；2：ABR（平均码率）
+	Mode byte `json:"mode"`
+	// 分辨率，0：QCIF；1：CIF；2：WCIF；3：D1；4：WD1；5：720P；6：1080P
+	Resolution byte `json:"resolution"`
+	// 关键帧间隔，单位帧，1~1000
+	KeyFrameInterval uint16 `json:"key_frame_interval"`
+	// 目标帧率，单位帧/s，1~120
+	FrameRate byte `json:"frame_rate"`
+	// 目标码率，单位kbps
+	BitRate uint32 `json:"bit_rate"`
+}
+
+func (e *AVEncoding) readBy(buf *bytes.Buffer) {
+	e.Mode, _ = buf.ReadByte()
+	e.Resolution, _ = buf.ReadByte()
+	e.KeyFrameInterval = binary.BigEndian.Uint16(buf.Next(2))
+	e.FrameRate, _ = buf.ReadByte()
+	e.BitRate = binary.BigEndian.Uint32(buf.Next(4))
+}
+
+func (e *AVEncoding) writeTo(buf *bytes.Buffer) {
+	buf.WriteByte(e.Mode)
+	buf.WriteByte(e.Resolution)
+	binary.Write(buf, binary.BigEndian, e.KeyFrameInterval)
+	buf.WriteByte(e.FrameRate)
+	binary.Write(buf, binary.BigEndian, e.BitRate)
+}
+
+// AVParam 音视频参数（0x0075）
+type AVParam struct {
+	// 实时流编码参数
+	Realtime AVEncoding `json:"realtime"`
+	// 存储流编码参数
+	Store AVEncoding `json:"store"`
+	// OSD字幕叠加设置，bit0：日期和时间；bit1：车牌号码；bit2：逻辑通道号；bit3：经纬度；
+	// bit4：行驶记录速度；bit5：卫星定位速度；bit6：连续驾驶时间
+	OSD uint16 `json:"osd"`
+	// 是否启用音频输出，0：不启用；1：启用
+	AudioOutput byte `json:"audio_output"`
+}
+
+func (p *AVParam) readBy(buf *bytes.Buffer) {
+	p.Realtime.readBy(buf)
+	p.Store.readBy(buf)
+	p.OSD = binary.BigEndian.Uint16(buf.Next(2))
+	p.AudioOutput, _ = buf.ReadByte()
+}
+
+func (p *AVParam) writeTo(buf *bytes.Buffer) {
+	p.Realtime.writeTo(buf)
+	p.Store.writeTo(buf)
+	binary.Write(buf, binary.BigEndian, p.OSD)
+	buf.WriteByte(p.AudioOutput)
+}
+
+// 音视频通道对照表项的字节数
+const avChannelSize = 4
+
+// AVChannel 音视频通道对照表项
+type AVChannel struct {
+	// 物理通道号，从1开始
+	Physical byte `json:"physical"`
+	// 逻辑通道号，按照JT/T 1078表2
+	Logical byte `json:"logical"`
+	// 通道类型，0：音视频；1：音频；2：视频
+	Type byte `json:"type"`
+	// 是否连接云台，类型为0和2时有效，0：未连接；1：连接
+	PTZ byte `json:"ptz"`
+}
+
+// AVChannelList 音视频通道列表（0x0076）
+type AVChannelList struct {
+	// 音视频通道总数
+	AVCount byte `json:"av_count"`
+	// 音频通道总数
+	AudioCount byte `json:"audio_count"`
+	// 视频通道总数
+	VideoCount byte `json:"video_count"`
+	// 音视频通道对照表，个数为三类通道总数之和
+	Channels []AVChannel `json:"channels"`
+}
+
+func (l *AVChannelList) readBy(buf *bytes.Buffer) {
+	l.AVCount, _ = buf.ReadByte()
+	l.AudioCount, _ = buf.ReadByte()
+	l.VideoCount, _ = buf.ReadByte()
+	l.Channels = make([]AVChannel, 0, buf.Len()/avChannelSize)
+	for buf.Len() >= avChannelSize {
+		data := buf.Next(avChannelSize)
+		l.Channels = append(l.Channels, AVChannel{Physical: data[0], Logical: data[1], Type: data[2], PTZ: data[3]})
+	}
+}
+
+func (l *AVChannelList) writeTo(buf *bytes.Buffer) {
+	buf.WriteByte(l.AVCount)
+	buf.WriteByte(l.AudioCount)
+	buf.WriteByte(l.VideoCount)
+	for _, c := range l.Channels {
+		buf.Write([]byte{c.Physical, c.Logical, c.Type, c.PTZ})
+	}
+}
+
+// 单独视频通道参数项的字节数
+const videoChannelParamSize = 21
+
+// VideoChannelParam 单独视频通道参数
+type VideoChannelParam struct {
+	// 逻辑通道号
+	Channel byte `json:"channel"`
+	// 实时流编码参数
+	Realtime AVEncoding `json:"realtime"`
+	// 存储流编码参数
+	Store AVEncoding `json:"store"`
+	// OSD字幕叠加设置，同AVParam
+	OSD uint16 `json:"osd"`
+}
+
+// VideoChannelParams 单独视频通道参数设置（0x0077）
+type VideoChannelParams struct {
+	// 需单独设置视频参数的通道列表
+	Channels []VideoChannelParam `json:"channels"`
+}
+
+func (p *VideoChannelParams) readBy(buf *bytes.Buffer) {
+	count, _ := buf.ReadByte()
+	p.Channels = make([]VideoChannelParam, 0, count)
+	for i := byte(0); i < count && buf.Len() >= videoChannelParamSize; i++ {
+		var c VideoChannelParam
+		c.Channel, _ = buf.ReadByte()
+		c.Realtime.readBy(buf)
+		c.Store.readBy(buf)
+		c.OSD = binary.BigEndian.Uint16(buf.Next(2))
+		p.Channels = append(p.Channels, c)
+	}
+}
+
+func (p *VideoChannelParams) writeTo(buf *bytes.Buffer) {
+	buf.WriteByte(byte(len(p.Channels)))
+	for idx := range p.Channels {
+		c := &p.Channels[idx]
+		buf.WriteByte(c.Channel)
+		c.Realtime.writeTo(buf)
+		c.Store.writeTo(buf)
+		binary.Write(buf, binary.BigEndian, c.OSD)
+	}
+}
+
+// AlarmRecordParam 特殊报警录像参数（0x0079）
+type AlarmRecordParam struct {
+	// 特殊报警录像存储阈值，占用主存储器存储阈值的百分比，1~99，默认20
+	Threshold byte `json:"threshold"`
+	// 特殊报警录像持续时间，单位分钟，默认5
+	Duration byte `json:"duration"`
+	// 特殊报警标识起始时间，报警发生前进行标记的录像时间，单位分钟，默认1
+	Before byte `json:"before"`
+}
+
+func (p *AlarmRecordParam) readBy(buf *bytes.Buffer) {
+	p.Threshold, _ = buf.ReadByte()
+	p.Duration, _ = buf.ReadByte()
+	p.Before, _ = buf.ReadByte()
+}
+
+func (p *AlarmRecordParam) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{p.Threshold, p.Duration, p.Before})
+}
+
+// ImageAnalysisParam 图像分析报警参数（0x007B）
+type ImageAnalysisParam struct {
+	// 车辆核载人数，1~100
+	Passengers byte `json:"passengers"`
+	// 疲劳程度阈值，超过阈值时产生疲劳驾驶报警
+	Fatigue byte `json:"fatigue"`
+}
+
+func (p *ImageAnalysisParam) readBy(buf *bytes.Buffer) {
+	p.Passengers, _ = buf.ReadByte()
+	p.Fatigue, _ = buf.ReadByte()
+}
+
+func (p *ImageAnalysisParam) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{p.Passengers, p.Fatigue})
+}
+
+// WakeUpPeriod 定时唤醒时间段，时间为HHMM格式
+type WakeUpPeriod struct {
+	// 启动时间
+	Start string `json:"start"`
+	// 关闭时间
+	End string `json:"end"`
+}
+
+// WakeUpParam 终端休眠唤醒模式（0x007C）
+type WakeUpParam struct {
+	// 休眠唤醒模式，bit0：条件唤醒；bit1：定时唤醒；bit2：手动唤醒
+	Mode byte `json:"mode"`
+	// 唤醒条件类型，bit0：紧急报警；bit1：碰撞侧翻报警；bit2：车辆开门
+	Condition byte `json:"condition"`
+	// 定时唤醒日设置，bit0~bit6：周一~周日
+	Days byte `json:"days"`
+	// 定时唤醒启用标志，bit0~bit3：时间段1~4
+	Enabled byte `json:"enabled"`
+	// 定时唤醒时间段1~4
+	Periods [4]WakeUpPeriod `json:"periods"`
+}
+
+func (p *WakeUpParam) readBy(buf *bytes.Buffer) {
+	p.Mode, _ = buf.ReadByte()
+	p.Condition, _ = buf.ReadByte()
+	p.Days, _ = buf.ReadByte()
+	p.Enabled, _ = buf.ReadByte()
+	for idx := range p.Periods {
+		p.Periods[idx].Start = fmt.Sprintf("%04d", readBCDUint(buf, 2))
+		p.Periods[idx].End = fmt.Sprintf("%04d", readBCDUint(buf, 2))
+	}
+}
+
+func (p *WakeUpParam) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{p.Mode, p.Condition, p.Days, p.Enabled})
+	for _, period := range p.Periods {
+		for _, value := range []string{period.Start, period.End} {
+			var hhmm uint32
+			fmt.Sscanf(value, "%d", &hhmm)
+			writeBCDUint(buf, hhmm, 2)
+		}
+	}
+}
+
+// SafetyBaseParam ADAS与DSM参数的公共部分，0xFF表示不修改
+type SafetyBaseParam struct {
+	// 报警判断速度阈值，单位km/h，高于阈值才报警
+	Speed byte `json:"speed"`
+	// 报警提示音量，0~8
+	Volume byte `json:"volume"`
+	// 主动拍照策略，0：不开启；1：定时拍照；2：定距拍照；3：保留
+	PhotoStrategy byte `json:"photo_strategy"`
+	// 主动定时拍照时间间隔，单位秒
+	PhotoTimeInterval uint16 `json:"photo_time_interval"`
+	// 主动定距拍照距离间隔，单位米
+	PhotoDistanceInterval uint16 `json:"photo_distance_interval"`
+	// 单次主动拍照张数，1~10
+	PhotoCount byte `json:"photo_count"`
+	// 单次主动拍照时间间隔，单位100ms
+	PhotoInterval byte `json:"photo_interval"`
+	// 拍照分辨率，0x01：352×288；0x02：704×288；0x03：704×576；0x04：640×480；0x05：1280×720；0x06：1920×1080
+	PhotoResolution byte `json:"photo_resolution"`
+	// 视频录制分辨率，0x01：CIF；0x02：HD1；0x03：D1；0x04：WD1；0x05：VGA；0x06：720P；0x07：1080P
+	VideoResolution byte `json:"video_resolution"`
+	// 报警使能，按位表示各报警的一级、二级报警
+	AlarmEnable uint32 `json:"alarm_enable"`
+	// 事件使能，按位表示各事件
+	EventEnable uint32 `json:"event_enable"`
+}
+
+func (p *SafetyBaseParam) readBy(buf *bytes.Buffer) {
+	p.Speed, _ = buf.ReadByte()
+	p.Volume, _ = buf.ReadByte()
+	p.PhotoStrategy, _ = buf.ReadByte()
+	p.PhotoTimeInterval = binary.BigEndian.Uint16(buf.Next(2))
+	p.PhotoDistanceInterval = binary.BigEndian.Uint16(buf.Next(2))
+	p.PhotoCount, _ = buf.ReadByte()
+	p.PhotoInterval, _ = buf.ReadByte()
+	p.PhotoResolution, _ = buf.ReadByte()
+	p.VideoResolution, _ = buf.ReadByte()
+	p.AlarmEnable = binary.BigEndian.Uint32(buf.Next(4))
+	p.EventEnable = binary.BigEndian.Uint32(buf.Next(4))
+}
+
+func (p *SafetyBaseParam) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{p.Speed, p.Volume, p.PhotoStrategy})
+	binary.Write(buf, binary.BigEndian, p.PhotoTimeInterval)
+	binary.Write(buf, binary.BigEndian, p.PhotoDistanceInterval)
+	buf.Write([]byte{p.PhotoCount, p.PhotoInterval, p.PhotoResolution, p.VideoResolution})
+	binary.Write(buf, binary.BigEndian, p.AlarmEnable)
+	binary.Write(buf, binary.BigEndian, p.EventEnable)
+}
+
+// SafetyCapture 单项报警的录像与拍照设置
+type SafetyCapture struct {
+	// 报警前后视频录制时间，单位秒，0表示不录像
+	Video byte `json:"video"`
+	// 报警拍照张数，0表示不抓拍
+	Photos byte `json:"photos"`
+	// 报警拍照间隔，单位100ms
+	PhotoInterval byte `json:"photo_interval"`
+}
+
+func (c *SafetyCapture) readBy(buf *bytes.Buffer) {
+	c.Video, _ = buf.ReadByte()
+	c.Photos, _ = buf.ReadByte()
+	c.PhotoInterval, _ = buf.ReadByte()
+}
+
+func (c *SafetyCapture) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{c.Video, c.Photos, c.PhotoInterval})
+}
+
+// ADASParam 高级驾驶辅助系统参数（0xF364），见JSATL12表4-10
+type ADASParam struct {
+	SafetyBaseParam
+	// 障碍物报警距离阈值，单位100ms
+	ObstacleDistance byte `json:"obstacle_distance"`
+	// 障碍物报警分级速度阈值，单位km/h
+	ObstacleSpeed byte `json:"obstacle_speed"`
+	// 障碍物报警录像与拍照
+	Obstacle SafetyCapture `json:"obstacle"`
+	// 频繁变道报警判断时间段，单位秒
+	LaneChangePeriod byte `json:"lane_change_period"`
+	// 频繁变道报警判断次数
+	LaneChangeCount byte `json:"lane_change_count"`
+	// 频繁变道报警分级速度阈值，单位km/h
+	LaneChangeSpeed byte `json:"lane_change_speed"`
+	// 频繁变道报警录像与拍照
+	LaneChange SafetyCapture `json:"lane_change"`
+	// 车道偏离报警分级速度阈值，单位km/h
+	LaneDepartureSpeed byte `json:"lane_departure_speed"`
+	// 车道偏离报警录像与拍照
+	LaneDeparture SafetyCapture `json:"lane_departure"`
+	// 前向碰撞报警时间阈值，单位100ms
+	ForwardCollisionTime byte `json:"forward_collision_time"`
+	// 前向碰撞报警分级速度阈值，单位km/h
+	ForwardCollisionSpeed byte `json:"forward_collision_speed"`
+	// 前向碰撞报警录像与拍照
+	ForwardCollision SafetyCapture `json:"forward_collision"`
+	// 行人碰撞报警时间阈值，单位100ms
+	PedestrianCollisionTime byte `json:"pedestrian_collision_time"`
+	// 行人碰撞报警使能速度阈值，单位km/h
+	PedestrianCollisionSpeed byte `json:"pedestrian_collision_speed"`
+	// 行人碰撞报警录像与拍照
+	PedestrianCollision SafetyCapture `json:"pedestrian_collision"`
+	// 车距监控报警距离阈值，单位100ms
+	HeadwayDistance byte `json:"headway_distance"`
+	// 车距监控报警分级速度阈值，单位km/h
+	HeadwaySpeed byte `json:"headway_speed"`
+	// 车距过近报警录像与拍照
+	Headway SafetyCapture `json:"headway"`
+	// 道路标志识别拍照张数
+	RoadSignPhotos byte `json:"road_sign_photos"`
+	// 道路标志识别拍照间隔，单位100ms
+	RoadSignPhotoInterval byte `json:"road_sign_photo_interval"`
+}
+
+func (p *ADASParam) readBy(buf *bytes.Buffer) {
+	p.SafetyBaseParam.readBy(buf)
+	// 保留字段
+	buf.Next(1)
+	p.ObstacleDistance, _ = buf.ReadByte()
+	p.ObstacleSpeed, _ = buf.ReadByte()
+	p.Obstacle.readBy(buf)
+	p.LaneChangePeriod, _ = buf.ReadByte()
+	p.LaneChangeCount, _ = buf.ReadByte()
+	p.LaneChangeSpeed, _ = buf.ReadByte()
+	p.LaneChange.readBy(buf)
+	p.LaneDepartureSpeed, _ = buf.ReadByte()
+	p.LaneDeparture.readBy(buf)
+	p.ForwardCollisionTime, _ = buf.ReadByte()
+	p.ForwardCollisionSpeed, _ = buf.ReadByte()
+	p.ForwardCollision.readBy(buf)
+	p.PedestrianCollisionTime, _ = buf.ReadByte()
+	p.PedestrianCollisionSpeed, _ = buf.ReadByte()
+	p.PedestrianCollision.readBy(buf)
+	p.HeadwayDistance, _ = buf.ReadByte()
+	p.HeadwaySpeed, _ = buf.ReadByte()
+	p.Headway.readBy(buf)
+	p.RoadSignPhotos, _ = buf.ReadByte()
+	p.RoadSignPhotoInterval, _ = buf.ReadByte()
+	// 保留字段
+	buf.Next(4)
+}
+
+func (p *ADASParam) writeTo(buf *bytes.Buffer) {
+	p.SafetyBaseParam.writeTo(buf)
+	buf.Write([]byte{0, p.ObstacleDistance, p.ObstacleSpeed})
+	p.Obstacle.writeTo(buf)
+	buf.Write([]byte{p.LaneChangePeriod, p.LaneChangeCount, p.LaneChangeSpeed})
+	p.LaneChange.writeTo(buf)
+	buf.WriteByte(p.LaneDepartureSpeed)
+	p.LaneDeparture.writeTo(buf)
+	buf.Write([]byte{p.ForwardCollisionTime, p.ForwardCollisionSpeed})
+	p.ForwardCollision.writeTo(buf)
+	buf.Write([]byte{p.PedestrianCollisionTime, p.PedestrianCollisionSpeed})
+	p.PedestrianCollision.writeTo(buf)
+	buf.Write([]byte{p.HeadwayDistance, p.HeadwaySpeed})
+	p.Headway.writeTo(buf)
+	buf.Write([]byte{p.RoadSignPhotos, p.RoadSignPhotoInterval})
+	buf.Write(make([]byte, 4))
+}
+
+// DSMParam 驾驶员状态监测系统参数（0xF365），见JSATL12表4-11
+type DSMParam struct {
+	SafetyBaseParam
+	// 吸烟报警判断时间间隔，单位秒
+	SmokingInterval uint16 `json:"smoking_interval"`
+	// 接打电话报警判断时间间隔，单位秒
+	PhoneInterval uint16 `json:"phone_interval"`
+	// 疲劳驾驶报警分级速度阈值，单位km/h
+	FatigueSpeed byte `json:"fatigue_speed"`
+	// 疲劳驾驶报警录像与拍照
+	Fatigue SafetyCapture `json:"fatigue"`
+	// 接打电话报警分级速度阈值，单位km/h
+	PhoneSpeed byte `json:"phone_speed"`
+	// 接打电话报警录像与驾驶员面部特征拍照
+	Phone SafetyCapture `json:"phone"`
+	// 抽烟报警分级速度阈值，单位km/h
+	SmokingSpeed byte `json:"smoking_speed"`
+	// 抽烟报警录像与驾驶员面部特征拍照
+	Smoking SafetyCapture `json:"smoking"`
+	// 分神驾驶报警分级速度阈值，单位km/h
+	DistractionSpeed byte `json:"distraction_speed"`
+	// 分神驾驶报警录像与拍照
+	Distraction SafetyCapture `json:"distraction"`
+	// 驾驶行为异常分级速度阈值，单位km/h
+	AbnormalSpeed byte `json:"abnormal_speed"`
+	// 驾驶行为异常录像与拍照
+	Abnormal SafetyCapture `json:"abnormal"`
+	// 驾驶员身份识别触发，0：不开启；1：定时触发；2：定距触发；3：插卡开始行驶触发
+	DriverIdentify byte `json:"driver_identify"`
+}
+
+func (p *DSMParam) readBy(buf *bytes.Buffer) {
+	p.SafetyBaseParam.readBy(buf)
+	p.SmokingInterval = binary.BigEndian.Uint16(buf.Next(2))
+	p.PhoneInterval = binary.BigEndian.Uint16(buf.Next(2))
+	// 保留字段
+	buf.Next(3)
+	p.FatigueSpeed, _ = buf.ReadByte()
+	p.Fatigue.readBy(buf)
+	p.PhoneSpeed, _ = buf.ReadByte()
+	p.Phone.readBy(buf)
+	p.SmokingSpeed, _ = buf.ReadByte()
+	p.Smoking.readBy(buf)
+	p.DistractionSpeed, _ = buf.ReadByte()
+	p.Distraction.readBy(buf)
+	p.AbnormalSpeed, _ = buf.ReadByte()
+	p.Abnormal.readBy(buf)
+	p.DriverIdentify, _ = buf.ReadByte()
+	// 保留字段
+	buf.Next(2)
+}
+
+func (p *DSMParam) writeTo(buf *bytes.Buffer) {
+	p.SafetyBaseParam.writeTo(buf)
+	binary.Write(buf, binary.BigEndian, p.SmokingInterval)
+	binary.Write(buf, binary.BigEndian, p.PhoneInterval)
+	buf.Write(make([]byte, 3))
+	buf.WriteByte(p.FatigueSpeed)
+	p.Fatigue.writeTo(buf)
+	buf.WriteByte(p.PhoneSpeed)
+	p.Phone.writeTo(buf)
+	buf.WriteByte(p.SmokingSpeed)
+	p.Smoking.writeTo(buf)
+	buf.WriteByte(p.DistractionSpeed)
+	p.Distraction.writeTo(buf)
+	buf.WriteByte(p.AbnormalSpeed)
+	p.Abnormal.writeTo(buf)
+	buf.WriteByte(p.DriverIdentify)
+	buf.Write(make([]byte, 2))
+}
+
+// TPMSParam 胎压监测系统参数（0xF366）
+type TPMSParam struct {
+	// 轮胎规格型号，如195/65R15 91V，最长12字节
+	Model string `json:"model"`
+	// 胎压单位，0：kg/cm²；1：bar；2：kPa；3：PSI
+	PressureUnit uint16 `json:"pressure_unit"`
+	// 正常胎压值
+	Normal uint16 `json:"normal"`
+	// 胎压不平衡报警阈值，单位%
+	Unbalance uint16 `json:"unbalance"`
+	// 慢漏气报警阈值，单位%
+	SlowLeak uint16 `json:"slow_leak"`
+	// 低压报警阈值
+	Low uint16 `json:"low"`
+	// 高压报警阈值
+	High uint16 `json:"high"`
+	// 高温报警阈值，单位℃
+	HighTemperature uint16 `json:"high_temperature"`
+	// 电压报警阈值，单位%
+	Voltage uint16 `json:"voltage"`
+	// 定时上报时间间隔，单位秒，0表示不上报
+	ReportInterval uint16 `json:"report_interval"`
+}
+
+func (p *TPMSParam) readBy(buf *bytes.Buffer) {
+	p.Model = readFixedString(buf, 12)
+	for _, value := range []*uint16{&p.PressureUnit, &p.Normal, &p.Unbalance, &p.SlowLeak, &p.Low, &p.High, &p.HighTemperature, &p.Voltage, &p.ReportInterval} {
+		*value = binary.BigEndian.Uint16(buf.Next(2))
+	}
+	// 保留字段
+	buf.Next(6)
+}
+
+func (p *TPMSParam) writeTo(buf *bytes.Buffer) {
+	writeFixedString(buf, p.Model, 12)
+	for _, value := range []uint16{p.PressureUnit, p.Normal, p.Unbalance, p.SlowLeak, p.Low, p.High, p.HighTemperature, p.Voltage, p.ReportInterval} {
+		binary.Write(buf, binary.BigEndian, value)
+	}
+	buf.Write(make([]byte, 6))
+}
+
+// BSDParam 盲区监测系统参数（0xF367）
+type BSDParam struct {
+	// 后方接近报警时间阈值，单位秒，1~10
+	Rear byte `json:"rear"`
+	// 侧后方接近报警时间阈值，单位秒，1~10
+	SideRear byte `json:"side_rear"`
+}
+
+func (p *BSDParam) readBy(buf *bytes.Buffer) {
+	p.Rear, _ = buf.ReadByte()
+	p.SideRear, _ = buf.ReadByte()
+}
+
+func (p *BSDParam) writeTo(buf *bytes.Buffer) {
+	buf.Write([]byte{p.Rear, p.SideRear})
+}
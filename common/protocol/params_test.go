@@ -0,0 +1,187 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func encodeParams(params Params) []byte {
+	msg := NewMsgTerParamsSettings()
+	msg.Params = params
+	var buf bytes.Buffer
+	msg.writeTo(&buf)
+	return buf.Bytes()
+}
+
+func TestParamDefs(t *testing.T) {
+	for _, id := range []uint32{0x0075, 0x0076, 0x0077, 0x0079, 0x007A, 0x007B, 0x007C, 0xF364, 0xF365, 0xF366, 0xF367, 0x0023, 0x0032, 0x01FF} {
+		if _, ok := LookupParam(id); !ok {
+			t.Fatalf("param %#04x is not defined", id)
+		}
+	}
+	for _, def := range ParamDefs() {
+		if "" == def.Name || "" == def.Type || "" == def.Standard {
+			t.Fatalf("incomplete param definition: %+v", def)
+		}
+		if tp := def.Type; "uint8" != tp && "uint16" != tp && "uint32" != tp && "string" != tp && "[]uint8" != tp && nil == newParamStruct(tp) {
+			t.Fatalf("param %#04x has unknown type %s", def.ID, tp)
+		}
+	}
+}
+
+func TestParamsRoundTrip(t *testing.T) {
+	body := []byte{
+		0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x1E, // 心跳间隔30秒
+		0x00, 0x00, 0xAB, 0xCD, 0x03, 0x01, 0x02, 0x03, // 未定义的参数
+		0x00, 0x00, 0x00, 0x55, 0x02, 0x00, 0x64, // 最高速度长度与类型不符
+		0x00, 0x00, 0x00, 0x83, 0x04, 0xD4, 0xC1, 0x41, 0x31, // 车牌号粤A1
+		0x00, 0x00, 0x00, 0x76, 0x0B, 0x01, 0x00, 0x01, 0x01, 0x01, 0x00, 0x01, 0x02, 0x02, 0x02, 0x00, // 音视频通道列表
+		0x00, 0x00, 0xF3, 0x67, 0x02, 0x05, 0x03, // 盲区监测参数
+	}
+	var msg MsgGetTerminalParamsResp
+	msg.readBy(bytes.NewBuffer(append([]byte{0x00, 0x09, 6}, body...)))
+	if 6 != len(msg.Params) {
+		t.Fatalf("got %d params, want 6", len(msg.Params))
+	}
+
+	if v, ok := msg.Params.Uint32(ParamIDHeartbeat); !ok || 30 != v {
+		t.Fatalf("unexpected heartbeat: %v", v)
+	}
+	if raw, ok := msg.Params.Bytes(0xABCD); !ok || !bytes.Equal([]byte{1, 2, 3}, raw) {
+		t.Fatalf("unexpected unknown param: %v", raw)
+	}
+	if _, ok := msg.Params.Bytes(ParamIDMaxSpeed); !ok {
+		t.Fatalf("malformed param should keep raw bytes")
+	}
+	if v, _ := msg.Params.String(ParamIDLicencePlate); "粤A1" != v {
+		t.Fatalf("unexpected licence plate: %q", v)
+	}
+	var channels AVChannelList
+	if !msg.Params.Value(ParamIDAVChannelList, &channels) || 2 != len(channels.Channels) || 2 != channels.Channels[1].Type {
+		t.Fatalf("unexpected channel list: %+v", channels)
+	}
+	if got := encodeParams(msg.Params); !bytes.Equal(append([]byte{6}, body...), got) {
+		t.Fatalf("round trip:\ngot  % x\nwant % x", got, body)
+	}
+
+	// JSON序列化后可还原为相同的编码
+	data, err := json.Marshal(msg.Params)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var params Params
+	if err := json.Unmarshal(data, &params); nil != err {
+		t.Fatal(err)
+	}
+	if got := encodeParams(params); !bytes.Equal(append([]byte{6}, body...), got) {
+		t.Fatalf("json round trip:\ngot  % x\nwant % x", got, body)
+	}
+}
+
+func TestParamsSet(t *testing.T) {
+	var params Params
+	if err := params.Set(ParamIDMaxSpeed, 120); nil != err {
+		t.Fatal(err)
+	}
+	if err := params.Set(ParamIDPlateColor, 256); nil == err {
+		t.Fatal("overflow should fail")
+	}
+	if err := params.Set(ParamIDLicencePlate, 1); nil == err {
+		t.Fatal("type mismatch should fail")
+	}
+	av := AVParam{Realtime: AVEncoding{Resolution: 1, KeyFrameInterval: 25, FrameRate: 15, BitRate: 512}, AudioOutput: 1}
+	if err := params.Set(ParamIDAVParam, av); nil != err {
+		t.Fatal(err)
+	}
+	wake := WakeUpParam{Mode: 2, Enabled: 1}
+	for idx := range wake.Periods {
+		wake.Periods[idx] = WakeUpPeriod{Start: "0000", End: "0000"}
+	}
+	wake.Periods[0] = WakeUpPeriod{Start: "0830", End: "1800"}
+	if err := params.Set(ParamIDWakeUpMode, &wake); nil != err {
+		t.Fatal(err)
+	}
+	if err := params.Set(ParamIDMaxSpeed, uint8(100)); nil != err {
+		t.Fatal(err)
+	}
+
+	if 3 != len(params) || 4 != params[0].Len || 21 != params[1].Len || 20 != params[2].Len {
+		t.Fatalf("unexpected params: %+v", params)
+	}
+	if v, ok := params.Uint32(ParamIDMaxSpeed); !ok || 100 != v {
+		t.Fatalf("unexpected max speed: %v", v)
+	}
+
+	var msg MsgGetTerminalParamsResp
+	msg.readBy(bytes.NewBuffer(append([]byte{0x00, 0x01}, encodeParams(params)...)))
+	var got WakeUpParam
+	if !msg.Params.Value(ParamIDWakeUpMode, &got) || got != wake {
+		t.Fatalf("got %+v, want %+v", got, wake)
+	}
+	var gotAV AVParam
+	if !msg.Params.Value(ParamIDAVParam, &gotAV) || gotAV != av {
+		t.Fatalf("got %+v, want %+v", gotAV, av)
+	}
+}
+
+func TestSafetyParams(t *testing.T) {
+	adas := ADASParam{
+		SafetyBaseParam:  SafetyBaseParam{Speed: 30, Volume: 6, PhotoTimeInterval: 60, AlarmEnable: 0x0001FFFF},
+		ObstacleDistance: 30,
+		Obstacle:         SafetyCapture{Video: 5, Photos: 3, PhotoInterval: 2},
+		Headway:          SafetyCapture{Video: 5},
+		RoadSignPhotos:   2,
+	}
+	dsm := DSMParam{
+		SafetyBaseParam: SafetyBaseParam{Speed: 30, EventEnable: 3},
+		SmokingInterval: 180,
+		PhoneInterval:   120,
+		Fatigue:         SafetyCapture{Video: 5, Photos: 3, PhotoInterval: 2},
+		DriverIdentify:  3,
+	}
+	var params Params
+	if err := params.Set(ParamIDADAS, adas); nil != err {
+		t.Fatal(err)
+	}
+	if err := params.Set(ParamIDDMS, &dsm); nil != err {
+		t.Fatal(err)
+	}
+	if 56 != params[0].Len || 49 != params[1].Len {
+		t.Fatalf("unexpected params: %+v", params)
+	}
+
+	var msg MsgGetTerminalParamsResp
+	msg.readBy(bytes.NewBuffer(append([]byte{0x00, 0x01}, encodeParams(params)...)))
+	var gotADAS ADASParam
+	if !msg.Params.Value(ParamIDADAS, &gotADAS) || gotADAS != adas {
+		t.Fatalf("got %+v, want %+v", gotADAS, adas)
+	}
+	var gotDSM DSMParam
+	if !msg.Params.Value(ParamIDDMS, &gotDSM) || gotDSM != dsm {
+		t.Fatalf("got %+v, want %+v", gotDSM, dsm)
+	}
+}
+
+func TestParamsSettingsInvalid(t *testing.T) {
+	// 编码失败的参数不能以空值下发
+	msg := NewMsgTerParamsSettings()
+	msg.Params = Params{{ID: ParamIDHeartbeat, Value: uint32(30)}, {ID: ParamIDLicencePlate, Value: 1}}
+	if body, err := setTerminalParamsMarshal(msg, version2019); nil == err {
+		t.Fatalf("expected an error, got % x", body)
+	}
+}
+
+func TestParamsDiff(t *testing.T) {
+	var profile, current Params
+	profile.Set(ParamIDHeartbeat, 30)
+	profile.Set(ParamIDMaxSpeed, 60)
+	profile.Set(ParamIDBSD, BSDParam{Rear: 3, SideRear: 3})
+	current.Set(ParamIDHeartbeat, uint32(30))
+	current.Set(ParamIDMaxSpeed, 80)
+
+	diff := profile.Diff(current)
+	if ids := diff.IDs(); 2 != len(ids) || ParamIDMaxSpeed != ids[0] || ParamIDBSD != ids[1] {
+		t.Fatalf("unexpected diff: %v", ids)
+	}
+}
@@ -0,0 +1,145 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+)
+
+// PosAuxType 厂商自定义位置附加信息项类型
+type PosAuxType struct {
+	// 附加项id
+	ID byte
+	// 类型名称，与位置附加信息对照表一致，如uint8、uint16、string等；
+	// 设置了编解码函数时仅用于说明
+	Name string
+	// 解码附加项内容，data为附加项长度指定的全部字节，返回错误时保留原始字节
+	Decode func(data []byte) (interface{}, error)
+	// 编码附加项内容，返回错误时按内置类型编码
+	Encode func(value interface{}) ([]byte, error)
+	// 新建JSON反序列化的目标值，需返回指针，为空时按类型名称解析
+	New func() interface{}
+}
+
+var (
+	posAuxMtx   sync.RWMutex
+	posAuxTypes = make(map[byte]*PosAuxType)
+)
+
+// RegisterPosAuxs 注册厂商自定义位置附加信息项，可在运行时调用，覆盖同id的已有定义
+func RegisterPosAuxs(types ...*PosAuxType) {
+	posAuxMtx.Lock()
+	defer posAuxMtx.Unlock()
+
+	for _, tp := range types {
+		if nil == tp {
+			continue
+		}
+		posAuxTypes[tp.ID] = tp
+	}
+}
+
+// UnregisterPosAuxs 注销厂商自定义位置附加信息项，恢复内置定义
+func UnregisterPosAuxs(ids ...byte) {
+	posAuxMtx.Lock()
+	defer posAuxMtx.Unlock()
+
+	for _, id := range ids {
+		delete(posAuxTypes, id)
+	}
+}
+
+// lookupPosAuxType 获取自定义附加信息项类型
+func lookupPosAuxType(id byte) *PosAuxType {
+	posAuxMtx.RLock()
+	defer posAuxMtx.RUnlock()
+	return posAuxTypes[id]
+}
+
+// posAuxTypeName 获取附加信息项的类型名称，自定义类型优先
+func posAuxTypeName(id byte) string {
+	if tp := lookupPosAuxType(id); nil != tp && "" != tp.Name {
+		return tp.Name
+	}
+	return PosAuxMap[id]
+}
+
+// CustomAuxInfo 自定义信息区（0xE0），0xE0附加项内容为后续自定义信息的长度，
+// 自定义信息紧随其后，不计入0xE0附加项长度
+type CustomAuxInfo struct {
+	// 后续自定义信息，能按附加项格式完整解析时有效
+	Auxs []PositionAux `json:"auxs,omitempty"`
+	// 后续自定义信息的原始字节，不能按附加项格式解析时有效
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// readCustomAuxInfo 读取自定义信息区，data为0xE0附加项内容，长度字段不是1、2或4字节时返回原始字节
+func readCustomAuxInfo(data []byte, buf *bytes.Buffer) interface{} {
+	var length int
+	switch len(data) {
+	case 1:
+		length = int(data[0])
+	case 2:
+		length = int(binary.BigEndian.Uint16(data))
+	case 4:
+		length = int(binary.BigEndian.Uint32(data))
+	default:
+		return append([]byte(nil), data...)
+	}
+
+	area := buf.Next(length)
+	info := &CustomAuxInfo{}
+	if !isAuxList(area) {
+		info.Raw = append([]byte(nil), area...)
+		return info
+	}
+
+	areaBuf := bytes.NewBuffer(area)
+	for areaBuf.Len() > 0 {
+		var aux PositionAux
+		aux.ReadBy(areaBuf)
+		info.Auxs = append(info.Auxs, aux)
+	}
+	return info
+}
+
+// isAuxList 是否可以按附加项格式完整解析
+func isAuxList(bts []byte) bool {
+	off := 0
+	for off+1 < len(bts) {
+		// 嵌套的自定义信息区无法单独校验长度
+		if AuxIDCustomLength == bts[off] {
+			return false
+		}
+		off += int(bts[off+1]) + 2
+	}
+	return off == len(bts)
+}
+
+// writeTo 写入长度字段和后续自定义信息，width为长度字段的字节数，为0时按实际长度选择
+func (info *CustomAuxInfo) writeTo(width byte, buf *bytes.Buffer) {
+	var area bytes.Buffer
+	if len(info.Auxs) > 0 {
+		for idx := range info.Auxs {
+			info.Auxs[idx].WriteTo(&area)
+		}
+	} else {
+		area.Write(info.Raw)
+	}
+
+	switch {
+	case 1 == width && area.Len() <= 0xFF, 2 == width && area.Len() <= 0xFFFF, 4 == width:
+	case area.Len() <= 0xFF:
+		width = 1
+	case area.Len() <= 0xFFFF:
+		width = 2
+	default:
+		width = 4
+	}
+
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(area.Len()))
+	buf.WriteByte(width)
+	buf.Write(value[4-width:])
+	buf.Write(area.Bytes())
+}
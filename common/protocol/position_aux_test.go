@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// 位置基本信息28字节
+var positionBase = make([]byte, 28)
+
+func decodePosition(auxs []byte) Position {
+	var position Position
+	position.ReadBy(bytes.NewBuffer(append(append([]byte(nil), positionBase...), auxs...)))
+	return position
+}
+
+func encodeAuxs(position Position) []byte {
+	var buf bytes.Buffer
+	position.WriteTo(&buf)
+	return buf.Bytes()[len(positionBase):]
+}
+
+func TestPositionAuxRaw(t *testing.T) {
+	auxs := []byte{
+		0xF5, 0x03, 0x01, 0x02, 0x03, // 未知附加项
+		0x01, 0x02, 0x00, 0x10, // 里程长度与类型不符
+		0x12, 0x02, 0x01, 0x02, // 进出区域报警长度不足
+		0x31, 0x01, 0x0C,
+	}
+	position := decodePosition(auxs)
+	if 4 != len(position.Auxs) {
+		t.Fatalf("got %d auxs, want 4", len(position.Auxs))
+	}
+	if raw, ok := position.Auxs[0].Value.([]byte); !ok || !bytes.Equal([]byte{1, 2, 3}, raw) {
+		t.Fatalf("unexpected unknown aux: %+v", position.Auxs[0])
+	}
+	if raw, ok := position.Auxs[1].Value.([]byte); !ok || 2 != len(raw) {
+		t.Fatalf("unexpected mileage aux: %+v", position.Auxs[1])
+	}
+	if _, ok := position.Auxs[2].Value.([]byte); !ok {
+		t.Fatalf("unexpected local alarm aux: %+v", position.Auxs[2])
+	}
+	if byte(12) != position.Auxs[3].Value {
+		t.Fatalf("unexpected aux: %+v", position.Auxs[3])
+	}
+	if encoded := encodeAuxs(position); !bytes.Equal(auxs, encoded) {
+		t.Fatalf("got %x, want %x", encoded, auxs)
+	}
+}
+
+// vendorTemp 厂商自定义温度附加项
+type vendorTemp struct {
+	Channel byte  `json:"channel"`
+	Value   int16 `json:"value"`
+}
+
+func TestPositionAuxCustom(t *testing.T) {
+	RegisterPosAuxs(&PosAuxType{
+		ID:   0xE1,
+		Name: "vendorTemp",
+		Decode: func(data []byte) (interface{}, error) {
+			if 3 != len(data) {
+				return nil, errors.New("invalid length")
+			}
+			return &vendorTemp{Channel: data[0], Value: int16(data[1])<<8 | int16(data[2])}, nil
+		},
+		Encode: func(value interface{}) ([]byte, error) {
+			temp, ok := value.(*vendorTemp)
+			if !ok {
+				return nil, errors.New("invalid value")
+			}
+			return []byte{temp.Channel, byte(temp.Value >> 8), byte(temp.Value)}, nil
+		},
+		New: func() interface{} {
+			return &vendorTemp{}
+		},
+	}, &PosAuxType{ID: 0xE3, Name: "uint16"})
+	defer UnregisterPosAuxs(0xE1, 0xE3)
+
+	// 0xE0后续自定义信息长度为9
+	auxs := []byte{
+		0xE0, 0x01, 0x09,
+		0xE1, 0x03, 0x02, 0xFF, 0xF6,
+		0xE3, 0x02, 0x01, 0x00,
+		0x30, 0x01, 0x1F,
+	}
+	position := decodePosition(auxs)
+	if 2 != len(position.Auxs) {
+		t.Fatalf("got %d auxs, want 2", len(position.Auxs))
+	}
+	info, ok := position.Auxs[0].Value.(*CustomAuxInfo)
+	if !ok || 2 != len(info.Auxs) {
+		t.Fatalf("unexpected custom info: %+v", position.Auxs[0])
+	}
+	if temp, ok := info.Auxs[0].Value.(*vendorTemp); !ok || 2 != temp.Channel || -10 != temp.Value {
+		t.Fatalf("unexpected vendor aux: %+v", info.Auxs[0])
+	}
+	if uint16(0x100) != info.Auxs[1].Value {
+		t.Fatalf("unexpected vendor aux: %+v", info.Auxs[1])
+	}
+	if byte(0x1F) != position.Auxs[1].Value {
+		t.Fatalf("unexpected aux after custom info: %+v", position.Auxs[1])
+	}
+	if encoded := encodeAuxs(position); !bytes.Equal(auxs, encoded) {
+		t.Fatalf("got %x, want %x", encoded, auxs)
+	}
+
+	// JSON往返后编码结果不变
+	data, err := json.Marshal(position)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var decoded Position
+	if err := json.Unmarshal(data, &decoded); nil != err {
+		t.Fatal(err)
+	}
+	if encoded := encodeAuxs(decoded); !bytes.Equal(auxs, encoded) {
+		t.Fatalf("got %x, want %x", encoded, auxs)
+	}
+
+	// 自定义信息无法按附加项格式解析时保留原始字节
+	position = decodePosition([]byte{0xE0, 0x02, 0x00, 0x03, 0xAA, 0xBB, 0xCC})
+	if info, ok := position.Auxs[0].Value.(*CustomAuxInfo); !ok || !bytes.Equal([]byte{0xAA, 0xBB, 0xCC}, info.Raw) {
+		t.Fatalf("unexpected custom info: %+v", position.Auxs[0])
+	}
+	if encoded := encodeAuxs(position); !bytes.Equal([]byte{0xE0, 0x02, 0x00, 0x03, 0xAA, 0xBB, 0xCC}, encoded) {
+		t.Fatalf("unexpected encoding: %x", encoded)
+	}
+}
@@ -0,0 +1,839 @@
+package protocol
+
+import (
+	"bytes"
+	"common/protocol/util"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axgle/mahonia"
+)
+
+// 行驶记录仪命令字（GB/T 19056-2012）
+const (
+	RecorderCmdStandard        = byte(0x00) // 采集记录仪执行标准版本
+	RecorderCmdDriver          = byte(0x01) // 采集当前驾驶人信息
+	RecorderCmdTime            = byte(0x02) // 采集记录仪实时时间
+	RecorderCmdMileage         = byte(0x03) // 采集累计行驶里程
+	RecorderCmdPulse           = byte(0x04) // 采集记录仪脉冲系数
+	RecorderCmdVehicle         = byte(0x05) // 采集车辆信息
+	RecorderCmdStatusConfig    = byte(0x06) // 采集记录仪状态信号配置信息
+	RecorderCmdUniqueID        = byte(0x07) // 采集记录仪唯一性编号
+	RecorderCmdSpeed           = byte(0x08) // 采集指定的行驶速度记录
+	RecorderCmdPosition        = byte(0x09) // 采集指定的位置信息记录（360h）
+	RecorderCmdAccident        = byte(0x10) // 采集指定的事故疑点记录
+	RecorderCmdOvertime        = byte(0x11) // 采集指定的超时驾驶记录
+	RecorderCmdDriverLog       = byte(0x12) // 采集指定的驾驶人身份记录
+	RecorderCmdPowerLog        = byte(0x13) // 采集指定的外部供电记录
+	RecorderCmdParamLog        = byte(0x14) // 采集指定的参数修改记录
+	RecorderCmdSpeedLog        = byte(0x15) // 采集指定的速度状态日志
+	RecorderCmdSetVehicle      = byte(0x82) // 设置车辆信息
+	RecorderCmdSetInstallDate  = byte(0x83) // 设置记录仪初次安装日期
+	RecorderCmdSetStatusConfig = byte(0x84) // 设置状态量配置信息
+	RecorderCmdSetTime         = byte(0xC2) // 设置记录仪时间
+	RecorderCmdSetPulse        = byte(0xC3) // 设置记录仪脉冲系数
+	RecorderCmdSetMileage      = byte(0xC4) // 设置初始里程
+)
+
+// 行驶记录仪出错应答命令字
+const (
+	recorderCmdGatherError  = byte(0xFA) // 采集数据命令帧接收出错
+	recorderCmdSettingError = byte(0xFB) // 设置参数命令帧接收出错
+)
+
+var (
+	// 平台下发的命令帧起始字头
+	recorderRequestMagic = []byte{0xAA, 0x75}
+	// 记录仪应答的数据帧起始字头
+	recorderAnswerMagic = []byte{0x55, 0x7A}
+)
+
+var (
+	// ErrRecorderFrame 行驶记录仪数据帧格式错误
+	ErrRecorderFrame = errors.New("the bad recorder frame")
+	// ErrRecorderChecksum 行驶记录仪数据帧校验错误
+	ErrRecorderChecksum = errors.New("the recorder frame checksum error")
+	// ErrRecorderGather 记录仪应答采集命令帧接收出错
+	ErrRecorderGather = errors.New("the recorder failed to receive the gather command")
+	// ErrRecorderSetting 记录仪应答设置命令帧接收出错
+	ErrRecorderSetting = errors.New("the recorder failed to receive the setting command")
+)
+
+// 记录仪数据帧头长度：起始字头2字节、命令字1字节、数据块长度2字节、保留字1字节
+const recorderFrameHeadSize = 6
+
+// RecorderFrame 行驶记录仪数据帧
+type RecorderFrame struct {
+	// 命令字
+	CMD byte `json:"cmd"`
+	// 数据块
+	Data []byte `json:"data"`
+}
+
+// ParseRecorderFrame 解析记录仪应答的数据帧（55 7A），记录仪应答出错时返回ErrRecorderGather或ErrRecorderSetting
+func ParseRecorderFrame(bts []byte) (*RecorderFrame, error) {
+	if len(bts) < 3 || !bytes.HasPrefix(bts, recorderAnswerMagic) {
+		return nil, ErrRecorderFrame
+	}
+
+	// 出错应答只有起始字头、命令字、保留字和校验字
+	switch bts[2] {
+	case recorderCmdGatherError:
+		return nil, ErrRecorderGather
+	case recorderCmdSettingError:
+		return nil, ErrRecorderSetting
+	}
+
+	if len(bts) < recorderFrameHeadSize+1 {
+		return nil, ErrRecorderFrame
+	}
+
+	size := int(binary.BigEndian.Uint16(bts[3:]))
+	if len(bts) < recorderFrameHeadSize+size+1 {
+		return nil, ErrRecorderFrame
+	}
+	end := recorderFrameHeadSize + size
+	if recorderChecksum(bts[:end]) != bts[end] {
+		return nil, ErrRecorderChecksum
+	}
+
+	return &RecorderFrame{CMD: bts[2], Data: append([]byte(nil), bts[recorderFrameHeadSize:end]...)}, nil
+}
+
+// recorderRequest 编码平台下发的命令帧（AA 75）
+func recorderRequest(cmd byte, data RecorderData) []byte {
+	return encodeRecorderFrame(recorderRequestMagic, cmd, data)
+}
+
+// EncodeRecorderAnswer 编码记录仪应答的数据帧（55 7A），用于模拟记录仪
+func EncodeRecorderAnswer(cmd byte, data RecorderData) []byte {
+	return encodeRecorderFrame(recorderAnswerMagic, cmd, data)
+}
+
+// encodeRecorderFrame 编码数据帧，data为空时数据块长度为0
+func encodeRecorderFrame(magic []byte, cmd byte, data RecorderData) []byte {
+	var body bytes.Buffer
+	if nil != data {
+		data.writeTo(&body)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(cmd)
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, uint16(body.Len()))
+	buf.Write(value)
+	// 保留字
+	buf.WriteByte(0)
+	buf.Write(body.Bytes())
+	buf.WriteByte(recorderChecksum(buf.Bytes()))
+	return buf.Bytes()
+}
+
+// recorderChecksum 校验字，为校验字之前所有字节的异或值
+func recorderChecksum(bts []byte) byte {
+	sum := byte(0)
+	for _, bt := range bts {
+		sum ^= bt
+	}
+	return sum
+}
+
+// RecorderData 行驶记录仪数据块
+type RecorderData interface {
+	writeTo(buf *bytes.Buffer)
+}
+
+// RecorderRecord 行驶记录仪按时间检索的记录
+type RecorderRecord interface {
+	RecorderData
+	// RecordTime 记录时间，用于按时间范围检索和排序
+	RecordTime() time.Time
+}
+
+// NewMsgRecorderGather 新建行驶记录数据采集命令，data为命令帧数据块，可为空
+func NewMsgRecorderGather(cmd byte, data RecorderData) *MsgGatherDrivingRecord {
+	msg := NewMsgGatherDrivingRecord()
+	msg.CMD = cmd
+	msg.Data = recorderRequest(cmd, data)
+	return msg
+}
+
+// NewMsgRecorderRange 新建按时间范围采集记录的命令，max为单次应答的最大数据块个数
+func NewMsgRecorderRange(cmd byte, start, end time.Time, max uint16) *MsgGatherDrivingRecord {
+	return NewMsgRecorderGather(cmd, &RecorderRange{Start: start, End: end, Max: max})
+}
+
+// NewMsgRecorderSetting 新建行驶记录参数下传命令，data的格式与对应的采集命令相同
+func NewMsgRecorderSetting(cmd byte, data RecorderData) *MsgDrivingRecordParamsIssued {
+	msg := NewMsgDrivingRecordParamsIssued()
+	msg.CMD = cmd
+	msg.Data = recorderRequest(cmd, data)
+	return msg
+}
+
+// Frame 解析行驶记录仪数据帧，数据块不带55 7A起始字头时整体作为命令字对应的数据块
+func (m *MsgDrivingRecordReport) Frame() (*RecorderFrame, error) {
+	if bytes.HasPrefix(m.Data, recorderAnswerMagic) {
+		return ParseRecorderFrame(m.Data)
+	}
+	return &RecorderFrame{CMD: m.CMD, Data: m.Data}, nil
+}
+
+// Record 解码行驶记录仪数据，返回值类型见DecodeRecorderData
+func (m *MsgDrivingRecordReport) Record() (interface{}, error) {
+	frame, err := m.Frame()
+	if nil != err {
+		return nil, err
+	}
+	return DecodeRecorderData(frame.CMD, frame.Data)
+}
+
+// 单项数据的数据块长度
+var recorderDataSize = map[byte]int{
+	RecorderCmdStandard:     2,
+	RecorderCmdDriver:       18,
+	RecorderCmdTime:         6,
+	RecorderCmdMileage:      20,
+	RecorderCmdPulse:        8,
+	RecorderCmdVehicle:      41,
+	RecorderCmdStatusConfig: 87,
+	RecorderCmdUniqueID:     35,
+}
+
+// 记录的数据块长度
+var recorderRecordSize = map[byte]int{
+	RecorderCmdSpeed:     126,
+	RecorderCmdPosition:  666,
+	RecorderCmdAccident:  234,
+	RecorderCmdOvertime:  50,
+	RecorderCmdDriverLog: 25,
+	RecorderCmdPowerLog:  7,
+	RecorderCmdParamLog:  7,
+	RecorderCmdSpeedLog:  133,
+}
+
+// newRecorderData 按命令字新建数据块
+func newRecorderData(cmd byte) interface{ readBy(*bytes.Buffer) } {
+	switch cmd {
+	case RecorderCmdStandard:
+		return &RecorderStandard{}
+	case RecorderCmdDriver:
+		return &RecorderDriver{}
+	case RecorderCmdTime:
+		return &RecorderTime{}
+	case RecorderCmdMileage:
+		return &RecorderMileage{}
+	case RecorderCmdPulse:
+		return &RecorderPulse{}
+	case RecorderCmdVehicle:
+		return &RecorderVehicle{}
+	case RecorderCmdStatusConfig:
+		return &RecorderStatusConfig{}
+	case RecorderCmdUniqueID:
+		return &RecorderUniqueID{}
+	case RecorderCmdSpeed:
+		return &RecorderSpeedRecord{}
+	case RecorderCmdPosition:
+		return &RecorderPositionRecord{}
+	case RecorderCmdAccident:
+		return &RecorderAccidentRecord{}
+	case RecorderCmdOvertime:
+		return &RecorderOvertimeRecord{}
+	case RecorderCmdDriverLog:
+		return &RecorderDriverLog{}
+	case RecorderCmdPowerLog:
+		return &RecorderPowerLog{}
+	case RecorderCmdParamLog:
+		return &RecorderParamLog{}
+	case RecorderCmdSpeedLog:
+		return &RecorderSpeedLog{}
+	}
+	return nil
+}
+
+// DecodeRecorderData 按命令字解码数据块。
+//
+// 0x00~0x07返回对应的单项数据指针，如*RecorderMileage；0x08~0x15返回[]RecorderRecord，
+// 元素为对应的记录指针，如*RecorderSpeedRecord，按数据块中的顺序排列。
+func DecodeRecorderData(cmd byte, data []byte) (interface{}, error) {
+	if size, ok := recorderDataSize[cmd]; ok {
+		if len(data) < size {
+			return nil, fmt.Errorf("recorder data %#02x: got %d bytes, want %d", cmd, len(data), size)
+		}
+		value := newRecorderData(cmd)
+		value.readBy(bytes.NewBuffer(data))
+		return value, nil
+	}
+
+	size, ok := recorderRecordSize[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unknown recorder command %#02x", cmd)
+	}
+	if 0 != len(data)%size {
+		return nil, fmt.Errorf("recorder data %#02x: %d bytes is not a multiple of %d", cmd, len(data), size)
+	}
+
+	records := make([]RecorderRecord, 0, len(data)/size)
+	buf := bytes.NewBuffer(data)
+	for buf.Len() > 0 {
+		record := newRecorderData(cmd)
+		record.readBy(bytes.NewBuffer(buf.Next(size)))
+		records = append(records, record.(RecorderRecord))
+	}
+	return records, nil
+}
+
+// readRecorderTime 读取6字节BCD时间，全0等无效时间返回零值
+func readRecorderTime(buf *bytes.Buffer) time.Time {
+	t, _ := util.ParseBCDTime(buf.Next(6), cstZone)
+	return t
+}
+
+// writeRecorderTime 写入6字节BCD时间，零值写入全0
+func writeRecorderTime(buf *bytes.Buffer, t time.Time) {
+	if t.IsZero() {
+		buf.Write(make([]byte, 6))
+		return
+	}
+	buf.Write(util.ToBCD([]byte(t.In(cstZone).Format("060102150405"))))
+}
+
+// readRecorderString 读取定长字符串，去除末尾的0和空格
+func readRecorderString(buf *bytes.Buffer, size int) string {
+	return strings.TrimRight(readFixedString(buf, size), " ")
+}
+
+// readRecorderGBK 读取GBK编码的定长字符串
+func readRecorderGBK(buf *bytes.Buffer, size int) string {
+	return gbkDecoder.ConvertString(readRecorderString(buf, size))
+}
+
+// writeRecorderGBK 写入GBK编码的定长字符串
+func writeRecorderGBK(buf *bytes.Buffer, value string, size int) {
+	writeFixedString(buf, mahonia.NewEncoder("gbk").ConvertString(value), size)
+}
+
+// readBCDUint 读取BCD码无符号整数
+func readBCDUint(buf *bytes.Buffer, size int) uint32 {
+	value, _ := strconv.ParseUint(string(util.ParseBCD(buf.Next(size))), 10, 32)
+	return uint32(value)
+}
+
+// writeBCDUint 写入BCD码无符号整数，size为字节数，超出位数时只保留低位
+func writeBCDUint(buf *bytes.Buffer, value uint32, size int) {
+	digits := fmt.Sprintf("%0*d", size*2, value)
+	buf.Write(util.ToBCD([]byte(digits[len(digits)-size*2:])))
+}
+
+// RecorderRange 按时间范围采集记录的数据块
+type RecorderRange struct {
+	// 开始时间
+	Start time.Time `json:"start"`
+	// 结束时间
+	End time.Time `json:"end"`
+	// 最大单位数据块个数
+	Max uint16 `json:"max"`
+}
+
+func (r *RecorderRange) readBy(buf *bytes.Buffer) {
+	r.Start = readRecorderTime(buf)
+	r.End = readRecorderTime(buf)
+	r.Max = binary.BigEndian.Uint16(buf.Next(2))
+}
+
+func (r *RecorderRange) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Start)
+	writeRecorderTime(buf, r.End)
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, r.Max)
+	buf.Write(value)
+}
+
+// RecorderStandard 记录仪执行标准版本（0x00）
+type RecorderStandard struct {
+	// 记录仪执行标准年号后2位，如12
+	Year byte `json:"year"`
+	// 修改单号，无修改单时为0
+	Amendment byte `json:"amendment"`
+}
+
+func (r *RecorderStandard) readBy(buf *bytes.Buffer) {
+	r.Year = byte(readBCDUint(buf, 1))
+	r.Amendment, _ = buf.ReadByte()
+}
+
+func (r *RecorderStandard) writeTo(buf *bytes.Buffer) {
+	writeBCDUint(buf, uint32(r.Year), 1)
+	buf.WriteByte(r.Amendment)
+}
+
+// RecorderDriver 当前驾驶人信息（0x01）
+type RecorderDriver struct {
+	// 机动车驾驶证号码
+	License string `json:"license"`
+}
+
+func (r *RecorderDriver) readBy(buf *bytes.Buffer) {
+	r.License = readRecorderString(buf, 18)
+}
+
+func (r *RecorderDriver) writeTo(buf *bytes.Buffer) {
+	writeFixedString(buf, r.License, 18)
+}
+
+// RecorderTime 记录仪实时时间（0x02），也用于设置记录仪时间（0xC2）和初次安装日期（0x83）
+type RecorderTime struct {
+	Time time.Time `json:"time"`
+}
+
+func (r *RecorderTime) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+}
+
+func (r *RecorderTime) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+}
+
+// RecorderMileage 累计行驶里程（0x03），也用于设置初始里程（0xC4）
+type RecorderMileage struct {
+	// 记录仪实时时间
+	Time time.Time `json:"time"`
+	// 记录仪初次安装时间
+	InstallTime time.Time `json:"install_time"`
+	// 初始里程，单位0.1km
+	Initial uint32 `json:"initial"`
+	// 累计行驶里程，单位0.1km
+	Total uint32 `json:"total"`
+}
+
+func (r *RecorderMileage) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.InstallTime = readRecorderTime(buf)
+	r.Initial = readBCDUint(buf, 4)
+	r.Total = readBCDUint(buf, 4)
+}
+
+func (r *RecorderMileage) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	writeRecorderTime(buf, r.InstallTime)
+	writeBCDUint(buf, r.Initial, 4)
+	writeBCDUint(buf, r.Total, 4)
+}
+
+// RecorderPulse 记录仪脉冲系数（0x04），也用于设置脉冲系数（0xC3）
+type RecorderPulse struct {
+	// 记录仪实时时间
+	Time time.Time `json:"time"`
+	// 脉冲系数，每公里脉冲数
+	Coefficient uint16 `json:"coefficient"`
+}
+
+func (r *RecorderPulse) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.Coefficient = binary.BigEndian.Uint16(buf.Next(2))
+}
+
+func (r *RecorderPulse) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, r.Coefficient)
+	buf.Write(value)
+}
+
+// RecorderVehicle 车辆信息（0x05），也用于设置车辆信息（0x82）
+type RecorderVehicle struct {
+	// 车辆识别代号
+	VIN string `json:"vin"`
+	// 机动车号牌号码
+	PlateNo string `json:"plate_no"`
+	// 机动车号牌分类
+	PlateClass string `json:"plate_class"`
+}
+
+func (r *RecorderVehicle) readBy(buf *bytes.Buffer) {
+	r.VIN = readRecorderString(buf, 17)
+	r.PlateNo = readRecorderGBK(buf, 12)
+	r.PlateClass = readRecorderGBK(buf, 12)
+}
+
+func (r *RecorderVehicle) writeTo(buf *bytes.Buffer) {
+	writeFixedString(buf, r.VIN, 17)
+	writeRecorderGBK(buf, r.PlateNo, 12)
+	writeRecorderGBK(buf, r.PlateClass, 12)
+}
+
+// RecorderStatusConfig 状态信号配置信息（0x06），也用于设置状态量配置信息（0x84）
+type RecorderStatusConfig struct {
+	// 记录仪实时时间
+	Time time.Time `json:"time"`
+	// 状态信号字节个数
+	Count byte `json:"count"`
+	// D0~D7状态信号名称
+	Names [8]string `json:"names"`
+}
+
+func (r *RecorderStatusConfig) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.Count, _ = buf.ReadByte()
+	for idx := range r.Names {
+		r.Names[idx] = readRecorderGBK(buf, 10)
+	}
+}
+
+func (r *RecorderStatusConfig) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	buf.WriteByte(r.Count)
+	for _, name := range r.Names {
+		writeRecorderGBK(buf, name, 10)
+	}
+}
+
+// RecorderUniqueID 记录仪唯一性编号（0x07）
+type RecorderUniqueID struct {
+	// 生产厂CCC认证代码
+	CCC string `json:"ccc"`
+	// 认证产品型号
+	Model string `json:"model"`
+	// 记录仪生产日期，精确到日
+	ProductionDate time.Time `json:"production_date"`
+	// 产品生产流水号
+	Serial uint32 `json:"serial"`
+}
+
+func (r *RecorderUniqueID) readBy(buf *bytes.Buffer) {
+	r.CCC = readRecorderString(buf, 7)
+	r.Model = readRecorderString(buf, 16)
+	r.ProductionDate, _ = util.ParseBCDTime(append(append([]byte(nil), buf.Next(3)...), 0, 0, 0), cstZone)
+	r.Serial = binary.BigEndian.Uint32(buf.Next(4))
+	// 备用
+	buf.Next(5)
+}
+
+func (r *RecorderUniqueID) writeTo(buf *bytes.Buffer) {
+	writeFixedString(buf, r.CCC, 7)
+	writeFixedString(buf, r.Model, 16)
+	if r.ProductionDate.IsZero() {
+		buf.Write(make([]byte, 3))
+	} else {
+		buf.Write(util.ToBCD([]byte(r.ProductionDate.In(cstZone).Format("060102"))))
+	}
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, r.Serial)
+	buf.Write(value)
+	buf.Write(make([]byte, 5))
+}
+
+// RecorderLocation 记录仪位置信息
+type RecorderLocation struct {
+	// 经度，单位0.0001分，东经为正，西经为负
+	Longitude int32 `json:"longitude"`
+	// 纬度，单位0.0001分，北纬为正，南纬为负
+	Latitude int32 `json:"latitude"`
+	// 高度，单位m
+	Altitude int16 `json:"altitude"`
+}
+
+func (r *RecorderLocation) readBy(buf *bytes.Buffer) {
+	r.Longitude = int32(binary.BigEndian.Uint32(buf.Next(4)))
+	r.Latitude = int32(binary.BigEndian.Uint32(buf.Next(4)))
+	r.Altitude = int16(binary.BigEndian.Uint16(buf.Next(2)))
+}
+
+func (r *RecorderLocation) writeTo(buf *bytes.Buffer) {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(r.Longitude))
+	buf.Write(value)
+	binary.BigEndian.PutUint32(value, uint32(r.Latitude))
+	buf.Write(value)
+	binary.BigEndian.PutUint16(value, uint16(r.Altitude))
+	buf.Write(value[:2])
+}
+
+// RecorderSpeedSample 速度及状态信号
+type RecorderSpeedSample struct {
+	// 速度，单位km/h
+	Speed byte `json:"speed"`
+	// 状态信号，D0~D7对应状态信号配置
+	Status byte `json:"status"`
+}
+
+// RecorderSpeedRecord 行驶速度记录（0x08），每分钟一条，记录每秒的平均速度与状态
+type RecorderSpeedRecord struct {
+	// 开始时间
+	Start time.Time `json:"start"`
+	// 每秒的速度及状态信号
+	Samples [60]RecorderSpeedSample `json:"samples"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderSpeedRecord) RecordTime() time.Time {
+	return r.Start
+}
+
+func (r *RecorderSpeedRecord) readBy(buf *bytes.Buffer) {
+	r.Start = readRecorderTime(buf)
+	for idx := range r.Samples {
+		r.Samples[idx].Speed, _ = buf.ReadByte()
+		r.Samples[idx].Status, _ = buf.ReadByte()
+	}
+}
+
+func (r *RecorderSpeedRecord) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Start)
+	for _, sample := range r.Samples {
+		buf.WriteByte(sample.Speed)
+		buf.WriteByte(sample.Status)
+	}
+}
+
+// RecorderPoint 每分钟的位置及平均速度
+type RecorderPoint struct {
+	RecorderLocation
+	// 平均速度，单位km/h
+	Speed byte `json:"speed"`
+}
+
+// RecorderPositionRecord 位置信息记录（0x09），每小时一条，记录每分钟的位置及平均速度
+type RecorderPositionRecord struct {
+	// 开始时间
+	Start time.Time `json:"start"`
+	// 每分钟的位置及平均速度
+	Points [60]RecorderPoint `json:"points"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderPositionRecord) RecordTime() time.Time {
+	return r.Start
+}
+
+func (r *RecorderPositionRecord) readBy(buf *bytes.Buffer) {
+	r.Start = readRecorderTime(buf)
+	for idx := range r.Points {
+		r.Points[idx].readBy(buf)
+		r.Points[idx].Speed, _ = buf.ReadByte()
+	}
+}
+
+func (r *RecorderPositionRecord) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Start)
+	for idx := range r.Points {
+		r.Points[idx].RecorderLocation.writeTo(buf)
+		buf.WriteByte(r.Points[idx].Speed)
+	}
+}
+
+// RecorderAccidentRecord 事故疑点记录（0x10），记录停车前20s每0.2s的速度与状态
+type RecorderAccidentRecord struct {
+	// 行驶结束时间
+	End time.Time `json:"end"`
+	// 机动车驾驶证号码
+	License string `json:"license"`
+	// 行驶结束前每0.2s的速度及状态信号，第一个为结束时刻
+	Samples [100]RecorderSpeedSample `json:"samples"`
+	// 行驶结束前最近一次有效位置
+	Location RecorderLocation `json:"location"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderAccidentRecord) RecordTime() time.Time {
+	return r.End
+}
+
+func (r *RecorderAccidentRecord) readBy(buf *bytes.Buffer) {
+	r.End = readRecorderTime(buf)
+	r.License = readRecorderString(buf, 18)
+	for idx := range r.Samples {
+		r.Samples[idx].Speed, _ = buf.ReadByte()
+		r.Samples[idx].Status, _ = buf.ReadByte()
+	}
+	r.Location.readBy(buf)
+}
+
+func (r *RecorderAccidentRecord) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.End)
+	writeFixedString(buf, r.License, 18)
+	for _, sample := range r.Samples {
+		buf.WriteByte(sample.Speed)
+		buf.WriteByte(sample.Status)
+	}
+	r.Location.writeTo(buf)
+}
+
+// RecorderOvertimeRecord 超时驾驶记录（0x11）
+type RecorderOvertimeRecord struct {
+	// 机动车驾驶证号码
+	License string `json:"license"`
+	// 连续驾驶开始时间
+	Start time.Time `json:"start"`
+	// 连续驾驶结束时间
+	End time.Time `json:"end"`
+	// 连续驾驶开始位置
+	StartLocation RecorderLocation `json:"start_location"`
+	// 连续驾驶结束位置
+	EndLocation RecorderLocation `json:"end_location"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderOvertimeRecord) RecordTime() time.Time {
+	return r.Start
+}
+
+func (r *RecorderOvertimeRecord) readBy(buf *bytes.Buffer) {
+	r.License = readRecorderString(buf, 18)
+	r.Start = readRecorderTime(buf)
+	r.End = readRecorderTime(buf)
+	r.StartLocation.readBy(buf)
+	r.EndLocation.readBy(buf)
+}
+
+func (r *RecorderOvertimeRecord) writeTo(buf *bytes.Buffer) {
+	writeFixedString(buf, r.License, 18)
+	writeRecorderTime(buf, r.Start)
+	writeRecorderTime(buf, r.End)
+	r.StartLocation.writeTo(buf)
+	r.EndLocation.writeTo(buf)
+}
+
+// 驾驶人身份记录事件类型
+const (
+	RecorderDriverLogin  = byte(1) // 登录
+	RecorderDriverLogout = byte(2) // 退出
+)
+
+// RecorderDriverLog 驾驶人身份记录（0x12）
+type RecorderDriverLog struct {
+	// 事件发生时间
+	Time time.Time `json:"time"`
+	// 机动车驾驶证号码
+	License string `json:"license"`
+	// 事件类型，见RecorderDriverLogin、RecorderDriverLogout
+	Type byte `json:"type"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderDriverLog) RecordTime() time.Time {
+	return r.Time
+}
+
+func (r *RecorderDriverLog) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.License = readRecorderString(buf, 18)
+	r.Type, _ = buf.ReadByte()
+}
+
+func (r *RecorderDriverLog) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	writeFixedString(buf, r.License, 18)
+	buf.WriteByte(r.Type)
+}
+
+// 外部供电记录事件类型
+const (
+	RecorderPowerOn  = byte(1) // 通电
+	RecorderPowerOff = byte(2) // 断电
+)
+
+// RecorderPowerLog 外部供电记录（0x13）
+type RecorderPowerLog struct {
+	// 事件发生时间
+	Time time.Time `json:"time"`
+	// 事件类型，见RecorderPowerOn、RecorderPowerOff
+	Type byte `json:"type"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderPowerLog) RecordTime() time.Time {
+	return r.Time
+}
+
+func (r *RecorderPowerLog) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.Type, _ = buf.ReadByte()
+}
+
+func (r *RecorderPowerLog) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	buf.WriteByte(r.Type)
+}
+
+// RecorderParamLog 参数修改记录（0x14）
+type RecorderParamLog struct {
+	// 事件发生时间
+	Time time.Time `json:"time"`
+	// 事件类型，为被修改参数的设置命令字
+	Type byte `json:"type"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderParamLog) RecordTime() time.Time {
+	return r.Time
+}
+
+func (r *RecorderParamLog) readBy(buf *bytes.Buffer) {
+	r.Time = readRecorderTime(buf)
+	r.Type, _ = buf.ReadByte()
+}
+
+func (r *RecorderParamLog) writeTo(buf *bytes.Buffer) {
+	writeRecorderTime(buf, r.Time)
+	buf.WriteByte(r.Type)
+}
+
+// RecorderSpeedPair 记录速度与参考速度
+type RecorderSpeedPair struct {
+	// 记录速度，单位km/h
+	Speed byte `json:"speed"`
+	// 参考速度（卫星定位速度），单位km/h
+	Reference byte `json:"reference"`
+}
+
+// RecorderSpeedLog 速度状态日志（0x15）
+type RecorderSpeedLog struct {
+	// 速度状态，1-正常，2-异常
+	Status byte `json:"status"`
+	// 速度状态判定的开始时间
+	Start time.Time `json:"start"`
+	// 速度状态判定的结束时间
+	End time.Time `json:"end"`
+	// 开始时间起每秒的记录速度与参考速度
+	Samples [60]RecorderSpeedPair `json:"samples"`
+}
+
+// RecordTime 记录时间
+func (r *RecorderSpeedLog) RecordTime() time.Time {
+	return r.Start
+}
+
+func (r *RecorderSpeedLog) readBy(buf *bytes.Buffer) {
+	r.Status, _ = buf.ReadByte()
+	r.Start = readRecorderTime(buf)
+	r.End = readRecorderTime(buf)
+	for idx := range r.Samples {
+		r.Samples[idx].Speed, _ = buf.ReadByte()
+		r.Samples[idx].Reference, _ = buf.ReadByte()
+	}
+}
+
+func (r *RecorderSpeedLog) writeTo(buf *bytes.Buffer) {
+	buf.WriteByte(r.Status)
+	writeRecorderTime(buf, r.Start)
+	writeRecorderTime(buf, r.End)
+	for _, sample := range r.Samples {
+		buf.WriteByte(sample.Speed)
+		buf.WriteByte(sample.Reference)
+	}
+}
+
+// RecorderRecords 多个记录组成的数据块，用于模拟记录仪应答
+type RecorderRecords []RecorderRecord
+
+func (r RecorderRecords) writeTo(buf *bytes.Buffer) {
+	for _, record := range r {
+		record.writeTo(buf)
+	}
+}
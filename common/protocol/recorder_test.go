@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecorderGather(t *testing.T) {
+	start := time.Date(2021, 10, 19, 8, 0, 0, 0, cstZone)
+	msg := NewMsgRecorderRange(RecorderCmdSpeed, start, start.Add(time.Hour), 5)
+
+	var buf bytes.Buffer
+	msg.writeTo(&buf)
+	want := []byte{RecorderCmdSpeed, 0xAA, 0x75, RecorderCmdSpeed, 0x00, 0x0E, 0x00,
+		0x21, 0x10, 0x19, 0x08, 0x00, 0x00, 0x21, 0x10, 0x19, 0x09, 0x00, 0x00, 0x00, 0x05}
+	want = append(want, recorderChecksum(want[1:]))
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Fatalf("got %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestRecorderAnswer(t *testing.T) {
+	now := time.Date(2021, 10, 19, 8, 30, 0, 0, cstZone)
+
+	// 单项数据
+	mileage := &RecorderMileage{Time: now, InstallTime: now.AddDate(-1, 0, 0), Initial: 12, Total: 123456}
+	report := &MsgDrivingRecordReport{ReqNum: 1, CMD: RecorderCmdMileage, Data: EncodeRecorderAnswer(RecorderCmdMileage, mileage)}
+	value, err := report.Record()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if got := value.(*RecorderMileage); *got != *mileage {
+		t.Fatalf("got %+v, want %+v", got, mileage)
+	}
+
+	vehicle := &RecorderVehicle{VIN: "LSVAA4182E2123456", PlateNo: "粤B12345", PlateClass: "大型汽车"}
+	report.Data = EncodeRecorderAnswer(RecorderCmdVehicle, vehicle)
+	if value, err = report.Record(); nil != err || *value.(*RecorderVehicle) != *vehicle {
+		t.Fatalf("unexpected vehicle: %+v %v", value, err)
+	}
+
+	// 记录列表
+	speed := &RecorderSpeedRecord{Start: now}
+	speed.Samples[59] = RecorderSpeedSample{Speed: 60, Status: 0x81}
+	accident := &RecorderAccidentRecord{End: now, License: "440301199001011234", Location: RecorderLocation{Longitude: -6789012, Latitude: 1357924, Altitude: -5}}
+	for cmd, records := range map[byte]RecorderRecords{
+		RecorderCmdSpeed:     {speed, speed},
+		RecorderCmdAccident:  {accident},
+		RecorderCmdDriverLog: {&RecorderDriverLog{Time: now, License: "440301199001011234", Type: RecorderDriverLogin}},
+	} {
+		report.Data = EncodeRecorderAnswer(cmd, records)
+		value, err := report.Record()
+		if nil != err {
+			t.Fatal(err)
+		}
+		decoded := value.([]RecorderRecord)
+		if len(decoded) != len(records) {
+			t.Fatalf("cmd %#x: got %d records, want %d", cmd, len(decoded), len(records))
+		}
+		var got, want bytes.Buffer
+		RecorderRecords(decoded).writeTo(&got)
+		records.writeTo(&want)
+		if !bytes.Equal(got.Bytes(), want.Bytes()) || !decoded[0].RecordTime().Equal(now) {
+			t.Fatalf("cmd %#x: unexpected records %+v", cmd, decoded)
+		}
+	}
+
+	// 出错应答与校验错误
+	report.Data = []byte{0x55, 0x7A, 0xFA, 0x00, 0x55 ^ 0x7A ^ 0xFA}
+	if _, err := report.Record(); ErrRecorderGather != err {
+		t.Fatalf("got %v, want ErrRecorderGather", err)
+	}
+	report.Data = EncodeRecorderAnswer(RecorderCmdTime, &RecorderTime{Time: now})
+	report.Data[len(report.Data)-1]++
+	if _, err := report.Record(); ErrRecorderChecksum != err {
+		t.Fatalf("got %v, want ErrRecorderChecksum", err)
+	}
+}
+
+func TestPacketReassemble(t *testing.T) {
+	var whole packet
+	whole.head.id = MsgIDDrivingRecordReport
+	whole.head.number = 0xFFFF
+	whole.body = bytes.Repeat([]byte{1, 2, 3}, maxBodySize)
+	whole.head.attr.subpackage()
+	whole.head.pack.total = 3
+
+	var codec packetCodec
+	var result *packet
+	// 子包乱序到达，重复的子包忽略
+	for _, idx := range []uint16{2, 1, 2, 3} {
+		sub, _ := whole.subpacket(idx)
+		sub.head.number = whole.head.number + idx - 1
+		result = codec.reassemble(sub)
+		if nil != result && 3 != idx {
+			t.Fatalf("reassembled early at %d", idx)
+		}
+	}
+	if nil == result {
+		t.Fatal("not reassembled")
+	}
+	if result.head.attr.isSubpackage() || 0xFFFF != result.head.number || !bytes.Equal(whole.body, result.body) {
+		t.Fatalf("unexpected packet: %+v %d", result.head, len(result.body))
+	}
+	if 0 != len(codec.pending) {
+		t.Fatalf("pending not cleared: %d", len(codec.pending))
+	}
+}
@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"bytes"
+	"common/protocol/util"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// JT/T1078 RTP包头帧头标识
+var rtpMagic = []byte{0x30, 0x31, 0x63, 0x64}
+
+// RTP包固定字段字节数：帧头标识至数据类型/分包处理标记
+const rtpFixedSize = 16
+
+// RTPMaxBodySize RTP包数据体最大字节数
+const RTPMaxBodySize = 950
+
+// RTPMaxPacketSize RTP包最大字节数：最长包头加最大数据体
+const RTPMaxPacketSize = 30 + RTPMaxBodySize
+
+// ErrRTPMagic 数据不以RTP帧头标识开头
+var ErrRTPMagic = errors.New("the bad protocol data: rtp magic mismatch")
+
+// RTP数据类型
+const (
+	// 视频I帧
+	RTPDataTypeIFrame = byte(0)
+	// 视频P帧
+	RTPDataTypePFrame = byte(1)
+	// 视频B帧
+	RTPDataTypeBFrame = byte(2)
+	// 音频帧
+	RTPDataTypeAudio = byte(3)
+	// 透传数据
+	RTPDataTypeTransparent = byte(4)
+)
+
+// RTP分包处理标记
+const (
+	// 原子包，不可被拆分
+	RTPSubpackageAtomic = byte(0)
+	// 分包处理时的第一个包
+	RTPSubpackageFirst = byte(1)
+	// 分包处理时的最后一个包
+	RTPSubpackageLast = byte(2)
+	// 分包处理时的中间包
+	RTPSubpackageMiddle = byte(3)
+)
+
+// RTP负载类型（部分）
+const (
+	// G.711A音频
+	PayloadTypeG711A = byte(6)
+	// G.711U音频
+	PayloadTypeG711U = byte(7)
+	// G.726音频
+	PayloadTypeG726 = byte(8)
+	// ADPCMA音频
+	PayloadTypeADPCMA = byte(26)
+	// AAC音频
+	PayloadTypeAAC = byte(19)
+	// H.264视频
+	PayloadTypeH264 = byte(98)
+	// H.265视频
+	PayloadTypeH265 = byte(99)
+)
+
+// RTPPacket JT/T1078音视频RTP包
+type RTPPacket struct {
+	// 标志位，确定是否是完整数据帧的边界
+	Marker bool `json:"marker"`
+	// 负载类型
+	PayloadType byte `json:"payload_type"`
+	// 包序号，初始为0，每发送一个RTP包序号加1
+	Sequence uint16 `json:"sequence"`
+	// 终端设备SIM卡号，12位数字
+	Phone string `json:"phone"`
+	// 逻辑通道号
+	Channel byte `json:"channel"`
+	// 数据类型，0-视频I帧，1-视频P帧，2-视频B帧，3-音频帧，4-透传数据
+	DataType byte `json:"data_type"`
+	// 分包处理标记，0-原子包，1-第一个包，2-最后一个包，3-中间包
+	Subpackage byte `json:"subpackage"`
+	// 时间戳，单位ms，透传数据无此字段
+	Timestamp uint64 `json:"timestamp"`
+	// 与上一关键帧的时间间隔，单位ms，仅视频帧有此字段
+	LastIFrameInterval uint16 `json:"last_iframe_interval"`
+	// 与上一帧的时间间隔，单位ms，仅视频帧有此字段
+	LastFrameInterval uint16 `json:"last_frame_interval"`
+	// 数据体
+	Body []byte `json:"body"`
+}
+
+// IsVideo 是否是视频帧
+func (p *RTPPacket) IsVideo() bool {
+	return p.DataType <= RTPDataTypeBFrame
+}
+
+// IsAudio 是否是音频帧
+func (p *RTPPacket) IsAudio() bool {
+	return RTPDataTypeAudio == p.DataType
+}
+
+// rtpHeaderSize 包头字节数（含数据体长度），随数据类型变化：视频30，音频26，透传18
+func rtpHeaderSize(dataType byte) int {
+	switch {
+	case dataType <= RTPDataTypeBFrame:
+		return rtpFixedSize + 8 + 4 + 2
+	case RTPDataTypeAudio == dataType:
+		return rtpFixedSize + 8 + 2
+	default:
+		return rtpFixedSize + 2
+	}
+}
+
+// ParseRTPPacket 从字节序头部解析一个RTP包，返回解析出的包及消耗的字节数。
+//
+// 数据不足一个完整包时返回0且不返回错误；数据体引用传入的字节序，不做拷贝。
+func ParseRTPPacket(bts []byte) (*RTPPacket, int, error) {
+	if len(bts) < rtpFixedSize {
+		return nil, 0, nil
+	}
+	if !bytes.Equal(rtpMagic, bts[:4]) {
+		return nil, 0, ErrRTPMagic
+	}
+
+	var p RTPPacket
+	headerSize := rtpHeaderSize(bts[15] >> 4)
+	if len(bts) < headerSize {
+		return nil, 0, nil
+	}
+
+	bodySize := int(binary.BigEndian.Uint16(bts[headerSize-2:]))
+	if bodySize > RTPMaxBodySize {
+		return nil, 0, errors.New("the bad protocol data: rtp body too large")
+	}
+	if len(bts) < headerSize+bodySize {
+		return nil, 0, nil
+	}
+
+	// 负载类型及标志位
+	p.Marker = bts[5]&0x80 > 0
+	p.PayloadType = bts[5] & 0x7F
+	// 包序号
+	p.Sequence = binary.BigEndian.Uint16(bts[6:])
+	// SIM卡号
+	p.Phone = string(util.ParseBCD(bts[8:14]))
+	// 逻辑通道号
+	p.Channel = bts[14]
+	// 数据类型及分包处理标记
+	p.DataType = bts[15] >> 4
+	p.Subpackage = bts[15] & 0x0F
+
+	idx := rtpFixedSize
+	if RTPDataTypeTransparent != p.DataType {
+		// 时间戳
+		p.Timestamp = binary.BigEndian.Uint64(bts[idx:])
+		idx += 8
+	}
+	if p.IsVideo() {
+		// 帧间隔
+		p.LastIFrameInterval = binary.BigEndian.Uint16(bts[idx:])
+		p.LastFrameInterval = binary.BigEndian.Uint16(bts[idx+2:])
+		idx += 4
+	}
+	// 数据体
+	p.Body = bts[headerSize : headerSize+bodySize]
+
+	return &p, headerSize + bodySize, nil
+}
+
+// IndexRTPMagic 查找RTP帧头标识的位置，用于数据错乱后重新同步
+func IndexRTPMagic(bts []byte) int {
+	return bytes.Index(bts, rtpMagic)
+}
+
+// WriteTo 将RTP包写入缓存中
+func (p *RTPPacket) WriteTo(buf *bytes.Buffer) {
+	value := make([]byte, 8)
+
+	// 帧头标识
+	buf.Write(rtpMagic)
+	// V=2，P=0，X=0，CC=1
+	buf.WriteByte(0x81)
+	// 标志位及负载类型
+	if p.Marker {
+		buf.WriteByte(0x80 | p.PayloadType)
+	} else {
+		buf.WriteByte(p.PayloadType & 0x7F)
+	}
+	// 包序号
+	binary.BigEndian.PutUint16(value, p.Sequence)
+	buf.Write(value[:2])
+	// SIM卡号，不足12位前面补0
+	phone := p.Phone
+	if len(phone) < 12 {
+		phone = strings.Repeat("0", 12-len(phone)) + phone
+	}
+	buf.Write(util.ToBCD([]byte(phone[len(phone)-12:])))
+	// 逻辑通道号
+	buf.WriteByte(p.Channel)
+	// 数据类型及分包处理标记
+	buf.WriteByte(p.DataType<<4 | p.Subpackage&0x0F)
+	if RTPDataTypeTransparent != p.DataType {
+		// 时间戳
+		binary.BigEndian.PutUint64(value, p.Timestamp)
+		buf.Write(value)
+	}
+	if p.IsVideo() {
+		// 帧间隔
+		binary.BigEndian.PutUint16(value, p.LastIFrameInterval)
+		binary.BigEndian.PutUint16(value[2:], p.LastFrameInterval)
+		buf.Write(value[:4])
+	}
+	// 数据体
+	binary.BigEndian.PutUint16(value, uint16(len(p.Body)))
+	buf.Write(value[:2])
+	buf.Write(p.Body)
+}
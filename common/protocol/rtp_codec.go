@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/go-netty/go-netty"
+	"github.com/go-netty/go-netty/codec"
+	"github.com/go-netty/go-netty/utils"
+)
+
+// RTPCodec create JT/T1078 rtp codec
+//
+// 从TCP流中切分出RTP包，读出的*RTPPacket数据体引用读缓存，只在后续Handler中同步使用；
+// 帧头标识不匹配时丢弃数据直到下一个帧头标识。写出时接受*RTPPacket。
+func RTPCodec(bufSize int) codec.Codec {
+	utils.AssertIf(bufSize < RTPMaxPacketSize, "bufSize must be not less than RTPMaxPacketSize")
+	return &rtpCodec{
+		readBuf: make([]byte, bufSize),
+	}
+}
+
+type rtpCodec struct {
+	readBuf  []byte       // 读缓存
+	readIdx  int          // 读索引，readBuf[:readIdx]为未处理的数据
+	writeBuf bytes.Buffer // 写缓存
+}
+
+func (*rtpCodec) CodecName() string {
+	return "rtp-codec"
+}
+
+func (r *rtpCodec) HandleRead(ctx netty.InboundContext, message netty.Message) {
+	reader := utils.MustToReader(message)
+	read := utils.AssertLength(reader.Read(r.readBuf[r.readIdx:]))
+	r.readIdx += read
+
+	r.split(func(packet *RTPPacket) {
+		ctx.HandleRead(packet)
+	})
+}
+
+func (r *rtpCodec) HandleWrite(ctx netty.OutboundContext, message netty.Message) {
+	packet := message.(*RTPPacket)
+
+	r.writeBuf.Reset()
+	packet.WriteTo(&r.writeBuf)
+	ctx.HandleWrite(r.writeBuf.Bytes())
+}
+
+// split 从读缓存中切分出完整的RTP包，剩余未完成的包移动到读缓存头部
+func (r *rtpCodec) split(handle func(*RTPPacket)) {
+	mark := 0
+	for mark < r.readIdx {
+		packet, n, err := ParseRTPPacket(r.readBuf[mark:r.readIdx])
+		if nil != err {
+			// 重新同步到下一个帧头标识，跳过当前位置避免死循环
+			idx := IndexRTPMagic(r.readBuf[mark+1 : r.readIdx])
+			if idx < 0 {
+				// 保留末尾可能是不完整帧头标识的3个字节
+				keep := r.readIdx - mark - 1
+				if keep > len(rtpMagic)-1 {
+					keep = len(rtpMagic) - 1
+				}
+				atomic.AddUint64(&discardedBytes, uint64(r.readIdx-mark-keep))
+				mark = r.readIdx - keep
+				break
+			}
+			atomic.AddUint64(&discardedBytes, uint64(idx+1))
+			mark += idx + 1
+			continue
+		}
+		if 0 == n {
+			break
+		}
+
+		handle(packet)
+		mark += n
+	}
+
+	// 移动剩余字节到读缓存头部
+	r.readIdx = copy(r.readBuf, r.readBuf[mark:r.readIdx])
+}
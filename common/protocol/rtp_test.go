@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestRTPPacketRoundTrip(t *testing.T) {
+	packets := []RTPPacket{
+		{Marker: true, PayloadType: PayloadTypeH264, Sequence: 7, Phone: "013912345678", Channel: 1, DataType: RTPDataTypeIFrame,
+			Subpackage: RTPSubpackageFirst, Timestamp: 1634610030000, LastIFrameInterval: 40, LastFrameInterval: 40, Body: []byte{0, 0, 0, 1, 0x67}},
+		{PayloadType: PayloadTypeG711A, Sequence: 8, Phone: "013912345678", Channel: 1, DataType: RTPDataTypeAudio, Timestamp: 1634610030020, Body: []byte{0xD5, 0xD5}},
+		{PayloadType: 0, Sequence: 9, Phone: "013912345678", Channel: 2, DataType: RTPDataTypeTransparent, Body: []byte{1}},
+	}
+
+	var buf bytes.Buffer
+	for idx := range packets {
+		packets[idx].WriteTo(&buf)
+	}
+
+	// 视频包头30字节，音频26字节，透传18字节
+	if want := 30 + 5 + 26 + 2 + 18 + 1; buf.Len() != want {
+		t.Fatalf("got %d bytes, want %d", buf.Len(), want)
+	}
+	if got := hex.EncodeToString(buf.Bytes()[:16]); got != "3031636481e20007013912345678"+"0101" {
+		t.Fatalf("unexpected header: %s", got)
+	}
+
+	bts := buf.Bytes()
+	for idx := range packets {
+		packet, n, err := ParseRTPPacket(bts)
+		if nil != err || 0 == n {
+			t.Fatalf("packet %d: n=%d, err=%v", idx, n, err)
+		}
+		want := packets[idx]
+		if packet.Marker != want.Marker || packet.PayloadType != want.PayloadType || packet.Sequence != want.Sequence ||
+			packet.Phone != want.Phone || packet.Channel != want.Channel || packet.DataType != want.DataType ||
+			packet.Subpackage != want.Subpackage || packet.Timestamp != want.Timestamp ||
+			packet.LastIFrameInterval != want.LastIFrameInterval || !bytes.Equal(packet.Body, want.Body) {
+			t.Fatalf("packet %d: got %+v, want %+v", idx, packet, want)
+		}
+		bts = bts[n:]
+	}
+
+	// 不完整的包
+	if packet, n, err := ParseRTPPacket(buf.Bytes()[:20]); nil != packet || 0 != n || nil != err {
+		t.Fatalf("partial packet: %v, %d, %v", packet, n, err)
+	}
+}
+
+func TestRTPCodecResync(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xAA, 0x30, 0x31})
+	packet := RTPPacket{PayloadType: PayloadTypeG711U, Phone: "13912345678", Channel: 3, DataType: RTPDataTypeAudio, Body: []byte{1, 2, 3}}
+	packet.WriteTo(&buf)
+	buf.Write([]byte{0x30, 0x31, 0x63})
+
+	r := RTPCodec(RTPMaxPacketSize).(*rtpCodec)
+	r.readIdx = copy(r.readBuf, buf.Bytes())
+
+	var packets []*RTPPacket
+	r.split(func(p *RTPPacket) {
+		packets = append(packets, p)
+	})
+	if len(packets) != 1 || packets[0].Channel != 3 || packets[0].Phone != "013912345678" {
+		t.Fatalf("unexpected packets: %+v", packets)
+	}
+	// 末尾不完整的帧头标识保留在读缓存中
+	if r.readIdx != 3 {
+		t.Fatalf("got %d pending bytes, want 3", r.readIdx)
+	}
+}
@@ -0,0 +1,172 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 终端控制命令字（0x8105）
+const (
+	TerminalCtrlUpgrade       = byte(1) // 无线升级
+	TerminalCtrlConnectServer = byte(2) // 控制终端连接指定服务器
+	TerminalCtrlShutdown      = byte(3) // 终端关机
+	TerminalCtrlReset         = byte(4) // 终端复位
+	TerminalCtrlFactoryReset  = byte(5) // 终端恢复出厂设置
+	TerminalCtrlCloseDataLink = byte(6) // 关闭数据通信
+	TerminalCtrlCloseWireless = byte(7) // 关闭所有无线通信
+)
+
+// 连接控制
+const (
+	ConnectToServer = byte(0) // 切换到指定监管平台服务器，连接后即进入应急状态
+	ConnectToOrigin = byte(1) // 切换回原缺省监控平台服务器，并恢复正常状态
+)
+
+// WirelessUpgradeParam 无线升级参数
+type WirelessUpgradeParam struct {
+	// 升级文件完整地址
+	URL string `json:"url"`
+	// 拨号点名称，一般为服务器APN
+	APN string `json:"apn"`
+	// 拨号用户名
+	User string `json:"user"`
+	// 拨号密码
+	Password string `json:"password"`
+	// 服务器地址，IP或域名
+	Address string `json:"address"`
+	// 服务器TCP端口
+	TCPPort uint16 `json:"tcp_port"`
+	// 服务器UDP端口
+	UDPPort uint16 `json:"udp_port"`
+	// 制造商ID
+	VendorID string `json:"vendor_id"`
+	// 硬件版本
+	HWVersion string `json:"hw_version"`
+	// 固件版本
+	FWVersion string `json:"fw_version"`
+	// 连接到指定服务器时限，单位：分钟，为0时表示一直连接
+	Timeout uint16 `json:"timeout"`
+}
+
+// String 以半角分号连接各参数
+func (p *WirelessUpgradeParam) String() string {
+	return strings.Join([]string{
+		p.URL, p.APN, p.User, p.Password, p.Address,
+		strconv.Itoa(int(p.TCPPort)), strconv.Itoa(int(p.UDPPort)),
+		p.VendorID, p.HWVersion, p.FWVersion, strconv.Itoa(int(p.Timeout)),
+	}, ";")
+}
+
+// ConnectServerParam 控制终端连接指定服务器参数
+type ConnectServerParam struct {
+	// 连接控制，见ConnectToXXX
+	Control byte `json:"control"`
+	// 监管平台鉴权码
+	AuthCode string `json:"auth_code"`
+	// 拨号点名称，一般为服务器APN
+	APN string `json:"apn"`
+	// 拨号用户名
+	User string `json:"user"`
+	// 拨号密码
+	Password string `json:"password"`
+	// 服务器地址，IP或域名
+	Address string `json:"address"`
+	// 服务器TCP端口
+	TCPPort uint16 `json:"tcp_port"`
+	// 服务器UDP端口
+	UDPPort uint16 `json:"udp_port"`
+	// 连接到指定服务器时限，单位：分钟，为0时表示一直连接
+	Timeout uint16 `json:"timeout"`
+}
+
+// String 以半角分号连接各参数，切换回原服务器时后续参数无意义，只保留连接控制
+func (p *ConnectServerParam) String() string {
+	if ConnectToOrigin == p.Control {
+		return strconv.Itoa(int(p.Control))
+	}
+	return strings.Join([]string{
+		strconv.Itoa(int(p.Control)), p.AuthCode, p.APN, p.User, p.Password, p.Address,
+		strconv.Itoa(int(p.TCPPort)), strconv.Itoa(int(p.UDPPort)), strconv.Itoa(int(p.Timeout)),
+	}, ";")
+}
+
+// checkCtrlFields 参数中不能包含分隔符
+func checkCtrlFields(fields ...string) error {
+	for _, field := range fields {
+		if strings.Contains(field, ";") {
+			return fmt.Errorf("parameter %q must not contain ';'", field)
+		}
+	}
+	return nil
+}
+
+// NewMsgWirelessUpgrade 新建无线升级终端控制消息
+func NewMsgWirelessUpgrade(param WirelessUpgradeParam) (*MsgTerminalControl, error) {
+	if "" == param.URL {
+		return nil, errors.New("the upgrade url is required")
+	}
+	err := checkCtrlFields(param.URL, param.APN, param.User, param.Password, param.Address,
+		param.VendorID, param.HWVersion, param.FWVersion)
+	if nil != err {
+		return nil, err
+	}
+
+	msg := NewMsgTerminalControl()
+	msg.Cmd, msg.Value = TerminalCtrlUpgrade, param.String()
+	return msg, nil
+}
+
+// NewMsgConnectServer 新建控制终端连接指定服务器消息
+func NewMsgConnectServer(param ConnectServerParam) (*MsgTerminalControl, error) {
+	switch param.Control {
+	case ConnectToOrigin:
+	case ConnectToServer:
+		if "" == param.Address {
+			return nil, errors.New("the server address is required")
+		}
+		err := checkCtrlFields(param.AuthCode, param.APN, param.User, param.Password, param.Address)
+		if nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown connection control %d", param.Control)
+	}
+
+	msg := NewMsgTerminalControl()
+	msg.Cmd, msg.Value = TerminalCtrlConnectServer, param.String()
+	return msg, nil
+}
+
+// NewMsgTerminalShutdown 新建终端关机消息
+func NewMsgTerminalShutdown() *MsgTerminalControl {
+	return newTerminalCtrl(TerminalCtrlShutdown)
+}
+
+// NewMsgTerminalReset 新建终端复位消息
+func NewMsgTerminalReset() *MsgTerminalControl {
+	return newTerminalCtrl(TerminalCtrlReset)
+}
+
+// NewMsgTerminalFactoryReset 新建终端恢复出厂设置消息
+func NewMsgTerminalFactoryReset() *MsgTerminalControl {
+	return newTerminalCtrl(TerminalCtrlFactoryReset)
+}
+
+// NewMsgCloseDataLink 新建关闭数据通信消息
+func NewMsgCloseDataLink() *MsgTerminalControl {
+	return newTerminalCtrl(TerminalCtrlCloseDataLink)
+}
+
+// NewMsgCloseWireless 新建关闭所有无线通信消息
+func NewMsgCloseWireless() *MsgTerminalControl {
+	return newTerminalCtrl(TerminalCtrlCloseWireless)
+}
+
+// newTerminalCtrl 新建无参数的终端控制消息
+func newTerminalCtrl(cmd byte) *MsgTerminalControl {
+	msg := NewMsgTerminalControl()
+	msg.Cmd = cmd
+	return msg
+}
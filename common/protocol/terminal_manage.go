@@ -1,4 +1,4 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
@@ -7,7 +7,7 @@ import (
 
 func init() {
 	RegisterUnmarshals(&Unmarshal{
-		Cmd: msgIDTerminalLogin,
+		Cmd: MsgIDTerminalLogin,
 		NewUnmarshaler: func() Unmarshaler {
 			return terminalLoginUnmarshal
 		},
@@ -22,17 +22,17 @@ func init() {
 			return terminalAuthUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDGetTerminalParamsResp,
+		Cmd: MsgIDGetTerminalParamsResp,
 		NewUnmarshaler: func() Unmarshaler {
 			return getTerminalParamsRespUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDGetTerminalAttrResp,
+		Cmd: MsgIDGetTerminalAttrResp,
 		NewUnmarshaler: func() Unmarshaler {
 			return getTerminalAttrRespUnmarshal
 		},
 	}, &Unmarshal{
-		Cmd: msgIDTerminalUpgradeResp,
+		Cmd: MsgIDTerminalUpgradeResp,
 		NewUnmarshaler: func() Unmarshaler {
 			return terminalUpgradeRespUnmarshal
 		},
@@ -195,11 +195,13 @@ func terminalLoginRespMarshal(output Output, version byte) ([]byte, error) {
 func setTerminalParamsMarshal(output Output, version byte) ([]byte, error) {
 	var buf bytes.Buffer
 
-	_, ok := output.(*MsgTerParamsSettings)
+	msg, ok := output.(*MsgTerParamsSettings)
 	if !ok {
 		return nil, errors.New("消息体数据与消息ID不符")
 	}
-	output.writeTo(&buf)
+	if err := msg.encode(&buf); nil != err {
+		return nil, err
+	}
 
 	return buf.Bytes(), nil
 }
@@ -264,7 +266,12 @@ func terminalUpgradeMarshal(output Output, version byte) ([]byte, error) {
 	if !ok {
 		return nil, errors.New("消息体数据与消息ID不符")
 	}
-	output.writeTo(&buf)
+
+	if version2011 == version && nil != output.base() {
+		output.base().writeTo(&buf)
+	} else {
+		output.writeTo(&buf)
+	}
 
 	return buf.Bytes(), nil
 }
@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestTerminalUpgradeMarshal(t *testing.T) {
+	msg := NewMsgTerminalUpgrade()
+	msg.Type = UpgradeTypeTerminal
+	msg.VendorID = "ABCDE"
+	msg.Version = "V1.2"
+	msg.Pkg = []byte{1, 2, 3}
+
+	want := append([]byte{0}, "ABCDE\x00\x00\x00\x00\x00\x00"...)
+	want = append(want, 4, 'V', '1', '.', '2', 0, 0, 0, 3, 1, 2, 3)
+	body, err := terminalUpgradeMarshal(msg, version2019)
+	if nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("2019:\ngot  % x\nwant % x", body, want)
+	}
+
+	// 2013版制造商ID为5字节
+	want = append(append([]byte{0}, "ABCDE"...), want[12:]...)
+	body, err = terminalUpgradeMarshal(msg, version2011)
+	if nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("2013:\ngot  % x\nwant % x", body, want)
+	}
+}
+
+func TestPacketResend(t *testing.T) {
+	var whole packet
+	whole.head.id = msgIDTerminalUpgrade
+	whole.head.number = 0x1234
+	whole.body = bytes.Repeat([]byte{1, 2, 3}, maxBodySize)
+	packs, err := whole.packs()
+	if nil != err || 3 != len(packs) {
+		t.Fatalf("got %d packs: %v", len(packs), err)
+	}
+
+	// 各子包的流水号自首包起依次递增，按首包流水号重组
+	var codec packetCodec
+	var reassembled *packet
+	for idx, pack := range packs {
+		var sub packet
+		if err := sub.unpack(pack); nil != err {
+			t.Fatal(err)
+		}
+		if 0x1234+uint16(idx) != sub.head.number {
+			t.Fatalf("subpackage %d has number %#x", idx+1, sub.head.number)
+		}
+		reassembled = codec.reassemble(&sub)
+	}
+	if nil == reassembled || 0x1234 != reassembled.head.number || !bytes.Equal(whole.body, reassembled.body) {
+		t.Fatal("the subpackages were not reassembled")
+	}
+
+	codec.remember(&whole)
+
+	// 补传第1、3包
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint16(body, 0x1234)
+	binary.BigEndian.PutUint16(body[2:], 2)
+	binary.BigEndian.PutUint16(body[4:], 1)
+	binary.BigEndian.PutUint16(body[6:], 3)
+	resent := codec.resend(body)
+	if 2 != len(resent) || !bytes.Equal(packs[0], resent[0]) || !bytes.Equal(packs[2], resent[1]) {
+		t.Fatalf("unexpected resent packs: %d", len(resent))
+	}
+
+	// 未知流水号
+	binary.BigEndian.PutUint16(body, 0x4321)
+	if resent := codec.resend(body); 0 != len(resent) {
+		t.Fatalf("unexpected resent packs for unknown number: %d", len(resent))
+	}
+}
+
+func TestReassembleLimits(t *testing.T) {
+	var codec packetCodec
+	sub := func(number, total, index uint16, size int) *packet {
+		return &packet{head: head{id: msgIDMultimediaDataReport, number: number, pack: packIndex{total: total, index: index}}, body: make([]byte, size)}
+	}
+
+	// 超出同时接收的分包消息数时丢弃最早的
+	for idx := 0; idx <= maxPendingMessages; idx++ {
+		codec.reassemble(sub(uint16(idx*10), 2, 1, 10))
+	}
+	if maxPendingMessages != len(codec.pending) || 10*maxPendingMessages != codec.pendingBytes {
+		t.Fatalf("got %d pending messages of %d bytes", len(codec.pending), codec.pendingBytes)
+	}
+	if _, ok := codec.pending[uint32(msgIDMultimediaDataReport)<<16]; ok {
+		t.Fatal("the oldest message was not dropped")
+	}
+
+	// 超出缓存字节数时丢弃该分包消息
+	idx := uint16(1)
+	for ; codec.pendingBytes+maxBodySize <= maxPendingBytes; idx++ {
+		codec.reassemble(sub(1000+idx-1, 0xffff, idx, maxBodySize))
+	}
+	if nil != codec.reassemble(sub(1000+idx-1, 0xffff, idx, maxBodySize)) || maxPendingMessages-1 != len(codec.pending) || 10*(maxPendingMessages-1) != codec.pendingBytes {
+		t.Fatalf("got %d pending messages of %d bytes", len(codec.pending), codec.pendingBytes)
+	}
+}
+
+func TestTerminalAttrUnmarshal(t *testing.T) {
+	body := []byte{0x00, 0x01}
+	body = append(body, "ACME\x00\x00\x00\x00\x00\x00\x00"...)
+	body = append(body, bytes.Repeat([]byte{'M'}, 30)...)
+	body = append(body, bytes.Repeat([]byte{'T'}, 30)...)
+	body = append(body, 0x89, 0x86, 0x01, 0x23, 0x45, 0x67, 0x89, 0x01, 0x23, 0x45)
+	body = append(body, 2, 'H', '1', 3, 'F', '2', '0', 0x03, 0x01)
+
+	input, err := getTerminalAttrRespUnmarshal(bytes.NewBuffer(body), version2019)
+	if nil != err {
+		t.Fatal(err)
+	}
+	attr := input.(*MsgGetTerminalAttrResp).TerminalAttr
+	if "89860123456789012345" != attr.ICCID || "H1" != attr.HWVersion || "F20" != attr.FWVersion ||
+		!attr.GnssAttr.IsGPS() || !attr.GnssAttr.IsBDS() || 0x01 != attr.CommAttr {
+		t.Fatalf("unexpected attr: %+v", attr)
+	}
+}
+
+func TestTerminalLoginResp(t *testing.T) {
+	msg := NewMsgTerminalLoginResp()
+	if MsgIDTerminalLogin|0x8000 != msg.ID {
+		t.Fatalf("unexpected id: %#x", msg.ID)
+	}
+}
+
+func TestTerminalControlMarshal(t *testing.T) {
+	upgrade, err := NewMsgWirelessUpgrade(WirelessUpgradeParam{URL: "ftp://host/fw.bin", Address: "host", TCPPort: 21, VendorID: "ACME", FWVersion: "2.0"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	body, err := terminalControlMarshal(upgrade, version2019)
+	if want := "\x01ftp://host/fw.bin;;;;host;21;0;ACME;;2.0;0"; nil != err || want != string(body) {
+		t.Fatalf("got %q, want %q", body, want)
+	}
+
+	connect, err := NewMsgConnectServer(ConnectServerParam{Control: ConnectToOrigin, Address: "ignored"})
+	if body, _ = terminalControlMarshal(connect, version2019); nil != err || "\x021" != string(body) {
+		t.Fatalf("unexpected connect body %q: %v", body, err)
+	}
+	if _, err := NewMsgConnectServer(ConnectServerParam{Address: "a;b"}); nil == err {
+		t.Fatal("separator in parameter must be rejected")
+	}
+
+	// 无参数的命令只写入命令字
+	reset := NewMsgTerminalReset()
+	reset.Value = "ignored"
+	if body, _ = terminalControlMarshal(reset, version2019); !bytes.Equal([]byte{TerminalCtrlReset}, body) {
+		t.Fatalf("unexpected reset body % x", body)
+	}
+}
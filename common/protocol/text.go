@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/axgle/mahonia"
+)
+
+// 文本信息级别（2019版文本标志位0~1），2011版只有紧急标志（位0）
+const (
+	TextLevelService   = byte(1) // 服务
+	TextLevelEmergency = byte(2) // 紧急
+	TextLevelNotice    = byte(3) // 通知
+)
+
+// 文本类型（2019版）
+const (
+	TextTypeNotice  = byte(1) // 通知
+	TextTypeService = byte(2) // 服务
+)
+
+// MaxTextLength 文本信息GBK编码后的最大字节数
+const MaxTextLength = 1024
+
+// TextOptions 文本信息下发选项
+type TextOptions struct {
+	// 紧急
+	Emergency bool `json:"emergency"`
+	// 终端显示器显示
+	Display bool `json:"display"`
+	// 终端TTS播读
+	TTS bool `json:"tts"`
+	// 广告屏显示，2019版已保留
+	Advertising bool `json:"advertising"`
+	// 文本为CAN故障码信息，否则为中心导航信息
+	CANFault bool `json:"can_fault"`
+	// 文本类型（2019版），见TextTypeXXX，为0时为通知
+	Type byte `json:"type"`
+}
+
+// Flag 按2019版生成文本标志，非紧急时级别与文本类型一致
+func (o *TextOptions) Flag() TextFlag {
+	flag := TextFlag(TextLevelNotice)
+	if o.Emergency {
+		flag = TextFlag(TextLevelEmergency)
+	} else if TextTypeService == o.Type {
+		flag = TextFlag(TextLevelService)
+	}
+	if o.Display {
+		flag |= 4
+	}
+	if o.TTS {
+		flag |= 8
+	}
+	if o.Advertising {
+		flag |= 16
+	}
+	if o.CANFault {
+		flag |= 32
+	}
+	return flag
+}
+
+// flag2011 按2011版生成文本标志，2011版只有紧急标志（位0）
+func (o *TextOptions) flag2011() TextFlag {
+	flag := o.Flag() &^ 0x03
+	if o.Emergency {
+		flag |= 1
+	}
+	return flag
+}
+
+// TextLength 文本GBK编码后的字节数，包含GBK无法编码的字符时返回错误
+func TextLength(text string) (int, error) {
+	encoded := mahonia.NewEncoder("gbk").ConvertString(text)
+	if text != mahonia.NewDecoder("gbk").ConvertString(encoded) {
+		return 0, errors.New("the text contains characters that cannot be encoded in GBK")
+	}
+	return len(encoded), nil
+}
+
+// NewMsgText 新建文本信息下发消息，校验文本长度及文本类型，按下发时终端的协议版本生成文本标志
+func NewMsgText(text string, options TextOptions) (*MsgTextIssued, error) {
+	if "" == text {
+		return nil, errors.New("the text is required")
+	}
+	length, err := TextLength(text)
+	if nil != err {
+		return nil, err
+	}
+	if length > MaxTextLength {
+		return nil, fmt.Errorf("the text is %d bytes in GBK, exceeding %d", length, MaxTextLength)
+	}
+
+	switch options.Type {
+	case 0:
+		options.Type = TextTypeNotice
+	case TextTypeNotice, TextTypeService:
+	default:
+		return nil, fmt.Errorf("unknown text type %d", options.Type)
+	}
+
+	// 标志按2019版生成，向2011版终端下发时使用2011版标志
+	flag2011 := options.flag2011()
+	msg := NewMsgTextIssued()
+	msg.Flag, msg.Type, msg.Text = options.Flag(), options.Type, text
+	msg.flag2011 = &flag2011
+	return msg, nil
+}
@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextMarshal(t *testing.T) {
+	msg, err := NewMsgText("你好", TextOptions{Emergency: true, Display: true, TTS: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !msg.Flag.IsEmergency() || !msg.Flag.IsShow() || !msg.Flag.IsTTS() || TextTypeNotice != msg.Type {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	body, err := textIssuedMarshal(msg, version2019)
+	if want := []byte{0x0E, TextTypeNotice, 0xC4, 0xE3, 0xBA, 0xC3}; nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("got % x, want % x", body, want)
+	}
+	// 2011版没有文本类型，紧急标志为位0
+	body, err = textIssuedMarshal(msg, version2011)
+	if want := []byte{0x0D, 0xC4, 0xE3, 0xBA, 0xC3}; nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("got % x, want % x", body, want)
+	}
+
+	service, _ := NewMsgText("a", TextOptions{Type: TextTypeService, Advertising: true})
+	if TextLevelService != service.Flag.Type() || !service.Flag.IsAdvertising() {
+		t.Fatalf("unexpected flag %08b", service.Flag)
+	}
+	if body, _ = textIssuedMarshal(service, version2011); 0x10 != body[0] {
+		t.Fatalf("unexpected 2011 flag %08b", body[0])
+	}
+
+	// 直接设置标志的消息按原样下发
+	issued := NewMsgTextIssued()
+	issued.Flag, issued.Text = 0x01, "a"
+	if body, _ = textIssuedMarshal(issued, version2011); 0x01 != body[0] {
+		t.Fatalf("unexpected 2011 flag %08b", body[0])
+	}
+
+	// 按GBK编码后的长度校验
+	if _, err := NewMsgText(strings.Repeat("中", MaxTextLength/2), TextOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := NewMsgText(strings.Repeat("中", MaxTextLength/2+1), TextOptions{}); nil == err {
+		t.Fatal("expected an error for a text exceeding the limit")
+	}
+	if _, err := NewMsgText("😀", TextOptions{}); nil == err {
+		t.Fatal("expected an error for a text that cannot be encoded in GBK")
+	}
+	if _, err := NewMsgText("a", TextOptions{Type: 3}); nil == err {
+		t.Fatal("expected an error for an unknown text type")
+	}
+}
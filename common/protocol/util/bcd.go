@@ -0,0 +1,91 @@
+package util
+
+import (
+	"errors"
+	"time"
+)
+
+// 0的ASCII码值
+const zero = byte(48)
+
+// 9的ASCII码值
+const nine = byte(57)
+
+// ToBCD 数字字符（ASCII）转BCD码
+func ToBCD(src []byte) []byte {
+	length := len(src)
+	if length <= 0 {
+		return nil
+	}
+
+	bts := make([]byte, (length+1)>>1)
+	for i := 0; i < length; i += 2 {
+		check(src[i])
+		bts[i>>1] = (src[i] - zero) << 4
+		if i+1 < length {
+			check(src[i+1])
+			bts[i>>1] |= src[i+1] - zero
+		}
+	}
+
+	return bts
+}
+
+// ParseBCD 解析BCD码为数字字符（ASCII）
+func ParseBCD(src []byte) []byte {
+	length := len(src)
+	if length <= 0 {
+		return nil
+	}
+
+	bts := make([]byte, length<<1)
+	for idx, bt := range src {
+		bts[idx<<1] = (bt >> 4) + zero
+		bts[(idx<<1)+1] = (bt & 0x0F) + zero
+	}
+
+	return bts
+}
+
+// 检查字符是否是数字字符
+func check(bt byte) {
+	if bt < zero || bt > nine {
+		panic("BCD must digital character.")
+	}
+}
+
+// ParseBCDTime 解析6字节BCD码时间（YYMMDDhhmmss），与time.ParseInLocation("060102150405")
+// 结果一致，但不产生内存分配
+func ParseBCDTime(src []byte, loc *time.Location) (time.Time, error) {
+	if len(src) < 6 {
+		return time.Time{}, errors.New("BCD time must be 6 bytes")
+	}
+
+	var fields [6]int
+	for idx, bt := range src[:6] {
+		hi, lo := bt>>4, bt&0x0F
+		if hi > 9 || lo > 9 {
+			return time.Time{}, errors.New("BCD time must be digital character")
+		}
+		fields[idx] = int(hi)*10 + int(lo)
+	}
+
+	year, month, day, hour, min, sec := fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5]
+	// 与time包对两位年份的处理保持一致
+	if year >= 69 {
+		year += 1900
+	} else {
+		year += 2000
+	}
+
+	if month < time.January || month > time.December || day < 1 || hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, errors.New("BCD time out of range")
+	}
+
+	t := time.Date(year, month, day, hour, min, sec, 0, loc)
+	if t.Day() != day {
+		return time.Time{}, errors.New("BCD time out of range")
+	}
+
+	return t, nil
+}
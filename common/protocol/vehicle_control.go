@@ -0,0 +1,193 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+func init() {
+	RegisterUnmarshals(&Unmarshal{
+		Cmd: MsgIDVehicleControlResp,
+		NewUnmarshaler: func() Unmarshaler {
+			return vehicleControlRespUnmarshal
+		},
+	})
+
+	RegisterMarshals(&Marshal{
+		Cmd: msgIDVehicleControl,
+		NewMarshaler: func() Marshaler {
+			return vehicleControlMarshal
+		},
+	})
+}
+
+// 车辆控制
+func vehicleControlMarshal(output Output, version byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	_, ok := output.(*MsgVehicleControl)
+	if !ok {
+		return nil, errors.New("消息体数据与消息ID不符")
+	}
+
+	if version2011 == version && nil != output.base() {
+		output.base().writeTo(&buf)
+	} else {
+		output.writeTo(&buf)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 车辆控制应答
+func vehicleControlRespUnmarshal(buf *bytes.Buffer, version byte) (Input, error) {
+	var msg MsgVehicleControlResp
+
+	if buf.Len() < 30 {
+		return nil, errors.New("the bad protocol data:body error")
+	}
+
+	msg.readBy(buf)
+
+	return &msg, nil
+}
+
+// 车辆控制类型ID
+const (
+	VehCtrlIDDoor    = uint16(0x0001) // 车门
+	VehCtrlIDOil     = uint16(0xF001) // 油路（厂商扩展）
+	VehCtrlIDCircuit = uint16(0xF002) // 电路（厂商扩展）
+)
+
+// 车门控制值，与2019版一致，2011版下发时转换为控制标志
+const (
+	VehCtrlDoorLock = byte(0) // 车门锁闭
+	VehCtrlDoorOpen = byte(1) // 车门开启
+)
+
+// 油路、电路控制值
+const (
+	VehCtrlRestore = byte(0) // 恢复
+	VehCtrlCut     = byte(1) // 断开
+)
+
+// VehCtrlDef 车辆控制类型定义
+type VehCtrlDef struct {
+	// 控制类型ID
+	ID uint16 `json:"id"`
+	// 名称
+	Name string `json:"name"`
+	// 控制参数编码类型：uint8、uint16、uint32、string（GBK编码）、[]uint8（原始字节）
+	Type string `json:"type"`
+	// 控制参数说明
+	Description string `json:"description"`
+	// 定义来源，见ParamStdXxx
+	Standard string `json:"standard"`
+	// 控制生效后应答位置信息中对应的状态位，为0时不校验
+	StatusMask uint32 `json:"status_mask"`
+	// 使状态位置位的控制参数值，其他取值应使状态位清零
+	SetValue uint32 `json:"set_value"`
+}
+
+// 内置的车辆控制类型定义，厂商扩展的控制类型ID各厂商不一，可通过RegisterVehCtrls覆盖
+var builtinVehCtrlDefs = []VehCtrlDef{
+	{VehCtrlIDDoor, "车门", "uint8", "0：车门锁闭；1：车门开启", ParamStd2019, 1 << 12, uint32(VehCtrlDoorLock)},
+	{VehCtrlIDOil, "油路", "uint8", "0：恢复油路；1：断开油路", ParamStdVendor, 1 << 10, uint32(VehCtrlCut)},
+	{VehCtrlIDCircuit, "电路", "uint8", "0：恢复电路；1：断开电路", ParamStdVendor, 1 << 11, uint32(VehCtrlCut)},
+}
+
+var (
+	vehCtrlDefMtx sync.RWMutex
+	vehCtrlDefs   = make(map[uint16]*VehCtrlDef)
+)
+
+func init() {
+	RegisterVehCtrls(builtinVehCtrlDefs...)
+}
+
+// RegisterVehCtrls 注册车辆控制类型，可在运行时调用，覆盖同id的已有定义
+func RegisterVehCtrls(defs ...VehCtrlDef) {
+	vehCtrlDefMtx.Lock()
+	defer vehCtrlDefMtx.Unlock()
+
+	for idx := range defs {
+		def := defs[idx]
+		vehCtrlDefs[def.ID] = &def
+	}
+}
+
+// LookupVehCtrl 获取车辆控制类型定义
+func LookupVehCtrl(id uint16) (VehCtrlDef, bool) {
+	vehCtrlDefMtx.RLock()
+	defer vehCtrlDefMtx.RUnlock()
+
+	if def, ok := vehCtrlDefs[id]; ok {
+		return *def, true
+	}
+	return VehCtrlDef{}, false
+}
+
+// VehCtrlDefs 获取全部车辆控制类型定义，按id升序排列
+func VehCtrlDefs() []VehCtrlDef {
+	vehCtrlDefMtx.RLock()
+	defs := make([]VehCtrlDef, 0, len(vehCtrlDefs))
+	for _, def := range vehCtrlDefs {
+		defs = append(defs, *def)
+	}
+	vehCtrlDefMtx.RUnlock()
+
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].ID < defs[j].ID
+	})
+	return defs
+}
+
+// NewVehCtrlParam 按控制类型定义新建车辆控制参数，value可为任意整数类型，按定义转换为对应宽度
+func NewVehCtrlParam(id uint16, value interface{}) (VehCtrlParam, error) {
+	def, ok := LookupVehCtrl(id)
+	if !ok {
+		return VehCtrlParam{}, fmt.Errorf("unknown vehicle control type %#04x", id)
+	}
+	value, err := convertParamValue(def.Type, value)
+	if nil != err {
+		return VehCtrlParam{}, fmt.Errorf("vehicle control %#04x: %s", id, err)
+	}
+	return VehCtrlParam{ID: id, Value: value}, nil
+}
+
+// vehCtrlValue 按控制类型定义转换控制参数值，未定义的控制类型保留原值
+func vehCtrlValue(param *VehCtrlParam) interface{} {
+	def, ok := LookupVehCtrl(param.ID)
+	if !ok {
+		return param.Value
+	}
+	if value, err := convertParamValue(def.Type, param.Value); nil == err {
+		return value
+	}
+	return param.Value
+}
+
+// VerifyVehCtrl 以车辆控制应答位置信息中的状态位校验控制结果，返回状态不符的控制类型ID，
+// 未定义状态位的控制类型不校验
+func VerifyVehCtrl(params []VehCtrlParam, status StatusFlag) []uint16 {
+	var mismatched []uint16
+	for idx := range params {
+		def, ok := LookupVehCtrl(params[idx].ID)
+		if !ok || 0 == def.StatusMask {
+			continue
+		}
+		value, err := paramUint(vehCtrlValue(&params[idx]), 32)
+		if nil != err {
+			mismatched = append(mismatched, params[idx].ID)
+			continue
+		}
+		set := 0 != uint32(status)&def.StatusMask
+		if set != (uint32(value) == def.SetValue) {
+			mismatched = append(mismatched, params[idx].ID)
+		}
+	}
+	return mismatched
+}
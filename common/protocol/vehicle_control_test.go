@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestVehicleControlMarshal(t *testing.T) {
+	msg := NewMsgVehicleControl()
+	door, err := NewVehCtrlParam(VehCtrlIDDoor, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	// JSON解析的数值为float64，按定义编码为BYTE
+	msg.Params = []VehCtrlParam{door, {ID: VehCtrlIDOil, Value: float64(1)}}
+
+	body, err := vehicleControlMarshal(msg, version2019)
+	want := []byte{0x00, 0x02, 0x00, 0x01, 0x00, 0xF0, 0x01, 0x01}
+	if nil != err || !bytes.Equal(want, body) {
+		t.Fatalf("2019:\ngot  % x\nwant % x", body, want)
+	}
+
+	// 2011版车门锁闭对应控制标志1
+	body, err = vehicleControlMarshal(msg, version2011)
+	if nil != err || !bytes.Equal([]byte{0x01}, body) {
+		t.Fatalf("2011: got % x", body)
+	}
+
+	if _, err := NewVehCtrlParam(VehCtrlIDDoor, 256); nil == err {
+		t.Fatal("overflow value must be rejected")
+	}
+	if _, err := NewVehCtrlParam(0x1234, 1); nil == err {
+		t.Fatal("unknown control type must be rejected")
+	}
+}
+
+func TestVehicleControlResp(t *testing.T) {
+	body := make([]byte, 30)
+	binary.BigEndian.PutUint16(body, 0x0102)
+	// 状态位：车门加锁、油路断开
+	binary.BigEndian.PutUint32(body[6:], 1<<12|1<<10)
+
+	input, err := vehicleControlRespUnmarshal(bytes.NewBuffer(body), version2019)
+	if nil != err {
+		t.Fatal(err)
+	}
+	resp := input.(*MsgVehicleControlResp)
+	if 0x0102 != resp.ReqNum {
+		t.Fatalf("unexpected req num %#x", resp.ReqNum)
+	}
+
+	params := []VehCtrlParam{
+		{ID: VehCtrlIDDoor, Value: VehCtrlDoorLock},
+		{ID: VehCtrlIDOil, Value: VehCtrlCut},
+		{ID: VehCtrlIDCircuit, Value: VehCtrlCut},
+	}
+	mismatched := VerifyVehCtrl(params, resp.Position.Status)
+	if 1 != len(mismatched) || VehCtrlIDCircuit != mismatched[0] {
+		t.Fatalf("unexpected mismatched: %v", mismatched)
+	}
+}
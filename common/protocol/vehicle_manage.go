@@ -1,4 +1,4 @@
-package jtt
+package protocol
 
 import (
 	"bytes"
@@ -7,7 +7,7 @@ import (
 
 func init() {
 	RegisterUnmarshals(&Unmarshal{
-		Cmd: msgIDGetAreaResp,
+		Cmd: MsgIDGetAreaResp,
 		NewUnmarshaler: func() Unmarshaler {
 			return getAreaOrPathRespUnmarshal
 		},
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// areaRespBody 将设置区域消息体转为查询区域应答消息体：查询类型、DWORD数量及区域数据
+func areaRespBody(tp ShapeType, count int, items []byte) []byte {
+	return append([]byte{tp, 0, 0, 0, byte(count)}, items...)
+}
+
+func TestAreaRoundTrip(t *testing.T) {
+	round := RoundArea{ID: 1, CenterY: 22500000, CenterX: 113900000, Radius: 500, MaxSpeed: 60, Duration: 10, MaxSpeedInNight: 40, Name: "仓库"}
+	round.Attr.SetSpeedEnable(true)
+	set := NewMsgRoundAreaSettings()
+	set.Operation, set.Areas = AreaOpAppend, []RoundArea{round}
+
+	for _, version := range []byte{version2019, version2011} {
+		body, err := setRoundAreaMarshal(set, version)
+		if nil != err || AreaOpAppend != body[0] || 1 != body[1] {
+			t.Fatalf("unexpected body % x: %v", body, err)
+		}
+		input, err := getAreaOrPathRespUnmarshal(bytes.NewBuffer(areaRespBody(ShapeTypeCircle, 1, body[2:])), version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		want := round
+		if version2011 == version {
+			// 2011版无夜间最高速度及区域名称
+			want.MaxSpeedInNight, want.Name = 0xFFFF, ""
+		}
+		areas := input.(*MsgGetAreaOrPathResp).Areas
+		if 1 != len(areas) || !reflect.DeepEqual(&want, areas[0]) {
+			t.Fatalf("version %d: unexpected areas %+v", version, areas)
+		}
+	}
+}
+
+func TestPolylineRoundTrip(t *testing.T) {
+	path := NewMsgPathSettings()
+	path.Path = Polyline{ID: 7, Name: "线路", Vertexs: []Vertex{
+		{ID: 1, Latitude: 22500000, Longitude: 113900000, Tag: Segment{ID: 1, Width: 50, Attr: 2, MaxSpeed: 80, Duration: 5, MaxSpeedInNight: 60}},
+		{ID: 2, Latitude: 22600000, Longitude: 113950000, Tag: Segment{ID: 2, Width: 50}},
+	}}
+
+	for _, version := range []byte{version2019, version2011} {
+		body, err := setPathMarshal(path, version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		input, err := getAreaOrPathRespUnmarshal(bytes.NewBuffer(areaRespBody(ShapeTypeRoad, 1, body)), version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		want := path.Path
+		if version2011 == version {
+			want.Name = ""
+			want.Vertexs = append([]Vertex(nil), want.Vertexs...)
+			want.Vertexs[0].Tag.MaxSpeedInNight = 0xFFFF
+		}
+		areas := input.(*MsgGetAreaOrPathResp).Areas
+		if 1 != len(areas) || !reflect.DeepEqual(&want, areas[0]) {
+			t.Fatalf("version %d: unexpected areas %+v", version, areas)
+		}
+	}
+}
+
+func TestAreaRespCount(t *testing.T) {
+	round := RoundArea{ID: 1, CenterY: 22500000, CenterX: 113900000, Radius: 500}
+	set := NewMsgRoundAreaSettings()
+	set.Operation, set.Areas = AreaOpAppend, []RoundArea{round}
+	body, err := setRoundAreaMarshal(set, version2011)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	for _, version := range []byte{version2019, version2011} {
+		// 数量不可信，剩余长度不足一项时结束
+		resp := append([]byte{ShapeTypeCircle, 0x01, 0x01, 0x01, 0x01}, body[2:]...)
+		input, err := getAreaOrPathRespUnmarshal(bytes.NewBuffer(resp), version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if areas := input.(*MsgGetAreaOrPathResp).Areas; 1 != len(areas) || 1 != areas[0].(*RoundArea).ID {
+			t.Fatalf("version %d: unexpected areas %+v", version, areas)
+		}
+
+		// 顶点数不可信
+		resp = areaRespBody(ShapeTypeRoad, 1, []byte{0, 0, 0, 7, 0, 0, 0xFF, 0xFF})
+		input, err = getAreaOrPathRespUnmarshal(bytes.NewBuffer(resp), version)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if areas := input.(*MsgGetAreaOrPathResp).Areas; 1 != len(areas) || 0 != len(areas[0].(*Polyline).Vertexs) {
+			t.Fatalf("version %d: unexpected areas %+v", version, areas)
+		}
+	}
+}
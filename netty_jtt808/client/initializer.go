@@ -8,6 +8,6 @@ import (
 func ChannelInitializer() netty.ChannelInitializer {
 	return func(channel netty.Channel) {
 		channel.Pipeline().
-			AddLast(protocol.DelimiterCodec(0x7E, true, 2048))
+			AddLast(protocol.DelimiterCodec(0x7E, true, 2048, protocol.MaxFrameSize))
 	}
 }